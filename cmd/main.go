@@ -1,20 +1,53 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/caarlos0/env/v6"
 	"github.com/couchbase/gocb/v2"
+	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 
+	"github.com/itsHabib/sim/internal/audit"
+	"github.com/itsHabib/sim/internal/bolt"
+	"github.com/itsHabib/sim/internal/cache"
+	"github.com/itsHabib/sim/internal/cloudfront"
+	"github.com/itsHabib/sim/internal/couchbase"
+	"github.com/itsHabib/sim/internal/dynamo"
 	"github.com/itsHabib/sim/internal/images"
 	"github.com/itsHabib/sim/internal/images/reader"
 	"github.com/itsHabib/sim/internal/images/service"
 	"github.com/itsHabib/sim/internal/images/writer"
+	"github.com/itsHabib/sim/internal/imaging"
+	"github.com/itsHabib/sim/internal/memory"
+	"github.com/itsHabib/sim/internal/migrate"
+	"github.com/itsHabib/sim/internal/rekognition"
 	"github.com/itsHabib/sim/internal/runner"
+	"github.com/itsHabib/sim/internal/schema"
+	"github.com/itsHabib/sim/internal/storage"
+	"github.com/itsHabib/sim/internal/textract"
+)
+
+const (
+	backendBolt      = "bolt"
+	backendCouchbase = "couchbase"
+	backendDynamoDB  = "dynamodb"
+	backendMemory    = "memory"
 )
 
 type config struct {
@@ -22,14 +55,121 @@ type config struct {
 
 	LocalstackURL string `env:"LOCALSTACK_URL"`
 
-	Region string `env:"REGION,required"`
+	Region string `env:"REGION"`
+
+	S3Accelerate    bool   `env:"S3_ACCELERATE" envDefault:"false"`
+	S3PathStyle     bool   `env:"S3_PATH_STYLE" envDefault:"false"`
+	S3ReplicaBucket string `env:"S3_REPLICA_BUCKET"`
+	S3ReplicaRegion string `env:"S3_REPLICA_REGION"`
+
+	Storage         string `env:"STORAGE,required"`
+	StorageProfiles string `env:"STORAGE_PROFILES"`
+	StorageProvider string `env:"STORAGE_PROVIDER" envDefault:"s3"`
+
+	CDNDistributionID string `env:"CDN_DISTRIBUTION_ID"`
+	CDNStorage        string `env:"CDN_STORAGE"`
+	CDNURL            string `env:"CDN_URL"`
+	CDNKeyPairID      string `env:"CDN_KEY_PAIR_ID"`
+	CDNPrivateKeyFile string `env:"CDN_PRIVATE_KEY_FILE"`
+
+	MirrorStorage string `env:"MIRROR_STORAGE"`
+	MirrorAsync   bool   `env:"MIRROR_ASYNC" envDefault:"false"`
+
+	ReconcileInterval time.Duration `env:"RECONCILE_INTERVAL" envDefault:"0"`
+	ReconcileGrace    time.Duration `env:"RECONCILE_GRACE" envDefault:"1m"`
+
+	Dedup bool `env:"DEDUP" envDefault:"false"`
+
+	AllowedTypes   []string `env:"ALLOWED_TYPES" envSeparator:","`
+	ValidateImages bool     `env:"VALIDATE_IMAGES" envDefault:"false"`
+	Compress       bool     `env:"COMPRESS" envDefault:"false"`
+	StripMetadata  bool     `env:"STRIP_METADATA" envDefault:"false"`
+
+	ThumbnailSizes []int  `env:"THUMBNAIL_SIZES" envSeparator:","`
+	Pipelines      string `env:"PIPELINES"`
+	Blurhash       bool   `env:"BLURHASH" envDefault:"false"`
+
+	Watermark         string  `env:"WATERMARK"`
+	WatermarkPosition string  `env:"WATERMARK_POSITION" envDefault:"bottom-right"`
+	WatermarkOpacity  float64 `env:"WATERMARK_OPACITY" envDefault:"0.5"`
+	WatermarkScale    float64 `env:"WATERMARK_SCALE" envDefault:"0.25"`
+
+	Moderation              string  `env:"MODERATION"`
+	ModerationPolicy        string  `env:"MODERATION_POLICY" envDefault:"flag"`
+	ModerationMinConfidence float64 `env:"MODERATION_MIN_CONFIDENCE" envDefault:"50"`
+
+	OCR string `env:"OCR"`
+
+	EncryptionKeyFile string `env:"ENCRYPTION_KEY_FILE"`
+
+	SSE         string `env:"SSE"`
+	SSEKMSKeyID string `env:"SSE_KMS_KEY_ID"`
+
+	StorageClass string `env:"STORAGE_CLASS"`
+
+	CacheControl       string `env:"CACHE_CONTROL"`
+	ContentDisposition string `env:"CONTENT_DISPOSITION"`
+
+	ObjectTags bool `env:"OBJECT_TAGS" envDefault:"false"`
+
+	IDFormat string `env:"ID_FORMAT" envDefault:"uuid"`
+	IDPrefix string `env:"ID_PREFIX"`
+
+	KeyLayout   string `env:"KEY_LAYOUT" envDefault:"default"`
+	KeyTemplate string `env:"KEY_TEMPLATE"`
+	KeyPrefix   string `env:"KEY_PREFIX" envDefault:"images"`
+
+	FSRoot string `env:"FS_ROOT"`
+
+	SFTPAddr           string `env:"SFTP_ADDR"`
+	SFTPUser           string `env:"SFTP_USER"`
+	SFTPKeyFile        string `env:"SFTP_KEY_FILE"`
+	SFTPKeyPassphrase  string `env:"SFTP_KEY_PASSPHRASE"`
+	SFTPKnownHostsFile string `env:"SFTP_KNOWN_HOSTS_FILE"`
+	SFTPRoot           string `env:"SFTP_ROOT"`
+
+	GCSEndpoint        string `env:"GCS_ENDPOINT"`
+	GCSCredentialsFile string `env:"GCS_CREDENTIALS_FILE"`
 
-	Storage string `env:"STORAGE,required"`
+	// MetadataBackend is read from STORAGE_METADATA, METADATA_BACKEND is an
+	// alias used when it is not set.
+	MetadataBackend      string `env:"STORAGE_METADATA"`
+	MetadataBackendAlias string `env:"METADATA_BACKEND"`
 
-	CouchbaseEndpoint string `env:"COUCHBASE_ENDPOINT,required"`
-	CouchbaseUsername string `env:"COUCHBASE_USERNAME,required"`
-	CouchbasePassword string `env:"COUCHBASE_PASSWORD,required"`
-	CouchbaseBucket   string `env:"COUCHBASE_BUCKET,required"`
+	SchemaRewrite bool `env:"SCHEMA_REWRITE" envDefault:"false"`
+
+	Audit bool   `env:"AUDIT" envDefault:"true"`
+	Actor string `env:"SIM_ACTOR"`
+
+	CouchbaseEndpoint string `env:"COUCHBASE_ENDPOINT"`
+	CouchbaseUsername string `env:"COUCHBASE_USERNAME"`
+	CouchbasePassword string `env:"COUCHBASE_PASSWORD"`
+	CouchbaseBucket   string `env:"COUCHBASE_BUCKET"`
+
+	CouchbaseCAFile        string `env:"COUCHBASE_CA_FILE"`
+	CouchbaseCertFile      string `env:"COUCHBASE_CERT_FILE"`
+	CouchbaseKeyFile       string `env:"COUCHBASE_KEY_FILE"`
+	CouchbaseTLSSkipVerify bool   `env:"COUCHBASE_TLS_SKIP_VERIFY" envDefault:"false"`
+
+	CouchbaseScope              string        `env:"COUCHBASE_SCOPE" envDefault:"default"`
+	CouchbaseCollection         string        `env:"COUCHBASE_COLLECTION" envDefault:"images"`
+	CouchbaseConnectTimeout     time.Duration `env:"COUCHBASE_CONNECT_TIMEOUT" envDefault:"10s"`
+	CouchbaseKVTimeout          time.Duration `env:"COUCHBASE_KV_TIMEOUT" envDefault:"3s"`
+	CouchbaseQueryTimeout       time.Duration `env:"COUCHBASE_QUERY_TIMEOUT" envDefault:"3s"`
+	CouchbaseReadyTimeout       time.Duration `env:"COUCHBASE_READY_TIMEOUT" envDefault:"3s"`
+	CouchbaseDisableCompression bool          `env:"COUCHBASE_DISABLE_COMPRESSION" envDefault:"false"`
+
+	BoltPath string `env:"BOLT_PATH"`
+
+	DynamoDBEndpoint string `env:"DYNAMODB_ENDPOINT"`
+	DynamoDBTable    string `env:"DYNAMODB_TABLE"`
+	DynamoDBAudit    string `env:"DYNAMODB_AUDIT_TABLE"`
+	DynamoDBActivity string `env:"DYNAMODB_ACTIVITY_TABLE"`
+
+	RedisAddr     string        `env:"REDIS_ADDR"`
+	RedisPassword string        `env:"REDIS_PASSWORD"`
+	RedisDB       int           `env:"REDIS_DB" envDefault:"0"`
+	RedisTTL      time.Duration `env:"REDIS_TTL" envDefault:"5m"`
 }
 
 func main() {
@@ -43,55 +183,599 @@ func main() {
 		log.Fatalf("unable to get logger: %s", err)
 	}
 
-	cluster, err := getCluster(cfg)
+	b, err := getRecords(cfg, logger)
 	if err != nil {
-		log.Fatalf("unable to get cb cluster connection: %s", err)
+		log.Fatalf("unable to get image record reader/writer: %s", err)
+	}
+	reader, writer := b.reader, b.writer
+	if cfg.RedisAddr != "" {
+		reader, writer, err = withCache(cfg, logger, reader, writer)
+		if err != nil {
+			log.Fatalf("unable to get image record cache: %s", err)
+		}
+	}
+	if cfg.Audit {
+		writer, err = audit.NewWriter(logger, writer, reader, b.history, actor(cfg))
+		if err != nil {
+			log.Fatalf("unable to get audit writer: %s", err)
+		}
 	}
 
-	writer, err := writer.NewService(logger, cluster, cfg.CouchbaseBucket)
+	stores, err := getStores(cfg, logger)
+	if err != nil {
+		log.Fatalf("unable to get object stores: %s", err)
+	}
+	var opts []service.Option
+	if cfg.MirrorStorage != "" {
+		opts = append(opts, service.WithMirror(cfg.MirrorStorage, cfg.MirrorAsync))
+	}
+	if cfg.ReconcileInterval > 0 {
+		opts = append(opts, service.WithReconciler(cfg.ReconcileInterval, cfg.ReconcileGrace))
+	}
+	if cfg.Dedup {
+		opts = append(opts, service.WithDedup())
+	}
+	if len(cfg.AllowedTypes) > 0 {
+		opts = append(opts, service.WithAllowedTypes(cfg.AllowedTypes...))
+	}
+	if cfg.ValidateImages {
+		opts = append(opts, service.WithImageValidation())
+	}
+	if cfg.Compress {
+		opts = append(opts, service.WithCompression())
+	}
+	if cfg.StripMetadata {
+		opts = append(opts, service.WithMetadataStripping())
+	}
+	if len(cfg.ThumbnailSizes) > 0 {
+		opts = append(opts, service.WithThumbnails(cfg.ThumbnailSizes...))
+	}
+	if cfg.Blurhash {
+		opts = append(opts, service.WithBlurhash())
+	}
+	if cfg.Pipelines != "" {
+		pipelines, err := images.LoadPipelines(cfg.Pipelines)
+		if err != nil {
+			log.Fatalf("unable to load pipelines: %s", err)
+		}
+		opts = append(opts, service.WithPipelines(pipelines))
+	}
+	if cfg.Watermark != "" {
+		watermark, err := readWatermark(cfg)
+		if err != nil {
+			log.Fatalf("unable to get watermark: %s", err)
+		}
+		opts = append(opts, service.WithWatermark(*watermark))
+	}
+	if cfg.Moderation != "" {
+		moderator, err := getModerator(cfg, logger)
+		if err != nil {
+			log.Fatalf("unable to get moderator: %s", err)
+		}
+		opts = append(opts, service.WithModeration(moderator, images.ModerationPolicy(cfg.ModerationPolicy)))
+	}
+	if cfg.OCR != "" {
+		extractor, err := getTextExtractor(cfg, logger)
+		if err != nil {
+			log.Fatalf("unable to get text extractor: %s", err)
+		}
+		opts = append(opts, service.WithTextExtraction(extractor))
+	}
+	if cfg.EncryptionKeyFile != "" {
+		key, err := readEncryptionKey(cfg.EncryptionKeyFile)
+		if err != nil {
+			log.Fatalf("unable to get encryption key: %s", err)
+		}
+		opts = append(opts, service.WithEncryption(key))
+	}
+	if cfg.SSE != "" || cfg.SSEKMSKeyID != "" {
+		opts = append(opts, service.WithServerSideEncryption(cfg.SSE, cfg.SSEKMSKeyID))
+	}
+	if cfg.StorageClass != "" {
+		opts = append(opts, service.WithStorageClass(cfg.StorageClass))
+	}
+	if cfg.CacheControl != "" {
+		opts = append(opts, service.WithCacheControl(cfg.CacheControl))
+	}
+	if cfg.ContentDisposition != "" {
+		opts = append(opts, service.WithContentDisposition(cfg.ContentDisposition))
+	}
+	if cfg.ObjectTags {
+		opts = append(opts, service.WithObjectTags())
+	}
+	if cfg.CDNDistributionID != "" {
+		dist, err := getCDN(cfg, logger)
+		if err != nil {
+			log.Fatalf("unable to get cdn distribution: %s", err)
+		}
+		opts = append(opts, service.WithCDN(cdnStorage(cfg), dist))
+	}
+	if cfg.CDNURL != "" {
+		signer, err := getURLSigner(cfg)
+		if err != nil {
+			log.Fatalf("unable to get cdn url signer: %s", err)
+		}
+		opts = append(opts, service.WithCDNURLs(cdnStorage(cfg), signer))
+	}
+	template := cfg.KeyTemplate
+	if template == "" {
+		if template, err = service.KeyLayout(cfg.KeyLayout); err != nil {
+			log.Fatalf("unable to get key template: %s", err)
+		}
+	}
+	keys, err := service.ParseKeyTemplate(template, cfg.KeyPrefix)
 	if err != nil {
-		log.Fatalf("unable to get writer: %s", err)
+		log.Fatalf("unable to get key template: %s", err)
 	}
-	reader, err := reader.NewService(logger, cluster, cfg.CouchbaseBucket)
+	opts = append(opts, service.WithKeyTemplate(keys))
+	newID, err := idGenerator(cfg)
 	if err != nil {
-		log.Fatalf("unable to get reader: %s", err)
+		log.Fatalf("unable to get id generator: %s", err)
 	}
-
-	awsCfg := getCfg(cfg)
-	svc, err := service.New(logger, cfg.Storage, reader, writer, images.WithSessionOptions(awsCfg))
+	opts = append(opts, service.WithIDGenerator(newID))
+	svc, err := service.New(logger, cfg.Storage, reader, writer, stores, opts...)
 	if err != nil {
 		log.Fatalf("unable to get service: %s", err)
 	}
-	runner := runner.NewRunner(logger, svc)
+	runnerOpts := []runner.Option{
+		runner.WithBackends(func(name string) (images.Reader, images.Writer, error) {
+			backend := *cfg
+			backend.MetadataBackend = name
+			b, err := getRecords(&backend, logger)
+			if err != nil {
+				return nil, nil, err
+			}
+			return b.reader, b.writer, nil
+		}),
+	}
+	if cfg.Audit {
+		runnerOpts = append(runnerOpts, runner.WithHistory(b.history), runner.WithActivity(b.activity, actor(cfg)))
+	}
+	if cfg.MetadataBackend == backendCouchbase {
+		runnerOpts = append(runnerOpts, runner.WithMigrations(func() (*migrate.Runner, error) {
+			return getMigrations(cfg, logger)
+		}), runner.WithIndexes(func() (*couchbase.Indexes, error) {
+			return getIndexes(cfg)
+		}))
+	}
+	runner := runner.NewRunner(logger, svc, runnerOpts...)
 
-	if err := runner.Run(); err != nil {
+	// an interrupt cancels the command instead of killing it part way
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err = runner.Run(ctx)
+	stop()
+	svc.Close()
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
-func getCfg(cfg *config) *aws.Config {
-	config := aws.
-		NewConfig().
-		WithRegion(cfg.Region)
+func getStores(cfg *config, logger *zap.Logger) (images.Stores, error) {
+	if cfg.StorageProfiles != "" {
+		profiles, err := storage.LoadProfiles(cfg.StorageProfiles)
+		if err != nil {
+			return nil, err
+		}
+		return storage.OpenAll(logger, profiles)
+	}
+
+	// without profiles a single storage is configured from the environment
+	profile := storage.Profile{
+		Provider:        cfg.StorageProvider,
+		Bucket:          cfg.Storage,
+		Region:          cfg.Region,
+		Accelerate:      cfg.S3Accelerate,
+		PathStyle:       cfg.S3PathStyle,
+		ReplicaBucket:   cfg.S3ReplicaBucket,
+		ReplicaRegion:   cfg.S3ReplicaRegion,
+		Endpoint:        cfg.GCSEndpoint,
+		CredentialsFile: cfg.GCSCredentialsFile,
+		Addr:            cfg.SFTPAddr,
+		User:            cfg.SFTPUser,
+		KeyFile:         cfg.SFTPKeyFile,
+		KeyPassphrase:   cfg.SFTPKeyPassphrase,
+		KnownHostsFile:  cfg.SFTPKnownHostsFile,
+	}
+	switch cfg.StorageProvider {
+	case storage.ProviderS3:
+		if cfg.LocalstackURL != "" {
+			profile.Endpoint = cfg.LocalstackURL
+			profile.AccessKeyID = "images"
+			profile.SecretAccessKey = "secret"
+		}
+	case storage.ProviderFS:
+		profile.Root = cfg.FSRoot
+	case storage.ProviderSFTP:
+		profile.Root = cfg.SFTPRoot
+	}
+
+	return storage.OpenAll(logger, map[string]storage.Profile{cfg.Storage: profile})
+}
+
+// backend represents the stores of a metadata backend.
+type backend struct {
+	reader   images.Reader
+	writer   images.Writer
+	history  audit.Store
+	activity audit.ActivityLog
+}
+
+// getRecords returns the stores of the configured metadata backend, the
+// records are upgraded to the current schema.
+func getRecords(cfg *config, logger *zap.Logger) (*backend, error) {
+	b, err := openRecords(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []schema.ReaderOption
+	if cfg.SchemaRewrite {
+		opts = append(opts, schema.WithRewrite(b.writer))
+	}
+	if b.reader, err = schema.NewReader(logger, b.reader, opts...); err != nil {
+		return nil, err
+	}
+	if b.writer, err = schema.NewWriter(logger, b.writer); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func openRecords(cfg *config, logger *zap.Logger) (*backend, error) {
+	switch cfg.MetadataBackend {
+	case backendBolt:
+		path := cfg.BoltPath
+		if path == "" {
+			var err error
+			if path, err = bolt.DefaultPath(); err != nil {
+				return nil, err
+			}
+		}
+		// the db is left open for the life of the process, bolt syncs every
+		// transaction and the file lock is released on exit
+		db, err := bolt.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		w, err := bolt.NewWriter(logger, db)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get writer: %w", err)
+		}
+		r, err := bolt.NewReader(logger, db)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get reader: %w", err)
+		}
+		return &backend{
+			reader:   r,
+			writer:   w,
+			history:  bolt.NewAuditStore(db),
+			activity: bolt.NewActivityLog(db),
+		}, nil
+	case backendCouchbase:
+		if cfg.CouchbaseEndpoint == "" || cfg.CouchbaseBucket == "" {
+			return nil, errors.New("COUCHBASE_ENDPOINT and COUCHBASE_BUCKET are required for the couchbase backend")
+		}
+		cluster, err := getCluster(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get cb cluster connection: %w", err)
+		}
+		w, err := writer.NewService(
+			logger,
+			cluster,
+			cfg.CouchbaseBucket,
+			writer.WithCollection(cfg.CouchbaseScope, cfg.CouchbaseCollection),
+			writer.WithReadyTimeout(cfg.CouchbaseReadyTimeout),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get writer: %w", err)
+		}
+		r, err := reader.NewService(
+			logger,
+			cluster,
+			cfg.CouchbaseBucket,
+			reader.WithCollection(cfg.CouchbaseScope, cfg.CouchbaseCollection),
+			reader.WithReadyTimeout(cfg.CouchbaseReadyTimeout),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get reader: %w", err)
+		}
+		return &backend{
+			reader:   r,
+			writer:   w,
+			history:  couchbase.NewAuditStore(cluster, cfg.CouchbaseBucket, cfg.CouchbaseScope, cfg.CouchbaseCollection),
+			activity: couchbase.NewActivityLog(cluster, cfg.CouchbaseBucket, cfg.CouchbaseScope, cfg.CouchbaseCollection),
+		}, nil
+	case backendDynamoDB:
+		client, err := getDynamoClient(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get dynamodb client: %w", err)
+		}
+		w, err := dynamo.NewWriter(logger, client, cfg.DynamoDBTable)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get writer: %w", err)
+		}
+		r, err := dynamo.NewReader(logger, client, cfg.DynamoDBTable)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get reader: %w", err)
+		}
+		auditTable := cfg.DynamoDBAudit
+		if auditTable == "" {
+			auditTable = cfg.DynamoDBTable + "-audit"
+		}
+		activityTable := cfg.DynamoDBActivity
+		if activityTable == "" {
+			activityTable = cfg.DynamoDBTable + "-activity"
+		}
+		return &backend{
+			reader:   r,
+			writer:   w,
+			history:  dynamo.NewAuditStore(client, auditTable),
+			activity: dynamo.NewActivityLog(client, activityTable),
+		}, nil
+	case backendMemory:
+		// nothing outlives the process, for trying sim out and for tests
+		records, err := memory.NewRecords(logger)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get records: %w", err)
+		}
+		return &backend{
+			reader:   records,
+			writer:   records,
+			history:  memory.NewAuditStore(),
+			activity: memory.NewActivityLog(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported metadata backend: %q", cfg.MetadataBackend)
+	}
+}
+
+// getMigrations returns the runner of the migrations which set up the
+// couchbase collection.
+func getMigrations(cfg *config, logger *zap.Logger) (*migrate.Runner, error) {
+	cluster, err := getCluster(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get cb cluster connection: %w", err)
+	}
+	migrations := couchbase.Migrations(cluster, cfg.CouchbaseBucket, cfg.CouchbaseScope, cfg.CouchbaseCollection)
+
+	return migrate.NewRunner(logger, couchbase.NewMigrationStore(cluster, cfg.CouchbaseBucket, cfg.CouchbaseScope, cfg.CouchbaseCollection), migrations)
+}
+
+// getIndexes returns the manager of the couchbase collection's indexes.
+func getIndexes(cfg *config) (*couchbase.Indexes, error) {
+	cluster, err := getCluster(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get cb cluster connection: %w", err)
+	}
+
+	return couchbase.NewIndexes(cluster, cfg.CouchbaseBucket, cfg.CouchbaseScope, cfg.CouchbaseCollection), nil
+}
+
+// actor returns who the changes are made by, SIM_ACTOR or the OS user.
+func actor(cfg *config) string {
+	if cfg.Actor != "" {
+		return cfg.Actor
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+
+	return "unknown"
+}
+
+// withCache decorates the reader and writer with a redis read-through cache.
+func withCache(cfg *config, logger *zap.Logger, r images.Reader, w images.Writer) (images.Reader, images.Writer, error) {
+	c := cache.NewRedis(redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	}))
+
+	cr, err := cache.NewReader(logger, r, c, cfg.RedisTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	cw, err := cache.NewWriter(logger, w, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cr, cw, nil
+}
+
+// cdnStorage returns the name of the storage the CDN serves, the default
+// storage unless CDN_STORAGE is set.
+func cdnStorage(cfg *config) string {
+	if cfg.CDNStorage != "" {
+		return cfg.CDNStorage
+	}
+
+	return cfg.Storage
+}
+
+// getCDN returns the CloudFront distribution of CDN_DISTRIBUTION_ID.
+func getCDN(cfg *config, logger *zap.Logger) (images.CDN, error) {
+	loadOpts := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithRegion(cfg.Region),
+	}
+	var opts []cloudfront.Option
+	if cfg.LocalstackURL != "" {
+		loadOpts = append(loadOpts, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider("images", "secret", ""),
+		))
+		opts = append(opts, cloudfront.WithEndpoint(cfg.LocalstackURL))
+	}
+
+	return cloudfront.NewDistribution(logger, cfg.CDNDistributionID, images.WithConfigOptions(loadOpts...), opts...)
+}
+
+// getModerator returns the moderator of MODERATION, rekognition is the only
+// one.
+func getModerator(cfg *config, logger *zap.Logger) (images.Moderator, error) {
+	if cfg.Moderation != "rekognition" {
+		return nil, fmt.Errorf("unknown moderator: %s", cfg.Moderation)
+	}
+
+	loadOpts := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithRegion(cfg.Region),
+	}
+	opts := []rekognition.Option{rekognition.WithMinConfidence(cfg.ModerationMinConfidence)}
+	if cfg.LocalstackURL != "" {
+		loadOpts = append(loadOpts, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider("images", "secret", ""),
+		))
+		opts = append(opts, rekognition.WithEndpoint(cfg.LocalstackURL))
+	}
+
+	return rekognition.NewModerator(logger, images.WithConfigOptions(loadOpts...), opts...)
+}
+
+// getTextExtractor returns the text extractor of OCR, textract is the only
+// one.
+func getTextExtractor(cfg *config, logger *zap.Logger) (images.TextExtractor, error) {
+	if cfg.OCR != "textract" {
+		return nil, fmt.Errorf("unknown text extractor: %s", cfg.OCR)
+	}
+
+	loadOpts := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithRegion(cfg.Region),
+	}
+	var opts []textract.Option
+	if cfg.LocalstackURL != "" {
+		loadOpts = append(loadOpts, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider("images", "secret", ""),
+		))
+		opts = append(opts, textract.WithEndpoint(cfg.LocalstackURL))
+	}
+
+	return textract.NewExtractor(logger, images.WithConfigOptions(loadOpts...), opts...)
+}
+
+// getURLSigner returns the signer of the URLs of CDN_URL, which signs them
+// with the key of CDN_PRIVATE_KEY_FILE when set.
+func getURLSigner(cfg *config) (images.URLSigner, error) {
+	if cfg.CDNPrivateKeyFile == "" {
+		return cloudfront.NewURLSigner(cfg.CDNURL, "", nil)
+	}
+
+	b, err := ioutil.ReadFile(cfg.CDNPrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cdn private key file: %w", err)
+	}
+	key, err := cloudfront.ParsePrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse cdn private key: %w", err)
+	}
+
+	return cloudfront.NewURLSigner(cfg.CDNURL, cfg.CDNKeyPairID, key)
+}
 
+func getDynamoClient(cfg *config) (*dynamodb.Client, error) {
+	opts := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithRegion(cfg.Region),
+	}
+	endpoint := cfg.DynamoDBEndpoint
+	if endpoint == "" {
+		endpoint = cfg.LocalstackURL
+	}
 	if cfg.LocalstackURL != "" {
-		config = config.
-			WithEndpoint(cfg.LocalstackURL).
-			WithS3ForcePathStyle(true).
-			WithCredentials(credentials.NewStaticCredentials("images", "secret", ""))
+		opts = append(opts, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider("images", "secret", ""),
+		))
 	}
 
-	return config
+	awsCfg, err := awsConfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var optFns []func(*dynamodb.Options)
+	if endpoint != "" {
+		optFns = append(optFns, func(o *dynamodb.Options) {
+			o.EndpointResolver = dynamodb.EndpointResolverFromURL(endpoint)
+		})
+	}
+
+	return dynamodb.NewFromConfig(awsCfg, optFns...), nil
 }
 
 func getCluster(cfg *config) (*gocb.Cluster, error) {
-	return gocb.Connect(
-		cfg.CouchbaseEndpoint,
-		gocb.ClusterOptions{
-			Username: cfg.CouchbaseUsername,
-			Password: cfg.CouchbasePassword,
-		},
-	)
+	return couchbase.Connect(couchbase.Config{
+		Endpoint:      cfg.CouchbaseEndpoint,
+		Username:      cfg.CouchbaseUsername,
+		Password:      cfg.CouchbasePassword,
+		CAFile:        cfg.CouchbaseCAFile,
+		CertFile:      cfg.CouchbaseCertFile,
+		KeyFile:       cfg.CouchbaseKeyFile,
+		TLSSkipVerify: cfg.CouchbaseTLSSkipVerify,
+
+		ConnectTimeout:     cfg.CouchbaseConnectTimeout,
+		KVTimeout:          cfg.CouchbaseKVTimeout,
+		QueryTimeout:       cfg.CouchbaseQueryTimeout,
+		DisableCompression: cfg.CouchbaseDisableCompression,
+	})
+}
+
+// idGenerator returns the generator of the ID_FORMAT, uuid or ulid, prefixed
+// with the ID_PREFIX when set.
+func idGenerator(cfg *config) (images.IDGenerator, error) {
+	var gen images.IDGenerator
+	switch cfg.IDFormat {
+	case "uuid":
+		gen = images.NewUUID
+	case "ulid":
+		gen = images.NewULID
+	default:
+		return nil, fmt.Errorf("unknown id format %q, expected uuid or ulid", cfg.IDFormat)
+	}
+	if cfg.IDPrefix == "" {
+		return gen, nil
+	}
+
+	return images.PrefixedIDs(cfg.IDPrefix, gen)
+}
+
+// readEncryptionKey reads the base64 encoded 32 byte key from the file, i.e.
+// one created with `openssl rand -base64 32`.
+func readEncryptionKey(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("key is not base64 encoded: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key is (%d) bytes, expected 32", len(key))
+	}
+
+	return key, nil
+}
+
+func readWatermark(cfg *config) (*imaging.Watermark, error) {
+	position, ok := imaging.ParsePosition(cfg.WatermarkPosition)
+	if !ok {
+		return nil, fmt.Errorf("unknown position (%s), expected top-left, top-right, bottom-left, bottom-right, center or tile", cfg.WatermarkPosition)
+	}
+
+	f, err := os.Open(cfg.Watermark)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mark, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("watermark is not a jpeg, png or gif: %w", err)
+	}
+
+	return &imaging.Watermark{
+		Mark:     mark,
+		Position: position,
+		Opacity:  cfg.WatermarkOpacity,
+		Scale:    cfg.WatermarkScale,
+	}, nil
 }
 
 func getLogger(debug bool) (*zap.Logger, error) {
@@ -112,6 +796,12 @@ func initConfig() (*config, error) {
 	if err := env.Parse(cfg); err != nil {
 		return nil, err
 	}
+	if cfg.MetadataBackend == "" {
+		cfg.MetadataBackend = cfg.MetadataBackendAlias
+	}
+	if cfg.MetadataBackend == "" {
+		cfg.MetadataBackend = backendCouchbase
+	}
 
 	return cfg, nil
 }
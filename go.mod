@@ -3,14 +3,32 @@ module github.com/itsHabib/sim
 go 1.16
 
 require (
-	github.com/aws/aws-sdk-go v1.41.1
-	github.com/caarlos0/env/v6 v6.7.2 // indirect
+	cloud.google.com/go/storage v1.18.2
+	github.com/aws/aws-sdk-go-v2 v1.10.0
+	github.com/aws/aws-sdk-go-v2/config v1.9.0
+	github.com/aws/aws-sdk-go-v2/credentials v1.5.0
+	github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue v1.2.0
+	github.com/aws/aws-sdk-go-v2/feature/s3/manager v1.6.0
+	github.com/aws/aws-sdk-go-v2/service/cloudfront v1.9.0
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.6.0
+	github.com/aws/aws-sdk-go-v2/service/rekognition v1.8.0
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.17.0
+	github.com/aws/aws-sdk-go-v2/service/textract v1.4.0
+	github.com/aws/smithy-go v1.8.1
+	github.com/caarlos0/env/v6 v6.7.2
+	github.com/chai2010/webp v1.4.0
 	github.com/couchbase/gocb/v2 v2.3.0
+	github.com/go-redis/redis/v8 v8.11.4
 	github.com/golang/mock v1.6.0
 	github.com/google/uuid v1.3.0
+	github.com/pkg/sftp v1.13.4
 	github.com/spf13/cobra v1.2.1
 	github.com/stretchr/testify v1.7.0
+	go.etcd.io/bbolt v1.3.6
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
 	go.uber.org/zap v1.19.1
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
+	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
+	google.golang.org/api v0.58.0
 )
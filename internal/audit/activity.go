@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// Outcome represents how an operation ended.
+type Outcome string
+
+const (
+	OutcomeOK    Outcome = "ok"
+	OutcomeError Outcome = "error"
+)
+
+// Activity represents an operation run against the images, i.e. a CLI
+// command.
+type Activity struct {
+	// ID of the activity, IDs of activities sort in the order they happened
+	ID string `json:"id"`
+
+	// At is when the operation finished
+	At time.Time `json:"at"`
+
+	// Actor is who ran the operation
+	Actor string `json:"actor"`
+
+	// Command is the operation, i.e. "upload" or "tag add"
+	Command string `json:"command"`
+
+	// ImageID is the image the operation acted on, empty for operations on
+	// many or no images
+	ImageID string `json:"imageId,omitempty"`
+
+	// Outcome is how the operation ended
+	Outcome Outcome `json:"outcome"`
+
+	// Error is the reason the operation failed
+	Error string `json:"error,omitempty"`
+}
+
+// ActivityFilter represents the conditions an activity must meet to be
+// returned. The zero value of a field does not filter on it.
+type ActivityFilter struct {
+	Actor   string
+	Command string
+	ImageID string
+
+	// Failed matches the activities which ended in an error
+	Failed bool
+
+	// After matches the activities which happened after the activity with
+	// the ID, used to follow the log
+	After string
+}
+
+// Match reports whether the activity meets the conditions of the filter.
+func (f *ActivityFilter) Match(a *Activity) bool {
+	switch {
+	case f.Actor != "" && a.Actor != f.Actor:
+		return false
+	case f.Command != "" && a.Command != f.Command:
+		return false
+	case f.ImageID != "" && a.ImageID != f.ImageID:
+		return false
+	case f.Failed && a.Outcome != OutcomeError:
+		return false
+	case f.After != "" && a.ID <= f.After:
+		return false
+	}
+
+	return true
+}
+
+// ActivityLog provides the means to persist and read activities.
+type ActivityLog interface {
+	// Record persists the activity.
+	Record(ctx context.Context, a *Activity) error
+	// Recent returns the latest activities matching the filter, up to limit
+	// when it is non zero, in the order they happened.
+	Recent(ctx context.Context, filter ActivityFilter, limit int) ([]Activity, error)
+}
+
+// NewActivity returns the activity of the command which ended with err.
+func NewActivity(actor, command, imageID string, at time.Time, err error) *Activity {
+	at = at.UTC().Truncate(time.Microsecond)
+	a := Activity{
+		ID:      newID(at),
+		At:      at,
+		Actor:   actor,
+		Command: command,
+		ImageID: imageID,
+		Outcome: OutcomeOK,
+	}
+	if err != nil {
+		a.Outcome = OutcomeError
+		a.Error = err.Error()
+	}
+
+	return &a
+}
@@ -0,0 +1,129 @@
+// Package audit records the history of changes made to image records.
+package audit
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// Action represents the kind of change made to a record.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Event represents a change made to an image record. Events are append only,
+// the history of a record outlives the record.
+type Event struct {
+	// ID of the event, IDs of events sort in the order they happened
+	ID string `json:"id"`
+
+	// ImageID is the ID of the changed record
+	ImageID string `json:"imageId"`
+
+	// Action is the kind of change
+	Action Action `json:"action"`
+
+	// Actor is who made the change
+	Actor string `json:"actor"`
+
+	// At is when the change was made
+	At time.Time `json:"at"`
+
+	// Changes are the fields which changed, creates hold the initial values
+	// and deletes the last
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Change represents the change of a record field.
+type Change struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// Store provides the means to persist and read events.
+type Store interface {
+	// Append persists the event.
+	Append(ctx context.Context, e *Event) error
+	// History returns the events of the image record in the order they
+	// happened. Returns images.ErrRecordNotFound if the record has no events.
+	History(ctx context.Context, imageID string) ([]Event, error)
+}
+
+// NewEvent returns the event of the action, at is truncated to the
+// microsecond so it survives every store's encoding.
+func NewEvent(imageID string, action Action, actor string, at time.Time, changes []Change) *Event {
+	at = at.UTC().Truncate(time.Microsecond)
+
+	return &Event{
+		ID:      newID(at),
+		ImageID: imageID,
+		Action:  action,
+		Actor:   actor,
+		At:      at,
+		Changes: changes,
+	}
+}
+
+// newID returns a unique ID which sorts by the time, at is in UTC.
+func newID(at time.Time) string {
+	return TimeID(at) + "-" + uuid.New().String()[:8]
+}
+
+// TimeID returns the ID which sorts before the IDs of everything which
+// happened at or after the time.
+func TimeID(at time.Time) string {
+	return at.UTC().Format("20060102T150405.000000Z")
+}
+
+// Diff returns the changes between the fields of two revisions of a record,
+// a nil revision has no fields. Bookkeeping fields such as the revision are
+// not compared.
+func Diff(from, to *images.Record) []Change {
+	var changes []Change
+	for _, f := range []struct {
+		name  string
+		value func(r *images.Record) string
+	}{
+		{name: "name", value: func(r *images.Record) string { return r.Name }},
+		{name: "tags", value: func(r *images.Record) string { return strings.Join(r.Tags, ",") }},
+		{name: "metadata", value: func(r *images.Record) string { return images.FormatMetadata(r.Metadata) }},
+		{name: "storage", value: func(r *images.Record) string { return r.Storage }},
+		{name: "key", value: func(r *images.Record) string { return r.Key }},
+		{name: "size", value: func(r *images.Record) string { return size(r.SizeInBytes) }},
+		{name: "etag", value: func(r *images.Record) string { return r.ETag }},
+		{name: "sha256", value: func(r *images.Record) string { return r.SHA256 }},
+		{name: "mirrors", value: func(r *images.Record) string { return strings.Join(r.Mirrors, ",") }},
+	} {
+		var a, b string
+		if from != nil {
+			a = f.value(from)
+		}
+		if to != nil {
+			b = f.value(to)
+		}
+		if a != b {
+			changes = append(changes, Change{Field: f.name, From: a, To: b})
+		}
+	}
+
+	return changes
+}
+
+func size(n int64) string {
+	if n == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Diff(t *testing.T) {
+	from := images.Record{Name: "cat.png", Tags: []string{"vacation"}, Storage: "sim", SizeInBytes: 10}
+	to := from
+	to.Name = "dog.png"
+	to.Tags = []string{"vacation", "beach"}
+
+	assert.Equal(t, []Change{
+		{Field: "name", From: "cat.png", To: "dog.png"},
+		{Field: "tags", From: "vacation", To: "vacation,beach"},
+	}, Diff(&from, &to))
+	assert.Equal(t, []Change{
+		{Field: "name", From: "cat.png"},
+		{Field: "tags", From: "vacation"},
+		{Field: "storage", From: "sim"},
+		{Field: "size", From: "10"},
+	}, Diff(&from, nil))
+}
+
+func Test_Writer(t *testing.T) {
+	rec := images.Record{ID: "id", Name: "cat.png"}
+	for _, tc := range []struct {
+		desc    string
+		mocks   func(r *mock_images.MockReader, w *mock_images.MockWriter, s *store)
+		do      func(w *Writer) error
+		check   func(t *testing.T, s *store)
+		wantErr bool
+	}{
+		{
+			desc: "Create() should append the initial fields",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *store) {
+				w.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			do: func(w *Writer) error {
+				c := rec
+				return w.Create(context.Background(), &c)
+			},
+			check: func(t *testing.T, s *store) {
+				require.Len(t, s.events, 1)
+				assert.Equal(t, "id", s.events[0].ImageID)
+				assert.Equal(t, ActionCreate, s.events[0].Action)
+				assert.Equal(t, "actor", s.events[0].Actor)
+				assert.Equal(t, []Change{{Field: "name", To: "cat.png"}}, s.events[0].Changes)
+			},
+		},
+		{
+			desc: "Create() should not append an event when the create fails",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *store) {
+				w.EXPECT().Create(gomock.Any(), gomock.Any()).Return(errors.New("random"))
+			},
+			do: func(w *Writer) error {
+				c := rec
+				return w.Create(context.Background(), &c)
+			},
+			check: func(t *testing.T, s *store) {
+				assert.Empty(t, s.events)
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Update() should append the changed fields",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *store) {
+				r.EXPECT().Get(gomock.Any(), "id").Return(&images.Record{ID: "id", Name: "cat.png"}, nil)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			do: func(w *Writer) error {
+				return w.Update(context.Background(), &images.Record{ID: "id", Name: "dog.png"})
+			},
+			check: func(t *testing.T, s *store) {
+				require.Len(t, s.events, 1)
+				assert.Equal(t, ActionUpdate, s.events[0].Action)
+				assert.Equal(t, []Change{{Field: "name", From: "cat.png", To: "dog.png"}}, s.events[0].Changes)
+			},
+		},
+		{
+			desc: "Delete() should not fail when the event can not be appended",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *store) {
+				r.EXPECT().Get(gomock.Any(), "id").Return(&images.Record{ID: "id", Name: "cat.png"}, nil)
+				w.EXPECT().Delete(gomock.Any(), "id").Return(nil)
+				s.err = errors.New("random")
+			},
+			do: func(w *Writer) error {
+				return w.Delete(context.Background(), "id")
+			},
+		},
+		{
+			desc: "DeleteBatch() should only append events for the deleted records",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *store) {
+				r.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, images.ErrRecordNotFound).Times(2)
+				w.EXPECT().DeleteBatch(gomock.Any(), []string{"1", "2"}).Return(images.BatchError{"2": images.ErrRecordNotFound})
+			},
+			do: func(w *Writer) error {
+				return w.DeleteBatch(context.Background(), []string{"1", "2"})
+			},
+			check: func(t *testing.T, s *store) {
+				require.Len(t, s.events, 1)
+				assert.Equal(t, "1", s.events[0].ImageID)
+				assert.Equal(t, ActionDelete, s.events[0].Action)
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), new(store)
+			tc.mocks(r, w, s)
+			aw, err := NewWriter(zap.NewNop(), w, r, s, "actor")
+			require.NoError(t, err)
+
+			err = tc.do(aw)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if tc.check != nil {
+				tc.check(t, s)
+			}
+		})
+	}
+}
+
+// store records the appended events, failing every append when err is set.
+type store struct {
+	events []Event
+	err    error
+}
+
+func (s *store) Append(ctx context.Context, e *Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, *e)
+
+	return nil
+}
+
+func (s *store) History(ctx context.Context, imageID string) ([]Event, error) {
+	return nil, images.ErrRecordNotFound
+}
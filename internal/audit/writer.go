@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const writerLoggerName = "audit.writer"
+
+// Writer decorates an images.Writer, appending an event to the store for
+// every record it changes. Events are written after the change succeeds and
+// failing to append one is logged rather than failing the change.
+type Writer struct {
+	actor  string
+	logger *zap.Logger
+	reader images.Reader
+	store  Store
+	writer images.Writer
+}
+
+// NewWriter returns an instantiated instance of an auditing writer which has
+// the following dependencies:
+//
+// logger: for structured logging
+//
+// writer: the writer whose changes are recorded
+//
+// reader: for reading the revision a record is updated from
+//
+// store: for persisting the events
+//
+// actor: who the changes are made by
+func NewWriter(logger *zap.Logger, writer images.Writer, reader images.Reader, store Store, actor string) (*Writer, error) {
+	w := Writer{
+		actor:  actor,
+		logger: logger.Named(writerLoggerName),
+		reader: reader,
+		store:  store,
+		writer: writer,
+	}
+
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+
+	w.logger.Debug("successfully initialized audit writer")
+
+	return &w, nil
+}
+
+func (w *Writer) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "actor",
+			chk: func() bool { return w.actor != "" },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return w.logger != nil },
+		},
+		{
+			dep: "reader",
+			chk: func() bool { return w.reader != nil },
+		},
+		{
+			dep: "store",
+			chk: func() bool { return w.store != nil },
+		},
+		{
+			dep: "writer",
+			chk: func() bool { return w.writer != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize writer due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Create creates the record and records its initial fields.
+func (w *Writer) Create(ctx context.Context, record *images.Record) error {
+	if err := w.writer.Create(ctx, record); err != nil {
+		return err
+	}
+	w.append(ctx, record.ID, ActionCreate, Diff(nil, record))
+
+	return nil
+}
+
+// CreateBatch creates the records and records the initial fields of those
+// which were created.
+func (w *Writer) CreateBatch(ctx context.Context, records []images.Record) error {
+	err := w.writer.CreateBatch(ctx, records)
+	failed, ok := err.(images.BatchError)
+	if err != nil && !ok {
+		return err
+	}
+
+	for i := range records {
+		if _, ok := failed[records[i].ID]; ok {
+			continue
+		}
+		w.append(ctx, records[i].ID, ActionCreate, Diff(nil, &records[i]))
+	}
+
+	return err
+}
+
+// Delete deletes the record and records its last fields.
+func (w *Writer) Delete(ctx context.Context, id string) error {
+	prev := w.previous(ctx, id)
+	if err := w.writer.Delete(ctx, id); err != nil {
+		return err
+	}
+	w.append(ctx, id, ActionDelete, Diff(prev, nil))
+
+	return nil
+}
+
+// DeleteBatch deletes the records and records the deletion of those which
+// were deleted.
+func (w *Writer) DeleteBatch(ctx context.Context, ids []string) error {
+	prev := make([]*images.Record, len(ids))
+	for i := range ids {
+		prev[i] = w.previous(ctx, ids[i])
+	}
+
+	err := w.writer.DeleteBatch(ctx, ids)
+	failed, ok := err.(images.BatchError)
+	if err != nil && !ok {
+		return err
+	}
+
+	for i := range ids {
+		if _, ok := failed[ids[i]]; ok {
+			continue
+		}
+		w.append(ctx, ids[i], ActionDelete, Diff(prev[i], nil))
+	}
+
+	return err
+}
+
+// Update replaces the record and records the fields which changed.
+func (w *Writer) Update(ctx context.Context, record *images.Record) error {
+	prev := w.previous(ctx, record.ID)
+	if err := w.writer.Update(ctx, record); err != nil {
+		return err
+	}
+	if prev == nil {
+		// the previous revision is unknown, record every field
+		prev = new(images.Record)
+	}
+	w.append(ctx, record.ID, ActionUpdate, Diff(prev, record))
+
+	return nil
+}
+
+// previous returns the stored revision of the record, nil when it can not be
+// read.
+func (w *Writer) previous(ctx context.Context, id string) *images.Record {
+	rec, err := w.reader.Get(ctx, id)
+	if err != nil {
+		w.logger.Warn("unable to read record before change", zap.String("imageId", id), zap.Error(err))
+		return nil
+	}
+
+	return rec
+}
+
+func (w *Writer) append(ctx context.Context, id string, action Action, changes []Change) {
+	e := NewEvent(id, action, w.actor, time.Now(), changes)
+	if err := w.store.Append(ctx, e); err != nil {
+		w.logger.Error(
+			"unable to append audit event",
+			zap.String("imageId", id),
+			zap.String("action", string(action)),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,77 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/itsHabib/sim/internal/audit"
+)
+
+// activityBucket holds the activities as JSON keyed by activity ID
+var activityBucket = []byte("activity")
+
+// ActivityLog persists the activities in the database.
+type ActivityLog struct {
+	db *bbolt.DB
+}
+
+// NewActivityLog returns an activity log on the database returned by Open.
+func NewActivityLog(db *bbolt.DB) *ActivityLog {
+	return &ActivityLog{db: db}
+}
+
+// Record adds the activity to the database.
+func (l *ActivityLog) Record(ctx context.Context, a *audit.Activity) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("unable to marshal activity: %w", err)
+	}
+
+	return l.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(activityBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(a.ID), b)
+	})
+}
+
+// Recent returns the latest activities matching the filter in the order they
+// happened, reading the log backwards from the latest activity.
+func (l *ActivityLog) Recent(ctx context.Context, filter audit.ActivityFilter, limit int) ([]audit.Activity, error) {
+	var recent []audit.Activity
+	err := l.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(activityBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Last(); k != nil && (limit == 0 || len(recent) < limit); k, v = c.Prev() {
+			if filter.After != "" && string(k) <= filter.After {
+				break
+			}
+			var a audit.Activity
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			if filter.Match(&a) {
+				recent = append(recent, a)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read activities: %w", err)
+	}
+	for i, j := 0, len(recent)-1; i < j; i, j = i+1, j-1 {
+		recent[i], recent[j] = recent[j], recent[i]
+	}
+
+	return recent, nil
+}
@@ -0,0 +1,80 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/itsHabib/sim/internal/audit"
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// auditBucket holds the audit events as JSON keyed by image ID and event ID
+var auditBucket = []byte("audit")
+
+// AuditStore persists the audit events of image records in the database.
+type AuditStore struct {
+	db *bbolt.DB
+}
+
+// NewAuditStore returns an audit store on the database returned by Open.
+func NewAuditStore(db *bbolt.DB) *AuditStore {
+	return &AuditStore{db: db}
+}
+
+// Append adds the event to the database.
+func (s *AuditStore) Append(ctx context.Context, e *audit.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("unable to marshal audit event: %w", err)
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(auditBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(auditKey(e.ImageID, e.ID), b)
+	})
+}
+
+// History returns the events of the image record in the order they happened.
+func (s *AuditStore) History(ctx context.Context, imageID string) ([]audit.Event, error) {
+	var events []audit.Event
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(auditBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		prefix := auditKey(imageID, "")
+		c := bucket.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var e audit.Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read audit events: %w", err)
+	}
+	if len(events) == 0 {
+		return nil, images.ErrRecordNotFound
+	}
+
+	return events, nil
+}
+
+// auditKey orders the events of an image by event ID, the separator can not
+// appear in an image ID.
+func auditKey(imageID, eventID string) []byte {
+	return []byte(imageID + "\x00" + eventID)
+}
@@ -0,0 +1,82 @@
+// Package bolt provides an embedded, single file implementation of the image
+// record reader and writer for running the CLI without an external database.
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const (
+	// openTimeout is how long to wait on another process holding the file
+	// lock before giving up.
+	openTimeout = time.Second
+)
+
+// bucket holds the image records as JSON keyed by ID
+var bucket = []byte(images.Collection)
+
+// DefaultPath returns the default location of the database, ~/.sim/sim.db
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to get home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".sim", "sim.db"), nil
+}
+
+// Open opens the database at path, creating it and its parent directories
+// when missing. The returned DB should be shared by the reader and writer
+// and closed when done.
+func Open(path string) (*bbolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create database directory: %w", err)
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create bucket: %w", err)
+	}
+
+	return db, nil
+}
+
+func getRecord(b *bbolt.Bucket, id string) (*images.Record, error) {
+	v := b.Get([]byte(id))
+	if v == nil {
+		return nil, images.ErrRecordNotFound
+	}
+
+	var rec images.Record
+	if err := json.Unmarshal(v, &rec); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal image record: %w", err)
+	}
+
+	return &rec, nil
+}
+
+func putRecord(b *bbolt.Bucket, rec *images.Record) error {
+	v, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("unable to marshal image record: %w", err)
+	}
+
+	return b.Put([]byte(rec.ID), v)
+}
@@ -0,0 +1,285 @@
+package bolt
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/audit"
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_ReaderWriter(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "nested", "sim.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	reader, err := NewReader(zap.NewNop(), db)
+	require.NoError(t, err)
+	writer, err := NewWriter(zap.NewNop(), db)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	rec := images.Record{
+		ID:          "id",
+		CreatedAt:   &now,
+		ETag:        "etag",
+		Key:         "images/id/name",
+		Name:        "name",
+		SizeInBytes: 1024,
+		Storage:     "sim",
+	}
+
+	for _, tc := range []struct {
+		desc string
+		do   func(t *testing.T)
+	}{
+		{
+			desc: "List() should return ErrRecordNotFound when there are no records",
+			do: func(t *testing.T) {
+				_, err := reader.List(context.Background(), images.ListOptions{})
+				assert.Equal(t, images.ErrRecordNotFound, err)
+			},
+		},
+		{
+			desc: "Update() should return ErrRecordNotFound when the record does not exist",
+			do: func(t *testing.T) {
+				assert.Equal(t, images.ErrRecordNotFound, writer.Update(context.Background(), &rec))
+			},
+		},
+		{
+			desc: "Create() should insert the record",
+			do: func(t *testing.T) {
+				require.NoError(t, writer.Create(context.Background(), &rec))
+			},
+		},
+		{
+			desc: "Create() should return an error when the record already exists",
+			do: func(t *testing.T) {
+				assert.Error(t, writer.Create(context.Background(), &rec))
+			},
+		},
+		{
+			desc: "Count() should count the records matching the filter",
+			do: func(t *testing.T) {
+				n, err := reader.Count(context.Background(), images.ListFilter{})
+				require.NoError(t, err)
+				assert.Equal(t, 1, n)
+
+				n, err = reader.Count(context.Background(), images.ListFilter{MinSize: rec.SizeInBytes + 1})
+				require.NoError(t, err)
+				assert.Equal(t, 0, n)
+			},
+		},
+		{
+			desc: "Get() should return the record",
+			do: func(t *testing.T) {
+				got, err := reader.Get(context.Background(), rec.ID)
+				require.NoError(t, err)
+				assert.Equal(t, &rec, got)
+			},
+		},
+		{
+			desc: "GetByName() should return the record with the name",
+			do: func(t *testing.T) {
+				got, err := reader.GetByName(context.Background(), rec.Name)
+				require.NoError(t, err)
+				assert.Equal(t, &rec, got)
+
+				_, err = reader.GetByName(context.Background(), "missing")
+				assert.Equal(t, images.ErrRecordNotFound, err)
+			},
+		},
+		{
+			desc: "Update() should replace the record",
+			do: func(t *testing.T) {
+				updated := rec
+				updated.Storage = "archive"
+				require.NoError(t, writer.Update(context.Background(), &updated))
+
+				page, err := reader.List(context.Background(), images.ListOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{updated}, page.Records)
+			},
+		},
+		{
+			desc: "Update() should return ErrConflict when the revision is stale",
+			do: func(t *testing.T) {
+				stale := rec
+				assert.Equal(t, images.ErrConflict, writer.Update(context.Background(), &stale))
+			},
+		},
+		{
+			desc: "Delete() should remove the record",
+			do: func(t *testing.T) {
+				require.NoError(t, writer.Delete(context.Background(), rec.ID))
+				assert.Equal(t, images.ErrRecordNotFound, writer.Delete(context.Background(), rec.ID))
+
+				_, err := reader.Get(context.Background(), rec.ID)
+				assert.Equal(t, images.ErrRecordNotFound, err)
+			},
+		},
+	} {
+		if !t.Run(tc.desc, tc.do) {
+			t.Fatalf("test ('%s') failed", tc.desc)
+		}
+	}
+}
+
+func Test_Writer_CreateBatch(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "sim.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	reader, err := NewReader(zap.NewNop(), db)
+	require.NoError(t, err)
+	writer, err := NewWriter(zap.NewNop(), db)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Create(context.Background(), &images.Record{ID: "a"}))
+
+	records := []images.Record{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	err = writer.CreateBatch(context.Background(), records)
+	var batchErr images.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Len(t, batchErr, 1)
+	assert.Contains(t, batchErr, "a")
+	assert.Zero(t, records[0].Revision)
+	assert.NotZero(t, records[1].Revision)
+
+	n, err := reader.Count(context.Background(), images.ListFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func Test_Writer_DeleteBatch(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "sim.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	reader, err := NewReader(zap.NewNop(), db)
+	require.NoError(t, err)
+	writer, err := NewWriter(zap.NewNop(), db)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.CreateBatch(context.Background(), []images.Record{{ID: "a"}, {ID: "b"}}))
+
+	err = writer.DeleteBatch(context.Background(), []string{"a", "b", "missing"})
+	assert.Equal(t, images.BatchError{"missing": images.ErrRecordNotFound}, err)
+
+	_, err = reader.List(context.Background(), images.ListOptions{})
+	assert.Equal(t, images.ErrRecordNotFound, err)
+}
+
+func Test_AuditStore(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "sim.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewAuditStore(db)
+	_, err = store.History(context.Background(), "id")
+	assert.Equal(t, images.ErrRecordNotFound, err)
+
+	now := time.Now()
+	created := audit.NewEvent("id", audit.ActionCreate, "actor", now, []audit.Change{{Field: "name", To: "cat.png"}})
+	other := audit.NewEvent("id2", audit.ActionCreate, "actor", now, nil)
+	deleted := audit.NewEvent("id", audit.ActionDelete, "actor", now.Add(time.Second), nil)
+	for _, e := range []*audit.Event{deleted, other, created} {
+		require.NoError(t, store.Append(context.Background(), e))
+	}
+
+	events, err := store.History(context.Background(), "id")
+	require.NoError(t, err)
+	assert.Equal(t, []audit.Event{*created, *deleted}, events)
+}
+
+func Test_ActivityLog(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "sim.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	log := NewActivityLog(db)
+	recent, err := log.Recent(context.Background(), audit.ActivityFilter{}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, recent)
+
+	now := time.Now()
+	upload := audit.NewActivity("alice", "upload", "id", now, nil)
+	failed := audit.NewActivity("bob", "delete", "id", now.Add(time.Second), errors.New("random"))
+	tagged := audit.NewActivity("alice", "tag add", "id2", now.Add(2*time.Second), nil)
+	for _, a := range []*audit.Activity{tagged, upload, failed} {
+		require.NoError(t, log.Record(context.Background(), a))
+	}
+
+	for _, tc := range []struct {
+		desc   string
+		filter audit.ActivityFilter
+		limit  int
+		want   []audit.Activity
+	}{
+		{
+			desc: "should return every activity in order",
+			want: []audit.Activity{*upload, *failed, *tagged},
+		},
+		{
+			desc:  "should return the latest activities up to the limit",
+			limit: 2,
+			want:  []audit.Activity{*failed, *tagged},
+		},
+		{
+			desc:   "should return the failed activities",
+			filter: audit.ActivityFilter{Failed: true},
+			want:   []audit.Activity{*failed},
+		},
+		{
+			desc:   "should return the activities of the actor",
+			filter: audit.ActivityFilter{Actor: "alice"},
+			limit:  1,
+			want:   []audit.Activity{*tagged},
+		},
+		{
+			desc:   "should return the activities after the ID",
+			filter: audit.ActivityFilter{After: upload.ID},
+			want:   []audit.Activity{*failed, *tagged},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			recent, err := log.Recent(context.Background(), tc.filter, tc.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, recent)
+		})
+	}
+}
+
+func Test_Reader_GetByIDPrefix(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "sim.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	reader, err := NewReader(zap.NewNop(), db)
+	require.NoError(t, err)
+	writer, err := NewWriter(zap.NewNop(), db)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.CreateBatch(context.Background(), []images.Record{{ID: "abc2"}, {ID: "abc1"}, {ID: "abd"}}))
+
+	list, err := reader.GetByIDPrefix(context.Background(), "abc", 10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "abc1", list[0].ID)
+	assert.Equal(t, "abc2", list[1].ID)
+
+	list, err = reader.GetByIDPrefix(context.Background(), "ab", 1)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "abc1", list[0].ID)
+
+	_, err = reader.GetByIDPrefix(context.Background(), "x", 10)
+	assert.Equal(t, images.ErrRecordNotFound, err)
+}
@@ -0,0 +1,273 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const readerLoggerName = "bolt.reader"
+
+// Reader provides the implementation to read image records from an embedded
+// bolt database.
+type Reader struct {
+	db     *bbolt.DB
+	logger *zap.Logger
+}
+
+// NewReader returns an instantiated instance of a reader which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// db: the bolt database returned by Open
+func NewReader(logger *zap.Logger, db *bbolt.DB) (*Reader, error) {
+	r := Reader{
+		db:     db,
+		logger: logger.Named(readerLoggerName),
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Debug("successfully initialized image reader")
+
+	return &r, nil
+}
+
+func (r *Reader) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "db",
+			chk: func() bool { return r.db != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return r.logger != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize reader due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Get returns an image record given the id. Returns ErrRecordNotFound if no
+// image is found by that ID.
+func (r *Reader) Get(ctx context.Context, id string) (*images.Record, error) {
+	logger := r.logger.With(zap.String("imageId", id))
+
+	var rec *images.Record
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		rec, err = getRecord(tx.Bucket(bucket), id)
+		return err
+	})
+	switch err {
+	case nil:
+		return rec, nil
+	case images.ErrRecordNotFound:
+		logger.Error("record not found")
+		return nil, err
+	default:
+		const msg = "unable to get image by id"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+}
+
+// GetByName returns the image record with the given name. This scans every
+// record. Returns ErrRecordNotFound if no image is found by that name.
+func (r *Reader) GetByName(ctx context.Context, name string) (*images.Record, error) {
+	logger := r.logger.With(zap.String("imageName", name))
+
+	var rec *images.Record
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var got images.Record
+			if err := json.Unmarshal(v, &got); err != nil {
+				return err
+			}
+			if got.Name == name {
+				rec = &got
+				return nil
+			}
+		}
+
+		return images.ErrRecordNotFound
+	})
+	switch err {
+	case nil:
+		return rec, nil
+	case images.ErrRecordNotFound:
+		logger.Error("record not found")
+		return nil, err
+	default:
+		const msg = "unable to get image by name"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+}
+
+// GetByIDPrefix returns up to limit image records whose ID starts with the
+// prefix, seeking to it since the records are keyed by ID. Returns
+// ErrRecordNotFound if no record's ID does.
+func (r *Reader) GetByIDPrefix(ctx context.Context, prefix string, limit int) ([]images.Record, error) {
+	logger := r.logger.With(zap.String("idPrefix", prefix))
+
+	var list []images.Record
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		p := []byte(prefix)
+		for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p) && len(list) < limit; k, v = c.Next() {
+			var rec images.Record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			list = append(list, rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		const msg = "unable to get images by id prefix"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	if len(list) == 0 {
+		logger.Error("record not found")
+		return nil, images.ErrRecordNotFound
+	}
+
+	return list, nil
+}
+
+// Count returns the number of image records matching the filter.
+func (r *Reader) Count(ctx context.Context, filter images.ListFilter) (int, error) {
+	var n int
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if filter.IsZero() {
+			n = b.Stats().KeyN
+			return nil
+		}
+
+		return b.ForEach(func(_, v []byte) error {
+			var rec images.Record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if filter.Match(&rec) {
+				n++
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		const msg = "unable to count image records"
+		r.logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// List lists a page of image records in the database ordered by ID, the
+// cursor is the ID of the last record of the previous page. Other orders are
+// sorted in memory as the bucket is only keyed by ID. Returns an
+// ErrRecordNotFound if no records are found.
+func (r *Reader) List(ctx context.Context, opts images.ListOptions) (*images.Page, error) {
+	if opts.Sort != images.SortID || opts.Desc {
+		return r.listSorted(opts)
+	}
+
+	var page images.Page
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+
+		// keys are ordered so the cursor can seek straight past the previous
+		// page
+		k, v := c.First()
+		if opts.Cursor != "" {
+			k, v = c.Seek([]byte(opts.Cursor))
+			if k != nil && string(k) == opts.Cursor {
+				k, v = c.Next()
+			}
+		}
+		for ; k != nil; k, v = c.Next() {
+			if opts.Limit > 0 && len(page.Records) == opts.Limit {
+				page.NextCursor = page.Records[len(page.Records)-1].ID
+				return nil
+			}
+
+			var rec images.Record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if !opts.Filter.Match(&rec) {
+				continue
+			}
+			page.Records = append(page.Records, rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		const msg = "unable to list image records"
+		r.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	if len(page.Records) == 0 {
+		return nil, images.ErrRecordNotFound
+	}
+
+	return &page, nil
+}
+
+func (r *Reader) listSorted(opts images.ListOptions) (*images.Page, error) {
+	var list []images.Record
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, v []byte) error {
+			var rec images.Record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			list = append(list, rec)
+
+			return nil
+		})
+	})
+	if err != nil {
+		const msg = "unable to list image records"
+		r.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return images.PageRecords(list, opts)
+}
@@ -0,0 +1,266 @@
+package bolt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const writerLoggerName = "bolt.writer"
+
+// Writer provides the implementation to write image records to an embedded
+// bolt database.
+type Writer struct {
+	db     *bbolt.DB
+	logger *zap.Logger
+}
+
+// NewWriter returns an instantiated instance of a writer which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// db: the bolt database returned by Open
+func NewWriter(logger *zap.Logger, db *bbolt.DB) (*Writer, error) {
+	w := Writer{
+		db:     db,
+		logger: logger.Named(writerLoggerName),
+	}
+
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+
+	w.logger.Debug("successfully initialized image writer")
+
+	return &w, nil
+}
+
+func (w *Writer) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "db",
+			chk: func() bool { return w.db != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return w.logger != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize writer due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Create adds the given record to the database.
+func (w *Writer) Create(ctx context.Context, record *images.Record) error {
+	logger := w.logger.With(
+		zap.String("recordId", record.ID),
+		zap.String("key", record.Key),
+		zap.String("storage", record.Storage),
+	)
+
+	now := time.Now().UTC()
+	doc := *record
+	doc.Revision = 1
+	doc.UpdatedAt = &now
+	err := w.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b.Get([]byte(record.ID)) != nil {
+			return errors.New("record already exists")
+		}
+
+		return putRecord(b, &doc)
+	})
+	if err != nil {
+		const msg = "unable to insert image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	record.Revision = doc.Revision
+	record.UpdatedAt = doc.UpdatedAt
+
+	logger.Info("successfully inserted item in db")
+
+	return nil
+}
+
+// CreateBatch adds the given records to the database in a single
+// transaction.
+func (w *Writer) CreateBatch(ctx context.Context, records []images.Record) error {
+	now := time.Now().UTC()
+	failed := make(images.BatchError)
+	err := w.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		for i := range records {
+			if b.Get([]byte(records[i].ID)) != nil {
+				failed[records[i].ID] = errors.New("record already exists")
+				continue
+			}
+
+			doc := records[i]
+			doc.Revision = 1
+			doc.UpdatedAt = &now
+			if err := putRecord(b, &doc); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		const msg = "unable to insert image records"
+		w.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	for i := range records {
+		if _, ok := failed[records[i].ID]; !ok {
+			records[i].Revision = 1
+			records[i].UpdatedAt = &now
+		}
+	}
+	w.logger.Info(
+		"successfully inserted items in db",
+		zap.Int("inserted", len(records)-len(failed)),
+		zap.Int("failed", len(failed)),
+	)
+
+	if len(failed) > 0 {
+		return failed
+	}
+
+	return nil
+}
+
+// Delete removes the record with id from the database.
+func (w *Writer) Delete(ctx context.Context, id string) error {
+	logger := w.logger.With(zap.String("imageId", id))
+
+	err := w.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b.Get([]byte(id)) == nil {
+			return images.ErrRecordNotFound
+		}
+
+		return b.Delete([]byte(id))
+	})
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		logger.Error("record not found")
+		return err
+	default:
+		const msg = "unable to delete image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	logger.Info("successfully deleted item from db")
+
+	return nil
+}
+
+// DeleteBatch removes the records with the ids from the database in a single
+// transaction.
+func (w *Writer) DeleteBatch(ctx context.Context, ids []string) error {
+	failed := make(images.BatchError)
+	err := w.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		for _, id := range ids {
+			if b.Get([]byte(id)) == nil {
+				failed[id] = images.ErrRecordNotFound
+				continue
+			}
+			if err := b.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		const msg = "unable to delete image records"
+		w.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	w.logger.Info(
+		"successfully deleted items from db",
+		zap.Int("deleted", len(ids)-len(failed)),
+		zap.Int("failed", len(failed)),
+	)
+
+	if len(failed) > 0 {
+		return failed
+	}
+
+	return nil
+}
+
+// Update replaces the existing record in the database.
+func (w *Writer) Update(ctx context.Context, record *images.Record) error {
+	logger := w.logger.With(
+		zap.String("recordId", record.ID),
+		zap.String("key", record.Key),
+		zap.String("storage", record.Storage),
+	)
+
+	now := time.Now().UTC()
+	doc := *record
+	doc.UpdatedAt = &now
+	err := w.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		stored, err := getRecord(b, record.ID)
+		if err != nil {
+			return err
+		}
+		if record.Revision != 0 && record.Revision != stored.Revision {
+			return images.ErrConflict
+		}
+		doc.Revision = stored.Revision + 1
+
+		return putRecord(b, &doc)
+	})
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		logger.Error("record not found")
+		return err
+	case images.ErrConflict:
+		logger.Error("record revision does not match", zap.Uint64("revision", record.Revision))
+		return err
+	default:
+		const msg = "unable to replace image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	record.Revision = doc.Revision
+	record.UpdatedAt = doc.UpdatedAt
+
+	logger.Info("successfully replaced item in db")
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package cache provides read-through caching of image records by decorating
+// an images.Reader and images.Writer.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/cache.go github.com/itsHabib/sim/internal/cache Cache
+
+const (
+	// keyPrefix namespaces the keys of the cached records
+	keyPrefix = "sim:images:"
+
+	// listKey holds the cached list of all records
+	listKey = keyPrefix + "list"
+
+	// cacheTimeout bounds every call to the cache, within the deadline of
+	// the caller's context
+	cacheTimeout = time.Second
+)
+
+// ErrMiss is returned by a Cache when no value is cached for the key.
+var ErrMiss = errors.New("cache miss")
+
+// Cache provides the means to store values for a limited time.
+type Cache interface {
+	// Get returns the value cached for the key. Returns ErrMiss if no value
+	// is cached.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set caches the value for the key until the ttl elapses.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the keys from the cache. Missing keys are not an error.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Redis provides the Redis implementation of the Cache.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis returns a cache backed by the redis client.
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// Get returns the value cached for the key.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	b, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+
+	return b, err
+}
+
+// Set caches the value for the key until the ttl elapses.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Delete removes the keys from the cache.
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func recordKey(id string) string {
+	return keyPrefix + id
+}
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	mock_cache "github.com/itsHabib/sim/internal/cache/mocks"
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Reader_Get(t *testing.T) {
+	id := "id"
+	want := &images.Record{ID: id, Name: "name"}
+	for _, tc := range []struct {
+		desc    string
+		cache   func(ctrl *gomock.Controller) Cache
+		reader  func(ctrl *gomock.Controller) images.Reader
+		wantErr bool
+	}{
+		{
+			desc: "Get() should return the cached record without reading it",
+			cache: func(ctrl *gomock.Controller) Cache {
+				c := mock_cache.NewMockCache(ctrl)
+				c.
+					EXPECT().
+					Get(gomock.Any(), recordKey(id)).
+					Return([]byte(`{"id":"id","name":"name"}`), nil)
+
+				return c
+			},
+			reader: func(ctrl *gomock.Controller) images.Reader { return mock_images.NewMockReader(ctrl) },
+		},
+		{
+			desc: "Get() should read and cache the record on a miss",
+			cache: func(ctrl *gomock.Controller) Cache {
+				c := mock_cache.NewMockCache(ctrl)
+				c.
+					EXPECT().
+					Get(gomock.Any(), recordKey(id)).
+					Return(nil, ErrMiss)
+				c.
+					EXPECT().
+					Set(gomock.Any(), recordKey(id), gomock.Any(), time.Minute).
+					Return(nil)
+
+				return c
+			},
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), id).
+					Return(want, nil)
+
+				return r
+			},
+		},
+		{
+			desc: "Get() should fall through to the reader when the cache fails",
+			cache: func(ctrl *gomock.Controller) Cache {
+				c := mock_cache.NewMockCache(ctrl)
+				c.
+					EXPECT().
+					Get(gomock.Any(), recordKey(id)).
+					Return(nil, errors.New("random"))
+				c.
+					EXPECT().
+					Set(gomock.Any(), recordKey(id), gomock.Any(), time.Minute).
+					Return(errors.New("random"))
+
+				return c
+			},
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), id).
+					Return(want, nil)
+
+				return r
+			},
+		},
+		{
+			desc: "Get() should not cache a reader error",
+			cache: func(ctrl *gomock.Controller) Cache {
+				c := mock_cache.NewMockCache(ctrl)
+				c.
+					EXPECT().
+					Get(gomock.Any(), recordKey(id)).
+					Return(nil, ErrMiss)
+
+				return c
+			},
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), id).
+					Return(nil, images.ErrRecordNotFound)
+
+				return r
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, err := NewReader(zap.NewNop(), tc.reader(ctrl), tc.cache(ctrl), time.Minute)
+			require.NoError(t, err)
+
+			rec, err := r.Get(context.Background(), id)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, want, rec)
+			}
+		})
+	}
+}
+
+func Test_Writer_Update(t *testing.T) {
+	rec := &images.Record{ID: "id"}
+	for _, tc := range []struct {
+		desc    string
+		cache   func(ctrl *gomock.Controller) Cache
+		writer  func(ctrl *gomock.Controller) images.Writer
+		wantErr bool
+	}{
+		{
+			desc:  "Update() should not invalidate the cache when the update fails",
+			cache: func(ctrl *gomock.Controller) Cache { return mock_cache.NewMockCache(ctrl) },
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Update(gomock.Any(), rec).
+					Return(errors.New("random"))
+
+				return w
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Update() should invalidate the record and list",
+			cache: func(ctrl *gomock.Controller) Cache {
+				c := mock_cache.NewMockCache(ctrl)
+				c.
+					EXPECT().
+					Delete(gomock.Any(), recordKey(rec.ID), listKey).
+					Return(nil)
+
+				return c
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Update(gomock.Any(), rec).
+					Return(nil)
+
+				return w
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			w, err := NewWriter(zap.NewNop(), tc.writer(ctrl), tc.cache(ctrl))
+			require.NoError(t, err)
+
+			err = w.Update(context.Background(), rec)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Reader_Get_Canceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := mock_cache.NewMockCache(ctrl)
+	c.
+		EXPECT().
+		Get(ctx, recordKey("id")).
+		DoAndReturn(func(ctx context.Context, _ string) ([]byte, error) { return nil, ctx.Err() })
+	c.
+		EXPECT().
+		Set(ctx, recordKey("id"), gomock.Any(), time.Minute).
+		DoAndReturn(func(ctx context.Context, _ string, _ []byte, _ time.Duration) error { return ctx.Err() })
+	reader := mock_images.NewMockReader(ctrl)
+	reader.
+		EXPECT().
+		Get(ctx, "id").
+		Return(&images.Record{ID: "id"}, nil)
+
+	r, err := NewReader(zap.NewNop(), reader, c, time.Minute)
+	require.NoError(t, err)
+
+	// the cache is given the caller's context, its errors are only logged
+	rec, err := r.Get(ctx, "id")
+	require.NoError(t, err)
+	assert.Equal(t, "id", rec.ID)
+}
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const readerLoggerName = "cache.reader"
+
+// Reader decorates an images.Reader, serving Get and List from the cache and
+// populating the cache on a miss. Cache failures are logged and fall through
+// to the underlying reader.
+type Reader struct {
+	cache  Cache
+	logger *zap.Logger
+	reader images.Reader
+	ttl    time.Duration
+}
+
+// NewReader returns an instantiated instance of a caching reader which has
+// the following dependencies:
+//
+// logger: for structured logging
+//
+// reader: the reader that is cached
+//
+// cache: holds the cached records
+//
+// ttl: how long records are cached for
+func NewReader(logger *zap.Logger, reader images.Reader, cache Cache, ttl time.Duration) (*Reader, error) {
+	r := Reader{
+		cache:  cache,
+		logger: logger.Named(readerLoggerName),
+		reader: reader,
+		ttl:    ttl,
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Debug("successfully initialized caching image reader")
+
+	return &r, nil
+}
+
+func (r *Reader) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "cache",
+			chk: func() bool { return r.cache != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return r.logger != nil },
+		},
+		{
+			dep: "reader",
+			chk: func() bool { return r.reader != nil },
+		},
+		{
+			dep: "ttl",
+			chk: func() bool { return r.ttl > 0 },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize reader due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Get returns the cached image record, reading it from the underlying reader
+// on a miss.
+func (r *Reader) Get(ctx context.Context, id string) (*images.Record, error) {
+	logger := r.logger.With(zap.String("imageId", id))
+	key := recordKey(id)
+
+	var rec images.Record
+	if r.get(ctx, key, &rec, logger) {
+		return &rec, nil
+	}
+
+	got, err := r.reader.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.set(ctx, key, got, logger)
+
+	return got, nil
+}
+
+// GetByName reads the image record from the underlying reader, lookups by
+// name are not cached since renames would leave stale entries.
+func (r *Reader) GetByName(ctx context.Context, name string) (*images.Record, error) {
+	return r.reader.GetByName(ctx, name)
+}
+
+// GetByIDPrefix reads the image records from the underlying reader, prefix
+// lookups are not cached since new images would be missed.
+func (r *Reader) GetByIDPrefix(ctx context.Context, prefix string, limit int) ([]images.Record, error) {
+	return r.reader.GetByIDPrefix(ctx, prefix, limit)
+}
+
+// Count counts the records using the underlying reader, counts are not
+// cached.
+func (r *Reader) Count(ctx context.Context, filter images.ListFilter) (int, error) {
+	return r.reader.Count(ctx, filter)
+}
+
+// List returns the cached list of image records, listing them from the
+// underlying reader on a miss. Only the unpaged list is cached, pages are
+// always read from the underlying reader.
+func (r *Reader) List(ctx context.Context, opts images.ListOptions) (*images.Page, error) {
+	if opts.Limit != 0 || opts.Cursor != "" || !opts.Filter.IsZero() || opts.Sort != images.SortID || opts.Desc {
+		return r.reader.List(ctx, opts)
+	}
+
+	var page images.Page
+	if r.get(ctx, listKey, &page, r.logger) {
+		return &page, nil
+	}
+
+	got, err := r.reader.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.set(ctx, listKey, got, r.logger)
+
+	return got, nil
+}
+
+func (r *Reader) get(ctx context.Context, key string, v interface{}, logger *zap.Logger) bool {
+	b, err := r.cache.Get(ctx, key)
+	switch err {
+	case nil:
+	case ErrMiss:
+		logger.Debug("cache miss", zap.String("cacheKey", key))
+		return false
+	default:
+		logger.Error("unable to get from cache", zap.Error(err))
+		return false
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		logger.Error("unable to unmarshal cached value", zap.Error(err))
+		return false
+	}
+	logger.Debug("cache hit", zap.String("cacheKey", key))
+
+	return true
+}
+
+func (r *Reader) set(ctx context.Context, key string, v interface{}, logger *zap.Logger) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("unable to marshal value to cache", zap.Error(err))
+		return
+	}
+
+	if err := r.cache.Set(ctx, key, b, r.ttl); err != nil {
+		logger.Error("unable to set cache", zap.Error(err))
+	}
+}
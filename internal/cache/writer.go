@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const writerLoggerName = "cache.writer"
+
+// Writer decorates an images.Writer, invalidating the cached record and list
+// after every successful write.
+type Writer struct {
+	cache  Cache
+	logger *zap.Logger
+	writer images.Writer
+}
+
+// NewWriter returns an instantiated instance of an invalidating writer which
+// has the following dependencies:
+//
+// logger: for structured logging
+//
+// writer: the writer whose writes invalidate the cache
+//
+// cache: holds the cached records
+func NewWriter(logger *zap.Logger, writer images.Writer, cache Cache) (*Writer, error) {
+	w := Writer{
+		cache:  cache,
+		logger: logger.Named(writerLoggerName),
+		writer: writer,
+	}
+
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+
+	w.logger.Debug("successfully initialized invalidating image writer")
+
+	return &w, nil
+}
+
+func (w *Writer) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "cache",
+			chk: func() bool { return w.cache != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return w.logger != nil },
+		},
+		{
+			dep: "writer",
+			chk: func() bool { return w.writer != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize writer due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Create creates the record and invalidates the cache.
+func (w *Writer) Create(ctx context.Context, record *images.Record) error {
+	if err := w.writer.Create(ctx, record); err != nil {
+		return err
+	}
+	w.invalidate(ctx, record.ID)
+
+	return nil
+}
+
+// CreateBatch creates the records and invalidates the cache. The cache is
+// invalidated even if some records fail as others may have been created.
+func (w *Writer) CreateBatch(ctx context.Context, records []images.Record) error {
+	err := w.writer.CreateBatch(ctx, records)
+
+	ids := make([]string, len(records))
+	for i := range records {
+		ids[i] = records[i].ID
+	}
+	w.invalidate(ctx, ids...)
+
+	return err
+}
+
+// Delete deletes the record and invalidates the cache.
+func (w *Writer) Delete(ctx context.Context, id string) error {
+	err := w.writer.Delete(ctx, id)
+	switch err {
+	case nil, images.ErrRecordNotFound:
+		// a missing record may still be cached
+		w.invalidate(ctx, id)
+	}
+
+	return err
+}
+
+// DeleteBatch deletes the records and invalidates the cache. The cache is
+// invalidated even if some records fail as others may have been deleted.
+func (w *Writer) DeleteBatch(ctx context.Context, ids []string) error {
+	err := w.writer.DeleteBatch(ctx, ids)
+	w.invalidate(ctx, ids...)
+
+	return err
+}
+
+// Update replaces the record and invalidates the cache.
+func (w *Writer) Update(ctx context.Context, record *images.Record) error {
+	if err := w.writer.Update(ctx, record); err != nil {
+		return err
+	}
+	w.invalidate(ctx, record.ID)
+
+	return nil
+}
+
+// invalidate removes the records and list from the cache. A failure leaves
+// stale entries until their ttl elapses.
+func (w *Writer) invalidate(ctx context.Context, ids ...string) {
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, recordKey(id))
+	}
+	keys = append(keys, listKey)
+
+	if err := w.cache.Delete(ctx, keys...); err != nil {
+		w.logger.Error("unable to invalidate cache", zap.Strings("imageIds", ids), zap.Error(err))
+	}
+}
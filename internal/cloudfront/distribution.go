@@ -0,0 +1,195 @@
+package cloudfront
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/client.go github.com/itsHabib/sim/internal/cloudfront Client
+
+const (
+	loggerName = "cloudfront.distribution"
+
+	// globalRegion is the region of the requests to CloudFront, a global
+	// service, when the AWS config has none
+	globalRegion = "us-east-1"
+
+	// maxPaths is the number of paths invalidated by a single request
+	maxPaths = 1000
+)
+
+// Client provides an abstraction to aid in mocking for unit tests
+type Client interface {
+	// CreateInvalidation creates a new invalidation of the paths of a
+	// distribution.
+	CreateInvalidation(ctx context.Context, params *cloudfront.CreateInvalidationInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error)
+}
+
+// Distribution provides the CloudFront implementation of the images.CDN. The
+// distribution must serve the bucket from its root, so that the path of an
+// object is its key.
+type Distribution struct {
+	client       Client
+	clientOpts   []func(*cloudfront.Options)
+	configGetter images.ConfigGetter
+	id           string
+	logger       *zap.Logger
+}
+
+// Option provides the means to configure optional behavior of the
+// distribution.
+type Option func(d *Distribution)
+
+// WithEndpoint calls the CloudFront API at the endpoint instead of its
+// global endpoint, i.e. localstack.
+func WithEndpoint(endpoint string) Option {
+	return func(d *Distribution) {
+		d.clientOpts = append(d.clientOpts, func(o *cloudfront.Options) {
+			o.EndpointResolver = cloudfront.EndpointResolverFromURL(endpoint)
+		})
+	}
+}
+
+// NewDistribution returns an instantiated instance of a distribution which
+// has the following dependencies:
+//
+// logger: for structured logging
+//
+// id: the ID of the CloudFront distribution, i.e. E2QWRUHAPOMQZL
+//
+// configGetter: for loading the AWS config holding the credentials
+func NewDistribution(logger *zap.Logger, id string, configGetter images.ConfigGetter, opts ...Option) (*Distribution, error) {
+	d := Distribution{
+		configGetter: configGetter,
+		id:           id,
+		logger:       logger.Named(loggerName).With(zap.String("distributionId", id)),
+	}
+	for i := range opts {
+		opts[i](&d)
+	}
+
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := d.configGetter()
+	if err != nil {
+		const msg = "unable to get AWS config"
+		d.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = globalRegion
+	}
+	d.client = cloudfront.NewFromConfig(cfg, d.clientOpts...)
+
+	d.logger.Debug("successfully initialized cloudfront distribution")
+
+	return &d, nil
+}
+
+func (d *Distribution) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "id",
+			chk: func() bool { return d.id != "" },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return d.logger != nil },
+		},
+		{
+			dep: "configGetter",
+			chk: func() bool { return d.configGetter != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize distribution due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Invalidate creates invalidations of the objects at the keys, at most
+// maxPaths per invalidation. It returns once CloudFront accepted them, the
+// edge caches are cleared in the background.
+func (d *Distribution) Invalidate(ctx context.Context, keys []string) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > maxPaths {
+			n = maxPaths
+		}
+		if err := d.invalidate(ctx, keys[:n]); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+
+	return nil
+}
+
+func (d *Distribution) invalidate(ctx context.Context, keys []string) error {
+	logger := d.logger.With(zap.Strings("keys", keys))
+
+	paths := make([]string, len(keys))
+	for i, key := range keys {
+		paths[i] = objectPath(key)
+	}
+	input := cloudfront.CreateInvalidationInput{
+		DistributionId: &d.id,
+		InvalidationBatch: &types.InvalidationBatch{
+			CallerReference: aws.String(images.NewUUID()),
+			Paths: &types.Paths{
+				Quantity: aws.Int32(int32(len(paths))),
+				Items:    paths,
+			},
+		},
+	}
+	out, err := d.client.CreateInvalidation(ctx, &input)
+	if err != nil {
+		const msg = "unable to create invalidation"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	var id, status string
+	if out.Invalidation != nil {
+		id, status = aws.ToString(out.Invalidation.Id), aws.ToString(out.Invalidation.Status)
+	}
+	logger.Info("created invalidation", zap.String("invalidationId", id), zap.String("status", status))
+
+	return nil
+}
+
+// objectPath returns the path of the object at the key on the distribution,
+// with the segments of the key escaped.
+func objectPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
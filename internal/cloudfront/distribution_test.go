@@ -0,0 +1,120 @@
+package cloudfront
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	mock_cloudfront "github.com/itsHabib/sim/internal/cloudfront/mocks"
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_NewDistribution(t *testing.T) {
+	for _, tc := range []struct {
+		desc         string
+		id           string
+		configGetter images.ConfigGetter
+		wantErr      bool
+	}{
+		{
+			desc:         "NewDistribution() should return an error without an id",
+			configGetter: func() (aws.Config, error) { return aws.Config{}, nil },
+			wantErr:      true,
+		},
+		{
+			desc:         "NewDistribution() should return an error when failing to get the config",
+			id:           "E2QWRUHAPOMQZL",
+			configGetter: func() (aws.Config, error) { return aws.Config{}, errors.New("random") },
+			wantErr:      true,
+		},
+		{
+			desc:         "NewDistribution() should create the SDK client",
+			id:           "E2QWRUHAPOMQZL",
+			configGetter: func() (aws.Config, error) { return aws.Config{}, nil },
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			d, err := NewDistribution(zap.NewNop(), tc.id, tc.configGetter, WithEndpoint("http://localhost:4566"))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, d.client)
+		})
+	}
+}
+
+func Test_Distribution_Invalidate(t *testing.T) {
+	created := &cloudfront.CreateInvalidationOutput{
+		Invalidation: &types.Invalidation{Id: aws.String("I1"), Status: aws.String("InProgress")},
+	}
+	for _, tc := range []struct {
+		desc     string
+		keys     []string
+		err      error
+		wantReqs int
+		wantErr  bool
+	}{
+		{
+			desc:     "Invalidate() should create an invalidation of the keys' paths",
+			keys:     []string{"images/1/a.png", "images/2/my cat.png"},
+			wantReqs: 1,
+		},
+		{
+			desc:     "Invalidate() should split the keys into batches",
+			keys:     make([]string, maxPaths+1),
+			wantReqs: 2,
+		},
+		{
+			desc:     "Invalidate() should return an error when the invalidation can not be created",
+			keys:     []string{"images/1/a.png"},
+			err:      errors.New("random"),
+			wantReqs: 1,
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			var reqs []*types.InvalidationBatch
+			client := mock_cloudfront.NewMockClient(ctrl)
+			client.
+				EXPECT().
+				CreateInvalidation(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, in *cloudfront.CreateInvalidationInput, _ ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error) {
+					assert.Equal(t, "E2QWRUHAPOMQZL", aws.ToString(in.DistributionId))
+					reqs = append(reqs, in.InvalidationBatch)
+					return created, tc.err
+				}).
+				Times(tc.wantReqs)
+			d := Distribution{client: client, id: "E2QWRUHAPOMQZL", logger: zap.NewNop()}
+
+			err := d.Invalidate(context.Background(), tc.keys)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var paths []string
+			for _, batch := range reqs {
+				assert.NotEmpty(t, aws.ToString(batch.CallerReference))
+				assert.Equal(t, int32(len(batch.Paths.Items)), aws.ToInt32(batch.Paths.Quantity))
+				paths = append(paths, batch.Paths.Items...)
+			}
+			assert.Len(t, paths, len(tc.keys))
+			if tc.wantReqs == 1 {
+				assert.Equal(t, []string{"/images/1/a.png", "/images/2/my%20cat.png"}, paths)
+			}
+		})
+	}
+}
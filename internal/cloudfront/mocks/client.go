@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/itsHabib/sim/internal/cloudfront (interfaces: Client)
+
+// Package mock_cloudfront is a generated GoMock package.
+package mock_cloudfront
+
+import (
+	context "context"
+	reflect "reflect"
+
+	cloudfront "github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockClient is a mock of Client interface.
+type MockClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientMockRecorder
+}
+
+// MockClientMockRecorder is the mock recorder for MockClient.
+type MockClientMockRecorder struct {
+	mock *MockClient
+}
+
+// NewMockClient creates a new mock instance.
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
+	return m.recorder
+}
+
+// CreateInvalidation mocks base method.
+func (m *MockClient) CreateInvalidation(arg0 context.Context, arg1 *cloudfront.CreateInvalidationInput, arg2 ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateInvalidation", varargs...)
+	ret0, _ := ret[0].(*cloudfront.CreateInvalidationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateInvalidation indicates an expected call of CreateInvalidation.
+func (mr *MockClientMockRecorder) CreateInvalidation(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInvalidation", reflect.TypeOf((*MockClient)(nil).CreateInvalidation), varargs...)
+}
@@ -0,0 +1,133 @@
+package cloudfront
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// signatureEncoding is the base64 encoding of CloudFront signatures and
+// policies, the characters which are invalid in a query string replaced.
+var signatureEncoding = strings.NewReplacer("+", "-", "=", "_", "/", "~")
+
+// URLSigner provides the CloudFront implementation of the images.URLSigner.
+// URLs are signed with a canned policy, which grants access to a single
+// object until the expiry, using the private key of a key of the
+// distribution's trusted key group.
+type URLSigner struct {
+	baseURL   string
+	key       *rsa.PrivateKey
+	keyPairID string
+}
+
+// NewURLSigner returns a signer of the URLs of the objects served from the
+// base URL, i.e. https://d111111abcdef8.cloudfront.net or a custom domain,
+// which must serve the bucket from its root. key is the private key of the
+// public key with the ID keyPairID, URLs are not signed when it is nil.
+func NewURLSigner(baseURL, keyPairID string, key *rsa.PrivateKey) (*URLSigner, error) {
+	u, err := url.Parse(baseURL)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+		return nil, fmt.Errorf("invalid base url, expected an http or https url: %s", baseURL)
+	case u.RawQuery != "" || u.Fragment != "":
+		return nil, fmt.Errorf("invalid base url, it can not have a query or fragment: %s", baseURL)
+	case key != nil && keyPairID == "":
+		return nil, errors.New("a key pair id is required to sign urls")
+	}
+
+	return &URLSigner{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		key:       key,
+		keyPairID: keyPairID,
+	}, nil
+}
+
+// URL returns the URL of the object at the key on the distribution.
+func (s *URLSigner) URL(key string) string {
+	return s.baseURL + objectPath(key)
+}
+
+// cannedPolicy is the policy CloudFront checks a signed URL against, it is
+// signed but not sent.
+type cannedPolicy struct {
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Resource  string `json:"Resource"`
+	Condition struct {
+		DateLessThan struct {
+			EpochTime int64 `json:"AWS:EpochTime"`
+		} `json:"DateLessThan"`
+	} `json:"Condition"`
+}
+
+// Sign returns the URL of the object at the key signed with a canned policy
+// which expires at the expiry.
+func (s *URLSigner) Sign(key string, expires time.Time) (string, error) {
+	if s.key == nil {
+		return "", fmt.Errorf("%w: no key to sign cdn urls with", images.ErrUnsupported)
+	}
+
+	resource := s.URL(key)
+	var statement policyStatement
+	statement.Resource = resource
+	statement.Condition.DateLessThan.EpochTime = expires.Unix()
+	policy, err := json.Marshal(cannedPolicy{Statement: []policyStatement{statement}})
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal policy: %w", err)
+	}
+
+	sum := sha1.Sum(policy)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA1, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign policy: %w", err)
+	}
+
+	q := url.Values{}
+	q.Set("Expires", fmt.Sprint(expires.Unix()))
+	q.Set("Signature", signatureEncoding.Replace(base64.StdEncoding.EncodeToString(sig)))
+	q.Set("Key-Pair-Id", s.keyPairID)
+
+	return resource + "?" + q.Encode(), nil
+}
+
+// ParsePrivateKey parses the PEM encoded RSA private key CloudFront URLs are
+// signed with, in either PKCS #1 or PKCS #8 form.
+func ParsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key is not an RSA key")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unexpected PEM block type: %s", block.Type)
+	}
+}
@@ -0,0 +1,142 @@
+package cloudfront
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_NewURLSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		desc      string
+		baseURL   string
+		keyPairID string
+		key       *rsa.PrivateKey
+		wantErr   bool
+	}{
+		{
+			desc:    "NewURLSigner() should accept an https base url",
+			baseURL: "https://d111111abcdef8.cloudfront.net/",
+		},
+		{
+			desc:      "NewURLSigner() should accept a signing key",
+			baseURL:   "https://images.example.com",
+			keyPairID: "K2JCJMDEHXQW5F",
+			key:       key,
+		},
+		{
+			desc:    "NewURLSigner() should return an error when the base url is not http",
+			baseURL: "s3://bucket",
+			wantErr: true,
+		},
+		{
+			desc:    "NewURLSigner() should return an error when the base url has a query",
+			baseURL: "https://images.example.com?a=b",
+			wantErr: true,
+		},
+		{
+			desc:    "NewURLSigner() should return an error when the key has no key pair id",
+			baseURL: "https://images.example.com",
+			key:     key,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewURLSigner(tc.baseURL, tc.keyPairID, tc.key)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_URLSigner_Sign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	s, err := NewURLSigner("https://images.example.com/", "K2JCJMDEHXQW5F", key)
+	require.NoError(t, err)
+	expires := time.Unix(1700000000, 0)
+
+	signed, err := s.Sign("images/1/my cat.png", expires)
+	require.NoError(t, err)
+
+	resource := "https://images.example.com/images/1/my%20cat.png"
+	require.True(t, strings.HasPrefix(signed, resource+"?"))
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, "1700000000", q.Get("Expires"))
+	assert.Equal(t, "K2JCJMDEHXQW5F", q.Get("Key-Pair-Id"))
+
+	sig, err := base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(q.Get("Signature")))
+	require.NoError(t, err)
+	policy := fmt.Sprintf(`{"Statement":[{"Resource":"%s","Condition":{"DateLessThan":{"AWS:EpochTime":1700000000}}}]}`, resource)
+	sum := sha1.Sum([]byte(policy))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, sum[:], sig))
+
+	unsigned, err := NewURLSigner("https://images.example.com", "", nil)
+	require.NoError(t, err)
+	_, err = unsigned.Sign("key", expires)
+	assert.True(t, errors.Is(err, images.ErrUnsupported))
+	assert.Equal(t, "https://images.example.com/key", unsigned.URL("key"))
+}
+
+func Test_ParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		desc    string
+		pem     []byte
+		wantErr bool
+	}{
+		{
+			desc: "ParsePrivateKey() should parse a PKCS #1 key",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+		{
+			desc: "ParsePrivateKey() should parse a PKCS #8 key",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		},
+		{
+			desc:    "ParsePrivateKey() should return an error when there is no PEM block",
+			pem:     []byte("not a key"),
+			wantErr: true,
+		},
+		{
+			desc:    "ParsePrivateKey() should return an error for a public key",
+			pem:     pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("k")}),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParsePrivateKey(tc.pem)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, key.Equal(got))
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package couchbase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+
+	"github.com/itsHabib/sim/internal/audit"
+)
+
+// ActivityCollection returns the name of the collection holding the
+// activities run against the image records in collection.
+func ActivityCollection(collection string) string {
+	return collection + "_activity"
+}
+
+// ActivityLog persists the activities as documents keyed by activity ID.
+type ActivityLog struct {
+	cluster    *gocb.Cluster
+	collection *gocb.Collection
+	keyspace   string
+}
+
+// NewActivityLog returns an activity log for the image records in the
+// collection, the activities are held in its ActivityCollection.
+func NewActivityLog(cluster *gocb.Cluster, bucket, scope, collection string) *ActivityLog {
+	name := ActivityCollection(collection)
+
+	return &ActivityLog{
+		cluster:    cluster,
+		collection: cluster.Bucket(bucket).Scope(scope).Collection(name),
+		keyspace:   Keyspace(bucket, scope, name),
+	}
+}
+
+// Record inserts the activity.
+func (l *ActivityLog) Record(ctx context.Context, a *audit.Activity) error {
+	if _, err := l.collection.Insert(a.ID, a, &gocb.InsertOptions{Context: ctx}); err != nil {
+		return fmt.Errorf("unable to insert activity: %w", err)
+	}
+
+	return nil
+}
+
+// Recent returns the latest activities matching the filter in the order they
+// happened.
+func (l *ActivityLog) Recent(ctx context.Context, filter audit.ActivityFilter, limit int) ([]audit.Activity, error) {
+	where := []string{"a.id IS NOT MISSING"}
+	params := make(map[string]interface{})
+	for _, c := range []struct {
+		field string
+		value string
+	}{
+		{field: "actor", value: filter.Actor},
+		{field: "command", value: filter.Command},
+		{field: "imageId", value: filter.ImageID},
+	} {
+		if c.value == "" {
+			continue
+		}
+		where = append(where, "a."+c.field+" = $"+c.field)
+		params[c.field] = c.value
+	}
+	if filter.Failed {
+		where = append(where, "a.outcome = $outcome")
+		params["outcome"] = audit.OutcomeError
+	}
+	if filter.After != "" {
+		where = append(where, "a.id > $after")
+		params["after"] = filter.After
+	}
+
+	query := "SELECT RAW a FROM " + l.keyspace + " a WHERE " + strings.Join(where, " AND ") + " ORDER BY a.id DESC"
+	if limit > 0 {
+		query += " LIMIT $limit"
+		params["limit"] = limit
+	}
+	options := gocb.QueryOptions{
+		Context:         ctx,
+		NamedParameters: params,
+		ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+	}
+	result, err := l.cluster.Query(query, &options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query activities: %w", err)
+	}
+
+	var recent []audit.Activity
+	for result.Next() {
+		var a audit.Activity
+		if err := result.Row(&a); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal activity: %w", err)
+		}
+		recent = append(recent, a)
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read activities: %w", err)
+	}
+	for i, j := 0, len(recent)-1; i < j; i, j = i+1, j-1 {
+		recent[i], recent[j] = recent[j], recent[i]
+	}
+
+	return recent, nil
+}
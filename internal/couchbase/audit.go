@@ -0,0 +1,77 @@
+package couchbase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/couchbase/gocb/v2"
+
+	"github.com/itsHabib/sim/internal/audit"
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// AuditCollection returns the name of the collection holding the audit
+// events of the image records in collection.
+func AuditCollection(collection string) string {
+	return collection + "_audit"
+}
+
+// AuditStore persists the audit events of image records as documents keyed
+// by event ID.
+type AuditStore struct {
+	cluster    *gocb.Cluster
+	collection *gocb.Collection
+	keyspace   string
+}
+
+// NewAuditStore returns an audit store for the image records in the
+// collection, the events are held in its AuditCollection.
+func NewAuditStore(cluster *gocb.Cluster, bucket, scope, collection string) *AuditStore {
+	name := AuditCollection(collection)
+
+	return &AuditStore{
+		cluster:    cluster,
+		collection: cluster.Bucket(bucket).Scope(scope).Collection(name),
+		keyspace:   Keyspace(bucket, scope, name),
+	}
+}
+
+// Append inserts the event.
+func (s *AuditStore) Append(ctx context.Context, e *audit.Event) error {
+	if _, err := s.collection.Insert(e.ID, e, &gocb.InsertOptions{Context: ctx}); err != nil {
+		return fmt.Errorf("unable to insert audit event: %w", err)
+	}
+
+	return nil
+}
+
+// History returns the events of the image record in the order they happened.
+func (s *AuditStore) History(ctx context.Context, imageID string) ([]audit.Event, error) {
+	query := "SELECT RAW a FROM " + s.keyspace + " a WHERE a.imageId = $imageId ORDER BY a.id"
+	options := gocb.QueryOptions{
+		Context:         ctx,
+		NamedParameters: map[string]interface{}{"imageId": imageID},
+		ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+	}
+	result, err := s.cluster.Query(query, &options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query audit events: %w", err)
+	}
+
+	var events []audit.Event
+	for result.Next() {
+		var e audit.Event
+		if err := result.Row(&e); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal audit event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read audit events: %w", err)
+	}
+	if len(events) == 0 {
+		return nil, images.ErrRecordNotFound
+	}
+
+	return events, nil
+}
@@ -0,0 +1,125 @@
+// Package couchbase provides the means to connect to the couchbase cluster
+// which holds the image records.
+package couchbase
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// tlsScheme is the connection string scheme which enables TLS
+const tlsScheme = "couchbases://"
+
+// Config represents the configuration needed to connect to the cluster.
+type Config struct {
+	// Endpoint is the connection string, use the couchbases:// scheme to
+	// connect over TLS
+	Endpoint string
+
+	// Username and Password authenticate with the cluster when no client
+	// certificate is configured
+	Username string
+	Password string
+
+	// CAFile is the path to a PEM encoded CA bundle used to verify the
+	// cluster's certificate, the system roots are used when empty
+	CAFile string
+
+	// CertFile and KeyFile are the paths to the PEM encoded client
+	// certificate and key used to authenticate with the cluster, requires TLS
+	CertFile string
+	KeyFile  string
+
+	// TLSSkipVerify disables verification of the cluster's certificate. This
+	// should only be used for local development.
+	TLSSkipVerify bool
+
+	// ConnectTimeout, KVTimeout and QueryTimeout bound connecting to the
+	// cluster, key value operations and queries, the SDK defaults are used
+	// when zero
+	ConnectTimeout time.Duration
+	KVTimeout      time.Duration
+	QueryTimeout   time.Duration
+
+	// DisableCompression turns off compression of documents sent to and
+	// received from the cluster
+	DisableCompression bool
+}
+
+// Connect connects to the cluster described by the config.
+func Connect(cfg Config) (*gocb.Cluster, error) {
+	opts, err := clusterOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return gocb.Connect(connectionString(cfg), opts)
+}
+
+// connectionString returns the endpoint with the connection string options
+// that have no equivalent in the cluster options.
+func connectionString(cfg Config) string {
+	if !cfg.DisableCompression {
+		return cfg.Endpoint
+	}
+
+	sep := "?"
+	if strings.Contains(cfg.Endpoint, "?") {
+		sep = "&"
+	}
+
+	return cfg.Endpoint + sep + "compression=false"
+}
+
+func clusterOptions(cfg Config) (gocb.ClusterOptions, error) {
+	tlsEnabled := strings.HasPrefix(cfg.Endpoint, tlsScheme)
+
+	var opts gocb.ClusterOptions
+	switch {
+	case cfg.CertFile != "" || cfg.KeyFile != "":
+		if !tlsEnabled {
+			return opts, errors.New("client certificate auth requires a " + tlsScheme + " connection string")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return opts, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		opts.Authenticator = gocb.CertificateAuthenticator{ClientCertificate: &cert}
+	default:
+		opts.Authenticator = gocb.PasswordAuthenticator{
+			Username: cfg.Username,
+			Password: cfg.Password,
+		}
+	}
+
+	if cfg.CAFile != "" {
+		if !tlsEnabled {
+			return opts, errors.New("a CA bundle requires a " + tlsScheme + " connection string")
+		}
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return opts, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return opts, errors.New("no certificates found in CA bundle")
+		}
+		opts.SecurityConfig.TLSRootCAs = pool
+	}
+	opts.SecurityConfig.TLSSkipVerify = cfg.TLSSkipVerify
+
+	opts.TimeoutsConfig = gocb.TimeoutsConfig{
+		ConnectTimeout: cfg.ConnectTimeout,
+		KVTimeout:      cfg.KVTimeout,
+		QueryTimeout:   cfg.QueryTimeout,
+	}
+
+	return opts, nil
+}
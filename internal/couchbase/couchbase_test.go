@@ -0,0 +1,123 @@
+package couchbase
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_clusterOptions(t *testing.T) {
+	dir := t.TempDir()
+	invalidCA := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(invalidCA, []byte("not a cert"), 0o600))
+
+	for _, tc := range []struct {
+		desc    string
+		cfg     Config
+		check   func(t *testing.T, opts gocb.ClusterOptions)
+		wantErr bool
+	}{
+		{
+			desc: "clusterOptions() should use password auth without a client certificate",
+			cfg:  Config{Endpoint: "localhost", Username: "user", Password: "pass"},
+			check: func(t *testing.T, opts gocb.ClusterOptions) {
+				assert.Equal(t, gocb.PasswordAuthenticator{Username: "user", Password: "pass"}, opts.Authenticator)
+			},
+		},
+		{
+			desc:    "clusterOptions() should require TLS for client certificate auth",
+			cfg:     Config{Endpoint: "couchbase://localhost", CertFile: "cert.pem", KeyFile: "key.pem"},
+			wantErr: true,
+		},
+		{
+			desc:    "clusterOptions() should require TLS for a CA bundle",
+			cfg:     Config{Endpoint: "couchbase://localhost", CAFile: invalidCA},
+			wantErr: true,
+		},
+		{
+			desc:    "clusterOptions() should return an error when the CA bundle has no certificates",
+			cfg:     Config{Endpoint: "couchbases://localhost", CAFile: invalidCA},
+			wantErr: true,
+		},
+		{
+			desc: "clusterOptions() should set skip verify",
+			cfg:  Config{Endpoint: "couchbases://localhost", TLSSkipVerify: true},
+			check: func(t *testing.T, opts gocb.ClusterOptions) {
+				assert.True(t, opts.SecurityConfig.TLSSkipVerify)
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts, err := clusterOptions(tc.cfg)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tc.check(t, opts)
+		})
+	}
+}
+
+func Test_connectionString(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		cfg  Config
+		want string
+	}{
+		{
+			desc: "connectionString() should return the endpoint when compression is enabled",
+			cfg:  Config{Endpoint: "couchbase://localhost"},
+			want: "couchbase://localhost",
+		},
+		{
+			desc: "connectionString() should disable compression",
+			cfg:  Config{Endpoint: "couchbase://localhost", DisableCompression: true},
+			want: "couchbase://localhost?compression=false",
+		},
+		{
+			desc: "connectionString() should append to existing options",
+			cfg:  Config{Endpoint: "couchbase://localhost?network=external", DisableCompression: true},
+			want: "couchbase://localhost?network=external&compression=false",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, connectionString(tc.cfg))
+		})
+	}
+}
+
+func Test_index_statement(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		index index
+		want  string
+	}{
+		{
+			desc:  "statement() should create a primary index without keys",
+			index: primaryIndex,
+			want:  "CREATE PRIMARY INDEX ON `b`.`s`.`c`",
+		},
+		{
+			desc:  "statement() should create a secondary index on the keys",
+			index: tagsIndex,
+			want:  "CREATE INDEX `images_tags` ON `b`.`s`.`c`(DISTINCT ARRAY t FOR t IN tags END)",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.index.statement(Keyspace("b", "s", "c")))
+		})
+	}
+}
+
+func Test_migrationsKey(t *testing.T) {
+	t.Run("migrationsKey() should be unique to the scope and collection", func(t *testing.T) {
+		assert.Equal(t, "sim::migrations::sim::images", migrationsKey("sim", "images"))
+		assert.NotEqual(t, migrationsKey("sim", "images"), migrationsKey("sim", "photos"))
+		assert.NotEqual(t, migrationsKey("sim", "images"), migrationsKey("other", "images"))
+	})
+}
@@ -0,0 +1,147 @@
+package couchbase
+
+import (
+	"fmt"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// index represents a N1QL index required by the image record queries.
+type index struct {
+	name string
+
+	// keys are the index keys, a primary index has none
+	keys string
+}
+
+var (
+	primaryIndex = index{name: "#primary"}
+	nameIndex    = index{name: "images_name", keys: "name"}
+
+	// createdAtIndex matches the expression lists are filtered and sorted by
+	createdAtIndex = index{name: "images_created_at", keys: "STR_TO_MILLIS(createdAt)"}
+	tagsIndex      = index{name: "images_tags", keys: "DISTINCT ARRAY t FOR t IN tags END"}
+	etagIndex      = index{name: "images_etag", keys: "etag"}
+	sha256Index    = index{name: "images_sha256", keys: "sha256"}
+
+	// auditIndex is on the audit collection, it serves the history of a
+	// record in order
+	auditIndex = index{name: "audit_image", keys: "imageId, id"}
+
+	// activityIndex is on the activity collection, it serves the latest
+	// activities
+	activityIndex = index{name: "activity_id", keys: "id"}
+)
+
+// requiredIndexes are the indexes the image record queries rely on.
+var requiredIndexes = []index{primaryIndex, nameIndex, createdAtIndex, tagsIndex, etagIndex, sha256Index}
+
+func (i index) statement(keyspace string) string {
+	if i.keys == "" {
+		return "CREATE PRIMARY INDEX ON " + keyspace
+	}
+
+	return "CREATE INDEX `" + i.name + "` ON " + keyspace + "(" + i.keys + ")"
+}
+
+const (
+	// IndexMissing is the state of a required index which does not exist
+	IndexMissing = "missing"
+
+	// IndexOnline is the state of an index which has been built and can
+	// serve queries
+	IndexOnline = "online"
+)
+
+// IndexStatus represents the state of a required index, IndexOnline once it
+// can serve queries. Other states are reported by the index service, i.e.
+// "building" or "deferred".
+type IndexStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// Indexes manages the indexes on the collection holding the image records.
+type Indexes struct {
+	bucket     string
+	cluster    *gocb.Cluster
+	collection string
+	scope      string
+}
+
+// NewIndexes returns the index manager of the collection.
+func NewIndexes(cluster *gocb.Cluster, bucket, scope, collection string) *Indexes {
+	return &Indexes{
+		bucket:     bucket,
+		cluster:    cluster,
+		collection: collection,
+		scope:      scope,
+	}
+}
+
+// Status returns the state of each required index.
+func (i *Indexes) Status() ([]IndexStatus, error) {
+	const query = "SELECT RAW {\"name\": name, \"state\": state} FROM system:indexes " +
+		"WHERE bucket_id = $bucket AND scope_id = $scope AND keyspace_id = $collection"
+	options := gocb.QueryOptions{
+		NamedParameters: map[string]interface{}{
+			"bucket":     i.bucket,
+			"scope":      i.scope,
+			"collection": i.collection,
+		},
+	}
+	result, err := i.cluster.Query(query, &options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query indexes: %w", err)
+	}
+
+	states := make(map[string]string)
+	for result.Next() {
+		var status IndexStatus
+		if err := result.Row(&status); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal index: %w", err)
+		}
+		states[status.Name] = status.State
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read indexes: %w", err)
+	}
+
+	statuses := make([]IndexStatus, len(requiredIndexes))
+	for n, idx := range requiredIndexes {
+		state, ok := states[idx.name]
+		if !ok {
+			state = IndexMissing
+		}
+		statuses[n] = IndexStatus{Name: idx.name, State: state}
+	}
+
+	return statuses, nil
+}
+
+// Ensure creates the required indexes which are missing and returns the
+// state of each. Indexes are built in the background, newly created indexes
+// may still be building.
+func (i *Indexes) Ensure() ([]IndexStatus, error) {
+	statuses, err := i.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	keyspace := Keyspace(i.bucket, i.scope, i.collection)
+	created := false
+	for n, idx := range requiredIndexes {
+		if statuses[n].State != IndexMissing {
+			continue
+		}
+		if err := createIndex(i.cluster, idx.statement(keyspace)); err != nil {
+			return nil, fmt.Errorf("unable to create index (%s): %w", idx.name, err)
+		}
+		created = true
+	}
+	if !created {
+		return statuses, nil
+	}
+
+	return i.Status()
+}
@@ -0,0 +1,207 @@
+package couchbase
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+
+	"github.com/itsHabib/sim/internal/migrate"
+)
+
+// migrationsKey returns the document in the bucket's default collection
+// which records the migrations applied to the collection of the scope. The
+// default collection always exists so it can be read before the images
+// collection is created, and each collection has a document of its own as
+// each is set up by migrations of its own.
+func migrationsKey(scope, collection string) string {
+	return "sim::migrations::" + scope + "::" + collection
+}
+
+// Migrations returns the migrations which create the scope, collections and
+// indexes holding the image records, their audit events and the activities.
+func Migrations(cluster *gocb.Cluster, bucket, scope, collection string) []migrate.Migration {
+	keyspace := Keyspace(bucket, scope, collection)
+	collections := cluster.Bucket(bucket).Collections()
+
+	return []migrate.Migration{
+		{
+			Version:     1,
+			Description: "create scope " + scope,
+			Up: func() error {
+				err := collections.CreateScope(scope, nil)
+				if errors.Is(err, gocb.ErrScopeExists) {
+					return nil
+				}
+				return err
+			},
+		},
+		{
+			Version:     2,
+			Description: "create collection " + collection,
+			Up: func() error {
+				return createCollection(collections, scope, collection)
+			},
+		},
+		{
+			Version:     3,
+			Description: "create primary index",
+			Up: func() error {
+				return createIndex(cluster, primaryIndex.statement(keyspace))
+			},
+		},
+		{
+			Version:     4,
+			Description: "create name index",
+			Up: func() error {
+				return createIndex(cluster, nameIndex.statement(keyspace))
+			},
+		},
+		{
+			Version:     5,
+			Description: "create createdAt and tags indexes",
+			Up: func() error {
+				if err := createIndex(cluster, createdAtIndex.statement(keyspace)); err != nil {
+					return err
+				}
+				return createIndex(cluster, tagsIndex.statement(keyspace))
+			},
+		},
+		{
+			Version:     6,
+			Description: "create etag and sha256 indexes",
+			Up: func() error {
+				if err := createIndex(cluster, etagIndex.statement(keyspace)); err != nil {
+					return err
+				}
+				return createIndex(cluster, sha256Index.statement(keyspace))
+			},
+		},
+		{
+			Version:     7,
+			Description: "create collection " + AuditCollection(collection),
+			Up: func() error {
+				if err := createCollection(collections, scope, AuditCollection(collection)); err != nil {
+					return err
+				}
+				audit := Keyspace(bucket, scope, AuditCollection(collection))
+				return createIndex(cluster, auditIndex.statement(audit))
+			},
+		},
+		{
+			Version:     8,
+			Description: "create collection " + ActivityCollection(collection),
+			Up: func() error {
+				if err := createCollection(collections, scope, ActivityCollection(collection)); err != nil {
+					return err
+				}
+				activity := Keyspace(bucket, scope, ActivityCollection(collection))
+				return createIndex(cluster, activityIndex.statement(activity))
+			},
+		},
+	}
+}
+
+// createCollection creates the collection, a collection which already
+// exists is not an error.
+func createCollection(collections *gocb.CollectionManager, scope, name string) error {
+	spec := gocb.CollectionSpec{Name: name, ScopeName: scope}
+	err := collections.CreateCollection(spec, nil)
+	if errors.Is(err, gocb.ErrCollectionExists) {
+		return nil
+	}
+
+	return err
+}
+
+// Keyspace returns the escaped keyspace of the collection for queries.
+func Keyspace(bucket, scope, collection string) string {
+	return "`" + bucket + "`.`" + scope + "`.`" + collection + "`"
+}
+
+// createIndex runs the index statement, an index which already exists is not
+// an error.
+func createIndex(cluster *gocb.Cluster, statement string) error {
+	_, err := cluster.Query(statement, nil)
+	if err == nil || isIndexExists(err) {
+		return nil
+	}
+
+	return err
+}
+
+// isIndexExists reports whether the error is due to the index already
+// existing. The query service does not return a distinct error code so the
+// message is checked, as the SDK's own index manager does.
+func isIndexExists(err error) bool {
+	return errors.Is(err, gocb.ErrIndexExists) || strings.Contains(strings.ToLower(err.Error()), "already exist")
+}
+
+// MigrationStore records the migrations applied to a collection in a
+// document in the bucket's default collection.
+type MigrationStore struct {
+	collection *gocb.Collection
+	key        string
+}
+
+// NewMigrationStore returns a migration store for the collection of the
+// scope in the bucket.
+func NewMigrationStore(cluster *gocb.Cluster, bucket, scope, collection string) *MigrationStore {
+	return &MigrationStore{
+		collection: cluster.Bucket(bucket).DefaultCollection(),
+		key:        migrationsKey(scope, collection),
+	}
+}
+
+type migrationsDoc struct {
+	Applied []migrate.Applied `json:"applied"`
+}
+
+// Applied returns the migrations which have been applied.
+func (s *MigrationStore) Applied() ([]migrate.Applied, error) {
+	doc, _, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Applied, nil
+}
+
+// Record records the migration as applied. Concurrent runs are detected by
+// the document's CAS.
+func (s *MigrationStore) Record(applied migrate.Applied) error {
+	doc, cas, err := s.get()
+	if err != nil {
+		return err
+	}
+	doc.Applied = append(doc.Applied, applied)
+
+	if cas == 0 {
+		_, err = s.collection.Insert(s.key, doc, nil)
+	} else {
+		_, err = s.collection.Replace(s.key, doc, &gocb.ReplaceOptions{Cas: cas})
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write applied migrations: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MigrationStore) get() (*migrationsDoc, gocb.Cas, error) {
+	var doc migrationsDoc
+	res, err := s.collection.Get(s.key, nil)
+	switch {
+	case errors.Is(err, gocb.ErrDocumentNotFound):
+		return &doc, 0, nil
+	case err != nil:
+		return nil, 0, fmt.Errorf("unable to get applied migrations: %w", err)
+	}
+
+	if err := res.Content(&doc); err != nil {
+		return nil, 0, fmt.Errorf("unable to unmarshal applied migrations: %w", err)
+	}
+
+	return &doc, res.Cas(), nil
+}
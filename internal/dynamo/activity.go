@@ -0,0 +1,143 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/itsHabib/sim/internal/audit"
+)
+
+// activityPartition is the partition key of every activity, the log is read
+// in sort key order so it is held in a single partition.
+const activityPartition = "activity"
+
+// ActivityLog persists the activities in a table with a string partition
+// key named log and a string sort key named id.
+type ActivityLog struct {
+	client Client
+	table  string
+}
+
+// NewActivityLog returns an activity log on the table.
+func NewActivityLog(client Client, table string) *ActivityLog {
+	return &ActivityLog{client: client, table: table}
+}
+
+// Record puts the activity in the table.
+func (l *ActivityLog) Record(ctx context.Context, a *audit.Activity) error {
+	enc := attributevalue.NewEncoder(func(o *attributevalue.EncoderOptions) {
+		o.TagKey = tagKey
+	})
+	av, err := enc.Encode(a)
+	if err != nil {
+		return fmt.Errorf("unable to marshal activity: %w", err)
+	}
+	m, ok := av.(*types.AttributeValueMemberM)
+	if !ok {
+		return fmt.Errorf("unexpected attribute value type: %T", av)
+	}
+	m.Value["log"] = &types.AttributeValueMemberS{Value: activityPartition}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	input := dynamodb.PutItemInput{
+		Item:      m.Value,
+		TableName: &l.table,
+	}
+	if _, err := l.client.PutItem(ctx, &input); err != nil {
+		return fmt.Errorf("unable to put activity: %w", err)
+	}
+
+	return nil
+}
+
+// Recent returns the latest activities matching the filter in the order they
+// happened, querying the log backwards from the latest activity.
+func (l *ActivityLog) Recent(ctx context.Context, filter audit.ActivityFilter, limit int) ([]audit.Activity, error) {
+	dec := attributevalue.NewDecoder(func(o *attributevalue.DecoderOptions) {
+		o.TagKey = tagKey
+	})
+
+	keyCond := "#log = :log"
+	names := map[string]string{"#log": "log"}
+	values := map[string]types.AttributeValue{
+		":log": &types.AttributeValueMemberS{Value: activityPartition},
+	}
+	if filter.After != "" {
+		keyCond += " AND #id > :after"
+		names["#id"] = "id"
+		values[":after"] = &types.AttributeValueMemberS{Value: filter.After}
+	}
+
+	var conds []string
+	for _, c := range []struct {
+		field string
+		value string
+	}{
+		{field: "actor", value: filter.Actor},
+		{field: "command", value: filter.Command},
+		{field: "imageId", value: filter.ImageID},
+	} {
+		if c.value == "" {
+			continue
+		}
+		conds = append(conds, "#"+c.field+" = :"+c.field)
+		names["#"+c.field] = c.field
+		values[":"+c.field] = &types.AttributeValueMemberS{Value: c.value}
+	}
+	if filter.Failed {
+		conds = append(conds, "#outcome = :outcome")
+		names["#outcome"] = "outcome"
+		values[":outcome"] = &types.AttributeValueMemberS{Value: string(audit.OutcomeError)}
+	}
+
+	var (
+		recent []audit.Activity
+		start  map[string]types.AttributeValue
+	)
+	for {
+		input := dynamodb.QueryInput{
+			ExclusiveStartKey:         start,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			KeyConditionExpression:    &keyCond,
+			ScanIndexForward:          boolPtr(false),
+			TableName:                 &l.table,
+		}
+		if len(conds) > 0 {
+			input.FilterExpression = strPtr(strings.Join(conds, " AND "))
+		}
+		ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+		out, err := l.client.Query(ctx, &input)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("unable to query activities: %w", err)
+		}
+
+		for _, item := range out.Items {
+			var a audit.Activity
+			if err := dec.Decode(&types.AttributeValueMemberM{Value: item}, &a); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal activity: %w", err)
+			}
+			recent = append(recent, a)
+			if limit > 0 && len(recent) == limit {
+				break
+			}
+		}
+		if (limit > 0 && len(recent) == limit) || len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		start = out.LastEvaluatedKey
+	}
+	for i, j := 0, len(recent)-1; i < j; i, j = i+1, j-1 {
+		recent[i], recent[j] = recent[j], recent[i]
+	}
+
+	return recent, nil
+}
@@ -0,0 +1,99 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/itsHabib/sim/internal/audit"
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// AuditStore persists the audit events of image records in a table with a
+// string partition key named imageId and a string sort key named id.
+type AuditStore struct {
+	client Client
+	table  string
+}
+
+// NewAuditStore returns an audit store on the table.
+func NewAuditStore(client Client, table string) *AuditStore {
+	return &AuditStore{client: client, table: table}
+}
+
+// Append puts the event in the table.
+func (s *AuditStore) Append(ctx context.Context, e *audit.Event) error {
+	enc := attributevalue.NewEncoder(func(o *attributevalue.EncoderOptions) {
+		o.TagKey = tagKey
+	})
+	av, err := enc.Encode(e)
+	if err != nil {
+		return fmt.Errorf("unable to marshal audit event: %w", err)
+	}
+	m, ok := av.(*types.AttributeValueMemberM)
+	if !ok {
+		return fmt.Errorf("unexpected attribute value type: %T", av)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	input := dynamodb.PutItemInput{
+		Item:      m.Value,
+		TableName: &s.table,
+	}
+	if _, err := s.client.PutItem(ctx, &input); err != nil {
+		return fmt.Errorf("unable to put audit event: %w", err)
+	}
+
+	return nil
+}
+
+// History returns the events of the image record in the order they happened.
+func (s *AuditStore) History(ctx context.Context, imageID string) ([]audit.Event, error) {
+	dec := attributevalue.NewDecoder(func(o *attributevalue.DecoderOptions) {
+		o.TagKey = tagKey
+	})
+
+	var (
+		events []audit.Event
+		start  map[string]types.AttributeValue
+	)
+	for {
+		input := dynamodb.QueryInput{
+			ExclusiveStartKey:        start,
+			ExpressionAttributeNames: map[string]string{"#imageId": "imageId"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":imageId": &types.AttributeValueMemberS{Value: imageID},
+			},
+			KeyConditionExpression: strPtr("#imageId = :imageId"),
+			TableName:              &s.table,
+		}
+		ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+		out, err := s.client.Query(ctx, &input)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("unable to query audit events: %w", err)
+		}
+
+		for _, item := range out.Items {
+			var e audit.Event
+			if err := dec.Decode(&types.AttributeValueMemberM{Value: item}, &e); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal audit event: %w", err)
+			}
+			events = append(events, e)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		start = out.LastEvaluatedKey
+	}
+	if len(events) == 0 {
+		return nil, images.ErrRecordNotFound
+	}
+
+	return events, nil
+}
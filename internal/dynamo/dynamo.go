@@ -0,0 +1,85 @@
+// Package dynamo provides the DynamoDB implementation of the image record
+// reader and writer.
+//
+// Records are stored in a table with a string partition key named "id" and a
+// global secondary index, NameIndex, with a string partition key named
+// "name".
+package dynamo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/client.go github.com/itsHabib/sim/internal/dynamo Client
+
+const (
+	// NameIndex is the global secondary index on the record name
+	NameIndex = "name-index"
+
+	// tagKey reuses the record's json tags as attribute names
+	tagKey = "json"
+)
+
+// Client provides an abstraction to aid in mocking for unit tests
+type Client interface {
+	// DeleteItem deletes a single item in a table by primary key.
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+
+	// GetItem returns a set of attributes for the item with the given primary
+	// key.
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+
+	// PutItem creates a new item, or replaces an old item with a new item.
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+
+	// Query finds items based on primary key values of a table or a
+	// secondary index.
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+
+	// Scan returns one or more items by accessing every item in a table or a
+	// secondary index.
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+func idKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+func marshalRecord(rec *images.Record) (map[string]types.AttributeValue, error) {
+	enc := attributevalue.NewEncoder(func(o *attributevalue.EncoderOptions) {
+		o.TagKey = tagKey
+	})
+	av, err := enc.Encode(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := av.(*types.AttributeValueMemberM)
+	if !ok {
+		return nil, fmt.Errorf("unexpected attribute value type: %T", av)
+	}
+
+	return m.Value, nil
+}
+
+func unmarshalRecord(item map[string]types.AttributeValue) (*images.Record, error) {
+	dec := attributevalue.NewDecoder(func(o *attributevalue.DecoderOptions) {
+		o.TagKey = tagKey
+	})
+
+	var rec images.Record
+	if err := dec.Decode(&types.AttributeValueMemberM{Value: item}, &rec); err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
@@ -0,0 +1,383 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/audit"
+	mock_dynamo "github.com/itsHabib/sim/internal/dynamo/mocks"
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_Record_Marshal(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	rec := images.Record{
+		ID:          "id",
+		CreatedAt:   &now,
+		ETag:        "etag",
+		Key:         "images/id/name",
+		Name:        "name",
+		SizeInBytes: 1024,
+		Storage:     "sim",
+		Mirrors:     []string{"mirror"},
+	}
+
+	item, err := marshalRecord(&rec)
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "id"}, item["id"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "name"}, item["name"])
+
+	got, err := unmarshalRecord(item)
+	require.NoError(t, err)
+	assert.Equal(t, &rec, got)
+}
+
+func Test_Reader_Get(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		client  func(ctrl *gomock.Controller) Client
+		wantErr error
+	}{
+		{
+			desc: "Get() should return an error when failing to get the item",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_dynamo.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					GetItem(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("random"))
+
+				return c
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc: "Get() should return ErrRecordNotFound when no item exists",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_dynamo.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					GetItem(gomock.Any(), gomock.Any()).
+					Return(new(dynamodb.GetItemOutput), nil)
+
+				return c
+			},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc: "Get() - happy path",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_dynamo.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					GetItem(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+						assert.Equal(t, "table", aws.ToString(i.TableName))
+						assert.Equal(t, idKey("id"), i.Key)
+
+						return &dynamodb.GetItemOutput{
+							Item: map[string]types.AttributeValue{
+								"id":   &types.AttributeValueMemberS{Value: "id"},
+								"name": &types.AttributeValueMemberS{Value: "name"},
+							},
+						}, nil
+					})
+
+				return c
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, err := NewReader(zap.NewNop(), tc.client(ctrl), "table")
+			require.NoError(t, err)
+
+			rec, err := r.Get(context.Background(), "id")
+			switch {
+			case tc.wantErr == images.ErrRecordNotFound:
+				assert.Equal(t, images.ErrRecordNotFound, err)
+			case tc.wantErr != nil:
+				assert.Error(t, err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, &images.Record{ID: "id", Name: "name"}, rec)
+			}
+		})
+	}
+}
+
+func Test_Reader_GetByName(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		items   []map[string]types.AttributeValue
+		wantErr error
+	}{
+		{
+			desc:    "GetByName() should return ErrRecordNotFound when no item has the name",
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc: "GetByName() - happy path",
+			items: []map[string]types.AttributeValue{{
+				"id":   &types.AttributeValueMemberS{Value: "id"},
+				"name": &types.AttributeValueMemberS{Value: "name"},
+			}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			c := mock_dynamo.NewMockClient(ctrl)
+			c.
+				EXPECT().
+				Query(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, i *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+					assert.Equal(t, NameIndex, aws.ToString(i.IndexName))
+					assert.Equal(t, &types.AttributeValueMemberS{Value: "name"}, i.ExpressionAttributeValues[":name"])
+
+					return &dynamodb.QueryOutput{Items: tc.items}, nil
+				})
+
+			r, err := NewReader(zap.NewNop(), c, "table")
+			require.NoError(t, err)
+
+			rec, err := r.GetByName(context.Background(), "name")
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, &images.Record{ID: "id", Name: "name"}, rec)
+			}
+		})
+	}
+}
+
+func Test_Reader_List(t *testing.T) {
+	created := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		desc     string
+		opts     images.ListOptions
+		wantExpr string
+		wantVals map[string]types.AttributeValue
+	}{
+		{
+			desc: "List() should scan without a filter expression when unfiltered",
+		},
+		{
+			desc: "List() should push the filter down into the scan",
+			opts: images.ListOptions{Filter: images.ListFilter{
+				NamePrefix:   "cat",
+				CreatedAfter: created,
+				MinSize:      1024,
+				Tags:         []string{"vacation"},
+			}},
+			wantExpr: "begins_with(#name, :namePrefix) AND #size >= :minSize AND #createdAt >= :createdAfter AND contains(#tags, :tag0)",
+			wantVals: map[string]types.AttributeValue{
+				":namePrefix":   &types.AttributeValueMemberS{Value: "cat"},
+				":minSize":      &types.AttributeValueMemberN{Value: "1024"},
+				":createdAfter": &types.AttributeValueMemberS{Value: "2021-10-01T12:00:00Z"},
+				":tag0":         &types.AttributeValueMemberS{Value: "vacation"},
+			},
+		},
+		{
+			desc: "List() should compare the capture time nested in the exif",
+			opts: images.ListOptions{Filter: images.ListFilter{
+				CapturedAfter:  created,
+				CapturedBefore: created.AddDate(0, 0, 1),
+			}},
+			wantExpr: "#exif.#capturedAt >= :capturedAfter AND #exif.#capturedAt < :capturedBefore",
+			wantVals: map[string]types.AttributeValue{
+				":capturedAfter":  &types.AttributeValueMemberS{Value: "2021-10-01T12:00:00Z"},
+				":capturedBefore": &types.AttributeValueMemberS{Value: "2021-10-02T12:00:00Z"},
+			},
+		},
+		{
+			desc:     "List() should match the extracted text containing the filter's",
+			opts:     images.ListOptions{Filter: images.ListFilter{Text: "Invoice 42"}},
+			wantExpr: "contains(#text, :text)",
+			wantVals: map[string]types.AttributeValue{
+				":text": &types.AttributeValueMemberS{Value: "Invoice 42"},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			c := mock_dynamo.NewMockClient(ctrl)
+			c.
+				EXPECT().
+				Scan(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, i *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+					assert.Equal(t, tc.wantExpr, aws.ToString(i.FilterExpression))
+					assert.Equal(t, tc.wantVals, i.ExpressionAttributeValues)
+
+					return &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{{
+						"id": &types.AttributeValueMemberS{Value: "id"},
+					}}}, nil
+				})
+
+			r, err := NewReader(zap.NewNop(), c, "table")
+			require.NoError(t, err)
+
+			page, err := r.List(context.Background(), tc.opts)
+			require.NoError(t, err)
+			assert.Equal(t, []images.Record{{ID: "id"}}, page.Records)
+		})
+	}
+}
+
+func Test_Reader_Count(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	c := mock_dynamo.NewMockClient(ctrl)
+	gomock.InOrder(
+		c.
+			EXPECT().
+			Scan(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, i *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+				assert.Equal(t, types.SelectCount, i.Select)
+				assert.Equal(t, "#storage = :storage", aws.ToString(i.FilterExpression))
+
+				return &dynamodb.ScanOutput{Count: 2, LastEvaluatedKey: idKey("id")}, nil
+			}),
+		c.
+			EXPECT().
+			Scan(gomock.Any(), gomock.Any()).
+			Return(&dynamodb.ScanOutput{Count: 1}, nil),
+	)
+
+	r, err := NewReader(zap.NewNop(), c, "table")
+	require.NoError(t, err)
+
+	n, err := r.Count(context.Background(), images.ListFilter{Storage: "sim"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func Test_Writer_Update(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		revision uint64
+		client   func(ctrl *gomock.Controller) Client
+		wantErr  error
+	}{
+		{
+			desc: "Update() should return ErrRecordNotFound when the record does not exist",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_dynamo.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					PutItem(gomock.Any(), gomock.Any()).
+					Return(nil, &types.ConditionalCheckFailedException{})
+
+				return c
+			},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc:     "Update() should return ErrConflict when the revision does not match",
+			revision: 1,
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_dynamo.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					PutItem(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+						assert.Equal(t, conditionExists+" AND "+conditionRevision, aws.ToString(i.ConditionExpression))
+						assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, i.ExpressionAttributeValues[":revision"])
+
+						return nil, &types.ConditionalCheckFailedException{}
+					})
+				c.
+					EXPECT().
+					GetItem(gomock.Any(), gomock.Any()).
+					Return(&dynamodb.GetItemOutput{Item: idKey("id")}, nil)
+
+				return c
+			},
+			wantErr: images.ErrConflict,
+		},
+		{
+			desc: "Update() - happy path",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_dynamo.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					PutItem(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+						assert.Equal(t, "table", aws.ToString(i.TableName))
+						assert.Equal(t, conditionExists, aws.ToString(i.ConditionExpression))
+						assert.Equal(t, &types.AttributeValueMemberS{Value: "id"}, i.Item["id"])
+
+						return new(dynamodb.PutItemOutput), nil
+					})
+
+				return c
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			w, err := NewWriter(zap.NewNop(), tc.client(ctrl), "table")
+			require.NoError(t, err)
+
+			rec := images.Record{ID: "id", Revision: tc.revision}
+			err = w.Update(context.Background(), &rec)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.NotZero(t, rec.Revision)
+			}
+		})
+	}
+}
+
+func Test_AuditStore_History_Canceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	c := mock_dynamo.NewMockClient(ctrl)
+	c.
+		EXPECT().
+		Query(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			return nil, ctx.Err()
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewAuditStore(c, "table").History(ctx, "id")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_ActivityLog_Recent_Canceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	c := mock_dynamo.NewMockClient(ctrl)
+	c.
+		EXPECT().
+		Query(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			return nil, ctx.Err()
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewActivityLog(c, "table").Recent(ctx, audit.ActivityFilter{}, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,432 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const (
+	readerLoggerName = "dynamo.reader"
+	dbTimeout        = time.Second * 3
+)
+
+// Reader provides the implementation to read image records from a dynamodb
+// table.
+type Reader struct {
+	client Client
+	logger *zap.Logger
+	table  string
+}
+
+// NewReader returns an instantiated instance of a reader which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// client: dynamodb client
+//
+// table: the dynamodb table name
+func NewReader(logger *zap.Logger, client Client, table string) (*Reader, error) {
+	r := Reader{
+		client: client,
+		logger: logger.Named(readerLoggerName),
+		table:  table,
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Debug("successfully initialized image reader")
+
+	return &r, nil
+}
+
+func (r *Reader) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "client",
+			chk: func() bool { return r.client != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return r.logger != nil },
+		},
+		{
+			dep: "db table name",
+			chk: func() bool { return r.table != "" },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize reader due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Get returns an image record given the id. Returns ErrRecordNotFound if no
+// image is found by that ID.
+func (r *Reader) Get(ctx context.Context, id string) (*images.Record, error) {
+	logger := r.logger.With(zap.String("imageId", id))
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	input := dynamodb.GetItemInput{
+		ConsistentRead: boolPtr(true),
+		Key:            idKey(id),
+		TableName:      &r.table,
+	}
+	out, err := r.client.GetItem(ctx, &input)
+	if err != nil {
+		const msg = "unable to get image by id"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	if len(out.Item) == 0 {
+		logger.Error("record not found")
+		return nil, images.ErrRecordNotFound
+	}
+
+	rec, err := unmarshalRecord(out.Item)
+	if err != nil {
+		const msg = "unable to unmarshal item into image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return rec, nil
+}
+
+// GetByName returns the image record with the given name using the
+// NameIndex. Returns ErrRecordNotFound if no image is found by that name.
+func (r *Reader) GetByName(ctx context.Context, name string) (*images.Record, error) {
+	logger := r.logger.With(zap.String("imageName", name))
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	input := dynamodb.QueryInput{
+		ExpressionAttributeNames: map[string]string{"#name": "name"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name": &types.AttributeValueMemberS{Value: name},
+		},
+		IndexName:              strPtr(NameIndex),
+		KeyConditionExpression: strPtr("#name = :name"),
+		Limit:                  int32Ptr(1),
+		TableName:              &r.table,
+	}
+	out, err := r.client.Query(ctx, &input)
+	if err != nil {
+		const msg = "unable to query image by name"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	if len(out.Items) == 0 {
+		logger.Error("record not found")
+		return nil, images.ErrRecordNotFound
+	}
+
+	rec, err := unmarshalRecord(out.Items[0])
+	if err != nil {
+		const msg = "unable to unmarshal item into image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return rec, nil
+}
+
+// GetByIDPrefix returns up to limit image records whose ID starts with the
+// prefix. The ID is the table's hash key which can not be queried by prefix
+// so this scans the table, the records are sorted in memory. Returns
+// ErrRecordNotFound if no record's ID does.
+func (r *Reader) GetByIDPrefix(ctx context.Context, prefix string, limit int) ([]images.Record, error) {
+	logger := r.logger.With(zap.String("idPrefix", prefix))
+
+	input := dynamodb.ScanInput{
+		ExpressionAttributeNames: map[string]string{"#id": "id"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: prefix},
+		},
+		FilterExpression: strPtr("begins_with(#id, :prefix)"),
+		TableName:        &r.table,
+	}
+	var list []images.Record
+	for {
+		ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+		out, err := r.client.Scan(ctx, &input)
+		cancel()
+		if err != nil {
+			const msg = "unable to scan table"
+			logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+
+		for _, item := range out.Items {
+			rec, err := unmarshalRecord(item)
+			if err != nil {
+				const msg = "unable to unmarshal item into image record"
+				logger.Error(msg, zap.Error(err))
+				return nil, fmt.Errorf(msg+": %w", err)
+			}
+			list = append(list, *rec)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+	if len(list) == 0 {
+		logger.Error("record not found")
+		return nil, images.ErrRecordNotFound
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	if len(list) > limit {
+		list = list[:limit]
+	}
+
+	return list, nil
+}
+
+// Count returns the number of image records matching the filter. This
+// performs a scan which only returns the counts of the matching items.
+func (r *Reader) Count(ctx context.Context, filter images.ListFilter) (int, error) {
+	input := dynamodb.ScanInput{
+		Select:    types.SelectCount,
+		TableName: &r.table,
+	}
+	if expr, names, values := filterExpression(&filter); expr != "" {
+		input.FilterExpression = &expr
+		input.ExpressionAttributeNames = names
+		input.ExpressionAttributeValues = values
+	}
+
+	var n int
+	for {
+		ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+		out, err := r.client.Scan(ctx, &input)
+		cancel()
+		if err != nil {
+			const msg = "unable to scan table"
+			r.logger.Error(msg, zap.Error(err))
+			return 0, fmt.Errorf(msg+": %w", err)
+		}
+
+		n += int(out.Count)
+		if len(out.LastEvaluatedKey) == 0 {
+			return n, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// List lists a page of image records in the table. This performs a scan
+// operation which can be slow with many items in the table, the cursor is the
+// ID of the last evaluated item. Scans are unordered so sorted lists scan
+// every matching item and are sorted in memory. Returns an ErrRecordNotFound
+// if no records are found.
+func (r *Reader) List(ctx context.Context, opts images.ListOptions) (*images.Page, error) {
+	if opts.Sort != images.SortID || opts.Desc {
+		return r.listSorted(ctx, opts)
+	}
+
+	input := dynamodb.ScanInput{
+		TableName: &r.table,
+	}
+	if expr, names, values := filterExpression(&opts.Filter); expr != "" {
+		input.FilterExpression = &expr
+		input.ExpressionAttributeNames = names
+		input.ExpressionAttributeValues = values
+	}
+	if opts.Cursor != "" {
+		input.ExclusiveStartKey = idKey(opts.Cursor)
+	}
+
+	var page images.Page
+	for {
+		if opts.Limit > 0 {
+			input.Limit = int32Ptr(int32(opts.Limit - len(page.Records)))
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+		out, err := r.client.Scan(ctx, &input)
+		cancel()
+		if err != nil {
+			const msg = "unable to scan table"
+			r.logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+
+		for i := range out.Items {
+			rec, err := unmarshalRecord(out.Items[i])
+			if err != nil {
+				const msg = "unable to unmarshal item into image record"
+				r.logger.Error(msg, zap.Error(err))
+				return nil, fmt.Errorf(msg+": %w", err)
+			}
+			page.Records = append(page.Records, *rec)
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		if opts.Limit > 0 && len(page.Records) >= opts.Limit {
+			id, ok := out.LastEvaluatedKey["id"].(*types.AttributeValueMemberS)
+			if !ok {
+				const msg = "unable to read last evaluated key"
+				r.logger.Error(msg)
+				return nil, errors.New(msg)
+			}
+			page.NextCursor = id.Value
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	if len(page.Records) == 0 {
+		return nil, images.ErrRecordNotFound
+	}
+
+	return &page, nil
+}
+
+func (r *Reader) listSorted(ctx context.Context, opts images.ListOptions) (*images.Page, error) {
+	all, err := r.List(ctx, images.ListOptions{Filter: opts.Filter})
+	if err != nil {
+		return nil, err
+	}
+
+	return images.PageRecords(all.Records, opts)
+}
+
+// filterExpression translates the filter into a scan filter expression.
+// CreatedAt and the EXIF CapturedAt are stored as RFC 3339 strings so the
+// times are compared to the second.
+func filterExpression(f *images.ListFilter) (string, map[string]string, map[string]types.AttributeValue) {
+	var (
+		conds  []string
+		names  = make(map[string]string)
+		values = make(map[string]types.AttributeValue)
+	)
+	if f.NamePrefix != "" {
+		conds = append(conds, "begins_with(#name, :namePrefix)")
+		names["#name"] = "name"
+		values[":namePrefix"] = &types.AttributeValueMemberS{Value: f.NamePrefix}
+	}
+	if f.Storage != "" {
+		conds = append(conds, "#storage = :storage")
+		names["#storage"] = "storage"
+		values[":storage"] = &types.AttributeValueMemberS{Value: f.Storage}
+	}
+	if f.MinSize > 0 {
+		conds = append(conds, "#size >= :minSize")
+		names["#size"] = "SizeInBytes"
+		values[":minSize"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(f.MinSize, 10)}
+	}
+	if f.MaxSize > 0 {
+		conds = append(conds, "#size <= :maxSize")
+		names["#size"] = "SizeInBytes"
+		values[":maxSize"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(f.MaxSize, 10)}
+	}
+	if !f.CreatedAfter.IsZero() {
+		conds = append(conds, "#createdAt >= :createdAfter")
+		names["#createdAt"] = "createdAt"
+		values[":createdAfter"] = &types.AttributeValueMemberS{Value: f.CreatedAfter.UTC().Format(time.RFC3339)}
+	}
+	if !f.CreatedBefore.IsZero() {
+		conds = append(conds, "#createdAt < :createdBefore")
+		names["#createdAt"] = "createdAt"
+		values[":createdBefore"] = &types.AttributeValueMemberS{Value: f.CreatedBefore.UTC().Format(time.RFC3339)}
+	}
+	if !f.CapturedAfter.IsZero() {
+		conds = append(conds, "#exif.#capturedAt >= :capturedAfter")
+		names["#exif"] = "exif"
+		names["#capturedAt"] = "capturedAt"
+		values[":capturedAfter"] = &types.AttributeValueMemberS{Value: f.CapturedAfter.UTC().Format(time.RFC3339)}
+	}
+	if !f.CapturedBefore.IsZero() {
+		conds = append(conds, "#exif.#capturedAt < :capturedBefore")
+		names["#exif"] = "exif"
+		names["#capturedAt"] = "capturedAt"
+		values[":capturedBefore"] = &types.AttributeValueMemberS{Value: f.CapturedBefore.UTC().Format(time.RFC3339)}
+	}
+	if f.Text != "" {
+		// DynamoDB has no function to compare strings ignoring their case
+		conds = append(conds, "contains(#text, :text)")
+		names["#text"] = "text"
+		values[":text"] = &types.AttributeValueMemberS{Value: f.Text}
+	}
+	if f.ETag != "" {
+		// S3 ETags are stored quoted
+		etag := images.TrimETag(f.ETag)
+		conds = append(conds, "#etag IN (:etag, :etagQuoted)")
+		names["#etag"] = "etag"
+		values[":etag"] = &types.AttributeValueMemberS{Value: etag}
+		values[":etagQuoted"] = &types.AttributeValueMemberS{Value: `"` + etag + `"`}
+	}
+	if f.SHA256 != "" {
+		conds = append(conds, "#sha256 = :sha256")
+		names["#sha256"] = "sha256"
+		values[":sha256"] = &types.AttributeValueMemberS{Value: strings.ToLower(f.SHA256)}
+	}
+	if f.Deleting {
+		conds = append(conds, "attribute_exists(#deletingAt)")
+		names["#deletingAt"] = "deletingAt"
+	}
+	for i, tag := range f.Tags {
+		name := ":tag" + strconv.Itoa(i)
+		conds = append(conds, "contains(#tags, "+name+")")
+		names["#tags"] = "tags"
+		values[name] = &types.AttributeValueMemberS{Value: tag}
+	}
+	if !f.UpdatedSince.IsZero() {
+		conds = append(conds, "(#updatedAt >= :updatedSince OR (attribute_not_exists(#updatedAt) AND #createdAt >= :updatedSince))")
+		names["#updatedAt"] = "updatedAt"
+		names["#createdAt"] = "createdAt"
+		values[":updatedSince"] = &types.AttributeValueMemberS{Value: f.UpdatedSince.UTC().Format(time.RFC3339)}
+	}
+
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	if len(values) == 0 {
+		// an empty map of values is rejected
+		values = nil
+	}
+
+	return strings.Join(conds, " AND "), names, values
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,289 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const (
+	writerLoggerName = "dynamo.writer"
+
+	conditionExists    = "attribute_exists(id)"
+	conditionNotExists = "attribute_not_exists(id)"
+	conditionRevision  = "#revision = :revision"
+)
+
+// Writer provides the implementation to write image records to a dynamodb
+// table.
+type Writer struct {
+	client Client
+	logger *zap.Logger
+	table  string
+}
+
+// NewWriter returns an instantiated instance of a writer which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// client: dynamodb client
+//
+// table: the dynamodb table name
+func NewWriter(logger *zap.Logger, client Client, table string) (*Writer, error) {
+	w := Writer{
+		client: client,
+		logger: logger.Named(writerLoggerName),
+		table:  table,
+	}
+
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+
+	w.logger.Debug("successfully initialized image writer")
+
+	return &w, nil
+}
+
+func (w *Writer) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "client",
+			chk: func() bool { return w.client != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return w.logger != nil },
+		},
+		{
+			dep: "db table name",
+			chk: func() bool { return w.table != "" },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize writer due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Create adds the given record to the dynamodb table.
+func (w *Writer) Create(ctx context.Context, record *images.Record) error {
+	logger := w.logger.With(
+		zap.String("recordId", record.ID),
+		zap.String("key", record.Key),
+		zap.String("storage", record.Storage),
+	)
+
+	now := time.Now().UTC()
+	doc := *record
+	doc.Revision = newRevision()
+	doc.UpdatedAt = &now
+	if err := w.put(ctx, &doc, conditionNotExists, nil); err != nil {
+		const msg = "unable to put image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	record.Revision = doc.Revision
+	record.UpdatedAt = doc.UpdatedAt
+
+	logger.Info("successfully inserted item in db")
+
+	return nil
+}
+
+// CreateBatch adds the given records to the table. BatchWriteItem does not
+// support conditions, so the records are put one at a time to avoid
+// overwriting existing records.
+func (w *Writer) CreateBatch(ctx context.Context, records []images.Record) error {
+	failed := make(images.BatchError)
+	for i := range records {
+		if err := w.Create(ctx, &records[i]); err != nil {
+			failed[records[i].ID] = err
+		}
+	}
+
+	if len(failed) > 0 {
+		return failed
+	}
+
+	return nil
+}
+
+// Delete removes the item with id from the table.
+func (w *Writer) Delete(ctx context.Context, id string) error {
+	logger := w.logger.With(zap.String("imageId", id))
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	input := dynamodb.DeleteItemInput{
+		ConditionExpression: strPtr(conditionExists),
+		Key:                 idKey(id),
+		TableName:           &w.table,
+	}
+	if _, err := w.client.DeleteItem(ctx, &input); err != nil {
+		if isConditionFailed(err) {
+			logger.Error("record not found")
+			return images.ErrRecordNotFound
+		}
+		const msg = "unable to delete image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	logger.Info("successfully deleted item from db")
+
+	return nil
+}
+
+// DeleteBatch removes the items with the ids from the table. BatchWriteItem
+// does not report missing items, so the items are deleted one at a time.
+func (w *Writer) DeleteBatch(ctx context.Context, ids []string) error {
+	failed := make(images.BatchError)
+	for _, id := range ids {
+		if err := w.Delete(ctx, id); err != nil {
+			failed[id] = err
+		}
+	}
+
+	if len(failed) > 0 {
+		return failed
+	}
+
+	return nil
+}
+
+// Update replaces the existing record in the table. Records with a revision
+// are only replaced if the stored revision matches.
+func (w *Writer) Update(ctx context.Context, record *images.Record) error {
+	logger := w.logger.With(
+		zap.String("recordId", record.ID),
+		zap.String("key", record.Key),
+		zap.String("storage", record.Storage),
+	)
+
+	condition := conditionExists
+	var values map[string]types.AttributeValue
+	if record.Revision != 0 {
+		condition += " AND " + conditionRevision
+		values = map[string]types.AttributeValue{
+			":revision": &types.AttributeValueMemberN{Value: strconv.FormatUint(record.Revision, 10)},
+		}
+	}
+
+	now := time.Now().UTC()
+	doc := *record
+	doc.Revision = newRevision()
+	doc.UpdatedAt = &now
+	if err := w.put(ctx, &doc, condition, values); err != nil {
+		if isConditionFailed(err) {
+			return w.conditionFailed(ctx, record, logger)
+		}
+		const msg = "unable to replace image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	record.Revision = doc.Revision
+	record.UpdatedAt = doc.UpdatedAt
+
+	logger.Info("successfully replaced item in db")
+
+	return nil
+}
+
+// conditionFailed determines whether a failed update condition was due to a
+// missing record or a revision mismatch.
+func (w *Writer) conditionFailed(ctx context.Context, record *images.Record, logger *zap.Logger) error {
+	if record.Revision == 0 {
+		logger.Error("record not found")
+		return images.ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	input := dynamodb.GetItemInput{
+		ConsistentRead:       boolPtr(true),
+		Key:                  idKey(record.ID),
+		ProjectionExpression: strPtr("id"),
+		TableName:            &w.table,
+	}
+	out, err := w.client.GetItem(ctx, &input)
+	switch {
+	case err != nil:
+		const msg = "unable to get image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	case len(out.Item) == 0:
+		logger.Error("record not found")
+		return images.ErrRecordNotFound
+	}
+
+	logger.Error("record revision does not match", zap.Uint64("revision", record.Revision))
+
+	return images.ErrConflict
+}
+
+func (w *Writer) put(ctx context.Context, record *images.Record, condition string, values map[string]types.AttributeValue) error {
+	item, err := marshalRecord(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal image record: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	input := dynamodb.PutItemInput{
+		ConditionExpression: &condition,
+		Item:                item,
+		TableName:           &w.table,
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeNames = map[string]string{"#revision": "revision"}
+		input.ExpressionAttributeValues = values
+	}
+	_, err = w.client.PutItem(ctx, &input)
+
+	return err
+}
+
+func isConditionFailed(err error) bool {
+	var condErr *types.ConditionalCheckFailedException
+	return errors.As(err, &condErr)
+}
+
+// newRevision returns the revision of a write. Puts can not increment the
+// stored revision so the write time is used, it only needs to change on every
+// write.
+func newRevision() uint64 {
+	return uint64(time.Now().UnixNano())
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,458 @@
+// Package filesystem provides a local filesystem implementation of the
+// images.ObjectStore, useful for running sim offline.
+package filesystem
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const (
+	loggerName = "filesystem.store"
+	dirPerm    = 0o755
+)
+
+// Store provides the local filesystem implementation of the
+// images.ObjectStore. Objects are stored as files under the root directory
+// using their key as the relative path.
+type Store struct {
+	logger *zap.Logger
+	root   string
+}
+
+// NewStore returns an instantiated instance of a store which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// root: the directory which holds the objects, created if missing
+func NewStore(logger *zap.Logger, root string) (*Store, error) {
+	s := Store{
+		logger: logger.Named(loggerName),
+		root:   root,
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		const msg = "unable to resolve root directory"
+		s.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	s.root = root
+
+	if err := os.MkdirAll(root, dirPerm); err != nil {
+		const msg = "unable to create root directory"
+		s.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	s.logger.Debug("successfully initialized filesystem store")
+
+	return &s, nil
+}
+
+func (s *Store) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "logger",
+			chk: func() bool { return s.logger != nil },
+		},
+		{
+			dep: "root",
+			chk: func() bool { return s.root != "" },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize store due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Delete removes the object's file. A missing object is not treated as an
+// error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Info("object not found")
+			return nil
+		}
+		const msg = "unable to delete object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// Get copies the object's file into the stream, returning the number of bytes
+// copied.
+func (s *Store) Get(ctx context.Context, key string, stream io.WriterAt) (int64, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	f, err := s.open(key)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(images.NewOffsetWriter(stream), f)
+	if err != nil {
+		const msg = "unable to download object"
+		logger.Error(msg, zap.Error(err))
+		return n, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// GetRange copies the byte range of the object's file into w, returning
+// the number of bytes copied.
+func (s *Store) GetRange(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error) {
+	logger := s.logger.With(zap.String("key", key), zap.Int64("offset", offset), zap.Int64("length", length))
+
+	f, err := s.open(key)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		const msg = "unable to stat object"
+		logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+	n, err := images.CopyRange(w, f, fi.Size(), offset, length)
+	switch err {
+	case nil:
+	case images.ErrInvalidRange:
+		logger.Error("range is not satisfiable", zap.Int64("size", fi.Size()))
+		return 0, err
+	default:
+		const msg = "unable to download object range"
+		logger.Error(msg, zap.Error(err))
+		return n, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// Head retrieves the metadata of the object. The ETag is the hex encoded MD5
+// digest of the file, matching S3's ETag for non-multipart uploads.
+func (s *Store) Head(ctx context.Context, key string) (*images.ObjectInfo, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	f, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		const msg = "unable to hash object"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return &images.ObjectInfo{
+		ETag:        hex.EncodeToString(h.Sum(nil)),
+		SizeInBytes: n,
+	}, nil
+}
+
+// List returns the objects whose keys start with the prefix. Files are not
+// read so the ETags are left empty, temporary files of uploads in progress
+// are skipped.
+func (s *Store) List(ctx context.Context, prefix string) ([]images.ObjectInfo, error) {
+	var objects []images.ObjectInfo
+	err := filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			objects = append(objects, images.ObjectInfo{
+				Key:          key,
+				SizeInBytes:  info.Size(),
+				LastModified: info.ModTime(),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		const msg = "unable to list objects"
+		s.logger.Error(msg, zap.String("prefix", prefix), zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return objects, nil
+}
+
+// Presign returns a file URL for the object. Local files have no notion of
+// expiry so the duration is ignored.
+func (s *Store) Presign(ctx context.Context, key string, _ time.Duration) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+// URL returns the file URL of the object, see Presign.
+func (s *Store) URL(ctx context.Context, key string) (string, error) {
+	return s.Presign(ctx, key, 0)
+}
+
+// PresignPut is not supported by local files, there is no URL a client can
+// upload to, and always returns ErrUnsupported.
+func (s *Store) PresignPut(context.Context, string, time.Duration, images.PutOptions) (*images.PresignedRequest, error) {
+	return nil, images.ErrUnsupported
+}
+
+// Put writes the body to the object's file. The body is written to a
+// temporary file first and renamed into place so that readers never observe
+// a partially written object. Files have no attributes, the options are
+// ignored.
+func (s *Store) Put(ctx context.Context, key string, body io.Reader, _ images.PutOptions) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		const msg = "unable to create object directory"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".upload-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		const msg = "unable to write object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		const msg = "unable to close temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		const msg = "unable to move object into place"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// PutParts writes the body to the object's file in parts. The parts are
+// written to a staging file next to the object, named after the upload, which
+// outlives an interrupted upload and is renamed into place once every part
+// was written. Files have no attributes, the options are ignored.
+func (s *Store) PutParts(ctx context.Context, key string, body io.ReaderAt, _ images.PutOptions, session *images.UploadSession, checkpoint func() error) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		const msg = "unable to create object directory"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	flag := os.O_WRONLY
+	if session.UploadID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			const msg = "unable to generate upload id"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		session.Start(hex.EncodeToString(id))
+		flag |= os.O_CREATE | os.O_EXCL
+	}
+	staging := filepath.Join(dir, ".upload-"+session.UploadID)
+	logger = logger.With(zap.String("uploadId", session.UploadID))
+
+	f, err := os.OpenFile(staging, flag, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Error("staging file not found", zap.Error(err))
+			return images.ErrUploadExpired
+		}
+		const msg = "unable to open staging file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	defer f.Close()
+	if flag&os.O_CREATE != 0 {
+		if err := checkpoint(); err != nil {
+			const msg = "unable to checkpoint upload"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	}
+
+	for n := int32(1); n <= session.PartCount(); n++ {
+		if session.Uploaded(n) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		section := session.Section(body, n)
+		off := int64(n-1) * session.PartSize
+		if _, err := f.Seek(off, io.SeekStart); err != nil {
+			const msg = "unable to seek staging file"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		h := md5.New()
+		if _, err := io.Copy(io.MultiWriter(f, h), section); err != nil {
+			const msg = "unable to write part"
+			logger.Error(msg, zap.Int32("part", n), zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		// the part is only recorded once it is durable
+		if err := f.Sync(); err != nil {
+			const msg = "unable to sync staging file"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		session.Parts = append(session.Parts, images.Part{Number: n, ETag: hex.EncodeToString(h.Sum(nil))})
+		if err := checkpoint(); err != nil {
+			const msg = "unable to checkpoint upload"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	}
+
+	if err := f.Truncate(session.Size); err != nil {
+		const msg = "unable to truncate staging file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if err := f.Close(); err != nil {
+		const msg = "unable to close staging file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if err := os.Rename(staging, path); err != nil {
+		const msg = "unable to move object into place"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// SetTags is not supported by the local filesystem and always returns
+// ErrUnsupported.
+func (s *Store) SetTags(context.Context, string, []string) error {
+	return images.ErrUnsupported
+}
+
+// Restore is not supported by the local filesystem, which does not archive
+// files, and always returns ErrUnsupported.
+func (s *Store) Restore(context.Context, string, images.RestoreTier, int) error {
+	return images.ErrUnsupported
+}
+
+func (s *Store) open(key string) (*os.File, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.logger.Error("object not found", zap.String("key", key))
+			return nil, images.ErrObjectNotFound
+		}
+		const msg = "unable to open object"
+		s.logger.Error(msg, zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return f, nil
+}
+
+// path resolves the key to a file path, rejecting keys which would escape the
+// root directory.
+func (s *Store) path(key string) (string, error) {
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, s.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key: %q", key)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,182 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_Store(t *testing.T) {
+	key := "images/id/test.png"
+	body := []byte("Hello, World!")
+	store, err := NewStore(zap.NewNop(), t.TempDir())
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		desc string
+		do   func(t *testing.T)
+	}{
+		{
+			desc: "Get() should return ErrObjectNotFound when the object does not exist",
+			do: func(t *testing.T) {
+				_, err := store.Get(context.Background(), key, manager.NewWriteAtBuffer(nil))
+				assert.Equal(t, images.ErrObjectNotFound, err)
+			},
+		},
+		{
+			desc: "Put() should reject keys outside of the root directory",
+			do: func(t *testing.T) {
+				assert.Error(t, store.Put(context.Background(), "../escape", strings.NewReader("x"), images.PutOptions{}))
+			},
+		},
+		{
+			desc: "Put() should write the object",
+			do: func(t *testing.T) {
+				require.NoError(t, store.Put(context.Background(), key, bytes.NewReader(body), images.PutOptions{}))
+			},
+		},
+		{
+			desc: "Head() should return the size and md5 etag of the object",
+			do: func(t *testing.T) {
+				info, err := store.Head(context.Background(), key)
+				require.NoError(t, err)
+				assert.Equal(t, int64(len(body)), info.SizeInBytes)
+				assert.Equal(t, "65a8e27d8879283831b664bd8b7f0ad4", info.ETag)
+			},
+		},
+		{
+			desc: "Get() should copy the object into the stream",
+			do: func(t *testing.T) {
+				buffer := manager.NewWriteAtBuffer(nil)
+				n, err := store.Get(context.Background(), key, buffer)
+				require.NoError(t, err)
+				assert.Equal(t, int64(len(body)), n)
+				assert.Equal(t, body, buffer.Bytes())
+			},
+		},
+		{
+			desc: "GetRange() should copy the byte range of the object",
+			do: func(t *testing.T) {
+				var buf bytes.Buffer
+				n, err := store.GetRange(context.Background(), key, 7, 5, &buf)
+				require.NoError(t, err)
+				assert.Equal(t, int64(5), n)
+				assert.Equal(t, "World", buf.String())
+
+				buf.Reset()
+				n, err = store.GetRange(context.Background(), key, 7, 0, &buf)
+				require.NoError(t, err)
+				assert.Equal(t, int64(6), n)
+				assert.Equal(t, "World!", buf.String())
+			},
+		},
+		{
+			desc: "GetRange() should return ErrInvalidRange when the offset is past the object",
+			do: func(t *testing.T) {
+				_, err := store.GetRange(context.Background(), key, int64(len(body)), 0, &bytes.Buffer{})
+				assert.Equal(t, images.ErrInvalidRange, err)
+			},
+		},
+		{
+			desc: "Presign() should return a file url",
+			do: func(t *testing.T) {
+				url, err := store.Presign(context.Background(), key, 0)
+				require.NoError(t, err)
+				assert.True(t, strings.HasPrefix(url, "file://"))
+				assert.True(t, strings.HasSuffix(url, key))
+			},
+		},
+		{
+			desc: "List() should return the objects under the prefix",
+			do: func(t *testing.T) {
+				require.NoError(t, store.Put(context.Background(), "other/id/test.png", strings.NewReader("x"), images.PutOptions{}))
+
+				objects, err := store.List(context.Background(), "images/")
+				require.NoError(t, err)
+				require.Len(t, objects, 1)
+				assert.Equal(t, key, objects[0].Key)
+				assert.Equal(t, int64(len(body)), objects[0].SizeInBytes)
+				assert.False(t, objects[0].LastModified.IsZero())
+			},
+		},
+		{
+			desc: "Delete() should remove the object and ignore missing objects",
+			do: func(t *testing.T) {
+				require.NoError(t, store.Delete(context.Background(), key))
+				require.NoError(t, store.Delete(context.Background(), key))
+
+				_, err := store.Head(context.Background(), key)
+				assert.Equal(t, images.ErrObjectNotFound, err)
+			},
+		},
+	} {
+		if !t.Run(tc.desc, tc.do) {
+			t.Fatalf("test ('%s') failed", tc.desc)
+		}
+	}
+}
+
+func Test_Store_PutParts(t *testing.T) {
+	key := "images/id/big.png"
+	body := bytes.Repeat([]byte("0123456789abcdef"), int(2*images.MinPartSize/16)+1)
+	store, err := NewStore(zap.NewNop(), t.TempDir())
+	require.NoError(t, err)
+
+	session := images.UploadSession{Size: int64(len(body))}
+	for _, tc := range []struct {
+		desc string
+		do   func(t *testing.T)
+	}{
+		{
+			desc: "PutParts() should keep the parts written before an interruption",
+			do: func(t *testing.T) {
+				errInterrupted := errors.New("interrupted")
+				checkpoint := func() error {
+					if len(session.Parts) == 1 {
+						return errInterrupted
+					}
+					return nil
+				}
+				err := store.PutParts(context.Background(), key, bytes.NewReader(body), images.PutOptions{}, &session, checkpoint)
+				assert.True(t, errors.Is(err, errInterrupted))
+				assert.Equal(t, int32(3), session.PartCount())
+				assert.Len(t, session.Parts, 1)
+
+				_, err = store.Head(context.Background(), key)
+				assert.Equal(t, images.ErrObjectNotFound, err)
+			},
+		},
+		{
+			desc: "PutParts() should resume the upload and move the object into place",
+			do: func(t *testing.T) {
+				require.NoError(t, store.PutParts(context.Background(), key, bytes.NewReader(body), images.PutOptions{}, &session, func() error { return nil }))
+				assert.Len(t, session.Parts, 3)
+
+				buffer := manager.NewWriteAtBuffer(nil)
+				_, err := store.Get(context.Background(), key, buffer)
+				require.NoError(t, err)
+				assert.Equal(t, body, buffer.Bytes())
+			},
+		},
+		{
+			desc: "PutParts() should return ErrUploadExpired when the upload no longer exists",
+			do: func(t *testing.T) {
+				err := store.PutParts(context.Background(), key, bytes.NewReader(body), images.PutOptions{}, &session, func() error { return nil })
+				assert.Equal(t, images.ErrUploadExpired, err)
+			},
+		},
+	} {
+		if !t.Run(tc.desc, tc.do) {
+			t.Fatalf("test ('%s') failed", tc.desc)
+		}
+	}
+}
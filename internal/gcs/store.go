@@ -0,0 +1,349 @@
+// Package gcs provides the Google Cloud Storage implementation of the
+// images.ObjectStore.
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const (
+	loggerName = "gcs.store"
+	timeout    = time.Minute * 5
+)
+
+// Store provides the GCS implementation of the images.ObjectStore.
+type Store struct {
+	bucket string
+	client *storage.Client
+	logger *zap.Logger
+}
+
+// NewStore returns an instantiated instance of a store which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// client: the GCS client, see storage.NewClient
+//
+// bucket: the GCS bucket that holds the objects
+func NewStore(logger *zap.Logger, client *storage.Client, bucket string) (*Store, error) {
+	s := Store{
+		bucket: bucket,
+		client: client,
+		logger: logger.Named(loggerName),
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug("successfully initialized gcs store")
+
+	return &s, nil
+}
+
+func (s *Store) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "bucket",
+			chk: func() bool { return s.bucket != "" },
+		},
+		{
+			dep: "client",
+			chk: func() bool { return s.client != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return s.logger != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize store due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Delete removes the object from the bucket. A missing object is not treated
+// as an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := s.client.Bucket(s.bucket).Object(key).Delete(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			logger.Info("object not found")
+			return nil
+		}
+		const msg = "unable to delete object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// Get downloads the object into the stream, returning the number of bytes
+// downloaded.
+func (s *Store) Get(ctx context.Context, key string, stream io.WriterAt) (int64, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r, err := s.client.Bucket(s.bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			logger.Error("object not found", zap.Error(err))
+			return 0, images.ErrObjectNotFound
+		}
+		const msg = "unable to open object reader"
+		logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+	defer r.Close()
+
+	n, err := io.Copy(images.NewOffsetWriter(stream), r)
+	if err != nil {
+		const msg = "unable to download object"
+		logger.Error(msg, zap.Error(err))
+		return n, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// GetRange downloads the byte range of the object into w, returning the
+// number of bytes downloaded.
+func (s *Store) GetRange(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error) {
+	logger := s.logger.With(zap.String("key", key), zap.Int64("offset", offset), zap.Int64("length", length))
+
+	if offset < 0 || length < 0 {
+		logger.Error("range is not satisfiable")
+		return 0, images.ErrInvalidRange
+	}
+	// a negative length reads the rest of the object
+	if length == 0 {
+		length = -1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r, err := s.client.Bucket(s.bucket).Object(key).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		var apiErr *googleapi.Error
+		switch {
+		case errors.Is(err, storage.ErrObjectNotExist):
+			logger.Error("object not found", zap.Error(err))
+			return 0, images.ErrObjectNotFound
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusRequestedRangeNotSatisfiable:
+			logger.Error("range is not satisfiable", zap.Error(err))
+			return 0, images.ErrInvalidRange
+		}
+		const msg = "unable to open object range reader"
+		logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+	defer r.Close()
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		const msg = "unable to download object range"
+		logger.Error(msg, zap.Error(err))
+		return n, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// Head retrieves the metadata of the object.
+func (s *Store) Head(ctx context.Context, key string) (*images.ObjectInfo, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	attrs, err := s.client.Bucket(s.bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			logger.Error("object not found", zap.Error(err))
+			return nil, images.ErrObjectNotFound
+		}
+		const msg = "unable to get object attributes"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return &images.ObjectInfo{
+		ETag:         attrs.Etag,
+		SizeInBytes:  attrs.Size,
+		StorageClass: attrs.StorageClass,
+	}, nil
+}
+
+// List returns the objects in the bucket whose keys start with the prefix.
+func (s *Store) List(ctx context.Context, prefix string) ([]images.ObjectInfo, error) {
+	logger := s.logger.With(zap.String("prefix", prefix))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var objects []images.ObjectInfo
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			const msg = "unable to list objects"
+			logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+		objects = append(objects, images.ObjectInfo{
+			Key:          attrs.Name,
+			ETag:         attrs.Etag,
+			SizeInBytes:  attrs.Size,
+			LastModified: attrs.Updated,
+		})
+	}
+
+	return objects, nil
+}
+
+// Presign creates a signed GET URL for the object which is valid for the
+// given duration. Signing requires service account credentials.
+func (s *Store) Presign(ctx context.Context, key string, expires time.Duration) (string, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	opts := storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(expires),
+	}
+	signed, err := s.client.Bucket(s.bucket).SignedURL(key, &opts)
+	if err != nil {
+		const msg = "unable to sign url"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+
+	return signed, nil
+}
+
+// URL returns the public URL of the object on storage.googleapis.com.
+func (s *Store) URL(ctx context.Context, key string) (string, error) {
+	u := url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + s.bucket + "/" + key}
+
+	return u.String(), nil
+}
+
+// PresignPut creates a signed PUT URL uploading the object with the content
+// type of the options, the other options are not signed and are ignored.
+// Signing requires service account credentials.
+func (s *Store) PresignPut(ctx context.Context, key string, expires time.Duration, opts images.PutOptions) (*images.PresignedRequest, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	signing := storage.SignedURLOptions{
+		Method:      "PUT",
+		Expires:     time.Now().Add(expires),
+		ContentType: opts.ContentType,
+	}
+	signed, err := s.client.Bucket(s.bucket).SignedURL(key, &signing)
+	if err != nil {
+		const msg = "unable to sign url"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	req := images.PresignedRequest{Method: "PUT", URL: signed}
+	if opts.ContentType != "" {
+		req.Headers = map[string]string{"Content-Type": opts.ContentType}
+	}
+
+	return &req, nil
+}
+
+// Put uploads the body to the bucket under the key with the content type of
+// the options.
+func (s *Store) Put(ctx context.Context, key string, body io.Reader, opts images.PutOptions) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	w := s.client.Bucket(s.bucket).Object(key).NewWriter(ctx)
+	w.ContentType = opts.ContentType
+	w.StorageClass = opts.StorageClass
+	w.CacheControl = opts.CacheControl
+	w.ContentDisposition = opts.ContentDisposition
+	w.Metadata = opts.Metadata
+	if opts.Public {
+		w.PredefinedACL = "publicRead"
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		// cancelling the context aborts the upload so that the partial
+		// object is never committed.
+		cancel()
+		w.Close()
+		const msg = "unable to upload object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		const msg = "unable to finalize object upload"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// PutParts is not supported by the GCS store and always returns
+// ErrUnsupported.
+func (s *Store) PutParts(context.Context, string, io.ReaderAt, images.PutOptions, *images.UploadSession, func() error) error {
+	return images.ErrUnsupported
+}
+
+// SetTags is not supported by GCS, which has no object tags, and always
+// returns ErrUnsupported.
+func (s *Store) SetTags(context.Context, string, []string) error {
+	return images.ErrUnsupported
+}
+
+// Restore is not supported by GCS, whose archive classes can be read without
+// restoring them, and always returns ErrUnsupported.
+func (s *Store) Restore(context.Context, string, images.RestoreTier, int) error {
+	return images.ErrUnsupported
+}
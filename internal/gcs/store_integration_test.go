@@ -0,0 +1,152 @@
+//go:build integration
+// +build integration
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+var (
+	emulator string
+	bucket   string
+)
+
+func TestMain(m *testing.M) {
+	var missingDeps []string
+	for _, tc := range []struct {
+		env string
+		set func() bool
+	}{
+		{
+			env: "GCS_ENDPOINT",
+			set: func() bool {
+				emulator = os.Getenv("GCS_ENDPOINT")
+				return emulator != ""
+			},
+		},
+		{
+			env: "GCS_BUCKET",
+			set: func() bool {
+				bucket = os.Getenv("GCS_BUCKET")
+				return bucket != ""
+			},
+		},
+	} {
+		if !tc.set() {
+			missingDeps = append(missingDeps, tc.env)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		fmt.Printf("missing (%d) dependencies: %s\n", len(missingDeps), strings.Join(missingDeps, ", "))
+		os.Exit(1)
+	}
+
+	if err := createBucket(); err != nil {
+		fmt.Printf("unable to create bucket: %s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
+}
+
+func Test_Store(t *testing.T) {
+	key := "images/integration/test"
+	body := []byte("Hello, World!")
+	for _, tc := range []struct {
+		desc string
+		do   func(store *Store, t *testing.T)
+	}{
+		{
+			desc: "Put() should upload the object",
+			do: func(store *Store, t *testing.T) {
+				require.NoError(t, store.Put(context.Background(), key, bytes.NewReader(body), images.PutOptions{ContentType: "image/png"}))
+			},
+		},
+		{
+			desc: "Head() should return the object's metadata",
+			do: func(store *Store, t *testing.T) {
+				info, err := store.Head(context.Background(), key)
+				require.NoError(t, err)
+				assert.Equal(t, int64(len(body)), info.SizeInBytes)
+				assert.NotEmpty(t, info.ETag)
+			},
+		},
+		{
+			desc: "Get() should download the object into the stream",
+			do: func(store *Store, t *testing.T) {
+				buffer := manager.NewWriteAtBuffer([]byte{})
+				n, err := store.Get(context.Background(), key, buffer)
+				require.NoError(t, err)
+				assert.Equal(t, int64(len(body)), n)
+				assert.Equal(t, body, buffer.Bytes())
+			},
+		},
+		{
+			desc: "Delete() should remove the object",
+			do: func(store *Store, t *testing.T) {
+				require.NoError(t, store.Delete(context.Background(), key))
+
+				_, err := store.Head(context.Background(), key)
+				assert.Equal(t, images.ErrObjectNotFound, err)
+			},
+		},
+	} {
+		if !t.Run(tc.desc, func(t *testing.T) {
+			tc.do(getStore(t), t)
+		}) {
+			t.Fatalf("test ('%s') failed", tc.desc)
+		}
+	}
+}
+
+func getStore(t *testing.T) *Store {
+	client, err := getClient()
+	require.NoError(t, err)
+
+	store, err := NewStore(zap.NewNop(), client, bucket)
+	require.NoError(t, err)
+
+	return store
+}
+
+func getClient() (*storage.Client, error) {
+	return storage.NewClient(
+		context.Background(),
+		option.WithEndpoint(emulator),
+		option.WithoutAuthentication(),
+	)
+}
+
+func createBucket() error {
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Bucket(bucket).Create(context.Background(), "sim", nil)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,29 @@
+package images
+
+// ConflictStrategy represents how an upload is handled when an image already
+// has its name.
+type ConflictStrategy string
+
+const (
+	// ConflictError fails the upload with ErrNameTaken
+	ConflictError ConflictStrategy = "error"
+
+	// ConflictSuffix uploads the image under the name suffixed with the
+	// first free number, i.e. cat-2.png
+	ConflictSuffix ConflictStrategy = "suffix"
+
+	// ConflictOverwrite replaces the object of the image with the name and
+	// updates its record in place, keeping its ID
+	ConflictOverwrite ConflictStrategy = "overwrite"
+)
+
+// Valid reports whether the strategy is known, the empty strategy is
+// ConflictError.
+func (c ConflictStrategy) Valid() bool {
+	switch c {
+	case "", ConflictError, ConflictSuffix, ConflictOverwrite:
+		return true
+	}
+
+	return false
+}
@@ -1,11 +1,67 @@
 package images
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 const (
-	ErrRecordNotFound Error = "no image record(s) found"
-	ErrObjectNotFound Error = "no object found in storage"
+	ErrRecordNotFound  Error = "no image record(s) found"
+	ErrObjectNotFound  Error = "no object found in storage"
+	ErrStorageNotFound Error = "no storage configured by that name"
+	ErrUnsupported     Error = "operation not supported by the storage backend"
+	ErrChecksum        Error = "object checksum does not match"
+	ErrNameTaken       Error = "an image with that name already exists"
+	ErrInvalidCursor   Error = "cursor is not valid for the list"
+	ErrInvalidSort     Error = "records can not be sorted by that field"
+	ErrConflict        Error = "image record was modified concurrently"
+	ErrOrphanedObject  Error = "uploaded object could not be removed after a failed upload"
+	ErrIntegrity       Error = "downloaded object does not match its record"
+	ErrTypeNotAllowed  Error = "content type is not allowed"
+	ErrInvalidImage    Error = "upload is not a decodable image"
+	ErrNoEncryptionKey Error = "image is encrypted and no encryption key is configured"
+	ErrInvalidConflict Error = "unknown conflict strategy"
+	ErrUploadExpired   Error = "resumable upload no longer exists in storage"
+	ErrSessionMismatch Error = "upload session does not match the upload"
+	ErrFileExists      Error = "a file already exists at the download path"
+	ErrInvalidRange    Error = "byte range is not satisfiable"
+	ErrInvalidExpiry   Error = "url expiry is out of range"
+	ErrNotPending      Error = "image is not awaiting an upload"
+	ErrNotPublic       Error = "image is not public"
+	ErrArchived        Error = "object is archived, restore it before downloading"
+	ErrRestoring       Error = "object is archived, restore in progress"
+	ErrNotArchived     Error = "object is not archived"
+	ErrInvalidTier     Error = "unknown restore tier"
+	ErrInvalidDays     Error = "restore days must be at least 1"
+	ErrInvalidResize   Error = "resize dimensions are out of range"
+	ErrInvalidFormat   Error = "unknown image format"
+	ErrInvalidQuality  Error = "jpeg quality must be between 1 and 100"
+	ErrUnknownPipeline Error = "no pipeline configured by that name"
+	ErrNoWatermark     Error = "no watermark configured"
+	ErrFlagged         Error = "image was flagged by moderation"
 )
 
 // Error provides a type to return named errors
 type Error string
 
 func (e Error) Error() string { return string(e) }
+
+// BatchError reports the records of a batch operation which failed, keyed by
+// record ID. The records which are not in the error succeeded.
+type BatchError map[string]error
+
+func (e BatchError) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	failures := make([]string, len(ids))
+	for i, id := range ids {
+		failures[i] = id + ": " + e[id].Error()
+	}
+
+	return fmt.Sprintf("(%d) records failed: %s", len(ids), strings.Join(failures, "; "))
+}
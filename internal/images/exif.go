@@ -0,0 +1,47 @@
+package images
+
+import "time"
+
+// Exif is the camera metadata of a photo read from its EXIF at upload.
+// Fields the photo does not record are empty.
+type Exif struct {
+	// Make and Model of the camera, i.e. Canon and Canon EOS R5
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+
+	// LensMake and LensModel of the lens the photo was taken with
+	LensMake  string `json:"lensMake,omitempty"`
+	LensModel string `json:"lensModel,omitempty"`
+
+	// CapturedAt is when the photo was taken, in UTC. Photos of cameras
+	// which do not record their offset from UTC are assumed to be in UTC.
+	CapturedAt *time.Time `json:"capturedAt,omitempty"`
+
+	// GPS is where the photo was taken
+	GPS *GPS `json:"gps,omitempty"`
+}
+
+// GPS is a position in degrees, negative latitudes are south of the equator
+// and negative longitudes west of Greenwich.
+type GPS struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Copy returns a deep copy of the EXIF.
+func (e *Exif) Copy() *Exif {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	if e.CapturedAt != nil {
+		t := *e.CapturedAt
+		c.CapturedAt = &t
+	}
+	if e.GPS != nil {
+		gps := *e.GPS
+		c.GPS = &gps
+	}
+
+	return &c
+}
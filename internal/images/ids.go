@@ -0,0 +1,126 @@
+package images
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidID is wrapped by the errors ValidateID returns for IDs which can
+// not be used.
+const ErrInvalidID Error = "invalid image id"
+
+// maxIDLength is the longest ID accepted, IDs are part of the object keys.
+const maxIDLength = 128
+
+// idempotencyNamespace is the namespace of the IDs derived from idempotency
+// keys, it must never change or retries would no longer find their image.
+var idempotencyNamespace = uuid.MustParse("92f3fb5d-0424-4dce-8ff8-1c4f81910ce1")
+
+// ValidateID returns an error wrapping ErrInvalidID unless the ID is made of
+// at most 128 letters, digits, '.', '_' and '-', and is not "." or "..".
+func ValidateID(id string) error {
+	if id == "" || id == "." || id == ".." || len(id) > maxIDLength {
+		return fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '-':
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidID, id)
+		}
+	}
+
+	return nil
+}
+
+// IdempotentID returns the image ID derived from the idempotency key, the
+// same key always derives the same ID.
+func IdempotentID(key string) string {
+	return uuid.NewSHA1(idempotencyNamespace, []byte(key)).String()
+}
+
+// IDGenerator returns a new unique image ID.
+type IDGenerator func() string
+
+// crockford is the base32 alphabet of ULIDs, it leaves out I, L, O and U.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewUUID returns a random UUID, the default image ID.
+func NewUUID() string {
+	return uuid.New().String()
+}
+
+// NewULID returns a ULID, 26 characters encoding the millisecond it was
+// created followed by 80 random bits, so IDs sort in the order they were
+// created across milliseconds.
+func NewULID() string {
+	return ulid(time.Now())
+}
+
+func ulid(now time.Time) string {
+	var b [16]byte
+	ms := uint64(now.UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint64(b[:8], ms<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic(fmt.Sprintf("unable to read random bytes: %s", err))
+	}
+
+	// the 128 bits are encoded 5 at a time from the least significant,
+	// the first character holds the 3 most significant bits
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:])
+}
+
+// PrefixedIDs returns a generator of the IDs of gen prefixed with the prefix
+// and an underscore, i.e. img_01HZX3..., so IDs describe themselves in logs
+// and URLs. Returns an error wrapping ErrInvalidID if the prefix can not be
+// part of an ID.
+func PrefixedIDs(prefix string, gen IDGenerator) (IDGenerator, error) {
+	prefix = strings.TrimSuffix(prefix, "_")
+	if err := ValidateID(prefix); err != nil {
+		return nil, err
+	}
+
+	return func() string { return prefix + "_" + gen() }, nil
+}
+
+// MinIDPrefix is the length of the shortest ID prefix which is resolved to
+// the image whose ID starts with it, shorter ones must be whole IDs.
+const MinIDPrefix = 4
+
+// ErrAmbiguousID is wrapped by the AmbiguousIDError of an ID prefix which
+// several images' IDs start with.
+const ErrAmbiguousID Error = "image id prefix matches several images"
+
+// AmbiguousIDError reports the IDs of the images an ID prefix matches, More
+// is set when there are more than the candidates.
+type AmbiguousIDError struct {
+	Prefix     string
+	Candidates []string
+	More       bool
+}
+
+func (e *AmbiguousIDError) Error() string {
+	msg := fmt.Sprintf("%s: %q matches %s", ErrAmbiguousID, e.Prefix, strings.Join(e.Candidates, ", "))
+	if e.More {
+		msg += " and more"
+	}
+
+	return msg
+}
+
+// Unwrap returns ErrAmbiguousID.
+func (e *AmbiguousIDError) Unwrap() error { return ErrAmbiguousID }
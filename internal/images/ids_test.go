@@ -0,0 +1,62 @@
+package images
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ValidateID(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		id      string
+		wantErr bool
+	}{
+		{desc: "should accept letters, digits, dots, underscores and dashes", id: "release-1.2_final"},
+		{desc: "should accept a uuid", id: IdempotentID("key")},
+		{desc: "should reject an empty id", id: "", wantErr: true},
+		{desc: "should reject a parent directory", id: "..", wantErr: true},
+		{desc: "should reject a slash", id: "a/b", wantErr: true},
+		{desc: "should reject whitespace", id: "a b", wantErr: true},
+		{desc: "should reject a long id", id: strings.Repeat("a", maxIDLength+1), wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := ValidateID(tc.id)
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidID))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_IdempotentID(t *testing.T) {
+	assert.Equal(t, IdempotentID("key"), IdempotentID("key"))
+	assert.NotEqual(t, IdempotentID("key"), IdempotentID("other"))
+}
+
+func Test_NewULID(t *testing.T) {
+	at := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	id := ulid(at)
+	assert.Len(t, id, 26)
+	assert.NoError(t, ValidateID(id))
+	// the first 10 characters encode the millisecond, 1717416000000
+	assert.Equal(t, "01HZEZGYG0", id[:10])
+
+	assert.True(t, ulid(at) < ulid(at.Add(time.Millisecond)), "ulid() should sort by creation time")
+	assert.NotEqual(t, NewULID(), NewULID())
+}
+
+func Test_PrefixedIDs(t *testing.T) {
+	gen, err := PrefixedIDs("img_", func() string { return "1" })
+	require.NoError(t, err)
+	assert.Equal(t, "img_1", gen())
+
+	_, err = PrefixedIDs("img/", NewUUID)
+	assert.True(t, errors.Is(err, ErrInvalidID), "PrefixedIDs() should reject a prefix which can not be part of an id")
+}
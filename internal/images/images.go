@@ -1,14 +1,20 @@
 package images
 
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/cdn.go github.com/itsHabib/sim/internal/images CDN
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/url_signer.go github.com/itsHabib/sim/internal/images URLSigner
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/moderator.go github.com/itsHabib/sim/internal/images Moderator
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/text_extractor.go github.com/itsHabib/sim/internal/images TextExtractor
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/object_store.go github.com/itsHabib/sim/internal/images ObjectStore
 //go:generate go run github.com/golang/mock/mockgen -destination mocks/reader.go github.com/itsHabib/sim/internal/images Reader
 //go:generate go run github.com/golang/mock/mockgen -destination mocks/writer.go github.com/itsHabib/sim/internal/images Writer
 
 import (
+	"context"
 	"io"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
 )
 
 const (
@@ -17,6 +23,9 @@ const (
 
 	// Collection is the couchbase collection for the image records
 	Collection = "images"
+
+	// EncodingGzip is the content encoding of objects compressed with gzip
+	EncodingGzip = "gzip"
 )
 
 // Record represents the image record stored in the db that links to an actual
@@ -28,51 +37,366 @@ type Record struct {
 	// CreatedAt is the created time stamp
 	CreatedAt *time.Time `json:"createdAt"`
 
+	// UpdatedAt is the time stamp of the last write, it is set by the
+	// writer. Records written before it was tracked do not have one.
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+
 	// Etag of the object
 	ETag string `json:"etag"`
 
+	// SHA256 is the hex encoded SHA-256 digest of the object. Records
+	// uploaded before it was computed do not have one.
+	SHA256 string `json:"sha256,omitempty"`
+
 	// Key of the object in cloud storage
 	Key string `json:"key"`
 
 	// Name of the object given during an upload. This must be unique.
 	Name string `json:"name"`
 
+	// Path is the path of the uploaded file relative to the directory it
+	// was uploaded from, i.e. 2023/trip/img_001.jpg, so that downloads can
+	// recreate the directory. It is empty for files uploaded on their own.
+	Path string `json:"path,omitempty"`
+
 	// Size is the size of the object in bytes
 	SizeInBytes int64 `json:"SizeInBytes"`
 
+	// ContentType is the MIME type of the object, detected from its content
+	// or name at upload. Records uploaded before it was detected do not have
+	// one.
+	ContentType string `json:"contentType,omitempty"`
+
+	// ContentEncoding is the encoding the object was compressed with, i.e.
+	// EncodingGzip, it is empty when the object is the image as uploaded.
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+
+	// EncryptedKey is the base64 encoded data key the object was encrypted
+	// with on the client, itself encrypted with the service's encryption key.
+	// It is empty when the object is not encrypted.
+	EncryptedKey string `json:"encryptedKey,omitempty"`
+
+	// ServerSideEncryption is how the storage reported the object to be
+	// encrypted at rest after the upload, i.e. aws:kms or AES256. It is empty
+	// for storages which do not report it.
+	ServerSideEncryption string `json:"serverSideEncryption,omitempty"`
+
+	// KMSKeyID is the KMS key the storage reported encrypting the object
+	// with when ServerSideEncryption is aws:kms.
+	KMSKeyID string `json:"kmsKeyId,omitempty"`
+
+	// StorageClass is the storage class the storage reported the object to
+	// be stored in after the upload, i.e. STANDARD_IA. It is empty for
+	// storages without classes.
+	StorageClass string `json:"storageClass,omitempty"`
+
+	// CacheControl and ContentDisposition are the headers the object was
+	// uploaded with so that browsers cache and save it as intended, they are
+	// empty when the object was uploaded without them.
+	CacheControl       string `json:"cacheControl,omitempty"`
+	ContentDisposition string `json:"contentDisposition,omitempty"`
+
+	// ObjectMetadata is the user metadata set on the object itself for
+	// consumers which only see the object, i.e. S3 event handlers. Unlike
+	// Metadata it is kept by the storage.
+	ObjectMetadata map[string]string `json:"objectMetadata,omitempty"`
+
+	// Visibility is who can read the object, VisibilityPublic or
+	// VisibilityPrivate, see Visibility.
+	Visibility string `json:"visibility,omitempty"`
+
 	// Storage is the cloud storage that holds the underlying images
 	// i.e. an AWS bucket
 	Storage string `json:"storage"`
+
+	// Mirrors are the names of the secondary storages which hold a copy of
+	// the object under the same key.
+	Mirrors []string `json:"mirrors,omitempty"`
+
+	// Thumbnails are the scaled down copies of the image generated at
+	// upload, stored in the image's storage next to its object.
+	Thumbnails []Thumbnail `json:"thumbnails,omitempty"`
+
+	// Variants are the resized copies of the image cached by downloads,
+	// stored in the image's storage next to its object.
+	Variants []Variant `json:"variants,omitempty"`
+
+	// Exif is the camera metadata read from the image's EXIF at upload, it
+	// is nil for images without EXIF.
+	Exif *Exif `json:"exif,omitempty"`
+
+	// Blurhash is the blurhash of the image computed at upload, a short
+	// string frontends decode into a placeholder shown while the image
+	// loads, see https://blurha.sh.
+	Blurhash string `json:"blurhash,omitempty"`
+
+	// Moderation is the result of moderating the image at upload, it is nil
+	// for images which were not moderated.
+	Moderation *Moderation `json:"moderation,omitempty"`
+
+	// Text is the text visible in the image extracted at upload, one line
+	// per line of text, see ListFilter.Text.
+	Text string `json:"text,omitempty"`
+
+	// Tags are the labels used to organize the image, see NormalizeTags
+	Tags []string `json:"tags,omitempty"`
+
+	// Metadata are free form key value pairs describing the image, see
+	// NormalizeMetadata
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// DeletingAt is set when the delete of the image started, the record is
+	// a tombstone until its object is removed and the record with it.
+	DeletingAt *time.Time `json:"deletingAt,omitempty"`
+
+	// PendingUntil is set while the image is uploaded directly to storage
+	// with a presigned upload, until the upload expires. The record is a
+	// placeholder without an object until the upload is confirmed.
+	PendingUntil *time.Time `json:"pendingUntil,omitempty"`
+
+	// SchemaVersion is the version of the record's schema, records written
+	// before it was tracked are version 0.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	// Revision changes on every write of the record, it is set by the reader
+	// and writer. Updating a record whose revision no longer matches the
+	// stored record fails with ErrConflict, the zero value always updates.
+	Revision uint64 `json:"revision,omitempty"`
 }
 
 // Reader interface provides the means to read image records from the underlying
-// database.
+// database. The context bounds the call, cancelling it abandons the read.
 type Reader interface {
 	// Get provides the means to retrieve an image record by id.
-	Get(id string) (*Record, error)
-	// List provides the means to list image records from the db.
-	List() ([]Record, error)
+	Get(ctx context.Context, id string) (*Record, error)
+	// GetByName provides the means to retrieve an image record by name.
+	// Returns ErrRecordNotFound if no record has the name.
+	GetByName(ctx context.Context, name string) (*Record, error)
+	// GetByIDPrefix provides the means to retrieve up to limit image records
+	// whose ID starts with the prefix, ordered by ID. Returns
+	// ErrRecordNotFound if no record's ID does.
+	GetByIDPrefix(ctx context.Context, prefix string, limit int) ([]Record, error)
+	// List provides the means to list a page of image records from the db.
+	// Returns ErrRecordNotFound if the page is empty.
+	List(ctx context.Context, opts ListOptions) (*Page, error)
+	// Count provides the means to count the image records matching the
+	// filter without listing them.
+	Count(ctx context.Context, filter ListFilter) (int, error)
 }
 
 // Writer interface provides the means to write image records to the underlying
-// database.
+// database. The context bounds the call.
 type Writer interface {
 	// Create provides the means to create image records in the db.
-	Create(record *Record) error
+	Create(ctx context.Context, record *Record) error
+
+	// CreateBatch provides the means to create many image records in the db
+	// at once. Returns a BatchError holding the records which could not be
+	// created, the others are created.
+	CreateBatch(ctx context.Context, records []Record) error
 
 	// Delete provides the means to delete an image record from the db.
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
+
+	// DeleteBatch provides the means to delete many image records from the
+	// db at once. Returns a BatchError holding the records which could not
+	// be deleted, ErrRecordNotFound for the IDs which do not exist.
+	DeleteBatch(ctx context.Context, ids []string) error
+
+	// Update provides the means to replace an existing image record in the
+	// db. Returns ErrRecordNotFound if no record exists by that ID and
+	// ErrConflict if the record's revision does not match the stored record.
+	Update(ctx context.Context, record *Record) error
+}
+
+// ObjectStore interface provides the means to interact with the objects in
+// cloud storage that back the image records. The context bounds the call,
+// cancelling it abandons a transfer.
+type ObjectStore interface {
+	// Delete provides the means to remove an object from storage. Deleting an
+	// object that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// Get provides the means to download an object into the stream. Returns
+	// ErrObjectNotFound if no object exists at the key.
+	Get(ctx context.Context, key string, stream io.WriterAt) (int64, error)
+
+	// GetRange provides the means to download length bytes of the object
+	// starting at offset sequentially into w, the rest of the object when
+	// length is 0. Returns ErrObjectNotFound if no object exists at the key
+	// and ErrInvalidRange if the offset is not before the end of the object.
+	GetRange(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error)
+
+	// Head provides the means to retrieve an object's metadata without
+	// retrieving the object itself. Returns ErrObjectNotFound if no object
+	// exists at the key.
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// List provides the means to list the objects whose keys start with the
+	// prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+
+	// Presign provides the means to create a URL which grants temporary
+	// read access to the object.
+	Presign(ctx context.Context, key string, expires time.Duration) (string, error)
+
+	// PresignPut provides the means to create a request which grants
+	// temporary write access to the object, uploading it with the attributes
+	// of the options. Returns ErrUnsupported for stores which clients can
+	// not upload to directly.
+	PresignPut(ctx context.Context, key string, expires time.Duration, opts PutOptions) (*PresignedRequest, error)
+
+	// Put provides the means to upload the body to storage under the key
+	// with the attributes of the options.
+	Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error
+
+	// PutParts provides the means to upload the body to storage under the
+	// key in parts, resuming the session's upload when it has one so that
+	// only the parts it is missing are sent. The session is updated when the
+	// upload starts and as every part completes, checkpoint is called after
+	// each update so it can be persisted. Returns ErrUploadExpired when the
+	// session's upload no longer exists and ErrUnsupported for stores without
+	// resumable uploads.
+	PutParts(ctx context.Context, key string, body io.ReaderAt, opts PutOptions, session *UploadSession, checkpoint func() error) error
+
+	// URL provides the means to return the stable URL of the object, which
+	// anyone can read it through when it is public. Returns ErrUnsupported
+	// for stores whose objects have no URL.
+	URL(ctx context.Context, key string) (string, error)
+
+	// SetTags provides the means to replace the tags of the object with the
+	// image's tags, see ObjectTags. Returns ErrUnsupported for stores without
+	// object tags.
+	SetTags(ctx context.Context, key string, tags []string) error
+
+	// Restore provides the means to request a temporary copy of the
+	// archived object which can be read for the number of days. Returns
+	// ErrRestoring when a restore is already in progress and
+	// ErrUnsupported for stores without archive classes.
+	Restore(ctx context.Context, key string, tier RestoreTier, days int) error
+}
+
+// CDN interface provides the means to interact with the content delivery
+// network which serves the objects of a storage from its edge caches.
+type CDN interface {
+	// Invalidate provides the means to evict the objects at the keys from
+	// the edge caches so that the next request fetches them from storage.
+	Invalidate(ctx context.Context, keys []string) error
+}
+
+// URLSigner interface provides the means to create the URLs of the objects
+// on the CDN which serves them.
+type URLSigner interface {
+	// URL returns the URL of the object at the key, which does not expire.
+	URL(key string) string
+	// Sign returns the URL of the object at the key signed to grant read
+	// access to it until the expiry. Returns ErrUnsupported when there is
+	// no key to sign with.
+	Sign(key string, expires time.Time) (string, error)
 }
 
-// SessionGetter provides the caller a way retrieve an AWS session with
+// Moderator interface provides the means to score images for content which
+// is not safe, i.e. nudity or violence.
+type Moderator interface {
+	// Moderate returns the moderation labels of the JPEG image the
+	// moderator is confident it shows, none when it is safe.
+	Moderate(ctx context.Context, jpeg []byte) (*Moderation, error)
+}
+
+// TextExtractor interface provides the means to read the text visible in
+// images, i.e. screenshots and scanned documents.
+type TextExtractor interface {
+	// ExtractText returns the lines of text of the JPEG image joined by
+	// newlines, empty when it has none.
+	ExtractText(ctx context.Context, jpeg []byte) (string, error)
+}
+
+// PutOptions are the attributes an object is uploaded with, stores which can
+// not keep an attribute ignore it.
+type PutOptions struct {
+	// ContentType is the MIME type of the object, i.e. image/png
+	ContentType string
+
+	// ServerSideEncryption is how the storage encrypts the object at rest,
+	// i.e. aws:kms or AES256. The storage's default is used when empty.
+	ServerSideEncryption string
+
+	// KMSKeyID is the KMS key the storage encrypts the object with when
+	// ServerSideEncryption is aws:kms.
+	KMSKeyID string
+
+	// StorageClass is the class the storage keeps the object in, i.e.
+	// STANDARD_IA. The storage's default is used when empty.
+	StorageClass string
+
+	// CacheControl and ContentDisposition are the headers the object is
+	// served with, i.e. max-age=86400 and attachment; filename="a.png".
+	CacheControl       string
+	ContentDisposition string
+
+	// Metadata is the user metadata of the object, i.e. S3's x-amz-meta-*
+	// headers.
+	Metadata map[string]string
+
+	// Tags are the image's tags to set on the object, see ObjectTags. Stores
+	// without object tags ignore them.
+	Tags []string
+
+	// Public makes the object readable by anyone through its URL, i.e. with
+	// S3's public-read ACL. The object is private otherwise.
+	Public bool
+}
+
+// Stores maps storage names, as recorded on image records, to the ObjectStore
+// that holds their objects.
+type Stores map[string]ObjectStore
+
+// ObjectInfo represents the metadata of an object in cloud storage.
+type ObjectInfo struct {
+	// Key of the object, set by List
+	Key string
+
+	// ETag of the object, List leaves it empty for stores which would have
+	// to read the object to compute it
+	ETag string
+
+	// SizeInBytes is the size of the object in bytes
+	SizeInBytes int64
+
+	// LastModified is when the object was last written, set by List
+	LastModified time.Time
+
+	// ServerSideEncryption is how the object is encrypted at rest, set by
+	// Head for stores which report it
+	ServerSideEncryption string
+
+	// KMSKeyID is the KMS key the object is encrypted with, set by Head for
+	// stores which report it
+	KMSKeyID string
+
+	// StorageClass is the class the object is stored in, set by Head for
+	// stores which have classes
+	StorageClass string
+
+	// Archived reports whether the object is in an archive class, i.e.
+	// GLACIER, and has to be restored before it can be read, set by Head
+	// for stores which have archive classes
+	Archived bool
+
+	// Restore is the status of the restore of an archived object, nil
+	// when none was requested
+	Restore *RestoreStatus
+}
+
+// ConfigGetter provides the caller a way retrieve an AWS config with
 // options they provide. Added to aid mocking in unit/integration tests
-type SessionGetter func() (*session.Session, error)
+type ConfigGetter func() (aws.Config, error)
 
-// WithSessionOptions provides the way to configure the session with custom
-// aws config options
-func WithSessionOptions(opts ...*aws.Config) SessionGetter {
-	return func() (*session.Session, error) {
-		return session.NewSession(opts...)
+// WithConfigOptions provides the way to load the shared AWS config with
+// custom load options
+func WithConfigOptions(opts ...func(*config.LoadOptions) error) ConfigGetter {
+	return func() (aws.Config, error) {
+		return config.LoadDefaultConfig(context.Background(), opts...)
 	}
 }
 
@@ -84,6 +408,48 @@ type DownloadRequest struct {
 
 	// Stream represents the io writer that the object will be downloaded into
 	Stream io.WriterAt
+
+	// Writer is written to in order instead of Stream, which must be nil,
+	// for targets which can not be written at offsets i.e. a pipe or an HTTP
+	// response. The object is downloaded with a single sequential request
+	// rather than in parallel parts and is checked against the record once
+	// written, so the output should be discarded on ErrIntegrity.
+	Writer io.Writer
+
+	// Offset and Length select the byte range of the object to download, the
+	// whole object when both are 0 and the rest of it from Offset when Length
+	// is 0. A range is written to the start of the stream and is only checked
+	// against the record's size, ranges of compressed or encrypted objects
+	// can not be downloaded.
+	Offset int64
+	Length int64
+}
+
+// PartialSuffix is appended to the path of a resumable download for the
+// file which holds it until it completes.
+const PartialSuffix = ".part"
+
+// DownloadFileRequest represents the type used to request a download of an
+// image into a local file.
+type DownloadFileRequest struct {
+	// ID of the image.
+	ID string
+
+	// Path of the file to download the image into.
+	Path string
+
+	// Overwrite allows replacing a file which already exists at the path.
+	Overwrite bool
+
+	// Resume keeps the download in a partial file next to the path, see
+	// PartialSuffix, which is continued from where it stopped when the
+	// download is repeated rather than starting over.
+	Resume bool
+
+	// Offset and Length select the byte range of the object to download, see
+	// DownloadRequest.
+	Offset int64
+	Length int64
 }
 
 // UploadRequest represents the type used to request an upload on an io.Reader
@@ -92,8 +458,319 @@ type UploadRequest struct {
 	// Name of the file to upload
 	Name string
 
+	// Path is the path of the file relative to the directory it is uploaded
+	// from, see NormalizePath.
+	Path string
+
+	// Storage is the name of the storage to upload to. The service's default
+	// storage is used when empty.
+	Storage string
+
 	// Body of the data to upload
 	Body io.Reader
+
+	// Force allows uploading an image with the same name as an existing
+	// image, OnConflict is not consulted.
+	Force bool
+
+	// OnConflict is how the upload is handled when an image already has its
+	// name, ConflictError when empty. An overwrite keeps the ID of the image
+	// it replaces so it can not be combined with ID or IdempotencyKey.
+	OnConflict ConflictStrategy
+
+	// Replace uploads the body as the new content of the image with the
+	// name, keeping its ID, see ConflictOverwrite. Returns ErrRecordNotFound
+	// when no image has the name.
+	Replace bool
+
+	// Session uploads the body in parts which can be resumed, resuming the
+	// session's upload when it has one. The body must be an io.ReaderAt and
+	// io.Seeker, i.e. an *os.File. Checkpoint, when set, is called with the
+	// session as it is updated so it can be persisted for the next attempt.
+	Session    *UploadSession
+	Checkpoint func(*UploadSession) error
+
+	// Tags of the image
+	Tags []string
+
+	// Metadata of the image
+	Metadata map[string]string
+
+	// ContentType is the MIME type of the body, it is detected from the
+	// body and name when empty.
+	ContentType string
+
+	// AllowedTypes are the MIME types the body may have, i.e. image/png or
+	// image/*. It replaces the service's allowlist when set.
+	AllowedTypes []string
+
+	// ID is the ID to give the image instead of a generated one, see
+	// ValidateID. Uploading an ID which already exists returns it without
+	// uploading again.
+	ID string
+
+	// IdempotencyKey derives the image's ID so that retrying an upload with
+	// the same key returns the image of the first upload instead of
+	// uploading a duplicate. It can not be combined with ID.
+	IdempotencyKey string
+
+	// SkipUnchanged hashes the body and returns the ID of the image with the
+	// same name instead of uploading when their content is the same.
+	SkipUnchanged bool
+
+	// ServerSideEncryption is how the storage should encrypt the object at
+	// rest, i.e. aws:kms or AES256, and KMSKeyID the KMS key for aws:kms.
+	// They replace the service's defaults when either is set, a KMSKeyID on
+	// its own implies aws:kms.
+	ServerSideEncryption string
+	KMSKeyID             string
+
+	// StorageClass is the class the storage should keep the object in, i.e.
+	// STANDARD_IA or GLACIER_IR. It replaces the service's default when set.
+	StorageClass string
+
+	// CacheControl is the Cache-Control header of the object, i.e.
+	// max-age=86400. ContentDisposition is its Content-Disposition, inline
+	// or attachment are completed with the image's name as the filename.
+	// They replace the service's defaults when set.
+	CacheControl       string
+	ContentDisposition string
+
+	// ObjectMetadata is the user metadata to set on the object, see
+	// NormalizeMetadata.
+	ObjectMetadata map[string]string
+
+	// Public makes the object readable by anyone through its URL instead of
+	// only through the service.
+	Public bool
+
+	// StripMetadata removes the metadata of a jpeg or png, i.e. its EXIF and
+	// GPS position, from the body before it is stored, see
+	// imaging.StripMetadata. The body is read in full before the upload, it
+	// can not be combined with Session.
+	StripMetadata bool
+}
+
+// MigrateStorageRequest represents the type used to request moving the
+// objects of every image record from one storage to another.
+type MigrateStorageRequest struct {
+	// From is the name of the storage to migrate objects out of
+	From string
+
+	// To is the name of the storage to migrate objects into
+	To string
+
+	// DeleteOriginals removes the objects from the From storage once they
+	// have been copied and verified.
+	DeleteOriginals bool
+}
+
+// MigrateStorageResult summarizes the outcome of a storage migration.
+type MigrateStorageResult struct {
+	// Migrated is the number of records moved to the new storage
+	Migrated int `json:"migrated"`
+
+	// Failed are the IDs of the records which could not be migrated, these
+	// records still point to the original storage.
+	Failed []string `json:"failed"`
+}
+
+// GCRequest represents the type used to request the reconciliation of the
+// objects in storage with the image records.
+type GCRequest struct {
+	// Storage limits the collection to the named storage, every configured
+	// storage is collected when empty.
+	Storage string
+
+	// Grace skips the objects written less than Grace ago, their upload may
+	// not have created the record yet.
+	Grace time.Duration
+
+	// DryRun reports the orphaned objects and dangling records without
+	// deleting them.
+	DryRun bool
+}
+
+// GCResult summarizes the outcome of a garbage collection.
+type GCResult struct {
+	// Orphans are the objects which no image record points to
+	Orphans []OrphanObject `json:"orphans"`
+
+	// Dangling are the IDs of the records whose object no longer exists
+	Dangling []string `json:"dangling"`
+
+	// Failed are the keys of the orphans and the IDs of the dangling records
+	// which could not be deleted
+	Failed []string `json:"failed"`
+}
+
+// OrphanObject represents an object in storage which no image record points
+// to.
+type OrphanObject struct {
+	// Storage holding the object
+	Storage string `json:"storage"`
+
+	// Key of the object
+	Key string `json:"key"`
+
+	// SizeInBytes is the size of the object in bytes
+	SizeInBytes int64 `json:"sizeInBytes"`
+}
+
+// Problem represents the kind of inconsistency found between an image record
+// and its object.
+type Problem string
+
+const (
+	// ProblemMissingField is a required field of the record which is empty
+	ProblemMissingField Problem = "missing_field"
+
+	// ProblemUnknownStorage is a storage of the record which is not
+	// configured, its object can not be checked
+	ProblemUnknownStorage Problem = "unknown_storage"
+
+	// ProblemMissingObject is an object which does not exist in the record's
+	// storage
+	ProblemMissingObject Problem = "missing_object"
+
+	// ProblemMissingMirror is an object which does not exist in one of the
+	// record's mirrors
+	ProblemMissingMirror Problem = "missing_mirror"
+
+	// ProblemSizeMismatch is an object whose size is not the record's
+	ProblemSizeMismatch Problem = "size_mismatch"
+
+	// ProblemETagMismatch is an object whose ETag is not the record's
+	ProblemETagMismatch Problem = "etag_mismatch"
+
+	// ProblemCheckFailed is an object which could not be checked, i.e. the
+	// storage was unavailable
+	ProblemCheckFailed Problem = "check_failed"
+)
+
+// Inconsistency represents a problem found with an image record.
+type Inconsistency struct {
+	// ImageID is the ID of the record
+	ImageID string `json:"imageId"`
+
+	// Problem is the kind of inconsistency
+	Problem Problem `json:"problem"`
+
+	// Field is the empty field of a ProblemMissingField
+	Field string `json:"field,omitempty"`
+
+	// Storage and Key locate the object which was checked
+	Storage string `json:"storage,omitempty"`
+	Key     string `json:"key,omitempty"`
+
+	// Expected is the value on the record and Actual the value of the object
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+
+	// Error is the reason of a ProblemCheckFailed
+	Error string `json:"error,omitempty"`
+}
+
+// FsckReport summarizes the outcome of a consistency check.
+type FsckReport struct {
+	// Checked is the number of records which were checked
+	Checked int `json:"checked"`
+
+	// Inconsistencies are the problems found, ordered by record ID
+	Inconsistencies []Inconsistency `json:"inconsistencies"`
+}
+
+// RepairRequest represents the type used to request fixing the
+// inconsistencies found by a consistency check.
+type RepairRequest struct {
+	// Inconsistencies to fix, see FsckReport
+	Inconsistencies []Inconsistency
+
+	// RemoveMissing deletes the records whose object is missing, otherwise
+	// they are skipped.
+	RemoveMissing bool
+
+	// Checksums computes the SHA-256 digest of the records matching
+	// ChecksumFilter which do not have one, which requires downloading
+	// their objects.
+	Checksums      bool
+	ChecksumFilter ListFilter
+}
+
+// RepairResult summarizes the outcome of a repair.
+type RepairResult struct {
+	// Refreshed are the IDs of the records whose size, ETag or mirrors were
+	// refreshed from their objects
+	Refreshed []string `json:"refreshed"`
+
+	// Removed are the IDs of the records deleted as their object is missing
+	Removed []string `json:"removed"`
+
+	// Checksummed are the IDs of the records whose SHA-256 was computed
+	Checksummed []string `json:"checksummed"`
+
+	// Skipped are the inconsistencies which can not be repaired or were
+	// not requested to be
+	Skipped []Inconsistency `json:"skipped"`
+
+	// Failed are the IDs of the records which could not be repaired
+	Failed []string `json:"failed"`
+}
+
+// CheckStatus represents the outcome of an integrity check.
+type CheckStatus string
+
+const (
+	CheckPass    CheckStatus = "pass"
+	CheckFail    CheckStatus = "fail"
+	CheckSkipped CheckStatus = "skipped"
+)
+
+// Check represents the comparison of a value recorded on an image record
+// with the value computed from its object.
+type Check struct {
+	// Name of the value, i.e. sha256
+	Name string `json:"name"`
+
+	// Status of the check
+	Status CheckStatus `json:"status"`
+
+	// Expected is the recorded value and Actual the computed one
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+
+	// Reason explains a skipped check, or a failed one which has no actual
+	// value
+	Reason string `json:"reason,omitempty"`
+}
+
+// VerifyResult summarizes the outcome of verifying an image's object.
+type VerifyResult struct {
+	// ImageID is the ID of the verified record
+	ImageID string `json:"imageId"`
+
+	// Checks are the comparisons made
+	Checks []Check `json:"checks"`
+
+	// OK reports whether no check failed
+	OK bool `json:"ok"`
+}
+
+// MigrateRecordsResult summarizes the outcome of copying the image records
+// between metadata backends.
+type MigrateRecordsResult struct {
+	// Copied is the number of records written to the target
+	Copied int `json:"copied"`
+
+	// Skipped is the number of records which already existed in the target
+	Skipped int `json:"skipped"`
+
+	// Failed are the IDs of the records which could not be written
+	Failed []string `json:"failed"`
+
+	// Mismatched are the IDs of the records which did not match the source
+	// when read back from the target
+	Mismatched []string `json:"mismatched"`
 }
 
 // Image represents the public facing type used to display the key
@@ -105,6 +782,43 @@ type Image struct {
 	// Name of the object given during an upload. This must be unique.
 	Name string `json:"name"`
 
+	// Path is the path the image was uploaded from relative to its
+	// directory, if any
+	Path string `json:"path,omitempty"`
+
 	// Size is the size of the object in bytes
 	SizeInBytes int64 `json:"sizeInBytes"`
+
+	// Size is SizeInBytes rendered with its unit, see FormatSize
+	Size string `json:"size"`
+
+	// SHA256 is the hex encoded SHA-256 digest of the object, if recorded
+	SHA256 string `json:"sha256,omitempty"`
+
+	// ContentType is the MIME type of the object, if recorded
+	ContentType string `json:"contentType,omitempty"`
+
+	// Visibility is who can read the object, see Visibility
+	Visibility string `json:"visibility"`
+
+	// Metadata of the image, if any
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Pending is set while the image awaits its presigned upload
+	Pending bool `json:"pending,omitempty"`
+
+	// Thumbnails are the scaled down copies of the image, if any
+	Thumbnails []Thumbnail `json:"thumbnails,omitempty"`
+
+	// Exif is the camera metadata of the image, if any
+	Exif *Exif `json:"exif,omitempty"`
+
+	// Blurhash is the placeholder of the image, if any
+	Blurhash string `json:"blurhash,omitempty"`
+
+	// Moderation of the image, if it was moderated
+	Moderation *Moderation `json:"moderation,omitempty"`
+
+	// Text extracted from the image, if any
+	Text string `json:"text,omitempty"`
 }
@@ -0,0 +1,363 @@
+package images
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// listAllPageSize is the number of records read per page by ListAll.
+const listAllPageSize = 500
+
+// ListOptions represents the options used to page through image records.
+type ListOptions struct {
+	// Limit is the maximum number of records to return, all records are
+	// returned when zero.
+	Limit int
+
+	// Cursor resumes listing after the last record of a previous page, it is
+	// the opaque Page.NextCursor of that page.
+	Cursor string
+
+	// Filter restricts the records listed, all records are listed when it is
+	// the zero value.
+	Filter ListFilter
+
+	// Sort is the field the records are ordered by, records are ordered by
+	// ID when empty. Records with equal fields are ordered by ID.
+	Sort SortField
+
+	// Desc orders the records in descending order
+	Desc bool
+}
+
+// SortField represents a field that records can be ordered by.
+type SortField string
+
+const (
+	SortID        SortField = ""
+	SortName      SortField = "name"
+	SortSize      SortField = "size"
+	SortCreatedAt SortField = "createdAt"
+)
+
+// Valid reports whether the records can be ordered by the field.
+func (f SortField) Valid() bool {
+	switch f {
+	case SortID, SortName, SortSize, SortCreatedAt:
+		return true
+	}
+
+	return false
+}
+
+// SortKey represents the position of a record in a sorted list, it is the
+// decoded form of a cursor.
+type SortKey struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name,omitempty"`
+	SizeInBytes int64      `json:"size,omitempty"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+}
+
+// EncodeCursor returns the cursor of the page following the record. Lists
+// ordered by ID use the ID as the cursor, otherwise the sort field is encoded
+// alongside it.
+func EncodeCursor(rec *Record, field SortField) string {
+	if field == SortID {
+		return rec.ID
+	}
+
+	key := SortKey{ID: rec.ID}
+	switch field {
+	case SortName:
+		key.Name = rec.Name
+	case SortSize:
+		key.SizeInBytes = rec.SizeInBytes
+	case SortCreatedAt:
+		key.CreatedAt = rec.CreatedAt
+	}
+	b, _ := json.Marshal(key)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor returns the sort key of the cursor. Returns ErrInvalidCursor
+// if the cursor was not encoded for the sort field.
+func DecodeCursor(cursor string, field SortField) (*SortKey, error) {
+	if field == SortID {
+		return &SortKey{ID: cursor}, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var key SortKey
+	if err := json.Unmarshal(b, &key); err != nil || key.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &key, nil
+}
+
+// ListFilter represents the conditions a record must meet to be listed. The
+// zero value of a field does not filter on it.
+type ListFilter struct {
+	// NamePrefix matches records whose name starts with the prefix
+	NamePrefix string
+
+	// CreatedAfter matches records created at or after the time
+	CreatedAfter time.Time
+
+	// CreatedBefore matches records created before the time
+	CreatedBefore time.Time
+
+	// CapturedAfter matches records of photos taken at or after the time,
+	// see Exif
+	CapturedAfter time.Time
+
+	// CapturedBefore matches records of photos taken before the time
+	CapturedBefore time.Time
+
+	// Text matches records whose text extracted at upload contains it, see
+	// Record.Text. Case is ignored, except by DynamoDB.
+	Text string
+
+	// UpdatedSince matches records written at or after the time, records
+	// without an UpdatedAt fall back to their CreatedAt.
+	UpdatedSince time.Time
+
+	// MinSize matches records of at least MinSize bytes
+	MinSize int64
+
+	// MaxSize matches records of at most MaxSize bytes
+	MaxSize int64
+
+	// Storage matches records held in the named storage
+	Storage string
+
+	// Tags matches records which have every tag
+	Tags []string
+
+	// ETag matches records whose object has the ETag, surrounding quotes
+	// are ignored.
+	ETag string
+
+	// SHA256 matches records whose object has the hex encoded SHA-256
+	// digest, case is ignored.
+	SHA256 string
+
+	// Deleting matches the records whose delete started but did not finish
+	Deleting bool
+}
+
+// IsZero reports whether the filter matches every record.
+func (f *ListFilter) IsZero() bool {
+	return f.NamePrefix == "" &&
+		f.CreatedAfter.IsZero() &&
+		f.CreatedBefore.IsZero() &&
+		f.CapturedAfter.IsZero() &&
+		f.CapturedBefore.IsZero() &&
+		f.Text == "" &&
+		f.UpdatedSince.IsZero() &&
+		f.MinSize == 0 &&
+		f.MaxSize == 0 &&
+		f.Storage == "" &&
+		len(f.Tags) == 0 &&
+		f.ETag == "" &&
+		f.SHA256 == "" &&
+		!f.Deleting
+}
+
+// TrimETag returns the ETag without the quotes S3 surrounds it with.
+func TrimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
+
+// Match reports whether the record meets the conditions of the filter.
+func (f *ListFilter) Match(rec *Record) bool {
+	switch {
+	case f.NamePrefix != "" && !strings.HasPrefix(rec.Name, f.NamePrefix):
+		return false
+	case f.Storage != "" && rec.Storage != f.Storage:
+		return false
+	case f.MinSize > 0 && rec.SizeInBytes < f.MinSize:
+		return false
+	case f.MaxSize > 0 && rec.SizeInBytes > f.MaxSize:
+		return false
+	case f.ETag != "" && TrimETag(rec.ETag) != TrimETag(f.ETag):
+		return false
+	case f.SHA256 != "" && !strings.EqualFold(rec.SHA256, f.SHA256):
+		return false
+	case f.Deleting && rec.DeletingAt == nil:
+		return false
+	case f.Text != "" && !strings.Contains(strings.ToLower(rec.Text), strings.ToLower(f.Text)):
+		return false
+	}
+
+	if !f.CreatedAfter.IsZero() || !f.CreatedBefore.IsZero() {
+		if rec.CreatedAt == nil {
+			return false
+		}
+		if !f.CreatedAfter.IsZero() && rec.CreatedAt.Before(f.CreatedAfter) {
+			return false
+		}
+		if !f.CreatedBefore.IsZero() && !rec.CreatedAt.Before(f.CreatedBefore) {
+			return false
+		}
+	}
+
+	if !f.CapturedAfter.IsZero() || !f.CapturedBefore.IsZero() {
+		if rec.Exif == nil || rec.Exif.CapturedAt == nil {
+			return false
+		}
+		if !f.CapturedAfter.IsZero() && rec.Exif.CapturedAt.Before(f.CapturedAfter) {
+			return false
+		}
+		if !f.CapturedBefore.IsZero() && !rec.Exif.CapturedAt.Before(f.CapturedBefore) {
+			return false
+		}
+	}
+
+	for _, tag := range f.Tags {
+		if !HasTag(rec, tag) {
+			return false
+		}
+	}
+
+	if !f.UpdatedSince.IsZero() {
+		updated := rec.UpdatedAt
+		if updated == nil {
+			updated = rec.CreatedAt
+		}
+		if updated == nil || updated.Before(f.UpdatedSince) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Page represents a page of image records.
+type Page struct {
+	// Records of the page
+	Records []Record
+
+	// NextCursor is set when more records may follow, pass it as the
+	// ListOptions.Cursor to retrieve the next page.
+	NextCursor string
+}
+
+// ListAll pages through and returns all the image records. Returns
+// ErrRecordNotFound if no records are found.
+func ListAll(ctx context.Context, r Reader) ([]Record, error) {
+	var (
+		list []Record
+		opts = ListOptions{Limit: listAllPageSize}
+	)
+	for {
+		page, err := r.List(ctx, opts)
+		switch err {
+		case nil:
+		case ErrRecordNotFound:
+			if len(list) == 0 {
+				return nil, err
+			}
+			return list, nil
+		default:
+			return nil, err
+		}
+
+		list = append(list, page.Records...)
+		if page.NextCursor == "" {
+			return list, nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// PageRecords returns the page of records described by the options. This is
+// for readers which hold all of their records in memory, or can not order
+// them in their queries. Returns ErrRecordNotFound if the page is empty.
+func PageRecords(records []Record, opts ListOptions) (*Page, error) {
+	if !opts.Sort.Valid() {
+		return nil, ErrInvalidSort
+	}
+
+	matched := records[:0]
+	for i := range records {
+		if opts.Filter.Match(&records[i]) {
+			matched = append(matched, records[i])
+		}
+	}
+	records = matched
+
+	less := func(a, b *Record) bool {
+		if opts.Desc {
+			a, b = b, a
+		}
+		return compare(a, b, opts.Sort)
+	}
+	sort.Slice(records, func(i, j int) bool { return less(&records[i], &records[j]) })
+
+	if opts.Cursor != "" {
+		key, err := DecodeCursor(opts.Cursor, opts.Sort)
+		if err != nil {
+			return nil, err
+		}
+		after := Record{
+			ID:          key.ID,
+			CreatedAt:   key.CreatedAt,
+			Name:        key.Name,
+			SizeInBytes: key.SizeInBytes,
+		}
+		start := sort.Search(len(records), func(i int) bool { return less(&after, &records[i]) })
+		records = records[start:]
+	}
+	if len(records) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	var page Page
+	if opts.Limit > 0 && len(records) > opts.Limit {
+		records = records[:opts.Limit]
+		page.NextCursor = EncodeCursor(&records[len(records)-1], opts.Sort)
+	}
+	page.Records = records
+
+	return &page, nil
+}
+
+// compare reports whether a is ordered before b by the field, ties are
+// broken by ID.
+func compare(a, b *Record, field SortField) bool {
+	switch field {
+	case SortName:
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+	case SortSize:
+		if a.SizeInBytes != b.SizeInBytes {
+			return a.SizeInBytes < b.SizeInBytes
+		}
+	case SortCreatedAt:
+		at, bt := createdAt(a), createdAt(b)
+		if !at.Equal(bt) {
+			return at.Before(bt)
+		}
+	}
+
+	return a.ID < b.ID
+}
+
+func createdAt(rec *Record) time.Time {
+	if rec.CreatedAt == nil {
+		return time.Time{}
+	}
+
+	return *rec.CreatedAt
+}
@@ -0,0 +1,47 @@
+package images
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidMetadata is wrapped by the errors NormalizeMetadata returns for
+// metadata which can not be stored.
+const ErrInvalidMetadata Error = "invalid metadata"
+
+// NormalizeMetadata returns the metadata with its keys trimmed of
+// surrounding whitespace, or nil if there is none. Returns an error wrapping
+// ErrInvalidMetadata if a key is empty, contains whitespace or an equals
+// sign, or is given twice.
+func NormalizeMetadata(metadata map[string]string) (map[string]string, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	normalized := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		key := strings.TrimSpace(k)
+		if key == "" || strings.ContainsAny(key, " \t\r\n=") {
+			return nil, fmt.Errorf("%w: key %q", ErrInvalidMetadata, k)
+		}
+		if _, ok := normalized[key]; ok {
+			return nil, fmt.Errorf("%w: duplicate key %q", ErrInvalidMetadata, key)
+		}
+		normalized[key] = v
+	}
+
+	return normalized, nil
+}
+
+// FormatMetadata renders the metadata as comma separated key=value pairs
+// sorted by key.
+func FormatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
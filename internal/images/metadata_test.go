@@ -0,0 +1,57 @@
+package images
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NormalizeMetadata(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		metadata map[string]string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			desc:     "NormalizeMetadata() should trim the keys",
+			metadata: map[string]string{" source ": "legacy cms", "author": ""},
+			want:     map[string]string{"source": "legacy cms", "author": ""},
+		},
+		{
+			desc: "NormalizeMetadata() should return nil for no metadata",
+		},
+		{
+			desc:     "NormalizeMetadata() should reject empty keys",
+			metadata: map[string]string{" ": "x"},
+			wantErr:  true,
+		},
+		{
+			desc:     "NormalizeMetadata() should reject keys containing an equals sign",
+			metadata: map[string]string{"a=b": "x"},
+			wantErr:  true,
+		},
+		{
+			desc:     "NormalizeMetadata() should reject keys which are the same once trimmed",
+			metadata: map[string]string{"source": "a", "source ": "b"},
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := NormalizeMetadata(tc.metadata)
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidMetadata))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_FormatMetadata(t *testing.T) {
+	assert.Equal(t, "author=me,source=cms", FormatMetadata(map[string]string{"source": "cms", "author": "me"}))
+	assert.Equal(t, "", FormatMetadata(nil))
+}
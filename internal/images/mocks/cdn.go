@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/itsHabib/sim/internal/images (interfaces: CDN)
+
+// Package mock_images is a generated GoMock package.
+package mock_images
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockCDN is a mock of CDN interface.
+type MockCDN struct {
+	ctrl     *gomock.Controller
+	recorder *MockCDNMockRecorder
+}
+
+// MockCDNMockRecorder is the mock recorder for MockCDN.
+type MockCDNMockRecorder struct {
+	mock *MockCDN
+}
+
+// NewMockCDN creates a new mock instance.
+func NewMockCDN(ctrl *gomock.Controller) *MockCDN {
+	mock := &MockCDN{ctrl: ctrl}
+	mock.recorder = &MockCDNMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCDN) EXPECT() *MockCDNMockRecorder {
+	return m.recorder
+}
+
+// Invalidate mocks base method.
+func (m *MockCDN) Invalidate(arg0 context.Context, arg1 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Invalidate", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Invalidate indicates an expected call of Invalidate.
+func (mr *MockCDNMockRecorder) Invalidate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invalidate", reflect.TypeOf((*MockCDN)(nil).Invalidate), arg0, arg1)
+}
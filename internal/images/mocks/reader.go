@@ -5,6 +5,7 @@
 package mock_images
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,32 +35,77 @@ func (m *MockReader) EXPECT() *MockReaderMockRecorder {
 	return m.recorder
 }
 
+// Count mocks base method.
+func (m *MockReader) Count(arg0 context.Context, arg1 images.ListFilter) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockReaderMockRecorder) Count(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockReader)(nil).Count), arg0, arg1)
+}
+
 // Get mocks base method.
-func (m *MockReader) Get(arg0 string) (*images.Record, error) {
+func (m *MockReader) Get(arg0 context.Context, arg1 string) (*images.Record, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", arg0)
+	ret := m.ctrl.Call(m, "Get", arg0, arg1)
 	ret0, _ := ret[0].(*images.Record)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get.
-func (mr *MockReaderMockRecorder) Get(arg0 interface{}) *gomock.Call {
+func (mr *MockReaderMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockReader)(nil).Get), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockReader)(nil).Get), arg0, arg1)
 }
 
-// List mocks base method.
-func (m *MockReader) List() ([]images.Record, error) {
+// GetByIDPrefix mocks base method.
+func (m *MockReader) GetByIDPrefix(arg0 context.Context, arg1 string, arg2 int) ([]images.Record, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "List")
+	ret := m.ctrl.Call(m, "GetByIDPrefix", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]images.Record)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// GetByIDPrefix indicates an expected call of GetByIDPrefix.
+func (mr *MockReaderMockRecorder) GetByIDPrefix(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIDPrefix", reflect.TypeOf((*MockReader)(nil).GetByIDPrefix), arg0, arg1, arg2)
+}
+
+// GetByName mocks base method.
+func (m *MockReader) GetByName(arg0 context.Context, arg1 string) (*images.Record, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByName", arg0, arg1)
+	ret0, _ := ret[0].(*images.Record)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByName indicates an expected call of GetByName.
+func (mr *MockReaderMockRecorder) GetByName(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByName", reflect.TypeOf((*MockReader)(nil).GetByName), arg0, arg1)
+}
+
+// List mocks base method.
+func (m *MockReader) List(arg0 context.Context, arg1 images.ListOptions) (*images.Page, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
+	ret0, _ := ret[0].(*images.Page)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // List indicates an expected call of List.
-func (mr *MockReaderMockRecorder) List() *gomock.Call {
+func (mr *MockReaderMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockReader)(nil).List))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockReader)(nil).List), arg0, arg1)
 }
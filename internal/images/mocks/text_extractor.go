@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/itsHabib/sim/internal/images (interfaces: TextExtractor)
+
+// Package mock_images is a generated GoMock package.
+package mock_images
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTextExtractor is a mock of TextExtractor interface.
+type MockTextExtractor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTextExtractorMockRecorder
+}
+
+// MockTextExtractorMockRecorder is the mock recorder for MockTextExtractor.
+type MockTextExtractorMockRecorder struct {
+	mock *MockTextExtractor
+}
+
+// NewMockTextExtractor creates a new mock instance.
+func NewMockTextExtractor(ctrl *gomock.Controller) *MockTextExtractor {
+	mock := &MockTextExtractor{ctrl: ctrl}
+	mock.recorder = &MockTextExtractorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTextExtractor) EXPECT() *MockTextExtractorMockRecorder {
+	return m.recorder
+}
+
+// ExtractText mocks base method.
+func (m *MockTextExtractor) ExtractText(arg0 context.Context, arg1 []byte) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtractText", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExtractText indicates an expected call of ExtractText.
+func (mr *MockTextExtractorMockRecorder) ExtractText(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractText", reflect.TypeOf((*MockTextExtractor)(nil).ExtractText), arg0, arg1)
+}
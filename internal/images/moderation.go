@@ -0,0 +1,63 @@
+package images
+
+// ModerationPolicy is what is done with an upload moderation flags.
+type ModerationPolicy string
+
+const (
+	// ModerationFlag stores a flagged image like any other, with its
+	// moderation recorded.
+	ModerationFlag ModerationPolicy = "flag"
+
+	// ModerationQuarantine stores a flagged image private, whatever the
+	// upload requested, until it is reviewed.
+	ModerationQuarantine ModerationPolicy = "quarantine"
+
+	// ModerationReject fails the upload of a flagged image with ErrFlagged,
+	// nothing is stored.
+	ModerationReject ModerationPolicy = "reject"
+)
+
+// Valid reports whether the policy is known.
+func (p ModerationPolicy) Valid() bool {
+	switch p {
+	case ModerationFlag, ModerationQuarantine, ModerationReject:
+		return true
+	}
+
+	return false
+}
+
+// Moderation is the result of moderating an image, see Moderator.
+type Moderation struct {
+	// Flagged is set when the image has moderation labels
+	Flagged bool `json:"flagged"`
+
+	// Quarantined is set when the image was stored private because it was
+	// flagged, see ModerationQuarantine.
+	Quarantined bool `json:"quarantined,omitempty"`
+
+	// Labels are the unsafe content the image shows
+	Labels []ModerationLabel `json:"labels,omitempty"`
+
+	// ModelVersion is the version of the model which moderated the image
+	ModelVersion string `json:"modelVersion,omitempty"`
+}
+
+// ModerationLabel is a kind of unsafe content, i.e. Explicit Nudity, and how
+// confident from 0 to 100 the moderator is that the image shows it.
+type ModerationLabel struct {
+	Name       string  `json:"name"`
+	Parent     string  `json:"parent,omitempty"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Copy returns a deep copy of the moderation.
+func (m *Moderation) Copy() *Moderation {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	c.Labels = append([]ModerationLabel(nil), m.Labels...)
+
+	return &c
+}
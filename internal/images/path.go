@@ -0,0 +1,28 @@
+package images
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrInvalidPath is wrapped by the errors NormalizePath returns for paths
+// which could escape the directory they are recreated in.
+const ErrInvalidPath Error = "invalid image path"
+
+// NormalizePath returns the slash separated relative path cleaned of
+// redundant elements, i.e. 2023/./trip//img_001.jpg becomes
+// 2023/trip/img_001.jpg. Returns an error wrapping ErrInvalidPath if the path
+// is absolute or leaves its directory.
+func NormalizePath(p string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+
+	clean := path.Clean(strings.ReplaceAll(p, `\`, "/"))
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, p)
+	}
+
+	return clean, nil
+}
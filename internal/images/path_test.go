@@ -0,0 +1,50 @@
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NormalizePath(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "NormalizePath() should keep an empty path",
+		},
+		{
+			desc: "NormalizePath() should clean the path",
+			path: `2023/./trip//img_001.jpg`,
+			want: "2023/trip/img_001.jpg",
+		},
+		{
+			desc: "NormalizePath() should use slashes as separators",
+			path: `2023\trip\img_001.jpg`,
+			want: "2023/trip/img_001.jpg",
+		},
+		{
+			desc:    "NormalizePath() should reject an absolute path",
+			path:    "/etc/passwd",
+			wantErr: true,
+		},
+		{
+			desc:    "NormalizePath() should reject a path leaving its directory",
+			path:    "2023/../../img_001.jpg",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := NormalizePath(tc.path)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidPath)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package images
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Pipeline is a named, reusable set of the transformations of a variant,
+// i.e. web for the copy of an image served on a site.
+type Pipeline struct {
+	// Width and Height are the box the image is scaled down to fit in,
+	// see VariantRequest.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Format the variant is encoded in, the image's format when empty.
+	Format string `json:"format"`
+
+	// Quality of a JPEG variant from 1 to 100, a default quality when 0.
+	Quality int `json:"quality"`
+
+	// Watermark overlays the configured watermark on the variant.
+	Watermark bool `json:"watermark"`
+
+	// OnUpload applies the pipeline to every uploaded image, its variant
+	// is stored and recorded on the image as part of the upload.
+	OnUpload bool `json:"onUpload"`
+}
+
+// Variant returns the request of the pipeline's variant of the image.
+func (p Pipeline) Variant(id string) VariantRequest {
+	return VariantRequest{
+		ID:        id,
+		Width:     p.Width,
+		Height:    p.Height,
+		Format:    p.Format,
+		Quality:   p.Quality,
+		Watermark: p.Watermark,
+	}
+}
+
+// LoadPipelines reads the named pipelines from a JSON file which maps
+// pipeline names to their transformations.
+func LoadPipelines(path string) (map[string]Pipeline, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pipelines: %w", err)
+	}
+
+	var pipelines map[string]Pipeline
+	if err := json.Unmarshal(b, &pipelines); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal pipelines: %w", err)
+	}
+
+	if len(pipelines) == 0 {
+		return nil, errors.New("no pipelines found")
+	}
+
+	return pipelines, nil
+}
@@ -2,8 +2,10 @@
 package reader
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,16 +17,24 @@ import (
 
 const (
 	loggerName = "images.reader"
-	dbTimeout  = time.Second * 3
+
+	// selectRecord selects the record along with its CAS as the revision
+	selectRecord = "x.*, META(x).cas AS revision"
+
+	// defaultReadyTimeout is how long to wait for the bucket to be ready
+	defaultReadyTimeout = time.Second * 3
 )
 
-// Service provides the implementation to read image records from a dynamodb
-// table.
+// Service provides the implementation to read image records from a couchbase
+// collection.
 type Service struct {
-	cb         *gocb.Cluster
-	collection *gocb.Collection
-	logger     *zap.Logger
-	name       string
+	cb             *gocb.Cluster
+	collection     *gocb.Collection
+	collectionName string
+	logger         *zap.Logger
+	name           string
+	readyTimeout   time.Duration
+	scope          string
 }
 
 // NewService returns an instantiated instance of a service which has the
@@ -35,11 +45,19 @@ type Service struct {
 // cb: couchbase cluster connection
 //
 // name: the couchbase bucket name
-func NewService(logger *zap.Logger, cb *gocb.Cluster, name string) (*Service, error) {
+//
+// opts: optional behavior i.e. WithCollection
+func NewService(logger *zap.Logger, cb *gocb.Cluster, name string, opts ...Option) (*Service, error) {
 	s := Service{
-		cb:     cb,
-		logger: logger.Named(loggerName),
-		name:   name,
+		cb:             cb,
+		collectionName: images.Collection,
+		logger:         logger.Named(loggerName),
+		name:           name,
+		readyTimeout:   defaultReadyTimeout,
+		scope:          images.Scope,
+	}
+	for i := range opts {
+		opts[i](&s)
 	}
 	if err := s.setCollection(cb, name); err != nil {
 		const msg = "unable to set collection"
@@ -56,6 +74,26 @@ func NewService(logger *zap.Logger, cb *gocb.Cluster, name string) (*Service, er
 	return &s, nil
 }
 
+// Option provides the means to configure optional behavior of the service.
+type Option func(s *Service)
+
+// WithCollection overrides the scope and collection that hold the image
+// records, defaults to images.Scope and images.Collection.
+func WithCollection(scope, collection string) Option {
+	return func(s *Service) {
+		s.scope = scope
+		s.collectionName = collection
+	}
+}
+
+// WithReadyTimeout overrides how long to wait for the bucket to be ready,
+// defaults to 3s.
+func WithReadyTimeout(d time.Duration) Option {
+	return func(s *Service) {
+		s.readyTimeout = d
+	}
+}
+
 func (s *Service) validate() error {
 	var missingDeps []string
 
@@ -98,13 +136,10 @@ func (s *Service) validate() error {
 
 // Get returns an image record given the id. Returns ErrRecordNotFound if no
 // image is found by that ID.
-func (s *Service) Get(id string) (*images.Record, error) {
+func (s *Service) Get(ctx context.Context, id string) (*images.Record, error) {
 	logger := s.logger.With(zap.String("imageId", id))
 
-	options := gocb.GetOptions{
-		Timeout: dbTimeout,
-	}
-	res, err := s.collection.Get(id, &options)
+	res, err := s.collection.Get(id, &gocb.GetOptions{Context: ctx})
 	if err != nil {
 		if errors.Is(err, gocb.ErrDocumentNotFound) {
 			logger.Error("record not found")
@@ -121,22 +156,137 @@ func (s *Service) Get(id string) (*images.Record, error) {
 		logger.Error(msg, zap.Error(err))
 		return nil, fmt.Errorf(msg+": %w", err)
 	}
+	rec.Revision = uint64(res.Cas())
 
 	return &rec, nil
 }
 
-// List lists all the image records in the db. This performs a scan
-// operation which can be slow with many items in the db. Returns an ErrRecordNotFound
-// if no records are found.
-func (s *Service) List() ([]images.Record, error) {
+// GetByName returns the image record with the given name. This requires an
+// index on the name field. Returns ErrRecordNotFound if no image is found by
+// that name.
+func (s *Service) GetByName(ctx context.Context, name string) (*images.Record, error) {
+	logger := s.logger.With(zap.String("imageName", name))
+
+	query := "SELECT " + selectRecord + " FROM " + s.fqn() + " x WHERE x.name = $name LIMIT 1"
+	options := gocb.QueryOptions{
+		Context:         ctx,
+		NamedParameters: map[string]interface{}{"name": name},
+	}
+	result, err := s.cb.Query(query, &options)
+	if err != nil {
+		const msg = "unable to query cluster"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	var rec images.Record
+	if err := result.One(&rec); err != nil {
+		if errors.Is(err, gocb.ErrNoResult) {
+			logger.Error("record not found")
+			return nil, images.ErrRecordNotFound
+		}
+		const msg = "unable to unmarshal result into image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return &rec, nil
+}
 
-	fqn := "`" + s.name + "`" + "." + images.Scope + "." + images.Collection
-	query := "SELECT x.* FROM " + fqn + " x"
+// GetByIDPrefix returns up to limit image records whose document ID starts
+// with the prefix, using the primary index. Returns ErrRecordNotFound if no
+// record's ID does.
+func (s *Service) GetByIDPrefix(ctx context.Context, prefix string, limit int) ([]images.Record, error) {
+	logger := s.logger.With(zap.String("idPrefix", prefix))
 
+	query := "SELECT " + selectRecord + " FROM " + s.fqn() + " x WHERE META(x).id LIKE $pattern ORDER BY META(x).id LIMIT $limit"
 	options := gocb.QueryOptions{
-		Timeout: dbTimeout,
+		Context: ctx,
+		NamedParameters: map[string]interface{}{
+			"pattern": escapeLike(prefix) + "%",
+			"limit":   limit,
+		},
 	}
 	result, err := s.cb.Query(query, &options)
+	if err != nil {
+		const msg = "unable to query cluster"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	var list []images.Record
+	for result.Next() {
+		var rec images.Record
+		if err := result.Row(&rec); err != nil {
+			const msg = "unable to unmarshal result into image record"
+			logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+		list = append(list, rec)
+	}
+	if err := result.Err(); err != nil {
+		const msg = "unable to read query results"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	if len(list) == 0 {
+		logger.Error("record not found")
+		return nil, images.ErrRecordNotFound
+	}
+
+	return list, nil
+}
+
+// Count returns the number of image records matching the filter.
+func (s *Service) Count(ctx context.Context, filter images.ListFilter) (int, error) {
+	where, params, err := listConditions(images.ListOptions{Filter: filter})
+	if err != nil {
+		s.logger.Error("unable to build count query", zap.Error(err))
+		return 0, err
+	}
+	query := "SELECT RAW COUNT(*) FROM " + s.fqn() + " x"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	result, err := s.cb.Query(query, &gocb.QueryOptions{Context: ctx, NamedParameters: params})
+	if err != nil {
+		const msg = "unable to query cluster"
+		s.logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+
+	var n int
+	if err := result.One(&n); err != nil {
+		const msg = "unable to read count result"
+		s.logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// List lists a page of image records in the db ordered by the sort field of
+// the options, the cursor is the sort key of the last record of the previous
+// page. Returns an ErrRecordNotFound if no records are found.
+func (s *Service) List(ctx context.Context, opts images.ListOptions) (*images.Page, error) {
+	where, params, err := listConditions(opts)
+	if err != nil {
+		s.logger.Error("unable to build list query", zap.Error(err))
+		return nil, err
+	}
+	query := "SELECT " + selectRecord + " FROM " + s.fqn() + " x"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + listOrder(opts)
+	if opts.Limit > 0 {
+		// read one past the limit to know whether another page follows
+		query += " LIMIT $limit"
+		params["limit"] = opts.Limit + 1
+	}
+
+	result, err := s.cb.Query(query, &gocb.QueryOptions{Context: ctx, NamedParameters: params})
 	if err != nil {
 		const msg = "unable to query cluster"
 		s.logger.Error(msg, zap.Error(err))
@@ -151,24 +301,189 @@ func (s *Service) List() ([]images.Record, error) {
 			s.logger.Error(msg, zap.Error(err))
 			return nil, fmt.Errorf(msg+": %w", err)
 		}
-		s.logger.Info("adding image to list", zap.Any("record", rec))
+		s.logger.Debug("adding image to list", zap.Any("record", rec))
 		list = append(list, rec)
 	}
+	if err := result.Err(); err != nil {
+		const msg = "unable to read query results"
+		s.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
 
 	if len(list) == 0 {
 		return nil, images.ErrRecordNotFound
 	}
 
-	return list, nil
+	var page images.Page
+	if opts.Limit > 0 && len(list) > opts.Limit {
+		list = list[:opts.Limit]
+		page.NextCursor = images.EncodeCursor(&list[len(list)-1], opts.Sort)
+	}
+	page.Records = list
+
+	return &page, nil
+}
+
+// sortExprs are the N1QL expressions records are ordered by for each sort
+// field.
+var sortExprs = map[images.SortField]string{
+	images.SortID:        "META(x).id",
+	images.SortName:      "x.name",
+	images.SortSize:      "x.SizeInBytes",
+	images.SortCreatedAt: "STR_TO_MILLIS(x.createdAt)",
+}
+
+// listOrder returns the ORDER BY clause of the options, ties are broken by
+// ID so that the cursor is stable.
+func listOrder(opts images.ListOptions) string {
+	dir := ""
+	if opts.Desc {
+		dir = " DESC"
+	}
+
+	order := sortExprs[opts.Sort] + dir
+	if opts.Sort != images.SortID {
+		order += ", META(x).id" + dir
+	}
+
+	return order
+}
+
+// listConditions translates the cursor and filter of the options into the
+// conditions of a parameterized N1QL WHERE clause.
+func listConditions(opts images.ListOptions) ([]string, map[string]interface{}, error) {
+	if !opts.Sort.Valid() {
+		return nil, nil, images.ErrInvalidSort
+	}
+
+	var (
+		f      = opts.Filter
+		where  []string
+		params = make(map[string]interface{})
+	)
+	if opts.Cursor != "" {
+		key, err := images.DecodeCursor(opts.Cursor, opts.Sort)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		op := ">"
+		if opts.Desc {
+			op = "<"
+		}
+		params["cursor"] = key.ID
+		switch opts.Sort {
+		case images.SortID:
+			where = append(where, "META(x).id "+op+" $cursor")
+		default:
+			expr := sortExprs[opts.Sort]
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s $cursorValue OR (%[1]s = $cursorValue AND META(x).id %[2]s $cursor))", expr, op))
+			params["cursorValue"] = cursorValue(key, opts.Sort)
+		}
+	}
+	if f.NamePrefix != "" {
+		where = append(where, "x.name LIKE $namePrefix")
+		params["namePrefix"] = escapeLike(f.NamePrefix) + "%"
+	}
+	if f.Storage != "" {
+		where = append(where, "x.storage = $storage")
+		params["storage"] = f.Storage
+	}
+	if f.MinSize > 0 {
+		where = append(where, "x.SizeInBytes >= $minSize")
+		params["minSize"] = f.MinSize
+	}
+	if f.MaxSize > 0 {
+		where = append(where, "x.SizeInBytes <= $maxSize")
+		params["maxSize"] = f.MaxSize
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "STR_TO_MILLIS(x.createdAt) >= $createdAfter")
+		params["createdAfter"] = millis(f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "STR_TO_MILLIS(x.createdAt) < $createdBefore")
+		params["createdBefore"] = millis(f.CreatedBefore)
+	}
+	if !f.CapturedAfter.IsZero() {
+		where = append(where, "STR_TO_MILLIS(x.exif.capturedAt) >= $capturedAfter")
+		params["capturedAfter"] = millis(f.CapturedAfter)
+	}
+	if !f.CapturedBefore.IsZero() {
+		where = append(where, "STR_TO_MILLIS(x.exif.capturedAt) < $capturedBefore")
+		params["capturedBefore"] = millis(f.CapturedBefore)
+	}
+	if f.Text != "" {
+		where = append(where, "CONTAINS(LOWER(x.text), $text)")
+		params["text"] = strings.ToLower(f.Text)
+	}
+	if f.ETag != "" {
+		// S3 ETags are stored quoted
+		etag := images.TrimETag(f.ETag)
+		where = append(where, "x.etag IN [$etag, $etagQuoted]")
+		params["etag"] = etag
+		params["etagQuoted"] = `"` + etag + `"`
+	}
+	if f.SHA256 != "" {
+		where = append(where, "x.sha256 = $sha256")
+		params["sha256"] = strings.ToLower(f.SHA256)
+	}
+	if f.Deleting {
+		where = append(where, "x.deletingAt IS VALUED")
+	}
+	for i, tag := range f.Tags {
+		// the range variable matches the tags index so it can be used
+		name := "tag" + strconv.Itoa(i)
+		where = append(where, "ANY t IN x.tags SATISFIES t = $"+name+" END")
+		params[name] = tag
+	}
+	if !f.UpdatedSince.IsZero() {
+		where = append(where, "STR_TO_MILLIS(IFMISSINGORNULL(x.updatedAt, x.createdAt)) >= $updatedSince")
+		params["updatedSince"] = millis(f.UpdatedSince)
+	}
+
+	return where, params, nil
+}
+
+// cursorValue returns the value of the sort key in the form compared by the
+// sort field's expression.
+func cursorValue(key *images.SortKey, field images.SortField) interface{} {
+	switch field {
+	case images.SortName:
+		return key.Name
+	case images.SortSize:
+		return key.SizeInBytes
+	case images.SortCreatedAt:
+		if key.CreatedAt == nil {
+			return int64(0)
+		}
+		return millis(*key.CreatedAt)
+	}
+
+	return key.ID
+}
+
+// escapeLike escapes the LIKE wildcards in s so that it is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// fqn returns the fully qualified name of the collection for queries.
+func (s *Service) fqn() string {
+	return "`" + s.name + "`" + "." + s.scope + "." + s.collectionName
 }
 
 func (s *Service) setCollection(c *gocb.Cluster, bucket string) error {
 	b := c.Bucket(bucket)
-	if err := b.WaitUntilReady(time.Second*3, nil); err != nil {
+	if err := b.WaitUntilReady(s.readyTimeout, nil); err != nil {
 		return fmt.Errorf("unable to connect to bucket: %q", err)
 	}
 
-	s.collection = b.Scope(images.Scope).Collection(images.Collection)
+	s.collection = b.Scope(s.scope).Collection(s.collectionName)
 
 	return nil
 }
@@ -0,0 +1,45 @@
+package images
+
+import "time"
+
+// RestoreTier represents how fast an archived object is restored, the faster
+// tiers cost more.
+type RestoreTier string
+
+const (
+	// RestoreExpedited restores the object within minutes, it is not
+	// available for DEEP_ARCHIVE
+	RestoreExpedited RestoreTier = "Expedited"
+
+	// RestoreStandard restores the object within hours
+	RestoreStandard RestoreTier = "Standard"
+
+	// RestoreBulk restores the object within a day or two, for the least
+	RestoreBulk RestoreTier = "Bulk"
+)
+
+// Valid reports whether the tier is known.
+func (t RestoreTier) Valid() bool {
+	switch t {
+	case RestoreExpedited, RestoreStandard, RestoreBulk:
+		return true
+	}
+
+	return false
+}
+
+// RestoreStatus is the status of the restore of an archived object.
+type RestoreStatus struct {
+	// InProgress reports whether the object is being restored
+	InProgress bool
+
+	// ExpiresAt is when the restored copy of the object is removed again,
+	// set once the restore is done
+	ExpiresAt *time.Time
+}
+
+// Restored reports whether the object was restored and can be read until
+// the restored copy expires.
+func (s *RestoreStatus) Restored() bool {
+	return s != nil && !s.InProgress && s.ExpiresAt != nil
+}
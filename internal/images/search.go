@@ -0,0 +1,317 @@
+package images
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidQuery is wrapped by the errors ParseQuery returns for malformed
+// search expressions.
+const ErrInvalidQuery Error = "invalid search query"
+
+// Query represents a parsed search expression. The conditions the database
+// can evaluate are in Filter, the name patterns are matched by the client.
+type Query struct {
+	// Filter restricts the records read from the database
+	Filter ListFilter
+
+	// Names are patterns, see path.Match, which a record's name must all
+	// match
+	Names []string
+}
+
+// Match reports whether the record meets every condition of the query.
+func (q *Query) Match(rec *Record) bool {
+	if !q.Filter.Match(rec) {
+		return false
+	}
+	for _, pattern := range q.Names {
+		if ok, _ := path.Match(pattern, rec.Name); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseQuery parses a search expression made of terms joined by AND, which
+// is optional, i.e. `name:*.png AND size>2MB storage:archive`. The terms are:
+//
+// name:<pattern> matches names against the pattern, see path.Match
+//
+// size<op><size> compares the size, i.e. size>=1.5MB, units are B, KB, MB,
+// GB and TB in multiples of 1024
+//
+// created<op><time> compares the creation time to an RFC 3339 time or a
+// 2006-01-02 date, which covers the whole day
+//
+// captured<op><time> compares the time the photo was taken, see Exif, like
+// created. Images without a capture time do not match
+//
+// updated>=<time> matches records created or updated at or after the time
+//
+// storage:<name> matches records held in the storage profile
+//
+// tag:<tag> matches records which have the tag
+//
+// text:<text> matches records whose text extracted at upload contains the
+// text, see Record.Text
+//
+// etag:<etag> and sha256:<digest> match records by the checksum of their
+// object
+//
+// Operators are one of : = > >= < <=. A bare word matches names which
+// contain it. Values holding spaces can be double quoted.
+func ParseQuery(expr string) (*Query, error) {
+	terms, err := splitTerms(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: empty query", ErrInvalidQuery)
+	}
+
+	var q Query
+	for _, term := range terms {
+		switch strings.ToUpper(term) {
+		case "AND":
+			continue
+		case "OR", "NOT":
+			return nil, fmt.Errorf("%w: %s is not supported, terms are always combined with AND", ErrInvalidQuery, term)
+		}
+		if err := q.addTerm(term); err != nil {
+			return nil, err
+		}
+	}
+	if q.Filter.MaxSize < 0 {
+		return nil, fmt.Errorf("%w: sizes must be at least 1 byte", ErrInvalidQuery)
+	}
+
+	return &q, nil
+}
+
+func (q *Query) addTerm(term string) error {
+	i := strings.IndexAny(term, ":=<>")
+	if i < 0 || term[0] == '"' {
+		return q.addName("*" + unquote(term) + "*")
+	}
+
+	field, rest := strings.ToLower(term[:i]), term[i:]
+	op := rest[:1]
+	if len(rest) > 1 && rest[1] == '=' && (op == "<" || op == ">") {
+		op = rest[:2]
+	}
+	value := unquote(rest[len(op):])
+	if value == "" {
+		return fmt.Errorf("%w: %s has no value", ErrInvalidQuery, term)
+	}
+
+	switch field {
+	case "name":
+		if op != ":" && op != "=" {
+			return fmt.Errorf("%w: name does not support %s", ErrInvalidQuery, op)
+		}
+		return q.addName(value)
+	case "size":
+		return q.addSize(op, value)
+	case "created":
+		return q.addTimes(op, value, &q.Filter.CreatedAfter, &q.Filter.CreatedBefore)
+	case "captured":
+		return q.addTimes(op, value, &q.Filter.CapturedAfter, &q.Filter.CapturedBefore)
+	case "updated":
+		return q.addUpdated(op, value)
+	case "storage":
+		if op != ":" && op != "=" {
+			return fmt.Errorf("%w: storage does not support %s", ErrInvalidQuery, op)
+		}
+		q.Filter.Storage = value
+		return nil
+	case "text":
+		if op != ":" && op != "=" {
+			return fmt.Errorf("%w: text does not support %s", ErrInvalidQuery, op)
+		}
+		if q.Filter.Text != "" {
+			return fmt.Errorf("%w: text can only be searched once", ErrInvalidQuery)
+		}
+		q.Filter.Text = value
+		return nil
+	case "etag", "sha256":
+		if op != ":" && op != "=" {
+			return fmt.Errorf("%w: %s does not support %s", ErrInvalidQuery, field, op)
+		}
+		if field == "etag" {
+			q.Filter.ETag = value
+		} else {
+			q.Filter.SHA256 = value
+		}
+		return nil
+	case "tag":
+		if op != ":" && op != "=" {
+			return fmt.Errorf("%w: tag does not support %s", ErrInvalidQuery, op)
+		}
+		q.Filter.Tags = append(q.Filter.Tags, value)
+		return nil
+	}
+
+	return fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, field)
+}
+
+// addName adds the name pattern, the literal prefix of the longest pattern
+// is pushed down to the database.
+func (q *Query) addName(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("%w: invalid name pattern %q", ErrInvalidQuery, pattern)
+	}
+	q.Names = append(q.Names, pattern)
+
+	prefix := pattern
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		prefix = pattern[:i]
+	}
+	if len(prefix) > len(q.Filter.NamePrefix) {
+		q.Filter.NamePrefix = prefix
+	}
+
+	return nil
+}
+
+func (q *Query) addSize(op, value string) error {
+	size, err := ParseSize(value)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+
+	min, max := int64(-1), int64(-1)
+	switch op {
+	case ":", "=":
+		min, max = size, size
+	case ">":
+		min = size + 1
+	case ">=":
+		min = size
+	case "<":
+		max = size - 1
+	case "<=":
+		max = size
+	}
+	if min >= 0 && min > q.Filter.MinSize {
+		q.Filter.MinSize = min
+	}
+	if max >= 0 && (q.Filter.MaxSize == 0 || max < q.Filter.MaxSize) {
+		q.Filter.MaxSize = max
+		if max == 0 {
+			// a zero MaxSize does not filter
+			q.Filter.MaxSize = -1
+		}
+	}
+
+	return nil
+}
+
+// addTimes narrows the range [after, before) of a time field, i.e. created,
+// to the times the comparison matches.
+func (q *Query) addTimes(op, value string, after, before *time.Time) error {
+	start, end, err := parseTime(value)
+	if err != nil {
+		return err
+	}
+
+	var from, until time.Time
+	switch op {
+	case ":", "=":
+		from, until = start, end
+	case ">":
+		from = end
+	case ">=":
+		from = start
+	case "<":
+		until = start
+	case "<=":
+		until = end
+	}
+	if from.After(*after) {
+		*after = from
+	}
+	if !until.IsZero() && (before.IsZero() || until.Before(*before)) {
+		*before = until
+	}
+
+	return nil
+}
+
+func (q *Query) addUpdated(op, value string) error {
+	start, end, err := parseTime(value)
+	if err != nil {
+		return err
+	}
+
+	since := start
+	switch op {
+	case ">=":
+	case ">":
+		since = end
+	default:
+		return fmt.Errorf("%w: updated only supports > and >=", ErrInvalidQuery)
+	}
+	if since.After(q.Filter.UpdatedSince) {
+		q.Filter.UpdatedSince = since
+	}
+
+	return nil
+}
+
+// parseTime returns the span [start, end) covered by the value, a whole day
+// for a date or a single instant for an RFC 3339 time.
+func parseTime(value string) (time.Time, time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid time %q, use RFC 3339 or 2006-01-02", ErrInvalidQuery, value)
+	}
+
+	return t, t.Add(time.Nanosecond), nil
+}
+
+// splitTerms splits the expression on whitespace outside of double quotes.
+func splitTerms(expr string) ([]string, error) {
+	var (
+		terms  []string
+		term   strings.Builder
+		quoted bool
+	)
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			term.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+
+	return terms, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
@@ -0,0 +1,143 @@
+package images
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseQuery(t *testing.T) {
+	day := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		desc    string
+		expr    string
+		want    Query
+		wantErr bool
+	}{
+		{
+			desc: "ParseQuery() should push the name prefix, size and storage down to the filter",
+			expr: "name:cats/*.png AND size>2MB storage:archive tag:vacation tag:beach",
+			want: Query{
+				Filter: ListFilter{NamePrefix: "cats/", MinSize: 2<<20 + 1, Storage: "archive", Tags: []string{"vacation", "beach"}},
+				Names:  []string{"cats/*.png"},
+			},
+		},
+		{
+			desc: "ParseQuery() should match names containing a bare word",
+			expr: `vacation "beach day"`,
+			want: Query{Names: []string{"*vacation*", "*beach day*"}},
+		},
+		{
+			desc: "ParseQuery() should keep the narrowest size range",
+			expr: "size>=1KB size<=1.5kb size<1MB",
+			want: Query{Filter: ListFilter{MinSize: 1024, MaxSize: 1536}},
+		},
+		{
+			desc: "ParseQuery() should cover the whole day of a date",
+			expr: "created:2021-10-01",
+			want: Query{Filter: ListFilter{CreatedAfter: day, CreatedBefore: day.AddDate(0, 0, 1)}},
+		},
+		{
+			desc: "ParseQuery() should start after the day for created>",
+			expr: "created>2021-10-01 updated>=2021-10-01T00:00:00Z",
+			want: Query{Filter: ListFilter{CreatedAfter: day.AddDate(0, 0, 1), UpdatedSince: day}},
+		},
+		{
+			desc: "ParseQuery() should compare the capture time of photos",
+			expr: "captured>=2021-10-01 captured<2021-10-08",
+			want: Query{Filter: ListFilter{CapturedAfter: day, CapturedBefore: day.AddDate(0, 0, 7)}},
+		},
+		{
+			desc: "ParseQuery() should match the extracted text",
+			expr: `text:"total due" name:*.png`,
+			want: Query{Filter: ListFilter{Text: "total due"}, Names: []string{"*.png"}},
+		},
+		{
+			desc:    "ParseQuery() should reject a second text term",
+			expr:    "text:total text:due",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseQuery() should reject OR",
+			expr:    "name:a* OR name:b*",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseQuery() should reject unknown fields",
+			expr:    "color:red",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseQuery() should reject invalid sizes",
+			expr:    "size>big",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseQuery() should reject sizes below 1 byte",
+			expr:    "size<1",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseQuery() should reject unterminated quotes",
+			expr:    `name:"cat`,
+			wantErr: true,
+		},
+		{
+			desc:    "ParseQuery() should reject an empty query",
+			expr:    " ",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			q, err := ParseQuery(tc.expr)
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidQuery))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &tc.want, q)
+		})
+	}
+}
+
+func Test_Query_Match(t *testing.T) {
+	q, err := ParseQuery("name:*.png size>=10")
+	require.NoError(t, err)
+
+	assert.True(t, q.Match(&Record{Name: "cat.png", SizeInBytes: 10}))
+	assert.False(t, q.Match(&Record{Name: "cat.jpg", SizeInBytes: 10}))
+	assert.False(t, q.Match(&Record{Name: "cat.png", SizeInBytes: 9}))
+}
+
+func Test_ListFilter_Match_Checksum(t *testing.T) {
+	rec := Record{ETag: `"abc"`, SHA256: "def"}
+
+	assert.True(t, (&ListFilter{ETag: "abc"}).Match(&rec))
+	assert.True(t, (&ListFilter{ETag: `"abc"`, SHA256: "DEF"}).Match(&rec))
+	assert.False(t, (&ListFilter{ETag: "abd"}).Match(&rec))
+	assert.False(t, (&ListFilter{SHA256: "abc"}).Match(&rec))
+}
+
+func Test_ListFilter_Match_Captured(t *testing.T) {
+	day := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	f := ListFilter{CapturedAfter: day, CapturedBefore: day.AddDate(0, 0, 1)}
+
+	captured := day.Add(12 * time.Hour)
+	assert.True(t, f.Match(&Record{Exif: &Exif{CapturedAt: &captured}}))
+	captured = day.AddDate(0, 0, 1)
+	assert.False(t, f.Match(&Record{Exif: &Exif{CapturedAt: &captured}}))
+	// images without a capture time never match
+	assert.False(t, f.Match(&Record{Exif: &Exif{Make: "Canon"}}))
+	assert.False(t, f.Match(&Record{}))
+}
+
+func Test_ListFilter_Match_Text(t *testing.T) {
+	f := ListFilter{Text: "total due"}
+
+	assert.True(t, f.Match(&Record{Text: "INVOICE 42\nTotal Due: $10"}))
+	assert.False(t, f.Match(&Record{Text: "INVOICE 42\nTotal: $10"}))
+	assert.False(t, f.Match(&Record{}))
+}
@@ -0,0 +1,10 @@
+package service
+
+// WithBlurhash computes the blurhash of every uploaded jpeg, png, gif and
+// webp and records it on the image's record, see Record.Blurhash. Like
+// thumbnails it is not computed for encrypted images as it would reveal what they show.
+func WithBlurhash() Option {
+	return func(s *Service) {
+		s.blurhash = true
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+func Test_Service_Blurhash(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	body := buf.Bytes()
+
+	for _, tc := range []struct {
+		desc string
+		body []byte
+		want string
+	}{
+		{
+			desc: "Upload() should record the blurhash of the image",
+			body: body,
+			want: imaging.Blurhash(img),
+		},
+		{
+			desc: "Upload() should upload an image which fails to decode without a blurhash",
+			body: []byte("not a png"),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			r.EXPECT().GetByName(gomock.Any(), "b.png").Return(nil, images.ErrRecordNotFound)
+			s.EXPECT().Put(gomock.Any(), "images/2/b.png", gomock.Any(), gomock.Any()).Return(nil)
+			s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: int64(len(tc.body))}, nil)
+			s.EXPECT().GetRange(gomock.Any(), gomock.Any(), int64(0), int64(0), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+					n, err := w.Write(tc.body)
+					return int64(n), err
+				})
+			w.EXPECT().Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, rec *images.Record) error {
+					assert.Equal(t, tc.want, rec.Blurhash)
+					assert.Empty(t, rec.Thumbnails)
+					return nil
+				})
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithBlurhash(), WithIDGenerator(func() string { return "2" }))
+			require.NoError(t, err)
+
+			_, err = svc.Upload(context.Background(), images.UploadRequest{
+				Name:        "b.png",
+				Body:        bytes.NewReader(tc.body),
+				ContentType: "image/png",
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
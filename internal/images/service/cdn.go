@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// WithCDN invalidates the objects of the named storage on the CDN which
+// serves them whenever an image is deleted or overwritten, so that the edge
+// caches do not keep serving the old content.
+func WithCDN(storage string, dist images.CDN) Option {
+	return func(s *Service) {
+		s.cdn = &cdn{
+			dist:    dist,
+			storage: storage,
+		}
+	}
+}
+
+type cdn struct {
+	dist    images.CDN
+	storage string
+}
+
+// WithCDNURLs has the URLs of the images of the named storage point at the
+// CDN which serves them rather than at the storage, see PresignURL and
+// PublicURL.
+func WithCDNURLs(storage string, signer images.URLSigner) Option {
+	return func(s *Service) {
+		s.cdnURLs = &cdnURLs{
+			signer:  signer,
+			storage: storage,
+		}
+	}
+}
+
+type cdnURLs struct {
+	signer  images.URLSigner
+	storage string
+}
+
+// servedURLs returns the signer of the URLs of the record's object on the
+// CDN, or nil when the CDN does not serve the record's storage.
+func (s *Service) servedURLs(rec *images.Record) images.URLSigner {
+	if s.cdnURLs == nil || rec.Storage != s.cdnURLs.storage {
+		return nil
+	}
+
+	return s.cdnURLs.signer
+}
+
+// invalidate evicts the objects of the storage from the CDN when it serves
+// the storage. The change is already made in storage so failing to
+// invalidate only logs, the edge caches serve the old content until it
+// expires.
+func (s *Service) invalidate(ctx context.Context, storage string, keys []string, logger *zap.Logger) {
+	if s.cdn == nil || storage != s.cdn.storage || len(keys) == 0 {
+		return
+	}
+
+	if err := s.cdn.dist.Invalidate(ctx, keys); err != nil {
+		logger.Warn("unable to invalidate objects on cdn", zap.Strings("keys", keys), zap.Error(err))
+		return
+	}
+	logger.Info("invalidated objects on cdn", zap.Strings("keys", keys))
+}
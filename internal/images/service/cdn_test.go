@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_CDN(t *testing.T) {
+	created := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	existing := images.Record{
+		ID:        "1",
+		CreatedAt: &created,
+		Key:       "images/1/a.png",
+		Name:      "a.png",
+		Storage:   "sim",
+	}
+	for _, tc := range []struct {
+		desc    string
+		run     func(svc *Service) error
+		mocks   func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore, c *mock_images.MockCDN)
+		wantErr bool
+	}{
+		{
+			desc: "Delete() should invalidate the deleted object",
+			run:  func(svc *Service) error { return svc.Delete(context.Background(), existing.ID) },
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore, c *mock_images.MockCDN) {
+				rec := existing
+				r.EXPECT().Get(gomock.Any(), existing.ID).Return(&rec, nil)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Delete(gomock.Any(), existing.Key).Return(nil)
+				c.EXPECT().Invalidate(gomock.Any(), []string{existing.Key}).Return(nil)
+				w.EXPECT().Delete(gomock.Any(), existing.ID).Return(nil)
+			},
+		},
+		{
+			desc: "Delete() should not fail when the invalidation fails",
+			run:  func(svc *Service) error { return svc.Delete(context.Background(), existing.ID) },
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore, c *mock_images.MockCDN) {
+				rec := existing
+				r.EXPECT().Get(gomock.Any(), existing.ID).Return(&rec, nil)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Delete(gomock.Any(), existing.Key).Return(nil)
+				c.EXPECT().Invalidate(gomock.Any(), gomock.Any()).Return(errors.New("random"))
+				w.EXPECT().Delete(gomock.Any(), existing.ID).Return(nil)
+			},
+		},
+		{
+			desc: "Delete() should not invalidate an object which was not deleted",
+			run:  func(svc *Service) error { return svc.Delete(context.Background(), existing.ID) },
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore, c *mock_images.MockCDN) {
+				rec := existing
+				r.EXPECT().Get(gomock.Any(), existing.ID).Return(&rec, nil)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Delete(gomock.Any(), existing.Key).Return(errors.New("random"))
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Upload() should invalidate the object an overwrite replaced",
+			run: func(svc *Service) error {
+				_, err := svc.Upload(context.Background(), images.UploadRequest{
+					Name:        "a.png",
+					Body:        strings.NewReader("hw"),
+					ContentType: "image/png",
+					OnConflict:  images.ConflictOverwrite,
+				})
+				return err
+			},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore, c *mock_images.MockCDN) {
+				rec := existing
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(&rec, nil)
+				s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag2", SizeInBytes: 2}, nil)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Delete(gomock.Any(), existing.Key).Return(nil)
+				c.EXPECT().Invalidate(gomock.Any(), []string{existing.Key}).Return(nil)
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s, c := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl), mock_images.NewMockCDN(ctrl)
+			tc.mocks(r, w, s, c)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithCDN("sim", c))
+			require.NoError(t, err)
+
+			err = tc.run(svc)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_Service_invalidate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// the cdn only serves the default storage
+	c := mock_images.NewMockCDN(ctrl)
+	stores := images.Stores{"sim": mock_images.NewMockObjectStore(ctrl), "backup": mock_images.NewMockObjectStore(ctrl)}
+	svc, err := New(zap.NewNop(), "sim", mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), stores, WithCDN("sim", c))
+	require.NoError(t, err)
+
+	svc.invalidate(context.Background(), "backup", []string{"k"}, zap.NewNop())
+
+	_, err = New(zap.NewNop(), "sim", mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), stores, WithCDN("missing", c))
+	assert.Error(t, err)
+}
+
+func Test_Service_CDNURLs(t *testing.T) {
+	public := images.Record{ID: "1", Key: "key", Storage: "sim", Visibility: images.VisibilityPublic}
+	for _, tc := range []struct {
+		desc    string
+		run     func(svc *Service) (string, error)
+		rec     images.Record
+		mocks   func(s *mock_images.MockObjectStore, u *mock_images.MockURLSigner)
+		want    string
+		wantErr error
+	}{
+		{
+			desc: "PresignURL() should sign a url of the cdn",
+			run:  func(svc *Service) (string, error) { return svc.PresignURL(context.Background(), "1", time.Hour) },
+			rec:  images.Record{ID: "1", Key: "key", Storage: "sim"},
+			mocks: func(s *mock_images.MockObjectStore, u *mock_images.MockURLSigner) {
+				u.
+					EXPECT().
+					Sign("key", gomock.Any()).
+					DoAndReturn(func(_ string, expires time.Time) (string, error) {
+						assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+						return "https://cdn/key?Signature=sig", nil
+					})
+			},
+			want: "https://cdn/key?Signature=sig",
+		},
+		{
+			desc: "PresignURL() should presign with the storage when cdn urls are not signed",
+			run:  func(svc *Service) (string, error) { return svc.PresignURL(context.Background(), "1", time.Hour) },
+			rec:  images.Record{ID: "1", Key: "key", Storage: "sim"},
+			mocks: func(s *mock_images.MockObjectStore, u *mock_images.MockURLSigner) {
+				u.EXPECT().Sign("key", gomock.Any()).Return("", images.ErrUnsupported)
+				s.EXPECT().Presign(gomock.Any(), "key", time.Hour).Return("https://signed", nil)
+			},
+			want: "https://signed",
+		},
+		{
+			desc: "PresignURL() should return an error when signing fails",
+			run:  func(svc *Service) (string, error) { return svc.PresignURL(context.Background(), "1", time.Hour) },
+			rec:  images.Record{ID: "1", Key: "key", Storage: "sim"},
+			mocks: func(s *mock_images.MockObjectStore, u *mock_images.MockURLSigner) {
+				u.EXPECT().Sign("key", gomock.Any()).Return("", images.ErrInvalidExpiry)
+			},
+			wantErr: images.ErrInvalidExpiry,
+		},
+		{
+			desc: "PresignURL() should presign with a storage the cdn does not serve",
+			run:  func(svc *Service) (string, error) { return svc.PresignURL(context.Background(), "1", time.Hour) },
+			rec:  images.Record{ID: "1", Key: "key", Storage: "backup"},
+			mocks: func(s *mock_images.MockObjectStore, u *mock_images.MockURLSigner) {
+				s.EXPECT().Presign(gomock.Any(), "key", time.Hour).Return("https://signed", nil)
+			},
+			want: "https://signed",
+		},
+		{
+			desc: "PublicURL() should return the url of the cdn",
+			run:  func(svc *Service) (string, error) { return svc.PublicURL(context.Background(), "1") },
+			rec:  public,
+			mocks: func(s *mock_images.MockObjectStore, u *mock_images.MockURLSigner) {
+				u.EXPECT().URL("key").Return("https://cdn/key")
+			},
+			want: "https://cdn/key",
+		},
+		{
+			desc:    "PublicURL() should return ErrNotPublic when the image is private",
+			run:     func(svc *Service) (string, error) { return svc.PublicURL(context.Background(), "1") },
+			rec:     images.Record{ID: "1", Key: "key", Storage: "sim"},
+			mocks:   func(s *mock_images.MockObjectStore, u *mock_images.MockURLSigner) {},
+			wantErr: images.ErrNotPublic,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			rec := tc.rec
+			r := mock_images.NewMockReader(ctrl)
+			r.EXPECT().Get(gomock.Any(), "1").Return(&rec, nil)
+			s, u := mock_images.NewMockObjectStore(ctrl), mock_images.NewMockURLSigner(ctrl)
+			tc.mocks(s, u)
+			stores := images.Stores{"sim": s, "backup": s}
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), stores, WithCDNURLs("sim", u))
+			require.NoError(t, err)
+
+			got, err := tc.run(svc)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// compressibleTypes are the content types WithCompression compresses. Formats
+// which are compressed already, such as jpeg, png and gif, gain nothing.
+var compressibleTypes = []string{
+	"image/bmp",
+	"image/svg+xml",
+	"image/tiff",
+	"image/vnd.microsoft.icon",
+	"image/x-icon",
+	"text/*",
+}
+
+// WithCompression gzips the uploads whose content type compresses well, i.e.
+// svg, bmp and tiff, recording the encoding on the record. Downloads decode
+// the object transparently. The record's size is of the stored object and its
+// SHA-256 of the content before it was compressed.
+func WithCompression() Option {
+	return func(s *Service) {
+		s.compress = true
+	}
+}
+
+// gzipBody compresses the body into a temp file and returns the file at its
+// start, the cleanup func removes it.
+func gzipBody(body io.Reader, logger *zap.Logger) (io.Reader, func(), error) {
+	f, err := ioutil.TempFile("", "sim-compress-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	gz := gzip.NewWriter(f)
+	if _, err := io.Copy(gz, body); err != nil {
+		cleanup()
+		const msg = "unable to compress image"
+		logger.Error(msg, zap.Error(err))
+		return nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		cleanup()
+		const msg = "unable to compress image"
+		logger.Error(msg, zap.Error(err))
+		return nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		const msg = "unable to seek compressed image"
+		logger.Error(msg, zap.Error(err))
+		return nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+
+	return f, cleanup, nil
+}
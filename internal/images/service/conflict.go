@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// maxNameSuffix is the highest number ConflictSuffix tries before giving up
+// on finding a free name.
+const maxNameSuffix = 1000
+
+// resolveName applies the upload's conflict strategy when an image already
+// has its name. It returns the name to upload the image under and, for
+// ConflictOverwrite, the record of the image to replace. This is a best
+// effort check, concurrent uploads of the same name can both pass.
+func (s *Service) resolveName(ctx context.Context, r images.UploadRequest, logger *zap.Logger) (string, *images.Record, error) {
+	existing, err := s.named(ctx, r.Name, logger)
+	if err != nil || existing == nil {
+		return r.Name, nil, err
+	}
+
+	switch r.OnConflict {
+	case images.ConflictSuffix:
+		name, err := s.freeName(ctx, r.Name, logger)
+		return name, nil, err
+	case images.ConflictOverwrite:
+		logger.Info("name taken, overwriting the image", zap.String("existingId", existing.ID))
+		return r.Name, existing, nil
+	default:
+		logger.Error("name taken")
+		return "", nil, images.ErrNameTaken
+	}
+}
+
+// freeName returns the name suffixed with the first number from 2 which no
+// image has, before its extension so that cat.png becomes cat-2.png.
+func (s *Service) freeName(ctx context.Context, name string, logger *zap.Logger) (string, error) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" || strings.HasSuffix(base, "/") {
+		base, ext = name, ""
+	}
+
+	for n := 2; n <= maxNameSuffix; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+		existing, err := s.named(ctx, candidate, logger)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			logger.Info("name taken, suffixing it", zap.String("suffixedName", candidate))
+			return candidate, nil
+		}
+	}
+	logger.Error("no free name", zap.Int("maxSuffix", maxNameSuffix))
+
+	return "", images.ErrNameTaken
+}
+
+// named returns the record with the name, or nil if there is none.
+func (s *Service) named(ctx context.Context, name string, logger *zap.Logger) (*images.Record, error) {
+	rec, err := s.reader.GetByName(ctx, name)
+	switch err {
+	case nil:
+		return rec, nil
+	case images.ErrRecordNotFound:
+		return nil, nil
+	default:
+		const msg = "unable to check image name"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+}
+
+// save creates the record of an upload. When the upload overwrites an image
+// the image's record is updated to point at the new object instead, keeping
+// its ID, creation time and, unless the upload gives them, its path, tags and
+// metadata. Returns ErrConflict if the image was modified since it was read.
+func (s *Service) save(ctx context.Context, rec, replaced *images.Record, logger *zap.Logger) error {
+	if replaced == nil {
+		return s.writer.Create(ctx, rec)
+	}
+
+	rec.ID = replaced.ID
+	rec.CreatedAt = replaced.CreatedAt
+	rec.Revision = replaced.Revision
+	if rec.Path == "" {
+		rec.Path = replaced.Path
+	}
+	if len(rec.Tags) == 0 {
+		rec.Tags = replaced.Tags
+	}
+	if len(rec.Metadata) == 0 {
+		rec.Metadata = replaced.Metadata
+	}
+	if err := s.writer.Update(ctx, rec); err != nil {
+		return err
+	}
+	s.removeReplaced(ctx, replaced, rec, logger)
+
+	return nil
+}
+
+// removeReplaced removes the object an overwrite replaced unless the image
+// still points at it or another record references it, along with the
+// replaced thumbnails and variants. Failing to remove it only leaves an orphaned object
+// behind for GC. The replaced object is invalidated on the CDN, as is the
+// object at the image's key when the overwrite wrote over it in place.
+func (s *Service) removeReplaced(ctx context.Context, replaced, rec *images.Record, logger *zap.Logger) {
+	s.deleteDerived(ctx, replaced, rec, logger)
+	if replaced.Key == rec.Key && replaced.Storage == rec.Storage {
+		s.invalidate(ctx, rec.Storage, []string{rec.Key}, logger)
+		return
+	}
+	logger = logger.With(zap.String("replacedKey", replaced.Key))
+
+	if shared, err := s.shared(ctx, replaced, logger); err != nil || shared {
+		return
+	}
+	store, err := s.store(replaced.Storage, logger)
+	if err != nil {
+		return
+	}
+	if err := store.Delete(ctx, replaced.Key); err != nil && err != images.ErrObjectNotFound {
+		logger.Warn("unable to delete replaced object", zap.Error(err))
+		return
+	}
+	s.deleteMirrors(ctx, replaced, logger)
+	s.invalidate(ctx, replaced.Storage, []string{replaced.Key}, logger)
+}
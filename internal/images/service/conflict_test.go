@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Upload_Conflict(t *testing.T) {
+	created := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	existing := images.Record{
+		ID:        "1",
+		CreatedAt: &created,
+		Key:       "images/1/a.png",
+		Name:      "a.png",
+		Storage:   "sim",
+		Tags:      []string{"cats"},
+		Revision:  3,
+	}
+	for _, tc := range []struct {
+		desc       string
+		onConflict images.ConflictStrategy
+		id         string
+		replace    bool
+		mocks      func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+		wantID     string
+		wantErr    error
+	}{
+		{
+			desc:       "Upload() should reject an unknown conflict strategy",
+			onConflict: "rename",
+			mocks:      func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {},
+			wantErr:    images.ErrInvalidConflict,
+		},
+		{
+			desc:       "Upload() should reject an overwrite with an ID",
+			onConflict: images.ConflictOverwrite,
+			id:         "2",
+			mocks:      func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {},
+			wantErr:    images.ErrInvalidID,
+		},
+		{
+			desc:       "Upload() should reject a replace with another conflict strategy",
+			onConflict: images.ConflictSuffix,
+			replace:    true,
+			mocks:      func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {},
+			wantErr:    images.ErrInvalidConflict,
+		},
+		{
+			desc:    "Upload() should return ErrRecordNotFound when no image has the name to replace",
+			replace: true,
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(nil, images.ErrRecordNotFound)
+			},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc:    "Upload() should replace the image with the name",
+			replace: true,
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(&existing, nil)
+				s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag2", SizeInBytes: 2}, nil)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Delete(gomock.Any(), existing.Key).Return(nil)
+			},
+			wantID: existing.ID,
+		},
+		{
+			desc:       "Upload() should return ErrNameTaken when the strategy is error",
+			onConflict: images.ConflictError,
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(&existing, nil)
+			},
+			wantErr: images.ErrNameTaken,
+		},
+		{
+			desc:       "Upload() should upload under the first free suffixed name",
+			onConflict: images.ConflictSuffix,
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(&existing, nil)
+				r.EXPECT().GetByName(gomock.Any(), "a-2.png").Return(&images.Record{ID: "2"}, nil)
+				r.EXPECT().GetByName(gomock.Any(), "a-3.png").Return(nil, images.ErrRecordNotFound)
+				s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 2}, nil)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, "a-3.png", rec.Name)
+						assert.Contains(t, rec.Key, "a-3.png")
+						return nil
+					})
+			},
+		},
+		{
+			desc:       "Upload() should replace the object of the image and update its record in place",
+			onConflict: images.ConflictOverwrite,
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(&existing, nil)
+				s.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, key string, _ io.Reader, _ images.PutOptions) error {
+						assert.NotEqual(t, existing.Key, key)
+						return nil
+					})
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag2", SizeInBytes: 2}, nil)
+				w.
+					EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, existing.ID, rec.ID)
+						assert.Equal(t, existing.CreatedAt, rec.CreatedAt)
+						assert.Equal(t, existing.Revision, rec.Revision)
+						assert.Equal(t, existing.Tags, rec.Tags)
+						assert.Equal(t, "etag2", rec.ETag)
+						assert.NotEqual(t, existing.Key, rec.Key)
+						return nil
+					})
+				s.EXPECT().Delete(gomock.Any(), existing.Key).Return(nil)
+			},
+			wantID: existing.ID,
+		},
+		{
+			desc:       "Upload() should remove the new object when the image was modified concurrently",
+			onConflict: images.ConflictOverwrite,
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				var key string
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(&existing, nil)
+				s.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, k string, _ io.Reader, _ images.PutOptions) error {
+						key = k
+						return nil
+					})
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag2", SizeInBytes: 2}, nil)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(images.ErrConflict)
+				s.
+					EXPECT().
+					Delete(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, k string) error {
+						assert.Equal(t, key, k)
+						return nil
+					})
+			},
+			wantErr: images.ErrConflict,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			tc.mocks(r, w, s)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			id, err := svc.Upload(context.Background(), images.UploadRequest{
+				Name:        "a.png",
+				Body:        strings.NewReader("hw"),
+				ContentType: "image/png",
+				ID:          tc.id,
+				OnConflict:  tc.onConflict,
+				Replace:     tc.replace,
+			})
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tc.wantID != "" {
+				assert.Equal(t, tc.wantID, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// sniffLen is the number of leading bytes the content type is detected
+// from, all of those http.DetectContentType considers.
+const sniffLen = 512
+
+// WithAllowedTypes restricts uploads to the MIME types, i.e. image/png, or
+// type families, i.e. image/*. Uploads of other types are rejected with
+// ErrTypeNotAllowed before they are stored.
+func WithAllowedTypes(types ...string) Option {
+	return func(s *Service) {
+		s.allowed = types
+	}
+}
+
+// checkType returns ErrTypeNotAllowed when the upload's content type is not
+// allowed by the upload's allowlist, or the service's when it has none.
+func (s *Service) checkType(r images.UploadRequest, logger *zap.Logger) error {
+	allowed := s.allowed
+	if len(r.AllowedTypes) > 0 {
+		allowed = r.AllowedTypes
+	}
+	if len(allowed) == 0 || typeAllowed(r.ContentType, allowed) {
+		return nil
+	}
+
+	logger.Error("content type not allowed", zap.String("contentType", r.ContentType), zap.Strings("allowed", allowed))
+	return fmt.Errorf("%w: %s", images.ErrTypeNotAllowed, r.ContentType)
+}
+
+// typeAllowed reports whether the content type, without its parameters,
+// matches one of the allowed types or type families.
+func typeAllowed(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == mediaType || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// detectContentType returns the MIME type of the body sniffed from its first
+// bytes or, when those are not recognized, from the extension of the name.
+// The returned reader replays the body and must be used in its place.
+func detectContentType(name string, body io.Reader, logger *zap.Logger) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		const msg = "unable to read image"
+		logger.Error(msg, zap.Error(err))
+		return "", nil, fmt.Errorf(msg+": %w", err)
+	}
+	head = head[:n]
+
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		if _, err := seeker.Seek(int64(-n), io.SeekCurrent); err != nil {
+			const msg = "unable to seek image"
+			logger.Error(msg, zap.Error(err))
+			return "", nil, fmt.Errorf(msg+": %w", err)
+		}
+	} else {
+		body = io.MultiReader(bytes.NewReader(head), body)
+	}
+
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" || strings.HasPrefix(contentType, "text/plain") {
+		if byExt := mime.TypeByExtension(path.Ext(name)); byExt != "" {
+			contentType = byExt
+		}
+	}
+
+	return contentType, body, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func Test_detectContentType(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", sniffLen)
+	for _, tc := range []struct {
+		desc    string
+		name    string
+		content string
+		noSeek  bool
+		want    string
+	}{
+		{
+			desc:    "detectContentType() should sniff the type of a body which can seek",
+			name:    "cat",
+			content: png,
+			want:    "image/png",
+		},
+		{
+			desc:    "detectContentType() should sniff the type of a body which can not seek",
+			name:    "cat",
+			content: png,
+			noSeek:  true,
+			want:    "image/png",
+		},
+		{
+			desc:    "detectContentType() should fall back to the extension of the name",
+			name:    "cat.svg",
+			content: "<svg></svg>",
+			noSeek:  true,
+			want:    "image/svg+xml",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tc.content)
+			if tc.noSeek {
+				body = ioutil.NopCloser(body)
+			}
+
+			got, replay, err := detectContentType(tc.name, body, zap.NewNop())
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+
+			b, err := ioutil.ReadAll(replay)
+			require.NoError(t, err)
+			assert.Equal(t, tc.content, string(b))
+		})
+	}
+}
+
+func Test_typeAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		contentType string
+		allowed     []string
+		want        bool
+	}{
+		{
+			desc:        "typeAllowed() should allow a listed type",
+			contentType: "image/png",
+			allowed:     []string{"image/jpeg", "image/png"},
+			want:        true,
+		},
+		{
+			desc:        "typeAllowed() should ignore the parameters and case of the type",
+			contentType: "Image/SVG+xml; charset=utf-8",
+			allowed:     []string{"image/svg+xml"},
+			want:        true,
+		},
+		{
+			desc:        "typeAllowed() should allow the types of a listed family",
+			contentType: "image/webp",
+			allowed:     []string{"image/*"},
+			want:        true,
+		},
+		{
+			desc:        "typeAllowed() should reject a type which is not listed",
+			contentType: "application/zip",
+			allowed:     []string{"image/*"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, typeAllowed(tc.contentType, tc.allowed))
+		})
+	}
+}
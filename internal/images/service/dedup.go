@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// WithDedup stores identical content once. An upload whose SHA-256 matches
+// an image in the same storage references that image's object instead of
+// uploading another. Whether dedup is on or not, a delete only removes the
+// object once no other record references it, see shared.
+func WithDedup() Option {
+	return func(s *Service) {
+		s.dedup = true
+	}
+}
+
+// duplicate returns an image in the storage whose object has the SHA-256
+// digest and the visibility, or nil when there is none. An object can not be
+// both public and private so images of different visibility never share it.
+func (s *Service) duplicate(ctx context.Context, storage, sum string, public bool, logger *zap.Logger) (*images.Record, error) {
+	store, err := s.store(storage, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *images.Record
+	err = s.eachReference(ctx, images.ListFilter{Storage: storage, SHA256: sum}, func(rec *images.Record) (bool, error) {
+		if (images.Visibility(rec) == images.VisibilityPublic) != public {
+			return true, nil
+		}
+		// the object may have been removed since the record was read
+		_, err := store.Head(ctx, rec.Key)
+		switch err {
+		case nil:
+			found = rec
+			return false, nil
+		case images.ErrObjectNotFound:
+			return true, nil
+		default:
+			const msg = "unable to head object"
+			logger.Error(msg, zap.Error(err))
+			return false, fmt.Errorf(msg+": %w", err)
+		}
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// createReference creates the record of an upload whose content is the
+// existing image's, referencing its object instead of uploading another. When
+// the upload overwrites an image its record references the object instead.
+func (s *Service) createReference(ctx context.Context, r images.UploadRequest, imageID string, tags []string, existing, replaced *images.Record, sums *digests, moderation *images.Moderation, logger *zap.Logger) (string, error) {
+	now := time.Now().UTC()
+	image := images.Record{
+		ID:              imageID,
+		CreatedAt:       &now,
+		ETag:            existing.ETag,
+		Key:             existing.Key,
+		Name:            r.Name,
+		Path:            r.Path,
+		SizeInBytes:     existing.SizeInBytes,
+		ContentType:     r.ContentType,
+		ContentEncoding: existing.ContentEncoding,
+		EncryptedKey:    existing.EncryptedKey,
+		Storage:         existing.Storage,
+		SHA256:          sums.sha256,
+		Mirrors:         existing.Mirrors,
+		Metadata:        r.Metadata,
+		Exif:            existing.Exif.Copy(),
+		Blurhash:        existing.Blurhash,
+		Moderation:      moderation,
+		Text:            existing.Text,
+
+		ServerSideEncryption: existing.ServerSideEncryption,
+		KMSKeyID:             existing.KMSKeyID,
+		StorageClass:         existing.StorageClass,
+		CacheControl:         existing.CacheControl,
+		ContentDisposition:   existing.ContentDisposition,
+		ObjectMetadata:       existing.ObjectMetadata,
+		Visibility:           existing.Visibility,
+	}
+	if len(tags) > 0 {
+		image.Tags = tags
+	}
+	s.derive(ctx, &image, logger)
+	if err := s.save(ctx, &image, replaced, logger); err != nil {
+		const msg = "unable to save image record"
+		logger.Error(msg, zap.Error(err))
+		if r.ID != "" || r.IdempotencyKey != "" {
+			// a concurrent upload of the same ID may have created it first
+			if existing, _ := s.existing(ctx, imageID, logger); existing != nil {
+				return imageID, nil
+			}
+		}
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("successfully deduplicated upload", zap.String("existingId", existing.ID), zap.String("key", existing.Key))
+
+	return image.ID, nil
+}
+
+// shared reports whether another record references the record's object.
+// The references are derived from the records sharing the object so they can
+// not drift from them, and are checked even when dedup is off as images
+// uploaded while it was on may still share objects. Records without a
+// SHA-256 were never deduplicated.
+func (s *Service) shared(ctx context.Context, rec *images.Record, logger *zap.Logger) (bool, error) {
+	if rec.SHA256 == "" {
+		return false, nil
+	}
+
+	var refs int
+	err := s.eachReference(ctx, images.ListFilter{Storage: rec.Storage, SHA256: rec.SHA256}, func(other *images.Record) (bool, error) {
+		if other.ID != rec.ID && other.Key == rec.Key {
+			refs++
+		}
+		return refs == 0, nil
+	}, logger)
+	if err != nil {
+		return false, err
+	}
+
+	return refs > 0, nil
+}
+
+// eachReference calls fn with the records matching the filter which are not
+// being deleted until fn returns false.
+func (s *Service) eachReference(ctx context.Context, filter images.ListFilter, fn func(rec *images.Record) (bool, error), logger *zap.Logger) error {
+	opts := images.ListOptions{
+		Limit:  searchPageSize,
+		Filter: filter,
+	}
+	for {
+		page, err := s.reader.List(ctx, opts)
+		switch err {
+		case nil:
+		case images.ErrRecordNotFound:
+			return nil
+		default:
+			const msg = "unable to list records"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+
+		for i := range page.Records {
+			rec := &page.Records[i]
+			if rec.DeletingAt != nil || !filter.Match(rec) {
+				continue
+			}
+			more, err := fn(rec)
+			if err != nil || !more {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Upload_Dedup(t *testing.T) {
+	sum := sha256.Sum256([]byte("hw"))
+	digest := hex.EncodeToString(sum[:])
+	dups := images.ListOptions{Limit: searchPageSize, Filter: images.ListFilter{Storage: "sim", SHA256: digest}}
+	existing := images.Record{ID: "1", Key: "images/1/a.png", Name: "a.png", Storage: "sim", ETag: "etag", SizeInBytes: 2, SHA256: digest, Mirrors: []string{"mirror"}}
+	for _, tc := range []struct {
+		desc   string
+		public bool
+		mocks  func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+	}{
+		{
+			desc: "Upload() should reference the object of an image with the same content",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), dups).Return(&images.Page{Records: []images.Record{existing}}, nil)
+				s.EXPECT().Head(gomock.Any(), existing.Key).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 2}, nil)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, "b.png", rec.Name)
+						assert.Equal(t, existing.Key, rec.Key)
+						assert.Equal(t, existing.Mirrors, rec.Mirrors)
+						assert.Equal(t, digest, rec.SHA256)
+						return nil
+					})
+			},
+		},
+		{
+			desc: "Upload() should upload when the object of the image with the same content is gone",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), dups).Return(&images.Page{Records: []images.Record{existing}}, nil)
+				s.EXPECT().Head(gomock.Any(), existing.Key).Return(nil, images.ErrObjectNotFound)
+				s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 2}, nil)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.NotEqual(t, existing.Key, rec.Key)
+						assert.Equal(t, digest, rec.SHA256)
+						return nil
+					})
+			},
+		},
+		{
+			desc:   "Upload() should not reference the object of an image with another visibility",
+			public: true,
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), dups).Return(&images.Page{Records: []images.Record{existing}}, nil)
+				s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 2}, nil)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.NotEqual(t, existing.Key, rec.Key)
+						assert.Equal(t, images.VisibilityPublic, rec.Visibility)
+						return nil
+					})
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			r.EXPECT().GetByName(gomock.Any(), "b.png").Return(nil, images.ErrRecordNotFound)
+			tc.mocks(r, w, s)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithDedup())
+			require.NoError(t, err)
+
+			id, err := svc.Upload(context.Background(), images.UploadRequest{Name: "b.png", Body: strings.NewReader("hw"), Public: tc.public})
+			require.NoError(t, err)
+			assert.NotEmpty(t, id)
+		})
+	}
+}
+
+func Test_Service_Delete_Dedup(t *testing.T) {
+	rec := images.Record{ID: "1", Key: "images/1/a.png", Storage: "sim", SHA256: "sum"}
+	refs := images.ListOptions{Limit: searchPageSize, Filter: images.ListFilter{Storage: "sim", SHA256: "sum"}}
+	for _, tc := range []struct {
+		desc  string
+		opts  []Option
+		refs  []images.Record
+		mocks func(s *mock_images.MockObjectStore)
+	}{
+		{
+			desc:  "Delete() should keep an object another record references",
+			opts:  []Option{WithDedup()},
+			refs:  []images.Record{rec, {ID: "2", Key: rec.Key, Storage: "sim", SHA256: "sum"}},
+			mocks: func(s *mock_images.MockObjectStore) {},
+		},
+		{
+			desc:  "Delete() should keep an object another record references when dedup was turned off",
+			refs:  []images.Record{rec, {ID: "2", Key: rec.Key, Storage: "sim", SHA256: "sum"}},
+			mocks: func(s *mock_images.MockObjectStore) {},
+		},
+		{
+			desc: "Delete() should remove the object with its last reference",
+			opts: []Option{WithDedup()},
+			refs: []images.Record{rec, {ID: "2", Key: "images/2/b.png", Storage: "sim", SHA256: "sum"}},
+			mocks: func(s *mock_images.MockObjectStore) {
+				s.EXPECT().Delete(gomock.Any(), rec.Key).Return(nil)
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			deleting := rec
+			r.EXPECT().Get(gomock.Any(), rec.ID).Return(&deleting, nil)
+			w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+			r.EXPECT().List(gomock.Any(), refs).Return(&images.Page{Records: tc.refs}, nil)
+			tc.mocks(s)
+			w.EXPECT().Delete(gomock.Any(), rec.ID).Return(nil)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, tc.opts...)
+			require.NoError(t, err)
+
+			require.NoError(t, svc.Delete(context.Background(), rec.ID))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// encoded reports whether the record's object is not the image as uploaded,
+// i.e. it was compressed or encrypted, so its SHA-256 is of the decoded
+// content.
+func encoded(rec *images.Record) bool {
+	return rec.ContentEncoding != "" || rec.EncryptedKey != ""
+}
+
+// decode returns a reader of the image's content given a reader of its
+// object, decrypting and then decompressing the object as the record
+// requires.
+func (s *Service) decode(rec *images.Record, object io.Reader) (io.Reader, error) {
+	if rec.EncryptedKey != "" {
+		plain, err := s.decrypt(rec, object)
+		if err != nil {
+			return nil, err
+		}
+		object = plain
+	}
+
+	switch rec.ContentEncoding {
+	case "":
+		return object, nil
+	case images.EncodingGzip:
+		return gzip.NewReader(object)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", rec.ContentEncoding)
+	}
+}
+
+// decodeDownload decodes the n bytes of the encoded object into the stream,
+// checking the decoded content against the record's SHA-256.
+func (s *Service) decodeDownload(rec *images.Record, object io.ReaderAt, n int64, stream io.WriterAt, logger *zap.Logger) error {
+	content, err := s.decode(rec, io.NewSectionReader(object, 0, n))
+	if err != nil {
+		const msg = "unable to decode object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(images.NewOffsetWriter(stream), h), content); err != nil {
+		const msg = "unable to decode object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return checkDecoded(rec, h, logger)
+}
+
+// checkDecoded compares the SHA-256 of the decoded content with the
+// record's, if it has one.
+func checkDecoded(rec *images.Record, h hash.Hash, logger *zap.Logger) error {
+	if rec.SHA256 == "" {
+		return nil
+	}
+	if actual, expected := hex.EncodeToString(h.Sum(nil)), strings.ToLower(rec.SHA256); actual != expected {
+		logger.Error("decoded checksum does not match record", zap.String("expected", expected), zap.String("actual", actual))
+		return fmt.Errorf("%w: expected sha256 %s, decoded %s", images.ErrIntegrity, expected, actual)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_decodeDownload(t *testing.T) {
+	content := `<svg xmlns="http://www.w3.org/2000/svg"><rect width="4" height="4"/></svg>`
+	sum := sha256.Sum256([]byte(content))
+
+	for _, tc := range []struct {
+		desc    string
+		sha256  string
+		wantErr bool
+	}{
+		{
+			desc:   "decodeDownload() should decode a gzipped object into the stream",
+			sha256: hex.EncodeToString(sum[:]),
+		},
+		{
+			desc: "decodeDownload() should skip the check when the record has no sha256",
+		},
+		{
+			desc:    "decodeDownload() should return ErrIntegrity when the decoded content does not match",
+			sha256:  hex.EncodeToString(make([]byte, sha256.Size)),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			compressed, cleanup, err := gzipBody(bytes.NewReader([]byte(content)), zap.NewNop())
+			require.NoError(t, err)
+			defer cleanup()
+
+			object, err := ioutil.ReadAll(compressed)
+			require.NoError(t, err)
+			assert.NotEqual(t, content, string(object))
+
+			rec := &images.Record{ContentEncoding: images.EncodingGzip, SHA256: tc.sha256}
+			stream := manager.NewWriteAtBuffer([]byte{})
+			err = (&Service{}).decodeDownload(rec, bytes.NewReader(object), int64(len(object)), stream, zap.NewNop())
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, images.ErrIntegrity))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, content, string(stream.Bytes()))
+		})
+	}
+}
+
+func Test_decode(t *testing.T) {
+	_, err := (&Service{}).decode(&images.Record{ContentEncoding: "br"}, bytes.NewReader(nil))
+	assert.Error(t, err, "decode() should reject an unsupported encoding")
+
+	r := bytes.NewReader([]byte("abc"))
+	content, err := (&Service{}).decode(&images.Record{}, r)
+	require.NoError(t, err)
+	assert.Equal(t, r, content, "decode() should return the object when it has no encoding")
+}
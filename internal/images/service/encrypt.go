@@ -0,0 +1,212 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// encryptionChunkSize is the size of the plaintext sealed in each chunk of an
+// encrypted object.
+const encryptionChunkSize = 64 * 1024
+
+// WithEncryption encrypts uploads on the client so storage only holds
+// ciphertext. Each object is encrypted with AES-256-GCM under its own random
+// data key, which is encrypted with the 32 byte key and kept on the record.
+// Downloads decrypt the object transparently.
+func WithEncryption(key []byte) Option {
+	return func(s *Service) {
+		s.encryptionKey = key
+	}
+}
+
+// encryptBody encrypts the body with a new data key into a temp file and
+// returns the file at its start along with the encrypted data key, the
+// cleanup func removes it.
+func (s *Service) encryptBody(body io.Reader, logger *zap.Logger) (io.Reader, string, func(), error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		const msg = "unable to generate data key"
+		logger.Error(msg, zap.Error(err))
+		return nil, "", func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	encryptedKey, err := wrapKey(s.encryptionKey, dataKey)
+	if err != nil {
+		const msg = "unable to encrypt data key"
+		logger.Error(msg, zap.Error(err))
+		return nil, "", func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		const msg = "unable to create cipher"
+		logger.Error(msg, zap.Error(err))
+		return nil, "", func() {}, fmt.Errorf(msg+": %w", err)
+	}
+
+	f, err := ioutil.TempFile("", "sim-encrypt-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return nil, "", func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	if err := encryptStream(f, body, aead); err != nil {
+		cleanup()
+		const msg = "unable to encrypt image"
+		logger.Error(msg, zap.Error(err))
+		return nil, "", func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		const msg = "unable to seek encrypted image"
+		logger.Error(msg, zap.Error(err))
+		return nil, "", func() {}, fmt.Errorf(msg+": %w", err)
+	}
+
+	return f, encryptedKey, cleanup, nil
+}
+
+// decrypt returns a reader of the plaintext of the record's encrypted object.
+// Ciphertext which was modified or truncated fails the read with
+// ErrIntegrity.
+func (s *Service) decrypt(rec *images.Record, object io.Reader) (io.Reader, error) {
+	if s.encryptionKey == nil {
+		return nil, images.ErrNoEncryptionKey
+	}
+	dataKey, err := unwrapKey(s.encryptionKey, rec.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptingReader{
+		aead:  aead,
+		chunk: make([]byte, encryptionChunkSize+aead.Overhead()),
+		r:     object,
+	}, nil
+}
+
+// encryptStream seals the src in chunks, the last of which is shorter than
+// encryptionChunkSize, possibly empty, and marked final in its nonce so that
+// a truncated object does not decrypt.
+func encryptStream(dst io.Writer, src io.Reader, aead cipher.AEAD) error {
+	buf := make([]byte, encryptionChunkSize)
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(src, buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+		if _, err := dst.Write(aead.Seal(nil, chunkNonce(counter, final), buf[:n], nil)); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// decryptingReader opens the chunks written by encryptStream.
+type decryptingReader struct {
+	aead    cipher.AEAD
+	buf     []byte
+	chunk   []byte
+	counter uint64
+	done    bool
+	r       io.Reader
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(d.r, d.chunk)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return 0, err
+		}
+		plain, err := d.aead.Open(d.chunk[:0], chunkNonce(d.counter, final), d.chunk[:n], nil)
+		if err != nil {
+			return 0, fmt.Errorf("%w: unable to decrypt chunk %d", images.ErrIntegrity, d.counter)
+		}
+		d.buf, d.counter, d.done = plain, d.counter+1, final
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+
+	return n, nil
+}
+
+// chunkNonce returns the nonce of a chunk, its counter followed by whether it
+// is the final chunk. Every object has its own data key so the nonces are
+// never reused under a key.
+func chunkNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if final {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+// wrapKey encrypts the data key with the key, returning the random nonce
+// followed by the ciphertext base64 encoded.
+func wrapKey(key, dataKey []byte) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, dataKey, nil)), nil
+}
+
+// unwrapKey decrypts a data key encrypted by wrapKey.
+func unwrapKey(key []byte, encryptedKey string) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted key is too short")
+	}
+
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
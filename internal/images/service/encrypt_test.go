@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_Service_encryptBody(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	s := Service{encryptionKey: key}
+
+	for _, tc := range []struct {
+		desc    string
+		size    int
+		tamper  func(object []byte) []byte
+		wantErr bool
+	}{
+		{
+			desc: "decrypt() should decrypt an empty image",
+		},
+		{
+			desc: "decrypt() should decrypt an image smaller than a chunk",
+			size: 10,
+		},
+		{
+			desc: "decrypt() should decrypt an image of exactly one chunk",
+			size: encryptionChunkSize,
+		},
+		{
+			desc: "decrypt() should decrypt an image of several chunks",
+			size: 2*encryptionChunkSize + 1,
+		},
+		{
+			desc: "decrypt() should return ErrIntegrity when the ciphertext was modified",
+			size: 10,
+			tamper: func(object []byte) []byte {
+				object[0] ^= 1
+				return object
+			},
+			wantErr: true,
+		},
+		{
+			desc: "decrypt() should return ErrIntegrity when the object was truncated at a chunk",
+			size: 2 * encryptionChunkSize,
+			tamper: func(object []byte) []byte {
+				return object[:encryptionChunkSize+16]
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			content := make([]byte, tc.size)
+			_, err := rand.Read(content)
+			require.NoError(t, err)
+
+			encrypted, encryptedKey, cleanup, err := s.encryptBody(bytes.NewReader(content), zap.NewNop())
+			require.NoError(t, err)
+			defer cleanup()
+			object, err := ioutil.ReadAll(encrypted)
+			require.NoError(t, err)
+			if tc.tamper != nil {
+				object = tc.tamper(object)
+			}
+
+			plain, err := s.decrypt(&images.Record{EncryptedKey: encryptedKey}, bytes.NewReader(object))
+			require.NoError(t, err)
+			b, err := ioutil.ReadAll(plain)
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, images.ErrIntegrity))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, content, b)
+		})
+	}
+}
+
+func Test_Service_decrypt(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	encryptedKey, err := wrapKey(key, make([]byte, 32))
+	require.NoError(t, err)
+	rec := &images.Record{EncryptedKey: encryptedKey}
+
+	_, err = (&Service{}).decrypt(rec, bytes.NewReader(nil))
+	assert.Equal(t, images.ErrNoEncryptionKey, err, "decrypt() should return ErrNoEncryptionKey without a key")
+
+	_, err = (&Service{encryptionKey: make([]byte, 32)}).decrypt(rec, bytes.NewReader(nil))
+	assert.Error(t, err, "decrypt() should return an error when the data key was encrypted with another key")
+}
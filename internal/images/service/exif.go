@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+// exifTypes are the content types whose EXIF is read
+var exifTypes = []string{"image/jpeg", "image/png"}
+
+// readExif reads the EXIF of a jpeg or png body from its first bytes, see
+// imaging.ExifHeadLen. The returned reader replays the body and must be used
+// in its place. EXIF which can not be parsed is not recorded.
+func readExif(contentType string, body io.Reader, logger *zap.Logger) (*images.Exif, io.Reader, error) {
+	if !typeAllowed(contentType, exifTypes) {
+		return nil, body, nil
+	}
+
+	head := make([]byte, imaging.ExifHeadLen)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		const msg = "unable to read image"
+		logger.Error(msg, zap.Error(err))
+		return nil, nil, fmt.Errorf(msg+": %w", err)
+	}
+	head = head[:n]
+
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		if _, err := seeker.Seek(int64(-n), io.SeekCurrent); err != nil {
+			const msg = "unable to seek image"
+			logger.Error(msg, zap.Error(err))
+			return nil, nil, fmt.Errorf(msg+": %w", err)
+		}
+	} else {
+		body = io.MultiReader(bytes.NewReader(head), body)
+	}
+
+	return parseExif(head, logger), body, nil
+}
+
+// objectExif reads the EXIF of the record's jpeg or png object from its
+// first bytes, i.e. of a presigned upload which did not pass through sim.
+func (s *Service) objectExif(ctx context.Context, store images.ObjectStore, rec *images.Record, logger *zap.Logger) *images.Exif {
+	if !typeAllowed(rec.ContentType, exifTypes) || encoded(rec) {
+		return nil
+	}
+
+	var head bytes.Buffer
+	if _, err := store.GetRange(ctx, rec.Key, 0, imaging.ExifHeadLen, &head); err != nil {
+		logger.Warn("unable to download object header, not recording exif", zap.Error(err))
+		return nil
+	}
+
+	return parseExif(head.Bytes(), logger)
+}
+
+// parseExif returns the EXIF of the image whose first bytes are head, nil
+// when it has none or it can not be parsed.
+func parseExif(head []byte, logger *zap.Logger) *images.Exif {
+	x, err := imaging.ParseExif(head)
+	if err != nil {
+		logger.Warn("unable to parse exif, not recording it", zap.Error(err))
+		return nil
+	}
+	if x == nil {
+		return nil
+	}
+
+	exif := images.Exif{
+		Make:       x.Make,
+		Model:      x.Model,
+		LensMake:   x.LensMake,
+		LensModel:  x.LensModel,
+		CapturedAt: x.CapturedAt,
+	}
+	if x.Latitude != nil && x.Longitude != nil {
+		exif.GPS = &images.GPS{Latitude: *x.Latitude, Longitude: *x.Longitude}
+	}
+	if exif == (images.Exif{}) {
+		return nil
+	}
+	logger.Debug("read exif", zap.String("make", exif.Make), zap.String("model", exif.Model))
+
+	return &exif
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// canonJPEG returns the markers of a JPEG whose EXIF records a Canon camera.
+func canonJPEG() []byte {
+	// a little endian TIFF holding a directory of the make
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0}
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry, 0x010f)
+	binary.LittleEndian.PutUint16(entry[2:], 2)
+	binary.LittleEndian.PutUint32(entry[4:], 6)
+	binary.LittleEndian.PutUint32(entry[8:], 26)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, "Canon\x00"...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	b := []byte{0xff, 0xd8, 0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(b[4:], uint16(len(segment)+2))
+	b = append(b, segment...)
+
+	return append(b, 0xff, 0xd9)
+}
+
+func Test_readExif(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		contentType string
+		body        []byte
+		want        *images.Exif
+	}{
+		{
+			desc:        "readExif() should read the exif of a jpeg",
+			contentType: "image/jpeg",
+			body:        canonJPEG(),
+			want:        &images.Exif{Make: "Canon"},
+		},
+		{
+			desc:        "readExif() should not read the exif of other types",
+			contentType: "image/gif",
+			body:        canonJPEG(),
+		},
+		{
+			desc:        "readExif() should not record exif which can not be parsed",
+			contentType: "image/jpeg",
+			body:        append([]byte{0xff, 0xd8, 0xff, 0xe1, 0, 8}, "Exif\x00\x00"...),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			// the body is replayed whether or not it can be seeked
+			for _, body := range []io.Reader{
+				bytes.NewReader(tc.body),
+				bytes.NewBuffer(tc.body),
+			} {
+				exif, replayed, err := readExif(tc.contentType, body, zap.NewNop())
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, exif)
+				b, err := ioutil.ReadAll(replayed)
+				require.NoError(t, err)
+				assert.Equal(t, tc.body, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// FindByChecksum returns the images whose object has the SHA-256 digest or
+// the ETag, the empty checksums are not looked up. Returns
+// ErrRecordNotFound if no images match.
+func (s *Service) FindByChecksum(ctx context.Context, sha256, etag string) ([]images.Image, error) {
+	var filters []images.ListFilter
+	if sha256 != "" {
+		filters = append(filters, images.ListFilter{SHA256: sha256})
+	}
+	if etag != "" {
+		filters = append(filters, images.ListFilter{ETag: etag})
+	}
+
+	var (
+		found []images.Image
+		seen  = make(map[string]bool)
+	)
+	for i := range filters {
+		matched, err := s.Search(ctx, &images.Query{Filter: filters[i]}, images.ListOptions{})
+		switch err {
+		case nil:
+		case images.ErrRecordNotFound:
+			continue
+		default:
+			return nil, err
+		}
+
+		for _, image := range matched {
+			if seen[image.ID] {
+				continue
+			}
+			seen[image.ID] = true
+			found = append(found, image)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, images.ErrRecordNotFound
+	}
+
+	return found, nil
+}
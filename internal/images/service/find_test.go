@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_FindByChecksum(t *testing.T) {
+	bySHA := images.ListOptions{Limit: searchPageSize, Filter: images.ListFilter{SHA256: "sha"}}
+	byETag := images.ListOptions{Limit: searchPageSize, Filter: images.ListFilter{ETag: "etag"}}
+	for _, tc := range []struct {
+		desc    string
+		reader  func(ctrl *gomock.Controller) images.Reader
+		want    []images.Image
+		wantErr error
+	}{
+		{
+			desc: "FindByChecksum() should return an error when failing to list the records",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().List(gomock.Any(), bySHA).Return(nil, errors.New("random"))
+
+				return r
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc: "FindByChecksum() should return ErrRecordNotFound when no record matches",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().List(gomock.Any(), bySHA).Return(nil, images.ErrRecordNotFound)
+				r.EXPECT().List(gomock.Any(), byETag).Return(nil, images.ErrRecordNotFound)
+
+				return r
+			},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc: "FindByChecksum() should merge the records matching either checksum",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					List(gomock.Any(), bySHA).
+					Return(&images.Page{Records: []images.Record{{ID: "1", SHA256: "sha", ETag: "etag"}}}, nil)
+				r.
+					EXPECT().
+					List(gomock.Any(), byETag).
+					Return(&images.Page{Records: []images.Record{{ID: "1", SHA256: "sha", ETag: "etag"}, {ID: "2", ETag: `"etag"`}}}, nil)
+
+				return r
+			},
+			want: []images.Image{{ID: "1", Size: "0 B", SHA256: "sha", Visibility: images.VisibilityPrivate}, {ID: "2", Size: "0 B", Visibility: images.VisibilityPrivate}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			svc, err := New(zap.NewNop(), "sim", tc.reader(ctrl), mock_images.NewMockWriter(ctrl), images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)})
+			require.NoError(t, err)
+
+			got, err := svc.FindByChecksum(context.Background(), "sha", "etag")
+			switch {
+			case tc.wantErr == images.ErrRecordNotFound:
+				assert.Equal(t, tc.wantErr, err)
+			case tc.wantErr != nil:
+				assert.Error(t, err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
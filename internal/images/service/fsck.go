@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// Fsck checks every record matching the filter against its object. The
+// record's required fields must be set, its object must exist with the
+// recorded size and ETag and its mirrors must hold a copy. Records being
+// deleted are left to ReconcileDeletes. The problems found are returned in
+// the report rather than as an error.
+func (s *Service) Fsck(ctx context.Context, filter images.ListFilter) (*images.FsckReport, error) {
+	s.logger.Info("attempting to check records")
+
+	opts := images.ListOptions{
+		Limit:  searchPageSize,
+		Filter: filter,
+	}
+	report := images.FsckReport{Inconsistencies: []images.Inconsistency{}}
+	for {
+		page, err := s.reader.List(ctx, opts)
+		switch err {
+		case nil:
+		case images.ErrRecordNotFound:
+			page = new(images.Page)
+		default:
+			const msg = "unable to list records"
+			s.logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+
+		for i := range page.Records {
+			rec := &page.Records[i]
+			if !filter.Match(rec) || rec.DeletingAt != nil || rec.PendingUntil != nil {
+				continue
+			}
+			report.Checked++
+			report.Inconsistencies = append(report.Inconsistencies, s.check(ctx, rec)...)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	s.logger.Info(
+		"successfully checked records",
+		zap.Int("checked", report.Checked),
+		zap.Int("inconsistencies", len(report.Inconsistencies)),
+	)
+
+	return &report, nil
+}
+
+// check returns the inconsistencies of the record.
+func (s *Service) check(ctx context.Context, rec *images.Record) []images.Inconsistency {
+	var found []images.Inconsistency
+	for _, f := range []struct {
+		name    string
+		missing bool
+	}{
+		{name: "key", missing: rec.Key == ""},
+		{name: "name", missing: rec.Name == ""},
+		{name: "storage", missing: rec.Storage == ""},
+		{name: "createdAt", missing: rec.CreatedAt == nil},
+		{name: "etag", missing: rec.ETag == ""},
+	} {
+		if f.missing {
+			found = append(found, images.Inconsistency{ImageID: rec.ID, Problem: images.ProblemMissingField, Field: f.name})
+		}
+	}
+	if rec.Key == "" || rec.Storage == "" {
+		return found
+	}
+
+	found = append(found, s.checkObject(ctx, rec, rec.Storage, images.ProblemMissingObject)...)
+	for _, mirror := range rec.Mirrors {
+		found = append(found, s.checkObject(ctx, rec, mirror, images.ProblemMissingMirror)...)
+	}
+
+	return found
+}
+
+// checkObject returns the inconsistencies between the record and its object
+// in the storage, missing is the problem reported when there is no object.
+func (s *Service) checkObject(ctx context.Context, rec *images.Record, storage string, missing images.Problem) []images.Inconsistency {
+	base := images.Inconsistency{ImageID: rec.ID, Storage: storage, Key: rec.Key}
+
+	store, ok := s.stores[storage]
+	if !ok {
+		base.Problem = images.ProblemUnknownStorage
+		return []images.Inconsistency{base}
+	}
+
+	info, err := store.Head(ctx, rec.Key)
+	switch err {
+	case nil:
+	case images.ErrObjectNotFound:
+		base.Problem = missing
+		return []images.Inconsistency{base}
+	default:
+		base.Problem = images.ProblemCheckFailed
+		base.Error = err.Error()
+		return []images.Inconsistency{base}
+	}
+
+	var found []images.Inconsistency
+	if info.SizeInBytes != rec.SizeInBytes {
+		i := base
+		i.Problem = images.ProblemSizeMismatch
+		i.Expected = strconv.FormatInt(rec.SizeInBytes, 10)
+		i.Actual = strconv.FormatInt(info.SizeInBytes, 10)
+		found = append(found, i)
+	}
+	if rec.ETag != "" && images.TrimETag(info.ETag) != images.TrimETag(rec.ETag) {
+		i := base
+		i.Problem = images.ProblemETagMismatch
+		i.Expected = rec.ETag
+		i.Actual = info.ETag
+		found = append(found, i)
+	}
+
+	return found
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Fsck(t *testing.T) {
+	now := time.Now()
+	rec := images.Record{ID: "1", Key: "images/1/a.png", Name: "a.png", Storage: "sim", CreatedAt: &now, ETag: `"etag"`, SizeInBytes: 10}
+	all := images.ListOptions{Limit: searchPageSize}
+	for _, tc := range []struct {
+		desc    string
+		mocks   func(r *mock_images.MockReader, s *mock_images.MockObjectStore)
+		want    *images.FsckReport
+		wantErr bool
+	}{
+		{
+			desc: "Fsck() should return an error when failing to list the records",
+			mocks: func(r *mock_images.MockReader, s *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), all).Return(nil, errors.New("random"))
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Fsck() should report nothing for consistent records",
+			mocks: func(r *mock_images.MockReader, s *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), all).Return(&images.Page{Records: []images.Record{rec}}, nil)
+				s.EXPECT().Head(gomock.Any(), rec.Key).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 10}, nil)
+			},
+			want: &images.FsckReport{Checked: 1, Inconsistencies: []images.Inconsistency{}},
+		},
+		{
+			desc: "Fsck() should report the inconsistencies of every record",
+			mocks: func(r *mock_images.MockReader, s *mock_images.MockObjectStore) {
+				mismatched := rec
+				missing := rec
+				missing.ID, missing.Key, missing.CreatedAt, missing.Mirrors = "2", "images/2/b.png", nil, []string{"other"}
+				failed := rec
+				failed.ID, failed.Key = "3", "images/3/c.png"
+				deleting := rec
+				deleting.ID, deleting.DeletingAt = "4", &now
+				r.
+					EXPECT().
+					List(gomock.Any(), all).
+					Return(&images.Page{Records: []images.Record{mismatched, missing, failed, deleting}}, nil)
+				s.EXPECT().Head(gomock.Any(), rec.Key).Return(&images.ObjectInfo{ETag: "other", SizeInBytes: 5}, nil)
+				s.EXPECT().Head(gomock.Any(), missing.Key).Return(nil, images.ErrObjectNotFound)
+				s.EXPECT().Head(gomock.Any(), failed.Key).Return(nil, errors.New("random"))
+			},
+			want: &images.FsckReport{
+				Checked: 3,
+				Inconsistencies: []images.Inconsistency{
+					{ImageID: "1", Problem: images.ProblemSizeMismatch, Storage: "sim", Key: rec.Key, Expected: "10", Actual: "5"},
+					{ImageID: "1", Problem: images.ProblemETagMismatch, Storage: "sim", Key: rec.Key, Expected: `"etag"`, Actual: "other"},
+					{ImageID: "2", Problem: images.ProblemMissingField, Field: "createdAt"},
+					{ImageID: "2", Problem: images.ProblemMissingObject, Storage: "sim", Key: "images/2/b.png"},
+					{ImageID: "2", Problem: images.ProblemUnknownStorage, Storage: "other", Key: "images/2/b.png"},
+					{ImageID: "3", Problem: images.ProblemCheckFailed, Storage: "sim", Key: "images/3/c.png", Error: "random"},
+				},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, s := mock_images.NewMockReader(ctrl), mock_images.NewMockObjectStore(ctrl)
+			tc.mocks(r, s)
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			got, err := svc.Fsck(context.Background(), images.ListFilter{})
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// GC reconciles the objects in storage with the image records. Objects which
+// no record points to are orphans and records whose object no longer exists
+// are dangling, both are deleted unless r.DryRun is set. Records being
+// deleted are left to ReconcileDeletes. Pending records of presigned uploads
+// are dangling once their upload expired more than r.Grace ago without an
+// object.
+func (s *Service) GC(ctx context.Context, r images.GCRequest) (*images.GCResult, error) {
+	logger := s.logger.With(zap.String("storage", r.Storage), zap.Bool("dryRun", r.DryRun))
+	logger.Info("attempting to collect garbage")
+
+	storages := make([]string, 0, len(s.stores))
+	for name := range s.stores {
+		if r.Storage == "" || name == r.Storage {
+			storages = append(storages, name)
+		}
+	}
+	if len(storages) == 0 {
+		logger.Error("storage not found")
+		return nil, fmt.Errorf("%w: %s", images.ErrStorageNotFound, r.Storage)
+	}
+	sort.Strings(storages)
+
+	records, err := images.ListAll(ctx, s.reader)
+	if err != nil && err != images.ErrRecordNotFound {
+		const msg = "unable to list records"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	// the keys each storage holds for the records, including mirrored copies
+	referenced := make(map[string]map[string]bool, len(storages))
+	for _, name := range storages {
+		referenced[name] = make(map[string]bool)
+	}
+	for i := range records {
+		for _, name := range append([]string{records[i].Storage}, records[i].Mirrors...) {
+			if keys, ok := referenced[name]; ok {
+				keys[records[i].Key] = true
+			}
+		}
+		// thumbnails and variants are only kept in the image's storage
+		if keys, ok := referenced[records[i].Storage]; ok {
+			for _, key := range images.DerivedKeys(&records[i]) {
+				keys[key] = true
+			}
+		}
+	}
+
+	var res images.GCResult
+	cutoff := time.Now().Add(-r.Grace)
+	listed := make(map[string]map[string]bool, len(storages))
+	for _, name := range storages {
+		// objects outside of the keys' prefix are not managed by sim and
+		// never collected
+		objects, err := s.stores[name].List(ctx, s.keys.Prefix())
+		if err != nil {
+			const msg = "unable to list objects"
+			logger.Error(msg, zap.String("storage", name), zap.Error(err))
+			return nil, fmt.Errorf(msg+" in storage (%s): %w", name, err)
+		}
+
+		listed[name] = make(map[string]bool, len(objects))
+		for _, obj := range objects {
+			listed[name][obj.Key] = true
+			if referenced[name][obj.Key] || obj.LastModified.After(cutoff) {
+				continue
+			}
+			res.Orphans = append(res.Orphans, images.OrphanObject{
+				Storage:     name,
+				Key:         obj.Key,
+				SizeInBytes: obj.SizeInBytes,
+			})
+		}
+	}
+
+	for i := range records {
+		rec := &records[i]
+		keys, ok := listed[rec.Storage]
+		if !ok || rec.DeletingAt != nil || keys[rec.Key] {
+			continue
+		}
+		if rec.PendingUntil != nil && rec.PendingUntil.After(cutoff) {
+			// the client may still upload the object
+			continue
+		}
+		if !strings.HasPrefix(rec.Key, s.keys.Prefix()) {
+			// keys outside of the prefix were not listed
+			if _, err := s.stores[rec.Storage].Head(ctx, rec.Key); err != images.ErrObjectNotFound {
+				continue
+			}
+		}
+		res.Dangling = append(res.Dangling, rec.ID)
+	}
+
+	if !r.DryRun {
+		s.collect(ctx, &res, logger)
+	}
+	logger.Info(
+		"successfully collected garbage",
+		zap.Int("orphans", len(res.Orphans)),
+		zap.Int("dangling", len(res.Dangling)),
+		zap.Int("failed", len(res.Failed)),
+	)
+
+	return &res, nil
+}
+
+// collect deletes the orphaned objects and dangling records of the result,
+// recording the ones which could not be deleted as failed.
+func (s *Service) collect(ctx context.Context, res *images.GCResult, logger *zap.Logger) {
+	for _, orphan := range res.Orphans {
+		if err := s.stores[orphan.Storage].Delete(ctx, orphan.Key); err != nil {
+			logger.Error("unable to delete orphaned object", zap.String("key", orphan.Key), zap.Error(err))
+			res.Failed = append(res.Failed, orphan.Key)
+		}
+	}
+
+	for _, id := range res.Dangling {
+		if err := s.writer.Delete(ctx, id); err != nil && err != images.ErrRecordNotFound {
+			logger.Error("unable to delete dangling record", zap.String("imageId", id), zap.Error(err))
+			res.Failed = append(res.Failed, id)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_GC(t *testing.T) {
+	old, recent := time.Now().Add(-time.Hour), time.Now()
+	records := []images.Record{
+		{ID: "1", Key: "images/1/a.png", Storage: "sim", Mirrors: []string{"mirror"}},
+		{ID: "2", Key: "images/2/b.png", Storage: "sim"},
+		{ID: "3", Key: "images/3/c.png", Storage: "sim", DeletingAt: &old},
+	}
+	objects := []images.ObjectInfo{
+		{Key: "images/1/a.png", LastModified: old},
+		{Key: "images/4/d.png", SizeInBytes: 10, LastModified: old},
+		{Key: "images/5/e.png", LastModified: recent},
+	}
+	for _, tc := range []struct {
+		desc    string
+		req     images.GCRequest
+		mocks   func(r *mock_images.MockReader, w *mock_images.MockWriter, s, m *mock_images.MockObjectStore)
+		want    *images.GCResult
+		wantErr bool
+	}{
+		{
+			desc: "GC() should return ErrStorageNotFound when the storage is not configured",
+			req:  images.GCRequest{Storage: "other"},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s, m *mock_images.MockObjectStore) {
+			},
+			wantErr: true,
+		},
+		{
+			desc: "GC() should return an error when failing to list the objects",
+			req:  images.GCRequest{Storage: "sim"},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s, m *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), gomock.Any()).Return(&images.Page{Records: records}, nil)
+				s.EXPECT().List(gomock.Any(), "images/").Return(nil, errors.New("random"))
+			},
+			wantErr: true,
+		},
+		{
+			desc: "GC() should only report the orphans and dangling records on a dry run",
+			req:  images.GCRequest{Storage: "sim", Grace: time.Minute, DryRun: true},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s, m *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), gomock.Any()).Return(&images.Page{Records: records}, nil)
+				s.EXPECT().List(gomock.Any(), "images/").Return(objects, nil)
+			},
+			want: &images.GCResult{
+				Orphans:  []images.OrphanObject{{Storage: "sim", Key: "images/4/d.png", SizeInBytes: 10}},
+				Dangling: []string{"2"},
+			},
+		},
+		{
+			desc: "GC() should delete the orphans and dangling records of every storage",
+			req:  images.GCRequest{Grace: time.Minute},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s, m *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), gomock.Any()).Return(&images.Page{Records: records}, nil)
+				m.EXPECT().List(gomock.Any(), "images/").Return([]images.ObjectInfo{{Key: "images/1/a.png", LastModified: old}}, nil)
+				s.EXPECT().List(gomock.Any(), "images/").Return(objects, nil)
+				s.EXPECT().Delete(gomock.Any(), "images/4/d.png").Return(errors.New("random"))
+				w.EXPECT().Delete(gomock.Any(), "2").Return(nil)
+			},
+			want: &images.GCResult{
+				Orphans:  []images.OrphanObject{{Storage: "sim", Key: "images/4/d.png", SizeInBytes: 10}},
+				Dangling: []string{"2"},
+				Failed:   []string{"images/4/d.png"},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl)
+			s, m := mock_images.NewMockObjectStore(ctrl), mock_images.NewMockObjectStore(ctrl)
+			tc.mocks(r, w, s, m)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s, "mirror": m})
+			require.NoError(t, err)
+
+			got, err := svc.GC(context.Background(), tc.req)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"mime"
+	"path"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// WithCacheControl uploads objects with the Cache-Control header, i.e.
+// max-age=86400, unless the upload sets its own.
+func WithCacheControl(cacheControl string) Option {
+	return func(s *Service) {
+		s.cacheControl = cacheControl
+	}
+}
+
+// WithContentDisposition uploads objects with the Content-Disposition header
+// unless the upload sets its own, inline or attachment are completed with the
+// image's name as the filename.
+func WithContentDisposition(disposition string) Option {
+	return func(s *Service) {
+		s.contentDisposition = disposition
+	}
+}
+
+// withHeaders sets the Cache-Control and Content-Disposition of the upload's
+// put options, the request's replace the service's.
+func (s *Service) withHeaders(r images.UploadRequest, opts images.PutOptions) images.PutOptions {
+	opts.CacheControl, opts.ContentDisposition = s.cacheControl, s.contentDisposition
+	if r.CacheControl != "" {
+		opts.CacheControl = r.CacheControl
+	}
+	if r.ContentDisposition != "" {
+		opts.ContentDisposition = r.ContentDisposition
+	}
+	opts.ContentDisposition = contentDisposition(opts.ContentDisposition, r.Name)
+
+	return opts
+}
+
+// contentDisposition completes a bare inline or attachment disposition with
+// the base of the image's name as the filename, encoding names which are not
+// plain ASCII as RFC 2231 requires. Other dispositions are returned as is.
+func contentDisposition(disposition, name string) string {
+	if disposition != "inline" && disposition != "attachment" {
+		return disposition
+	}
+	if v := mime.FormatMediaType(disposition, map[string]string{"filename": path.Base(name)}); v != "" {
+		return v
+	}
+
+	return disposition
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_Service_withHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		service Service
+		request images.UploadRequest
+		want    images.PutOptions
+	}{
+		{
+			desc:    "withHeaders() should leave the headers empty without settings",
+			request: images.UploadRequest{Name: "a.png"},
+		},
+		{
+			desc:    "withHeaders() should use the service's headers",
+			service: Service{cacheControl: "max-age=60", contentDisposition: "inline"},
+			request: images.UploadRequest{Name: "a.png"},
+			want:    images.PutOptions{CacheControl: "max-age=60", ContentDisposition: `inline; filename=a.png`},
+		},
+		{
+			desc:    "withHeaders() should replace the service's headers with the request's",
+			service: Service{cacheControl: "max-age=60", contentDisposition: "inline"},
+			request: images.UploadRequest{Name: "photos/a b.png", CacheControl: "no-cache", ContentDisposition: "attachment"},
+			want:    images.PutOptions{CacheControl: "no-cache", ContentDisposition: `attachment; filename="a b.png"`},
+		},
+		{
+			desc:    "withHeaders() should encode a filename which is not ascii",
+			request: images.UploadRequest{Name: "café.png", ContentDisposition: "attachment"},
+			want:    images.PutOptions{ContentDisposition: `attachment; filename*=utf-8''caf%C3%A9.png`},
+		},
+		{
+			desc:    "withHeaders() should keep a complete disposition",
+			request: images.UploadRequest{Name: "a.png", ContentDisposition: `attachment; filename="b.png"`},
+			want:    images.PutOptions{ContentDisposition: `attachment; filename="b.png"`},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.service.withHeaders(tc.request, images.PutOptions{}))
+		})
+	}
+}
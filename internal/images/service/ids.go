@@ -0,0 +1,14 @@
+package service
+
+import (
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// WithIDGenerator generates the IDs of uploads with gen instead of
+// images.NewUUID, i.e. images.NewULID so records sort by their upload. IDs
+// given by the upload or derived from its idempotency key are unaffected.
+func WithIDGenerator(gen images.IDGenerator) Option {
+	return func(s *Service) {
+		s.newID = gen
+	}
+}
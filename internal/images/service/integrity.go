@@ -0,0 +1,107 @@
+package service
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// checkIntegrity compares the n bytes downloaded to the stream with the
+// record. The content is only hashed when the stream can be read back, i.e.
+// a file, see integrityHash.
+func checkIntegrity(rec *images.Record, stream io.WriterAt, n int64, logger *zap.Logger) error {
+	if err := checkSize(rec, n, logger); err != nil {
+		return err
+	}
+
+	readerAt, ok := stream.(io.ReaderAt)
+	if !ok {
+		return nil
+	}
+	name, h, expected := integrityHash(rec)
+	if h == nil {
+		return nil
+	}
+
+	if _, err := io.Copy(h, io.NewSectionReader(readerAt, 0, n)); err != nil {
+		const msg = "unable to checksum download"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return checkSum(name, h, expected, logger)
+}
+
+// checkSize compares the n bytes downloaded with the record's size.
+func checkSize(rec *images.Record, n int64, logger *zap.Logger) error {
+	if n != rec.SizeInBytes {
+		logger.Error("downloaded size does not match record", zap.Int64("expected", rec.SizeInBytes), zap.Int64("actual", n))
+		return fmt.Errorf("%w: expected %d bytes, downloaded %d", images.ErrIntegrity, rec.SizeInBytes, n)
+	}
+
+	return nil
+}
+
+// integrityHash returns the name of the checksum the record's object is
+// checked with, a hash to compute it and the expected hex digest, preferring
+// the SHA-256 over an ETag which is an MD5. The SHA-256 of an encoded object
+// is of its decoded content so only its ETag is used. The hash is nil when
+// the record has neither.
+func integrityHash(rec *images.Record) (string, hash.Hash, string) {
+	etag, isMD5 := md5ETag(rec.ETag, rec.ServerSideEncryption)
+	switch {
+	case rec.SHA256 != "" && !encoded(rec):
+		return "sha256", sha256.New(), strings.ToLower(rec.SHA256)
+	case isMD5:
+		return "etag", md5.New(), strings.ToLower(etag)
+	default:
+		return "", nil, ""
+	}
+}
+
+// checkSum compares the digest of the hash with the expected one.
+func checkSum(name string, h hash.Hash, expected string, logger *zap.Logger) error {
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		logger.Error("downloaded checksum does not match record", zap.String("checksum", name), zap.String("expected", expected), zap.String("actual", actual))
+		return fmt.Errorf("%w: expected %s %s, downloaded %s", images.ErrIntegrity, name, expected, actual)
+	}
+
+	return nil
+}
+
+// hashingReader computes the SHA-256 digest of the bytes read through it.
+type hashingReader struct {
+	r    io.Reader
+	h    hash.Hash
+	size int64
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{r: r, h: sha256.New()}
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.size += int64(n)
+
+	return n, err
+}
+
+// sum returns the hex encoded digest when exactly size bytes were read,
+// otherwise the body was not read in full and the digest is unknown.
+func (r *hashingReader) sum(size int64) (string, bool) {
+	if r.size != size {
+		return "", false
+	}
+
+	return hex.EncodeToString(r.h.Sum(nil)), true
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_hashingReader(t *testing.T) {
+	sum := sha256.Sum256([]byte("hw"))
+	for _, tc := range []struct {
+		desc   string
+		read   int64
+		want   string
+		wantOK bool
+	}{
+		{
+			desc:   "sum() should return the digest of a body read in full",
+			read:   2,
+			want:   hex.EncodeToString(sum[:]),
+			wantOK: true,
+		},
+		{
+			desc: "sum() should return false when the body was not read in full",
+			read: 1,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := newHashingReader(strings.NewReader("hw"))
+			_, err := io.Copy(ioutil.Discard, io.LimitReader(r, tc.read))
+			require.NoError(t, err)
+
+			got, ok := r.sum(2)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultKeyTemplate is the layout of the keys objects are uploaded
+	// under unless WithKeyTemplate is given.
+	DefaultKeyTemplate = "{prefix}/{id}/{name}"
+
+	// DateKeyTemplate partitions the keys by the year and month of the
+	// upload, i.e. images/2024/06/<id>/<name>, which keeps prefixes balanced
+	// and lets lifecycle rules expire a month at once.
+	DateKeyTemplate = "{prefix}/{yyyy}/{mm}/{id}/{name}"
+
+	// DefaultKeyPrefix is the prefix {prefix} stands for by default.
+	DefaultKeyPrefix = "images"
+)
+
+var placeholderRegex = regexp.MustCompile(`\{[^}]*\}`)
+
+// keyPlaceholders are the placeholders a key template may hold besides
+// {prefix}, which is replaced when the template is parsed.
+var keyPlaceholders = map[string]bool{
+	"{id}":   true,
+	"{name}": true,
+	"{ext}":  true,
+	"{yyyy}": true,
+	"{mm}":   true,
+	"{dd}":   true,
+}
+
+// keyLayouts are the built-in key templates by name.
+var keyLayouts = map[string]string{
+	"default": DefaultKeyTemplate,
+	"date":    DateKeyTemplate,
+}
+
+// KeyLayout returns the template of the built-in key layout, default or date.
+func KeyLayout(name string) (string, error) {
+	template, ok := keyLayouts[name]
+	if !ok {
+		return "", fmt.Errorf("unknown key layout %q, expected default or date", name)
+	}
+
+	return template, nil
+}
+
+// KeyTemplate lays out the keys objects are uploaded under, see
+// ParseKeyTemplate.
+type KeyTemplate struct {
+	static   string
+	template string
+}
+
+// ParseKeyTemplate parses the template of the keys objects are uploaded
+// under, i.e. {prefix}/{yyyy}/{mm}/{id}-{name}. The placeholders are:
+//
+// {prefix}: the prefix, i.e. DefaultKeyPrefix
+//
+// {id}: the image's ID, every template must hold it so keys are unique
+//
+// {name}: the image's name and {ext} the extension of its name without the dot
+//
+// {yyyy}, {mm} and {dd}: the year, month and day of the upload in UTC
+//
+// The template must start with a fixed directory, i.e. images/, which tells
+// the objects sim manages apart from the rest of the storage during GC.
+func ParseKeyTemplate(template, prefix string) (*KeyTemplate, error) {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" && strings.Contains(template, "{prefix}") {
+		return nil, fmt.Errorf("key template %q uses {prefix} but the prefix is empty", template)
+	}
+	expanded := strings.ReplaceAll(template, "{prefix}", prefix)
+
+	for _, p := range placeholderRegex.FindAllString(expanded, -1) {
+		if !keyPlaceholders[p] {
+			return nil, fmt.Errorf("key template %q has unknown placeholder %s", template, p)
+		}
+	}
+	if !strings.Contains(expanded, "{id}") {
+		return nil, fmt.Errorf("key template %q must hold {id}", template)
+	}
+
+	static := expanded[:strings.Index(expanded, "{")]
+	static = static[:strings.LastIndex(static, "/")+1]
+	if strings.Trim(static, "/") == "" || strings.HasPrefix(static, "/") {
+		return nil, fmt.Errorf("key template %q must start with a fixed directory, i.e. images/", template)
+	}
+
+	return &KeyTemplate{static: static, template: expanded}, nil
+}
+
+// WithKeyTemplate uploads objects under the keys of the template instead of
+// DefaultKeyTemplate. Existing images keep their keys, GC only considers the
+// objects under the template's fixed directory.
+func WithKeyTemplate(t *KeyTemplate) Option {
+	return func(s *Service) {
+		s.keys = t
+	}
+}
+
+// Prefix returns the fixed directory every key of the template starts with.
+func (t *KeyTemplate) Prefix() string {
+	return t.static
+}
+
+// key returns the key of the image uploaded at the time.
+func (t *KeyTemplate) key(id, name string, now time.Time) string {
+	now = now.UTC()
+
+	return strings.NewReplacer(
+		"{id}", id,
+		"{name}", name,
+		"{ext}", strings.TrimPrefix(path.Ext(name), "."),
+		"{yyyy}", now.Format("2006"),
+		"{mm}", now.Format("01"),
+		"{dd}", now.Format("02"),
+	).Replace(t.template)
+}
+
+// defaultKeyTemplate returns the parsed DefaultKeyTemplate.
+func defaultKeyTemplate() *KeyTemplate {
+	t, err := ParseKeyTemplate(DefaultKeyTemplate, DefaultKeyPrefix)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
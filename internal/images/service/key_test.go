@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseKeyTemplate(t *testing.T) {
+	now := time.Date(2024, 6, 3, 23, 0, 0, 0, time.FixedZone("", -2*60*60))
+	for _, tc := range []struct {
+		desc       string
+		template   string
+		prefix     string
+		wantKey    string
+		wantPrefix string
+		wantErr    bool
+	}{
+		{
+			desc:       "ParseKeyTemplate() should lay out the default keys",
+			template:   DefaultKeyTemplate,
+			prefix:     DefaultKeyPrefix,
+			wantKey:    "images/1/photos/a.png",
+			wantPrefix: "images/",
+		},
+		{
+			desc:       "ParseKeyTemplate() should replace the date of the upload in UTC",
+			template:   "{prefix}/{yyyy}/{mm}/{dd}/{id}-{name}",
+			prefix:     "/archive/",
+			wantKey:    "archive/2024/06/04/1-photos/a.png",
+			wantPrefix: "archive/",
+		},
+		{
+			desc:       "ParseKeyTemplate() should partition the date layout by month",
+			template:   DateKeyTemplate,
+			prefix:     DefaultKeyPrefix,
+			wantKey:    "images/2024/06/1/photos/a.png",
+			wantPrefix: "images/",
+		},
+		{
+			desc:       "ParseKeyTemplate() should replace the extension",
+			template:   "media/{ext}/{id}",
+			wantKey:    "media/png/1",
+			wantPrefix: "media/",
+		},
+		{
+			desc:     "ParseKeyTemplate() should reject a template without {id}",
+			template: "{prefix}/{name}",
+			prefix:   DefaultKeyPrefix,
+			wantErr:  true,
+		},
+		{
+			desc:     "ParseKeyTemplate() should reject an unknown placeholder",
+			template: "{prefix}/{id}/{hash}",
+			prefix:   DefaultKeyPrefix,
+			wantErr:  true,
+		},
+		{
+			desc:     "ParseKeyTemplate() should reject a template without a fixed directory",
+			template: "{yyyy}/{id}",
+			wantErr:  true,
+		},
+		{
+			desc:     "ParseKeyTemplate() should reject {prefix} without a prefix",
+			template: "{prefix}/{id}",
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			keys, err := ParseKeyTemplate(tc.template, tc.prefix)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantKey, keys.key("1", "photos/a.png", now))
+			assert.Equal(t, tc.wantPrefix, keys.Prefix())
+		})
+	}
+}
+
+func Test_KeyLayout(t *testing.T) {
+	template, err := KeyLayout("date")
+	require.NoError(t, err)
+	assert.Equal(t, DateKeyTemplate, template)
+
+	_, err = KeyLayout("weekly")
+	assert.Error(t, err, "KeyLayout() should reject an unknown layout")
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// MigrateStorage copies the objects of every record held in r.From to r.To
+// under the same key, verifies the copies and repoints the records at r.To.
+// A record that fails to migrate is left pointing at r.From and the
+// migration moves on, the failed IDs are returned in the result.
+func (s *Service) MigrateStorage(ctx context.Context, r images.MigrateStorageRequest) (*images.MigrateStorageResult, error) {
+	logger := s.logger.With(zap.String("from", r.From), zap.String("to", r.To))
+	logger.Info("attempting to migrate storage")
+
+	if r.From == r.To {
+		const msg = "unable to migrate storage to itself"
+		logger.Error(msg)
+		return nil, fmt.Errorf(msg+": %s", r.From)
+	}
+	from, err := s.store(r.From, logger)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.store(r.To, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := images.ListAll(ctx, s.reader)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		return new(images.MigrateStorageResult), nil
+	default:
+		const msg = "unable to list records"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	var res images.MigrateStorageResult
+	for i := range records {
+		rec := &records[i]
+		if rec.Storage != r.From {
+			continue
+		}
+
+		logger := logger.With(zap.String("imageId", rec.ID), zap.String("key", rec.Key))
+		if err := s.migrateRecord(ctx, rec, from, to, r, logger); err != nil {
+			res.Failed = append(res.Failed, rec.ID)
+			continue
+		}
+		res.Migrated++
+	}
+	logger.Info(
+		"successfully migrated storage",
+		zap.Int("migrated", res.Migrated),
+		zap.Int("failed", len(res.Failed)),
+	)
+
+	return &res, nil
+}
+
+func (s *Service) migrateRecord(ctx context.Context, rec *images.Record, from, to images.ObjectStore, r images.MigrateStorageRequest, logger *zap.Logger) error {
+	f, err := ioutil.TempFile("", "sim-migrate-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := from.Get(ctx, rec.Key, f); err != nil {
+		const msg = "unable to download object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	sum, err := md5Sum(f)
+	if err != nil {
+		const msg = "unable to checksum object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if !etagMatches(rec.ETag, rec.ServerSideEncryption, sum) {
+		logger.Error("downloaded object does not match record", zap.String("etag", rec.ETag), zap.String("md5", sum))
+		return images.ErrChecksum
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		const msg = "unable to seek temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	// the copy keeps the record's attributes and the service's encryption
+	opts := s.withSSE(images.UploadRequest{}, images.PutOptions{
+		ContentType:        rec.ContentType,
+		StorageClass:       rec.StorageClass,
+		CacheControl:       rec.CacheControl,
+		ContentDisposition: rec.ContentDisposition,
+		Metadata:           rec.ObjectMetadata,
+		Public:             images.Visibility(rec) == images.VisibilityPublic,
+	})
+	if s.objectTags {
+		opts.Tags = rec.Tags
+	}
+	if err := to.Put(ctx, rec.Key, f, opts); err != nil {
+		const msg = "unable to upload object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	info, err := to.Head(ctx, rec.Key)
+	if err != nil {
+		const msg = "unable to head object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if info.SizeInBytes != rec.SizeInBytes || !etagMatches(info.ETag, info.ServerSideEncryption, sum) {
+		logger.Error("copied object does not match record", zap.String("etag", info.ETag), zap.String("md5", sum))
+		return images.ErrChecksum
+	}
+
+	// the destination may already be one of the record's mirrors
+	mirrors := rec.Mirrors[:0]
+	for _, m := range rec.Mirrors {
+		if m != r.To {
+			mirrors = append(mirrors, m)
+		}
+	}
+	// cached variants are not copied, they are resized again when needed
+	originals := images.Record{Storage: r.From, Thumbnails: rec.Thumbnails, Variants: rec.Variants}
+	rec.Thumbnails = s.copyThumbnails(ctx, rec, from, to, opts, logger)
+	rec.Variants = nil
+	rec.ETag = info.ETag
+	rec.KMSKeyID = info.KMSKeyID
+	rec.Mirrors = mirrors
+	rec.ServerSideEncryption = info.ServerSideEncryption
+	rec.StorageClass = info.StorageClass
+	rec.Storage = r.To
+	if err := s.writer.Update(ctx, rec); err != nil {
+		const msg = "unable to update image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if r.DeleteOriginals {
+		// the record no longer points at the original, failing to remove it
+		// only leaves an orphaned object behind. A deduplicated original is
+		// kept until the last record referencing it is migrated.
+		original := *rec
+		original.Storage = r.From
+		if shared, err := s.shared(ctx, &original, logger); err != nil || shared {
+			logger.Info("original object is shared, keeping it")
+		} else if err := from.Delete(ctx, rec.Key); err != nil {
+			logger.Error("unable to delete original object", zap.Error(err))
+		}
+		s.deleteDerived(ctx, &originals, nil, logger)
+	}
+	logger.Info("successfully migrated object")
+
+	return nil
+}
+
+func md5Sum(f *os.File) (string, error) {
+	return fileSum(f, md5.New())
+}
+
+// fileSum returns the hex encoded digest of the whole file.
+func fileSum(f *os.File, h hash.Hash) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// etagMatches reports whether the etag matches the md5 sum. ETags which are
+// not a plain md5, i.e. multipart S3 uploads, can not be verified and always
+// match.
+func etagMatches(etag, sse, sum string) bool {
+	etag, ok := md5ETag(etag, sse)
+	if !ok {
+		return true
+	}
+
+	return strings.EqualFold(etag, sum)
+}
+
+// md5ETag returns the etag without quotes, reporting whether it is a plain
+// md5 sum. The ETag of an object encrypted with SSE-KMS, as reported by sse,
+// is not an md5 even when it looks like one.
+func md5ETag(etag, sse string) (string, bool) {
+	etag = strings.Trim(etag, `"`)
+	if strings.HasPrefix(sse, "aws:kms") {
+		return etag, false
+	}
+	if _, err := hex.DecodeString(etag); err != nil || len(etag) != md5.Size*2 {
+		return etag, false
+	}
+
+	return etag, true
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const (
+	migratorLoggerName = "images.migrator"
+
+	// DefaultBatchSize is the number of records copied between progress
+	// reports.
+	DefaultBatchSize = 100
+)
+
+// RecordMigrator copies the image records from one metadata backend into
+// another.
+type RecordMigrator struct {
+	batchSize int
+	from      images.Reader
+	logger    *zap.Logger
+	to        images.Reader
+	writer    images.Writer
+}
+
+// NewRecordMigrator returns an instantiated instance of a record migrator
+// which has the following dependencies:
+//
+// logger: for structured logging
+//
+// from: for reading the records out of the source backend
+//
+// to: for reading the records back from the target backend
+//
+// writer: for writing the records into the target backend
+//
+// batchSize: the number of records copied between progress reports
+func NewRecordMigrator(logger *zap.Logger, from, to images.Reader, writer images.Writer, batchSize int) (*RecordMigrator, error) {
+	m := RecordMigrator{
+		batchSize: batchSize,
+		from:      from,
+		logger:    logger.Named(migratorLoggerName),
+		to:        to,
+		writer:    writer,
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	m.logger.Debug("successfully initialized record migrator")
+
+	return &m, nil
+}
+
+func (m *RecordMigrator) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "batch size",
+			chk: func() bool { return m.batchSize > 0 },
+		},
+		{
+			dep: "from",
+			chk: func() bool { return m.from != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return m.logger != nil },
+		},
+		{
+			dep: "to",
+			chk: func() bool { return m.to != nil },
+		},
+		{
+			dep: "writer",
+			chk: func() bool { return m.writer != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize migrator due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Migrate copies every record into the target backend, calling progress
+// after each batch, then verifies the copies by reading them back. Records
+// which already exist in the target are skipped so that a failed migration
+// can be re-run.
+func (m *RecordMigrator) Migrate(ctx context.Context, progress func(done, total int)) (*images.MigrateRecordsResult, error) {
+	records, err := images.ListAll(ctx, m.from)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		return new(images.MigrateRecordsResult), nil
+	default:
+		const msg = "unable to list records"
+		m.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	var res images.MigrateRecordsResult
+	failed := make(map[string]bool)
+	for start := 0; start < len(records); start += m.batchSize {
+		end := start + m.batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		for i := start; i < end; i++ {
+			logger := m.logger.With(zap.String("imageId", records[i].ID))
+			copied, err := m.copy(ctx, &records[i])
+			switch {
+			case err != nil:
+				logger.Error("unable to migrate record", zap.Error(err))
+				res.Failed = append(res.Failed, records[i].ID)
+				failed[records[i].ID] = true
+			case copied:
+				res.Copied++
+			default:
+				logger.Debug("record already exists in target")
+				res.Skipped++
+			}
+		}
+		if progress != nil {
+			progress(end, len(records))
+		}
+	}
+
+	// verify every record made it into the target unchanged
+	for i := range records {
+		if failed[records[i].ID] {
+			continue
+		}
+
+		got, err := m.to.Get(ctx, records[i].ID)
+		if err != nil || !recordsEqual(&records[i], got) {
+			m.logger.Error("record does not match source", zap.String("imageId", records[i].ID), zap.Error(err))
+			res.Mismatched = append(res.Mismatched, records[i].ID)
+		}
+	}
+	m.logger.Info(
+		"successfully migrated records",
+		zap.Int("copied", res.Copied),
+		zap.Int("skipped", res.Skipped),
+		zap.Int("failed", len(res.Failed)),
+		zap.Int("mismatched", len(res.Mismatched)),
+	)
+
+	return &res, nil
+}
+
+// copy creates the record in the target, returning false if it already
+// exists.
+func (m *RecordMigrator) copy(ctx context.Context, rec *images.Record) (bool, error) {
+	_, err := m.to.Get(ctx, rec.ID)
+	switch err {
+	case nil:
+		return false, nil
+	case images.ErrRecordNotFound:
+	default:
+		return false, err
+	}
+
+	if err := m.writer.Create(ctx, rec); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func recordsEqual(a, b *images.Record) bool {
+	switch {
+	case a.CreatedAt == nil && b.CreatedAt == nil:
+	case a.CreatedAt == nil || b.CreatedAt == nil:
+		return false
+	case !a.CreatedAt.Equal(*b.CreatedAt):
+		return false
+	}
+
+	if !stringsEqual(a.Mirrors, b.Mirrors) || !stringsEqual(a.Tags, b.Tags) {
+		return false
+	}
+	if !metadataEqual(a.Metadata, b.Metadata) || !metadataEqual(a.ObjectMetadata, b.ObjectMetadata) {
+		return false
+	}
+
+	return a.ID == b.ID &&
+		a.ETag == b.ETag &&
+		a.SHA256 == b.SHA256 &&
+		a.ContentType == b.ContentType &&
+		a.ContentEncoding == b.ContentEncoding &&
+		a.EncryptedKey == b.EncryptedKey &&
+		a.ServerSideEncryption == b.ServerSideEncryption &&
+		a.KMSKeyID == b.KMSKeyID &&
+		a.StorageClass == b.StorageClass &&
+		a.CacheControl == b.CacheControl &&
+		a.ContentDisposition == b.ContentDisposition &&
+		a.Visibility == b.Visibility &&
+		a.Key == b.Key &&
+		a.Name == b.Name &&
+		a.Path == b.Path &&
+		a.SizeInBytes == b.SizeInBytes &&
+		a.Storage == b.Storage
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func metadataEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+
+	return true
+}
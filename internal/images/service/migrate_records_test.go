@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+	"github.com/itsHabib/sim/internal/memory"
+)
+
+func Test_RecordMigrator_Migrate(t *testing.T) {
+	now := time.Now().UTC()
+	records := []images.Record{
+		{ID: "a", CreatedAt: &now, Name: "a"},
+		{ID: "b", CreatedAt: &now, Name: "b"},
+		{ID: "c", CreatedAt: &now, Name: "c"},
+	}
+
+	t.Run("Migrate() should copy the records in batches and skip existing records", func(t *testing.T) {
+		from, err := memory.NewRecords(zap.NewNop(), records...)
+		require.NoError(t, err)
+		to, err := memory.NewRecords(zap.NewNop(), records[0])
+		require.NoError(t, err)
+
+		m, err := NewRecordMigrator(zap.NewNop(), from, to, to, 2)
+		require.NoError(t, err)
+
+		var reports []int
+		res, err := m.Migrate(context.Background(), func(done, total int) {
+			assert.Equal(t, len(records), total)
+			reports = append(reports, done)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &images.MigrateRecordsResult{Copied: 2, Skipped: 1}, res)
+		assert.Equal(t, []int{2, 3}, reports)
+
+		page, err := to.List(context.Background(), images.ListOptions{})
+		require.NoError(t, err)
+		// revisions and update times are assigned by the target
+		for i := range page.Records {
+			page.Records[i].Revision = 0
+			page.Records[i].UpdatedAt = nil
+		}
+		assert.Equal(t, records, page.Records)
+	})
+
+	t.Run("Migrate() should report the records which fail to copy", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		from, err := memory.NewRecords(zap.NewNop(), records[0])
+		require.NoError(t, err)
+		to, err := memory.NewRecords(zap.NewNop())
+		require.NoError(t, err)
+
+		w := mock_images.NewMockWriter(ctrl)
+		w.
+			EXPECT().
+			Create(gomock.Any(), gomock.Any()).
+			Return(errors.New("random"))
+
+		m, err := NewRecordMigrator(zap.NewNop(), from, to, w, DefaultBatchSize)
+		require.NoError(t, err)
+
+		res, err := m.Migrate(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, &images.MigrateRecordsResult{Failed: []string{"a"}}, res)
+	})
+}
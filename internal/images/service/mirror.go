@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// mirrorQueueSize is the number of mirror operations that can be pending
+// before uploads and deletes block on the background worker.
+const mirrorQueueSize = 64
+
+// WithMirror mirrors every upload and delete to the named storage so that
+// downloads can fall back to it when the primary storage is unavailable. When
+// async is true the copies are made by a background worker, Close waits for
+// any pending copies to finish.
+func WithMirror(storage string, async bool) Option {
+	return func(s *Service) {
+		s.mirror = &mirror{
+			async:   async,
+			storage: storage,
+		}
+	}
+}
+
+type mirror struct {
+	async   bool
+	storage string
+
+	closed bool
+	jobs   chan func(ctx context.Context)
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+}
+
+func (m *mirror) start(logger *zap.Logger) {
+	if m == nil || !m.async {
+		return
+	}
+
+	m.jobs = make(chan func(ctx context.Context), mirrorQueueSize)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for job := range m.jobs {
+			job(context.Background())
+		}
+		logger.Debug("mirror worker stopped")
+	}()
+}
+
+// run executes the job in the background when async, otherwise inline with
+// the caller's context. Background jobs outlive the caller so they are not
+// bound to its context.
+func (m *mirror) run(ctx context.Context, job func(ctx context.Context)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.async || m.closed {
+		job(ctx)
+		return
+	}
+
+	m.jobs <- job
+}
+
+func (m *mirror) close() {
+	if m == nil || !m.async {
+		return
+	}
+
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.jobs)
+	}
+	m.mu.Unlock()
+
+	m.wg.Wait()
+}
+
+// spool tees the body into a temp file as it is read so that it can be
+// replayed to the mirror after the primary upload.
+func (m *mirror) spool(body io.Reader) (io.Reader, *spoolFile, error) {
+	if m == nil {
+		return body, nil, nil
+	}
+
+	f, err := ioutil.TempFile("", "sim-mirror-*")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return io.TeeReader(body, f), &spoolFile{f}, nil
+}
+
+type spoolFile struct {
+	*os.File
+}
+
+// discard closes and removes the spooled file.
+func (f *spoolFile) discard() {
+	if f == nil {
+		return
+	}
+
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// mirrorUpload copies the spooled object to the mirror storage, returning
+// the storages to record as mirrors. Failing to mirror does not fail the
+// upload, the mirror is left off the record instead. Async copies are
+// recorded before they complete.
+func (s *Service) mirrorUpload(ctx context.Context, key string, spool *spoolFile, opts images.PutOptions, logger *zap.Logger) []string {
+	if s.mirror == nil {
+		return nil
+	}
+
+	storage := s.mirror.storage
+	logger = logger.With(zap.String("mirror", storage))
+	store := s.stores[storage]
+
+	var mirrored bool
+	s.mirror.run(ctx, func(ctx context.Context) {
+		defer spool.discard()
+
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			logger.Error("unable to seek spooled image", zap.Error(err))
+			return
+		}
+		if err := store.Put(ctx, key, spool, opts); err != nil {
+			logger.Error("unable to mirror image", zap.Error(err))
+			return
+		}
+
+		mirrored = true
+		logger.Info("successfully mirrored image")
+	})
+
+	if !s.mirror.async && !mirrored {
+		return nil
+	}
+
+	return []string{storage}
+}
+
+// deleteMirrors removes the record's object from its mirrors. Failures are
+// logged, the primary object and record are the source of truth.
+func (s *Service) deleteMirrors(ctx context.Context, rec *images.Record, logger *zap.Logger) {
+	for _, storage := range rec.Mirrors {
+		logger := logger.With(zap.String("mirror", storage))
+		store, ok := s.stores[storage]
+		if !ok {
+			logger.Error("mirror storage not found")
+			continue
+		}
+
+		job := func(ctx context.Context) {
+			if err := store.Delete(ctx, rec.Key); err != nil {
+				logger.Error("unable to delete mirrored object", zap.Error(err))
+			}
+		}
+		if s.mirror != nil {
+			s.mirror.run(ctx, job)
+		} else {
+			job(ctx)
+		}
+	}
+}
+
+// downloadMirror attempts to download the record's object from its mirrors,
+// returning the bytes downloaded and true on the first successful download.
+func (s *Service) downloadMirror(ctx context.Context, rec *images.Record, stream io.WriterAt, logger *zap.Logger) (int64, bool) {
+	for _, storage := range rec.Mirrors {
+		logger := logger.With(zap.String("mirror", storage))
+		store, ok := s.stores[storage]
+		if !ok {
+			logger.Error("mirror storage not found")
+			continue
+		}
+
+		n, err := store.Get(ctx, rec.Key, stream)
+		if err != nil {
+			logger.Error("unable to download from mirror", zap.Error(err))
+			continue
+		}
+
+		logger.Warn("primary storage unavailable, downloaded from mirror")
+		return n, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+// moderationSize is the longest side images are scaled down to before they
+// are sent to the moderator, which keeps them well under the size it
+// accepts.
+const moderationSize = 1024
+
+// moderation configures how uploads are moderated.
+type moderation struct {
+	moderator images.Moderator
+	policy    images.ModerationPolicy
+}
+
+// WithModeration moderates every uploaded jpeg, png, gif and webp with the
+// moderator before it is stored and records the result on the image's
+// record, see Record.Moderation. Flagged images are handled by the policy.
+// An upload fails when its image can not be moderated, images which fail to
+// decode are stored without a moderation. Encrypted images are not
+// moderated as their content would be sent to the moderator.
+func WithModeration(moderator images.Moderator, policy images.ModerationPolicy) Option {
+	return func(s *Service) {
+		s.moderation = &moderation{moderator: moderator, policy: policy}
+	}
+}
+
+// moderateBody moderates the upload's jpeg, png, gif or webp body and applies the
+// policy to it when it is flagged, see applyModeration. The body is replaced
+// with one which replays what was read, the cleanup func removes the temp
+// file it was read into.
+func (s *Service) moderateBody(ctx context.Context, r *images.UploadRequest, logger *zap.Logger) (*images.Moderation, func(), error) {
+	cleanup := func() {}
+	if s.moderation == nil || s.encryptionKey != nil {
+		return nil, cleanup, nil
+	}
+	if _, ok := imaging.FormatOf(r.ContentType); !ok {
+		logger.Debug("only jpeg, png, gif and webp images are moderated", zap.String("contentType", r.ContentType))
+		return nil, cleanup, nil
+	}
+
+	// a body which can not seek is read into a temp file as it is decoded,
+	// the rest of it is still unread
+	src, seeker := r.Body, io.ReadSeeker(nil)
+	if rs, ok := r.Body.(io.ReadSeeker); ok {
+		seeker = rs
+	} else {
+		f, err := ioutil.TempFile("", "sim-moderate-*")
+		if err != nil {
+			const msg = "unable to create temp file"
+			logger.Error(msg, zap.Error(err))
+			return nil, cleanup, fmt.Errorf(msg+": %w", err)
+		}
+		cleanup = func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		src, seeker = io.TeeReader(r.Body, f), f
+		r.Body = io.MultiReader(f, r.Body)
+	}
+	img, decodeErr := decode(src)
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		const msg = "unable to seek image"
+		logger.Error(msg, zap.Error(err))
+		return nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	if decodeErr != nil {
+		logger.Warn("unable to decode image, not moderating it", zap.Error(decodeErr))
+		return nil, cleanup, nil
+	}
+
+	m, err := s.moderate(ctx, img, logger)
+	if err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	if err := s.applyModeration(m, logger); err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	if m.Quarantined {
+		r.Public = false
+	}
+
+	return m, cleanup, nil
+}
+
+// moderateUploaded moderates the pending record's jpeg, png, gif or webp object
+// and applies the policy to it when it is flagged. The object and the
+// record of a rejected image are removed.
+func (s *Service) moderateUploaded(ctx context.Context, store images.ObjectStore, rec *images.Record, logger *zap.Logger) (*images.Moderation, error) {
+	if _, ok := imaging.FormatOf(rec.ContentType); !ok {
+		logger.Debug("only jpeg, png, gif and webp images are moderated", zap.String("contentType", rec.ContentType))
+		return nil, nil
+	}
+	img, err := s.decodeImage(ctx, rec, logger)
+	if err != nil {
+		logger.Warn("unable to decode image, not moderating it", zap.Error(err))
+		return nil, nil
+	}
+
+	m, err := s.moderate(ctx, img, logger)
+	if err != nil {
+		return nil, err
+	}
+	// presigned uploads are private, a quarantined one stays so
+	if err := s.applyModeration(m, logger); err != nil {
+		s.discardUpload(ctx, store, rec, logger)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// moderate sends the decoded image scaled down as a JPEG to the moderator,
+// the image is flagged when it has moderation labels.
+func (s *Service) moderate(ctx context.Context, img image.Image, logger *zap.Logger) (*images.Moderation, error) {
+	var buf bytes.Buffer
+	if err := imaging.EncodeJPEG(&buf, imaging.Fit(img, moderationSize, moderationSize), imaging.DefaultJPEGQuality); err != nil {
+		const msg = "unable to encode image for moderation"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	m, err := s.moderation.moderator.Moderate(ctx, buf.Bytes())
+	if err != nil {
+		const msg = "unable to moderate image"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	m.Flagged = len(m.Labels) > 0
+	if m.Flagged {
+		names := make([]string, len(m.Labels))
+		for i := range m.Labels {
+			names[i] = m.Labels[i].Name
+		}
+		logger.Warn("image was flagged by moderation", zap.Strings("labels", names))
+	}
+
+	return m, nil
+}
+
+// applyModeration applies the policy to a flagged image: it returns
+// ErrFlagged when the policy rejects it and marks it quarantined when the
+// policy quarantines it.
+func (s *Service) applyModeration(m *images.Moderation, logger *zap.Logger) error {
+	if !m.Flagged {
+		return nil
+	}
+
+	switch s.moderation.policy {
+	case images.ModerationReject:
+		logger.Error("rejecting flagged image")
+		return images.ErrFlagged
+	case images.ModerationQuarantine:
+		logger.Info("quarantining flagged image")
+		m.Quarantined = true
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Upload_Moderation(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 4))))
+	body := buf.Bytes()
+
+	safe := &images.Moderation{ModelVersion: "6.0"}
+	flagged := func() *images.Moderation {
+		return &images.Moderation{
+			Labels:       []images.ModerationLabel{{Name: "Violence", Confidence: 90}},
+			ModelVersion: "6.0",
+		}
+	}
+	for _, tc := range []struct {
+		desc       string
+		policy     images.ModerationPolicy
+		body       io.Reader
+		moderation *images.Moderation
+		modErr     error
+		wantPublic bool
+		want       *images.Moderation
+		wantErr    error
+	}{
+		{
+			desc:       "Upload() should record the moderation of a safe image",
+			policy:     images.ModerationReject,
+			body:       bytes.NewReader(body),
+			moderation: safe,
+			wantPublic: true,
+			want:       &images.Moderation{ModelVersion: "6.0"},
+		},
+		{
+			desc:       "Upload() should store a flagged image with the flag policy",
+			policy:     images.ModerationFlag,
+			body:       bytes.NewReader(body),
+			moderation: flagged(),
+			wantPublic: true,
+			want: &images.Moderation{
+				Flagged:      true,
+				Labels:       []images.ModerationLabel{{Name: "Violence", Confidence: 90}},
+				ModelVersion: "6.0",
+			},
+		},
+		{
+			desc:       "Upload() should store a flagged image private with the quarantine policy",
+			policy:     images.ModerationQuarantine,
+			body:       bytes.NewReader(body),
+			moderation: flagged(),
+			want: &images.Moderation{
+				Flagged:      true,
+				Quarantined:  true,
+				Labels:       []images.ModerationLabel{{Name: "Violence", Confidence: 90}},
+				ModelVersion: "6.0",
+			},
+		},
+		{
+			desc:       "Upload() should moderate a body which can not seek and store all of it",
+			policy:     images.ModerationReject,
+			body:       io.MultiReader(bytes.NewReader(body)),
+			moderation: safe,
+			wantPublic: true,
+			want:       &images.Moderation{ModelVersion: "6.0"},
+		},
+		{
+			desc:       "Upload() should return ErrFlagged for a flagged image with the reject policy",
+			policy:     images.ModerationReject,
+			body:       bytes.NewReader(body),
+			moderation: flagged(),
+			wantErr:    images.ErrFlagged,
+		},
+		{
+			desc:    "Upload() should fail when the image can not be moderated",
+			policy:  images.ModerationFlag,
+			body:    bytes.NewReader(body),
+			modErr:  assert.AnError,
+			wantErr: assert.AnError,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			m := mock_images.NewMockModerator(ctrl)
+			m.EXPECT().Moderate(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, jpeg []byte) (*images.Moderation, error) {
+					// the image is sent as a jpeg
+					assert.Equal(t, []byte{0xff, 0xd8}, jpeg[:2])
+					return tc.moderation, tc.modErr
+				})
+			if tc.wantErr == nil {
+				s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, r io.Reader, opts images.PutOptions) error {
+						b, err := ioutil.ReadAll(r)
+						require.NoError(t, err)
+						assert.Equal(t, body, b)
+						assert.Equal(t, tc.wantPublic, opts.Public)
+						return nil
+					})
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{SizeInBytes: int64(len(body))}, nil)
+				w.EXPECT().Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, tc.want, rec.Moderation)
+						return nil
+					})
+			}
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithModeration(m, tc.policy))
+			require.NoError(t, err)
+
+			_, err = svc.Upload(context.Background(), images.UploadRequest{
+				Name:        "b.png",
+				Body:        tc.body,
+				ContentType: "image/png",
+				Public:      true,
+				Force:       true,
+			})
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("Upload() should not moderate an image which is not a jpeg, png or gif", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+		s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{SizeInBytes: 3}, nil)
+		w.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, rec *images.Record) error {
+				assert.Nil(t, rec.Moderation)
+				return nil
+			})
+		svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithModeration(mock_images.NewMockModerator(ctrl), images.ModerationReject))
+		require.NoError(t, err)
+
+		_, err = svc.Upload(context.Background(), images.UploadRequest{
+			Name:        "a.txt",
+			Body:        bytes.NewReader([]byte("abc")),
+			ContentType: "text/plain",
+			Force:       true,
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("ConfirmUpload() should remove a flagged image and its record with the reject policy", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		until := time.Now().Add(time.Hour)
+		rec := &images.Record{ID: "1", Key: "key", Storage: "sim", ContentType: "image/png", PendingUntil: &until}
+		r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+		r.EXPECT().Get(gomock.Any(), "1").Return(rec, nil)
+		s.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{SizeInBytes: int64(len(body))}, nil)
+		s.EXPECT().GetRange(gomock.Any(), "key", gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+				n, err := w.Write(body)
+				return int64(n), err
+			}).
+			AnyTimes()
+		m := mock_images.NewMockModerator(ctrl)
+		m.EXPECT().Moderate(gomock.Any(), gomock.Any()).Return(flagged(), nil)
+		s.EXPECT().Delete(gomock.Any(), "key").Return(nil)
+		w.EXPECT().Delete(gomock.Any(), "1").Return(nil)
+		svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithModeration(m, images.ModerationReject))
+		require.NoError(t, err)
+
+		_, err = svc.ConfirmUpload(context.Background(), "1")
+		assert.True(t, errors.Is(err, images.ErrFlagged), err)
+	})
+
+	t.Run("New() should return an error when the moderation policy is not valid", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		_, err := New(zap.NewNop(), "sim", mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)}, WithModeration(mock_images.NewMockModerator(ctrl), "delete"))
+		assert.Error(t, err)
+	})
+}
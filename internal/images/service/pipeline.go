@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+// WithPipelines configures the named pipelines, see Pipeline. The variants
+// of the pipelines applied at upload are generated for every uploaded jpeg,
+// png and gif like its thumbnails, failing to generate one only logs.
+func WithPipelines(pipelines map[string]images.Pipeline) Option {
+	return func(s *Service) {
+		s.pipelines = pipelines
+	}
+}
+
+// Pipeline returns the named pipeline, ErrUnknownPipeline if there is none.
+func (s *Service) Pipeline(name string) (*images.Pipeline, error) {
+	p, ok := s.pipelines[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", images.ErrUnknownPipeline, name)
+	}
+
+	return &p, nil
+}
+
+// validPipelines checks the transformations of every pipeline, and that a
+// watermark is configured for the pipelines which apply it.
+func (s *Service) validPipelines() bool {
+	for _, p := range s.pipelines {
+		if validVariant(p.Variant("")) != nil || (p.Watermark && s.watermark == nil) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// derive generates the thumbnails of the uploaded record's image, the
+// variants of the pipelines applied at upload, its blurhash and its text,
+// decoding the image once. Images which can not be decoded and encrypted images, whose
+// copies would not be encrypted, get none.
+func (s *Service) derive(ctx context.Context, rec *images.Record, logger *zap.Logger) {
+	names := s.uploadPipelines()
+	switch {
+	case len(s.thumbnailSizes) == 0 && len(names) == 0 && !s.blurhash && s.textExtractor == nil:
+		return
+	case rec.EncryptedKey != "":
+		logger.Debug("image is encrypted, not generating thumbnails or variants")
+		return
+	}
+	if _, ok := imaging.FormatOf(rec.ContentType); !ok {
+		return
+	}
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return
+	}
+
+	img, err := s.decodeImage(ctx, rec, logger)
+	if err != nil {
+		logger.Warn("unable to decode image, not generating thumbnails or variants", zap.Error(err))
+		return
+	}
+	rec.Thumbnails = s.thumbnails(ctx, store, rec, img, logger)
+	rec.Variants = s.pipelineVariants(ctx, store, rec, img, names, logger)
+	if s.blurhash {
+		rec.Blurhash = imaging.Blurhash(img)
+	}
+	if s.textExtractor != nil {
+		rec.Text = s.extractText(ctx, img, logger)
+	}
+}
+
+// uploadPipelines returns the names of the pipelines applied at upload, in
+// order.
+func (s *Service) uploadPipelines() []string {
+	var names []string
+	for name, p := range s.pipelines {
+		if p.OnUpload {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// pipelineVariants generates the variants of the named pipelines of the
+// record's decoded image and uploads them, returning the variants which
+// were uploaded. Pipelines with the same transformations share a variant.
+func (s *Service) pipelineVariants(ctx context.Context, store images.ObjectStore, rec *images.Record, img image.Image, names []string, logger *zap.Logger) []images.Variant {
+	var (
+		variants []images.Variant
+		done     = make(map[string]bool)
+	)
+	for _, name := range names {
+		logger := logger.With(zap.String("pipeline", name))
+
+		job, err := s.newVariantJob(rec, store, s.pipelines[name].Variant(rec.ID))
+		if err != nil || done[job.key] {
+			continue
+		}
+		done[job.key] = true
+
+		b, bounds, err := encodeVariant(img, job)
+		if err != nil {
+			logger.Warn("unable to encode variant", zap.Error(err))
+			continue
+		}
+		contentType := job.format.ContentType()
+		if err := store.Put(ctx, job.key, bytes.NewReader(b), s.derivedOptions(rec, contentType)); err != nil {
+			logger.Warn("unable to upload variant", zap.String("variantKey", job.key), zap.Error(err))
+			continue
+		}
+		variants = append(variants, images.Variant{
+			Key:         job.key,
+			ContentType: contentType,
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+			SizeInBytes: int64(len(b)),
+		})
+	}
+	if len(variants) > 0 {
+		logger.Info("generated variants", zap.Int("variants", len(variants)))
+	}
+
+	return variants
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Pipelines(t *testing.T) {
+	pipelines := map[string]images.Pipeline{
+		"web":   {Width: 4, Format: "jpeg", Quality: 80, OnUpload: true},
+		"small": {Width: 4, Format: "jpg", Quality: 80, OnUpload: true},
+		"print": {Format: "png"},
+	}
+
+	t.Run("Upload() should store the variants of the pipelines applied at upload", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 4))))
+		body := buf.Bytes()
+
+		ctrl := gomock.NewController(t)
+		r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+		r.EXPECT().GetByName(gomock.Any(), "b.png").Return(nil, images.ErrRecordNotFound)
+		s.EXPECT().Put(gomock.Any(), "images/2/b.png", gomock.Any(), gomock.Any()).Return(nil)
+		s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: int64(len(body))}, nil)
+		// the image is decoded once for its thumbnails and variants
+		s.EXPECT().GetRange(gomock.Any(), "images/2/b.png", int64(0), int64(0), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+				n, err := w.Write(body)
+				return int64(n), err
+			})
+		s.EXPECT().Put(gomock.Any(), "images/2/thumb/2.jpg", gomock.Any(), gomock.Any()).Return(nil)
+		// web and small share their variant
+		s.EXPECT().Put(gomock.Any(), "images/2/variant/4x0-q80.jpg", gomock.Any(), gomock.Any()).Return(nil)
+		w.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, rec *images.Record) error {
+				assert.Len(t, rec.Thumbnails, 1)
+				require.Len(t, rec.Variants, 1)
+				assert.Equal(t, "image/jpeg", rec.Variants[0].ContentType)
+				assert.Equal(t, 4, rec.Variants[0].Width)
+				assert.Equal(t, 2, rec.Variants[0].Height)
+				return nil
+			})
+
+		svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s},
+			WithThumbnails(2), WithPipelines(pipelines), WithIDGenerator(func() string { return "2" }))
+		require.NoError(t, err)
+
+		_, err = svc.Upload(context.Background(), images.UploadRequest{
+			Name:        "b.png",
+			Body:        bytes.NewReader(body),
+			ContentType: "image/png",
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Pipeline() should return the named pipeline", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		svc, err := New(zap.NewNop(), "sim", mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl),
+			images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)}, WithPipelines(pipelines))
+		require.NoError(t, err)
+
+		p, err := svc.Pipeline("print")
+		require.NoError(t, err)
+		assert.Equal(t, "png", p.Format)
+
+		_, err = svc.Pipeline("missing")
+		assert.True(t, errors.Is(err, images.ErrUnknownPipeline))
+	})
+
+	t.Run("New() should return an error when a pipeline is not valid", func(t *testing.T) {
+		for _, p := range []images.Pipeline{
+			{Format: "avif"},
+			{Width: -1},
+			{Quality: 101},
+			{},
+		} {
+			ctrl := gomock.NewController(t)
+			_, err := New(zap.NewNop(), "sim", mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl),
+				images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)}, WithPipelines(map[string]images.Pipeline{"p": p}))
+			assert.Error(t, err)
+		}
+	})
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// PresignUpload creates a pending image record and a presigned request which
+// uploads its object directly to the default storage, i.e. from a browser,
+// without the bytes passing through the service. ConfirmUpload completes the
+// record once the client uploaded the object. The object is stored as the
+// client sends it, so direct uploads are refused with ErrUnsupported when
+// the service encrypts images. Pending records whose upload expired without
+// an object are removed by GC.
+func (s *Service) PresignUpload(ctx context.Context, r images.PresignUploadRequest) (*images.PresignedUpload, error) {
+	logger := s.logger.With(zap.String("name", r.Name), zap.String("storage", s.storage), zap.Duration("expires", r.Expires))
+	logger.Info("attempting to presign upload")
+
+	switch {
+	case r.Name == "":
+		logger.Error("no name")
+		return nil, errors.New("an image name is required")
+	case r.Expires <= 0 || r.Expires > images.MaxURLExpiry:
+		logger.Error("expiry out of range")
+		return nil, fmt.Errorf("%w: must be between 0s and %s", images.ErrInvalidExpiry, images.MaxURLExpiry)
+	case s.encryptionKey != nil:
+		logger.Error("encrypted images can not be uploaded directly")
+		return nil, fmt.Errorf("%w: images are encrypted before they are stored", images.ErrUnsupported)
+	}
+
+	store, err := s.store(s.storage, logger)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := images.NormalizeTags(r.Tags)
+	if err != nil {
+		logger.Error("invalid tags", zap.Error(err))
+		return nil, err
+	}
+	metadata, err := images.NormalizeMetadata(r.Metadata)
+	if err != nil {
+		logger.Error("invalid metadata", zap.Error(err))
+		return nil, err
+	}
+
+	upload := images.UploadRequest{Name: r.Name, ContentType: r.ContentType}
+	if upload.ContentType == "" {
+		upload.ContentType = mime.TypeByExtension(path.Ext(r.Name))
+	}
+	if upload.ContentType == "" {
+		logger.Error("no content type")
+		return nil, errors.New("a content type is required when the name has no known extension")
+	}
+	if err := s.checkType(upload, logger); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.named(ctx, r.Name, logger)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		logger.Error("name taken")
+		return nil, images.ErrNameTaken
+	}
+
+	now := time.Now().UTC()
+	imageID := s.newID()
+	key := s.keys.key(imageID, r.Name, now)
+	logger = logger.With(zap.String("imageId", imageID))
+
+	opts := s.pendingPutOptions(upload, tags)
+	req, err := store.PresignPut(ctx, key, r.Expires, opts)
+	if err != nil {
+		if err == images.ErrUnsupported {
+			logger.Error("storage does not support direct uploads")
+			return nil, err
+		}
+		const msg = "unable to presign upload"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	expiresAt := now.Add(r.Expires)
+	rec := images.Record{
+		ID:                 imageID,
+		CreatedAt:          &now,
+		Key:                key,
+		Name:               r.Name,
+		ContentType:        upload.ContentType,
+		Storage:            s.storage,
+		CacheControl:       opts.CacheControl,
+		ContentDisposition: opts.ContentDisposition,
+		Metadata:           metadata,
+		Visibility:         images.VisibilityPrivate,
+		PendingUntil:       &expiresAt,
+	}
+	if len(tags) > 0 {
+		rec.Tags = tags
+	}
+	if err := s.writer.Create(ctx, &rec); err != nil {
+		const msg = "unable to create pending image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("successfully presigned upload")
+
+	return &images.PresignedUpload{ImageID: imageID, Request: *req, ExpiresAt: expiresAt}, nil
+}
+
+// pendingPutOptions returns the options the object of a presigned upload is
+// put with, the client puts it with those signed and the mirror is put with
+// the same.
+func (s *Service) pendingPutOptions(upload images.UploadRequest, tags []string) images.PutOptions {
+	opts := s.withHeaders(upload, s.withSSE(upload, images.PutOptions{
+		ContentType:  upload.ContentType,
+		StorageClass: s.storageClass,
+	}))
+	if s.objectTags {
+		opts.Tags = tags
+	}
+
+	return opts
+}
+
+// ConfirmUpload completes the pending record of a presigned upload from its
+// uploaded object, which it no longer is pending once it has. The object is
+// read to record its checksum and copied to the mirror, like the body of an
+// Upload. Returns ErrNotPending if the image is not awaiting an upload or
+// its upload expired and ErrObjectNotFound if the client has not uploaded it
+// yet. With image validation enabled an
+// object which is not an image is removed along with its record and
+// ErrInvalidImage is returned. With metadata stripping a jpeg or png object
+// is replaced with a copy without its metadata. With moderation an image the
+// policy rejects is removed along with its record and ErrFlagged is returned.
+func (s *Service) ConfirmUpload(ctx context.Context, id string) (*images.Record, error) {
+	logger := s.logger.With(zap.String("imageId", id))
+	logger.Info("attempting to confirm upload")
+
+	rec, err := s.reader.Get(ctx, id)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		logger.Error("record not found", zap.Error(err))
+		return nil, err
+	default:
+		const msg = "unable to retrieve image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	if rec.PendingUntil == nil || rec.DeletingAt != nil {
+		logger.Error("record is not pending")
+		return nil, images.ErrNotPending
+	}
+	if time.Now().After(*rec.PendingUntil) {
+		logger.Error("upload expired", zap.Time("pendingUntil", *rec.PendingUntil))
+		return nil, fmt.Errorf("%w: the upload expired at %s", images.ErrNotPending, rec.PendingUntil.Format(time.RFC3339))
+	}
+
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return nil, err
+	}
+	info, err := store.Head(ctx, rec.Key)
+	switch err {
+	case nil:
+	case images.ErrObjectNotFound:
+		logger.Error("object not uploaded", zap.Error(err))
+		return nil, err
+	default:
+		const msg = "unable to head object"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	if s.validateImages {
+		if err := s.checkUploaded(ctx, store, rec, logger); err != nil {
+			return nil, err
+		}
+	}
+	if s.stripMetadata {
+		stripped, err := s.stripUploaded(ctx, store, rec, logger)
+		if err != nil {
+			return nil, err
+		}
+		if stripped {
+			if info, err = store.Head(ctx, rec.Key); err != nil {
+				const msg = "unable to head stripped object"
+				logger.Error(msg, zap.Error(err))
+				return nil, fmt.Errorf(msg+": %w", err)
+			}
+		}
+	}
+
+	rec.ETag = info.ETag
+	rec.SizeInBytes = info.SizeInBytes
+	rec.ServerSideEncryption = info.ServerSideEncryption
+	rec.KMSKeyID = info.KMSKeyID
+	rec.StorageClass = info.StorageClass
+	rec.PendingUntil = nil
+	rec.Exif = s.objectExif(ctx, store, rec, logger)
+	if s.moderation != nil {
+		if rec.Moderation, err = s.moderateUploaded(ctx, store, rec, logger); err != nil {
+			return nil, err
+		}
+	}
+	sum, spool, err := s.readUploaded(ctx, store, rec, logger)
+	if err != nil {
+		return nil, err
+	}
+	rec.SHA256 = sum
+	upload := images.UploadRequest{Name: rec.Name, ContentType: rec.ContentType}
+	rec.Mirrors = s.mirrorUpload(ctx, rec.Key, spool, s.pendingPutOptions(upload, rec.Tags), logger)
+	s.derive(ctx, rec, logger)
+	if err := s.writer.Update(ctx, rec); err != nil {
+		const msg = "unable to complete image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("successfully confirmed upload")
+
+	return rec, nil
+}
+
+// readUploaded downloads the pending record's object to compute its sha256
+// and, when the service mirrors uploads, returns the spooled object to copy
+// to the mirror. The spool must be discarded by the caller unless it is
+// passed to mirrorUpload.
+func (s *Service) readUploaded(ctx context.Context, store images.ObjectStore, rec *images.Record, logger *zap.Logger) (string, *spoolFile, error) {
+	f, err := ioutil.TempFile("", "sim-confirm-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return "", nil, fmt.Errorf(msg+": %w", err)
+	}
+	spool := &spoolFile{f}
+
+	n, err := store.Get(ctx, rec.Key, f)
+	if err != nil {
+		spool.discard()
+		const msg = "unable to download uploaded object"
+		logger.Error(msg, zap.Error(err))
+		return "", nil, fmt.Errorf(msg+": %w", err)
+	}
+	hashing := newHashingReader(f)
+	if _, err := io.Copy(ioutil.Discard, hashing); err != nil {
+		spool.discard()
+		const msg = "unable to hash uploaded object"
+		logger.Error(msg, zap.Error(err))
+		return "", nil, fmt.Errorf(msg+": %w", err)
+	}
+	sum, ok := hashing.sum(n)
+	if !ok {
+		logger.Warn("uploaded object was not read in full, not recording its sha256", zap.Int64("read", hashing.size))
+	}
+
+	if s.mirror == nil {
+		spool.discard()
+		return sum, nil, nil
+	}
+
+	return sum, spool, nil
+}
+
+// checkUploaded decodes the header of the pending record's object, removing
+// the object and the record when it is not an image.
+func (s *Service) checkUploaded(ctx context.Context, store images.ObjectStore, rec *images.Record, logger *zap.Logger) error {
+	var head bytes.Buffer
+	if _, err := store.GetRange(ctx, rec.Key, 0, sniffLen, &head); err != nil && err != images.ErrInvalidRange {
+		const msg = "unable to download object header"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	_, invalid := checkImage(&head, logger)
+	if invalid == nil {
+		return nil
+	}
+	s.discardUpload(ctx, store, rec, logger)
+
+	return invalid
+}
+
+// discardUpload removes the object and the record of a pending upload which
+// is refused. The record is kept when the object can not be removed.
+func (s *Service) discardUpload(ctx context.Context, store images.ObjectStore, rec *images.Record, logger *zap.Logger) {
+	if err := store.Delete(ctx, rec.Key); err != nil {
+		logger.Warn("unable to delete refused object", zap.Error(err))
+		return
+	}
+	if err := s.writer.Delete(ctx, rec.ID); err != nil && err != images.ErrRecordNotFound {
+		logger.Warn("unable to delete pending record", zap.Error(err))
+	}
+}
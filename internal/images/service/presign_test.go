@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_PresignUpload(t *testing.T) {
+	signed := &images.PresignedRequest{Method: "PUT", URL: "https://signed", Headers: map[string]string{"Content-Type": "image/png"}}
+	for _, tc := range []struct {
+		desc    string
+		req     images.PresignUploadRequest
+		mocks   func(reader *mock_images.MockReader, writer *mock_images.MockWriter, store *mock_images.MockObjectStore)
+		wantErr error
+	}{
+		{
+			desc: "PresignUpload() should create a pending record and presign its upload",
+			req:  images.PresignUploadRequest{Name: "cat.png", Tags: []string{"pets"}, Expires: time.Hour},
+			mocks: func(reader *mock_images.MockReader, writer *mock_images.MockWriter, store *mock_images.MockObjectStore) {
+				reader.EXPECT().GetByName(gomock.Any(), "cat.png").Return(nil, images.ErrRecordNotFound)
+				store.
+					EXPECT().
+					PresignPut(gomock.Any(), gomock.Any(), time.Hour, gomock.Any()).
+					DoAndReturn(func(_ context.Context, key string, _ time.Duration, opts images.PutOptions) (*images.PresignedRequest, error) {
+						assert.Equal(t, "image/png", opts.ContentType)
+						return signed, nil
+					})
+				writer.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, "cat.png", rec.Name)
+						assert.Equal(t, "image/png", rec.ContentType)
+						assert.Equal(t, []string{"pets"}, rec.Tags)
+						assert.NotNil(t, rec.PendingUntil)
+						return nil
+					})
+			},
+		},
+		{
+			desc:    "PresignUpload() should return ErrInvalidExpiry when the expiry is too long",
+			req:     images.PresignUploadRequest{Name: "cat.png", Expires: images.MaxURLExpiry + time.Second},
+			wantErr: images.ErrInvalidExpiry,
+		},
+		{
+			desc:    "PresignUpload() should return ErrTypeNotAllowed when the content type is not allowed",
+			req:     images.PresignUploadRequest{Name: "notes.txt", Expires: time.Hour},
+			wantErr: images.ErrTypeNotAllowed,
+		},
+		{
+			desc: "PresignUpload() should return ErrNameTaken when an image has the name",
+			req:  images.PresignUploadRequest{Name: "cat.png", Expires: time.Hour},
+			mocks: func(reader *mock_images.MockReader, writer *mock_images.MockWriter, store *mock_images.MockObjectStore) {
+				reader.EXPECT().GetByName(gomock.Any(), "cat.png").Return(&images.Record{ID: "1"}, nil)
+			},
+			wantErr: images.ErrNameTaken,
+		},
+		{
+			desc: "PresignUpload() should return ErrUnsupported when the storage can not be uploaded to directly",
+			req:  images.PresignUploadRequest{Name: "cat.png", Expires: time.Hour},
+			mocks: func(reader *mock_images.MockReader, writer *mock_images.MockWriter, store *mock_images.MockObjectStore) {
+				reader.EXPECT().GetByName(gomock.Any(), "cat.png").Return(nil, images.ErrRecordNotFound)
+				store.EXPECT().PresignPut(gomock.Any(), gomock.Any(), time.Hour, gomock.Any()).Return(nil, images.ErrUnsupported)
+			},
+			wantErr: images.ErrUnsupported,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			reader := mock_images.NewMockReader(ctrl)
+			writer := mock_images.NewMockWriter(ctrl)
+			store := mock_images.NewMockObjectStore(ctrl)
+			if tc.mocks != nil {
+				tc.mocks(reader, writer, store)
+			}
+
+			svc, err := New(zap.NewNop(), "sim", reader, writer, images.Stores{"sim": store}, WithAllowedTypes("image/*"))
+			require.NoError(t, err)
+
+			got, err := svc.PresignUpload(context.Background(), tc.req)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, got.ImageID)
+			assert.Equal(t, *signed, got.Request)
+		})
+	}
+}
+
+func Test_Service_ConfirmUpload(t *testing.T) {
+	pendingUntil := time.Now().Add(time.Hour)
+	body := "0123456789"
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(body)))
+	pending := func() *images.Record {
+		until := pendingUntil
+		return &images.Record{ID: "1", Key: "key", Storage: "sim", PendingUntil: &until}
+	}
+	for _, tc := range []struct {
+		desc    string
+		rec     *images.Record
+		mocks   func(writer *mock_images.MockWriter, store *mock_images.MockObjectStore)
+		wantErr error
+	}{
+		{
+			desc: "ConfirmUpload() should complete the record from its object",
+			rec:  pending(),
+			mocks: func(writer *mock_images.MockWriter, store *mock_images.MockObjectStore) {
+				store.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 10}, nil)
+				expectGet(store, "key", body)
+				writer.
+					EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, "etag", rec.ETag)
+						assert.Equal(t, sum, rec.SHA256)
+						assert.Equal(t, int64(10), rec.SizeInBytes)
+						assert.Nil(t, rec.PendingUntil)
+						assert.Empty(t, rec.Mirrors)
+						return nil
+					})
+			},
+		},
+		{
+			desc: "ConfirmUpload() should return ErrNotPending when the upload expired",
+			rec: func() *images.Record {
+				rec := pending()
+				expired := time.Now().Add(-time.Minute)
+				rec.PendingUntil = &expired
+				return rec
+			}(),
+			wantErr: images.ErrNotPending,
+		},
+		{
+			desc:    "ConfirmUpload() should return ErrNotPending when the image is not pending",
+			rec:     &images.Record{ID: "1", Key: "key", Storage: "sim"},
+			wantErr: images.ErrNotPending,
+		},
+		{
+			desc: "ConfirmUpload() should return ErrObjectNotFound when the object was not uploaded",
+			rec:  pending(),
+			mocks: func(writer *mock_images.MockWriter, store *mock_images.MockObjectStore) {
+				store.EXPECT().Head(gomock.Any(), "key").Return(nil, images.ErrObjectNotFound)
+			},
+			wantErr: images.ErrObjectNotFound,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			reader := mock_images.NewMockReader(ctrl)
+			reader.EXPECT().Get(gomock.Any(), "1").Return(tc.rec, nil)
+			writer := mock_images.NewMockWriter(ctrl)
+			store := mock_images.NewMockObjectStore(ctrl)
+			if tc.mocks != nil {
+				tc.mocks(writer, store)
+			}
+
+			svc, err := New(zap.NewNop(), "sim", reader, writer, images.Stores{"sim": store})
+			require.NoError(t, err)
+
+			got, err := svc.ConfirmUpload(context.Background(), "1")
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Nil(t, got.PendingUntil)
+		})
+	}
+
+	t.Run("ConfirmUpload() should copy the object to the mirror", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		reader := mock_images.NewMockReader(ctrl)
+		reader.EXPECT().Get(gomock.Any(), "1").Return(pending(), nil)
+		store, mirror := mock_images.NewMockObjectStore(ctrl), mock_images.NewMockObjectStore(ctrl)
+		store.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 10}, nil)
+		expectGet(store, "key", body)
+		mirror.
+			EXPECT().
+			Put(gomock.Any(), "key", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, r io.Reader, _ images.PutOptions) error {
+				b, err := ioutil.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, body, string(b))
+				return nil
+			})
+		writer := mock_images.NewMockWriter(ctrl)
+		writer.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+
+		svc, err := New(zap.NewNop(), "sim", reader, writer, images.Stores{"sim": store, "mirror": mirror}, WithMirror("mirror", false))
+		require.NoError(t, err)
+
+		got, err := svc.ConfirmUpload(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"mirror"}, got.Mirrors)
+		assert.Equal(t, sum, got.SHA256)
+	})
+}
+
+// expectGet expects the object at the key to be downloaded, writing the body.
+func expectGet(store *mock_images.MockObjectStore, key, body string) {
+	store.
+		EXPECT().
+		Get(gomock.Any(), key, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, w io.WriterAt) (int64, error) {
+			n, err := w.WriteAt([]byte(body), 0)
+			return int64(n), err
+		})
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// ranged reports whether the request downloads a byte range of the object
+// rather than all of it.
+func ranged(r images.DownloadRequest) bool {
+	return r.Offset != 0 || r.Length != 0
+}
+
+// downloadRange downloads the request's byte range of the record's object to
+// the start of its Stream, or into its Writer. The range must start within the object, the
+// number of bytes downloaded is checked against what the record's size
+// leaves of it from the offset.
+func (s *Service) downloadRange(ctx context.Context, rec *images.Record, store images.ObjectStore, r images.DownloadRequest, logger *zap.Logger) error {
+	logger = logger.With(zap.Int64("offset", r.Offset), zap.Int64("length", r.Length))
+
+	switch {
+	case r.Offset < 0 || r.Length < 0 || r.Offset >= rec.SizeInBytes:
+		logger.Error("range is not satisfiable", zap.Int64("size", rec.SizeInBytes))
+		return fmt.Errorf("%w: offset %d and length %d of %d bytes", images.ErrInvalidRange, r.Offset, r.Length, rec.SizeInBytes)
+	case encoded(rec):
+		logger.Error("range of a compressed or encrypted object")
+		return fmt.Errorf("%w: image is stored compressed or encrypted", images.ErrInvalidRange)
+	}
+
+	want := rec.SizeInBytes - r.Offset
+	if r.Length > 0 && r.Length < want {
+		want = r.Length
+	}
+
+	n, err := store.GetRange(ctx, rec.Key, r.Offset, r.Length, output(r))
+	switch err {
+	case nil:
+	case images.ErrObjectNotFound, images.ErrInvalidRange:
+		logger.Error("unable to download range", zap.Error(err))
+		return err
+	default:
+		const msg = "unable to download range"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if n != want {
+		logger.Error("downloaded size does not match range", zap.Int64("expected", want), zap.Int64("actual", n))
+		return fmt.Errorf("%w: expected %d bytes, downloaded %d", images.ErrIntegrity, want, n)
+	}
+	logger.Info("successfully downloaded range")
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Download_range(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		rec     images.Record
+		offset  int64
+		length  int64
+		body    string
+		err     error
+		want    string
+		wantErr error
+	}{
+		{
+			desc:   "Download() should download the byte range to the start of the stream",
+			rec:    images.Record{SizeInBytes: 13},
+			offset: 7,
+			length: 5,
+			body:   "World",
+			want:   "World",
+		},
+		{
+			desc:   "Download() should download the rest of the object from the offset",
+			rec:    images.Record{SizeInBytes: 13},
+			offset: 7,
+			body:   "World!",
+			want:   "World!",
+		},
+		{
+			desc:   "Download() should clip a range past the end of the object",
+			rec:    images.Record{SizeInBytes: 13},
+			offset: 7,
+			length: 100,
+			body:   "World!",
+			want:   "World!",
+		},
+		{
+			desc:    "Download() should return ErrInvalidRange when the offset is past the object",
+			rec:     images.Record{SizeInBytes: 13},
+			offset:  13,
+			wantErr: images.ErrInvalidRange,
+		},
+		{
+			desc:    "Download() should return ErrInvalidRange for a range of a compressed object",
+			rec:     images.Record{SizeInBytes: 13, ContentEncoding: "gzip"},
+			length:  5,
+			wantErr: images.ErrInvalidRange,
+		},
+		{
+			desc:    "Download() should return ErrIntegrity when the range is short",
+			rec:     images.Record{SizeInBytes: 13},
+			offset:  7,
+			length:  5,
+			body:    "Wor",
+			wantErr: images.ErrIntegrity,
+		},
+		{
+			desc:    "Download() should return ErrObjectNotFound when the object does not exist",
+			rec:     images.Record{SizeInBytes: 13},
+			length:  5,
+			err:     images.ErrObjectNotFound,
+			wantErr: images.ErrObjectNotFound,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			rec := tc.rec
+			rec.ID, rec.Key, rec.Storage = "1", "key", "sim"
+			r, s := mock_images.NewMockReader(ctrl), mock_images.NewMockObjectStore(ctrl)
+			r.EXPECT().Get(gomock.Any(), "1").Return(&rec, nil)
+			if tc.body != "" || tc.err != nil {
+				s.
+					EXPECT().
+					GetRange(gomock.Any(), "key", tc.offset, tc.length, gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+						if tc.err != nil {
+							return 0, tc.err
+						}
+						return io.Copy(w, bytes.NewBufferString(tc.body))
+					})
+			}
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			stream := manager.NewWriteAtBuffer(nil)
+			err = svc.Download(context.Background(), images.DownloadRequest{ID: "1", Stream: stream, Offset: tc.offset, Length: tc.length})
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(stream.Bytes()))
+		})
+	}
+}
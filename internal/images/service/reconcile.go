@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// WithReconciler finishes interrupted deletes in the background, every
+// interval the records marked as deleting for longer than grace are passed
+// to ReconcileDeletes. Close stops the reconciler.
+func WithReconciler(interval, grace time.Duration) Option {
+	return func(s *Service) {
+		s.reconciler = &reconciler{
+			grace:    grace,
+			interval: interval,
+		}
+	}
+}
+
+type reconciler struct {
+	grace    time.Duration
+	interval time.Duration
+
+	cancel context.CancelFunc
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+func (r *reconciler) start(s *Service) {
+	if r == nil {
+		return
+	}
+
+	// closing cancels a reconcile in flight as well
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.logger.Debug("delete reconciler stopped")
+				return
+			case <-ticker.C:
+				// failures are logged by ReconcileDeletes
+				_, _ = s.ReconcileDeletes(ctx, r.grace)
+			}
+		}
+	}()
+}
+
+func (r *reconciler) close() {
+	if r == nil {
+		return
+	}
+
+	r.once.Do(r.cancel)
+	r.wg.Wait()
+}
+
+// ReconcileDeletes finishes the deletes which were interrupted after the
+// record was marked as deleting, removing the object and then the record.
+// Records marked less than grace ago are skipped as their delete may still
+// be running. Returns the number of deletes finished and a BatchError holding
+// the records which could not be.
+func (s *Service) ReconcileDeletes(ctx context.Context, grace time.Duration) (int, error) {
+	s.logger.Info("attempting to reconcile deletes")
+
+	opts := images.ListOptions{
+		Limit:  searchPageSize,
+		Filter: images.ListFilter{Deleting: true},
+	}
+	cutoff := time.Now().Add(-grace)
+
+	var finished int
+	failed := make(images.BatchError)
+	for {
+		page, err := s.reader.List(ctx, opts)
+		switch err {
+		case nil:
+		case images.ErrRecordNotFound:
+			page = new(images.Page)
+		default:
+			const msg = "unable to list records being deleted"
+			s.logger.Error(msg, zap.Error(err))
+			return finished, fmt.Errorf(msg+": %w", err)
+		}
+
+		for i := range page.Records {
+			rec := &page.Records[i]
+			if rec.DeletingAt == nil || rec.DeletingAt.After(cutoff) {
+				continue
+			}
+			logger := s.logger.With(zap.String("imageId", rec.ID))
+			if err := s.finishDelete(ctx, rec, logger); err != nil {
+				failed[rec.ID] = err
+				continue
+			}
+			logger.Info("finished interrupted delete")
+			finished++
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	if len(failed) > 0 {
+		s.logger.Error("unable to finish deletes", zap.Error(failed))
+		return finished, failed
+	}
+	s.logger.Info("successfully reconciled deletes", zap.Int("finished", finished))
+
+	return finished, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_ReconcileDeletes(t *testing.T) {
+	deleting := images.ListOptions{Limit: searchPageSize, Filter: images.ListFilter{Deleting: true}}
+	old, recent := time.Now().Add(-time.Hour), time.Now()
+	for _, tc := range []struct {
+		desc     string
+		mocks    func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+		finished int
+		wantErr  bool
+	}{
+		{
+			desc: "ReconcileDeletes() should return an error when failing to list the records",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), deleting).Return(nil, errors.New("random"))
+			},
+			wantErr: true,
+		},
+		{
+			desc: "ReconcileDeletes() should do nothing when no records are being deleted",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().List(gomock.Any(), deleting).Return(nil, images.ErrRecordNotFound)
+			},
+		},
+		{
+			desc: "ReconcileDeletes() should finish the deletes marked before the grace period",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.
+					EXPECT().
+					List(gomock.Any(), deleting).
+					Return(&images.Page{Records: []images.Record{
+						{ID: "1", Key: "1", Storage: "sim", DeletingAt: &old},
+						{ID: "2", Key: "2", Storage: "sim", DeletingAt: &recent},
+						{ID: "3", Key: "3", Storage: "sim", DeletingAt: &old},
+					}}, nil)
+				s.EXPECT().Delete(gomock.Any(), "1").Return(images.ErrObjectNotFound)
+				w.EXPECT().Delete(gomock.Any(), "1").Return(nil)
+				s.EXPECT().Delete(gomock.Any(), "3").Return(errors.New("random"))
+			},
+			finished: 1,
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			tc.mocks(r, w, s)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			finished, err := svc.ReconcileDeletes(context.Background(), time.Minute)
+			assert.Equal(t, tc.finished, finished)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// Rename gives the image record the name and returns the updated record.
+// Returns ErrNameTaken if another image has the name, this is a best effort
+// check like the one of Upload, and ErrRecordNotFound if no record exists by
+// the ID.
+func (s *Service) Rename(ctx context.Context, id, name string) (*images.Record, error) {
+	logger := s.logger.With(zap.String("imageId", id), zap.String("imageName", name))
+
+	if name == "" {
+		logger.Error("no name")
+		return nil, errors.New("an image name is required")
+	}
+
+	existing, err := s.named(ctx, name, logger)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.ID == id {
+			return existing, nil
+		}
+		logger.Error("name taken", zap.String("existingId", existing.ID))
+		return nil, images.ErrNameTaken
+	}
+
+	rec, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rec.Name = name
+	if err := s.Update(ctx, rec); err != nil {
+		return nil, err
+	}
+	logger.Info("successfully renamed image")
+
+	return rec, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Rename(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		name    string
+		mocks   func(r *mock_images.MockReader, w *mock_images.MockWriter)
+		want    *images.Record
+		wantErr bool
+		errIs   error
+	}{
+		{
+			desc: "Rename() should update the name of the record",
+			name: "b.png",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter) {
+				r.EXPECT().GetByName(gomock.Any(), "b.png").Return(nil, images.ErrRecordNotFound)
+				r.EXPECT().Get(gomock.Any(), "id").Return(&images.Record{ID: "id", Name: "a.png"}, nil)
+				w.EXPECT().Update(gomock.Any(), &images.Record{ID: "id", Name: "b.png"}).Return(nil)
+			},
+			want: &images.Record{ID: "id", Name: "b.png"},
+		},
+		{
+			desc: "Rename() should return ErrNameTaken when another image has the name",
+			name: "b.png",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter) {
+				r.EXPECT().GetByName(gomock.Any(), "b.png").Return(&images.Record{ID: "other", Name: "b.png"}, nil)
+			},
+			wantErr: true,
+			errIs:   images.ErrNameTaken,
+		},
+		{
+			desc: "Rename() should not update the record when it has the name",
+			name: "a.png",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter) {
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(&images.Record{ID: "id", Name: "a.png"}, nil)
+			},
+			want: &images.Record{ID: "id", Name: "a.png"},
+		},
+		{
+			desc:    "Rename() should reject an empty name",
+			mocks:   func(r *mock_images.MockReader, w *mock_images.MockWriter) {},
+			wantErr: true,
+		},
+		{
+			desc: "Rename() should return ErrRecordNotFound when there is no record by the ID",
+			name: "b.png",
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter) {
+				r.EXPECT().GetByName(gomock.Any(), "b.png").Return(nil, images.ErrRecordNotFound)
+				r.EXPECT().Get(gomock.Any(), "id").Return(nil, images.ErrRecordNotFound)
+			},
+			wantErr: true,
+			errIs:   images.ErrRecordNotFound,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl)
+			tc.mocks(r, w)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)})
+			require.NoError(t, err)
+
+			rec, err := svc.Rename(context.Background(), "id", tc.name)
+			if tc.wantErr {
+				assert.Error(t, err)
+				if tc.errIs != nil {
+					assert.True(t, errors.Is(err, tc.errIs), err)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, rec)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// Repair fixes the inconsistencies found by Fsck. Records whose object
+// differs are refreshed from it, mirrors without a matching copy are dropped
+// from the record and records whose object is missing are deleted when
+// r.RemoveMissing is set. Inconsistencies which need a person to look at
+// them, i.e. a missing name, are skipped. The records are re-read so a stale
+// report does not undo later writes.
+func (s *Service) Repair(ctx context.Context, r images.RepairRequest) (*images.RepairResult, error) {
+	s.logger.Info("attempting to repair records", zap.Int("inconsistencies", len(r.Inconsistencies)))
+
+	var (
+		ids      []string
+		problems = make(map[string][]images.Inconsistency)
+	)
+	for _, i := range r.Inconsistencies {
+		if _, ok := problems[i.ImageID]; !ok {
+			ids = append(ids, i.ImageID)
+		}
+		problems[i.ImageID] = append(problems[i.ImageID], i)
+	}
+
+	var res images.RepairResult
+	for _, id := range ids {
+		s.repairRecord(ctx, id, problems[id], r.RemoveMissing, &res)
+	}
+
+	if r.Checksums {
+		if err := s.repairChecksums(ctx, r.ChecksumFilter, &res); err != nil {
+			return nil, err
+		}
+	}
+	s.logger.Info(
+		"successfully repaired records",
+		zap.Int("refreshed", len(res.Refreshed)),
+		zap.Int("removed", len(res.Removed)),
+		zap.Int("checksummed", len(res.Checksummed)),
+		zap.Int("skipped", len(res.Skipped)),
+		zap.Int("failed", len(res.Failed)),
+	)
+
+	return &res, nil
+}
+
+func (s *Service) repairRecord(ctx context.Context, id string, problems []images.Inconsistency, removeMissing bool, res *images.RepairResult) {
+	logger := s.logger.With(zap.String("imageId", id))
+
+	rec, err := s.reader.Get(ctx, id)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		logger.Info("record no longer exists")
+		return
+	default:
+		logger.Error("unable to retrieve image record", zap.Error(err))
+		res.Failed = append(res.Failed, id)
+		return
+	}
+
+	var (
+		missing     []images.Inconsistency
+		refresh     bool
+		dropMirrors = make(map[string]bool)
+	)
+	for _, p := range problems {
+		mismatch := p.Problem == images.ProblemSizeMismatch || p.Problem == images.ProblemETagMismatch
+		switch {
+		case p.Problem == images.ProblemMissingObject:
+			missing = append(missing, p)
+		case p.Problem == images.ProblemMissingMirror, mismatch && p.Storage != rec.Storage:
+			dropMirrors[p.Storage] = true
+		case mismatch, p.Problem == images.ProblemMissingField && p.Field == "etag":
+			refresh = true
+		default:
+			res.Skipped = append(res.Skipped, p)
+		}
+	}
+
+	if len(missing) > 0 {
+		if !removeMissing {
+			res.Skipped = append(res.Skipped, missing...)
+			return
+		}
+		if err := s.Delete(ctx, rec.ID); err != nil {
+			res.Failed = append(res.Failed, id)
+			return
+		}
+		logger.Info("removed record of missing object")
+		res.Removed = append(res.Removed, id)
+		return
+	}
+	if !refresh && len(dropMirrors) == 0 {
+		return
+	}
+
+	if refresh {
+		store, err := s.store(rec.Storage, logger)
+		if err != nil {
+			res.Failed = append(res.Failed, id)
+			return
+		}
+		info, err := store.Head(ctx, rec.Key)
+		if err != nil {
+			logger.Error("unable to head object", zap.Error(err))
+			res.Failed = append(res.Failed, id)
+			return
+		}
+		rec.ETag = info.ETag
+		rec.SizeInBytes = info.SizeInBytes
+	}
+
+	var mirrors []string
+	for _, m := range rec.Mirrors {
+		if !dropMirrors[m] {
+			mirrors = append(mirrors, m)
+		}
+	}
+	rec.Mirrors = mirrors
+
+	if err := s.writer.Update(ctx, rec); err != nil {
+		logger.Error("unable to update image record", zap.Error(err))
+		res.Failed = append(res.Failed, id)
+		return
+	}
+	logger.Info("refreshed record from its object")
+	res.Refreshed = append(res.Refreshed, id)
+}
+
+// repairChecksums computes the SHA-256 digest of the records matching the
+// filter which do not have one.
+func (s *Service) repairChecksums(ctx context.Context, filter images.ListFilter, res *images.RepairResult) error {
+	opts := images.ListOptions{
+		Limit:  searchPageSize,
+		Filter: filter,
+	}
+	for {
+		page, err := s.reader.List(ctx, opts)
+		switch err {
+		case nil:
+		case images.ErrRecordNotFound:
+			page = new(images.Page)
+		default:
+			const msg = "unable to list records"
+			s.logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+
+		for i := range page.Records {
+			rec := &page.Records[i]
+			if rec.SHA256 != "" || rec.DeletingAt != nil || rec.PendingUntil != nil || !filter.Match(rec) {
+				continue
+			}
+			if err := s.checksum(ctx, rec); err != nil {
+				res.Failed = append(res.Failed, rec.ID)
+				continue
+			}
+			res.Checksummed = append(res.Checksummed, rec.ID)
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// checksum downloads the record's object to compute and store its SHA-256
+// digest.
+func (s *Service) checksum(ctx context.Context, rec *images.Record) error {
+	logger := s.logger.With(zap.String("imageId", rec.ID))
+
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile("", "sim-checksum-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := store.Get(ctx, rec.Key, f); err != nil {
+		const msg = "unable to download object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		const msg = "unable to seek temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	// the sha256 of an encoded object is of its decoded content
+	content, err := s.decode(rec, f)
+	if err != nil {
+		const msg = "unable to decode object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		const msg = "unable to checksum object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	rec.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if err := s.writer.Update(ctx, rec); err != nil {
+		const msg = "unable to update image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Repair(t *testing.T) {
+	rec := func() *images.Record {
+		return &images.Record{ID: "1", Key: "key", Storage: "sim", ETag: "old", SizeInBytes: 5, Mirrors: []string{"mirror"}}
+	}
+	sum := sha256.Sum256([]byte("hw"))
+	for _, tc := range []struct {
+		desc  string
+		req   images.RepairRequest
+		mocks func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+		want  *images.RepairResult
+	}{
+		{
+			desc: "Repair() should refresh the record from its object and drop missing mirrors",
+			req: images.RepairRequest{Inconsistencies: []images.Inconsistency{
+				{ImageID: "1", Problem: images.ProblemETagMismatch, Storage: "sim"},
+				{ImageID: "1", Problem: images.ProblemMissingMirror, Storage: "mirror"},
+				{ImageID: "1", Problem: images.ProblemMissingField, Field: "createdAt"},
+			}},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().Get(gomock.Any(), "1").Return(rec(), nil)
+				s.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{ETag: "new", SizeInBytes: 10}, nil)
+				w.
+					EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, "new", rec.ETag)
+						assert.Equal(t, int64(10), rec.SizeInBytes)
+						assert.Empty(t, rec.Mirrors)
+						return nil
+					})
+			},
+			want: &images.RepairResult{
+				Refreshed: []string{"1"},
+				Skipped:   []images.Inconsistency{{ImageID: "1", Problem: images.ProblemMissingField, Field: "createdAt"}},
+			},
+		},
+		{
+			desc: "Repair() should skip records whose object is missing unless asked to remove them",
+			req: images.RepairRequest{Inconsistencies: []images.Inconsistency{
+				{ImageID: "1", Problem: images.ProblemMissingObject, Storage: "sim"},
+			}},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().Get(gomock.Any(), "1").Return(rec(), nil)
+			},
+			want: &images.RepairResult{
+				Skipped: []images.Inconsistency{{ImageID: "1", Problem: images.ProblemMissingObject, Storage: "sim"}},
+			},
+		},
+		{
+			desc: "Repair() should remove records whose object is missing",
+			req: images.RepairRequest{
+				Inconsistencies: []images.Inconsistency{
+					{ImageID: "1", Problem: images.ProblemMissingObject, Storage: "sim"},
+					{ImageID: "2", Problem: images.ProblemSizeMismatch, Storage: "sim"},
+				},
+				RemoveMissing: true,
+			},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().Get(gomock.Any(), "1").Return(&images.Record{ID: "1", Key: "key", Storage: "sim"}, nil).Times(2)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+				s.EXPECT().Delete(gomock.Any(), "key").Return(images.ErrObjectNotFound)
+				w.EXPECT().Delete(gomock.Any(), "1").Return(nil)
+				r.EXPECT().Get(gomock.Any(), "2").Return(nil, errors.New("random"))
+			},
+			want: &images.RepairResult{
+				Removed: []string{"1"},
+				Failed:  []string{"2"},
+			},
+		},
+		{
+			desc: "Repair() should compute the missing checksums",
+			req:  images.RepairRequest{Checksums: true},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.
+					EXPECT().
+					List(gomock.Any(), images.ListOptions{Limit: searchPageSize}).
+					Return(&images.Page{Records: []images.Record{{ID: "1", Key: "key", Storage: "sim"}, {ID: "2", SHA256: "sum"}}}, nil)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					DoAndReturn(func(_ context.Context, key string, stream io.WriterAt) (int64, error) {
+						n, err := stream.WriteAt([]byte("hw"), 0)
+						return int64(n), err
+					})
+				w.
+					EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, hex.EncodeToString(sum[:]), rec.SHA256)
+						return nil
+					})
+			},
+			want: &images.RepairResult{Checksummed: []string{"1"}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			tc.mocks(r, w, s)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			got, err := svc.Repair(context.Background(), tc.req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// maxCandidates is the number of image IDs an ambiguous ID prefix reports.
+const maxCandidates = 10
+
+// Resolve retrieves the image record by id or, when no image has the id, by
+// a unique prefix of its ID of at least images.MinIDPrefix characters like a
+// short git hash. Returns ErrRecordNotFound if no image matches and an
+// AmbiguousIDError listing the candidates if several images' IDs start with
+// the prefix.
+func (s *Service) Resolve(ctx context.Context, id string) (*images.Record, error) {
+	logger := s.logger.With(zap.String("imageId", id))
+
+	rec, err := s.reader.Get(ctx, id)
+	switch {
+	case err == nil:
+		return rec, nil
+	case err != images.ErrRecordNotFound:
+		const msg = "unable to retrieve image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	case len(id) < images.MinIDPrefix:
+		logger.Error("record not found", zap.Error(err))
+		return nil, err
+	}
+
+	list, err := s.reader.GetByIDPrefix(ctx, id, maxCandidates+1)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		logger.Error("record not found", zap.Error(err))
+		return nil, err
+	default:
+		const msg = "unable to retrieve image records by id prefix"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	if len(list) == 1 {
+		logger.Debug("resolved id prefix", zap.String("resolvedId", list[0].ID))
+		return &list[0], nil
+	}
+
+	ambiguous := images.AmbiguousIDError{Prefix: id}
+	if len(list) > maxCandidates {
+		list, ambiguous.More = list[:maxCandidates], true
+	}
+	for i := range list {
+		ambiguous.Candidates = append(ambiguous.Candidates, list[i].ID)
+	}
+	logger.Error("id prefix is ambiguous", zap.Strings("candidates", ambiguous.Candidates))
+
+	return nil, &ambiguous
+}
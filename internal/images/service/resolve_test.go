@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Resolve(t *testing.T) {
+	many := make([]images.Record, maxCandidates+1)
+	for i := range many {
+		many[i].ID = "abcd" + strconv.Itoa(i)
+	}
+	for _, tc := range []struct {
+		desc    string
+		id      string
+		reader  func(ctrl *gomock.Controller) images.Reader
+		want    string
+		wantErr error
+	}{
+		{
+			desc: "Resolve() should return the record with the id",
+			id:   "abcdef",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().Get(gomock.Any(), "abcdef").Return(&images.Record{ID: "abcdef"}, nil)
+
+				return r
+			},
+			want: "abcdef",
+		},
+		{
+			desc: "Resolve() should return the record whose id starts with the prefix",
+			id:   "abcd",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().Get(gomock.Any(), "abcd").Return(nil, images.ErrRecordNotFound)
+				r.EXPECT().GetByIDPrefix(gomock.Any(), "abcd", maxCandidates+1).Return([]images.Record{{ID: "abcdef"}}, nil)
+
+				return r
+			},
+			want: "abcdef",
+		},
+		{
+			desc: "Resolve() should not look up a prefix shorter than the minimum",
+			id:   "abc",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().Get(gomock.Any(), "abc").Return(nil, images.ErrRecordNotFound)
+
+				return r
+			},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc: "Resolve() should return ErrRecordNotFound when no id starts with the prefix",
+			id:   "abcd",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().Get(gomock.Any(), "abcd").Return(nil, images.ErrRecordNotFound)
+				r.EXPECT().GetByIDPrefix(gomock.Any(), "abcd", maxCandidates+1).Return(nil, images.ErrRecordNotFound)
+
+				return r
+			},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc: "Resolve() should return an AmbiguousIDError when several ids start with the prefix",
+			id:   "abcd",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().Get(gomock.Any(), "abcd").Return(nil, images.ErrRecordNotFound)
+				r.EXPECT().GetByIDPrefix(gomock.Any(), "abcd", maxCandidates+1).Return([]images.Record{{ID: "abcd1"}, {ID: "abcd2"}}, nil)
+
+				return r
+			},
+			wantErr: &images.AmbiguousIDError{Prefix: "abcd", Candidates: []string{"abcd1", "abcd2"}},
+		},
+		{
+			desc: "Resolve() should report more candidates than it lists",
+			id:   "abcd",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().Get(gomock.Any(), "abcd").Return(nil, images.ErrRecordNotFound)
+				r.EXPECT().GetByIDPrefix(gomock.Any(), "abcd", maxCandidates+1).Return(many, nil)
+
+				return r
+			},
+			wantErr: &images.AmbiguousIDError{Prefix: "abcd", Candidates: []string{"abcd0", "abcd1", "abcd2", "abcd3", "abcd4", "abcd5", "abcd6", "abcd7", "abcd8", "abcd9"}, More: true},
+		},
+		{
+			desc: "Resolve() should return an error when failing to get the record",
+			id:   "abcd",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.EXPECT().Get(gomock.Any(), "abcd").Return(nil, errors.New("random"))
+
+				return r
+			},
+			wantErr: errors.New("random"),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			svc, err := New(zap.NewNop(), "sim", tc.reader(ctrl), mock_images.NewMockWriter(ctrl), images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)})
+			require.NoError(t, err)
+
+			got, err := svc.Resolve(context.Background(), tc.id)
+			var ambiguous *images.AmbiguousIDError
+			switch {
+			case tc.wantErr == images.ErrRecordNotFound:
+				assert.Equal(t, tc.wantErr, err)
+			case errors.As(tc.wantErr, &ambiguous):
+				assert.Equal(t, tc.wantErr, err)
+				assert.True(t, errors.Is(err, images.ErrAmbiguousID))
+			case tc.wantErr != nil:
+				assert.Error(t, err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// Restore requests a copy of the archived image's object, restored in the
+// tier, which can be downloaded for the number of days and returns the
+// status of the restore. Restoring an image which was already restored
+// extends how long its copy is kept, one which is being restored is left
+// as is. Returns ErrNotArchived if the object is not archived, see
+// RestoreStatus.
+func (s *Service) Restore(ctx context.Context, id string, tier images.RestoreTier, days int) (*images.RestoreStatus, error) {
+	logger := s.logger.With(zap.String("imageId", id), zap.String("tier", string(tier)), zap.Int("days", days))
+	logger.Info("attempting to restore object")
+
+	switch {
+	case !tier.Valid():
+		logger.Error("unknown restore tier")
+		return nil, fmt.Errorf("%w: %s, expected Expedited, Standard or Bulk", images.ErrInvalidTier, tier)
+	case days < 1:
+		logger.Error("restore days out of range")
+		return nil, images.ErrInvalidDays
+	}
+
+	rec, store, status, err := s.archived(ctx, id, logger)
+	if err != nil {
+		return nil, err
+	}
+	if status != nil && status.InProgress {
+		logger.Info("restore already in progress")
+		return status, nil
+	}
+
+	err = store.Restore(ctx, rec.Key, tier, days)
+	switch err {
+	case nil:
+	case images.ErrRestoring:
+		logger.Info("restore already in progress")
+		return &images.RestoreStatus{InProgress: true}, nil
+	default:
+		const msg = "unable to restore object"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("successfully requested restore")
+
+	// a restore of a restored object only extends its expiry
+	info, err := store.Head(ctx, rec.Key)
+	if err != nil || info.Restore == nil {
+		return &images.RestoreStatus{InProgress: true}, nil
+	}
+
+	return info.Restore, nil
+}
+
+// RestoreStatus returns the status of the restore of the archived image's
+// object, nil when none was requested. Returns ErrNotArchived if the object
+// is not archived.
+func (s *Service) RestoreStatus(ctx context.Context, id string) (*images.RestoreStatus, error) {
+	logger := s.logger.With(zap.String("imageId", id))
+
+	_, _, status, err := s.archived(ctx, id, logger)
+
+	return status, err
+}
+
+// archived returns the image's record, the store of its object and the
+// status of the object's restore, returning ErrNotArchived if the object is
+// not archived.
+func (s *Service) archived(ctx context.Context, id string, logger *zap.Logger) (*images.Record, images.ObjectStore, *images.RestoreStatus, error) {
+	rec, err := s.downloadRecord(ctx, id, logger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	info, err := store.Head(ctx, rec.Key)
+	switch {
+	case err == images.ErrObjectNotFound:
+		logger.Error("object not found")
+		return nil, nil, nil, err
+	case err != nil:
+		const msg = "unable to head object"
+		logger.Error(msg, zap.Error(err))
+		return nil, nil, nil, fmt.Errorf(msg+": %w", err)
+	case !info.Archived:
+		logger.Error("object is not archived", zap.String("storageClass", info.StorageClass))
+		return nil, nil, nil, images.ErrNotArchived
+	}
+
+	return rec, store, info.Restore, nil
+}
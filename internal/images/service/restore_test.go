@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Restore(t *testing.T) {
+	expires := time.Now().Add(24 * time.Hour)
+	for _, tc := range []struct {
+		desc    string
+		tier    images.RestoreTier
+		days    int
+		store   func(s *mock_images.MockObjectStore)
+		want    *images.RestoreStatus
+		wantErr error
+	}{
+		{
+			desc: "Restore() should request a restore of the archived object",
+			tier: images.RestoreStandard,
+			days: 2,
+			store: func(s *mock_images.MockObjectStore) {
+				gomock.InOrder(
+					s.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{Archived: true}, nil),
+					s.EXPECT().Restore(gomock.Any(), "key", images.RestoreStandard, 2).Return(nil),
+					s.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{Archived: true, Restore: &images.RestoreStatus{InProgress: true}}, nil),
+				)
+			},
+			want: &images.RestoreStatus{InProgress: true},
+		},
+		{
+			desc: "Restore() should return the extended expiry of a restored object",
+			tier: images.RestoreBulk,
+			days: 7,
+			store: func(s *mock_images.MockObjectStore) {
+				gomock.InOrder(
+					s.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{Archived: true, Restore: &images.RestoreStatus{ExpiresAt: &time.Time{}}}, nil),
+					s.EXPECT().Restore(gomock.Any(), "key", images.RestoreBulk, 7).Return(nil),
+					s.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{Archived: true, Restore: &images.RestoreStatus{ExpiresAt: &expires}}, nil),
+				)
+			},
+			want: &images.RestoreStatus{ExpiresAt: &expires},
+		},
+		{
+			desc: "Restore() should not request a restore which is in progress",
+			tier: images.RestoreExpedited,
+			days: 1,
+			store: func(s *mock_images.MockObjectStore) {
+				s.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{Archived: true, Restore: &images.RestoreStatus{InProgress: true}}, nil)
+			},
+			want: &images.RestoreStatus{InProgress: true},
+		},
+		{
+			desc: "Restore() should return ErrNotArchived when the object is not archived",
+			tier: images.RestoreStandard,
+			days: 1,
+			store: func(s *mock_images.MockObjectStore) {
+				s.EXPECT().Head(gomock.Any(), "key").Return(&images.ObjectInfo{StorageClass: "STANDARD"}, nil)
+			},
+			wantErr: images.ErrNotArchived,
+		},
+		{
+			desc:    "Restore() should return ErrInvalidTier for an unknown tier",
+			tier:    "Fast",
+			days:    1,
+			wantErr: images.ErrInvalidTier,
+		},
+		{
+			desc:    "Restore() should return ErrInvalidDays when the days are not positive",
+			tier:    images.RestoreStandard,
+			wantErr: images.ErrInvalidDays,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, s := mock_images.NewMockReader(ctrl), mock_images.NewMockObjectStore(ctrl)
+			if tc.store != nil {
+				r.EXPECT().Get(gomock.Any(), "1").Return(&images.Record{ID: "1", Key: "key", Storage: "sim"}, nil)
+				tc.store(s)
+			}
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			got, err := svc.Restore(context.Background(), "1", tc.tier, tc.days)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// seekableBody is the body of a resumable upload, its parts are read from it
+// directly.
+type seekableBody interface {
+	io.ReaderAt
+	io.ReadSeeker
+}
+
+// resumable prepares the upload's session, returning the body its parts are
+// read from and the body's digests. The session records the body's digest and
+// size, and the storage, so that resuming it for another body or storage
+// returns ErrSessionMismatch.
+func (s *Service) resumable(r *images.UploadRequest, storage string, logger *zap.Logger) (io.ReaderAt, *digests, error) {
+	if s.encryptionKey != nil {
+		logger.Error("resumable uploads can not be encrypted")
+		return nil, nil, errors.New("resumable uploads can not be encrypted on the client")
+	}
+	body, ok := r.Body.(seekableBody)
+	if !ok {
+		logger.Error("resumable upload body is not seekable")
+		return nil, nil, errors.New("the body of a resumable upload must be an io.ReaderAt and io.Seeker")
+	}
+
+	sums, _, _, err := hashBody(body, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	size, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		const msg = "unable to seek image"
+		logger.Error(msg, zap.Error(err))
+		return nil, nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	session := r.Session
+	if session.SHA256 != "" && (session.SHA256 != sums.sha256 || session.Size != size || session.Storage != storage) {
+		logger.Error("upload session does not match the upload", zap.String("sessionStorage", session.Storage))
+		return nil, nil, images.ErrSessionMismatch
+	}
+	session.SHA256, session.Size, session.Storage = sums.sha256, size, storage
+	r.Body = io.NewSectionReader(body, 0, size)
+
+	return body, sums, nil
+}
+
+// putParts uploads the body in parts under the key, checkpointing the
+// upload's session as it progresses. A session whose upload no longer exists
+// in storage, i.e. it was aborted, is restarted.
+func (s *Service) putParts(ctx context.Context, store images.ObjectStore, key string, body io.ReaderAt, opts images.PutOptions, r images.UploadRequest, logger *zap.Logger) error {
+	session := r.Session
+	session.Key = key
+	checkpoint := func() error {
+		if r.Checkpoint == nil {
+			return nil
+		}
+		return r.Checkpoint(session)
+	}
+
+	err := store.PutParts(ctx, key, body, opts, session, checkpoint)
+	if err == images.ErrUploadExpired {
+		logger.Warn("upload session expired, restarting the upload", zap.String("uploadId", session.UploadID))
+		session.UploadID, session.Parts = "", nil
+		err = store.PutParts(ctx, key, body, opts, session, checkpoint)
+	}
+
+	return err
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Upload_Session(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		session images.UploadSession
+		mocks   func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+		wantID  string
+		wantErr error
+	}{
+		{
+			desc:    "Upload() should return ErrSessionMismatch when the session is for another body",
+			session: images.UploadSession{SHA256: "other", Size: 2, Storage: "sim"},
+			mocks:   func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {},
+			wantErr: images.ErrSessionMismatch,
+		},
+		{
+			desc:    "Upload() should return the image of a session which already completed",
+			session: images.UploadSession{ImageID: "1"},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().Get(gomock.Any(), "1").Return(&images.Record{ID: "1"}, nil)
+			},
+			wantID: "1",
+		},
+		{
+			desc:    "Upload() should resume the session's upload under its key and restart it once expired",
+			session: images.UploadSession{ImageID: "1", Key: "images/1/a.png", UploadID: "upload"},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				r.EXPECT().Get(gomock.Any(), "1").Return(nil, images.ErrRecordNotFound)
+				r.EXPECT().GetByName(gomock.Any(), "a.png").Return(nil, images.ErrRecordNotFound)
+				s.
+					EXPECT().
+					PutParts(gomock.Any(), "images/1/a.png", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _ io.ReaderAt, _ images.PutOptions, session *images.UploadSession, _ func() error) error {
+						assert.Equal(t, "upload", session.UploadID)
+						assert.Equal(t, int64(2), session.Size)
+						return images.ErrUploadExpired
+					})
+				s.
+					EXPECT().
+					PutParts(gomock.Any(), "images/1/a.png", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _ io.ReaderAt, _ images.PutOptions, session *images.UploadSession, checkpoint func() error) error {
+						assert.Empty(t, session.UploadID)
+						session.Start("restarted")
+						return checkpoint()
+					})
+				s.EXPECT().Head(gomock.Any(), "images/1/a.png").Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 2}, nil)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, "1", rec.ID)
+						assert.NotEmpty(t, rec.SHA256)
+						return nil
+					})
+			},
+			wantID: "1",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			tc.mocks(r, w, s)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			var checkpoints []images.UploadSession
+			id, err := svc.Upload(context.Background(), images.UploadRequest{
+				Name:        "a.png",
+				Body:        bytes.NewReader([]byte("hw")),
+				ContentType: "image/png",
+				Session:     &tc.session,
+				Checkpoint: func(s *images.UploadSession) error {
+					checkpoints = append(checkpoints, *s)
+					return nil
+				},
+			})
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantID, id)
+			for _, c := range checkpoints {
+				assert.Equal(t, "1", c.ImageID)
+				assert.Equal(t, "restarted", c.UploadID)
+			}
+		})
+	}
+}
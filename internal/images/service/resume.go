@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// resumeFile downloads the image into the partial file next to the request's
+// path, returning the partial file's path once it holds the whole image. The
+// bytes the partial file already has are kept and only the rest of the
+// object is downloaded, from their end. The whole file is then checked
+// against the record and removed on ErrIntegrity so that the next attempt
+// starts over. Compressed and encrypted objects can not be downloaded in
+// ranges so they are always downloaded in full.
+func (s *Service) resumeFile(ctx context.Context, r images.DownloadFileRequest, logger *zap.Logger) (string, error) {
+	if r.Offset != 0 || r.Length != 0 {
+		logger.Error("range download can not be resumed")
+		return "", fmt.Errorf("%w: a range download can not be resumed", images.ErrInvalidRange)
+	}
+
+	rec, err := s.downloadRecord(ctx, r.ID, logger)
+	if err != nil {
+		return "", err
+	}
+
+	part := r.Path + images.PartialSuffix
+	logger = logger.With(zap.String("partialPath", part))
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, downloadFileMode)
+	if err != nil {
+		const msg = "unable to open partial file"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		const msg = "unable to stat partial file"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+	have := fi.Size()
+	if have > rec.SizeInBytes || (have > 0 && encoded(rec)) {
+		logger.Warn("partial file can not be resumed, starting over", zap.Int64("size", have))
+		have = 0
+		if err := f.Truncate(0); err != nil {
+			const msg = "unable to truncate partial file"
+			logger.Error(msg, zap.Error(err))
+			return "", fmt.Errorf(msg+": %w", err)
+		}
+	}
+
+	err = s.resumeDownload(ctx, rec, f, have, logger)
+	switch {
+	case err == nil:
+	case errors.Is(err, images.ErrIntegrity):
+		f.Close()
+		os.Remove(part)
+		return "", err
+	default:
+		// what was written is kept for the next attempt
+		f.Sync()
+		return "", err
+	}
+
+	if err := closeFile(f, logger); err != nil {
+		return "", err
+	}
+
+	return part, nil
+}
+
+// resumeDownload downloads the part of the record's object the file does not
+// have yet, the file has the first have bytes of it, and checks the whole
+// file against the record.
+func (s *Service) resumeDownload(ctx context.Context, rec *images.Record, f *os.File, have int64, logger *zap.Logger) error {
+	if encoded(rec) {
+		return s.download(ctx, rec, images.DownloadRequest{ID: rec.ID, Stream: f}, logger)
+	}
+
+	if have < rec.SizeInBytes {
+		logger.Info("resuming download", zap.Int64("offset", have), zap.Int64("size", rec.SizeInBytes))
+		if _, err := f.Seek(have, io.SeekStart); err != nil {
+			const msg = "unable to seek partial file"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		// a download from offset 0 is not a range, it streams the object
+		// and checks it as it goes
+		if err := s.download(ctx, rec, images.DownloadRequest{ID: rec.ID, Writer: f, Offset: have}, logger); err != nil {
+			return err
+		}
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		const msg = "unable to stat partial file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return checkIntegrity(rec, f, fi.Size(), logger)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_DownloadFile_resume(t *testing.T) {
+	const content = "Hello, World!"
+	sum := sha256.Sum256([]byte(content))
+
+	for _, tc := range []struct {
+		desc       string
+		partial    string
+		wantOffset int64
+		err        error
+		want       string
+		wantPart   string
+		wantErr    error
+	}{
+		{
+			desc:       "DownloadFile() should download the rest of the object after the partial file's bytes",
+			partial:    "Hello, ",
+			wantOffset: 7,
+			want:       content,
+		},
+		{
+			desc:       "DownloadFile() should download the whole object without a partial file",
+			wantOffset: 0,
+			want:       content,
+		},
+		{
+			desc:       "DownloadFile() should only check a partial file which is complete",
+			partial:    content,
+			wantOffset: -1,
+			want:       content,
+		},
+		{
+			desc:       "DownloadFile() should start over when the partial file is larger than the object",
+			partial:    content + "extra",
+			wantOffset: 0,
+			want:       content,
+		},
+		{
+			desc:       "DownloadFile() should remove a partial file which does not match the record",
+			partial:    "Howdy, ",
+			wantOffset: 7,
+			wantErr:    images.ErrIntegrity,
+		},
+		{
+			desc:       "DownloadFile() should keep the partial file when the download fails",
+			partial:    "Hello, ",
+			wantOffset: 7,
+			err:        errors.New("random"),
+			wantPart:   "Hello, Wor",
+			wantErr:    errors.New("random"),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			path := filepath.Join(t.TempDir(), "a.png")
+			part := path + images.PartialSuffix
+			if tc.partial != "" {
+				require.NoError(t, ioutil.WriteFile(part, []byte(tc.partial), 0644))
+			}
+
+			rec := images.Record{ID: "1", Key: "key", Storage: "sim", SizeInBytes: int64(len(content)), SHA256: hex.EncodeToString(sum[:])}
+			r, s := mock_images.NewMockReader(ctrl), mock_images.NewMockObjectStore(ctrl)
+			r.EXPECT().Get(gomock.Any(), "1").Return(&rec, nil)
+			if tc.wantOffset >= 0 {
+				s.
+					EXPECT().
+					GetRange(gomock.Any(), "key", tc.wantOffset, int64(0), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, offset, _ int64, w io.Writer) (int64, error) {
+						if tc.err != nil {
+							n, _ := io.WriteString(w, content[offset:offset+3])
+							return int64(n), tc.err
+						}
+						return io.Copy(w, strings.NewReader(content[offset:]))
+					})
+			}
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			err = svc.DownloadFile(context.Background(), images.DownloadFileRequest{ID: "1", Path: path, Resume: true})
+			if tc.wantErr != nil {
+				assert.Error(t, err)
+				if tc.wantErr == images.ErrIntegrity {
+					assert.True(t, errors.Is(err, images.ErrIntegrity))
+				}
+				_, err := os.Stat(path)
+				assert.True(t, os.IsNotExist(err))
+
+				b, err := ioutil.ReadFile(part)
+				if tc.wantPart == "" {
+					assert.True(t, os.IsNotExist(err))
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantPart, string(b))
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(b))
+			_, err = os.Stat(part)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
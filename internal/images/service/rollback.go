@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// rollbackAttempts is how many times the uploaded object is deleted when the
+// upload fails after the object was stored.
+const rollbackAttempts = 3
+
+// rollbackBackoff is the delay before the first retry of the delete, doubled
+// on every retry.
+var rollbackBackoff = 200 * time.Millisecond
+
+// rollbackTimeout bounds the rollback, which does not use the context of the
+// upload as the upload often fails because its context was canceled.
+const rollbackTimeout = 30 * time.Second
+
+// rollbackUpload removes the object of an upload which failed after the
+// object was stored, so that it is not left in storage without a record. The
+// mirrors the object was copied to are removed too. cause is the failure of
+// the upload, the returned error wraps it when the object was removed and
+// ErrOrphanedObject when it could not be.
+func (s *Service) rollbackUpload(store images.ObjectStore, rec *images.Record, cause error, logger *zap.Logger) error {
+	logger = logger.With(zap.String("key", rec.Key))
+	logger.Warn("rolling back uploaded object", zap.Error(cause))
+
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+	backoff := rollbackBackoff
+	var err error
+	for attempt := 1; attempt <= rollbackAttempts; attempt++ {
+		if err = store.Delete(ctx, rec.Key); err == nil || err == images.ErrObjectNotFound {
+			break
+		}
+		logger.Error("unable to delete uploaded object", zap.Int("attempt", attempt), zap.Error(err))
+		if attempt == rollbackAttempts {
+			break
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+	}
+	s.deleteMirrors(ctx, rec, logger)
+
+	if err != nil && err != images.ErrObjectNotFound {
+		logger.Error("uploaded object is orphaned", zap.Error(err))
+		return fmt.Errorf(
+			"%w: object (%s) in storage (%s) remains after: %v, delete error: %v",
+			images.ErrOrphanedObject,
+			rec.Key,
+			rec.Storage,
+			cause,
+			err,
+		)
+	}
+	logger.Info("successfully rolled back uploaded object")
+
+	return fmt.Errorf("%w, the uploaded object was removed", cause)
+}
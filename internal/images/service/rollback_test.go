@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Upload_Rollback(t *testing.T) {
+	t.Run("Upload() should remove the object when the upload is canceled while its record is created", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+		s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{SizeInBytes: 2}, nil)
+		w.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, _ *images.Record) error {
+				cancel()
+				return ctx.Err()
+			})
+		s.EXPECT().Delete(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, _ string) error {
+				// the rollback is not canceled with the upload
+				return ctx.Err()
+			})
+		svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s})
+		assert.NoError(t, err)
+
+		_, err = svc.Upload(ctx, images.UploadRequest{Name: "a", Body: strings.NewReader("hw"), Force: true})
+		assert.True(t, errors.Is(err, context.Canceled), err)
+		assert.False(t, errors.Is(err, images.ErrOrphanedObject), err)
+	})
+}
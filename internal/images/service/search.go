@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// searchPageSize is the number of records read per page while searching.
+const searchPageSize = 500
+
+// Search returns the images matching the query, ordered by opts.Sort and
+// opts.Desc. The query's filter is evaluated by the database and the rest
+// of the query by the service, so every record passing the filter is read.
+// At most opts.Limit images are returned when it is non zero. Returns
+// ErrRecordNotFound if no images match.
+func (s *Service) Search(ctx context.Context, q *images.Query, opts images.ListOptions) ([]images.Image, error) {
+	limit := opts.Limit
+	opts.Limit = searchPageSize
+	opts.Cursor = ""
+	opts.Filter = q.Filter
+
+	var resp []images.Image
+	for {
+		page, err := s.reader.List(ctx, opts)
+		switch err {
+		case nil:
+		case images.ErrRecordNotFound:
+			page = new(images.Page)
+		default:
+			const msg = "unable to list records"
+			s.logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+
+		for i := range page.Records {
+			if !q.Match(&page.Records[i]) {
+				continue
+			}
+			resp = append(resp, toImage(&page.Records[i]))
+			if limit > 0 && len(resp) == limit {
+				return resp, nil
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	if len(resp) == 0 {
+		return nil, images.ErrRecordNotFound
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Search(t *testing.T) {
+	query := images.Query{
+		Filter: images.ListFilter{NamePrefix: "cat", MinSize: 10},
+		Names:  []string{"cat*.png"},
+	}
+	for _, tc := range []struct {
+		desc    string
+		limit   int
+		reader  func(ctrl *gomock.Controller) images.Reader
+		want    []images.Image
+		wantErr error
+	}{
+		{
+			desc: "Search() should return an error when failing to list the records",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					List(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("random"))
+
+				return r
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc: "Search() should return ErrRecordNotFound when no record matches",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					List(gomock.Any(), gomock.Any()).
+					Return(&images.Page{Records: []images.Record{{ID: "1", Name: "cat.jpg", SizeInBytes: 10}}}, nil)
+
+				return r
+			},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc: "Search() should page through the filtered records and match the names",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				gomock.InOrder(
+					r.
+						EXPECT().
+						List(gomock.Any(), images.ListOptions{Limit: searchPageSize, Filter: query.Filter}).
+						Return(&images.Page{
+							Records:    []images.Record{{ID: "1", Name: "cat.jpg", SizeInBytes: 10}, {ID: "2", Name: "cat1.png", SizeInBytes: 10}},
+							NextCursor: "2",
+						}, nil),
+					r.
+						EXPECT().
+						List(gomock.Any(), images.ListOptions{Limit: searchPageSize, Cursor: "2", Filter: query.Filter}).
+						Return(&images.Page{Records: []images.Record{{ID: "3", Name: "cat2.png", SizeInBytes: 10}}}, nil),
+				)
+
+				return r
+			},
+			want: []images.Image{{ID: "2", Name: "cat1.png", SizeInBytes: 10, Size: "10 B", Visibility: images.VisibilityPrivate}, {ID: "3", Name: "cat2.png", SizeInBytes: 10, Size: "10 B", Visibility: images.VisibilityPrivate}},
+		},
+		{
+			desc:  "Search() should stop once the limit is reached",
+			limit: 1,
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					List(gomock.Any(), gomock.Any()).
+					Return(&images.Page{
+						Records:    []images.Record{{ID: "2", Name: "cat1.png", SizeInBytes: 10}, {ID: "3", Name: "cat2.png", SizeInBytes: 10}},
+						NextCursor: "3",
+					}, nil)
+
+				return r
+			},
+			want: []images.Image{{ID: "2", Name: "cat1.png", SizeInBytes: 10, Size: "10 B", Visibility: images.VisibilityPrivate}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			svc, err := New(zap.NewNop(), "sim", tc.reader(ctrl), mock_images.NewMockWriter(ctrl), images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)})
+			require.NoError(t, err)
+
+			got, err := svc.Search(context.Background(), &query, images.ListOptions{Limit: tc.limit})
+			switch {
+			case tc.wantErr == images.ErrRecordNotFound:
+				assert.Equal(t, tc.wantErr, err)
+			case tc.wantErr != nil:
+				assert.Error(t, err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
@@ -1,64 +1,100 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
-	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/itsHabib/sim/internal/images"
-	internalS3 "github.com/itsHabib/sim/internal/s3"
+	"github.com/itsHabib/sim/internal/imaging"
 )
 
 const (
 	loggerName = "images.service"
-	region     = "us-east-1"
+
+	// downloadFileMode is the mode of downloaded files, temp files are
+	// created readable only by the owner.
+	downloadFileMode = 0644
 )
 
 // Service provides the implementation for interacting with images.
 type Service struct {
-	logger        *zap.Logger
-	reader        images.Reader
-	sdk           *sdk
-	sessionGetter images.SessionGetter
-	storage       string
-	writer        images.Writer
+	allowed              []string
+	blurhash             bool
+	cacheControl         string
+	cdn                  *cdn
+	cdnURLs              *cdnURLs
+	compress             bool
+	contentDisposition   string
+	dedup                bool
+	encryptionKey        []byte
+	keys                 *KeyTemplate
+	kmsKeyID             string
+	logger               *zap.Logger
+	mirror               *mirror
+	moderation           *moderation
+	newID                images.IDGenerator
+	objectTags           bool
+	pipelines            map[string]images.Pipeline
+	reader               images.Reader
+	reconciler           *reconciler
+	serverSideEncryption string
+	storage              string
+	storageClass         string
+	stores               images.Stores
+	stripMetadata        bool
+	textExtractor        images.TextExtractor
+	thumbnailSizes       []int
+	validateImages       bool
+	watermark            *imaging.Watermark
+	writer               images.Writer
 }
 
+// Option provides the means to configure optional behavior of the service.
+type Option func(s *Service)
+
 // New returns an instantiated instance of a service which has the
 // following dependencies:
 //
 // logger: for structured logging
 //
-// storage: the cloud storage name i.e. AWS bucket
+// storage: the name of the default storage uploads are sent to
 //
 // reader: for reading image records
 //
 // writer: for writing image records
 //
-// sessionGetter: for configuring the AWS session
-func New(logger *zap.Logger, storage string, reader images.Reader, writer images.Writer, sessionGetter images.SessionGetter) (*Service, error) {
+// stores: for interacting with the objects in cloud storage, by storage name
+//
+// opts: optional behavior i.e. WithMirror or WithReconciler
+func New(logger *zap.Logger, storage string, reader images.Reader, writer images.Writer, stores images.Stores, opts ...Option) (*Service, error) {
 	s := Service{
-		logger:        logger.Named(loggerName),
-		sdk:           new(sdk),
-		sessionGetter: sessionGetter,
-		storage:       storage,
-		reader:        reader,
-		writer:        writer,
+		keys:    defaultKeyTemplate(),
+		logger:  logger.Named(loggerName),
+		newID:   images.NewUUID,
+		storage: storage,
+		stores:  stores,
+		reader:  reader,
+		writer:  writer,
+	}
+	for i := range opts {
+		opts[i](&s)
 	}
 
 	if err := s.validate(); err != nil {
 		return nil, err
 	}
 
+	s.mirror.start(s.logger)
+	s.reconciler.start(&s)
+
 	s.logger.Info("successfully initialized image writer")
 
 	return &s, nil
@@ -79,10 +115,61 @@ func (s *Service) validate() error {
 			dep: "reader",
 			chk: func() bool { return s.reader != nil },
 		},
+		{
+			dep: "default storage",
+			chk: func() bool { return s.stores[s.storage] != nil },
+		},
 		{
 			dep: "writer",
 			chk: func() bool { return s.writer != nil },
 		},
+		{
+			dep: "mirror storage",
+			chk: func() bool { return s.mirror == nil || s.stores[s.mirror.storage] != nil },
+		},
+		{
+			dep: "32 byte encryption key",
+			chk: func() bool { return s.encryptionKey == nil || len(s.encryptionKey) == 32 },
+		},
+		{
+			dep: "cdn storage",
+			chk: func() bool { return s.cdn == nil || (s.cdn.dist != nil && s.stores[s.cdn.storage] != nil) },
+		},
+		{
+			dep: "cdn url storage",
+			chk: func() bool {
+				return s.cdnURLs == nil || (s.cdnURLs.signer != nil && s.stores[s.cdnURLs.storage] != nil)
+			},
+		},
+		{
+			dep: "positive thumbnail sizes",
+			chk: func() bool {
+				for _, size := range s.thumbnailSizes {
+					if size <= 0 {
+						return false
+					}
+				}
+				return true
+			},
+		},
+		{
+			dep: "valid pipelines",
+			chk: s.validPipelines,
+		},
+		{
+			dep: "valid watermark",
+			chk: func() bool { return s.watermark == nil || s.watermark.Valid() },
+		},
+		{
+			dep: "moderator and valid moderation policy",
+			chk: func() bool {
+				return s.moderation == nil || (s.moderation.moderator != nil && s.moderation.policy.Valid())
+			},
+		},
+		{
+			dep: "reconciler interval",
+			chk: func() bool { return s.reconciler == nil || s.reconciler.interval > 0 },
+		},
 	} {
 		if !tc.chk() {
 			missingDeps = append(missingDeps, tc.dep)
@@ -100,13 +187,22 @@ func (s *Service) validate() error {
 	return nil
 }
 
+// Close stops the delete reconciler and waits for any pending background
+// mirror operations to finish.
+func (s *Service) Close() {
+	s.reconciler.close()
+	s.mirror.close()
+}
+
 // Delete will remove both the image from cloud storage and the DB record
-// that represents the image.
-func (s *Service) Delete(id string) error {
+// that represents the image. The record is first marked as deleting so a
+// delete interrupted between removing the object and the record is finished
+// by ReconcileDeletes rather than leaving a record without an object.
+func (s *Service) Delete(ctx context.Context, id string) error {
 	logger := s.logger.With(zap.String("imageId", id))
 
 	//get record  from id
-	rec, err := s.reader.Get(id)
+	rec, err := s.reader.Get(ctx, id)
 	switch err {
 	case nil:
 	case images.ErrRecordNotFound:
@@ -118,15 +214,52 @@ func (s *Service) Delete(id string) error {
 		return fmt.Errorf(msg+": %w", err)
 	}
 
-	// delete image object
-	if err := s.deleteObject(rec.Key, logger); err != nil {
-		const msg = "unable to delete object"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	// mark the record as deleting before touching the object
+	if rec.DeletingAt == nil {
+		now := time.Now().UTC()
+		rec.DeletingAt = &now
+		if err := s.writer.Update(ctx, rec); err != nil {
+			const msg = "unable to mark image record as deleting"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	}
+
+	return s.finishDelete(ctx, rec, logger)
+}
+
+// finishDelete removes the object of the record marked as deleting and then
+// the record. Objects and records which are already gone are not an error
+// so an interrupted delete can be finished.
+func (s *Service) finishDelete(ctx context.Context, rec *images.Record, logger *zap.Logger) error {
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return err
 	}
 
+	shared, err := s.shared(ctx, rec, logger)
+	if err != nil {
+		return err
+	}
+
+	// delete image object unless another record still references it
+	if shared {
+		logger.Info("object is shared, keeping it", zap.String("key", rec.Key))
+	} else {
+		if err := store.Delete(ctx, rec.Key); err != nil && err != images.ErrObjectNotFound {
+			const msg = "unable to delete object"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		s.deleteMirrors(ctx, rec, logger)
+		s.invalidate(ctx, rec.Storage, []string{rec.Key}, logger)
+	}
+	// thumbnails and variants belong to the image alone, even when its
+	// object is shared
+	s.deleteDerived(ctx, rec, nil, logger)
+
 	// remove record from db
-	err = s.writer.Delete(id)
+	err = s.writer.Delete(ctx, rec.ID)
 	switch err {
 	case nil, images.ErrRecordNotFound:
 		return nil
@@ -138,55 +271,225 @@ func (s *Service) Delete(id string) error {
 }
 
 // Download attempts to download an image file from cloud storage to the
-// requested file path.
-func (s *Service) Download(r images.DownloadRequest) error {
+// requested file path. The download is checked against the record's size and,
+// when the stream can be read back, its SHA-256 or ETag. A mismatch returns
+// ErrIntegrity. Compressed objects are decoded into the stream. A request
+// with a byte range downloads only that range and one with a Writer rather
+// than a Stream is downloaded sequentially, see images.DownloadRequest.
+func (s *Service) Download(ctx context.Context, r images.DownloadRequest) error {
 	logger := s.logger.With(zap.String("imageId", r.ID))
 	logger.Info("attempting to download object")
 
-	//get record  from id
-	rec, err := s.reader.Get(r.ID)
+	rec, err := s.downloadRecord(ctx, r.ID, logger)
+	if err != nil {
+		return err
+	}
+
+	return s.download(ctx, rec, r, logger)
+}
+
+// downloadRecord returns the record of the image to download, records being
+// deleted and pending records without an object yet are not found.
+func (s *Service) downloadRecord(ctx context.Context, id string, logger *zap.Logger) (*images.Record, error) {
+	rec, err := s.reader.Get(ctx, id)
 	switch err {
 	case nil:
 	case images.ErrRecordNotFound:
 		logger.Error("record not found", zap.Error(err))
-		return err
+		return nil, err
 	default:
 		const msg = "unable to retrieve image record"
 		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	switch {
+	case rec.DeletingAt != nil:
+		logger.Error("record is being deleted")
+		return nil, images.ErrRecordNotFound
+	case rec.PendingUntil != nil:
+		logger.Error("record is pending an upload")
+		return nil, images.ErrRecordNotFound
 	}
 
-	// get downloader
-	sess, err := s.sessionGetter()
+	return rec, nil
+}
+
+// download downloads the record's object as the request asks, see Download.
+func (s *Service) download(ctx context.Context, rec *images.Record, r images.DownloadRequest, logger *zap.Logger) error {
+	store, err := s.store(rec.Storage, logger)
 	if err != nil {
-		const msg = "unable to get AWS session"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+		return err
+	}
+	switch {
+	case ranged(r):
+		return s.downloadRange(ctx, rec, store, r, logger)
+	case streamed(r):
+		return s.downloadStream(ctx, rec, store, r, logger)
+	}
+
+	// an encoded object is downloaded to a temp file and decoded into the
+	// stream
+	stream := r.Stream
+	if encoded(rec) {
+		f, err := ioutil.TempFile("", "sim-download-*")
+		if err != nil {
+			const msg = "unable to create temp file"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		stream = f
 	}
-	s.sdk.init(withSDKDownloader(sess))
 
 	// download
-	input := s3.GetObjectInput{
-		Bucket: &s.storage,
-		Key:    &rec.Key,
+	n, err := store.Get(ctx, rec.Key, stream)
+	if err != nil {
+		var ok bool
+		if n, ok = s.downloadMirror(ctx, rec, stream, logger); !ok {
+			if err == images.ErrObjectNotFound {
+				logger.Error("object not found", zap.Error(err))
+				return err
+			}
+			const msg = "unable to download file"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	}
+
+	if err := checkIntegrity(rec, stream, n, logger); err != nil {
+		return err
+	}
+	if encoded(rec) {
+		if err := s.decodeDownload(rec, stream.(io.ReaderAt), n, r.Stream, logger); err != nil {
+			return err
+		}
 	}
-	if _, err := s.sdk.downloader.Download(r.Stream, &input); err != nil {
-		const msg = "unable to download file"
+	logger.Info("successfully downloaded file")
+
+	return nil
+}
+
+// DownloadFile downloads the image to the file path atomically. The image is
+// downloaded to a temp file in the same directory which is renamed to the
+// path once complete, so a failed download never leaves a partial file at
+// the path or replaces an existing one. ErrFileExists is returned if a file
+// is at the path unless r.Overwrite is set. With r.Resume the download is
+// kept in a partial file instead which later downloads continue, see
+// resumeFile.
+func (s *Service) DownloadFile(ctx context.Context, r images.DownloadFileRequest) error {
+	path := r.Path
+	logger := s.logger.With(zap.String("imageId", r.ID), zap.String("filePath", path))
+
+	if !r.Overwrite {
+		if _, err := os.Lstat(path); err == nil {
+			logger.Error("file already exists")
+			return images.ErrFileExists
+		}
+	}
+	if r.Resume {
+		part, err := s.resumeFile(ctx, r, logger)
+		if err != nil {
+			return err
+		}
+
+		return placeFile(part, path, r.Overwrite, logger)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".download-*")
+	if err != nil {
+		const msg = "unable to create temp file"
 		logger.Error(msg, zap.Error(err))
-		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
-			return images.ErrObjectNotFound
+		return fmt.Errorf(msg+": %w", err)
+	}
+	tmp := f.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			f.Close()
+			os.Remove(tmp)
 		}
+	}()
+
+	req := images.DownloadRequest{
+		ID:     r.ID,
+		Stream: f,
+		Offset: r.Offset,
+		Length: r.Length,
+	}
+	if err := s.Download(ctx, req); err != nil {
+		return err
+	}
+
+	if err := closeFile(f, logger); err != nil {
+		return err
+	}
+	if err := placeFile(tmp, path, r.Overwrite, logger); err != nil {
+		return err
+	}
+	renamed = true
+
+	return nil
+}
+
+// closeFile sets the mode of the downloaded file and flushes it before it
+// is moved to its path.
+func closeFile(f *os.File, logger *zap.Logger) error {
+	if err := f.Chmod(downloadFileMode); err != nil {
+		const msg = "unable to set file mode"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		const msg = "unable to sync file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if err := f.Close(); err != nil {
+		const msg = "unable to close file"
+		logger.Error(msg, zap.Error(err))
 		return fmt.Errorf(msg+": %w", err)
 	}
-	logger.Info("successfully downloaded file")
 
 	return nil
 }
 
+// placeFile moves the downloaded file at tmp to the path, replacing the file
+// at the path only when overwrite is set. tmp no longer exists once it
+// returns without error.
+func placeFile(tmp, path string, overwrite bool, logger *zap.Logger) error {
+	if overwrite {
+		if err := os.Rename(tmp, path); err != nil {
+			const msg = "unable to rename file"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+
+		return nil
+	}
+
+	// linking, unlike renaming, fails if a file was created at the path
+	// during the download
+	err := os.Link(tmp, path)
+	switch {
+	case err == nil:
+	case os.IsExist(err):
+		logger.Error("file already exists")
+		return images.ErrFileExists
+	default:
+		const msg = "unable to link file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return os.Remove(tmp)
+}
+
 // Get retrieves the image record by id
-func (s *Service) Get(id string) (*images.Record, error) {
+func (s *Service) Get(ctx context.Context, id string) (*images.Record, error) {
 	logger := s.logger.With(zap.String("imageId", id))
-	rec, err := s.reader.Get(id)
+	rec, err := s.reader.Get(ctx, id)
 	switch err {
 	case nil:
 		return rec, nil
@@ -200,168 +503,470 @@ func (s *Service) Get(id string) (*images.Record, error) {
 	}
 }
 
-// List returns a list all the image records stored in the database.
-func (s *Service) List() ([]images.Image, error) {
-	records, err := s.reader.List()
+// GetByName retrieves the image record by name
+func (s *Service) GetByName(ctx context.Context, name string) (*images.Record, error) {
+	logger := s.logger.With(zap.String("imageName", name))
+	rec, err := s.reader.GetByName(ctx, name)
 	switch err {
 	case nil:
+		return rec, nil
 	case images.ErrRecordNotFound:
-		return nil, err
+		logger.Error("record not found", zap.Error(err))
+		return nil, images.ErrRecordNotFound
+	default:
+		const msg = "unable to retrieve image record by name"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+}
+
+// Count returns the number of image records matching the filter.
+func (s *Service) Count(ctx context.Context, filter images.ListFilter) (int, error) {
+	n, err := s.reader.Count(ctx, filter)
+	if err != nil {
+		const msg = "unable to count records"
+		s.logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// List returns a page of the image records stored in the database and the
+// cursor of the next page, which is empty on the last page.
+func (s *Service) List(ctx context.Context, opts images.ListOptions) ([]images.Image, string, error) {
+	page, err := s.reader.List(ctx, opts)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		return nil, "", err
 	default:
 		const msg = "unable to list records"
 		s.logger.Error(msg, zap.Error(err))
-		return nil, fmt.Errorf(msg+": %w", err)
+		return nil, "", fmt.Errorf(msg+": %w", err)
 	}
 
-	resp := make([]images.Image, len(records))
-	for i := range records {
-		resp[i] = images.Image{
-			ID:          records[i].ID,
-			Name:        records[i].Name,
-			SizeInBytes: records[i].SizeInBytes,
-		}
+	resp := make([]images.Image, len(page.Records))
+	for i := range page.Records {
+		resp[i] = toImage(&page.Records[i])
 	}
 
-	return resp, nil
+	return resp, page.NextCursor, nil
+}
+
+// Update replaces the image record. Returns ErrRecordNotFound if no record
+// exists by the record's ID and ErrConflict if the record was modified since
+// it was read.
+func (s *Service) Update(ctx context.Context, rec *images.Record) error {
+	logger := s.logger.With(zap.String("imageId", rec.ID))
+
+	err := s.writer.Update(ctx, rec)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound, images.ErrConflict:
+		logger.Error("unable to update image record", zap.Error(err))
+		return err
+	default:
+		const msg = "unable to update image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("successfully updated image record")
+
+	return nil
 }
 
 // Upload attempts to upload using the given request and adds a corresponding
-// image record in the DB.
-func (s *Service) Upload(r images.UploadRequest) (string, error) {
-	logger := s.logger.With(zap.String("name", r.Name))
+// image record in the DB. When the record can not be created the uploaded
+// object is removed, ErrOrphanedObject is returned if it could not be. When
+// the request gives an ID or idempotency key whose image already exists its
+// ID is returned without uploading, as is the ID of the image with the same
+// name and content when r.SkipUnchanged is set. An upload whose name an image
+// already has is handled by r.OnConflict, r.Replace requires one. With
+// moderation an image the policy rejects fails with ErrFlagged before it is
+// stored, see WithModeration.
+func (s *Service) Upload(ctx context.Context, r images.UploadRequest) (string, error) {
+	storage := r.Storage
+	if storage == "" {
+		storage = s.storage
+	}
+	logger := s.logger.With(zap.String("name", r.Name), zap.String("storage", storage))
 	logger.Info("attempting to upload")
 
-	// get session
-	sess, err := s.sessionGetter()
+	store, err := s.store(storage, logger)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := images.NormalizeTags(r.Tags)
+	if err != nil {
+		logger.Error("invalid tags", zap.Error(err))
+		return "", err
+	}
+	metadata, err := images.NormalizeMetadata(r.Metadata)
+	if err != nil {
+		logger.Error("invalid metadata", zap.Error(err))
+		return "", err
+	}
+	r.Metadata = metadata
+	objectMetadata, err := images.NormalizeMetadata(r.ObjectMetadata)
+	if err != nil {
+		logger.Error("invalid object metadata", zap.Error(err))
+		return "", err
+	}
+	r.ObjectMetadata = objectMetadata
+	if r.Path, err = images.NormalizePath(r.Path); err != nil {
+		logger.Error("invalid path", zap.Error(err))
+		return "", err
+	}
+	if !r.OnConflict.Valid() {
+		logger.Error("invalid conflict strategy", zap.String("onConflict", string(r.OnConflict)))
+		return "", fmt.Errorf("%w: %s", images.ErrInvalidConflict, r.OnConflict)
+	}
+	if r.Replace {
+		if r.Force || (r.OnConflict != "" && r.OnConflict != images.ConflictOverwrite) {
+			logger.Error("invalid conflict strategy for a replace", zap.String("onConflict", string(r.OnConflict)))
+			return "", fmt.Errorf("%w: a replace overwrites the image", images.ErrInvalidConflict)
+		}
+		r.OnConflict = images.ConflictOverwrite
+	}
+	strip := r.StripMetadata || s.stripMetadata
+	if strip && r.Session != nil {
+		logger.Error("unable to strip metadata of a resumable upload")
+		return "", fmt.Errorf("%w: metadata can not be stripped from a resumable upload", images.ErrUnsupported)
+	}
+
+	imageID, err := uploadID(r)
 	if err != nil {
-		const msg = "unable to get AWS session"
+		logger.Error("invalid image id", zap.Error(err))
+		return "", err
+	}
+	if imageID == "" && r.Session != nil {
+		imageID = r.Session.ImageID
+	}
+	if imageID != "" {
+		// a retried upload returns the image of the first attempt
+		existing, err := s.existing(ctx, imageID, logger)
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			return imageID, nil
+		}
+	} else {
+		imageID = s.newID()
+	}
+	logger = logger.With(zap.String("imageId", imageID))
+
+	var (
+		sums  *digests
+		parts io.ReaderAt
+	)
+	if r.Session != nil {
+		r.Session.ImageID = imageID
+		if parts, sums, err = s.resumable(&r, storage, logger); err != nil {
+			return "", err
+		}
+	}
+	// the stripped body is hashed and stored
+	if strip {
+		if r.ContentType == "" {
+			contentType, body, err := detectContentType(r.Name, r.Body, logger)
+			if err != nil {
+				return "", err
+			}
+			r.ContentType, r.Body = contentType, body
+		}
+		stripped, cleanup, err := stripBody(r.ContentType, r.Body, logger)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		r.Body = stripped
+	}
+	if sums == nil && (r.SkipUnchanged || s.dedup) {
+		d, body, cleanup, err := hashBody(r.Body, logger)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		sums, r.Body = d, body
+	}
+
+	if r.ContentType == "" {
+		contentType, body, err := detectContentType(r.Name, r.Body, logger)
+		if err != nil {
+			return "", err
+		}
+		r.ContentType, r.Body = contentType, body
+	}
+	if err := s.checkType(r, logger); err != nil {
+		return "", err
+	}
+	if s.validateImages {
+		if r.Body, err = checkImage(r.Body, logger); err != nil {
+			return "", err
+		}
+	}
+	exif, body, err := readExif(r.ContentType, r.Body, logger)
+	if err != nil {
+		return "", err
+	}
+	r.Body = body
+
+	if r.SkipUnchanged {
+		existing, err := s.unchanged(ctx, r.Name, sums, logger)
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			logger.Info("image unchanged, skipping upload", zap.String("existingId", existing.ID))
+			return existing.ID, nil
+		}
+	}
+	// a quarantined upload is made private before it is stored
+	moderation, cleanup, err := s.moderateBody(ctx, &r, logger)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	var replaced *images.Record
+	if !r.Force {
+		if r.Name, replaced, err = s.resolveName(ctx, r, logger); err != nil {
+			return "", err
+		}
+	}
+	if r.Replace && replaced == nil {
+		logger.Error("no image to replace")
+		return "", images.ErrRecordNotFound
+	}
+
+	if s.dedup {
+		existing, err := s.duplicate(ctx, storage, sums.sha256, r.Public, logger)
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			return s.createReference(ctx, r, imageID, tags, existing, replaced, sums, moderation, logger)
+		}
+	}
+
+	// hash the body as it is uploaded unless it was hashed beforehand
+	var hashing *hashingReader
+	if sums == nil {
+		hashing = newHashingReader(r.Body)
+		r.Body = hashing
+	}
+
+	var encoding string
+	// the parts of a resumable upload are read from the body as is
+	if s.compress && parts == nil && typeAllowed(r.ContentType, compressibleTypes) {
+		compressed, cleanup, err := gzipBody(r.Body, logger)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		encoding, r.Body = images.EncodingGzip, compressed
+	}
+
+	// storage only holds the ciphertext of an encrypted image
+	opts := s.withHeaders(r, s.withSSE(r, images.PutOptions{
+		ContentType:  r.ContentType,
+		StorageClass: r.StorageClass,
+		Metadata:     r.ObjectMetadata,
+		Public:       r.Public,
+	}))
+	if s.objectTags {
+		opts.Tags = tags
+	}
+	if opts.StorageClass == "" {
+		opts.StorageClass = s.storageClass
+	}
+	var encryptedKey string
+	if s.encryptionKey != nil {
+		encrypted, key, cleanup, err := s.encryptBody(r.Body, logger)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		encryptedKey, r.Body = key, encrypted
+		opts.ContentType = "application/octet-stream"
+	}
+
+	// spool the body so that it can be replayed to the mirror
+	body, spool, err := s.mirror.spool(r.Body)
+	if err != nil {
+		const msg = "unable to spool image for mirroring"
 		logger.Error(msg, zap.Error(err))
 		return "", fmt.Errorf(msg+": %w", err)
 	}
-	s.sdk.init(withSDKClient(sess), withSDKUploader(sess))
+	if parts != nil && spool != nil {
+		// the parts are not read from the spooling body, it is read up front
+		if _, err := io.Copy(ioutil.Discard, body); err != nil {
+			spool.discard()
+			const msg = "unable to spool image for mirroring"
+			logger.Error(msg, zap.Error(err))
+			return "", fmt.Errorf(msg+": %w", err)
+		}
+	}
 
-	// upload image
-	imageID := uuid.New().String()
-	key := uploadKey(r, imageID)
-	uploadInput := s3manager.UploadInput{
-		ACL:    aws.String("private"),
-		Body:   r.Body,
-		Bucket: &s.storage,
-		Key:    &key,
+	// upload image, an overwrite is uploaded under the key of the generated
+	// ID so that the image keeps its object until its record is updated. A
+	// resumed upload continues under the key it was started with.
+	key := s.keys.key(imageID, r.Name, time.Now())
+	if r.Session != nil && r.Session.Key != "" {
+		key = r.Session.Key
+	}
+	if parts != nil {
+		err = s.putParts(ctx, store, key, parts, opts, r, logger)
+	} else {
+		err = store.Put(ctx, key, body, opts)
 	}
-	if _, err := s.sdk.uploader.Upload(&uploadInput); err != nil {
+	if err != nil {
+		spool.discard()
 		const msg = "unable to upload image"
 		logger.Error(msg, zap.Error(err))
 		return "", fmt.Errorf(msg+": %w", err)
 	}
 
 	// head object to get the content length
-	headInput := s3.HeadObjectInput{
-		Bucket: &s.storage,
-		Key:    &key,
-	}
-	resp, err := s.sdk.client.HeadObject(&headInput)
+	info, err := store.Head(ctx, key)
 	if err != nil {
+		spool.discard()
 		const msg = "unable to head object"
 		logger.Error(msg, zap.Error(err))
-		return "", fmt.Errorf(msg+": %w", err)
-	}
-
-	if resp.ETag == nil || resp.ContentLength == nil {
-		const msg = "etag and/or content length is nil, unable to save metadata"
-		logger.Error(msg)
-		return "", errors.New(msg)
+		return "", s.rollbackUpload(store, &images.Record{Key: key, Storage: storage}, fmt.Errorf(msg+": %w", err), logger)
 	}
 
 	// create image record to point to this object
 	now := time.Now().UTC()
 	image := images.Record{
-		ID:          imageID,
-		CreatedAt:   &now,
-		ETag:        *resp.ETag,
-		Key:         key,
-		Name:        r.Name,
-		SizeInBytes: *resp.ContentLength,
-		Storage:     s.storage,
-	}
-	if err := s.writer.Create(&image); err != nil {
-		const msg = "unable to create image record"
-		logger.Error(msg, zap.Error(err))
-		return "", fmt.Errorf(msg+": %w", err)
+		ID:              imageID,
+		CreatedAt:       &now,
+		ETag:            info.ETag,
+		Key:             key,
+		Name:            r.Name,
+		Path:            r.Path,
+		SizeInBytes:     info.SizeInBytes,
+		ContentType:     r.ContentType,
+		ContentEncoding: encoding,
+		EncryptedKey:    encryptedKey,
+		Storage:         storage,
+		Mirrors:         s.mirrorUpload(ctx, key, spool, opts, logger),
+		Exif:            exif,
+		Moderation:      moderation,
+
+		ServerSideEncryption: info.ServerSideEncryption,
+		KMSKeyID:             info.KMSKeyID,
+		StorageClass:         info.StorageClass,
+		CacheControl:         opts.CacheControl,
+		ContentDisposition:   opts.ContentDisposition,
 	}
-	logger.Info("successfully uploaded file")
-
-	return imageID, nil
-}
-
-func (s *Service) deleteObject(key string, logger *zap.Logger) error {
-	sess, err := s.sessionGetter()
-	if err != nil {
-		const msg = "unable to get AWS session"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	// an encoded body was read in full before it was uploaded
+	read := info.SizeInBytes
+	if encoded(&image) && hashing != nil {
+		read = hashing.size
 	}
-	s.sdk.init(withSDKClient(sess))
-
-	input := s3.DeleteObjectInput{
-		Bucket: &s.storage,
-		Key:    &key,
+	if sums != nil {
+		image.SHA256 = sums.sha256
+	} else if sum, ok := hashing.sum(read); ok {
+		image.SHA256 = sum
+	} else {
+		logger.Warn("upload body was not read in full, not recording its sha256", zap.Int64("read", hashing.size))
 	}
-	if _, err := s.sdk.client.DeleteObject(&input); err != nil {
-		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() != s3.ErrCodeNoSuchKey && strings.Contains(awsErr.Code(), "NotFound") {
-			logger.Info("object not found")
-			return nil
-		}
-		const msg = "unable to delete object"
+	if len(tags) > 0 {
+		image.Tags = tags
+	}
+	image.Metadata = r.Metadata
+	image.ObjectMetadata = r.ObjectMetadata
+	image.Visibility = images.VisibilityPrivate
+	if r.Public {
+		image.Visibility = images.VisibilityPublic
+	}
+	s.derive(ctx, &image, logger)
+	if err := s.save(ctx, &image, replaced, logger); err != nil {
+		const msg = "unable to save image record"
 		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+		if r.ID != "" || r.IdempotencyKey != "" {
+			// a concurrent upload of the same ID may have created it first
+			if existing, _ := s.existing(ctx, imageID, logger); existing != nil {
+				if existing.Key != key {
+					s.rollbackUpload(store, &image, fmt.Errorf(msg+": %w", err), logger)
+				}
+				return imageID, nil
+			}
+		}
+		return "", s.rollbackUpload(store, &image, fmt.Errorf(msg+": %w", err), logger)
 	}
+	logger.Info("successfully uploaded file")
 
-	return nil
-}
-
-type sdk struct {
-	client     internalS3.Client
-	downloader internalS3.Downloader
-	uploader   internalS3.Uploader
-}
-
-func (s *sdk) init(opts ...sdkOpts) {
-	for i := range opts {
-		opts[i](s)
-	}
+	return image.ID, nil
 }
 
-type sdkOpts func(s *sdk)
-
-func withSDKClient(sess *session.Session) sdkOpts {
-	return func(s *sdk) {
-		if s.client == nil {
-			s.client = s3.New(sess)
+// uploadID returns the ID requested by the upload, if any.
+func uploadID(r images.UploadRequest) (string, error) {
+	switch {
+	case r.ID != "" && r.IdempotencyKey != "":
+		return "", fmt.Errorf("%w: an id and an idempotency key can not both be given", images.ErrInvalidID)
+	case r.OnConflict == images.ConflictOverwrite && (r.ID != "" || r.IdempotencyKey != ""):
+		return "", fmt.Errorf("%w: an overwrite keeps the id of the image it replaces", images.ErrInvalidID)
+	case r.ID != "":
+		if err := images.ValidateID(r.ID); err != nil {
+			return "", err
 		}
+		return r.ID, nil
+	case r.IdempotencyKey != "":
+		return images.IdempotentID(r.IdempotencyKey), nil
 	}
+
+	return "", nil
 }
 
-func withSDKDownloader(sess *session.Session) sdkOpts {
-	return func(s *sdk) {
-		if s.downloader == nil {
-			s.downloader = s3manager.NewDownloader(sess)
-		}
+// existing returns the record with the ID, or nil if there is none.
+func (s *Service) existing(ctx context.Context, id string, logger *zap.Logger) (*images.Record, error) {
+	rec, err := s.reader.Get(ctx, id)
+	switch err {
+	case nil:
+		logger.Info("image already uploaded", zap.String("imageId", id))
+		return rec, nil
+	case images.ErrRecordNotFound:
+		return nil, nil
+	default:
+		const msg = "unable to retrieve image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
 	}
 }
 
-func withSDKUploader(sess *session.Session) sdkOpts {
-	return func(s *sdk) {
-		if s.uploader == nil {
-			s.uploader = s3manager.NewUploader(sess)
-		}
+// store resolves the object store by the storage name.
+func (s *Service) store(name string, logger *zap.Logger) (images.ObjectStore, error) {
+	store, ok := s.stores[name]
+	if !ok {
+		logger.Error("storage not found", zap.String("storage", name))
+		return nil, images.ErrStorageNotFound
 	}
-}
 
-func uploadKey(r images.UploadRequest, imageID string) string {
-	return "images/" + imageID + "/" + r.Name
+	return store, nil
 }
 
-func bytesToKB(b int64) int64 {
-	return b / 1024
+// toImage returns the public facing image of the record.
+func toImage(rec *images.Record) images.Image {
+	return images.Image{
+		ID:          rec.ID,
+		Name:        rec.Name,
+		Path:        rec.Path,
+		SizeInBytes: rec.SizeInBytes,
+		Size:        images.FormatSize(rec.SizeInBytes),
+		SHA256:      rec.SHA256,
+		ContentType: rec.ContentType,
+		Visibility:  images.Visibility(rec),
+		Metadata:    rec.Metadata,
+		Pending:     rec.PendingUntil != nil,
+		Thumbnails:  rec.Thumbnails,
+		Exif:        rec.Exif,
+		Blurhash:    rec.Blurhash,
+		Moderation:  rec.Moderation,
+		Text:        rec.Text,
+	}
 }
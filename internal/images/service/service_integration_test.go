@@ -5,16 +5,21 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"testing"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/couchbase/gocb/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,8 +28,11 @@ import (
 	"github.com/itsHabib/sim/internal/images"
 	"github.com/itsHabib/sim/internal/images/reader"
 	"github.com/itsHabib/sim/internal/images/writer"
+	internalS3 "github.com/itsHabib/sim/internal/s3"
 )
 
+const region = "us-east-1"
+
 var (
 	localstack   string
 	imageStorage string
@@ -112,12 +120,12 @@ func Test_Service(t *testing.T) {
 					Body: bytes.NewReader(body),
 				}
 				var err error
-				id, err = svc.Upload(r)
+				id, err = svc.Upload(context.Background(), r)
 				require.Nil(t, err)
 				require.NotEmpty(t, id)
 			},
 			chk: func(svc *Service, t *testing.T) {
-				rec, err := svc.reader.Get(id)
+				rec, err := svc.reader.Get(context.Background(), id)
 				require.NoError(t, err)
 				assert.Equal(t, id, rec.ID)
 				assert.Equal(t, "test", rec.Name)
@@ -129,41 +137,40 @@ func Test_Service(t *testing.T) {
 			desc: "Download() should successfully download to the writer stream",
 			do:   func(svc *Service, t *testing.T) {},
 			chk: func(svc *Service, t *testing.T) {
-				buffer := aws.NewWriteAtBuffer([]byte{})
+				buffer := manager.NewWriteAtBuffer([]byte{})
 				r := images.DownloadRequest{
 					ID:     id,
 					Stream: buffer,
 				}
-				require.NoError(t, svc.Download(r))
+				require.NoError(t, svc.Download(context.Background(), r))
 				assert.Equal(t, body, buffer.Bytes())
 			},
 		},
 		{
 			desc: "Delete() should remove both the object and record.",
 			do: func(svc *Service, t *testing.T) {
-				require.NoError(t, svc.Delete(id))
+				require.NoError(t, svc.Delete(context.Background(), id))
 			},
 			chk: func(svc *Service, t *testing.T) {
 				r := images.UploadRequest{
 					Name: "test",
 				}
-				sess := getSession(t)
-				c := s3.New(sess)
+				c := getClient(t)
 				s3Input := s3.HeadObjectInput{
-					Bucket: aws.String("sim"),
-					Key:    aws.String(uploadKey(r, id)),
+					Bucket: aws.String(imageStorage),
+					Key:    aws.String(svc.keys.key(id, r.Name, time.Now())),
 				}
-				_, err := c.HeadObject(&s3Input)
+				_, err := c.HeadObject(context.Background(), &s3Input)
 				if err == nil {
 					t.Fatal("expected object to be deleted")
 				}
-				if err != nil {
-					if awsErr, ok := err.(awserr.Error); !ok || (awsErr.Code() != s3.ErrCodeNoSuchKey && !strings.Contains(awsErr.Code(), "NotFound")) {
-						t.Fatalf("unexpected error while getting object: %v", awsErr)
-					}
+				var noSuchKey *types.NoSuchKey
+				var apiErr smithy.APIError
+				if !errors.As(err, &noSuchKey) && (!errors.As(err, &apiErr) || !strings.Contains(apiErr.ErrorCode(), "NotFound")) {
+					t.Fatalf("unexpected error while getting object: %v", err)
 				}
 
-				_, err = svc.reader.Get(id)
+				_, err = svc.reader.Get(context.Background(), id)
 				assert.EqualError(t, err, images.ErrRecordNotFound.Error())
 			},
 		},
@@ -190,19 +197,20 @@ func getService(t *testing.T) *Service {
 	w, err := writer.NewService(nop, cb, cbBucket)
 	require.NoError(t, err)
 
-	svc, err := New(zap.NewNop(), imageStorage, r, w, images.WithSessionOptions(getCfg()))
+	store, err := internalS3.NewStore(nop, imageStorage, images.WithConfigOptions(getCfgOptions()...), internalS3.WithEndpoint(localstack))
+	require.NoError(t, err)
+
+	svc, err := New(zap.NewNop(), imageStorage, r, w, images.Stores{imageStorage: store})
 	require.NoError(t, err)
 
 	return svc
 }
 
-func getCfg() *aws.Config {
-	return aws.
-		NewConfig().
-		WithEndpoint(localstack).
-		WithRegion(region).
-		WithS3ForcePathStyle(true).
-		WithCredentials(credentials.NewStaticCredentials("images", "secret", ""))
+func getCfgOptions() []func(*config.LoadOptions) error {
+	return []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("images", "secret", "")),
+	}
 }
 
 func getCluster() (*gocb.Cluster, error) {
@@ -215,9 +223,9 @@ func getCluster() (*gocb.Cluster, error) {
 	)
 }
 
-func getSession(t *testing.T) *session.Session {
-	sess, err := session.NewSession(getCfg())
+func getClient(t *testing.T) *s3.Client {
+	cfg, err := images.WithConfigOptions(getCfgOptions()...)()
 	require.NoError(t, err)
 
-	return sess
+	return s3.NewFromConfig(cfg, internalS3.WithEndpoint(localstack))
 }
@@ -1,15 +1,20 @@
 package service
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,18 +22,17 @@ import (
 
 	"github.com/itsHabib/sim/internal/images"
 	mock_images "github.com/itsHabib/sim/internal/images/mocks"
-	internalS3 "github.com/itsHabib/sim/internal/s3"
-	mock_s3 "github.com/itsHabib/sim/internal/s3/mocks"
 )
 
 func Test_Service_Delete(t *testing.T) {
 	id := "id"
 	storage := "storage"
+	now := time.Now()
 	for _, tc := range []struct {
 		desc    string
 		reader  func(ctrl *gomock.Controller) images.Reader
 		writer  func(ctrl *gomock.Controller) images.Writer
-		client  func(ctrl *gomock.Controller) internalS3.Client
+		store   func(ctrl *gomock.Controller) images.ObjectStore
 		wantErr bool
 	}{
 		{
@@ -37,13 +41,13 @@ func Test_Service_Delete(t *testing.T) {
 				r := mock_images.NewMockReader(ctrl)
 				r.
 					EXPECT().
-					Get(id).
+					Get(gomock.Any(), id).
 					Return(nil, errors.New("random"))
 
 				return r
 			},
 			writer:  func(ctrl *gomock.Controller) images.Writer { return mock_images.NewMockWriter(ctrl) },
-			client:  func(ctrl *gomock.Controller) internalS3.Client { return mock_s3.NewMockClient(ctrl) },
+			store:   func(ctrl *gomock.Controller) images.ObjectStore { return mock_images.NewMockObjectStore(ctrl) },
 			wantErr: true,
 		},
 		{
@@ -52,20 +56,28 @@ func Test_Service_Delete(t *testing.T) {
 				r := mock_images.NewMockReader(ctrl)
 				r.
 					EXPECT().
-					Get(id).
-					Return(&images.Record{Key: "key"}, nil)
+					Get(gomock.Any(), id).
+					Return(&images.Record{ID: id, Key: "key", Storage: storage}, nil)
 
 				return r
 			},
-			writer: func(ctrl *gomock.Controller) images.Writer { return mock_images.NewMockWriter(ctrl) },
-			client: func(ctrl *gomock.Controller) internalS3.Client {
-				c := mock_s3.NewMockClient(ctrl)
-				c.
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
 					EXPECT().
-					DeleteObject(gomock.Any()).
-					Return(nil, errors.New("random"))
+					Update(gomock.Any(), gomock.Any()).
+					Return(nil)
+
+				return w
+			},
+			store: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Delete(gomock.Any(), "key").
+					Return(errors.New("random"))
 
-				return c
+				return s
 			},
 			wantErr: true,
 		},
@@ -75,8 +87,8 @@ func Test_Service_Delete(t *testing.T) {
 				r := mock_images.NewMockReader(ctrl)
 				r.
 					EXPECT().
-					Get(id).
-					Return(&images.Record{Key: "key"}, nil)
+					Get(gomock.Any(), id).
+					Return(&images.Record{ID: id, Key: "key", Storage: storage}, nil)
 
 				return r
 			},
@@ -84,30 +96,90 @@ func Test_Service_Delete(t *testing.T) {
 				w := mock_images.NewMockWriter(ctrl)
 				w.
 					EXPECT().
-					Delete(id).
+					Update(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.NotNil(t, rec.DeletingAt)
+						return nil
+					})
+				w.
+					EXPECT().
+					Delete(gomock.Any(), id).
 					Return(errors.New("random"))
 
 				return w
 			},
-			client: func(ctrl *gomock.Controller) internalS3.Client {
-				c := mock_s3.NewMockClient(ctrl)
-				c.
+			store: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Delete(gomock.Any(), "key").
+					Return(nil)
+
+				return s
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Delete() should not remove the object when failing to mark the record",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), id).
+					Return(&images.Record{ID: id, Key: "key", Storage: storage}, nil)
+
+				return r
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
 					EXPECT().
-					DeleteObject(gomock.Any()).
-					Return(nil, nil)
+					Update(gomock.Any(), gomock.Any()).
+					Return(images.ErrConflict)
 
-				return c
+				return w
 			},
+			store:   func(ctrl *gomock.Controller) images.ObjectStore { return mock_images.NewMockObjectStore(ctrl) },
 			wantErr: true,
 		},
+		{
+			desc: "Delete() should finish the delete of a record already marked",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), id).
+					Return(&images.Record{ID: id, Key: "key", Storage: storage, DeletingAt: &now}, nil)
+
+				return r
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Delete(gomock.Any(), id).
+					Return(nil)
+
+				return w
+			},
+			store: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Delete(gomock.Any(), "key").
+					Return(images.ErrObjectNotFound)
+
+				return s
+			},
+		},
 		{
 			desc: "Delete() - happy path",
 			reader: func(ctrl *gomock.Controller) images.Reader {
 				r := mock_images.NewMockReader(ctrl)
 				r.
 					EXPECT().
-					Get(id).
-					Return(&images.Record{Key: "key"}, nil)
+					Get(gomock.Any(), id).
+					Return(&images.Record{ID: id, Key: "key", Storage: storage}, nil)
 
 				return r
 			},
@@ -115,30 +187,36 @@ func Test_Service_Delete(t *testing.T) {
 				w := mock_images.NewMockWriter(ctrl)
 				w.
 					EXPECT().
-					Delete(id).
+					Update(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.NotNil(t, rec.DeletingAt)
+						return nil
+					})
+				w.
+					EXPECT().
+					Delete(gomock.Any(), id).
 					Return(nil)
 
 				return w
 			},
-			client: func(ctrl *gomock.Controller) internalS3.Client {
-				c := mock_s3.NewMockClient(ctrl)
-				c.
+			store: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
 					EXPECT().
-					DeleteObject(gomock.Any()).
-					Return(nil, nil)
+					Delete(gomock.Any(), "key").
+					Return(nil)
 
-				return c
+				return s
 			},
 		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
-			svc, err := New(zap.NewNop(), storage, tc.reader(ctrl), tc.writer(ctrl), mockSessionGetter)
-			svc.sdk.client = tc.client(ctrl)
+			svc, err := New(zap.NewNop(), storage, tc.reader(ctrl), tc.writer(ctrl), images.Stores{storage: tc.store(ctrl)})
 			require.NoError(t, err)
 
-			err = svc.Delete(id)
+			err = svc.Delete(context.Background(), id)
 			if tc.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -155,10 +233,10 @@ func Test_Service_Download(t *testing.T) {
 		ID: "id",
 	}
 	for _, tc := range []struct {
-		desc       string
-		reader     func(ctrl *gomock.Controller) images.Reader
-		downloader func(t *testing.T, ctrl *gomock.Controller) internalS3.Downloader
-		wantErr    bool
+		desc    string
+		reader  func(ctrl *gomock.Controller) images.Reader
+		store   func(t *testing.T, ctrl *gomock.Controller) images.ObjectStore
+		wantErr error
 	}{
 		{
 			desc: "Download() should return an error when failing to retrieve the image record.",
@@ -166,34 +244,47 @@ func Test_Service_Download(t *testing.T) {
 				r := mock_images.NewMockReader(ctrl)
 				r.
 					EXPECT().
-					Get(id).
-					Return(nil, errors.New("random"))
+					Get(gomock.Any(), id).
+					Return(nil, images.ErrRecordNotFound)
 
 				return r
 			},
-			wantErr: true,
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc: "Download() should return an error when the record's storage is not configured.",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), id).
+					Return(&images.Record{Key: "key", Storage: "unknown"}, nil)
+
+				return r
+			},
+			wantErr: images.ErrStorageNotFound,
 		},
 		{
-			desc: "Download() should return an error when failing to download the object.",
+			desc: "Download() should return an error when the object does not exist.",
 			reader: func(ctrl *gomock.Controller) images.Reader {
 				r := mock_images.NewMockReader(ctrl)
 				r.
 					EXPECT().
-					Get(id).
-					Return(&images.Record{Key: "key"}, nil)
+					Get(gomock.Any(), id).
+					Return(&images.Record{Key: "key", Storage: storage}, nil)
 
 				return r
 			},
-			downloader: func(t *testing.T, ctrl *gomock.Controller) internalS3.Downloader {
-				c := mock_s3.NewMockDownloader(ctrl)
-				c.
+			store: func(t *testing.T, ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
 					EXPECT().
-					Download(gomock.Any(), gomock.Any()).
-					Return(int64(0), errors.New("random"))
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(0), images.ErrObjectNotFound)
 
-				return c
+				return s
 			},
-			wantErr: true,
+			wantErr: images.ErrObjectNotFound,
 		},
 		{
 			desc: "Download() - happy path",
@@ -201,191 +292,609 @@ func Test_Service_Download(t *testing.T) {
 				r := mock_images.NewMockReader(ctrl)
 				r.
 					EXPECT().
-					Get(id).
-					Return(&images.Record{Key: "key"}, nil)
+					Get(gomock.Any(), id).
+					Return(&images.Record{Key: "key", Storage: storage, SizeInBytes: 10}, nil)
 
 				return r
 			},
-			downloader: func(t *testing.T, ctrl *gomock.Controller) internalS3.Downloader {
-				c := mock_s3.NewMockDownloader(ctrl)
-				c.
+			store: func(t *testing.T, ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
 					EXPECT().
-					Download(gomock.Any(), gomock.Any()).
-					DoAndReturn(func(_ io.WriterAt, i *s3.GetObjectInput, _ ...func(*s3manager.Downloader)) (int64, error) {
-						require.NotNil(t, i)
-						assert.Equal(t, "key", unwrapStr(i.Key))
-						assert.Equal(t, storage, unwrapStr(i.Bucket))
+					Get(gomock.Any(), "key", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, w io.WriterAt) (int64, error) {
+						assert.Equal(t, req.Stream, w)
 
 						return 10, nil
 					})
 
-				return c
+				return s
 			},
 		},
+		{
+			desc: "Download() should return ErrIntegrity when the size does not match the record",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), id).
+					Return(&images.Record{Key: "key", Storage: storage, SizeInBytes: 10}, nil)
+
+				return r
+			},
+			store: func(t *testing.T, ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(5), nil)
+
+				return s
+			},
+			wantErr: images.ErrIntegrity,
+		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
-			r := mock_images.NewMockReader(ctrl)
-			if tc.reader == nil {
-				tc.reader = func(ctrl *gomock.Controller) images.Reader { return r }
+			s := mock_images.NewMockObjectStore(ctrl)
+			if tc.store == nil {
+				tc.store = func(_ *testing.T, ctrl *gomock.Controller) images.ObjectStore { return s }
 			}
-			d := mock_s3.NewMockDownloader(ctrl)
-			if tc.downloader == nil {
-				tc.downloader = func(_ *testing.T, ctrl *gomock.Controller) internalS3.Downloader { return d }
+
+			svc, err := New(zap.NewNop(), storage, tc.reader(ctrl), mock_images.NewMockWriter(ctrl), images.Stores{storage: tc.store(t, ctrl)})
+			require.NoError(t, err)
+
+			err = svc.Download(context.Background(), req)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Service_DownloadFile(t *testing.T) {
+	sum, other := md5.Sum([]byte("hw")), md5.Sum([]byte("other"))
+	for _, tc := range []struct {
+		desc      string
+		etag      string
+		err       error
+		noFile    bool
+		overwrite bool
+		want      string
+		wantErr   error
+	}{
+		{
+			desc:      "DownloadFile() should replace the file once the download completes",
+			etag:      `"` + hex.EncodeToString(sum[:]) + `"`,
+			overwrite: true,
+			want:      "hw",
+		},
+		{
+			desc:   "DownloadFile() should create the file once the download completes",
+			etag:   `"` + hex.EncodeToString(sum[:]) + `"`,
+			noFile: true,
+			want:   "hw",
+		},
+		{
+			desc:    "DownloadFile() should return ErrFileExists if a file is at the path",
+			want:    "old",
+			wantErr: images.ErrFileExists,
+		},
+		{
+			desc:      "DownloadFile() should leave the file untouched when the download fails",
+			err:       errors.New("random"),
+			overwrite: true,
+			want:      "old",
+			wantErr:   errors.New("random"),
+		},
+		{
+			desc:      "DownloadFile() should leave the file untouched when the download does not match its record",
+			etag:      `"` + hex.EncodeToString(other[:]) + `"`,
+			overwrite: true,
+			want:      "old",
+			wantErr:   images.ErrIntegrity,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "a.png")
+			if !tc.noFile {
+				require.NoError(t, ioutil.WriteFile(path, []byte("old"), 0644))
 			}
 
-			svc, err := New(zap.NewNop(), storage, tc.reader(ctrl), mock_images.NewMockWriter(ctrl), mockSessionGetter)
-			svc.sdk.downloader = tc.downloader(t, ctrl)
+			r, s := mock_images.NewMockReader(ctrl), mock_images.NewMockObjectStore(ctrl)
+			if tc.wantErr != images.ErrFileExists {
+				r.EXPECT().Get(gomock.Any(), "1").Return(&images.Record{ID: "1", Key: "key", Storage: "sim", ETag: tc.etag, SizeInBytes: 2}, nil)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					DoAndReturn(func(_ context.Context, key string, stream io.WriterAt) (int64, error) {
+						n, err := stream.WriteAt([]byte("h"), 0)
+						if tc.err != nil {
+							return int64(n), tc.err
+						}
+						m, err := stream.WriteAt([]byte("w"), 1)
+						return int64(n + m), err
+					})
+			}
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), images.Stores{"sim": s})
 			require.NoError(t, err)
 
-			err = svc.Download(req)
-			if tc.wantErr {
+			err = svc.DownloadFile(context.Background(), images.DownloadFileRequest{ID: "1", Path: path, Overwrite: tc.overwrite})
+			if tc.wantErr != nil {
 				assert.Error(t, err)
+				if tc.wantErr == images.ErrIntegrity || tc.wantErr == images.ErrFileExists {
+					assert.True(t, errors.Is(err, tc.wantErr))
+				}
 			} else {
-				assert.NoError(t, err)
+				require.NoError(t, err)
 			}
+			b, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(b))
+
+			// the temp file is always removed
+			entries, err := ioutil.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
 		})
 	}
 }
 
 func Test_Service_Upload(t *testing.T) {
+	rollbackBackoff = 0
 	storage := "sim"
 	r := images.UploadRequest{
 		Name: "test",
 		Body: strings.NewReader("hw"),
 	}
-	defaultMockUpload := func(ctrl *gomock.Controller, t *testing.T) internalS3.Uploader {
-		u := mock_s3.NewMockUploader(ctrl)
-		u.
+	expectPut := func(s *mock_images.MockObjectStore, t *testing.T, err error) {
+		s.
 			EXPECT().
-			Upload(gomock.Any()).
-			DoAndReturn(func(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
-				require.NotNil(t, input.Key)
-				assert.NotNil(t, input.Bucket)
-				assert.Equal(t, storage, *input.Bucket)
-				assert.Contains(t, *input.Key, "images/")
-				assert.Contains(t, *input.Key, "test")
-				assert.Equal(t, r.Body, input.Body)
-
-				return new(s3manager.UploadOutput), nil
-			})
+			Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, key string, body io.Reader, opts images.PutOptions) error {
+				assert.Contains(t, key, "images/")
+				assert.Contains(t, key, "test")
+				assert.Equal(t, r.Body, body.(*hashingReader).r)
+				assert.Equal(t, "text/plain; charset=utf-8", opts.ContentType)
 
-		return u
+				return err
+			})
 	}
-	defaultMockClient := func(ctrl *gomock.Controller) internalS3.Client {
-		c := mock_s3.NewMockClient(ctrl)
-		c.
+	expectHead := func(s *mock_images.MockObjectStore, t *testing.T, err error) {
+		s.
 			EXPECT().
-			HeadObject(gomock.Any()).
-			DoAndReturn(func(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
-				require.NotNil(t, input.Key)
-				require.NotNil(t, input.Bucket)
-				assert.Equal(t, storage, *input.Bucket)
-				assert.Contains(t, *input.Key, "images/")
-				assert.Contains(t, *input.Key, "test")
-
-				return &s3.HeadObjectOutput{
-					ContentLength: aws.Int64(1024),
-					ETag:          aws.String("etag"),
+			Head(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, key string) (*images.ObjectInfo, error) {
+				assert.Contains(t, key, "images/")
+				assert.Contains(t, key, "test")
+				if err != nil {
+					return nil, err
+				}
+
+				return &images.ObjectInfo{
+					ETag:        "etag",
+					SizeInBytes: 1024,
 				}, nil
 			})
-
-		return c
 	}
 
 	for _, tc := range []struct {
-		desc          string
-		client        func(ctrl *gomock.Controller) internalS3.Client
-		uploader      func(ctrl *gomock.Controller, t *testing.T) internalS3.Uploader
-		writer        func(ctrl *gomock.Controller) images.Writer
-		sessionGetter images.SessionGetter
-		wantErr       bool
+		desc     string
+		allowed  []string
+		compress bool
+		force    bool
+		id       string
+		key      string
+		metadata map[string]string
+		objMeta  map[string]string
+		path     string
+		public   bool
+		reader   func(ctrl *gomock.Controller) images.Reader
+		store    func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore
+		writer   func(ctrl *gomock.Controller) images.Writer
+		wantErr  error
 	}{
 		{
-			desc:          "Upload() should return an error when failing to get the session",
-			sessionGetter: func() (*session.Session, error) { return nil, errors.New("random") },
-			wantErr:       true,
+			desc: "Upload() should return ErrNameTaken when an image has the name",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					GetByName(gomock.Any(), "test").
+					Return(&images.Record{ID: "other", Name: "test"}, nil)
+
+				return r
+			},
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				return mock_images.NewMockObjectStore(ctrl)
+			},
+			wantErr: images.ErrNameTaken,
+		},
+		{
+			desc: "Upload() should return an error when failing to check the name",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					GetByName(gomock.Any(), "test").
+					Return(nil, errors.New("random"))
+
+				return r
+			},
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				return mock_images.NewMockObjectStore(ctrl)
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc:   "Upload() should not check the name when forced",
+			force:  true,
+			reader: func(ctrl *gomock.Controller) images.Reader { return mock_images.NewMockReader(ctrl) },
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, nil)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					Return(nil)
+
+				return w
+			},
 		},
 		{
-			desc:          "Upload() should return an error when failing to upload",
-			sessionGetter: func() (*session.Session, error) { return new(session.Session), nil },
-			uploader: func(ctrl *gomock.Controller, t *testing.T) internalS3.Uploader {
-				u := mock_s3.NewMockUploader(ctrl)
-				u.
+			desc: "Upload() should return an error when failing to upload",
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, errors.New("random"))
+
+				return s
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc: "Upload() should return an error when failing to head object",
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, errors.New("random"))
+				s.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(nil)
+
+				return s
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc: "Upload() should remove the object when the image writer fails",
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, nil)
+				gomock.InOrder(
+					s.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errors.New("random")),
+					s.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(nil),
+				)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
 					EXPECT().
-					Upload(gomock.Any()).
-					DoAndReturn(func(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
-						require.NotNil(t, input.Key)
-						require.NotNil(t, input.Bucket)
-						assert.Equal(t, storage, *input.Bucket)
-						assert.Contains(t, *input.Key, "images/")
-						assert.Contains(t, *input.Key, "test")
-						assert.Equal(t, r.Body, input.Body)
+					Create(gomock.Any(), gomock.Any()).
+					Return(errors.New("random"))
 
-						return nil, errors.New("random")
+				return w
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc: "Upload() should return ErrOrphanedObject when the object can not be removed",
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, nil)
+				s.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errors.New("random")).Times(rollbackAttempts)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					Return(errors.New("random"))
+
+				return w
+			},
+			wantErr: images.ErrOrphanedObject,
+		},
+		{
+			desc: "Upload() should return the image of an idempotency key without uploading",
+			key:  "key",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), images.IdempotentID("key")).
+					Return(&images.Record{ID: images.IdempotentID("key")}, nil)
+
+				return r
+			},
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				return mock_images.NewMockObjectStore(ctrl)
+			},
+		},
+		{
+			desc:   "Upload() should reject an invalid ID",
+			id:     "../id",
+			reader: func(ctrl *gomock.Controller) images.Reader { return mock_images.NewMockReader(ctrl) },
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				return mock_images.NewMockObjectStore(ctrl)
+			},
+			wantErr: images.ErrInvalidID,
+		},
+		{
+			desc: "Upload() should upload the image with the requested ID",
+			id:   "custom",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := nameAvailable(ctrl).(*mock_images.MockReader)
+				r.EXPECT().Get(gomock.Any(), "custom").Return(nil, images.ErrRecordNotFound)
+
+				return r
+			},
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, nil)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						assert.Equal(t, "custom", i.ID)
+						assert.Equal(t, "images/custom/test", i.Key)
+						return nil
 					})
 
-				return u
+				return w
 			},
-			wantErr: true,
 		},
 		{
-			desc:          "Upload() should return an error when failing to head object",
-			sessionGetter: func() (*session.Session, error) { return new(session.Session), nil },
-			uploader:      defaultMockUpload,
-			client: func(ctrl *gomock.Controller) internalS3.Client {
-				c := mock_s3.NewMockClient(ctrl)
-				c.
+			desc: "Upload() should return the ID created by a concurrent upload",
+			id:   "custom",
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := nameAvailable(ctrl).(*mock_images.MockReader)
+				gomock.InOrder(
+					r.EXPECT().Get(gomock.Any(), "custom").Return(nil, images.ErrRecordNotFound),
+					r.EXPECT().Get(gomock.Any(), "custom").Return(&images.Record{ID: "custom", Key: "images/custom/test"}, nil),
+				)
+
+				return r
+			},
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, nil)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
 					EXPECT().
-					HeadObject(gomock.Any()).
-					DoAndReturn(func(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
-						require.NotNil(t, input.Key)
-						require.NotNil(t, input.Bucket)
-						assert.Equal(t, storage, *input.Bucket)
-						assert.Contains(t, *input.Key, "images/")
-						assert.Contains(t, *input.Key, "test")
+					Create(gomock.Any(), gomock.Any()).
+					Return(errors.New("exists"))
 
-						return nil, errors.New("random")
+				return w
+			},
+		},
+		{
+			desc:     "Upload() should reject invalid metadata",
+			metadata: map[string]string{"": "x"},
+			reader:   func(ctrl *gomock.Controller) images.Reader { return mock_images.NewMockReader(ctrl) },
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				return mock_images.NewMockObjectStore(ctrl)
+			},
+			wantErr: images.ErrInvalidMetadata,
+		},
+		{
+			desc:    "Upload() should reject a content type which is not allowed before uploading",
+			allowed: []string{"image/*"},
+			reader:  func(ctrl *gomock.Controller) images.Reader { return mock_images.NewMockReader(ctrl) },
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				return mock_images.NewMockObjectStore(ctrl)
+			},
+			wantErr: images.ErrTypeNotAllowed,
+		},
+		{
+			desc:     "Upload() should record the metadata of the image",
+			metadata: map[string]string{" source": "legacy cms"},
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, nil)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						assert.Equal(t, map[string]string{"source": "legacy cms"}, i.Metadata)
+						return nil
 					})
 
-				return c
+				return w
 			},
-			wantErr: true,
 		},
 		{
-			desc:          "Upload() should return an error when the image writer fails",
-			sessionGetter: func() (*session.Session, error) { return new(session.Session), nil },
-			uploader:      defaultMockUpload,
-			client:        defaultMockClient,
+			desc:    "Upload() should set the object metadata on the object and record it",
+			objMeta: map[string]string{"source": "cms"},
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, key string, body io.Reader, opts images.PutOptions) error {
+						assert.Equal(t, map[string]string{"source": "cms"}, opts.Metadata)
+						return nil
+					})
+				expectHead(s, t, nil)
+
+				return s
+			},
 			writer: func(ctrl *gomock.Controller) images.Writer {
 				w := mock_images.NewMockWriter(ctrl)
 				w.
 					EXPECT().
-					Create(gomock.Any()).
-					Return(errors.New("random"))
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						assert.Equal(t, map[string]string{"source": "cms"}, i.ObjectMetadata)
+						return nil
+					})
+
+				return w
+			},
+		},
+		{
+			desc:   "Upload() should reject a path which leaves its directory",
+			path:   "../a.png",
+			reader: func(ctrl *gomock.Controller) images.Reader { return mock_images.NewMockReader(ctrl) },
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				return mock_images.NewMockObjectStore(ctrl)
+			},
+			wantErr: images.ErrInvalidPath,
+		},
+		{
+			desc: "Upload() should record the path of the image",
+			path: "2023/trip/./a.png",
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, nil)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						assert.Equal(t, "2023/trip/a.png", i.Path)
+						return nil
+					})
+
+				return w
+			},
+		},
+		{
+			desc:   "Upload() should upload a public object and record its visibility",
+			public: true,
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, key string, body io.Reader, opts images.PutOptions) error {
+						assert.True(t, opts.Public)
+						return nil
+					})
+				expectHead(s, t, nil)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						assert.Equal(t, images.VisibilityPublic, i.Visibility)
+						return nil
+					})
 
 				return w
 			},
-			wantErr: true,
 		},
 		{
-			desc:          "Upload() - happy path",
-			sessionGetter: func() (*session.Session, error) { return new(session.Session), nil },
-			uploader:      defaultMockUpload,
-			client:        defaultMockClient,
+			desc:     "Upload() should gzip a compressible image and record its encoding",
+			compress: true,
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, key string, body io.Reader, opts images.PutOptions) error {
+						gz, err := gzip.NewReader(body)
+						require.NoError(t, err)
+						b, err := ioutil.ReadAll(gz)
+						require.NoError(t, err)
+						assert.Equal(t, "hw", string(b))
+
+						return nil
+					})
+				expectHead(s, t, nil)
+
+				return s
+			},
 			writer: func(ctrl *gomock.Controller) images.Writer {
 				w := mock_images.NewMockWriter(ctrl)
 				w.
 					EXPECT().
-					Create(gomock.Any()).
-					DoAndReturn(func(i *images.Record) error {
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						sum := sha256.Sum256([]byte("hw"))
+						assert.Equal(t, images.EncodingGzip, i.ContentEncoding)
+						assert.Equal(t, hex.EncodeToString(sum[:]), i.SHA256)
+
+						return nil
+					})
+
+				return w
+			},
+		},
+		{
+			desc: "Upload() - happy path",
+			store: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t, nil)
+				expectHead(s, t, nil)
+
+				return s
+			},
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
 						require.NotNil(t, i)
 						assert.NotEmpty(t, i.CreatedAt)
 						assert.Equal(t, "etag", i.ETag)
 						assert.Equal(t, int64(1024), i.SizeInBytes)
 						assert.Equal(t, "test", i.Name)
 						assert.Equal(t, storage, i.Storage)
+						assert.Equal(t, "text/plain; charset=utf-8", i.ContentType)
+						assert.Equal(t, images.VisibilityPrivate, i.Visibility)
 
 						return nil
 					})
@@ -401,39 +910,437 @@ func Test_Service_Upload(t *testing.T) {
 			if tc.writer == nil {
 				tc.writer = func(ctrl *gomock.Controller) images.Writer { return w }
 			}
-			u := mock_s3.NewMockUploader(ctrl)
-			if tc.uploader == nil {
-				tc.uploader = func(ctrl *gomock.Controller, _ *testing.T) internalS3.Uploader { return u }
+
+			if tc.reader == nil {
+				tc.reader = nameAvailable
 			}
-			c := mock_s3.NewMockClient(ctrl)
-			if tc.client == nil {
-				tc.client = func(ctrl *gomock.Controller) internalS3.Client { return c }
+
+			req := r
+			var opts []Option
+			if tc.compress {
+				opts = append(opts, WithCompression())
+				req.Body = strings.NewReader("hw")
+			}
+			svc, err := New(zap.NewNop(), storage, tc.reader(ctrl), tc.writer(ctrl), images.Stores{storage: tc.store(ctrl, t)}, opts...)
+			require.NoError(t, err)
+
+			req.Force = tc.force
+			req.ID = tc.id
+			req.IdempotencyKey = tc.key
+			req.Metadata = tc.metadata
+			req.ObjectMetadata = tc.objMeta
+			req.Path = tc.path
+			req.Public = tc.public
+			req.AllowedTypes = tc.allowed
+			s, err := svc.Upload(context.Background(), req)
+			switch {
+			case tc.wantErr == images.ErrNameTaken:
+				assert.Equal(t, images.ErrNameTaken, err)
+			case tc.wantErr == images.ErrOrphanedObject, tc.wantErr == images.ErrInvalidID, tc.wantErr == images.ErrInvalidMetadata,
+				tc.wantErr == images.ErrTypeNotAllowed, tc.wantErr == images.ErrInvalidPath:
+				assert.True(t, errors.Is(err, tc.wantErr))
+			case tc.wantErr != nil:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+				assert.NotEmpty(t, s)
 			}
+		})
+	}
+}
+
+func Test_Service_Mirror(t *testing.T) {
+	storage := "sim"
+	mirror := "mirror"
+	for _, tc := range []struct {
+		desc string
+		do   func(t *testing.T, ctrl *gomock.Controller)
+	}{
+		{
+			desc: "Upload() should copy the image to the mirror and record it",
+			do: func(t *testing.T, ctrl *gomock.Controller) {
+				primary := mock_images.NewMockObjectStore(ctrl)
+				primary.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, body io.Reader, _ images.PutOptions) error {
+						_, err := ioutil.ReadAll(body)
+						return err
+					})
+				primary.
+					EXPECT().
+					Head(gomock.Any(), gomock.Any()).
+					Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 2}, nil)
+
+				secondary := mock_images.NewMockObjectStore(ctrl)
+				secondary.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, body io.Reader, _ images.PutOptions) error {
+						b, err := ioutil.ReadAll(body)
+						require.NoError(t, err)
+						assert.Equal(t, "hw", string(b))
+
+						return nil
+					})
+
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						assert.Equal(t, []string{mirror}, i.Mirrors)
+						return nil
+					})
+
+				svc, err := New(
+					zap.NewNop(),
+					storage,
+					nameAvailable(ctrl),
+					w,
+					images.Stores{storage: primary, mirror: secondary},
+					WithMirror(mirror, false),
+				)
+				require.NoError(t, err)
+				defer svc.Close()
+
+				_, err = svc.Upload(context.Background(), images.UploadRequest{Name: "test", Body: strings.NewReader("hw")})
+				assert.NoError(t, err)
+			},
+		},
+		{
+			desc: "Upload() should not record the mirror when failing to copy",
+			do: func(t *testing.T, ctrl *gomock.Controller) {
+				primary := mock_images.NewMockObjectStore(ctrl)
+				primary.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil)
+				primary.
+					EXPECT().
+					Head(gomock.Any(), gomock.Any()).
+					Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: 2}, nil)
+
+				secondary := mock_images.NewMockObjectStore(ctrl)
+				secondary.
+					EXPECT().
+					Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(errors.New("random"))
+
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						assert.Empty(t, i.Mirrors)
+						return nil
+					})
+
+				svc, err := New(
+					zap.NewNop(),
+					storage,
+					nameAvailable(ctrl),
+					w,
+					images.Stores{storage: primary, mirror: secondary},
+					WithMirror(mirror, false),
+				)
+				require.NoError(t, err)
+				defer svc.Close()
+
+				_, err = svc.Upload(context.Background(), images.UploadRequest{Name: "test", Body: strings.NewReader("hw")})
+				assert.NoError(t, err)
+			},
+		},
+		{
+			desc: "Download() should fall back to the mirror when the primary fails",
+			do: func(t *testing.T, ctrl *gomock.Controller) {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					Get(gomock.Any(), "id").
+					Return(&images.Record{Key: "key", Storage: storage, SizeInBytes: 2, Mirrors: []string{mirror}}, nil)
+
+				primary := mock_images.NewMockObjectStore(ctrl)
+				primary.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(0), errors.New("random"))
+
+				secondary := mock_images.NewMockObjectStore(ctrl)
+				secondary.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(2), nil)
+
+				svc, err := New(
+					zap.NewNop(),
+					storage,
+					r,
+					mock_images.NewMockWriter(ctrl),
+					images.Stores{storage: primary, mirror: secondary},
+				)
+				require.NoError(t, err)
+
+				err = svc.Download(context.Background(), images.DownloadRequest{ID: "id", Stream: manager.NewWriteAtBuffer(nil)})
+				assert.NoError(t, err)
+			},
+		},
+		{
+			desc: "New() should return an error when the mirror storage is not configured",
+			do: func(t *testing.T, ctrl *gomock.Controller) {
+				_, err := New(
+					zap.NewNop(),
+					storage,
+					mock_images.NewMockReader(ctrl),
+					mock_images.NewMockWriter(ctrl),
+					images.Stores{storage: mock_images.NewMockObjectStore(ctrl)},
+					WithMirror(mirror, true),
+				)
+				assert.Error(t, err)
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			tc.do(t, gomock.NewController(t))
+		})
+	}
+}
+
+func Test_Service_MigrateStorage(t *testing.T) {
+	from := "from"
+	to := "to"
+	body := "hw"
+	sum := md5.Sum([]byte(body))
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	rec := images.Record{ID: "id", ETag: etag, Key: "key", SizeInBytes: int64(len(body)), Storage: from}
+	expectGet := func(s *mock_images.MockObjectStore) {
+		s.
+			EXPECT().
+			Get(gomock.Any(), "key", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, stream io.WriterAt) (int64, error) {
+				n, err := stream.WriteAt([]byte(body), 0)
+				return int64(n), err
+			})
+	}
+	expectPut := func(s *mock_images.MockObjectStore, t *testing.T) {
+		s.
+			EXPECT().
+			Put(gomock.Any(), "key", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, r io.Reader, _ images.PutOptions) error {
+				b, err := ioutil.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, body, string(b))
+
+				return nil
+			})
+	}
+
+	for _, tc := range []struct {
+		desc    string
+		request images.MigrateStorageRequest
+		reader  func(ctrl *gomock.Controller) images.Reader
+		writer  func(ctrl *gomock.Controller, t *testing.T) images.Writer
+		from    func(ctrl *gomock.Controller) images.ObjectStore
+		to      func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore
+		want    *images.MigrateStorageResult
+		wantErr bool
+	}{
+		{
+			desc:    "MigrateStorage() should return an error when migrating a storage to itself",
+			request: images.MigrateStorageRequest{From: from, To: from},
+			reader:  func(ctrl *gomock.Controller) images.Reader { return mock_images.NewMockReader(ctrl) },
+			writer:  func(ctrl *gomock.Controller, t *testing.T) images.Writer { return mock_images.NewMockWriter(ctrl) },
+			from:    func(ctrl *gomock.Controller) images.ObjectStore { return mock_images.NewMockObjectStore(ctrl) },
+			to: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				return mock_images.NewMockObjectStore(ctrl)
+			},
+			wantErr: true,
+		},
+		{
+			desc:    "MigrateStorage() should not repoint the record when the copy does not match",
+			request: images.MigrateStorageRequest{From: from, To: to, DeleteOriginals: true},
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					List(gomock.Any(), gomock.Any()).
+					Return(&images.Page{Records: []images.Record{rec}}, nil)
+
+				return r
+			},
+			writer: func(ctrl *gomock.Controller, t *testing.T) images.Writer { return mock_images.NewMockWriter(ctrl) },
+			from: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectGet(s)
+
+				return s
+			},
+			to: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t)
+				s.
+					EXPECT().
+					Head(gomock.Any(), "key").
+					Return(&images.ObjectInfo{ETag: "00000000000000000000000000000000", SizeInBytes: 2}, nil)
+
+				return s
+			},
+			want: &images.MigrateStorageResult{Failed: []string{"id"}},
+		},
+		{
+			desc:    "MigrateStorage() - happy path",
+			request: images.MigrateStorageRequest{From: from, To: to, DeleteOriginals: true},
+			reader: func(ctrl *gomock.Controller) images.Reader {
+				r := mock_images.NewMockReader(ctrl)
+				r.
+					EXPECT().
+					List(gomock.Any(), gomock.Any()).
+					Return(&images.Page{Records: []images.Record{rec, {ID: "other", Storage: to}}}, nil)
 
-			svc, err := New(zap.NewNop(), storage, mock_images.NewMockReader(ctrl), tc.writer(ctrl), tc.sessionGetter)
-			svc.sdk.uploader = tc.uploader(ctrl, t)
-			svc.sdk.client = tc.client(ctrl)
+				return r
+			},
+			writer: func(ctrl *gomock.Controller, t *testing.T) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *images.Record) error {
+						assert.Equal(t, "id", i.ID)
+						assert.Equal(t, "key", i.Key)
+						assert.Equal(t, to, i.Storage)
+
+						return nil
+					})
+
+				return w
+			},
+			from: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectGet(s)
+				s.
+					EXPECT().
+					Delete(gomock.Any(), "key").
+					Return(nil)
+
+				return s
+			},
+			to: func(ctrl *gomock.Controller, t *testing.T) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				expectPut(s, t)
+				s.
+					EXPECT().
+					Head(gomock.Any(), "key").
+					Return(&images.ObjectInfo{ETag: etag, SizeInBytes: 2}, nil)
+
+				return s
+			},
+			want: &images.MigrateStorageResult{Migrated: 1},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			stores := images.Stores{from: tc.from(ctrl), to: tc.to(ctrl, t)}
+			svc, err := New(zap.NewNop(), from, tc.reader(ctrl), tc.writer(ctrl, t), stores)
 			require.NoError(t, err)
 
-			s, err := svc.Upload(r)
+			res, err := svc.MigrateStorage(context.Background(), tc.request)
 			if tc.wantErr {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.NotEmpty(t, s)
+				assert.Equal(t, tc.want, res)
 			}
 		})
 	}
 }
 
-func mockSessionGetter() (*session.Session, error) {
-	return new(session.Session), nil
-}
+func Test_Service_Update(t *testing.T) {
+	storage := "sim"
+	rec := &images.Record{ID: "id", Name: "renamed"}
+	for _, tc := range []struct {
+		desc    string
+		writer  func(ctrl *gomock.Controller) images.Writer
+		wantErr error
+	}{
+		{
+			desc: "Update() should return ErrRecordNotFound when the record does not exist",
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Update(gomock.Any(), rec).
+					Return(images.ErrRecordNotFound)
 
-func unwrapStr(s *string) string {
-	if s == nil {
-		return ""
+				return w
+			},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc: "Update() should return ErrConflict when the record was modified",
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Update(gomock.Any(), rec).
+					Return(images.ErrConflict)
+
+				return w
+			},
+			wantErr: images.ErrConflict,
+		},
+		{
+			desc: "Update() should return an error when failing to update the record",
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Update(gomock.Any(), rec).
+					Return(errors.New("random"))
+
+				return w
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc: "Update() - happy path",
+			writer: func(ctrl *gomock.Controller) images.Writer {
+				w := mock_images.NewMockWriter(ctrl)
+				w.
+					EXPECT().
+					Update(gomock.Any(), rec).
+					Return(nil)
+
+				return w
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			svc, err := New(zap.NewNop(), storage, mock_images.NewMockReader(ctrl), tc.writer(ctrl), images.Stores{storage: mock_images.NewMockObjectStore(ctrl)})
+			require.NoError(t, err)
+
+			err = svc.Update(context.Background(), rec)
+			switch {
+			case tc.wantErr == images.ErrRecordNotFound, tc.wantErr == images.ErrConflict:
+				assert.Equal(t, tc.wantErr, err)
+			case tc.wantErr != nil:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+		})
 	}
+}
+
+// nameAvailable returns a reader on which no image has the uploaded name.
+func nameAvailable(ctrl *gomock.Controller) images.Reader {
+	r := mock_images.NewMockReader(ctrl)
+	r.
+		EXPECT().
+		GetByName(gomock.Any(), gomock.Any()).
+		Return(nil, images.ErrRecordNotFound)
 
-	return *s
+	return r
 }
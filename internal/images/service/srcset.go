@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// Srcset stores the variants of the image scaled down to each of the
+// request's widths, like CreateVariant, and returns them with their URLs,
+// narrowest first. The image is decoded once for the variants it does not
+// have yet and the widths larger than the image share one variant of the
+// image's width. Returns ErrInvalidResize if a width is out of range,
+// ErrNotPublic if the request asks for the stable URLs of a private image,
+// ErrInvalidExpiry if the expiry of presigned URLs is out of range and the
+// errors of CreateVariant.
+func (s *Service) Srcset(ctx context.Context, r images.SrcsetRequest) ([]images.SrcsetCandidate, error) {
+	logger := s.logger.With(zap.String("imageId", r.ID), zap.Ints("widths", r.Widths), zap.String("format", r.Format))
+	logger.Info("attempting to create srcset")
+
+	widths := r.Widths
+	if len(widths) == 0 {
+		widths = images.DefaultSrcsetWidths
+	}
+	widths = append([]int(nil), widths...)
+	sort.Ints(widths)
+	if widths[0] <= 0 {
+		logger.Error("srcset widths out of range")
+		return nil, fmt.Errorf("%w: widths must be positive", images.ErrInvalidResize)
+	}
+	if err := validVariant(images.VariantRequest{Width: widths[len(widths)-1], Format: r.Format}); err != nil {
+		logger.Error("invalid variant", zap.Error(err))
+		return nil, err
+	}
+	if !r.Public && (r.Expires <= 0 || r.Expires > images.MaxURLExpiry) {
+		logger.Error("expiry out of range")
+		return nil, fmt.Errorf("%w: must be between 0s and %s", images.ErrInvalidExpiry, images.MaxURLExpiry)
+	}
+
+	rec, err := s.downloadRecord(ctx, r.ID, logger)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case r.Public && images.Visibility(rec) != images.VisibilityPublic:
+		logger.Error("image is not public")
+		return nil, images.ErrNotPublic
+	case rec.EncryptedKey != "":
+		logger.Error("image is encrypted")
+		return nil, fmt.Errorf("%w: variants of encrypted images would be stored unencrypted", images.ErrUnsupported)
+	}
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		candidates []images.SrcsetCandidate
+		img        image.Image
+		seen       = make(map[image.Point]bool)
+	)
+	for _, width := range widths {
+		job, err := s.newVariantJob(rec, store, images.VariantRequest{Width: width, Format: r.Format})
+		if err != nil {
+			logger.Error("image can not be decoded", zap.String("contentType", rec.ContentType))
+			return nil, err
+		}
+		variant := recordedVariant(rec, job.key)
+		if variant == nil {
+			if img == nil {
+				if img, err = s.decodeImage(ctx, rec, logger); err != nil {
+					const msg = "unable to decode image"
+					logger.Error(msg, zap.Error(err))
+					return nil, fmt.Errorf(msg+": %w", err)
+				}
+			}
+			b, bounds, err := encodeVariant(img, job)
+			if err != nil {
+				const msg = "unable to encode variant"
+				logger.Error(msg, zap.Error(err))
+				return nil, fmt.Errorf(msg+": %w", err)
+			}
+			if variant, err = s.storeVariant(ctx, job, b, bounds, logger); err != nil {
+				const msg = "unable to store variant"
+				logger.Error(msg, zap.Error(err))
+				return nil, fmt.Errorf(msg+": %w", err)
+			}
+		}
+		// the widths beyond the image's have its size
+		size := image.Pt(variant.Width, variant.Height)
+		if seen[size] {
+			continue
+		}
+		seen[size] = true
+
+		var url string
+		if r.Public {
+			url, err = s.publicKey(ctx, rec, variant.Key, logger)
+		} else {
+			url, err = s.presignKey(ctx, rec, variant.Key, r.Expires, logger)
+		}
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, images.SrcsetCandidate{
+			Width:   variant.Width,
+			URL:     url,
+			Variant: *variant,
+		})
+	}
+	logger.Info("successfully created srcset", zap.Int("candidates", len(candidates)))
+
+	return candidates, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Srcset(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 4))))
+	body := buf.Bytes()
+
+	public := images.Record{
+		ID:          "1",
+		Key:         "images/1/a.png",
+		Name:        "a.png",
+		ContentType: "image/png",
+		SizeInBytes: int64(len(body)),
+		Storage:     "sim",
+		Visibility:  images.VisibilityPublic,
+	}
+	for _, tc := range []struct {
+		desc    string
+		req     images.SrcsetRequest
+		rec     images.Record
+		mocks   func(w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+		want    []int
+		wantErr error
+	}{
+		{
+			desc: "Srcset() should store a variant of each width and return their urls",
+			req:  images.SrcsetRequest{Widths: []int{16, 2, 8}, Public: true},
+			rec:  public,
+			mocks: func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				// the image is decoded once
+				s.EXPECT().GetRange(gomock.Any(), public.Key, int64(0), int64(0), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+						n, err := w.Write(body)
+						return int64(n), err
+					})
+				for _, key := range []string{"images/1/variant/2x0.png", "images/1/variant/8x0.png", "images/1/variant/16x0.png"} {
+					s.EXPECT().Put(gomock.Any(), key, gomock.Any(), gomock.Any()).Return(nil)
+				}
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+				// 16 is wider than the image and shares the size of 8
+				s.EXPECT().URL(gomock.Any(), "images/1/variant/2x0.png").Return("https://example.com/2", nil)
+				s.EXPECT().URL(gomock.Any(), "images/1/variant/8x0.png").Return("https://example.com/8", nil)
+			},
+			want: []int{2, 8},
+		},
+		{
+			desc: "Srcset() should presign the urls of the variants the image has",
+			req:  images.SrcsetRequest{Widths: []int{2}, Expires: time.Hour},
+			rec: func() images.Record {
+				rec := public
+				rec.Visibility = images.VisibilityPrivate
+				rec.Variants = []images.Variant{{Key: "images/1/variant/2x0.png", Width: 2, Height: 1}}
+				return rec
+			}(),
+			mocks: func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				s.EXPECT().Presign(gomock.Any(), "images/1/variant/2x0.png", time.Hour).Return("https://example.com/2?sig", nil)
+			},
+			want: []int{2},
+		},
+		{
+			desc: "Srcset() should return ErrNotPublic when asked for the stable urls of a private image",
+			req:  images.SrcsetRequest{Public: true},
+			rec: func() images.Record {
+				rec := public
+				rec.Visibility = images.VisibilityPrivate
+				return rec
+			}(),
+			wantErr: images.ErrNotPublic,
+		},
+		{
+			desc:    "Srcset() should return ErrInvalidExpiry when the expiry is out of range",
+			req:     images.SrcsetRequest{},
+			rec:     public,
+			wantErr: images.ErrInvalidExpiry,
+		},
+		{
+			desc:    "Srcset() should return ErrInvalidResize when a width is not positive",
+			req:     images.SrcsetRequest{Widths: []int{0, 320}, Public: true},
+			rec:     public,
+			wantErr: images.ErrInvalidResize,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			rec := tc.rec
+			r.EXPECT().Get(gomock.Any(), public.ID).Return(&rec, nil).AnyTimes()
+			if tc.mocks != nil {
+				tc.mocks(w, s)
+			}
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			tc.req.ID = public.ID
+			got, err := svc.Srcset(context.Background(), tc.req)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+			widths := make([]int, len(got))
+			for i := range got {
+				widths[i] = got[i].Width
+				assert.NotEmpty(t, got[i].URL)
+			}
+			assert.Equal(t, tc.want, widths)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// sseKMS is the server-side encryption mode of objects encrypted with a KMS
+// key.
+const sseKMS = "aws:kms"
+
+// WithServerSideEncryption asks the storage to encrypt uploaded objects at
+// rest with the mode, i.e. aws:kms or AES256, and the KMS key for aws:kms.
+// Uploads may override both, see images.UploadRequest.
+func WithServerSideEncryption(mode, kmsKeyID string) Option {
+	return func(s *Service) {
+		s.serverSideEncryption = mode
+		s.kmsKeyID = kmsKeyID
+	}
+}
+
+// withSSE sets the server-side encryption of the upload's put options, the
+// request's settings replace the service's when either is set.
+func (s *Service) withSSE(r images.UploadRequest, opts images.PutOptions) images.PutOptions {
+	opts.ServerSideEncryption, opts.KMSKeyID = s.serverSideEncryption, s.kmsKeyID
+	if r.ServerSideEncryption != "" || r.KMSKeyID != "" {
+		opts.ServerSideEncryption, opts.KMSKeyID = r.ServerSideEncryption, r.KMSKeyID
+	}
+	if opts.ServerSideEncryption == "" && opts.KMSKeyID != "" {
+		opts.ServerSideEncryption = sseKMS
+	}
+
+	return opts
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_Service_withSSE(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		service Service
+		request images.UploadRequest
+		want    images.PutOptions
+	}{
+		{
+			desc: "withSSE() should leave the encryption to the storage without settings",
+			want: images.PutOptions{ContentType: "image/png"},
+		},
+		{
+			desc:    "withSSE() should use the service's settings",
+			service: Service{serverSideEncryption: "AES256"},
+			want:    images.PutOptions{ContentType: "image/png", ServerSideEncryption: "AES256"},
+		},
+		{
+			desc:    "withSSE() should replace the service's settings with the request's",
+			service: Service{serverSideEncryption: "AES256"},
+			request: images.UploadRequest{ServerSideEncryption: "aws:kms", KMSKeyID: "key-id"},
+			want:    images.PutOptions{ContentType: "image/png", ServerSideEncryption: "aws:kms", KMSKeyID: "key-id"},
+		},
+		{
+			desc:    "withSSE() should imply aws:kms for a KMS key on its own",
+			service: Service{kmsKeyID: "key-id"},
+			want:    images.PutOptions{ContentType: "image/png", ServerSideEncryption: "aws:kms", KMSKeyID: "key-id"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tc.service.withSSE(tc.request, images.PutOptions{ContentType: "image/png"})
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
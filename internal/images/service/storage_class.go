@@ -0,0 +1,10 @@
+package service
+
+// WithStorageClass uploads objects in the storage class, i.e. STANDARD_IA or
+// GLACIER_IR, unless the upload requests another. The storage's default class
+// is used otherwise.
+func WithStorageClass(class string) Option {
+	return func(s *Service) {
+		s.storageClass = class
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// streamed reports whether the request downloads sequentially into its
+// Writer, which it does when it has no Stream to write to at offsets.
+func streamed(r images.DownloadRequest) bool {
+	return r.Stream == nil && r.Writer != nil
+}
+
+// output returns the writer the request's download is written to in order.
+func output(r images.DownloadRequest) io.Writer {
+	if streamed(r) {
+		return r.Writer
+	}
+
+	return images.NewOffsetWriter(r.Stream)
+}
+
+// downloadStream downloads the record's object into the request's Writer
+// with a single sequential GetRange, decoding an encoded object as it
+// arrives. The download is hashed as it is written and checked against the
+// record like a download to a stream, a mismatch is only known once all of
+// it was written so it is up to the caller to discard the output on
+// ErrIntegrity.
+func (s *Service) downloadStream(ctx context.Context, rec *images.Record, store images.ObjectStore, r images.DownloadRequest, logger *zap.Logger) error {
+	if encoded(rec) {
+		return s.decodeStream(ctx, rec, store, r.Writer, logger)
+	}
+
+	var (
+		counter           countingWriter
+		name, h, expected = integrityHash(rec)
+		w                 = io.MultiWriter(r.Writer, &counter)
+	)
+	if h != nil {
+		w = io.MultiWriter(w, h)
+	}
+	if err := s.getStream(ctx, rec, store, w, logger); err != nil {
+		return err
+	}
+
+	if err := checkSize(rec, counter.n, logger); err != nil {
+		return err
+	}
+	if h != nil {
+		if err := checkSum(name, h, expected, logger); err != nil {
+			return err
+		}
+	}
+	logger.Info("successfully streamed file")
+
+	return nil
+}
+
+// decodeStream downloads the encoded object through a pipe into decode,
+// writing the decoded content into w. The object is checked against the
+// record's size and ETag, the content against its SHA-256.
+func (s *Service) decodeStream(ctx context.Context, rec *images.Record, store images.ObjectStore, w io.Writer, logger *zap.Logger) error {
+	var (
+		counter       countingWriter
+		_, h, etag    = integrityHash(rec)
+		pr, pw        = io.Pipe()
+		object        = io.MultiWriter(pw, &counter)
+		downloaded    = make(chan error, 1)
+		contentSHA256 = sha256.New()
+	)
+	if h != nil {
+		object = io.MultiWriter(object, h)
+	}
+	go func() {
+		err := s.getStream(ctx, rec, store, object, logger)
+		pw.CloseWithError(err)
+		downloaded <- err
+	}()
+	content, err := s.decode(rec, pr)
+	if err == nil {
+		_, err = io.Copy(io.MultiWriter(w, contentSHA256), content)
+	}
+	// unblocks the download when the decode ended early
+	pr.Close()
+	derr := <-downloaded
+	switch {
+	case derr != nil && !errors.Is(derr, io.ErrClosedPipe):
+		// a failed download fails the decode, its error is the cause
+		return derr
+	case err != nil:
+		const msg = "unable to decode object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	case derr != nil:
+		// the decode ended before the object did
+		const msg = "unable to decode object"
+		logger.Error(msg, zap.Error(derr))
+		return fmt.Errorf(msg+": %w", derr)
+	}
+
+	if err := checkSize(rec, counter.n, logger); err != nil {
+		return err
+	}
+	if h != nil {
+		if err := checkSum("etag", h, etag, logger); err != nil {
+			return err
+		}
+	}
+
+	return checkDecoded(rec, contentSHA256, logger)
+}
+
+// getStream downloads the record's object into w, falling back to its
+// mirrors when the primary storage fails before anything was written.
+func (s *Service) getStream(ctx context.Context, rec *images.Record, store images.ObjectStore, w io.Writer, logger *zap.Logger) error {
+	n, err := store.GetRange(ctx, rec.Key, 0, 0, w)
+	if err == nil {
+		return nil
+	}
+	if n == 0 && s.streamMirror(ctx, rec, w, logger) {
+		return nil
+	}
+
+	if err == images.ErrObjectNotFound {
+		logger.Error("object not found", zap.Error(err))
+		return err
+	}
+	const msg = "unable to download file"
+	logger.Error(msg, zap.Error(err))
+	return fmt.Errorf(msg+": %w", err)
+}
+
+// streamMirror attempts to download the record's object from its mirrors
+// into w, returning true on the first successful download. A mirror which
+// fails after writing part of the object ends the attempt.
+func (s *Service) streamMirror(ctx context.Context, rec *images.Record, w io.Writer, logger *zap.Logger) bool {
+	for _, storage := range rec.Mirrors {
+		logger := logger.With(zap.String("mirror", storage))
+		store, ok := s.stores[storage]
+		if !ok {
+			logger.Error("mirror storage not found")
+			continue
+		}
+
+		n, err := store.GetRange(ctx, rec.Key, 0, 0, w)
+		if err != nil {
+			logger.Error("unable to download from mirror", zap.Error(err))
+			if n > 0 {
+				return false
+			}
+			continue
+		}
+
+		logger.Warn("primary storage unavailable, downloaded from mirror")
+		return true
+	}
+
+	return false
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+
+	return len(p), nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Download_stream(t *testing.T) {
+	const content = "Hello, World!"
+	sum := sha256.Sum256([]byte(content))
+	compressed, cleanup, err := gzipBody(bytes.NewReader([]byte(content)), zap.NewNop())
+	require.NoError(t, err)
+	defer cleanup()
+	object, err := ioutil.ReadAll(compressed)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		desc    string
+		rec     images.Record
+		body    []byte
+		err     error
+		mirror  []byte
+		want    string
+		wantErr error
+	}{
+		{
+			desc: "Download() should stream the object into the writer",
+			rec:  images.Record{SizeInBytes: int64(len(content)), SHA256: hex.EncodeToString(sum[:])},
+			body: []byte(content),
+			want: content,
+		},
+		{
+			desc: "Download() should decode a compressed object as it streams",
+			rec: images.Record{
+				SizeInBytes:     int64(len(object)),
+				SHA256:          hex.EncodeToString(sum[:]),
+				ContentEncoding: images.EncodingGzip,
+			},
+			body: object,
+			want: content,
+		},
+		{
+			desc:    "Download() should return ErrIntegrity when the streamed object does not match its record",
+			rec:     images.Record{SizeInBytes: int64(len(content)), SHA256: hex.EncodeToString(make([]byte, sha256.Size))},
+			body:    []byte(content),
+			wantErr: images.ErrIntegrity,
+		},
+		{
+			desc:    "Download() should return ErrIntegrity when the streamed object is short",
+			rec:     images.Record{SizeInBytes: int64(len(content)) + 1},
+			body:    []byte(content),
+			wantErr: images.ErrIntegrity,
+		},
+		{
+			desc:    "Download() should return ErrIntegrity when the decoded content does not match its record",
+			rec:     images.Record{SizeInBytes: int64(len(object)), SHA256: hex.EncodeToString(make([]byte, sha256.Size)), ContentEncoding: images.EncodingGzip},
+			body:    object,
+			wantErr: images.ErrIntegrity,
+		},
+		{
+			desc:    "Download() should return the error of a failed download of a compressed object",
+			rec:     images.Record{SizeInBytes: int64(len(object)), ContentEncoding: images.EncodingGzip},
+			err:     errors.New("random"),
+			wantErr: errors.New("random"),
+		},
+		{
+			desc:   "Download() should stream the object from a mirror when the primary fails",
+			rec:    images.Record{SizeInBytes: int64(len(content)), Mirrors: []string{"backup"}},
+			err:    errors.New("random"),
+			mirror: []byte(content),
+			want:   content,
+		},
+		{
+			desc:    "Download() should return ErrObjectNotFound when the object does not exist",
+			rec:     images.Record{SizeInBytes: int64(len(content))},
+			err:     images.ErrObjectNotFound,
+			wantErr: images.ErrObjectNotFound,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			rec := tc.rec
+			rec.ID, rec.Key, rec.Storage = "1", "key", "sim"
+			r := mock_images.NewMockReader(ctrl)
+			r.EXPECT().Get(gomock.Any(), "1").Return(&rec, nil)
+			getRange := func(body []byte, err error) func(context.Context, string, int64, int64, io.Writer) (int64, error) {
+				return func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+					if err != nil {
+						return 0, err
+					}
+					return io.Copy(w, bytes.NewReader(body))
+				}
+			}
+			s, m := mock_images.NewMockObjectStore(ctrl), mock_images.NewMockObjectStore(ctrl)
+			s.EXPECT().GetRange(gomock.Any(), "key", int64(0), int64(0), gomock.Any()).DoAndReturn(getRange(tc.body, tc.err))
+			if tc.mirror != nil {
+				m.EXPECT().GetRange(gomock.Any(), "key", int64(0), int64(0), gomock.Any()).DoAndReturn(getRange(tc.mirror, nil))
+			}
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), images.Stores{"sim": s, "backup": m})
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = svc.Download(context.Background(), images.DownloadRequest{ID: "1", Writer: &buf})
+			if tc.wantErr != nil {
+				assert.Error(t, err)
+				if tc.wantErr == images.ErrIntegrity || tc.wantErr == images.ErrObjectNotFound {
+					assert.True(t, errors.Is(err, tc.wantErr))
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+}
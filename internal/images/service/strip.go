@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+// WithMetadataStripping strips the metadata of every uploaded jpeg and png
+// as if the upload requested it, see UploadRequest.StripMetadata. The
+// objects of presigned uploads are replaced with a stripped copy when they
+// are confirmed.
+func WithMetadataStripping() Option {
+	return func(s *Service) {
+		s.stripMetadata = true
+	}
+}
+
+// stripBody copies the jpeg or png body without its metadata into a temp
+// file and returns the file at its start, the cleanup func removes it.
+// Bodies of other types are returned as is. Returns ErrInvalidImage when
+// the body is not a valid jpeg or png.
+func stripBody(contentType string, body io.Reader, logger *zap.Logger) (io.Reader, func(), error) {
+	if !typeAllowed(contentType, exifTypes) {
+		logger.Warn("only the metadata of jpeg and png images is stripped", zap.String("contentType", contentType))
+		return body, func() {}, nil
+	}
+
+	f, err := ioutil.TempFile("", "sim-strip-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	if err := imaging.StripMetadata(f, body); err != nil {
+		cleanup()
+		logger.Error("unable to strip image metadata", zap.Error(err))
+		return nil, func() {}, fmt.Errorf("%w: %s", images.ErrInvalidImage, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		const msg = "unable to seek stripped image"
+		logger.Error(msg, zap.Error(err))
+		return nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	logger.Debug("stripped image metadata")
+
+	return f, cleanup, nil
+}
+
+// stripUploaded replaces the jpeg or png object of the pending record with a
+// copy without its metadata, returning false for objects of other types.
+func (s *Service) stripUploaded(ctx context.Context, store images.ObjectStore, rec *images.Record, logger *zap.Logger) (bool, error) {
+	if !typeAllowed(rec.ContentType, exifTypes) {
+		logger.Warn("only the metadata of jpeg and png images is stripped", zap.String("contentType", rec.ContentType))
+		return false, nil
+	}
+
+	f, err := ioutil.TempFile("", "sim-strip-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return false, fmt.Errorf(msg+": %w", err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	if _, err := store.GetRange(ctx, rec.Key, 0, 0, f); err != nil {
+		const msg = "unable to download object"
+		logger.Error(msg, zap.Error(err))
+		return false, fmt.Errorf(msg+": %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		const msg = "unable to seek object"
+		logger.Error(msg, zap.Error(err))
+		return false, fmt.Errorf(msg+": %w", err)
+	}
+
+	body, cleanup, err := stripBody(rec.ContentType, f, logger)
+	if err != nil {
+		return false, err
+	}
+	defer cleanup()
+
+	opts := s.derivedOptions(rec, rec.ContentType)
+	opts.StorageClass = s.storageClass
+	opts.ContentDisposition = rec.ContentDisposition
+	if err := store.Put(ctx, rec.Key, body, opts); err != nil {
+		const msg = "unable to upload stripped object"
+		logger.Error(msg, zap.Error(err))
+		return false, fmt.Errorf(msg+": %w", err)
+	}
+
+	return true, nil
+}
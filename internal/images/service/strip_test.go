@@ -0,0 +1,82 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Upload_StripMetadata(t *testing.T) {
+	// the markers of the jpeg without its exif segment
+	stripped := []byte{0xff, 0xd8, 0xff, 0xd9}
+	sum := sha256.Sum256(stripped)
+
+	for _, tc := range []struct {
+		desc    string
+		req     images.UploadRequest
+		opts    []Option
+		wantErr error
+	}{
+		{
+			desc: "Upload() should store and hash the jpeg without its metadata",
+			req:  images.UploadRequest{StripMetadata: true},
+		},
+		{
+			desc: "Upload() should strip the metadata of every upload with WithMetadataStripping",
+			opts: []Option{WithMetadataStripping()},
+		},
+		{
+			desc:    "Upload() should return ErrUnsupported when stripping a resumable upload",
+			req:     images.UploadRequest{StripMetadata: true, Session: &images.UploadSession{}},
+			wantErr: images.ErrUnsupported,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			if tc.wantErr == nil {
+				s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, body io.Reader, opts images.PutOptions) error {
+						b, err := ioutil.ReadAll(body)
+						require.NoError(t, err)
+						assert.Equal(t, stripped, b)
+						assert.Equal(t, "image/jpeg", opts.ContentType)
+						return nil
+					})
+				s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{SizeInBytes: int64(len(stripped))}, nil)
+				w.EXPECT().Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						assert.Equal(t, hex.EncodeToString(sum[:]), rec.SHA256)
+						assert.Nil(t, rec.Exif)
+						return nil
+					})
+			}
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, tc.opts...)
+			require.NoError(t, err)
+
+			tc.req.Name = "photo.jpg"
+			tc.req.Body = bytes.NewReader(canonJPEG())
+			tc.req.Force = true
+			_, err = svc.Upload(context.Background(), tc.req)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
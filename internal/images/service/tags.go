@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// tagAttempts is how many times a tag change is applied when the record is
+// modified concurrently.
+const tagAttempts = 3
+
+// WithObjectTags keeps the tags of the objects in sync with the tags of their
+// images, see images.ObjectTags, so that bucket lifecycle rules and cost
+// reports can use them. Objects shared by deduplicated images carry the tags
+// of the image changed last.
+func WithObjectTags() Option {
+	return func(s *Service) {
+		s.objectTags = true
+	}
+}
+
+// AddTags adds the tags to the image record and returns the updated record.
+// Returns ErrRecordNotFound if no record exists by the ID.
+func (s *Service) AddTags(ctx context.Context, id string, tags []string) (*images.Record, error) {
+	return s.updateTags(ctx, id, tags, images.AddTags)
+}
+
+// RemoveTags removes the tags from the image record and returns the updated
+// record. Returns ErrRecordNotFound if no record exists by the ID.
+func (s *Service) RemoveTags(ctx context.Context, id string, tags []string) (*images.Record, error) {
+	return s.updateTags(ctx, id, tags, images.RemoveTags)
+}
+
+// updateTags applies the change to the latest revision of the record, it is
+// re-read and the change re-applied when the record is modified concurrently.
+func (s *Service) updateTags(ctx context.Context, id string, tags []string, change func(*images.Record, []string) bool) (*images.Record, error) {
+	logger := s.logger.With(zap.String("imageId", id), zap.Strings("tags", tags))
+
+	tags, err := images.NormalizeTags(tags)
+	if err != nil {
+		logger.Error("invalid tags", zap.Error(err))
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		rec, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		// the object is tagged even when the record is unchanged so that a
+		// retry repairs a failed sync
+		if !change(rec, tags) {
+			if err := s.syncObjectTags(ctx, rec, logger); err != nil {
+				return nil, err
+			}
+			return rec, nil
+		}
+
+		err = s.Update(ctx, rec)
+		switch {
+		case err == nil:
+			logger.Info("successfully updated tags")
+			if err := s.syncObjectTags(ctx, rec, logger); err != nil {
+				return nil, err
+			}
+			return rec, nil
+		case err == images.ErrConflict && attempt < tagAttempts:
+			logger.Debug("record modified concurrently, retrying", zap.Int("attempt", attempt))
+		default:
+			const msg = "unable to update tags"
+			logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+	}
+}
+
+// syncObjectTags replaces the tags of the record's object with its tags when
+// WithObjectTags is set, stores without object tags are skipped.
+func (s *Service) syncObjectTags(ctx context.Context, rec *images.Record, logger *zap.Logger) error {
+	if !s.objectTags {
+		return nil
+	}
+
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return err
+	}
+	switch err := store.SetTags(ctx, rec.Key, rec.Tags); err {
+	case nil:
+		return nil
+	case images.ErrUnsupported:
+		logger.Debug("storage does not support object tags, skipping")
+		return nil
+	default:
+		const msg = "unable to tag object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_AddTags(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		tags    []string
+		mocks   func(r *mock_images.MockReader, w *mock_images.MockWriter)
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc:    "AddTags() should reject invalid tags",
+			tags:    []string{"summer vacation"},
+			mocks:   func(r *mock_images.MockReader, w *mock_images.MockWriter) {},
+			wantErr: true,
+		},
+		{
+			desc: "AddTags() should not update the record when it has the tags",
+			tags: []string{"vacation"},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter) {
+				r.EXPECT().Get(gomock.Any(), "id").Return(&images.Record{ID: "id", Tags: []string{"vacation"}}, nil)
+			},
+			want: []string{"vacation"},
+		},
+		{
+			desc: "AddTags() should retry when the record is modified concurrently",
+			tags: []string{"beach"},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter) {
+				gomock.InOrder(
+					r.EXPECT().Get(gomock.Any(), "id").Return(&images.Record{ID: "id", Revision: 1}, nil),
+					w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(images.ErrConflict),
+					r.EXPECT().Get(gomock.Any(), "id").Return(&images.Record{ID: "id", Revision: 2, Tags: []string{"vacation"}}, nil),
+					w.
+						EXPECT().
+						Update(gomock.Any(), &images.Record{ID: "id", Revision: 2, Tags: []string{"vacation", "beach"}}).
+						Return(nil),
+				)
+			},
+			want: []string{"vacation", "beach"},
+		},
+		{
+			desc: "AddTags() should give up after repeated conflicts",
+			tags: []string{"beach"},
+			mocks: func(r *mock_images.MockReader, w *mock_images.MockWriter) {
+				r.
+					EXPECT().
+					Get(gomock.Any(), "id").
+					DoAndReturn(func(_ context.Context, id string) (*images.Record, error) { return &images.Record{ID: id}, nil }).
+					Times(tagAttempts)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(images.ErrConflict).Times(tagAttempts)
+			},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl)
+			tc.mocks(r, w)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)})
+			require.NoError(t, err)
+
+			rec, err := svc.AddTags(context.Background(), "id", tc.tags)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, rec.Tags)
+		})
+	}
+}
+
+func Test_Service_syncObjectTags(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		err     error
+		wantErr bool
+	}{
+		{
+			desc: "AddTags() should tag the object with the record's tags",
+		},
+		{
+			desc: "AddTags() should skip stores without object tags",
+			err:  images.ErrUnsupported,
+		},
+		{
+			desc:    "AddTags() should return an error when failing to tag the object",
+			err:     errors.New("random"),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			r.EXPECT().Get(gomock.Any(), "id").Return(&images.Record{ID: "id", Key: "key", Storage: "sim", Tags: []string{"vacation"}}, nil)
+			w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+			s.EXPECT().SetTags(gomock.Any(), "key", []string{"vacation", "beach"}).Return(tc.err)
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithObjectTags())
+			require.NoError(t, err)
+
+			_, err = svc.AddTags(context.Background(), "id", []string{"beach"})
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+const (
+	// textSize is the longest side images are scaled down to before their
+	// text is extracted, documents scanned at up to 175 DPI keep their size
+	// while the image stays under the size the extractor accepts.
+	textSize = 2048
+
+	// maxTextLen is the most bytes of extracted text recorded, the rest of
+	// the text of a dense document is dropped so its record stays small.
+	maxTextLen = 32 << 10
+)
+
+// WithTextExtraction extracts the text visible in every uploaded jpeg, png,
+// gif and webp with the extractor and records it on the image's record, see
+// Record.Text, so that screenshots and scanned documents can be found by
+// their text. Failing to extract it only logs, the image is uploaded without
+// it. Encrypted images have none as their content would be sent to the
+// extractor.
+func WithTextExtraction(extractor images.TextExtractor) Option {
+	return func(s *Service) {
+		s.textExtractor = extractor
+	}
+}
+
+// extractText returns the text of the record's decoded image, at most
+// maxTextLen bytes of it.
+func (s *Service) extractText(ctx context.Context, img image.Image, logger *zap.Logger) string {
+	var buf bytes.Buffer
+	if err := imaging.EncodeJPEG(&buf, imaging.Fit(img, textSize, textSize), imaging.DefaultJPEGQuality); err != nil {
+		logger.Warn("unable to encode image for text extraction", zap.Error(err))
+		return ""
+	}
+	text, err := s.textExtractor.ExtractText(ctx, buf.Bytes())
+	if err != nil {
+		logger.Warn("unable to extract text", zap.Error(err))
+		return ""
+	}
+	if len(text) > maxTextLen {
+		logger.Info("truncating extracted text", zap.Int("length", len(text)))
+		text = text[:maxTextLen]
+		// drop the rune cut in half
+		for len(text) > 0 && !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	logger.Info("extracted text", zap.Int("length", len(text)))
+
+	return text
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_TextExtraction(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 4))))
+	body := buf.Bytes()
+
+	long := strings.Repeat("a", maxTextLen-1) + "é"
+	for _, tc := range []struct {
+		desc    string
+		text    string
+		textErr error
+		want    string
+	}{
+		{
+			desc: "Upload() should record the text of the image",
+			text: "INVOICE 42\nTotal Due: $10",
+			want: "INVOICE 42\nTotal Due: $10",
+		},
+		{
+			desc:    "Upload() should upload an image whose text can not be extracted without it",
+			textErr: assert.AnError,
+		},
+		{
+			desc: "Upload() should truncate long text without cutting a rune in half",
+			text: long,
+			want: long[:maxTextLen-1],
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			s.EXPECT().Put(gomock.Any(), "images/2/b.png", gomock.Any(), gomock.Any()).Return(nil)
+			s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: int64(len(body))}, nil)
+			s.EXPECT().GetRange(gomock.Any(), gomock.Any(), int64(0), int64(0), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+					n, err := w.Write(body)
+					return int64(n), err
+				})
+			e := mock_images.NewMockTextExtractor(ctrl)
+			e.EXPECT().ExtractText(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, jpeg []byte) (string, error) {
+					// the image is sent as a jpeg
+					assert.Equal(t, []byte{0xff, 0xd8}, jpeg[:2])
+					return tc.text, tc.textErr
+				})
+			w.EXPECT().Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, rec *images.Record) error {
+					assert.Equal(t, tc.want, rec.Text)
+					return nil
+				})
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithTextExtraction(e), WithIDGenerator(func() string { return "2" }))
+			require.NoError(t, err)
+
+			_, err = svc.Upload(context.Background(), images.UploadRequest{
+				Name:        "b.png",
+				Body:        bytes.NewReader(body),
+				ContentType: "image/png",
+				Force:       true,
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+// maxDecodePixels is the largest image, in pixels, which is decoded so that
+// processing an image does not exhaust the memory.
+const maxDecodePixels = 50 * 1000 * 1000
+
+// WithThumbnails generates a JPEG thumbnail of every uploaded jpeg, png,
+// gif and webp for each of the sizes, the length its longest side is scaled down to,
+// i.e. 256. The thumbnails are stored in the image's storage under
+// {prefix}/{id}/thumb/{size}.jpg and recorded on the image's record.
+// Failing to generate them only logs, the image is uploaded without them.
+// Encrypted images have none as their thumbnails would not be encrypted.
+func WithThumbnails(sizes ...int) Option {
+	return func(s *Service) {
+		s.thumbnailSizes = append([]int(nil), sizes...)
+		sort.Ints(s.thumbnailSizes)
+	}
+}
+
+// thumbnailKey returns the key of the image's thumbnail of the size.
+func (t *KeyTemplate) thumbnailKey(id string, size int) string {
+	return fmt.Sprintf("%s%s/thumb/%d.jpg", t.static, id, size)
+}
+
+// thumbnails generates the thumbnails of the record's decoded image and
+// uploads them under the keys of the image's ID. It returns the thumbnails
+// which were uploaded.
+func (s *Service) thumbnails(ctx context.Context, store images.ObjectStore, rec *images.Record, img image.Image, logger *zap.Logger) []images.Thumbnail {
+	if len(s.thumbnailSizes) == 0 {
+		return nil
+	}
+
+	opts := s.derivedOptions(rec, "image/jpeg")
+	var thumbs []images.Thumbnail
+	for _, size := range s.thumbnailSizes {
+		logger := logger.With(zap.Int("thumbnailSize", size))
+
+		thumb := imaging.Fit(img, size, size)
+		var buf bytes.Buffer
+		if err := imaging.EncodeJPEG(&buf, thumb, imaging.DefaultJPEGQuality); err != nil {
+			logger.Warn("unable to encode thumbnail", zap.Error(err))
+			continue
+		}
+		key := s.keys.thumbnailKey(rec.ID, size)
+		n := int64(buf.Len())
+		if err := store.Put(ctx, key, &buf, opts); err != nil {
+			logger.Warn("unable to upload thumbnail", zap.String("thumbnailKey", key), zap.Error(err))
+			continue
+		}
+
+		b := thumb.Bounds()
+		thumbs = append(thumbs, images.Thumbnail{
+			Size:        size,
+			Key:         key,
+			Width:       b.Dx(),
+			Height:      b.Dy(),
+			SizeInBytes: n,
+		})
+	}
+	logger.Info("generated thumbnails", zap.Int("thumbnails", len(thumbs)))
+
+	return thumbs
+}
+
+// derivedOptions returns the options of an object derived from the
+// record's, of the content type, which is read like the image's object.
+func (s *Service) derivedOptions(rec *images.Record, contentType string) images.PutOptions {
+	opts := s.withSSE(images.UploadRequest{}, images.PutOptions{
+		ContentType:  contentType,
+		CacheControl: rec.CacheControl,
+		Public:       images.Visibility(rec) == images.VisibilityPublic,
+	})
+	if s.objectTags {
+		opts.Tags = rec.Tags
+	}
+
+	return opts
+}
+
+// decodeImage downloads the record's object through a pipe into the image
+// decoder. Images larger than maxDecodePixels are not decoded.
+func (s *Service) decodeImage(ctx context.Context, rec *images.Record, logger *zap.Logger) (image.Image, error) {
+	pr, pw := io.Pipe()
+	downloaded := make(chan error, 1)
+	go func() {
+		err := s.download(ctx, rec, images.DownloadRequest{ID: rec.ID, Writer: pw}, logger)
+		pw.CloseWithError(err)
+		downloaded <- err
+	}()
+	// unblocks the download when the decode ended early
+	defer func() {
+		pr.Close()
+		<-downloaded
+	}()
+
+	img, err := decode(pr)
+	if err != nil {
+		return nil, err
+	}
+	// the download is checked once all of it was read
+	if _, err := io.Copy(ioutil.Discard, pr); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// decode decodes the image read from r. Images larger than maxDecodePixels
+// are not decoded.
+func decode(r io.Reader) (image.Image, error) {
+	var head bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxDecodePixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(io.MultiReader(&head, r))
+
+	return img, err
+}
+
+// deleteDerived removes the thumbnails and variants of the record which keep
+// does not have as well, i.e. those of an image an overwrite replaced, and
+// invalidates them on the CDN. Failing to remove one only leaves an orphaned
+// object behind for GC.
+func (s *Service) deleteDerived(ctx context.Context, rec, keep *images.Record, logger *zap.Logger) {
+	if len(rec.Thumbnails) == 0 && len(rec.Variants) == 0 {
+		return
+	}
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return
+	}
+
+	kept := make(map[string]bool)
+	if keep != nil && keep.Storage == rec.Storage {
+		for _, key := range images.DerivedKeys(keep) {
+			kept[key] = true
+		}
+	}
+	var deleted []string
+	for _, key := range images.DerivedKeys(rec) {
+		if kept[key] {
+			continue
+		}
+		if err := store.Delete(ctx, key); err != nil && err != images.ErrObjectNotFound {
+			logger.Warn("unable to delete derived object", zap.String("derivedKey", key), zap.Error(err))
+			continue
+		}
+		deleted = append(deleted, key)
+	}
+	s.invalidate(ctx, rec.Storage, deleted, logger)
+}
+
+// copyThumbnails copies the record's thumbnails between the stores with the
+// options of the migrated object, returning those which were copied. A
+// thumbnail which fails to copy is dropped from the record rather than
+// failing the migration.
+func (s *Service) copyThumbnails(ctx context.Context, rec *images.Record, from, to images.ObjectStore, opts images.PutOptions, logger *zap.Logger) []images.Thumbnail {
+	opts.ContentType = "image/jpeg"
+	opts.ContentDisposition = ""
+	opts.Metadata = nil
+
+	var thumbs []images.Thumbnail
+	for _, thumb := range rec.Thumbnails {
+		logger := logger.With(zap.String("thumbnailKey", thumb.Key))
+
+		var buf bytes.Buffer
+		if _, err := from.GetRange(ctx, thumb.Key, 0, 0, &buf); err != nil {
+			logger.Warn("unable to download thumbnail, dropping it", zap.Error(err))
+			continue
+		}
+		if err := to.Put(ctx, thumb.Key, &buf, opts); err != nil {
+			logger.Warn("unable to upload thumbnail, dropping it", zap.Error(err))
+			continue
+		}
+		thumbs = append(thumbs, thumb)
+	}
+
+	return thumbs
+}
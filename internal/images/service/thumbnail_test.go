@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Thumbnails(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	src.Set(0, 0, color.Black)
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+	body := buf.Bytes()
+
+	existing := images.Record{
+		ID:      "1",
+		Key:     "images/1/a.png",
+		Name:    "a.png",
+		Storage: "sim",
+		Thumbnails: []images.Thumbnail{
+			{Size: 2, Key: "images/1/thumb/2.jpg"},
+		},
+	}
+	for _, tc := range []struct {
+		desc        string
+		contentType string
+		body        []byte
+		mocks       func(w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+		want        []images.Thumbnail
+	}{
+		{
+			desc:        "Upload() should generate and record a thumbnail of each size",
+			contentType: "image/png",
+			body:        body,
+			mocks: func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				s.EXPECT().Put(gomock.Any(), "images/2/thumb/2.jpg", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _ io.Reader, opts images.PutOptions) error {
+						assert.Equal(t, "image/jpeg", opts.ContentType)
+						return nil
+					})
+				s.EXPECT().Put(gomock.Any(), "images/2/thumb/16.jpg", gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want: []images.Thumbnail{
+				{Size: 2, Key: "images/2/thumb/2.jpg", Width: 2, Height: 1},
+				{Size: 16, Key: "images/2/thumb/16.jpg", Width: 8, Height: 4},
+			},
+		},
+		{
+			desc:        "Upload() should not record the thumbnails which fail to upload",
+			contentType: "image/png",
+			body:        body,
+			mocks: func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				s.EXPECT().Put(gomock.Any(), "images/2/thumb/2.jpg", gomock.Any(), gomock.Any()).Return(assert.AnError)
+				s.EXPECT().Put(gomock.Any(), "images/2/thumb/16.jpg", gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want: []images.Thumbnail{
+				{Size: 16, Key: "images/2/thumb/16.jpg", Width: 8, Height: 4},
+			},
+		},
+		{
+			desc:        "Upload() should upload an image which fails to decode without thumbnails",
+			contentType: "image/png",
+			body:        []byte("not a png"),
+			mocks:       func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) {},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			r.EXPECT().GetByName(gomock.Any(), "b.png").Return(nil, images.ErrRecordNotFound)
+			s.EXPECT().Put(gomock.Any(), "images/2/b.png", gomock.Any(), gomock.Any()).Return(nil)
+			s.EXPECT().Head(gomock.Any(), gomock.Any()).Return(&images.ObjectInfo{ETag: "etag", SizeInBytes: int64(len(tc.body))}, nil)
+			s.EXPECT().GetRange(gomock.Any(), gomock.Any(), int64(0), int64(0), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+					n, err := w.Write(tc.body)
+					return int64(n), err
+				})
+			tc.mocks(w, s)
+			w.EXPECT().Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, rec *images.Record) error {
+					for i := range rec.Thumbnails {
+						assert.NotZero(t, rec.Thumbnails[i].SizeInBytes)
+						rec.Thumbnails[i].SizeInBytes = 0
+					}
+					assert.Equal(t, tc.want, rec.Thumbnails)
+					return nil
+				})
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithThumbnails(16, 2), WithIDGenerator(func() string { return "2" }))
+			require.NoError(t, err)
+
+			_, err = svc.Upload(context.Background(), images.UploadRequest{
+				Name:        "b.png",
+				Body:        bytes.NewReader(tc.body),
+				ContentType: tc.contentType,
+			})
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("Delete() should remove the image's thumbnails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+		rec := existing
+		r.EXPECT().Get(gomock.Any(), existing.ID).Return(&rec, nil)
+		w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+		s.EXPECT().Delete(gomock.Any(), existing.Key).Return(nil)
+		s.EXPECT().Delete(gomock.Any(), "images/1/thumb/2.jpg").Return(images.ErrObjectNotFound)
+		w.EXPECT().Delete(gomock.Any(), existing.ID).Return(nil)
+		svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithThumbnails(2))
+		require.NoError(t, err)
+
+		assert.NoError(t, svc.Delete(context.Background(), existing.ID))
+	})
+
+	t.Run("New() should return an error when a thumbnail size is not positive", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		s := mock_images.NewMockObjectStore(ctrl)
+		_, err := New(zap.NewNop(), "sim", mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), images.Stores{"sim": s}, WithThumbnails(0))
+		assert.Error(t, err)
+	})
+}
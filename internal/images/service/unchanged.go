@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// digests are the hex encoded checksums of an upload's body.
+type digests struct {
+	md5    string
+	sha256 string
+}
+
+// hashBody returns the digests of the body and a reader which replays it,
+// which must be used in its place. The body is spooled to a temp file unless
+// it can seek, the cleanup func removes the spooled body.
+func hashBody(body io.Reader, logger *zap.Logger) (*digests, io.Reader, func(), error) {
+	cleanup := func() {}
+
+	seeker, ok := body.(io.ReadSeeker)
+	if !ok {
+		f, err := ioutil.TempFile("", "sim-upload-*")
+		if err != nil {
+			const msg = "unable to create temp file"
+			logger.Error(msg, zap.Error(err))
+			return nil, nil, cleanup, fmt.Errorf(msg+": %w", err)
+		}
+		cleanup = func() {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		body, seeker = io.TeeReader(body, f), f
+	}
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), body); err != nil {
+		cleanup()
+		const msg = "unable to hash image"
+		logger.Error(msg, zap.Error(err))
+		return nil, nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		const msg = "unable to seek image"
+		logger.Error(msg, zap.Error(err))
+		return nil, nil, func() {}, fmt.Errorf(msg+": %w", err)
+	}
+
+	d := digests{
+		md5:    hex.EncodeToString(md5Hash.Sum(nil)),
+		sha256: hex.EncodeToString(sha256Hash.Sum(nil)),
+	}
+
+	return &d, seeker, cleanup, nil
+}
+
+// unchanged returns the image with the name when its content has the
+// digests, otherwise nil.
+func (s *Service) unchanged(ctx context.Context, name string, d *digests, logger *zap.Logger) (*images.Record, error) {
+	existing, err := s.reader.GetByName(ctx, name)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		return nil, nil
+	default:
+		const msg = "unable to check image name"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	if !sameContent(existing, d) {
+		return nil, nil
+	}
+
+	return existing, nil
+}
+
+// sameContent reports whether the record's object has the digests. The
+// SHA-256 is preferred, records without one are compared by their ETag when
+// it is an MD5. Records which can not be compared are never the same.
+func sameContent(rec *images.Record, d *digests) bool {
+	if rec.SHA256 != "" {
+		return strings.EqualFold(rec.SHA256, d.sha256)
+	}
+	if etag, ok := md5ETag(rec.ETag, rec.ServerSideEncryption); ok {
+		return strings.EqualFold(etag, d.md5)
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_unchanged(t *testing.T) {
+	md5Sum, sha256Sum := md5.Sum([]byte("hw")), sha256.Sum256([]byte("hw"))
+	etag, sum := hex.EncodeToString(md5Sum[:]), hex.EncodeToString(sha256Sum[:])
+	for _, tc := range []struct {
+		desc    string
+		rec     *images.Record
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{
+			desc: "unchanged() should return nil when there is no image with the name",
+			err:  images.ErrRecordNotFound,
+		},
+		{
+			desc:    "unchanged() should return an error when failing to check the name",
+			err:     errors.New("random"),
+			wantErr: true,
+		},
+		{
+			desc: "unchanged() should return the image when its SHA-256 matches",
+			rec:  &images.Record{ID: "1", SHA256: strings.ToUpper(sum), ETag: "other"},
+			want: true,
+		},
+		{
+			desc: "unchanged() should return the image when its ETag matches",
+			rec:  &images.Record{ID: "1", ETag: `"` + etag + `"`},
+			want: true,
+		},
+		{
+			desc: "unchanged() should return nil when the content differs",
+			rec:  &images.Record{ID: "1", SHA256: "other", ETag: `"` + etag + `"`},
+		},
+		{
+			desc: "unchanged() should return nil when the image can not be compared",
+			rec:  &images.Record{ID: "1", ETag: `"abc-2"`},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r := mock_images.NewMockReader(ctrl)
+			r.EXPECT().GetByName(gomock.Any(), "test").Return(tc.rec, tc.err)
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), images.Stores{"sim": mock_images.NewMockObjectStore(ctrl)})
+			require.NoError(t, err)
+
+			got, err := svc.unchanged(context.Background(), "test", &digests{md5: etag, sha256: sum}, zap.NewNop())
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.want {
+				assert.Equal(t, tc.rec, got)
+			} else {
+				assert.Nil(t, got)
+			}
+		})
+	}
+}
+
+func Test_hashBody(t *testing.T) {
+	md5Sum, sha256Sum := md5.Sum([]byte("hw")), sha256.Sum256([]byte("hw"))
+	want := &digests{md5: hex.EncodeToString(md5Sum[:]), sha256: hex.EncodeToString(sha256Sum[:])}
+	for _, tc := range []struct {
+		desc string
+		body io.Reader
+	}{
+		{
+			desc: "hashBody() should replay a body which can seek",
+			body: strings.NewReader("hw"),
+		},
+		{
+			desc: "hashBody() should spool a body which can not seek",
+			body: ioutil.NopCloser(strings.NewReader("hw")),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, replay, cleanup, err := hashBody(tc.body, zap.NewNop())
+			require.NoError(t, err)
+			defer cleanup()
+			assert.Equal(t, want, got)
+
+			b, err := ioutil.ReadAll(replay)
+			require.NoError(t, err)
+			assert.Equal(t, "hw", string(b))
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// PresignURL creates a URL which grants read access to the image's object
+// for the duration, so that it can be shared or embedded without its bytes
+// passing through the service. The duration must be positive and at most
+// images.MaxURLExpiry, ErrInvalidExpiry is returned otherwise. Compressed
+// and encrypted objects are not the image as uploaded so they can not be
+// shared by URL, ErrUnsupported is returned for them. When a CDN serves the
+// image's storage the URL is signed for the CDN, falling back to presigning
+// it with the storage when there is no key to sign CDN URLs with.
+func (s *Service) PresignURL(ctx context.Context, id string, expires time.Duration) (string, error) {
+	logger := s.logger.With(zap.String("imageId", id), zap.Duration("expires", expires))
+	logger.Info("attempting to presign url")
+
+	if expires <= 0 || expires > images.MaxURLExpiry {
+		logger.Error("expiry out of range")
+		return "", fmt.Errorf("%w: must be between 0s and %s", images.ErrInvalidExpiry, images.MaxURLExpiry)
+	}
+
+	rec, err := s.downloadRecord(ctx, id, logger)
+	if err != nil {
+		return "", err
+	}
+	if encoded(rec) {
+		logger.Error("object is encoded")
+		return "", fmt.Errorf("%w: compressed and encrypted images can not be shared by url", images.ErrUnsupported)
+	}
+
+	return s.presignKey(ctx, rec, rec.Key, expires, logger)
+}
+
+// presignKey presigns the URL of the object under the key in the record's
+// storage, signing it for the CDN when it serves the storage.
+func (s *Service) presignKey(ctx context.Context, rec *images.Record, key string, expires time.Duration, logger *zap.Logger) (string, error) {
+	if signer := s.servedURLs(rec); signer != nil {
+		url, err := signer.Sign(key, time.Now().Add(expires))
+		switch {
+		case err == nil:
+			logger.Info("successfully signed cdn url")
+			return url, nil
+		case errors.Is(err, images.ErrUnsupported):
+			logger.Debug("cdn urls are not signed, presigning with storage")
+		default:
+			const msg = "unable to sign cdn url"
+			logger.Error(msg, zap.Error(err))
+			return "", fmt.Errorf(msg+": %w", err)
+		}
+	}
+
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return "", err
+	}
+	url, err := store.Presign(ctx, key, expires)
+	if err != nil {
+		const msg = "unable to presign url"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("successfully presigned url")
+
+	return url, nil
+}
+
+// PublicURL returns the stable URL of the public image's object, which unlike
+// a presigned URL does not expire, i.e. to embed the image in a site.
+// Returns ErrNotPublic if the image is private and ErrUnsupported if its
+// storage has no URLs or its object is compressed or encrypted. When a CDN
+// serves the image's storage the URL is the object's on the CDN.
+func (s *Service) PublicURL(ctx context.Context, id string) (string, error) {
+	logger := s.logger.With(zap.String("imageId", id))
+
+	rec, err := s.downloadRecord(ctx, id, logger)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case images.Visibility(rec) != images.VisibilityPublic:
+		logger.Error("image is not public")
+		return "", images.ErrNotPublic
+	case encoded(rec):
+		logger.Error("object is encoded")
+		return "", fmt.Errorf("%w: compressed and encrypted images can not be shared by url", images.ErrUnsupported)
+	}
+
+	return s.publicKey(ctx, rec, rec.Key, logger)
+}
+
+// publicKey returns the stable URL of the object under the key in the
+// public record's storage, its URL on the CDN when it serves the storage.
+func (s *Service) publicKey(ctx context.Context, rec *images.Record, key string, logger *zap.Logger) (string, error) {
+	if signer := s.servedURLs(rec); signer != nil {
+		return signer.URL(key), nil
+	}
+
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return "", err
+	}
+	url, err := store.URL(ctx, key)
+	if err != nil {
+		const msg = "unable to get object url"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+
+	return url, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_PresignURL(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		desc    string
+		expires time.Duration
+		rec     *images.Record
+		presign func(store *mock_images.MockObjectStore)
+		want    string
+		wantErr error
+	}{
+		{
+			desc:    "PresignURL() should presign a url for the image's object",
+			expires: 15 * time.Minute,
+			rec:     &images.Record{ID: "1", Key: "key", Storage: "sim"},
+			presign: func(store *mock_images.MockObjectStore) {
+				store.EXPECT().Presign(gomock.Any(), "key", 15*time.Minute).Return("https://signed", nil)
+			},
+			want: "https://signed",
+		},
+		{
+			desc:    "PresignURL() should return ErrInvalidExpiry when the expiry is not positive",
+			wantErr: images.ErrInvalidExpiry,
+		},
+		{
+			desc:    "PresignURL() should return ErrInvalidExpiry when the expiry is too long",
+			expires: images.MaxURLExpiry + time.Second,
+			wantErr: images.ErrInvalidExpiry,
+		},
+		{
+			desc:    "PresignURL() should return ErrRecordNotFound when the image is being deleted",
+			expires: time.Minute,
+			rec:     &images.Record{ID: "1", Key: "key", Storage: "sim", DeletingAt: &now},
+			wantErr: images.ErrRecordNotFound,
+		},
+		{
+			desc:    "PresignURL() should return ErrUnsupported when the object is encoded",
+			expires: time.Minute,
+			rec:     &images.Record{ID: "1", Key: "key", Storage: "sim", ContentEncoding: "gzip"},
+			wantErr: images.ErrUnsupported,
+		},
+		{
+			desc:    "PresignURL() should return an error when the storage fails to presign",
+			expires: time.Minute,
+			rec:     &images.Record{ID: "1", Key: "key", Storage: "sim"},
+			presign: func(store *mock_images.MockObjectStore) {
+				store.EXPECT().Presign(gomock.Any(), "key", time.Minute).Return("", images.ErrUnsupported)
+			},
+			wantErr: images.ErrUnsupported,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			reader := mock_images.NewMockReader(ctrl)
+			if tc.rec != nil {
+				reader.EXPECT().Get(gomock.Any(), "1").Return(tc.rec, nil)
+			}
+			store := mock_images.NewMockObjectStore(ctrl)
+			if tc.presign != nil {
+				tc.presign(store)
+			}
+
+			svc, err := New(zap.NewNop(), "sim", reader, mock_images.NewMockWriter(ctrl), images.Stores{"sim": store})
+			require.NoError(t, err)
+
+			got, err := svc.PresignURL(context.Background(), "1", tc.expires)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_Service_PublicURL(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		rec     *images.Record
+		url     func(store *mock_images.MockObjectStore)
+		want    string
+		wantErr error
+	}{
+		{
+			desc: "PublicURL() should return the url of the public image's object",
+			rec:  &images.Record{ID: "1", Key: "key", Storage: "sim", Visibility: images.VisibilityPublic},
+			url: func(store *mock_images.MockObjectStore) {
+				store.EXPECT().URL(gomock.Any(), "key").Return("https://bucket/key", nil)
+			},
+			want: "https://bucket/key",
+		},
+		{
+			desc:    "PublicURL() should return ErrNotPublic when the image is private",
+			rec:     &images.Record{ID: "1", Key: "key", Storage: "sim"},
+			wantErr: images.ErrNotPublic,
+		},
+		{
+			desc:    "PublicURL() should return ErrUnsupported when the object is encoded",
+			rec:     &images.Record{ID: "1", Key: "key", Storage: "sim", Visibility: images.VisibilityPublic, EncryptedKey: "k"},
+			wantErr: images.ErrUnsupported,
+		},
+		{
+			desc: "PublicURL() should return ErrUnsupported when the storage has no urls",
+			rec:  &images.Record{ID: "1", Key: "key", Storage: "sim", Visibility: images.VisibilityPublic},
+			url: func(store *mock_images.MockObjectStore) {
+				store.EXPECT().URL(gomock.Any(), "key").Return("", images.ErrUnsupported)
+			},
+			wantErr: images.ErrUnsupported,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			reader := mock_images.NewMockReader(ctrl)
+			reader.EXPECT().Get(gomock.Any(), "1").Return(tc.rec, nil)
+			store := mock_images.NewMockObjectStore(ctrl)
+			if tc.url != nil {
+				tc.url(store)
+			}
+
+			svc, err := New(zap.NewNop(), "sim", reader, mock_images.NewMockWriter(ctrl), images.Stores{"sim": store})
+			require.NoError(t, err)
+
+			got, err := svc.PublicURL(context.Background(), "1")
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// WithImageValidation rejects uploads whose body does not start with the
+// header of a jpeg, png, gif or webp with ErrInvalidImage, i.e. an archive or log
+// uploaded by accident. Only the header is decoded, not the whole image.
+func WithImageValidation() Option {
+	return func(s *Service) {
+		s.validateImages = true
+	}
+}
+
+// checkImage decodes the header of the body and returns ErrInvalidImage if
+// it is not an image. The returned reader replays the body and must be used
+// in its place.
+func checkImage(body io.Reader, logger *zap.Logger) (io.Reader, error) {
+	var head bytes.Buffer
+	_, format, err := image.DecodeConfig(io.TeeReader(body, &head))
+	if err != nil {
+		logger.Error("unable to decode image header", zap.Error(err))
+		return nil, fmt.Errorf("%w: %s", images.ErrInvalidImage, err)
+	}
+	logger.Debug("decoded image header", zap.String("format", format))
+
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		if _, err := seeker.Seek(int64(-head.Len()), io.SeekCurrent); err != nil {
+			const msg = "unable to seek image"
+			logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+		return body, nil
+	}
+
+	return io.MultiReader(&head, body), nil
+}
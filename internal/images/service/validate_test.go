@@ -0,0 +1,63 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_checkImage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))))
+	img := buf.String()
+
+	for _, tc := range []struct {
+		desc    string
+		content string
+		noSeek  bool
+		wantErr bool
+	}{
+		{
+			desc:    "checkImage() should replay an image which can seek",
+			content: img,
+		},
+		{
+			desc:    "checkImage() should replay an image which can not seek",
+			content: img,
+			noSeek:  true,
+		},
+		{
+			desc:    "checkImage() should return ErrInvalidImage when the body is not an image",
+			content: "PK\x03\x04 not an image",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var body io.Reader = bytes.NewReader([]byte(tc.content))
+			if tc.noSeek {
+				body = ioutil.NopCloser(body)
+			}
+
+			replay, err := checkImage(body, zap.NewNop())
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, images.ErrInvalidImage))
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := ioutil.ReadAll(replay)
+			require.NoError(t, err)
+			assert.Equal(t, tc.content, string(b))
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+// DownloadVariant writes the image scaled down to fit within the request's
+// width and height and encoded in its format, the image's by default, into
+// the request's Writer. A variant the image already has is served from
+// storage, otherwise the image is downloaded, decoded and processed and with
+// the request's Cache the variant is stored next to the image's object and
+// recorded on the image so that the next download of it is served from
+// storage. Returns ErrInvalidResize if the dimensions are out of
+// range, ErrInvalidFormat if the format is unknown, ErrInvalidQuality if
+// the quality is out of range, ErrNoWatermark if a watermark is requested
+// but none is configured and ErrUnsupported if the image is not a jpeg, png,
+// gif or webp or can not be encoded in the format.
+// Variants of encrypted images are never cached as they would be stored
+// unencrypted.
+func (s *Service) DownloadVariant(ctx context.Context, r images.VariantRequest) error {
+	logger := s.logger.With(zap.String("imageId", r.ID), zap.Int("width", r.Width), zap.Int("height", r.Height), zap.String("format", r.Format))
+	logger.Info("attempting to download variant")
+
+	job, err := s.variantJob(ctx, r, logger)
+	if err != nil {
+		return err
+	}
+	logger = logger.With(zap.String("variantKey", job.key))
+
+	cache := r.Cache && job.rec.EncryptedKey == ""
+	if r.Cache && !cache {
+		logger.Warn("image is encrypted, not caching variant")
+	}
+	if s.cachedVariant(ctx, job, r.Writer, logger) {
+		return nil
+	}
+
+	b, bounds, err := s.renderVariant(ctx, job, logger)
+	if err != nil {
+		return err
+	}
+	if cache {
+		if _, err := s.storeVariant(ctx, job, b, bounds, logger); err != nil {
+			logger.Warn("unable to cache variant", zap.Error(err))
+		}
+	}
+
+	if _, err := r.Writer.Write(b); err != nil {
+		const msg = "unable to write variant"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("successfully downloaded variant")
+
+	return nil
+}
+
+// CreateVariant stores the image scaled down to fit within the request's
+// width and height and encoded in its format next to the image's object,
+// i.e. to serve a modern format converted from a legacy original, and
+// records it on the image. A variant the image already has is returned as
+// is. The request's Writer and Cache are not used. Returns the errors of
+// DownloadVariant and ErrUnsupported if the image is encrypted.
+func (s *Service) CreateVariant(ctx context.Context, r images.VariantRequest) (*images.Variant, error) {
+	logger := s.logger.With(zap.String("imageId", r.ID), zap.Int("width", r.Width), zap.Int("height", r.Height), zap.String("format", r.Format))
+	logger.Info("attempting to create variant")
+
+	job, err := s.variantJob(ctx, r, logger)
+	if err != nil {
+		return nil, err
+	}
+	logger = logger.With(zap.String("variantKey", job.key))
+	if job.rec.EncryptedKey != "" {
+		logger.Error("image is encrypted")
+		return nil, fmt.Errorf("%w: variants of encrypted images would be stored unencrypted", images.ErrUnsupported)
+	}
+	if v := recordedVariant(job.rec, job.key); v != nil {
+		logger.Info("variant already exists")
+		return v, nil
+	}
+
+	b, bounds, err := s.renderVariant(ctx, job, logger)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.storeVariant(ctx, job, b, bounds, logger)
+	if err != nil {
+		const msg = "unable to store variant"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("successfully created variant")
+
+	return v, nil
+}
+
+// variantJob is a variant of an image to process.
+type variantJob struct {
+	rec       *images.Record
+	store     images.ObjectStore
+	width     int
+	height    int
+	format    imaging.Format
+	quality   int
+	watermark *imaging.Watermark
+	key       string
+}
+
+// variantJob validates the request and looks up the image it is a variant
+// of.
+func (s *Service) variantJob(ctx context.Context, r images.VariantRequest, logger *zap.Logger) (*variantJob, error) {
+	if err := validVariant(r); err != nil {
+		logger.Error("invalid variant", zap.Error(err))
+		return nil, err
+	}
+	if r.Watermark && s.watermark == nil {
+		logger.Error("no watermark configured")
+		return nil, images.ErrNoWatermark
+	}
+
+	rec, err := s.downloadRecord(ctx, r.ID, logger)
+	if err != nil {
+		return nil, err
+	}
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return nil, err
+	}
+	job, err := s.newVariantJob(rec, store, r)
+	if err != nil {
+		logger.Error("image can not be decoded", zap.String("contentType", rec.ContentType))
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// newVariantJob returns the job of the valid request's variant of the
+// record's image, returning ErrUnsupported if the image can not be decoded.
+func (s *Service) newVariantJob(rec *images.Record, store images.ObjectStore, r images.VariantRequest) (*variantJob, error) {
+	format, ok := imaging.FormatOf(rec.ContentType)
+	if !ok {
+		return nil, fmt.Errorf("%w: images of type (%s) can not be processed", images.ErrUnsupported, rec.ContentType)
+	}
+	if r.Format != "" {
+		format, _ = imaging.ParseFormat(r.Format)
+	}
+	quality := r.Quality
+	if format != imaging.JPEG {
+		quality = 0
+	}
+	var watermark *imaging.Watermark
+	if r.Watermark {
+		watermark = s.watermark
+	}
+
+	return &variantJob{
+		rec:       rec,
+		store:     store,
+		width:     r.Width,
+		height:    r.Height,
+		format:    format,
+		quality:   quality,
+		watermark: watermark,
+		key:       s.keys.variantKey(rec.ID, r.Width, r.Height, format, quality, watermark != nil),
+	}, nil
+}
+
+// validVariant checks the request's dimensions, format and quality.
+func validVariant(r images.VariantRequest) error {
+	switch {
+	case r.Width < 0 || r.Height < 0 || r.Width > images.MaxVariantSize || r.Height > images.MaxVariantSize:
+		return fmt.Errorf("%w: width and height must be at most %d", images.ErrInvalidResize, images.MaxVariantSize)
+	case r.Width+r.Height == 0 && r.Format == "" && r.Quality == 0 && !r.Watermark:
+		return fmt.Errorf("%w: a width, height, format, quality or watermark must be set", images.ErrInvalidResize)
+	case r.Quality < 0 || r.Quality > 100:
+		return images.ErrInvalidQuality
+	case r.Format == "":
+		return nil
+	}
+
+	format, ok := imaging.ParseFormat(r.Format)
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: %s, expected jpeg, png, gif or webp", images.ErrInvalidFormat, r.Format)
+	case !format.Encodable():
+		return fmt.Errorf("%w: images can not be encoded as %s", images.ErrUnsupported, format)
+	}
+
+	return nil
+}
+
+// variantKey returns the key of the image's variant of the dimensions in
+// the format, full when it has the image's size. The quality of a JPEG
+// encoded with other than the default is part of its key, as is whether it
+// is watermarked.
+func (t *KeyTemplate) variantKey(id string, width, height int, format imaging.Format, quality int, watermark bool) string {
+	name := "full"
+	if width+height > 0 {
+		name = fmt.Sprintf("%dx%d", width, height)
+	}
+	if quality != 0 {
+		name += fmt.Sprintf("-q%d", quality)
+	}
+	if watermark {
+		name += "-wm"
+	}
+
+	return fmt.Sprintf("%s%s/variant/%s.%s", t.static, id, name, format.Ext())
+}
+
+// renderVariant downloads and decodes the image, scales it down and encodes
+// it, returning the encoded variant and its bounds.
+func (s *Service) renderVariant(ctx context.Context, job *variantJob, logger *zap.Logger) ([]byte, image.Rectangle, error) {
+	img, err := s.decodeImage(ctx, job.rec, logger)
+	if err != nil {
+		const msg = "unable to decode image"
+		logger.Error(msg, zap.Error(err))
+		return nil, image.Rectangle{}, fmt.Errorf(msg+": %w", err)
+	}
+
+	b, bounds, err := encodeVariant(img, job)
+	if err != nil {
+		const msg = "unable to encode variant"
+		logger.Error(msg, zap.Error(err))
+		return nil, image.Rectangle{}, fmt.Errorf(msg+": %w", err)
+	}
+
+	return b, bounds, nil
+}
+
+// encodeVariant scales the decoded image down, watermarks it and encodes
+// it, returning the encoded variant and its bounds.
+func encodeVariant(img image.Image, job *variantJob) ([]byte, image.Rectangle, error) {
+	variant := imaging.Fit(img, job.width, job.height)
+	if job.watermark != nil {
+		variant = job.watermark.Apply(variant)
+	}
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, variant, job.format, job.quality); err != nil {
+		return nil, image.Rectangle{}, err
+	}
+
+	return buf.Bytes(), variant.Bounds(), nil
+}
+
+// cachedVariant writes the variant of the job recorded on the image into w,
+// returning false when there is none. A cached variant which fails to
+// download is processed again, it is only reported as written once all of
+// it was downloaded.
+func (s *Service) cachedVariant(ctx context.Context, job *variantJob, w io.Writer, logger *zap.Logger) bool {
+	cached := recordedVariant(job.rec, job.key)
+	if cached == nil {
+		return false
+	}
+
+	var buf bytes.Buffer
+	if _, err := job.store.GetRange(ctx, job.key, 0, 0, &buf); err != nil {
+		logger.Warn("unable to download cached variant, processing image", zap.Error(err))
+		return false
+	}
+	if int64(buf.Len()) != cached.SizeInBytes {
+		logger.Warn("cached variant does not match its record, processing image", zap.Int("size", buf.Len()))
+		return false
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		logger.Warn("unable to write cached variant, processing image", zap.Error(err))
+		return false
+	}
+	logger.Info("downloaded cached variant")
+
+	return true
+}
+
+// recordedVariant returns a copy of the record's variant under the key, nil
+// when it has none.
+func recordedVariant(rec *images.Record, key string) *images.Variant {
+	for i := range rec.Variants {
+		if rec.Variants[i].Key == key {
+			v := rec.Variants[i]
+			return &v
+		}
+	}
+
+	return nil
+}
+
+// storeVariant stores the encoded variant under the job's key and records
+// it on the image. An object which was stored without being recorded is
+// left behind for GC.
+func (s *Service) storeVariant(ctx context.Context, job *variantJob, b []byte, bounds image.Rectangle, logger *zap.Logger) (*images.Variant, error) {
+	contentType := job.format.ContentType()
+	if err := job.store.Put(ctx, job.key, bytes.NewReader(b), s.derivedOptions(job.rec, contentType)); err != nil {
+		return nil, err
+	}
+
+	variant := images.Variant{
+		Key:         job.key,
+		ContentType: contentType,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		SizeInBytes: int64(len(b)),
+	}
+	variants := make([]images.Variant, 0, len(job.rec.Variants)+1)
+	for _, v := range job.rec.Variants {
+		if v.Key != job.key {
+			variants = append(variants, v)
+		}
+	}
+	job.rec.Variants = append(variants, variant)
+	if err := s.writer.Update(ctx, job.rec); err != nil {
+		return nil, err
+	}
+	logger.Info("stored variant")
+
+	return &variant, nil
+}
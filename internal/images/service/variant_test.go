@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_DownloadVariant(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 4))))
+	body := buf.Bytes()
+
+	existing := images.Record{
+		ID:          "1",
+		Key:         "images/1/a.png",
+		Name:        "a.png",
+		ContentType: "image/png",
+		SizeInBytes: int64(len(body)),
+		Storage:     "sim",
+	}
+	const variantKey = "images/1/variant/4x0.png"
+	expectDownload := func(s *mock_images.MockObjectStore) {
+		s.EXPECT().GetRange(gomock.Any(), existing.Key, int64(0), int64(0), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+				n, err := w.Write(body)
+				return int64(n), err
+			})
+	}
+	for _, tc := range []struct {
+		desc       string
+		req        images.VariantRequest
+		rec        images.Record
+		mocks      func(w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+		wantSize   image.Point
+		wantFormat string
+		wantBytes  []byte
+		wantErr    error
+	}{
+		{
+			desc:     "DownloadVariant() should write the image scaled down to fit the dimensions",
+			req:      images.VariantRequest{Width: 4},
+			rec:      existing,
+			mocks:    func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) { expectDownload(s) },
+			wantSize: image.Pt(4, 2),
+		},
+		{
+			desc:       "DownloadVariant() should convert the image to the format",
+			req:        images.VariantRequest{Format: "jpg"},
+			rec:        existing,
+			mocks:      func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) { expectDownload(s) },
+			wantSize:   image.Pt(8, 4),
+			wantFormat: "jpeg",
+		},
+		{
+			desc: "DownloadVariant() should cache the variant and record it on the image",
+			req:  images.VariantRequest{Width: 4, Cache: true},
+			rec:  existing,
+			mocks: func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				expectDownload(s)
+				s.EXPECT().Put(gomock.Any(), variantKey, gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _ io.Reader, opts images.PutOptions) error {
+						assert.Equal(t, "image/png", opts.ContentType)
+						return nil
+					})
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, rec *images.Record) error {
+						require.Len(t, rec.Variants, 1)
+						assert.Equal(t, variantKey, rec.Variants[0].Key)
+						assert.Equal(t, 4, rec.Variants[0].Width)
+						assert.Equal(t, 2, rec.Variants[0].Height)
+						return nil
+					})
+			},
+			wantSize: image.Pt(4, 2),
+		},
+		{
+			desc: "DownloadVariant() should write the cached variant without resizing the image",
+			req:  images.VariantRequest{Width: 4, Cache: true},
+			rec: func() images.Record {
+				rec := existing
+				rec.Variants = []images.Variant{{Key: variantKey, SizeInBytes: 6}}
+				return rec
+			}(),
+			mocks: func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				s.EXPECT().GetRange(gomock.Any(), variantKey, int64(0), int64(0), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+						n, err := w.Write([]byte("cached"))
+						return int64(n), err
+					})
+			},
+			wantBytes: []byte("cached"),
+		},
+		{
+			desc:    "DownloadVariant() should return ErrInvalidResize when no dimension is set",
+			rec:     existing,
+			wantErr: images.ErrInvalidResize,
+		},
+		{
+			desc:    "DownloadVariant() should return ErrInvalidResize when a dimension is too large",
+			req:     images.VariantRequest{Width: images.MaxVariantSize + 1},
+			rec:     existing,
+			wantErr: images.ErrInvalidResize,
+		},
+		{
+			desc: "DownloadVariant() should return ErrUnsupported when the image can not be decoded",
+			req:  images.VariantRequest{Width: 4},
+			rec: func() images.Record {
+				rec := existing
+				rec.ContentType = "image/tiff"
+				return rec
+			}(),
+			wantErr: images.ErrUnsupported,
+		},
+		{
+			desc:    "DownloadVariant() should return ErrUnsupported when the format can not be encoded",
+			req:     images.VariantRequest{Format: "avif"},
+			rec:     existing,
+			wantErr: images.ErrUnsupported,
+		},
+		{
+			desc:    "DownloadVariant() should return ErrInvalidFormat when the format is unknown",
+			req:     images.VariantRequest{Format: "bmp"},
+			rec:     existing,
+			wantErr: images.ErrInvalidFormat,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			rec := tc.rec
+			r.EXPECT().Get(gomock.Any(), existing.ID).Return(&rec, nil).AnyTimes()
+			if tc.mocks != nil {
+				tc.mocks(w, s)
+			}
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			tc.req.ID = existing.ID
+			tc.req.Writer = &out
+			err = svc.DownloadVariant(context.Background(), tc.req)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.wantBytes != nil {
+				assert.Equal(t, tc.wantBytes, out.Bytes())
+				return
+			}
+			img, format, err := image.Decode(&out)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSize, img.Bounds().Size())
+			if tc.wantFormat == "" {
+				tc.wantFormat = "png"
+			}
+			assert.Equal(t, tc.wantFormat, format)
+		})
+	}
+}
+
+func Test_Service_CreateVariant(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 4))))
+	body := buf.Bytes()
+
+	existing := images.Record{
+		ID:          "1",
+		Key:         "images/1/a.png",
+		Name:        "a.png",
+		ContentType: "image/png",
+		SizeInBytes: int64(len(body)),
+		Storage:     "sim",
+	}
+	const variantKey = "images/1/variant/full.jpg"
+	for _, tc := range []struct {
+		desc    string
+		rec     images.Record
+		mocks   func(w *mock_images.MockWriter, s *mock_images.MockObjectStore)
+		want    *images.Variant
+		wantErr error
+	}{
+		{
+			desc: "CreateVariant() should store and record the converted image",
+			rec:  existing,
+			mocks: func(w *mock_images.MockWriter, s *mock_images.MockObjectStore) {
+				s.EXPECT().GetRange(gomock.Any(), existing.Key, int64(0), int64(0), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+						n, err := w.Write(body)
+						return int64(n), err
+					})
+				s.EXPECT().Put(gomock.Any(), variantKey, gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _ io.Reader, opts images.PutOptions) error {
+						assert.Equal(t, "image/jpeg", opts.ContentType)
+						return nil
+					})
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want: &images.Variant{Key: variantKey, ContentType: "image/jpeg", Width: 8, Height: 4},
+		},
+		{
+			desc: "CreateVariant() should return the variant the image already has",
+			rec: func() images.Record {
+				rec := existing
+				rec.Variants = []images.Variant{{Key: variantKey, ContentType: "image/jpeg"}}
+				return rec
+			}(),
+			want: &images.Variant{Key: variantKey, ContentType: "image/jpeg"},
+		},
+		{
+			desc: "CreateVariant() should return ErrUnsupported when the image is encrypted",
+			rec: func() images.Record {
+				rec := existing
+				rec.EncryptedKey = "key"
+				return rec
+			}(),
+			wantErr: images.ErrUnsupported,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			rec := tc.rec
+			r.EXPECT().Get(gomock.Any(), existing.ID).Return(&rec, nil)
+			if tc.mocks != nil {
+				tc.mocks(w, s)
+			}
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithEncryption(make([]byte, 32)))
+			require.NoError(t, err)
+
+			got, err := svc.CreateVariant(context.Background(), images.VariantRequest{ID: existing.ID, Format: "jpeg"})
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+			// the size of the encoding is not asserted
+			got.SizeInBytes = 0
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
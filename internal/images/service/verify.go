@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// Verify downloads the image's object and compares its size, MD5 and SHA-256
+// with the size, ETag and SHA-256 on the record, the SHA-256 of a compressed
+// or encrypted object is of its decoded content. Checks which can not be made, i.e. the
+// ETag of a multipart upload is not an MD5, are skipped. A failed check is
+// reported in the result rather than as an error.
+func (s *Service) Verify(ctx context.Context, id string) (*images.VerifyResult, error) {
+	logger := s.logger.With(zap.String("imageId", id))
+	logger.Info("attempting to verify image")
+
+	rec, err := s.reader.Get(ctx, id)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		logger.Error("record not found", zap.Error(err))
+		return nil, err
+	default:
+		const msg = "unable to retrieve image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	store, err := s.store(rec.Storage, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile("", "sim-verify-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := store.Get(ctx, rec.Key, f); err != nil {
+		if err == images.ErrObjectNotFound {
+			logger.Error("object not found", zap.Error(err))
+			return nil, err
+		}
+		const msg = "unable to download object"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	// hash the object in a single pass
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		const msg = "unable to seek temp file"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	w := io.MultiWriter(md5Hash, sha256Hash)
+	if encoded(rec) {
+		w = md5Hash
+	}
+	size, err := io.Copy(w, f)
+	if err != nil {
+		const msg = "unable to checksum object"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	// the sha256 of an encoded object is of its decoded content
+	var undecryptable bool
+	if encoded(rec) {
+		content, err := s.decode(rec, io.NewSectionReader(f, 0, size))
+		if err == nil {
+			_, err = io.Copy(sha256Hash, content)
+		}
+		switch {
+		case errors.Is(err, images.ErrIntegrity):
+			undecryptable = true
+		case err != nil:
+			const msg = "unable to checksum decoded object"
+			logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+	}
+	sha256Check := verifySHA256(rec.SHA256, hex.EncodeToString(sha256Hash.Sum(nil)))
+	if undecryptable {
+		sha256Check = images.Check{Name: "sha256", Status: images.CheckFail, Expected: rec.SHA256, Reason: "object does not decrypt"}
+	}
+
+	res := images.VerifyResult{
+		ImageID: rec.ID,
+		Checks: []images.Check{
+			compare("size", strconv.FormatInt(rec.SizeInBytes, 10), strconv.FormatInt(size, 10)),
+			verifyETag(rec.ETag, rec.ServerSideEncryption, hex.EncodeToString(md5Hash.Sum(nil))),
+			sha256Check,
+		},
+		OK: true,
+	}
+	for _, c := range res.Checks {
+		if c.Status == images.CheckFail {
+			res.OK = false
+		}
+	}
+	logger.Info("successfully verified image", zap.Bool("ok", res.OK))
+
+	return &res, nil
+}
+
+func verifyETag(etag, sse, sum string) images.Check {
+	trimmed, ok := md5ETag(etag, sse)
+	switch {
+	case etag == "":
+		return images.Check{Name: "etag", Status: images.CheckSkipped, Actual: sum, Reason: "no etag recorded"}
+	case !ok:
+		return images.Check{Name: "etag", Status: images.CheckSkipped, Expected: etag, Reason: "etag is not an md5, i.e. a multipart or SSE-KMS upload"}
+	}
+
+	return compare("etag", trimmed, sum)
+}
+
+func verifySHA256(recorded, sum string) images.Check {
+	if recorded == "" {
+		return images.Check{Name: "sha256", Status: images.CheckSkipped, Actual: sum, Reason: "no sha256 recorded"}
+	}
+
+	return compare("sha256", strings.ToLower(recorded), sum)
+}
+
+func compare(name, expected, actual string) images.Check {
+	c := images.Check{Name: name, Status: images.CheckPass, Expected: expected, Actual: actual}
+	if expected != actual {
+		c.Status = images.CheckFail
+	}
+
+	return c
+}
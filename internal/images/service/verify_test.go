@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_Service_Verify(t *testing.T) {
+	md5Sum, sha256Sum := md5.Sum([]byte("hw")), sha256.Sum256([]byte("hw"))
+	etag, sum := hex.EncodeToString(md5Sum[:]), hex.EncodeToString(sha256Sum[:])
+	get := func(s *mock_images.MockObjectStore) {
+		s.
+			EXPECT().
+			Get(gomock.Any(), "key", gomock.Any()).
+			DoAndReturn(func(_ context.Context, key string, stream io.WriterAt) (int64, error) {
+				n, err := stream.WriteAt([]byte("hw"), 0)
+				return int64(n), err
+			})
+	}
+	for _, tc := range []struct {
+		desc    string
+		rec     *images.Record
+		mocks   func(s *mock_images.MockObjectStore)
+		want    []images.CheckStatus
+		wantOK  bool
+		wantErr error
+	}{
+		{
+			desc: "Verify() should return ErrObjectNotFound when the object does not exist",
+			rec:  &images.Record{ID: "1", Key: "key", Storage: "sim"},
+			mocks: func(s *mock_images.MockObjectStore) {
+				s.EXPECT().Get(gomock.Any(), "key", gomock.Any()).Return(int64(0), images.ErrObjectNotFound)
+			},
+			wantErr: images.ErrObjectNotFound,
+		},
+		{
+			desc:   "Verify() should pass when every checksum matches",
+			rec:    &images.Record{ID: "1", Key: "key", Storage: "sim", SizeInBytes: 2, ETag: `"` + etag + `"`, SHA256: sum},
+			mocks:  get,
+			want:   []images.CheckStatus{images.CheckPass, images.CheckPass, images.CheckPass},
+			wantOK: true,
+		},
+		{
+			desc:   "Verify() should skip the checksums which can not be compared",
+			rec:    &images.Record{ID: "1", Key: "key", Storage: "sim", SizeInBytes: 2, ETag: `"abc-2"`},
+			mocks:  get,
+			want:   []images.CheckStatus{images.CheckPass, images.CheckSkipped, images.CheckSkipped},
+			wantOK: true,
+		},
+		{
+			desc:   "Verify() should skip the etag of an object encrypted with SSE-KMS",
+			rec:    &images.Record{ID: "1", Key: "key", Storage: "sim", SizeInBytes: 2, ETag: sum[:32], SHA256: sum, ServerSideEncryption: "aws:kms"},
+			mocks:  get,
+			want:   []images.CheckStatus{images.CheckPass, images.CheckSkipped, images.CheckPass},
+			wantOK: true,
+		},
+		{
+			desc:  "Verify() should fail when a checksum does not match",
+			rec:   &images.Record{ID: "1", Key: "key", Storage: "sim", SizeInBytes: 2, ETag: etag, SHA256: "other"},
+			mocks: get,
+			want:  []images.CheckStatus{images.CheckPass, images.CheckPass, images.CheckFail},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, s := mock_images.NewMockReader(ctrl), mock_images.NewMockObjectStore(ctrl)
+			r.EXPECT().Get(gomock.Any(), "1").Return(tc.rec, nil)
+			tc.mocks(s)
+			svc, err := New(zap.NewNop(), "sim", r, mock_images.NewMockWriter(ctrl), images.Stores{"sim": s})
+			require.NoError(t, err)
+
+			got, err := svc.Verify(context.Background(), "1")
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr))
+				return
+			}
+			require.NoError(t, err)
+			var statuses []images.CheckStatus
+			for _, c := range got.Checks {
+				statuses = append(statuses, c.Status)
+			}
+			assert.Equal(t, tc.want, statuses)
+			assert.Equal(t, tc.wantOK, got.OK)
+		})
+	}
+}
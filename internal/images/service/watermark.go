@@ -0,0 +1,13 @@
+package service
+
+import "github.com/itsHabib/sim/internal/imaging"
+
+// WithWatermark configures the watermark overlaid on the variants which
+// request it, i.e. the variants of a pipeline with watermark set. The
+// watermark is not part of the keys of the variants, variants stored before
+// it was changed keep the previous watermark.
+func WithWatermark(w imaging.Watermark) Option {
+	return func(s *Service) {
+		s.watermark = &w
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+func Test_Service_DownloadVariant_Watermark(t *testing.T) {
+	// a black image and a white mark covering all of it
+	src := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	draw.Draw(src, src.Rect, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+	body := buf.Bytes()
+	mark := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	draw.Draw(mark, mark.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+	watermark := imaging.Watermark{Mark: mark, Position: imaging.Center, Opacity: 1, Scale: 1}
+
+	existing := images.Record{
+		ID:          "1",
+		Key:         "images/1/a.png",
+		Name:        "a.png",
+		ContentType: "image/png",
+		SizeInBytes: int64(len(body)),
+		Storage:     "sim",
+	}
+	for _, tc := range []struct {
+		desc    string
+		opts    []Option
+		wantErr error
+	}{
+		{
+			desc: "DownloadVariant() should overlay the watermark and cache the variant under its own key",
+			opts: []Option{WithWatermark(watermark)},
+		},
+		{
+			desc:    "DownloadVariant() should return ErrNoWatermark when no watermark is configured",
+			wantErr: images.ErrNoWatermark,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+			rec := existing
+			r.EXPECT().Get(gomock.Any(), existing.ID).Return(&rec, nil).AnyTimes()
+			if tc.wantErr == nil {
+				s.EXPECT().GetRange(gomock.Any(), existing.Key, int64(0), int64(0), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, _, _ int64, w io.Writer) (int64, error) {
+						n, err := w.Write(body)
+						return int64(n), err
+					})
+				s.EXPECT().Put(gomock.Any(), "images/1/variant/4x0-wm.png", gomock.Any(), gomock.Any()).Return(nil)
+				w.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+			}
+			svc, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, tc.opts...)
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			err = svc.DownloadVariant(context.Background(), images.VariantRequest{
+				ID:        existing.ID,
+				Width:     4,
+				Watermark: true,
+				Writer:    &out,
+				Cache:     true,
+			})
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+			img, err := png.Decode(&out)
+			require.NoError(t, err)
+			assert.Equal(t, image.Pt(4, 2), img.Bounds().Size())
+			assert.Equal(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBAModel.Convert(img.At(2, 1)))
+		})
+	}
+}
+
+func Test_New_Watermark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	r, w, s := mock_images.NewMockReader(ctrl), mock_images.NewMockWriter(ctrl), mock_images.NewMockObjectStore(ctrl)
+
+	_, err := New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithWatermark(imaging.Watermark{Position: imaging.Tile}))
+	assert.Error(t, err)
+
+	// a pipeline applying the watermark requires one
+	_, err = New(zap.NewNop(), "sim", r, w, images.Stores{"sim": s}, WithPipelines(map[string]images.Pipeline{
+		"proof": {Width: 800, Watermark: true},
+	}))
+	assert.Error(t, err)
+}
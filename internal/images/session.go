@@ -0,0 +1,90 @@
+package images
+
+import "io"
+
+const (
+	// MinPartSize is the size of the parts of a resumable upload, larger
+	// bodies use larger parts to stay within MaxParts.
+	MinPartSize int64 = 5 << 20
+
+	// MaxParts is the most parts a resumable upload can have.
+	MaxParts int64 = 10000
+)
+
+// UploadSession is the state of a resumable upload. It is persisted between
+// attempts so that an interrupted upload only sends the parts it is missing.
+type UploadSession struct {
+	// ImageID is the ID generated for the image by the first attempt
+	ImageID string `json:"imageId"`
+
+	// Storage and Key are where the object is uploaded to
+	Storage string `json:"storage"`
+	Key     string `json:"key"`
+
+	// SHA256 and Size identify the body, a body which no longer matches them
+	// can not be resumed
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+
+	// UploadID identifies the upload in storage, it is empty until the
+	// upload was started
+	UploadID string `json:"uploadId,omitempty"`
+
+	// PartSize is the size of every part but the last
+	PartSize int64 `json:"partSize,omitempty"`
+
+	// Parts are the parts uploaded so far
+	Parts []Part `json:"parts,omitempty"`
+}
+
+// Part represents an uploaded part of a resumable upload.
+type Part struct {
+	// Number of the part, from 1
+	Number int32 `json:"number"`
+
+	// ETag the storage returned for the part
+	ETag string `json:"etag"`
+}
+
+// Start records the upload started in storage, resetting the parts of any
+// previous upload.
+func (u *UploadSession) Start(uploadID string) {
+	u.UploadID = uploadID
+	u.PartSize = MinPartSize
+	if min := (u.Size + MaxParts - 1) / MaxParts; min > u.PartSize {
+		u.PartSize = min
+	}
+	u.Parts = nil
+}
+
+// PartCount returns the number of parts the body is uploaded in, an empty
+// body is uploaded as one empty part.
+func (u *UploadSession) PartCount() int32 {
+	if u.Size == 0 {
+		return 1
+	}
+
+	return int32((u.Size + u.PartSize - 1) / u.PartSize)
+}
+
+// Section returns the section of the body uploaded as the part number.
+func (u *UploadSession) Section(body io.ReaderAt, number int32) *io.SectionReader {
+	off := int64(number-1) * u.PartSize
+	n := u.PartSize
+	if off+n > u.Size {
+		n = u.Size - off
+	}
+
+	return io.NewSectionReader(body, off, n)
+}
+
+// Uploaded reports whether the part number was uploaded.
+func (u *UploadSession) Uploaded(number int32) bool {
+	for _, p := range u.Parts {
+		if p.Number == number {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package images
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSize is returned when a size can not be parsed.
+const ErrInvalidSize Error = "invalid size"
+
+// Sizes are stored in bytes, the units are binary multiples of a byte.
+const (
+	KB int64 = 1 << 10
+	MB int64 = 1 << 20
+	GB int64 = 1 << 30
+	TB int64 = 1 << 40
+)
+
+// sizeUnits are the multipliers of the size suffixes, longest first so a
+// suffix is not mistaken for a shorter one.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{suffix: "TB", bytes: TB},
+	{suffix: "GB", bytes: GB},
+	{suffix: "MB", bytes: MB},
+	{suffix: "KB", bytes: KB},
+	{suffix: "B", bytes: 1},
+}
+
+// ParseSize returns the bytes of a size with an optional unit, i.e. 512,
+// 10KB or 1.5MB. Units are case insensitive, fractions are rounded up to the
+// next byte. Sizes which do not fit in an int64 are invalid.
+func ParseSize(value string) (int64, error) {
+	number, multiplier := strings.ToUpper(strings.TrimSpace(value)), int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("%w %q", ErrInvalidSize, value)
+	}
+
+	// float64(math.MaxInt64) rounds up to 2^63, which already overflows
+	bytes := math.Ceil(n * float64(multiplier))
+	if bytes >= float64(math.MaxInt64) {
+		return 0, fmt.Errorf("%w %q", ErrInvalidSize, value)
+	}
+
+	return int64(bytes), nil
+}
+
+// FormatSize renders the bytes in the largest unit they make at least one
+// of, with up to one decimal, i.e. 512 B, 10 KB or 1.5 MB.
+func FormatSize(n int64) string {
+	for _, unit := range sizeUnits {
+		if n >= unit.bytes && unit.bytes > 1 {
+			s := strconv.FormatFloat(float64(n)/float64(unit.bytes), 'f', 1, 64)
+			return strings.TrimSuffix(s, ".0") + " " + unit.suffix
+		}
+	}
+
+	return strconv.FormatInt(n, 10) + " B"
+}
@@ -0,0 +1,107 @@
+package images
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{
+			desc:  "ParseSize() should treat a bare number as bytes",
+			value: "512",
+			want:  512,
+		},
+		{
+			desc:  "ParseSize() should apply the unit regardless of case",
+			value: "10kb",
+			want:  10 * KB,
+		},
+		{
+			desc:  "ParseSize() should round fractions up to the next byte",
+			value: "1.5MB",
+			want:  MB + MB/2,
+		},
+		{
+			desc:    "ParseSize() should reject negative sizes",
+			value:   "-1KB",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseSize() should reject unknown units",
+			value:   "1PB",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseSize() should reject sizes which overflow an int64",
+			value:   "99999999TB",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseSize() should reject sizes which overflow an int64 without a unit",
+			value:   "1e19",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseSize() should reject infinite sizes",
+			value:   "Inf",
+			wantErr: true,
+		},
+		{
+			desc:    "ParseSize() should reject sizes which are not a number",
+			value:   "NaN",
+			wantErr: true,
+		},
+		{
+			desc:  "ParseSize() should accept the largest sizes which fit in an int64",
+			value: "8388607TB",
+			want:  8388607 * TB,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseSize(tc.value)
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidSize))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_FormatSize(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		n    int64
+		want string
+	}{
+		{
+			desc: "FormatSize() should render small sizes in bytes",
+			n:    512,
+			want: "512 B",
+		},
+		{
+			desc: "FormatSize() should drop a zero decimal",
+			n:    10 * KB,
+			want: "10 KB",
+		},
+		{
+			desc: "FormatSize() should render the largest unit with one decimal",
+			n:    MB + MB/2,
+			want: "1.5 MB",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, FormatSize(tc.n))
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package images
+
+import "time"
+
+// DefaultSrcsetWidths are the widths of a standard set of responsive sizes.
+var DefaultSrcsetWidths = []int{320, 640, 1280, 1920}
+
+// SrcsetRequest represents the type used to request the responsive sizes
+// of an image, i.e. for the srcset of an HTML img.
+type SrcsetRequest struct {
+	// ID of the image.
+	ID string
+
+	// Widths the image is scaled down to, DefaultSrcsetWidths when empty.
+	// Images are never scaled up, the widths larger than the image share
+	// a variant of the image's width.
+	Widths []int
+
+	// Format the variants are encoded in, the image's format when empty.
+	Format string
+
+	// Public returns the stable URLs of the variants of a public image,
+	// otherwise the URLs are presigned for Expires.
+	Public  bool
+	Expires time.Duration
+}
+
+// SrcsetCandidate is one of the responsive sizes of an image.
+type SrcsetCandidate struct {
+	// Width of the variant in pixels, its width descriptor
+	Width int `json:"width"`
+
+	// URL of the variant
+	URL string `json:"url"`
+
+	// Variant is the stored variant
+	Variant Variant `json:"variant"`
+}
@@ -0,0 +1,48 @@
+package images
+
+import "io"
+
+// OffsetWriter adapts an io.WriterAt into a sequential io.Writer, tracking the
+// offset of the next write. Useful for object stores whose SDKs only provide
+// sequential readers for downloads.
+type OffsetWriter struct {
+	stream io.WriterAt
+	offset int64
+}
+
+// NewOffsetWriter returns an OffsetWriter which starts writing to the stream
+// at offset 0.
+func NewOffsetWriter(stream io.WriterAt) *OffsetWriter {
+	return &OffsetWriter{stream: stream}
+}
+
+// Write writes p to the underlying stream at the current offset.
+func (w *OffsetWriter) Write(p []byte) (int, error) {
+	n, err := w.stream.WriteAt(p, w.offset)
+	w.offset += int64(n)
+
+	return n, err
+}
+
+// CopyRange copies length bytes of r starting at offset into w, the rest of r
+// when length is 0. size is the size of r, ErrInvalidRange is returned if the
+// offset is not before it.
+func CopyRange(w io.Writer, r io.ReadSeeker, size, offset, length int64) (int64, error) {
+	if offset < 0 || length < 0 || offset >= size {
+		return 0, ErrInvalidRange
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if length == 0 {
+		return io.Copy(w, r)
+	}
+
+	n, err := io.CopyN(w, r, length)
+	if err == io.EOF {
+		// the range ends past the end of r, which is the end of the range
+		err = nil
+	}
+
+	return n, err
+}
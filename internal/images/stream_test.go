@@ -0,0 +1,60 @@
+package images
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CopyRange(t *testing.T) {
+	const body = "Hello, World!"
+	for _, tc := range []struct {
+		desc    string
+		offset  int64
+		length  int64
+		want    string
+		wantErr error
+	}{
+		{
+			desc:   "CopyRange() should copy the range",
+			offset: 7,
+			length: 5,
+			want:   "World",
+		},
+		{
+			desc:   "CopyRange() should copy the rest of the reader when the length is 0",
+			offset: 7,
+			want:   "World!",
+		},
+		{
+			desc:   "CopyRange() should stop at the end of the reader",
+			offset: 7,
+			length: 100,
+			want:   "World!",
+		},
+		{
+			desc:    "CopyRange() should return ErrInvalidRange when the offset is past the reader",
+			offset:  int64(len(body)),
+			wantErr: ErrInvalidRange,
+		},
+		{
+			desc:    "CopyRange() should return ErrInvalidRange for a negative length",
+			length:  -1,
+			wantErr: ErrInvalidRange,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := CopyRange(&buf, strings.NewReader(body), int64(len(body)), tc.offset, tc.length)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(tc.want)), n)
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+}
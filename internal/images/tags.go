@@ -0,0 +1,97 @@
+package images
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidTag is wrapped by the errors NormalizeTags returns for tags
+// which can not be stored.
+const ErrInvalidTag Error = "invalid tag"
+
+// NormalizeTags returns the tags trimmed of surrounding whitespace with
+// duplicates removed, in the order given. Returns an error wrapping
+// ErrInvalidTag if a tag is empty or contains whitespace or a comma.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || strings.IndexFunc(tag, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) >= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized, nil
+}
+
+// HasTag reports whether the record has the tag.
+func HasTag(rec *Record, tag string) bool {
+	for i := range rec.Tags {
+		if rec.Tags[i] == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddTags adds the tags the record does not already have. Reports whether
+// the record changed.
+func AddTags(rec *Record, tags []string) bool {
+	changed := false
+	for _, tag := range tags {
+		if HasTag(rec, tag) {
+			continue
+		}
+		rec.Tags = append(rec.Tags, tag)
+		changed = true
+	}
+
+	return changed
+}
+
+// RemoveTags removes the tags from the record. Reports whether the record
+// changed.
+func RemoveTags(rec *Record, tags []string) bool {
+	remove := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		remove[tag] = true
+	}
+
+	kept := rec.Tags[:0]
+	for _, tag := range rec.Tags {
+		if !remove[tag] {
+			kept = append(kept, tag)
+		}
+	}
+	changed := len(kept) != len(rec.Tags)
+	rec.Tags = kept
+	if len(rec.Tags) == 0 {
+		rec.Tags = nil
+	}
+
+	return changed
+}
+
+// ObjectTags maps the image's tags to the key value tags of an object, i.e.
+// S3 object tags. A tag of the form key=value is split at its first =, any
+// other tag becomes a key with an empty value.
+func ObjectTags(tags []string) map[string]string {
+	objectTags := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key, value := tag, ""
+		if i := strings.Index(tag, "="); i > 0 {
+			key, value = tag[:i], tag[i+1:]
+		}
+		objectTags[key] = value
+	}
+
+	return objectTags
+}
@@ -0,0 +1,64 @@
+package images
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NormalizeTags(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc: "NormalizeTags() should trim and dedupe the tags in order",
+			tags: []string{" vacation", "beach", "vacation "},
+			want: []string{"vacation", "beach"},
+		},
+		{
+			desc:    "NormalizeTags() should reject empty tags",
+			tags:    []string{" "},
+			wantErr: true,
+		},
+		{
+			desc:    "NormalizeTags() should reject tags containing whitespace",
+			tags:    []string{"summer vacation"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := NormalizeTags(tc.tags)
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidTag))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_AddTags_RemoveTags(t *testing.T) {
+	rec := Record{Tags: []string{"vacation"}}
+
+	assert.False(t, AddTags(&rec, []string{"vacation"}))
+	assert.True(t, AddTags(&rec, []string{"vacation", "beach"}))
+	assert.Equal(t, []string{"vacation", "beach"}, rec.Tags)
+
+	assert.False(t, RemoveTags(&rec, []string{"missing"}))
+	assert.True(t, RemoveTags(&rec, []string{"vacation", "beach"}))
+	assert.Nil(t, rec.Tags)
+}
+
+func Test_ObjectTags(t *testing.T) {
+	assert.Equal(
+		t,
+		map[string]string{"vacation": "", "cost-center": "eng", "expr": "a=b"},
+		ObjectTags([]string{"vacation", "cost-center=eng", "expr=a=b"}),
+	)
+}
@@ -0,0 +1,19 @@
+package images
+
+// Thumbnail is a scaled down JPEG copy of an image, i.e. for a preview in a
+// listing, stored in the image's storage next to its object.
+type Thumbnail struct {
+	// Size is the length the longest side of the image was scaled down to,
+	// the thumbnail of an image which is already smaller has its size.
+	Size int `json:"size"`
+
+	// Key of the thumbnail's object
+	Key string `json:"key"`
+
+	// Width and Height are the dimensions of the thumbnail in pixels
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// SizeInBytes is the size of the thumbnail's object in bytes
+	SizeInBytes int64 `json:"sizeInBytes"`
+}
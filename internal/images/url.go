@@ -0,0 +1,52 @@
+package images
+
+import "time"
+
+// MaxURLExpiry is the longest a presigned URL can be valid for, the limit of
+// S3's signature version 4 signed URLs.
+const MaxURLExpiry = 7 * 24 * time.Hour
+
+// PresignedRequest is a request clients make directly against storage with
+// the access granted by its signature, i.e. a browser uploading an image.
+type PresignedRequest struct {
+	// Method and URL of the request
+	Method string `json:"method"`
+	URL    string `json:"url"`
+
+	// Headers are the headers which were signed, the request must be sent
+	// with them as they are
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// PresignUploadRequest represents the type used to request a presigned
+// upload, see PresignedUpload.
+type PresignUploadRequest struct {
+	// Name of the image, it must be unique
+	Name string
+
+	// ContentType is the MIME type of the image, the upload must be sent
+	// with it
+	ContentType string
+
+	// Tags of the image
+	Tags []string
+
+	// Metadata of the image
+	Metadata map[string]string
+
+	// Expires is how long the upload can be made for, at most MaxURLExpiry
+	Expires time.Duration
+}
+
+// PresignedUpload is an upload a client makes directly to storage. The image
+// record is pending until the upload is confirmed.
+type PresignedUpload struct {
+	// ImageID is the ID of the pending image, used to confirm the upload
+	ImageID string `json:"imageId"`
+
+	// Request uploads the image
+	Request PresignedRequest `json:"request"`
+
+	// ExpiresAt is when the request stops being accepted
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,71 @@
+package images
+
+import "io"
+
+// MaxVariantSize is the largest width or height, in pixels, an image can be
+// resized to.
+const MaxVariantSize = 10000
+
+// Variant is a resized copy of an image cached in the image's storage next
+// to its object, so that downloading the same size again does not process
+// the image again.
+type Variant struct {
+	// Key of the variant's object
+	Key string `json:"key"`
+
+	// ContentType of the variant's object
+	ContentType string `json:"contentType"`
+
+	// Width and Height are the dimensions of the variant in pixels
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// SizeInBytes is the size of the variant's object in bytes
+	SizeInBytes int64 `json:"sizeInBytes"`
+}
+
+// VariantRequest represents the type used to request a download of a
+// resized copy of an image.
+type VariantRequest struct {
+	// ID of the image.
+	ID string
+
+	// Width and Height are the box the image is scaled down to fit in,
+	// keeping its aspect ratio. A zero side is unbounded, the image keeps
+	// its size when both are. Images are never scaled up.
+	Width  int
+	Height int
+
+	// Format the variant is encoded in, i.e. png, the image's format when
+	// empty. At least one of the dimensions, the format, the quality or
+	// the watermark must be set.
+	Format string
+
+	// Quality of a JPEG variant from 1 to 100, a default quality when 0.
+	Quality int
+
+	// Watermark overlays the configured watermark on the variant.
+	Watermark bool
+
+	// Writer is written the variant.
+	Writer io.Writer
+
+	// Cache stores the variant in the image's storage and records it on
+	// the image, a cached variant is downloaded instead of resizing the
+	// image again.
+	Cache bool
+}
+
+// DerivedKeys returns the keys of the objects derived from the record's,
+// its thumbnails and variants, which belong to the record alone.
+func DerivedKeys(rec *Record) []string {
+	keys := make([]string, 0, len(rec.Thumbnails)+len(rec.Variants))
+	for i := range rec.Thumbnails {
+		keys = append(keys, rec.Thumbnails[i].Key)
+	}
+	for i := range rec.Variants {
+		keys = append(keys, rec.Variants[i].Key)
+	}
+
+	return keys
+}
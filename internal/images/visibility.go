@@ -0,0 +1,21 @@
+package images
+
+const (
+	// VisibilityPrivate images can only be read through the service or a
+	// presigned URL.
+	VisibilityPrivate = "private"
+
+	// VisibilityPublic images can be read by anyone through the URL of their
+	// object.
+	VisibilityPublic = "public"
+)
+
+// Visibility returns the visibility of the record's object. Records uploaded
+// before it was recorded are private.
+func Visibility(rec *Record) string {
+	if rec.Visibility == "" {
+		return VisibilityPrivate
+	}
+
+	return rec.Visibility
+}
@@ -2,6 +2,8 @@
 package writer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -14,15 +16,20 @@ import (
 
 const (
 	loggerName = "images.writer"
-	dbTimeout  = time.Second * 3
+
+	// defaultReadyTimeout is how long to wait for the bucket to be ready
+	defaultReadyTimeout = time.Second * 3
 )
 
-// Service provides the implementation to write image records to a dynamodb
-// table.
+// Service provides the implementation to write image records to a couchbase
+// collection.
 type Service struct {
-	collection *gocb.Collection
-	logger     *zap.Logger
-	name       string
+	collection     *gocb.Collection
+	collectionName string
+	logger         *zap.Logger
+	name           string
+	readyTimeout   time.Duration
+	scope          string
 }
 
 // NewService returns an instantiated instance of a service which has the
@@ -33,10 +40,18 @@ type Service struct {
 // cb: couchbase cluster connection
 //
 // name: the couchbase bucket name
-func NewService(logger *zap.Logger, cb *gocb.Cluster, name string) (*Service, error) {
+//
+// opts: optional behavior i.e. WithCollection
+func NewService(logger *zap.Logger, cb *gocb.Cluster, name string, opts ...Option) (*Service, error) {
 	s := Service{
-		logger: logger.Named(loggerName),
-		name:   name,
+		collectionName: images.Collection,
+		logger:         logger.Named(loggerName),
+		name:           name,
+		readyTimeout:   defaultReadyTimeout,
+		scope:          images.Scope,
+	}
+	for i := range opts {
+		opts[i](&s)
 	}
 
 	if err := s.setCollection(cb, name); err != nil {
@@ -54,6 +69,26 @@ func NewService(logger *zap.Logger, cb *gocb.Cluster, name string) (*Service, er
 	return &s, nil
 }
 
+// Option provides the means to configure optional behavior of the service.
+type Option func(s *Service)
+
+// WithCollection overrides the scope and collection that hold the image
+// records, defaults to images.Scope and images.Collection.
+func WithCollection(scope, collection string) Option {
+	return func(s *Service) {
+		s.scope = scope
+		s.collectionName = collection
+	}
+}
+
+// WithReadyTimeout overrides how long to wait for the bucket to be ready,
+// defaults to 3s.
+func WithReadyTimeout(d time.Duration) Option {
+	return func(s *Service) {
+		s.readyTimeout = d
+	}
+}
+
 func (s *Service) validate() error {
 	var missingDeps []string
 
@@ -90,8 +125,8 @@ func (s *Service) validate() error {
 	return nil
 }
 
-// Create adds the given record to the dynamodb table.
-func (s *Service) Create(record *images.Record) error {
+// Create adds the given record to the couchbase collection.
+func (s *Service) Create(ctx context.Context, record *images.Record) error {
 	logger := s.logger.With(
 		zap.String("recordId", record.ID),
 		zap.String("key", record.Key),
@@ -100,25 +135,72 @@ func (s *Service) Create(record *images.Record) error {
 
 	// attempt to insert item
 	options := gocb.InsertOptions{
+		Context:         ctx,
 		DurabilityLevel: gocb.DurabilityLevelNone,
-		Timeout:         dbTimeout,
 	}
-	if _, err := s.collection.Insert(record.ID, record, &options); err != nil {
+	doc := document(record, time.Now().UTC())
+	res, err := s.collection.Insert(record.ID, doc, &options)
+	if err != nil {
 		const msg = "unable to insert image record"
 		logger.Error(msg, zap.Error(err))
 		return fmt.Errorf(msg+": %w", err)
 	}
+	record.Revision = uint64(res.Cas())
+	record.UpdatedAt = doc.UpdatedAt
 
 	logger.Info("successfully inserted item in db")
 
 	return nil
 }
 
+// CreateBatch adds the given records to the couchbase collection using a
+// single bulk operation.
+func (s *Service) CreateBatch(ctx context.Context, records []images.Record) error {
+	now := time.Now().UTC()
+	ops := make([]gocb.BulkOp, len(records))
+	inserts := make([]gocb.InsertOp, len(records))
+	for i := range records {
+		inserts[i] = gocb.InsertOp{
+			ID:    records[i].ID,
+			Value: document(&records[i], now),
+		}
+		ops[i] = &inserts[i]
+	}
+
+	if err := s.collection.Do(ops, &gocb.BulkOpOptions{Context: ctx}); err != nil {
+		const msg = "unable to insert image records"
+		s.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	failed := make(images.BatchError)
+	for i := range inserts {
+		if err := inserts[i].Err; err != nil {
+			s.logger.Error("unable to insert image record", zap.String("recordId", inserts[i].ID), zap.Error(err))
+			failed[inserts[i].ID] = err
+			continue
+		}
+		records[i].Revision = uint64(inserts[i].Result.Cas())
+		records[i].UpdatedAt = &now
+	}
+	s.logger.Info(
+		"successfully inserted items in db",
+		zap.Int("inserted", len(records)-len(failed)),
+		zap.Int("failed", len(failed)),
+	)
+
+	if len(failed) > 0 {
+		return failed
+	}
+
+	return nil
+}
+
 // Delete removes the item with id from the database.
-func (s *Service) Delete(id string) error {
+func (s *Service) Delete(ctx context.Context, id string) error {
 	logger := s.logger.With(zap.String("imageId", id))
 
-	if _, err := s.collection.Remove(id, &gocb.RemoveOptions{Timeout: dbTimeout}); err != nil {
+	if _, err := s.collection.Remove(id, &gocb.RemoveOptions{Context: ctx}); err != nil {
 		const msg = "unable to delete image record"
 		logger.Error(msg, zap.Error(err))
 		return fmt.Errorf(msg+": %w", err)
@@ -129,13 +211,102 @@ func (s *Service) Delete(id string) error {
 	return nil
 }
 
+// DeleteBatch removes the items with the ids from the database using a
+// single bulk operation.
+func (s *Service) DeleteBatch(ctx context.Context, ids []string) error {
+	ops := make([]gocb.BulkOp, len(ids))
+	removes := make([]gocb.RemoveOp, len(ids))
+	for i := range ids {
+		removes[i] = gocb.RemoveOp{ID: ids[i]}
+		ops[i] = &removes[i]
+	}
+
+	if err := s.collection.Do(ops, &gocb.BulkOpOptions{Context: ctx}); err != nil {
+		const msg = "unable to delete image records"
+		s.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	failed := make(images.BatchError)
+	for i := range removes {
+		err := removes[i].Err
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, gocb.ErrDocumentNotFound):
+			err = images.ErrRecordNotFound
+		}
+		s.logger.Error("unable to delete image record", zap.String("imageId", removes[i].ID), zap.Error(err))
+		failed[removes[i].ID] = err
+	}
+	s.logger.Info(
+		"successfully deleted items from db",
+		zap.Int("deleted", len(ids)-len(failed)),
+		zap.Int("failed", len(failed)),
+	)
+
+	if len(failed) > 0 {
+		return failed
+	}
+
+	return nil
+}
+
+// Update replaces the existing record in the database.
+func (s *Service) Update(ctx context.Context, record *images.Record) error {
+	logger := s.logger.With(
+		zap.String("recordId", record.ID),
+		zap.String("key", record.Key),
+		zap.String("storage", record.Storage),
+	)
+
+	// the revision is the document's CAS, the replace fails if the document
+	// changed since the record was read
+	options := gocb.ReplaceOptions{
+		Cas:             gocb.Cas(record.Revision),
+		Context:         ctx,
+		DurabilityLevel: gocb.DurabilityLevelNone,
+	}
+	doc := document(record, time.Now().UTC())
+	res, err := s.collection.Replace(record.ID, doc, &options)
+	if err != nil {
+		switch {
+		case errors.Is(err, gocb.ErrDocumentNotFound):
+			logger.Error("record not found")
+			return images.ErrRecordNotFound
+		case errors.Is(err, gocb.ErrCasMismatch):
+			logger.Error("record revision does not match", zap.Uint64("revision", record.Revision))
+			return images.ErrConflict
+		}
+		const msg = "unable to replace image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	record.Revision = uint64(res.Cas())
+	record.UpdatedAt = doc.UpdatedAt
+
+	logger.Info("successfully replaced item in db")
+
+	return nil
+}
+
+// document returns the record as it is stored when written at now, the
+// revision is the document's CAS rather than part of its content.
+func document(record *images.Record, now time.Time) *images.Record {
+	doc := *record
+	doc.Revision = 0
+	doc.UpdatedAt = &now
+
+	return &doc
+}
+
 func (s *Service) setCollection(c *gocb.Cluster, bucket string) error {
 	b := c.Bucket(bucket)
-	if err := b.WaitUntilReady(time.Second*3, nil); err != nil {
+	if err := b.WaitUntilReady(s.readyTimeout, nil); err != nil {
 		return fmt.Errorf("unable to connect to bucket: %q", err)
 	}
 
-	s.collection = b.Scope(images.Scope).Collection(images.Collection)
+	s.collection = b.Scope(s.scope).Collection(s.collectionName)
 
 	return nil
 }
@@ -0,0 +1,133 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+// blurhashSize is the longest side images are scaled down to before their
+// blurhash is computed, the hash holds a few components so the detail lost
+// does not change it.
+const blurhashSize = 64
+
+// Blurhash returns the blurhash of the image, see https://blurha.sh, a short
+// string frontends decode into a blurred placeholder shown while the image
+// loads. The hash has 4 components along the image's longer side and 3
+// along its shorter side.
+func Blurhash(img image.Image) string {
+	rgba := toRGBA(Fit(img, blurhashSize, blurhashSize))
+	xComp, yComp := 4, 3
+	if rgba.Rect.Dy() > rgba.Rect.Dx() {
+		xComp, yComp = 3, 4
+	}
+
+	factors := make([][3]float64, 0, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			factors = append(factors, blurhashFactor(rgba, i, j))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(encode83((xComp-1)+(yComp-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	var actualMax float64
+	for _, f := range ac {
+		for _, c := range f {
+			actualMax = math.Max(actualMax, math.Abs(c))
+		}
+	}
+	quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+	maxValue := float64(quantisedMax+1) / 166
+	b.WriteString(encode83(quantisedMax, 1))
+
+	b.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		var value int
+		for _, c := range f {
+			q := int(math.Max(0, math.Min(18, math.Floor(signPow(c/maxValue, 0.5)*9+9.5))))
+			value = value*19 + q
+		}
+		b.WriteString(encode83(value, 2))
+	}
+
+	return b.String()
+}
+
+// blurhashFactor returns the linear RGB weight of the image's cosine
+// component i, j.
+func blurhashFactor(img *image.RGBA, i, j int) [3]float64 {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	var f [3]float64
+	if w == 0 || h == 0 {
+		return f
+	}
+
+	for y := 0; y < h; y++ {
+		cy := math.Cos(math.Pi * float64(j) * float64(y) / float64(h))
+		for x := 0; x < w; x++ {
+			basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) * cy
+			p := img.Pix[img.PixOffset(x, y):]
+			for c := range f {
+				f[c] += basis * sRGBToLinear(unpremultiply(p[c], p[3]))
+			}
+		}
+	}
+
+	scale := 2.0
+	if i == 0 && j == 0 {
+		scale = 1
+	}
+	for c := range f {
+		f[c] *= scale / float64(w*h)
+	}
+
+	return f
+}
+
+// unpremultiply returns the color value of the alpha premultiplied value.
+func unpremultiply(v, a uint8) uint8 {
+	if a == 0 || a == 255 {
+		return v
+	}
+
+	return uint8(int(v) * 255 / int(a))
+}
+
+func sRGBToLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	c := math.Max(0, math.Min(1, v))
+	if c <= 0.0031308 {
+		return int(c*12.92*255 + 0.5)
+	}
+
+	return int((1.055*math.Pow(c, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// base83 are the digits of the blurhash encoding
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// encode83 encodes the value as length base 83 digits.
+func encode83(value, length int) string {
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = base83[value%83]
+		value /= 83
+	}
+
+	return string(b)
+}
@@ -0,0 +1,66 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Blurhash(t *testing.T) {
+	solid := func(w, h int, c color.Color) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+		return img
+	}
+	// a black to white gradient from left to right
+	gradient := image.NewGray(image.Rect(0, 0, 100, 50))
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 50; y++ {
+			gradient.SetGray(x, y, color.Gray{Y: uint8(x * 255 / 99)})
+		}
+	}
+	// the 11 components of a black image are all 0, fQ
+	flat := strings.Repeat("fQ", 11)
+
+	for _, tc := range []struct {
+		desc string
+		img  image.Image
+		want string
+	}{
+		{
+			desc: "Blurhash() should hash a white image to its color",
+			img:  solid(100, 50, color.White),
+			want: "L9TSUA-;fQ-;~qj[fQj[fQfQfQfQ",
+		},
+		{
+			desc: "Blurhash() should hash a black image without detail",
+			img:  solid(100, 50, color.Black),
+			want: "L00000" + flat,
+		},
+		{
+			desc: "Blurhash() should use 4 components along the height of a portrait image",
+			img:  solid(50, 100, color.White),
+			want: "T9TSUA~qfQ-;j[fQfQfQfQ-;j[fQ",
+		},
+		{
+			desc: "Blurhash() should hash the color of translucent pixels",
+			img:  solid(100, 50, color.NRGBA{R: 255, G: 255, B: 255, A: 128}),
+			want: "L9TSUA-;fQ-;~qj[fQj[fQfQfQfQ",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, Blurhash(tc.img))
+		})
+	}
+
+	t.Run("Blurhash() should hash the detail of the image", func(t *testing.T) {
+		got := Blurhash(gradient)
+		assert.Len(t, got, 28)
+		assert.Equal(t, "L", got[:1])
+		assert.NotEqual(t, Blurhash(solid(100, 50, color.Gray{Y: 128})), got)
+	})
+}
@@ -0,0 +1,317 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ExifHeadLen is how many bytes from the start of an image are read for its
+// EXIF, which JPEGs hold in a segment of at most 64KB before the image data.
+const ExifHeadLen = 128 << 10
+
+// Exif is the EXIF metadata of an image sim records.
+type Exif struct {
+	Make      string
+	Model     string
+	LensMake  string
+	LensModel string
+
+	// CapturedAt is when the photo was taken, in UTC. Cameras which do not
+	// record their offset from UTC are assumed to be set to UTC.
+	CapturedAt *time.Time
+
+	// Latitude and Longitude are the GPS position in degrees, negative in
+	// the south and west.
+	Latitude  *float64
+	Longitude *float64
+}
+
+// errInvalidExif is returned for EXIF which can not be parsed.
+var errInvalidExif = errors.New("invalid exif")
+
+// exif tags
+const (
+	tagMake               = 0x010f
+	tagModel              = 0x0110
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagLensMake           = 0xa433
+	tagLensModel          = 0xa434
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+)
+
+// ParseExif returns the EXIF of the JPEG or PNG whose first bytes are head,
+// see ExifHeadLen. It returns nil when the image has none.
+func ParseExif(head []byte) (*Exif, error) {
+	tiff := exifSegment(head)
+	if tiff == nil {
+		return nil, nil
+	}
+
+	t, err := newTIFF(tiff)
+	if err != nil {
+		return nil, err
+	}
+	ifd0, err := t.ifd(t.first)
+	if err != nil {
+		return nil, err
+	}
+
+	x := Exif{
+		Make:  t.str(ifd0[tagMake]),
+		Model: t.str(ifd0[tagModel]),
+	}
+	captured, offset := t.str(ifd0[tagDateTime]), ""
+	if off := t.long(ifd0[tagExifIFD]); off != 0 {
+		sub, err := t.ifd(off)
+		if err != nil {
+			return nil, err
+		}
+		x.LensMake, x.LensModel = t.str(sub[tagLensMake]), t.str(sub[tagLensModel])
+		if original := t.str(sub[tagDateTimeOriginal]); original != "" {
+			captured, offset = original, t.str(sub[tagOffsetTimeOriginal])
+		}
+	}
+	x.CapturedAt = exifTime(captured, offset)
+	if off := t.long(ifd0[tagGPSIFD]); off != 0 {
+		gps, err := t.ifd(off)
+		if err != nil {
+			return nil, err
+		}
+		x.Latitude = t.coordinate(gps[tagGPSLatitude], t.str(gps[tagGPSLatitudeRef]), "S")
+		x.Longitude = t.coordinate(gps[tagGPSLongitude], t.str(gps[tagGPSLongitudeRef]), "W")
+	}
+
+	return &x, nil
+}
+
+// exifSegment returns the TIFF structure holding the EXIF of the JPEG or
+// PNG, nil when it has none.
+func exifSegment(b []byte) []byte {
+	switch {
+	case bytes.HasPrefix(b, []byte("\xff\xd8")):
+		return jpegExif(b)
+	case bytes.HasPrefix(b, []byte(pngSignature)):
+		return pngExif(b)
+	}
+
+	return nil
+}
+
+// jpegExif returns the TIFF structure of the JPEG's APP1 Exif segment.
+func jpegExif(b []byte) []byte {
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xff {
+			return nil
+		}
+		marker := b[i+1]
+		switch {
+		case marker == 0xff:
+			// fill byte
+			i++
+			continue
+		case marker == 0xd8 || (marker >= 0xd0 && marker <= 0xd7):
+			i += 2
+			continue
+		case marker == 0xd9 || marker == 0xda:
+			// the metadata segments precede the image data
+			return nil
+		}
+
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if n < 2 || i+2+n > len(b) {
+			return nil
+		}
+		segment := b[i+4 : i+2+n]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte(exifHeader)) {
+			return segment[len(exifHeader):]
+		}
+		i += 2 + n
+	}
+
+	return nil
+}
+
+// pngExif returns the TIFF structure of the PNG's eXIf chunk.
+func pngExif(b []byte) []byte {
+	for i := len(pngSignature); i+8 <= len(b); {
+		n := int(binary.BigEndian.Uint32(b[i:]))
+		typ := string(b[i+4 : i+8])
+		if i+12+n > len(b) || typ == "IDAT" || typ == "IEND" {
+			return nil
+		}
+		if typ == "eXIf" {
+			return b[i+8 : i+8+n]
+		}
+		i += 12 + n
+	}
+
+	return nil
+}
+
+const (
+	exifHeader   = "Exif\x00\x00"
+	pngSignature = "\x89PNG\r\n\x1a\n"
+)
+
+// tiff reads the image file directories of a TIFF structure.
+type tiff struct {
+	b     []byte
+	order binary.ByteOrder
+	first uint32
+}
+
+// entry is a field of an image file directory, value holds the value when
+// it fits in 4 bytes or else the offset of the value.
+type entry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func newTIFF(b []byte) (*tiff, error) {
+	if len(b) < 8 {
+		return nil, errInvalidExif
+	}
+	t := tiff{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errInvalidExif
+	}
+	if t.order.Uint16(b[2:]) != 42 {
+		return nil, errInvalidExif
+	}
+	t.first = t.order.Uint32(b[4:])
+
+	return &t, nil
+}
+
+// ifd returns the fields of the image file directory at the offset by tag.
+func (t *tiff) ifd(offset uint32) (map[uint16]entry, error) {
+	if int64(offset)+2 > int64(len(t.b)) {
+		return nil, errInvalidExif
+	}
+	n := int(t.order.Uint16(t.b[offset:]))
+	start := int(offset) + 2
+	if start+n*12 > len(t.b) {
+		return nil, errInvalidExif
+	}
+
+	fields := make(map[uint16]entry, n)
+	for i := 0; i < n; i++ {
+		f := t.b[start+i*12 : start+i*12+12]
+		fields[t.order.Uint16(f)] = entry{
+			typ:   t.order.Uint16(f[2:]),
+			count: t.order.Uint32(f[4:]),
+			value: f[8:12],
+		}
+	}
+
+	return fields, nil
+}
+
+// typeSizes are the sizes in bytes of the field types sim reads
+var typeSizes = map[uint16]int{
+	2: 1, // ASCII
+	3: 2, // SHORT
+	4: 4, // LONG
+	5: 8, // RATIONAL
+}
+
+// data returns the bytes of the field's value, nil when the field is
+// missing or out of bounds.
+func (t *tiff) data(e entry) []byte {
+	size, ok := typeSizes[e.typ]
+	if !ok || e.count == 0 || e.count > uint32(len(t.b)) {
+		return nil
+	}
+	n := size * int(e.count)
+	if n <= 4 {
+		return e.value[:n]
+	}
+	offset := int64(t.order.Uint32(e.value))
+	if offset+int64(n) > int64(len(t.b)) {
+		return nil
+	}
+
+	return t.b[offset : offset+int64(n)]
+}
+
+// str returns the ASCII field's value.
+func (t *tiff) str(e entry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	b := t.data(e)
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+// long returns the LONG field's value.
+func (t *tiff) long(e entry) uint32 {
+	if e.typ != 4 {
+		return 0
+	}
+
+	return t.order.Uint32(e.value)
+}
+
+// coordinate returns the degrees of the GPS field's degrees, minutes and
+// seconds, negative when the ref is negative, i.e. S.
+func (t *tiff) coordinate(e entry, ref, negative string) *float64 {
+	b := t.data(e)
+	if e.typ != 5 || len(b) != 24 {
+		return nil
+	}
+
+	var parts [3]float64
+	for i := range parts {
+		num, den := t.order.Uint32(b[i*8:]), t.order.Uint32(b[i*8+4:])
+		if den == 0 {
+			return nil
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	deg := parts[0] + parts[1]/60 + parts[2]/3600
+	if strings.EqualFold(ref, negative) {
+		deg = -deg
+	}
+
+	return &deg
+}
+
+// exifTime returns the time of the EXIF date and time and its offset from
+// UTC, i.e. +02:00, in UTC. Nil when the date is missing or malformed.
+func exifTime(datetime, offset string) *time.Time {
+	if datetime == "" {
+		return nil
+	}
+	loc := time.UTC
+	if o, err := time.Parse("-07:00", offset); err == nil {
+		_, secs := o.Zone()
+		loc = time.FixedZone("", secs)
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", datetime, loc)
+	if err != nil {
+		return nil
+	}
+	t = t.UTC()
+
+	return &t
+}
@@ -0,0 +1,190 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// field is a field of an image file directory to encode.
+type field struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func ascii(tag uint16, s string) field {
+	return field{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+func long(tag uint16, v uint32) field {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return field{tag: tag, typ: 4, count: 1, data: b}
+}
+
+func rationals(tag uint16, vs ...uint32) field {
+	b := make([]byte, 4*len(vs))
+	for i, v := range vs {
+		binary.LittleEndian.PutUint32(b[i*4:], v)
+	}
+	return field{tag: tag, typ: 5, count: uint32(len(vs) / 2), data: b}
+}
+
+// encodeIFD encodes the fields as an image file directory at the offset,
+// followed by the values which do not fit in their field.
+func encodeIFD(offset int, fields []field) []byte {
+	var (
+		dir   = make([]byte, 2+12*len(fields)+4)
+		extra []byte
+	)
+	binary.LittleEndian.PutUint16(dir, uint16(len(fields)))
+	for i, f := range fields {
+		e := dir[2+i*12:]
+		binary.LittleEndian.PutUint16(e, f.tag)
+		binary.LittleEndian.PutUint16(e[2:], f.typ)
+		binary.LittleEndian.PutUint32(e[4:], f.count)
+		if len(f.data) <= 4 {
+			copy(e[8:12], f.data)
+			continue
+		}
+		binary.LittleEndian.PutUint32(e[8:], uint32(offset+len(dir)+len(extra)))
+		extra = append(extra, f.data...)
+	}
+
+	return append(dir, extra...)
+}
+
+// testExif returns the TIFF structure of the EXIF of a photo taken in
+// Paris, see Test_ParseExif.
+func testExif() []byte {
+	sub := []field{
+		ascii(tagDateTimeOriginal, "2023:06:01 14:30:00"),
+		ascii(tagOffsetTimeOriginal, "+02:00"),
+		ascii(tagLensModel, "RF24-105mm F4 L IS USM"),
+	}
+	gps := []field{
+		ascii(tagGPSLatitudeRef, "N"),
+		rationals(tagGPSLatitude, 48, 1, 51, 1, 30, 1),
+		ascii(tagGPSLongitudeRef, "W"),
+		rationals(tagGPSLongitude, 2, 1, 21, 1, 0, 1),
+	}
+	ifd0 := []field{
+		ascii(tagMake, "Canon"),
+		ascii(tagModel, "Canon EOS R5"),
+		ascii(tagDateTime, "2023:06:02 09:00:00"),
+		long(tagExifIFD, 0),
+		long(tagGPSIFD, 0),
+	}
+
+	// the directories follow the header in order
+	subOffset := 8 + len(encodeIFD(8, ifd0))
+	gpsOffset := subOffset + len(encodeIFD(subOffset, sub))
+	ifd0[3], ifd0[4] = long(tagExifIFD, uint32(subOffset)), long(tagGPSIFD, uint32(gpsOffset))
+
+	b := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	b = append(b, encodeIFD(8, ifd0)...)
+	b = append(b, encodeIFD(subOffset, sub)...)
+	return append(b, encodeIFD(gpsOffset, gps)...)
+}
+
+// exifJPEG returns a JPEG holding the EXIF in an APP1 segment after its
+// JFIF segment.
+func exifJPEG(t *testing.T, exif []byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil))
+	b := buf.Bytes()
+
+	segment := append([]byte(exifHeader), exif...)
+	app1 := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, b[:2]...)
+	out = append(out, app1...)
+	return append(out, b[2:]...)
+}
+
+// exifPNG returns a PNG holding the EXIF in an eXIf chunk after its header.
+func exifPNG(t *testing.T, exif []byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))))
+	b := buf.Bytes()
+
+	// the signature and the IHDR chunk
+	n := len(pngSignature) + 12 + 13
+	chunk := make([]byte, 8, 12+len(exif))
+	binary.BigEndian.PutUint32(chunk, uint32(len(exif)))
+	copy(chunk[4:], "eXIf")
+	chunk = append(chunk, exif...)
+	chunk = append(chunk, 0, 0, 0, 0)
+
+	out := append([]byte{}, b[:n]...)
+	out = append(out, chunk...)
+	return append(out, b[n:]...)
+}
+
+func Test_ParseExif(t *testing.T) {
+	captured := time.Date(2023, 6, 1, 12, 30, 0, 0, time.UTC)
+	lat, lon := 48+51.0/60+30.0/3600, -(2 + 21.0/60)
+	want := &Exif{
+		Make:       "Canon",
+		Model:      "Canon EOS R5",
+		LensModel:  "RF24-105mm F4 L IS USM",
+		CapturedAt: &captured,
+		Latitude:   &lat,
+		Longitude:  &lon,
+	}
+
+	var plain bytes.Buffer
+	require.NoError(t, jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 4, 4)), nil))
+
+	for _, tc := range []struct {
+		desc    string
+		head    []byte
+		want    *Exif
+		wantErr bool
+	}{
+		{
+			desc: "ParseExif() should parse the camera, lens, capture time and position of a jpeg",
+			head: exifJPEG(t, testExif()),
+			want: want,
+		},
+		{
+			desc: "ParseExif() should parse the eXIf chunk of a png",
+			head: exifPNG(t, testExif()),
+			want: want,
+		},
+		{
+			desc: "ParseExif() should return nil for an image without exif",
+			head: plain.Bytes(),
+		},
+		{
+			desc: "ParseExif() should return nil for an image which is not a jpeg or png",
+			head: []byte("GIF89a"),
+		},
+		{
+			desc:    "ParseExif() should fail when the exif is malformed",
+			head:    exifJPEG(t, []byte("II*\x00\xff\xff\x00\x00")),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseExif(tc.head)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/chai2010/webp"
+)
+
+// Format is an encoding images are read and written in.
+type Format string
+
+const (
+	JPEG Format = "jpeg"
+	PNG  Format = "png"
+	GIF  Format = "gif"
+	WebP Format = "webp"
+	AVIF Format = "avif"
+)
+
+// formats are the formats which can be decoded, by content type
+var formats = map[string]Format{
+	"image/jpeg": JPEG,
+	"image/png":  PNG,
+	"image/gif":  GIF,
+	"image/webp": WebP,
+}
+
+// ParseFormat returns the format of the name, i.e. png or jpg, false when
+// the format is unknown.
+func ParseFormat(name string) (Format, bool) {
+	switch f := Format(strings.ToLower(name)); f {
+	case "jpg":
+		return JPEG, true
+	case JPEG, PNG, GIF, WebP, AVIF:
+		return f, true
+	default:
+		return "", false
+	}
+}
+
+// Encodable reports whether images can be encoded in the format, there is
+// no AVIF encoder.
+func (f Format) Encodable() bool {
+	return f == JPEG || f == PNG || f == GIF || f == WebP
+}
+
+// FormatOf returns the format of the content type, false when images of the
+// type can not be decoded.
+func FormatOf(contentType string) (Format, bool) {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	f, ok := formats[t]
+
+	return f, ok
+}
+
+// ContentType returns the content type of the format.
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Ext returns the file extension of the format, without the dot.
+func (f Format) Ext() string {
+	if f == JPEG {
+		return "jpg"
+	}
+
+	return string(f)
+}
+
+// Encode encodes the image in the format, a JPEG with the quality or
+// DefaultJPEGQuality when 0 and a WebP lossy with DefaultWebPQuality. Only
+// the first frame of an animated GIF is kept.
+func Encode(w io.Writer, img image.Image, f Format, quality int) error {
+	switch f {
+	case JPEG:
+		if quality == 0 {
+			quality = DefaultJPEGQuality
+		}
+		return EncodeJPEG(w, img, quality)
+	case PNG:
+		return png.Encode(w, img)
+	case GIF:
+		return gif.Encode(w, img, nil)
+	case WebP:
+		return webp.Encode(w, img, &webp.Options{Quality: DefaultWebPQuality})
+	default:
+		return fmt.Errorf("unsupported format: %s", f)
+	}
+}
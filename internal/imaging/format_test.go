@@ -0,0 +1,107 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/chai2010/webp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseFormat(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		name          string
+		want          Format
+		wantOK        bool
+		wantEncodable bool
+	}{
+		{
+			desc:          "ParseFormat() should return the format of its extension",
+			name:          "JPG",
+			want:          JPEG,
+			wantOK:        true,
+			wantEncodable: true,
+		},
+		{
+			desc:          "ParseFormat() should return webp",
+			name:          "webp",
+			want:          WebP,
+			wantOK:        true,
+			wantEncodable: true,
+		},
+		{
+			desc:   "ParseFormat() should return formats which can not be encoded",
+			name:   "avif",
+			want:   AVIF,
+			wantOK: true,
+		},
+		{
+			desc: "ParseFormat() should return false for unknown formats",
+			name: "bmp",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := ParseFormat(tc.name)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantEncodable, got.Encodable())
+		})
+	}
+}
+
+func Test_FormatOf(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		contentType string
+		want        Format
+		wantOK      bool
+	}{
+		{
+			desc:        "FormatOf() should return the format of the content type",
+			contentType: "image/jpeg",
+			want:        JPEG,
+			wantOK:      true,
+		},
+		{
+			desc:        "FormatOf() should ignore the parameters of the content type",
+			contentType: "image/PNG; charset=binary",
+			want:        PNG,
+			wantOK:      true,
+		},
+		{
+			desc:        "FormatOf() should return webp, which is decoded with libwebp",
+			contentType: "image/webp",
+			want:        WebP,
+			wantOK:      true,
+		},
+		{
+			desc:        "FormatOf() should return false for types which can not be decoded",
+			contentType: "image/tiff",
+		},
+		{
+			desc:        "FormatOf() should return false for avif, which has no decoder",
+			contentType: "image/avif",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := FormatOf(tc.contentType)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_Encode_WebP(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 6))
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, src, WebP, 0))
+
+	cfg, err := webp.DecodeConfig(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 8, cfg.Width)
+	assert.Equal(t, 6, cfg.Height)
+}
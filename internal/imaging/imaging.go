@@ -0,0 +1,148 @@
+// Package imaging provides the image processing sim applies to uploads and
+// downloads, i.e. scaling images down to thumbnails.
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+)
+
+// DefaultJPEGQuality is the quality images are encoded as JPEG with.
+const DefaultJPEGQuality = 85
+
+// DefaultWebPQuality is the quality images are encoded as lossy WebP with.
+const DefaultWebPQuality = 80
+
+// FitSize returns the size the image of the width and height is scaled to
+// so that it fits within maxWidth by maxHeight, keeping its aspect ratio.
+// A zero max leaves that side unbounded. Images are never scaled up.
+func FitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= 0 || height <= 0 {
+		return width, height
+	}
+
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && float64(height)*scale > float64(maxHeight) {
+		scale = float64(maxHeight) / float64(height)
+	}
+	if scale == 1 {
+		return width, height
+	}
+
+	w, h := int(float64(width)*scale+0.5), int(float64(height)*scale+0.5)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	return w, h
+}
+
+// Fit scales the image down so that it fits within maxWidth by maxHeight,
+// see FitSize. The image is returned as is when it already fits.
+func Fit(src image.Image, maxWidth, maxHeight int) image.Image {
+	b := src.Bounds()
+	w, h := FitSize(b.Dx(), b.Dy(), maxWidth, maxHeight)
+	if w == b.Dx() && h == b.Dy() {
+		return src
+	}
+
+	return Scale(src, w, h)
+}
+
+// Scale returns the image scaled to the width and height. Each pixel is the
+// average of the pixels of the source it covers, which keeps the detail of
+// an image scaled down to a fraction of its size from aliasing.
+func Scale(src image.Image, width, height int) *image.RGBA {
+	rgba := toRGBA(src)
+	sw, sh := rgba.Rect.Dx(), rgba.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+
+	for y := 0; y < height; y++ {
+		y0, y1 := span(y, height, sh)
+		for x := 0; x < width; x++ {
+			x0, x1 := span(x, width, sw)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					p := rgba.Pix[i : i+4 : i+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+					i += 4
+				}
+			}
+
+			p := dst.Pix[dst.PixOffset(x, y):]
+			p[0] = uint8((r + n/2) / n)
+			p[1] = uint8((g + n/2) / n)
+			p[2] = uint8((b + n/2) / n)
+			p[3] = uint8((a + n/2) / n)
+		}
+	}
+
+	return dst
+}
+
+// span returns the range of the source pixels the i-th of n destination
+// pixels covers along a side of size pixels, at least one pixel.
+func span(i, n, size int) (int, int) {
+	start, end := i*size/n, (i+1)*size/n
+	if end <= start {
+		end = start + 1
+	}
+	if end > size {
+		start, end = size-1, size
+	}
+
+	return start, end
+}
+
+// toRGBA returns the image as an RGBA image whose bounds start at 0, 0.
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, src, b.Min, draw.Src)
+
+	return rgba
+}
+
+// Flatten returns the image drawn over the background color, i.e. to encode
+// an image with transparency in a format without it.
+func Flatten(src image.Image, background color.Color) image.Image {
+	if opaque, ok := src.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return src
+	}
+
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Rect, image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Rect, src, b.Min, draw.Over)
+
+	return dst
+}
+
+// EncodeJPEG encodes the image as a JPEG of the quality, transparent pixels
+// are flattened onto white as JPEG has no alpha.
+func EncodeJPEG(w io.Writer, img image.Image, quality int) error {
+	return jpeg.Encode(w, Flatten(img, color.White), &jpeg.Options{Quality: quality})
+}
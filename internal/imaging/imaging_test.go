@@ -0,0 +1,93 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FitSize(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		size image.Point
+		max  image.Point
+		want image.Point
+	}{
+		{
+			desc: "FitSize() should scale a landscape image down to the max width",
+			size: image.Pt(1000, 500),
+			max:  image.Pt(256, 256),
+			want: image.Pt(256, 128),
+		},
+		{
+			desc: "FitSize() should scale a portrait image down to the max height",
+			size: image.Pt(500, 1000),
+			max:  image.Pt(256, 256),
+			want: image.Pt(128, 256),
+		},
+		{
+			desc: "FitSize() should not scale an image which fits up",
+			size: image.Pt(100, 50),
+			max:  image.Pt(256, 256),
+			want: image.Pt(100, 50),
+		},
+		{
+			desc: "FitSize() should leave a side without a max unbounded",
+			size: image.Pt(1000, 4000),
+			max:  image.Pt(500, 0),
+			want: image.Pt(500, 2000),
+		},
+		{
+			desc: "FitSize() should keep at least one pixel",
+			size: image.Pt(10000, 1),
+			max:  image.Pt(100, 100),
+			want: image.Pt(100, 1),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			w, h := FitSize(tc.size.X, tc.size.Y, tc.max.X, tc.max.Y)
+			assert.Equal(t, tc.want, image.Pt(w, h))
+		})
+	}
+}
+
+func Test_Scale(t *testing.T) {
+	// a 4x2 image, its left half black and its right half white
+	src := image.NewGray(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		src.SetGray(2, y, color.Gray{Y: 255})
+		src.SetGray(3, y, color.Gray{Y: 255})
+	}
+
+	dst := Scale(src, 2, 1)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), dst.Bounds())
+	assert.Equal(t, color.RGBA{A: 255}, dst.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, dst.RGBAAt(1, 0))
+
+	// each pixel averages the pixels it covers
+	dst = Scale(src, 1, 1)
+	assert.Equal(t, color.RGBA{R: 128, G: 128, B: 128, A: 255}, dst.RGBAAt(0, 0))
+
+	// the bounds of a sub image do not start at 0, 0
+	sub := src.SubImage(image.Rect(2, 0, 4, 2))
+	dst = Scale(sub, 1, 1)
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, dst.RGBAAt(0, 0))
+}
+
+func Test_EncodeJPEG(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJPEG(&buf, src, DefaultJPEGQuality))
+
+	// transparent pixels are flattened onto white
+	img, err := jpeg.Decode(&buf)
+	require.NoError(t, err)
+	r, g, b, _ := img.At(4, 4).RGBA()
+	assert.True(t, r > 0xf000 && g > 0xf000 && b > 0xf000)
+}
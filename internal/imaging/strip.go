@@ -0,0 +1,145 @@
+package imaging
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ErrNotStrippable is returned by StripMetadata for images which are not a
+// jpeg or png.
+var ErrNotStrippable = errors.New("metadata can only be stripped from jpeg and png images")
+
+// StripMetadata copies the JPEG or PNG read from r into w without its
+// metadata, i.e. the camera and GPS position of a photo. The EXIF, XMP and
+// IPTC segments and comments of a JPEG and the eXIf and text chunks of a PNG
+// are removed, color profiles and the image itself are copied as is.
+func StripMetadata(w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(pngSignature))
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	switch {
+	case len(head) >= 2 && head[0] == 0xff && head[1] == 0xd8:
+		return stripJPEG(w, br)
+	case string(head) == pngSignature:
+		return stripPNG(w, br)
+	}
+
+	return ErrNotStrippable
+}
+
+// stripJPEG copies the segments of the JPEG up to its image data without the
+// APP1 (EXIF and XMP) and APP13 (IPTC) segments and comments, then the rest
+// of the image.
+func stripJPEG(w io.Writer, r *bufio.Reader) error {
+	var marker [2]byte
+	for {
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return fmt.Errorf("invalid jpeg: %w", err)
+		}
+		if marker[0] != 0xff {
+			return errors.New("invalid jpeg: expected a marker")
+		}
+		if marker[1] == 0xff {
+			// fill byte, the marker follows it
+			if err := r.UnreadByte(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch m := marker[1]; {
+		case m == 0xd8 || (m >= 0xd0 && m <= 0xd7) || m == 0x01:
+			// markers without a segment
+			if _, err := w.Write(marker[:]); err != nil {
+				return err
+			}
+			continue
+		case m == 0xd9:
+			_, err := w.Write(marker[:])
+			return err
+		}
+
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return fmt.Errorf("invalid jpeg: %w", err)
+		}
+		n := int(binary.BigEndian.Uint16(size[:]))
+		if n < 2 {
+			return errors.New("invalid jpeg: segment length")
+		}
+
+		switch marker[1] {
+		case 0xe1, 0xed, 0xfe:
+			if _, err := r.Discard(n - 2); err != nil {
+				return fmt.Errorf("invalid jpeg: %w", err)
+			}
+			continue
+		case 0xda:
+			// the image data follows its header to the end of the image
+			if _, err := w.Write(append(marker[:], size[:]...)); err != nil {
+				return err
+			}
+			_, err := io.Copy(w, r)
+			return err
+		}
+
+		if _, err := w.Write(append(marker[:], size[:]...)); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, int64(n-2)); err != nil {
+			return fmt.Errorf("invalid jpeg: %w", err)
+		}
+	}
+}
+
+// strippedChunks are the PNG chunks holding metadata
+var strippedChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+}
+
+// stripPNG copies the chunks of the PNG without its metadata chunks.
+func stripPNG(w io.Writer, r *bufio.Reader) error {
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return err
+	}
+	if _, err := w.Write(sig); err != nil {
+		return err
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return fmt.Errorf("invalid png: %w", err)
+		}
+		// the data is followed by its CRC
+		n := int64(binary.BigEndian.Uint32(header[:4])) + 4
+		typ := string(header[4:])
+
+		if strippedChunks[typ] {
+			if _, err := io.CopyN(ioutil.Discard, r, n); err != nil {
+				return fmt.Errorf("invalid png: %w", err)
+			}
+			continue
+		}
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, n); err != nil {
+			return fmt.Errorf("invalid png: %w", err)
+		}
+		if typ == "IEND" {
+			return nil
+		}
+	}
+}
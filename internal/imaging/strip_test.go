@@ -0,0 +1,68 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StripMetadata(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		image   []byte
+		decode  func([]byte) (image.Image, error)
+		wantErr error
+	}{
+		{
+			desc:   "StripMetadata() should remove the exif of a jpeg",
+			image:  exifJPEG(t, testExif()),
+			decode: func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) },
+		},
+		{
+			desc:   "StripMetadata() should remove the exif of a png",
+			image:  exifPNG(t, testExif()),
+			decode: func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) },
+		},
+		{
+			desc:    "StripMetadata() should return ErrNotStrippable for other formats",
+			image:   []byte("GIF89a"),
+			wantErr: ErrNotStrippable,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			x, err := ParseExif(tc.image)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = StripMetadata(&buf, bytes.NewReader(tc.image))
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, x)
+
+			stripped := buf.Bytes()
+			x, err = ParseExif(stripped)
+			require.NoError(t, err)
+			assert.Nil(t, x)
+			assert.Less(t, len(stripped), len(tc.image))
+
+			// the image itself is kept
+			img, err := tc.decode(stripped)
+			require.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 4, 4), img.Bounds())
+		})
+	}
+}
+
+func Test_StripMetadata_Invalid(t *testing.T) {
+	// the exif segment is cut off
+	b := exifJPEG(t, testExif())[:40]
+	assert.Error(t, StripMetadata(&bytes.Buffer{}, bytes.NewReader(b)))
+}
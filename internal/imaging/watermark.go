@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+// Position is where a watermark is placed on an image.
+type Position string
+
+const (
+	TopLeft     Position = "top-left"
+	TopRight    Position = "top-right"
+	BottomLeft  Position = "bottom-left"
+	BottomRight Position = "bottom-right"
+	Center      Position = "center"
+	Tile        Position = "tile"
+)
+
+// ParsePosition returns the position of the name, i.e. bottom-right, false
+// when the position is unknown.
+func ParsePosition(name string) (Position, bool) {
+	switch p := Position(strings.ToLower(name)); p {
+	case TopLeft, TopRight, BottomLeft, BottomRight, Center, Tile:
+		return p, true
+	default:
+		return "", false
+	}
+}
+
+// Watermark is an image overlaid on images, i.e. a logo marking proofs.
+type Watermark struct {
+	// Mark is the image overlaid, its transparent pixels leave the image
+	// as is.
+	Mark image.Image
+
+	// Position of the mark on the image, Tile repeats it over all of it.
+	Position Position
+
+	// Opacity of the mark from 0, invisible, to 1.
+	Opacity float64
+
+	// Scale is the width of the mark as a fraction of the image's width,
+	// the mark is scaled down further when it would not fit its height.
+	Scale float64
+}
+
+// Valid reports whether the watermark has a mark, a known position and an
+// opacity and scale greater than 0 and at most 1.
+func (w *Watermark) Valid() bool {
+	_, ok := ParsePosition(string(w.Position))
+
+	return w.Mark != nil && ok &&
+		w.Opacity > 0 && w.Opacity <= 1 &&
+		w.Scale > 0 && w.Scale <= 1
+}
+
+// Apply returns the image with the watermark drawn over it. The mark is
+// scaled to the image so that images of every size are marked alike, and
+// placed a margin of 2% of the image's shorter side away from its edges.
+func (w *Watermark) Apply(src image.Image) image.Image {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Rect, src, b.Min, draw.Src)
+
+	mb := w.Mark.Bounds()
+	if mb.Dx() == 0 || mb.Dy() == 0 || dst.Rect.Empty() {
+		return dst
+	}
+	mw := int(float64(b.Dx())*w.Scale + 0.5)
+	mh := mw * mb.Dy() / mb.Dx()
+	if mh > b.Dy() {
+		mw, mh = mw*b.Dy()/mh, b.Dy()
+	}
+	if mw < 1 || mh < 1 {
+		return dst
+	}
+	mark := Scale(w.Mark, mw, mh)
+	opacity := image.NewUniform(color.Alpha{A: uint8(w.Opacity*255 + 0.5)})
+
+	margin := b.Dx()
+	if b.Dy() < margin {
+		margin = b.Dy()
+	}
+	margin /= 50
+
+	for _, pt := range w.positions(dst.Rect.Size(), mark.Rect.Size(), margin) {
+		r := image.Rectangle{Min: pt, Max: pt.Add(mark.Rect.Size())}
+		draw.DrawMask(dst, r, mark, image.Point{}, opacity, image.Point{}, draw.Over)
+	}
+
+	return dst
+}
+
+// positions returns the top left corners the mark of the size is drawn at
+// on an image of the size.
+func (w *Watermark) positions(size, mark image.Point, margin int) []image.Point {
+	left, top := margin, margin
+	right, bottom := size.X-mark.X-margin, size.Y-mark.Y-margin
+
+	switch w.Position {
+	case TopLeft:
+		return []image.Point{{left, top}}
+	case TopRight:
+		return []image.Point{{right, top}}
+	case BottomLeft:
+		return []image.Point{{left, bottom}}
+	case Center:
+		return []image.Point{{(size.X - mark.X) / 2, (size.Y - mark.Y) / 2}}
+	case Tile:
+		// the marks are a mark apart in both directions
+		var pts []image.Point
+		for y := margin; y < size.Y; y += 2 * mark.Y {
+			for x := margin; x < size.X; x += 2 * mark.X {
+				pts = append(pts, image.Pt(x, y))
+			}
+		}
+		return pts
+	default:
+		return []image.Point{{right, bottom}}
+	}
+}
@@ -0,0 +1,80 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Watermark_Apply(t *testing.T) {
+	// a black 100x50 image and a white 2x1 mark
+	src := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	draw.Draw(src, src.Rect, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	mark := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	draw.Draw(mark, mark.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	black := color.RGBA{A: 255}
+	for _, tc := range []struct {
+		desc   string
+		wm     Watermark
+		marked []image.Point
+		clear  []image.Point
+		want   color.RGBA
+	}{
+		{
+			desc: "Apply() should scale the mark to the image and place it in the bottom right corner",
+			wm:   Watermark{Mark: mark, Position: BottomRight, Opacity: 1, Scale: 0.2},
+			// the 20x10 mark is a margin of 1 pixel from the edges
+			marked: []image.Point{{79, 39}, {98, 48}},
+			clear:  []image.Point{{78, 39}, {99, 49}, {0, 0}},
+			want:   color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+		{
+			desc:   "Apply() should center the mark",
+			wm:     Watermark{Mark: mark, Position: Center, Opacity: 1, Scale: 0.2},
+			marked: []image.Point{{40, 20}, {59, 29}},
+			clear:  []image.Point{{39, 20}, {60, 30}},
+			want:   color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+		{
+			desc:   "Apply() should blend the mark with its opacity",
+			wm:     Watermark{Mark: mark, Position: TopLeft, Opacity: 0.5, Scale: 0.2},
+			marked: []image.Point{{1, 1}},
+			clear:  []image.Point{{0, 0}},
+			want:   color.RGBA{R: 128, G: 128, B: 128, A: 255},
+		},
+		{
+			desc:   "Apply() should repeat a tiled mark over the image",
+			wm:     Watermark{Mark: mark, Position: Tile, Opacity: 1, Scale: 0.2},
+			marked: []image.Point{{1, 1}, {41, 1}, {81, 21}},
+			clear:  []image.Point{{21, 1}, {1, 11}},
+			want:   color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dst := tc.wm.Apply(src).(*image.RGBA)
+			assert.Equal(t, src.Rect, dst.Rect)
+			for _, pt := range tc.marked {
+				assert.Equal(t, tc.want, dst.RGBAAt(pt.X, pt.Y), pt)
+			}
+			for _, pt := range tc.clear {
+				assert.Equal(t, black, dst.RGBAAt(pt.X, pt.Y), pt)
+			}
+		})
+	}
+
+	// the source is not modified
+	assert.Equal(t, black, src.RGBAAt(98, 48))
+}
+
+func Test_Watermark_Valid(t *testing.T) {
+	mark := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	assert.True(t, (&Watermark{Mark: mark, Position: Tile, Opacity: 1, Scale: 0.25}).Valid())
+	assert.False(t, (&Watermark{Position: Tile, Opacity: 1, Scale: 0.25}).Valid())
+	assert.False(t, (&Watermark{Mark: mark, Position: "middle", Opacity: 1, Scale: 0.25}).Valid())
+	assert.False(t, (&Watermark{Mark: mark, Position: Tile, Opacity: 0, Scale: 0.25}).Valid())
+	assert.False(t, (&Watermark{Mark: mark, Position: Tile, Opacity: 1, Scale: 1.5}).Valid())
+}
@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/itsHabib/sim/internal/audit"
+)
+
+// ActivityLog holds the activities in memory.
+type ActivityLog struct {
+	mu         sync.RWMutex
+	activities []audit.Activity
+}
+
+// NewActivityLog returns an empty in memory activity log.
+func NewActivityLog() *ActivityLog {
+	return new(ActivityLog)
+}
+
+// Record adds the activity.
+func (l *ActivityLog) Record(ctx context.Context, a *audit.Activity) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.activities = append(l.activities, *a)
+
+	return nil
+}
+
+// Recent returns the latest activities matching the filter in the order they
+// were recorded.
+func (l *ActivityLog) Recent(ctx context.Context, filter audit.ActivityFilter, limit int) ([]audit.Activity, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var recent []audit.Activity
+	for i := len(l.activities) - 1; i >= 0 && (limit == 0 || len(recent) < limit); i-- {
+		if filter.Match(&l.activities[i]) {
+			recent = append(recent, l.activities[i])
+		}
+	}
+	reverse(recent)
+
+	return recent, nil
+}
+
+func reverse(activities []audit.Activity) {
+	for i, j := 0, len(activities)-1; i < j; i, j = i+1, j-1 {
+		activities[i], activities[j] = activities[j], activities[i]
+	}
+}
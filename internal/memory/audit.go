@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/itsHabib/sim/internal/audit"
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// AuditStore holds the audit events of image records in memory.
+type AuditStore struct {
+	mu     sync.RWMutex
+	events map[string][]audit.Event
+}
+
+// NewAuditStore returns an empty in memory audit store.
+func NewAuditStore() *AuditStore {
+	return &AuditStore{events: make(map[string][]audit.Event)}
+}
+
+// Append adds a copy of the event.
+func (s *AuditStore) Append(ctx context.Context, e *audit.Event) error {
+	c := *e
+	c.Changes = append([]audit.Change(nil), e.Changes...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[e.ImageID] = append(s.events[e.ImageID], c)
+
+	return nil
+}
+
+// History returns copies of the events of the image record in the order they
+// were appended.
+func (s *AuditStore) History(ctx context.Context, imageID string) ([]audit.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := s.events[imageID]
+	if len(events) == 0 {
+		return nil, images.ErrRecordNotFound
+	}
+
+	return append([]audit.Event(nil), events...), nil
+}
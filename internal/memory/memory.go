@@ -0,0 +1,324 @@
+// Package memory provides an in memory, concurrency safe implementation of the
+// image record reader and writer for demos and tests.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const loggerName = "memory.records"
+
+// Records implements both the images.Reader and images.Writer by holding the
+// image records in memory. Records are copied in and out so callers can not
+// mutate the stored records.
+type Records struct {
+	logger  *zap.Logger
+	mu      sync.RWMutex
+	records map[string]images.Record
+}
+
+// NewRecords returns an instantiated instance of in memory records which has
+// the following dependencies:
+//
+// logger: for structured logging
+//
+// records: optional records to seed the store with
+func NewRecords(logger *zap.Logger, records ...images.Record) (*Records, error) {
+	r := Records{
+		logger:  logger.Named(loggerName),
+		records: make(map[string]images.Record, len(records)),
+	}
+	for i := range records {
+		r.records[records[i].ID] = copyRecord(&records[i])
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Debug("successfully initialized in memory records")
+
+	return &r, nil
+}
+
+func (r *Records) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "logger",
+			chk: func() bool { return r.logger != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize records due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Get returns an image record given the id. Returns ErrRecordNotFound if no
+// image is found by that ID.
+func (r *Records) Get(ctx context.Context, id string) (*images.Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rec, ok := r.records[id]
+	if !ok {
+		r.logger.Error("record not found", zap.String("imageId", id))
+		return nil, images.ErrRecordNotFound
+	}
+	rec = copyRecord(&rec)
+
+	return &rec, nil
+}
+
+// GetByName returns the image record with the given name. Returns
+// ErrRecordNotFound if no image is found by that name.
+func (r *Records) GetByName(ctx context.Context, name string) (*images.Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for id := range r.records {
+		if rec := r.records[id]; rec.Name == name {
+			rec = copyRecord(&rec)
+			return &rec, nil
+		}
+	}
+	r.logger.Error("record not found", zap.String("imageName", name))
+
+	return nil, images.ErrRecordNotFound
+}
+
+// GetByIDPrefix returns up to limit image records whose ID starts with the
+// prefix, ordered by ID. Returns ErrRecordNotFound if no record's ID does.
+func (r *Records) GetByIDPrefix(ctx context.Context, prefix string, limit int) ([]images.Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []images.Record
+	for id := range r.records {
+		if rec := r.records[id]; strings.HasPrefix(id, prefix) {
+			list = append(list, copyRecord(&rec))
+		}
+	}
+	if len(list) == 0 {
+		r.logger.Error("record not found", zap.String("idPrefix", prefix))
+		return nil, images.ErrRecordNotFound
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	if len(list) > limit {
+		list = list[:limit]
+	}
+
+	return list, nil
+}
+
+// List returns a page of the image records ordered by ID. Returns an
+// ErrRecordNotFound if no records are found.
+func (r *Records) List(ctx context.Context, opts images.ListOptions) (*images.Page, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]images.Record, 0, len(r.records))
+	for id := range r.records {
+		rec := r.records[id]
+		list = append(list, copyRecord(&rec))
+	}
+
+	return images.PageRecords(list, opts)
+}
+
+// Count returns the number of records matching the filter.
+func (r *Records) Count(ctx context.Context, filter images.ListFilter) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var n int
+	for id := range r.records {
+		rec := r.records[id]
+		if filter.Match(&rec) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Create adds the given record, returning an error if a record with the same
+// ID already exists.
+func (r *Records) Create(ctx context.Context, record *images.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.records[record.ID]; ok {
+		const msg = "unable to insert image record"
+		err := fmt.Errorf(msg+": record (%s) already exists", record.ID)
+		r.logger.Error(msg, zap.Error(err))
+		return err
+	}
+	now := time.Now().UTC()
+	record.Revision = 1
+	record.UpdatedAt = &now
+	r.records[record.ID] = copyRecord(record)
+
+	return nil
+}
+
+// CreateBatch adds the given records. Returns a BatchError holding the
+// records whose IDs already exist.
+func (r *Records) CreateBatch(ctx context.Context, records []images.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	failed := make(images.BatchError)
+	for i := range records {
+		if _, ok := r.records[records[i].ID]; ok {
+			failed[records[i].ID] = fmt.Errorf("record (%s) already exists", records[i].ID)
+			continue
+		}
+		records[i].Revision = 1
+		records[i].UpdatedAt = &now
+		r.records[records[i].ID] = copyRecord(&records[i])
+	}
+
+	if len(failed) > 0 {
+		r.logger.Error("unable to insert image records", zap.Error(failed))
+		return failed
+	}
+
+	return nil
+}
+
+// Delete removes the record with id. Returns ErrRecordNotFound if no record
+// exists by that ID.
+func (r *Records) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.records[id]; !ok {
+		r.logger.Error("record not found", zap.String("imageId", id))
+		return images.ErrRecordNotFound
+	}
+	delete(r.records, id)
+
+	return nil
+}
+
+// DeleteBatch removes the records with the ids. Returns a BatchError holding
+// the IDs which do not exist.
+func (r *Records) DeleteBatch(ctx context.Context, ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed := make(images.BatchError)
+	for _, id := range ids {
+		if _, ok := r.records[id]; !ok {
+			failed[id] = images.ErrRecordNotFound
+			continue
+		}
+		delete(r.records, id)
+	}
+
+	if len(failed) > 0 {
+		r.logger.Error("unable to delete image records", zap.Error(failed))
+		return failed
+	}
+
+	return nil
+}
+
+// Update replaces the existing record. Returns ErrRecordNotFound if no record
+// exists by that ID and ErrConflict if the revision does not match.
+func (r *Records) Update(ctx context.Context, record *images.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.records[record.ID]
+	if !ok {
+		r.logger.Error("record not found", zap.String("imageId", record.ID))
+		return images.ErrRecordNotFound
+	}
+	if record.Revision != 0 && record.Revision != stored.Revision {
+		r.logger.Error("record revision does not match", zap.String("imageId", record.ID))
+		return images.ErrConflict
+	}
+	now := time.Now().UTC()
+	record.Revision = stored.Revision + 1
+	record.UpdatedAt = &now
+	r.records[record.ID] = copyRecord(record)
+
+	return nil
+}
+
+func copyRecord(rec *images.Record) images.Record {
+	c := *rec
+	if rec.CreatedAt != nil {
+		t := *rec.CreatedAt
+		c.CreatedAt = &t
+	}
+	if rec.UpdatedAt != nil {
+		t := *rec.UpdatedAt
+		c.UpdatedAt = &t
+	}
+	if rec.DeletingAt != nil {
+		t := *rec.DeletingAt
+		c.DeletingAt = &t
+	}
+	if rec.PendingUntil != nil {
+		t := *rec.PendingUntil
+		c.PendingUntil = &t
+	}
+	if rec.Mirrors != nil {
+		c.Mirrors = append([]string(nil), rec.Mirrors...)
+	}
+	if rec.Thumbnails != nil {
+		c.Thumbnails = append([]images.Thumbnail(nil), rec.Thumbnails...)
+	}
+	if rec.Variants != nil {
+		c.Variants = append([]images.Variant(nil), rec.Variants...)
+	}
+	if rec.Tags != nil {
+		c.Tags = append([]string(nil), rec.Tags...)
+	}
+	c.Exif = rec.Exif.Copy()
+	c.Moderation = rec.Moderation.Copy()
+	c.Metadata = copyMap(rec.Metadata)
+	c.ObjectMetadata = copyMap(rec.ObjectMetadata)
+
+	return c
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
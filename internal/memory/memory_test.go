@@ -0,0 +1,262 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_Records(t *testing.T) {
+	now := time.Now().UTC()
+	seed := images.Record{ID: "seed", Name: "seed", CreatedAt: &now}
+	rec := images.Record{ID: "id", Name: "name", Mirrors: []string{"mirror"}, Tags: []string{"vacation"}}
+
+	records, err := NewRecords(zap.NewNop(), seed)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		desc string
+		do   func(t *testing.T)
+	}{
+		{
+			desc: "Get() should return the seeded record",
+			do: func(t *testing.T) {
+				got, err := records.Get(context.Background(), seed.ID)
+				require.NoError(t, err)
+				assert.Equal(t, &seed, got)
+			},
+		},
+		{
+			desc: "Create() should copy the record",
+			do: func(t *testing.T) {
+				in := rec
+				in.Mirrors = []string{"mirror"}
+				require.NoError(t, records.Create(context.Background(), &in))
+				in.Mirrors[0] = "changed"
+				assert.NotZero(t, in.Revision)
+				require.NotNil(t, in.UpdatedAt)
+				rec.Revision = in.Revision
+				rec.UpdatedAt = in.UpdatedAt
+
+				got, err := records.Get(context.Background(), rec.ID)
+				require.NoError(t, err)
+				assert.Equal(t, &rec, got)
+			},
+		},
+		{
+			desc: "Create() should return an error when the record already exists",
+			do: func(t *testing.T) {
+				assert.Error(t, records.Create(context.Background(), &rec))
+			},
+		},
+		{
+			desc: "GetByName() should return the record with the name",
+			do: func(t *testing.T) {
+				got, err := records.GetByName(context.Background(), rec.Name)
+				require.NoError(t, err)
+				assert.Equal(t, &rec, got)
+
+				_, err = records.GetByName(context.Background(), "missing")
+				assert.Equal(t, images.ErrRecordNotFound, err)
+			},
+		},
+		{
+			desc: "List() should return the records ordered by id",
+			do: func(t *testing.T) {
+				page, err := records.List(context.Background(), images.ListOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{rec, seed}, page.Records)
+				assert.Empty(t, page.NextCursor)
+			},
+		},
+		{
+			desc: "List() should page through the records with the cursor",
+			do: func(t *testing.T) {
+				page, err := records.List(context.Background(), images.ListOptions{Limit: 1})
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{rec}, page.Records)
+				assert.Equal(t, rec.ID, page.NextCursor)
+
+				page, err = records.List(context.Background(), images.ListOptions{Limit: 1, Cursor: page.NextCursor})
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{seed}, page.Records)
+				assert.Empty(t, page.NextCursor)
+
+				_, err = records.List(context.Background(), images.ListOptions{Cursor: seed.ID})
+				assert.Equal(t, images.ErrRecordNotFound, err)
+			},
+		},
+		{
+			desc: "List() should only return the records matching the filter",
+			do: func(t *testing.T) {
+				page, err := records.List(context.Background(), images.ListOptions{Filter: images.ListFilter{NamePrefix: "se"}})
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{seed}, page.Records)
+
+				page, err = records.List(context.Background(), images.ListOptions{Filter: images.ListFilter{CreatedBefore: now.Add(time.Second)}})
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{seed}, page.Records)
+
+				_, err = records.List(context.Background(), images.ListOptions{Filter: images.ListFilter{CreatedAfter: now.Add(time.Second)}})
+				assert.Equal(t, images.ErrRecordNotFound, err)
+
+				page, err = records.List(context.Background(), images.ListOptions{Filter: images.ListFilter{UpdatedSince: *rec.UpdatedAt}})
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{rec}, page.Records)
+
+				page, err = records.List(context.Background(), images.ListOptions{Filter: images.ListFilter{Tags: []string{"vacation"}}})
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{rec}, page.Records)
+
+				_, err = records.List(context.Background(), images.ListOptions{Filter: images.ListFilter{Tags: []string{"vacation", "beach"}}})
+				assert.Equal(t, images.ErrRecordNotFound, err)
+			},
+		},
+		{
+			desc: "List() should page through the records in the sort order",
+			do: func(t *testing.T) {
+				opts := images.ListOptions{Limit: 1, Sort: images.SortName, Desc: true}
+				page, err := records.List(context.Background(), opts)
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{seed}, page.Records)
+				require.NotEmpty(t, page.NextCursor)
+
+				opts.Cursor = page.NextCursor
+				page, err = records.List(context.Background(), opts)
+				require.NoError(t, err)
+				assert.Equal(t, []images.Record{rec}, page.Records)
+				assert.Empty(t, page.NextCursor)
+
+				_, err = records.List(context.Background(), images.ListOptions{Sort: "color"})
+				assert.Equal(t, images.ErrInvalidSort, err)
+
+				_, err = records.List(context.Background(), images.ListOptions{Sort: images.SortSize, Cursor: "not a cursor"})
+				assert.Equal(t, images.ErrInvalidCursor, err)
+			},
+		},
+		{
+			desc: "Count() should count the records matching the filter",
+			do: func(t *testing.T) {
+				n, err := records.Count(context.Background(), images.ListFilter{})
+				require.NoError(t, err)
+				assert.Equal(t, 2, n)
+
+				n, err = records.Count(context.Background(), images.ListFilter{NamePrefix: "se"})
+				require.NoError(t, err)
+				assert.Equal(t, 1, n)
+			},
+		},
+		{
+			desc: "Update() should return ErrConflict when the revision is stale",
+			do: func(t *testing.T) {
+				stale := rec
+				stale.Revision++
+				assert.Equal(t, images.ErrConflict, records.Update(context.Background(), &stale))
+			},
+		},
+		{
+			desc: "Update() should replace the record",
+			do: func(t *testing.T) {
+				updated := rec
+				updated.Storage = "archive"
+				require.NoError(t, records.Update(context.Background(), &updated))
+
+				got, err := records.Get(context.Background(), rec.ID)
+				require.NoError(t, err)
+				assert.Equal(t, "archive", got.Storage)
+			},
+		},
+		{
+			desc: "Delete() should remove the record",
+			do: func(t *testing.T) {
+				require.NoError(t, records.Delete(context.Background(), rec.ID))
+				assert.Equal(t, images.ErrRecordNotFound, records.Delete(context.Background(), rec.ID))
+				assert.Equal(t, images.ErrRecordNotFound, records.Update(context.Background(), &rec))
+
+				_, err := records.Get(context.Background(), rec.ID)
+				assert.Equal(t, images.ErrRecordNotFound, err)
+			},
+		},
+	} {
+		if !t.Run(tc.desc, tc.do) {
+			t.Fatalf("test ('%s') failed", tc.desc)
+		}
+	}
+}
+
+func Test_Records_Concurrent(t *testing.T) {
+	records, err := NewRecords(zap.NewNop())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := strconv.Itoa(i)
+			assert.NoError(t, records.Create(context.Background(), &images.Record{ID: id}))
+			_, err := records.Get(context.Background(), id)
+			assert.NoError(t, err)
+			_, err = records.List(context.Background(), images.ListOptions{})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	list, err := images.ListAll(context.Background(), records)
+	require.NoError(t, err)
+	assert.Len(t, list, 50)
+}
+
+func Test_Records_CreateBatch(t *testing.T) {
+	records, err := NewRecords(zap.NewNop(), images.Record{ID: "a"})
+	require.NoError(t, err)
+
+	batch := []images.Record{{ID: "a"}, {ID: "b"}}
+	err = records.CreateBatch(context.Background(), batch)
+	var batchErr images.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Contains(t, batchErr, "a")
+	assert.NotContains(t, batchErr, "b")
+
+	got, err := records.Get(context.Background(), "b")
+	require.NoError(t, err)
+	assert.Equal(t, &batch[1], got)
+}
+
+func Test_Records_DeleteBatch(t *testing.T) {
+	records, err := NewRecords(zap.NewNop(), images.Record{ID: "a"}, images.Record{ID: "b"})
+	require.NoError(t, err)
+
+	require.NoError(t, records.DeleteBatch(context.Background(), []string{"a"}))
+	err = records.DeleteBatch(context.Background(), []string{"a", "b"})
+	assert.Equal(t, images.BatchError{"a": images.ErrRecordNotFound}, err)
+
+	n, err := records.Count(context.Background(), images.ListFilter{})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func Test_Records_GetByIDPrefix(t *testing.T) {
+	records, err := NewRecords(zap.NewNop(), images.Record{ID: "abc2"}, images.Record{ID: "abc1"}, images.Record{ID: "abd"})
+	require.NoError(t, err)
+
+	list, err := records.GetByIDPrefix(context.Background(), "abc", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []images.Record{{ID: "abc1"}, {ID: "abc2"}}, list)
+
+	list, err = records.GetByIDPrefix(context.Background(), "ab", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []images.Record{{ID: "abc1"}}, list)
+
+	_, err = records.GetByIDPrefix(context.Background(), "x", 10)
+	assert.Equal(t, images.ErrRecordNotFound, err)
+}
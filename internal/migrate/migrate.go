@@ -0,0 +1,172 @@
+// Package migrate applies versioned changes, such as creating collections and
+// indexes, to the database holding the image records. Applied migrations are
+// recorded in the database so that each is only applied once.
+package migrate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const loggerName = "migrate"
+
+// Migration represents a versioned change to the database. Up must be
+// idempotent as a migration which fails to be recorded is applied again.
+type Migration struct {
+	// Version orders the migrations, it must be unique and greater than zero
+	Version int
+
+	// Description is a short summary of the change
+	Description string
+
+	// Up applies the change
+	Up func() error
+}
+
+// Applied represents a migration which has been applied to the database.
+type Applied struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	AppliedAt   time.Time `json:"appliedAt"`
+}
+
+// Store provides the means to record the applied migrations.
+type Store interface {
+	// Applied returns the migrations which have been applied.
+	Applied() ([]Applied, error)
+
+	// Record records the migration as applied.
+	Record(applied Applied) error
+}
+
+// Runner applies the pending migrations in order of their version.
+type Runner struct {
+	logger     *zap.Logger
+	migrations []Migration
+	store      Store
+}
+
+// NewRunner returns an instantiated instance of a migration runner which has
+// the following dependencies:
+//
+// logger: for structured logging
+//
+// store: records the applied migrations
+//
+// migrations: the migrations to apply, ordered by version
+func NewRunner(logger *zap.Logger, store Store, migrations []Migration) (*Runner, error) {
+	r := Runner{
+		logger:     logger.Named(loggerName),
+		migrations: migrations,
+		store:      store,
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Debug("successfully initialized migration runner")
+
+	return &r, nil
+}
+
+func (r *Runner) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "logger",
+			chk: func() bool { return r.logger != nil },
+		},
+		{
+			dep: "store",
+			chk: func() bool { return r.store != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize runner due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	for i := range r.migrations {
+		if r.migrations[i].Version <= 0 || (i > 0 && r.migrations[i].Version <= r.migrations[i-1].Version) {
+			return fmt.Errorf("migration (%d) is out of order", r.migrations[i].Version)
+		}
+	}
+
+	return nil
+}
+
+// Pending returns the migrations which have not been applied.
+func (r *Runner) Pending() ([]Migration, error) {
+	applied, err := r.store.Applied()
+	if err != nil {
+		const msg = "unable to get applied migrations"
+		r.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	done := make(map[int]bool, len(applied))
+	for i := range applied {
+		done[applied[i].Version] = true
+	}
+
+	var pending []Migration
+	for i := range r.migrations {
+		if !done[r.migrations[i].Version] {
+			pending = append(pending, r.migrations[i])
+		}
+	}
+
+	return pending, nil
+}
+
+// Run applies the pending migrations in order, stopping at the first
+// failure. Returns the migrations applied by this run.
+func (r *Runner) Run() ([]Applied, error) {
+	pending, err := r.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Applied
+	for i := range pending {
+		m := &pending[i]
+		logger := r.logger.With(zap.Int("version", m.Version), zap.String("description", m.Description))
+
+		if err := m.Up(); err != nil {
+			const msg = "unable to apply migration"
+			logger.Error(msg, zap.Error(err))
+			return applied, fmt.Errorf(msg+" (%d): %w", m.Version, err)
+		}
+
+		a := Applied{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedAt:   time.Now().UTC(),
+		}
+		if err := r.store.Record(a); err != nil {
+			const msg = "unable to record migration"
+			logger.Error(msg, zap.Error(err))
+			return applied, fmt.Errorf(msg+" (%d): %w", m.Version, err)
+		}
+		applied = append(applied, a)
+		logger.Info("successfully applied migration")
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type store struct {
+	applied []Applied
+}
+
+func (s *store) Applied() ([]Applied, error) { return s.applied, nil }
+
+func (s *store) Record(a Applied) error {
+	s.applied = append(s.applied, a)
+	return nil
+}
+
+func Test_Runner_Run(t *testing.T) {
+	var ran []int
+	migration := func(version int, err error) Migration {
+		return Migration{
+			Version: version,
+			Up: func() error {
+				ran = append(ran, version)
+				return err
+			},
+		}
+	}
+
+	t.Run("Run() should apply the pending migrations in order", func(t *testing.T) {
+		ran = nil
+		s := &store{applied: []Applied{{Version: 1}}}
+		r, err := NewRunner(zap.NewNop(), s, []Migration{migration(1, nil), migration(2, nil), migration(3, nil)})
+		require.NoError(t, err)
+
+		applied, err := r.Run()
+		require.NoError(t, err)
+		assert.Len(t, applied, 2)
+		assert.Equal(t, []int{2, 3}, ran)
+
+		pending, err := r.Pending()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("Run() should stop at the first failing migration", func(t *testing.T) {
+		ran = nil
+		s := new(store)
+		r, err := NewRunner(zap.NewNop(), s, []Migration{migration(1, nil), migration(2, errors.New("random")), migration(3, nil)})
+		require.NoError(t, err)
+
+		applied, err := r.Run()
+		assert.Error(t, err)
+		assert.Len(t, applied, 1)
+		assert.Equal(t, []int{1, 2}, ran)
+		assert.Len(t, s.applied, 1)
+	})
+
+	t.Run("NewRunner() should reject migrations out of order", func(t *testing.T) {
+		_, err := NewRunner(zap.NewNop(), new(store), []Migration{migration(2, nil), migration(1, nil)})
+		assert.Error(t, err)
+	})
+}
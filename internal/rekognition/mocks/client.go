@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/itsHabib/sim/internal/rekognition (interfaces: Client)
+
+// Package mock_rekognition is a generated GoMock package.
+package mock_rekognition
+
+import (
+	context "context"
+	reflect "reflect"
+
+	rekognition "github.com/aws/aws-sdk-go-v2/service/rekognition"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockClient is a mock of Client interface.
+type MockClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientMockRecorder
+}
+
+// MockClientMockRecorder is the mock recorder for MockClient.
+type MockClientMockRecorder struct {
+	mock *MockClient
+}
+
+// NewMockClient creates a new mock instance.
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
+	return m.recorder
+}
+
+// DetectModerationLabels mocks base method.
+func (m *MockClient) DetectModerationLabels(arg0 context.Context, arg1 *rekognition.DetectModerationLabelsInput, arg2 ...func(*rekognition.Options)) (*rekognition.DetectModerationLabelsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DetectModerationLabels", varargs...)
+	ret0, _ := ret[0].(*rekognition.DetectModerationLabelsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DetectModerationLabels indicates an expected call of DetectModerationLabels.
+func (mr *MockClientMockRecorder) DetectModerationLabels(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetectModerationLabels", reflect.TypeOf((*MockClient)(nil).DetectModerationLabels), varargs...)
+}
@@ -0,0 +1,166 @@
+package rekognition
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/client.go github.com/itsHabib/sim/internal/rekognition Client
+
+const (
+	loggerName = "rekognition.moderator"
+
+	// DefaultMinConfidence is the confidence from 0 to 100 below which
+	// Rekognition does not return a moderation label
+	DefaultMinConfidence = 50
+)
+
+// Client provides an abstraction to aid in mocking for unit tests
+type Client interface {
+	// DetectModerationLabels detects unsafe content in a specified JPEG or
+	// PNG format image.
+	DetectModerationLabels(ctx context.Context, params *rekognition.DetectModerationLabelsInput, optFns ...func(*rekognition.Options)) (*rekognition.DetectModerationLabelsOutput, error)
+}
+
+// Moderator provides the Rekognition implementation of the images.Moderator.
+type Moderator struct {
+	client        Client
+	clientOpts    []func(*rekognition.Options)
+	configGetter  images.ConfigGetter
+	logger        *zap.Logger
+	minConfidence float64
+}
+
+// Option provides the means to configure optional behavior of the
+// moderator.
+type Option func(m *Moderator)
+
+// WithEndpoint calls the Rekognition API at the endpoint instead of the
+// endpoint of the region, i.e. localstack.
+func WithEndpoint(endpoint string) Option {
+	return func(m *Moderator) {
+		m.clientOpts = append(m.clientOpts, func(o *rekognition.Options) {
+			o.EndpointResolver = rekognition.EndpointResolverFromURL(endpoint)
+		})
+	}
+}
+
+// WithMinConfidence only flags images for the labels Rekognition is at
+// least as confident of, from 0 to 100, instead of DefaultMinConfidence.
+func WithMinConfidence(confidence float64) Option {
+	return func(m *Moderator) {
+		m.minConfidence = confidence
+	}
+}
+
+// NewModerator returns an instantiated instance of a moderator which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// configGetter: for loading the AWS config holding the credentials and region
+func NewModerator(logger *zap.Logger, configGetter images.ConfigGetter, opts ...Option) (*Moderator, error) {
+	m := Moderator{
+		configGetter:  configGetter,
+		logger:        logger.Named(loggerName),
+		minConfidence: DefaultMinConfidence,
+	}
+	for i := range opts {
+		opts[i](&m)
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := m.configGetter()
+	if err != nil {
+		const msg = "unable to get AWS config"
+		m.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	m.client = rekognition.NewFromConfig(cfg, m.clientOpts...)
+
+	m.logger.Debug("successfully initialized rekognition moderator")
+
+	return &m, nil
+}
+
+func (m *Moderator) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "logger",
+			chk: func() bool { return m.logger != nil },
+		},
+		{
+			dep: "configGetter",
+			chk: func() bool { return m.configGetter != nil },
+		},
+		{
+			dep: "min confidence between 0 and 100",
+			chk: func() bool { return m.minConfidence >= 0 && m.minConfidence <= 100 },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize moderator due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Moderate detects the moderation labels of the JPEG image, which must be at
+// most 5MB.
+func (m *Moderator) Moderate(ctx context.Context, jpeg []byte) (*images.Moderation, error) {
+	input := rekognition.DetectModerationLabelsInput{
+		Image:         &types.Image{Bytes: jpeg},
+		MinConfidence: aws.Float32(float32(m.minConfidence)),
+	}
+	out, err := m.client.DetectModerationLabels(ctx, &input)
+	if err != nil {
+		const msg = "unable to detect moderation labels"
+		m.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	moderation := images.Moderation{ModelVersion: aws.ToString(out.ModerationModelVersion)}
+	for _, l := range out.ModerationLabels {
+		moderation.Labels = append(moderation.Labels, images.ModerationLabel{
+			Name:       aws.ToString(l.Name),
+			Parent:     aws.ToString(l.ParentName),
+			Confidence: confidence(l.Confidence),
+		})
+	}
+	m.logger.Debug("detected moderation labels", zap.Int("labels", len(moderation.Labels)))
+
+	return &moderation, nil
+}
+
+// confidence returns the float32 confidence as the float64 of its shortest
+// decimal, i.e. 88.1 rather than 88.0999984741211.
+func confidence(c *float32) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(aws.ToFloat32(c)), 'g', -1, 32), 64)
+	return f
+}
@@ -0,0 +1,115 @@
+package rekognition
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_rekognition "github.com/itsHabib/sim/internal/rekognition/mocks"
+)
+
+func Test_NewModerator(t *testing.T) {
+	for _, tc := range []struct {
+		desc         string
+		configGetter images.ConfigGetter
+		opts         []Option
+		wantErr      bool
+	}{
+		{
+			desc:         "NewModerator() should return an error when failing to get the config",
+			configGetter: func() (aws.Config, error) { return aws.Config{}, errors.New("random") },
+			wantErr:      true,
+		},
+		{
+			desc:         "NewModerator() should return an error when the min confidence is out of range",
+			configGetter: func() (aws.Config, error) { return aws.Config{}, nil },
+			opts:         []Option{WithMinConfidence(101)},
+			wantErr:      true,
+		},
+		{
+			desc:         "NewModerator() should create the SDK client",
+			configGetter: func() (aws.Config, error) { return aws.Config{Region: "us-east-1"}, nil },
+			opts:         []Option{WithEndpoint("http://localhost:4566")},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			m, err := NewModerator(zap.NewNop(), tc.configGetter, tc.opts...)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, m.client)
+		})
+	}
+}
+
+func Test_Moderator_Moderate(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		out     *rekognition.DetectModerationLabelsOutput
+		err     error
+		want    *images.Moderation
+		wantErr bool
+	}{
+		{
+			desc: "Moderate() should return the moderation labels Rekognition detects",
+			out: &rekognition.DetectModerationLabelsOutput{
+				ModerationLabels: []types.ModerationLabel{
+					{Name: aws.String("Explicit Nudity"), ParentName: aws.String(""), Confidence: aws.Float32(97.5)},
+					{Name: aws.String("Nudity"), ParentName: aws.String("Explicit Nudity"), Confidence: aws.Float32(88.1)},
+				},
+				ModerationModelVersion: aws.String("6.0"),
+			},
+			want: &images.Moderation{
+				Labels: []images.ModerationLabel{
+					{Name: "Explicit Nudity", Confidence: 97.5},
+					{Name: "Nudity", Parent: "Explicit Nudity", Confidence: 88.1},
+				},
+				ModelVersion: "6.0",
+			},
+		},
+		{
+			desc: "Moderate() should return no labels for a safe image",
+			out:  &rekognition.DetectModerationLabelsOutput{ModerationModelVersion: aws.String("6.0")},
+			want: &images.Moderation{ModelVersion: "6.0"},
+		},
+		{
+			desc:    "Moderate() should return an error when the labels can not be detected",
+			err:     errors.New("random"),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			client := mock_rekognition.NewMockClient(ctrl)
+			client.
+				EXPECT().
+				DetectModerationLabels(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, in *rekognition.DetectModerationLabelsInput, _ ...func(*rekognition.Options)) (*rekognition.DetectModerationLabelsOutput, error) {
+					assert.Equal(t, []byte("jpeg"), in.Image.Bytes)
+					assert.Equal(t, float32(80), aws.ToFloat32(in.MinConfidence))
+					return tc.out, tc.err
+				})
+			m := Moderator{client: client, logger: zap.NewNop(), minConfidence: 80}
+
+			got, err := m.Moderate(context.Background(), []byte("jpeg"))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/imaging"
+)
+
+// downloadPageSize is the number of records listed per page when selecting
+// the images of a batch download.
+const downloadPageSize = 500
+
+// downloadTarget is an image of a batch download and the path it is
+// downloaded to. label is how the image was asked for, its id or name, and
+// err is set when it could not be resolved.
+type downloadTarget struct {
+	label   string
+	imageID string
+	name    string
+	relPath string
+	path    string
+	err     error
+}
+
+// downloadBatch downloads the images selected by --ids, --names, --all or the
+// filter flags into --dir with up to --concurrency downloads running at once,
+// reporting the progress to stderr, and prints a table of the results.
+func (r *Runner) downloadBatch(ctx context.Context) error {
+	byRef := len(r.command.imageIDs) > 0 || len(r.command.imageNames) > 0
+	switch {
+	case r.command.filePath != "":
+		return errors.New("only one of --file or --dir can be set")
+	case byRef && (r.command.all || r.filterSet()):
+		return errors.New("--ids and --names can not be combined with --all or the filter flags")
+	case r.command.offset != 0, r.command.length != 0:
+		return errors.New("--offset and --length can only be used when downloading a single image")
+	case r.command.concurrency < 1:
+		return errors.New("--concurrency must be at least 1")
+	}
+
+	var (
+		targets []downloadTarget
+		err     error
+	)
+	if byRef {
+		targets = r.resolveTargets(ctx)
+	} else if targets, err = r.listTargets(ctx); err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Println("no images to download")
+		return nil
+	}
+	downloadPaths(r.command.dir, targets)
+
+	var (
+		done int
+		jobs = make(chan int)
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < r.command.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if targets[j].err == nil {
+					targets[j].err = r.downloadTo(ctx, targets[j])
+				}
+
+				mu.Lock()
+				done++
+				fmt.Fprintf(os.Stderr, "downloaded %d/%d images\n", done, len(targets))
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return printDownloadResults(targets)
+}
+
+// filterSet reports whether any of the list filter flags are set.
+func (r *Runner) filterSet() bool {
+	c := r.command
+	return c.namePrefix != "" || c.createdAfter != "" || c.createdBefore != "" ||
+		c.capturedAfter != "" || c.capturedBefore != "" || c.since != "" ||
+		c.minSize != "" || c.maxSize != "" || c.storage != "" || len(c.tags) > 0 || c.text != ""
+}
+
+// resolveTargets looks up the images of --ids, which may be unique prefixes
+// of their IDs, and --names. An image asked for more than once is downloaded
+// once.
+func (r *Runner) resolveTargets(ctx context.Context) []downloadTarget {
+	var (
+		targets []downloadTarget
+		seen    = make(map[string]bool)
+	)
+	add := func(label string, rec *images.Record, err error) {
+		if err != nil {
+			targets = append(targets, downloadTarget{label: label, err: err})
+			return
+		}
+		if seen[rec.ID] {
+			return
+		}
+		seen[rec.ID] = true
+		targets = append(targets, downloadTarget{label: label, imageID: rec.ID, name: rec.Name, relPath: rec.Path})
+	}
+	for _, id := range r.command.imageIDs {
+		rec, err := r.svc.Resolve(ctx, id)
+		add(id, rec, err)
+	}
+	for _, name := range r.command.imageNames {
+		rec, err := r.svc.GetByName(ctx, name)
+		add(name, rec, err)
+	}
+
+	return targets
+}
+
+// listTargets lists the images matching the filter flags, every image when
+// none are set.
+func (r *Runner) listTargets(ctx context.Context) ([]downloadTarget, error) {
+	filter, err := r.listFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := images.ListOptions{
+		Limit:  downloadPageSize,
+		Filter: *filter,
+	}
+	var targets []downloadTarget
+	for {
+		list, next, err := r.svc.List(ctx, opts)
+		switch err {
+		case nil:
+		case images.ErrRecordNotFound:
+			return targets, nil
+		default:
+			const msg = "failed to list images"
+			r.logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+
+		for _, img := range list {
+			targets = append(targets, downloadTarget{label: img.ID, imageID: img.ID, name: img.Name, relPath: img.Path})
+		}
+		if next == "" {
+			return targets, nil
+		}
+		opts.Cursor = next
+	}
+}
+
+// downloadPaths sets the path of each target under the directory, its
+// recorded path recreating the directory it was uploaded from or else its
+// name. An image whose path another image of the batch already has gets its
+// id appended, i.e. cat.png becomes cat-<id>.png.
+func downloadPaths(dir string, targets []downloadTarget) {
+	taken := make(map[string]bool)
+	for i := range targets {
+		t := &targets[i]
+		if t.err != nil {
+			continue
+		}
+
+		rel := t.relPath
+		if rel == "" {
+			rel = t.name
+		}
+		p, err := localPath(dir, rel)
+		if err != nil {
+			t.err = err
+			continue
+		}
+		if taken[p] {
+			ext := filepath.Ext(p)
+			p = strings.TrimSuffix(p, ext) + "-" + t.imageID + ext
+		}
+		taken[p] = true
+		t.path = p
+	}
+}
+
+// localPath returns the path of the file named after the image in the
+// directory, the current one when empty. Returns an error wrapping
+// ErrInvalidPath if the name would leave the directory.
+func localPath(dir, name string) (string, error) {
+	rel, err := images.NormalizePath(name)
+	switch {
+	case err != nil:
+		return "", err
+	case rel == "":
+		return "", fmt.Errorf("%w: image has no name", images.ErrInvalidPath)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(rel)), nil
+}
+
+// partialExists reports whether a resumable download to the path left a
+// partial file behind.
+func partialExists(path string) bool {
+	_, err := os.Stat(path + images.PartialSuffix)
+	return err == nil
+}
+
+// downloadTo downloads the image of the target to its path, creating the
+// directories of the path.
+func (r *Runner) downloadTo(ctx context.Context, t downloadTarget) error {
+	logger := r.logger.With(zap.String("filePath", t.path), zap.String("imageId", t.imageID))
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		const msg = "unable to create directory"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if err := r.svc.DownloadFile(ctx, images.DownloadFileRequest{ID: t.imageID, Path: t.path, Overwrite: r.command.force, Resume: r.command.resume}); err != nil {
+		const msg = "unable to download image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// printDownloadResults prints a table of the results and returns an error
+// when any of the images failed to download.
+func printDownloadResults(targets []downloadTarget) error {
+	var failed int
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tSTATUS\tFILE / ERROR")
+	for _, t := range targets {
+		if t.err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\tFAILED\t%s\n", t.label, t.err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\tOK\t%s\n", t.label, t.path)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("unable to download (%d) of (%d) images", failed, len(targets))
+	}
+
+	return nil
+}
+
+// variantRequest returns the variant of --pipeline, or else of --resize,
+// --to and --watermark, at least one of which must be set.
+func (r *Runner) variantRequest() (*images.VariantRequest, error) {
+	switch {
+	case r.command.pipeline != "" && (r.command.resize != "" || r.command.to != "" || r.command.watermark):
+		return nil, errors.New("--pipeline can not be combined with --resize, --to or --watermark")
+	case r.command.pipeline != "":
+		p, err := r.svc.Pipeline(r.command.pipeline)
+		if err != nil {
+			return nil, err
+		}
+		req := p.Variant("")
+		return &req, nil
+	case r.command.resize == "" && r.command.to == "" && !r.command.watermark:
+		return nil, errors.New("one of --resize, --to, --watermark or --pipeline must be set")
+	}
+
+	var req images.VariantRequest
+	if r.command.resize != "" {
+		width, height, err := parseDimensions(r.command.resize)
+		if err != nil {
+			return nil, err
+		}
+		req.Width, req.Height = width, height
+	}
+	req.Format = r.command.to
+	req.Watermark = r.command.watermark
+
+	return &req, nil
+}
+
+// variantName returns the name of the image with the extension of the
+// format it is converted to, its name as is when it is not converted.
+func variantName(name, format string) string {
+	f, ok := imaging.ParseFormat(format)
+	if !ok {
+		return name
+	}
+
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "." + f.Ext()
+}
+
+// parseDimensions parses the WIDTHxHEIGHT of --resize, either of which may
+// be omitted to leave that side unbounded, i.e. 800x600, 800x or x600.
+func parseDimensions(s string) (int, int, error) {
+	invalid := fmt.Errorf("invalid --resize (%s), expected WIDTHxHEIGHT i.e. 800x600, 800x or x600", s)
+
+	parts := strings.Split(strings.ToLower(s), "x")
+	if len(parts) != 2 || parts[0]+parts[1] == "" {
+		return 0, 0, invalid
+	}
+	dims := make([]int, 2)
+	for i, p := range parts {
+		if p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 {
+			return 0, 0, invalid
+		}
+		dims[i] = n
+	}
+
+	return dims[0], dims[1], nil
+}
+
+// downloadVariant downloads the processed image into a temp file next to the
+// path and renames it into place once written, an existing file is only
+// replaced with --force.
+func (r *Runner) downloadVariant(ctx context.Context, req images.VariantRequest, path string, logger *zap.Logger) error {
+	if _, err := os.Stat(path); err == nil && !r.command.force {
+		return fmt.Errorf("file (%s) already exists, use --force to replace it", path)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".sim-variant-*")
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	req.Writer = f
+	if err := r.svc.DownloadVariant(ctx, req); err != nil {
+		const msg = "unable to download processed image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if err := f.Close(); err != nil {
+		const msg = "unable to close temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	// temp files are created readable only by the owner
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		const msg = "unable to set file mode"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		const msg = "unable to move download into place"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	logger.Debug("successfully downloaded processed image")
+	fmt.Printf("successfully downloaded processed image to: (%s)\n", path)
+
+	return nil
+}
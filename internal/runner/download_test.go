@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_downloadPaths(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		targets []downloadTarget
+		want    []string
+		wantErr []bool
+	}{
+		{
+			desc: "downloadPaths() should name the files after the images' names",
+			targets: []downloadTarget{
+				{imageID: "1", name: "cat.png"},
+				{imageID: "2", name: "dog.jpg"},
+			},
+			want:    []string{"cat.png", "dog.jpg"},
+			wantErr: []bool{false, false},
+		},
+		{
+			desc: "downloadPaths() should recreate the directories of the images' paths",
+			targets: []downloadTarget{
+				{imageID: "1", name: "img_001.jpg", relPath: "2023/trip/img_001.jpg"},
+			},
+			want:    []string{filepath.Join("2023", "trip", "img_001.jpg")},
+			wantErr: []bool{false},
+		},
+		{
+			desc: "downloadPaths() should append the id of an image whose path is taken",
+			targets: []downloadTarget{
+				{imageID: "1", name: "cat.png"},
+				{imageID: "2", name: "cat.png"},
+			},
+			want:    []string{"cat.png", "cat-2.png"},
+			wantErr: []bool{false, false},
+		},
+		{
+			desc: "downloadPaths() should fail the images whose name leaves the directory",
+			targets: []downloadTarget{
+				{imageID: "1", name: "../cat.png"},
+				{imageID: "2", name: "dog.jpg"},
+			},
+			want:    []string{"", "dog.jpg"},
+			wantErr: []bool{true, false},
+		},
+		{
+			desc: "downloadPaths() should skip the images which could not be resolved",
+			targets: []downloadTarget{
+				{label: "missing", err: images.ErrRecordNotFound},
+			},
+			want:    []string{""},
+			wantErr: []bool{true},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			downloadPaths(dir, tc.targets)
+			for i, target := range tc.targets {
+				if tc.wantErr[i] {
+					assert.Error(t, target.err)
+					assert.Empty(t, target.path)
+					continue
+				}
+				assert.NoError(t, target.err)
+				assert.Equal(t, filepath.Join(dir, tc.want[i]), target.path)
+			}
+		})
+	}
+}
+
+func Test_localPath(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		dir     string
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "localPath() should name the file after the image in the current directory",
+			name: "cat.png",
+			want: "cat.png",
+		},
+		{
+			desc: "localPath() should name the file after the image in the directory",
+			dir:  "out",
+			name: "trip/cat.png",
+			want: filepath.Join("out", "trip", "cat.png"),
+		},
+		{
+			desc:    "localPath() should return an error if the name leaves the directory",
+			dir:     "out",
+			name:    "../cat.png",
+			wantErr: true,
+		},
+		{
+			desc:    "localPath() should return an error if the image has no name",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := localPath(tc.dir, tc.name)
+			if tc.wantErr {
+				assert.True(t, errors.Is(err, images.ErrInvalidPath))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_parseDimensions(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		s          string
+		wantWidth  int
+		wantHeight int
+		wantErr    bool
+	}{
+		{
+			desc:       "parseDimensions() should parse the width and height",
+			s:          "800x600",
+			wantWidth:  800,
+			wantHeight: 600,
+		},
+		{
+			desc:      "parseDimensions() should leave an omitted height unbounded",
+			s:         "800x",
+			wantWidth: 800,
+		},
+		{
+			desc:       "parseDimensions() should leave an omitted width unbounded",
+			s:          "X600",
+			wantHeight: 600,
+		},
+		{
+			desc:    "parseDimensions() should return an error if both are omitted",
+			s:       "x",
+			wantErr: true,
+		},
+		{
+			desc:    "parseDimensions() should return an error if there is no separator",
+			s:       "800",
+			wantErr: true,
+		},
+		{
+			desc:    "parseDimensions() should return an error if a side is not positive",
+			s:       "0x600",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			width, height, err := parseDimensions(tc.s)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantWidth, width)
+			assert.Equal(t, tc.wantHeight, height)
+		})
+	}
+}
+
+func Test_variantName(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		name string
+		to   string
+		want string
+	}{
+		{
+			desc: "variantName() should replace the extension with the format's",
+			name: "trip/cat.png",
+			to:   "jpeg",
+			want: "trip/cat.jpg",
+		},
+		{
+			desc: "variantName() should keep the name of an image which is not converted",
+			name: "cat.png",
+			want: "cat.png",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, variantName(tc.name, tc.to))
+		})
+	}
+}
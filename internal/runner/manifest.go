@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestMetadataPrefix prefixes the manifest CSV columns which hold the
+// metadata of the images, i.e. the metadata.source column.
+const manifestMetadataPrefix = "metadata."
+
+// manifestEntry is a file listed by an upload manifest.
+type manifestEntry struct {
+	File     string            `json:"file"`
+	Name     string            `json:"name"`
+	Tags     []string          `json:"tags"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// readManifest reads the upload manifest at the path, a JSON array of
+// entries or a CSV file with a header, and returns the files it lists.
+// Relative file paths are resolved against the manifest's directory and
+// names default to the file's basename.
+func readManifest(path string) ([]batchFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []manifestEntry
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		if err := json.NewDecoder(f).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+	case ".csv":
+		if entries, err = parseManifestCSV(f); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q, expected .json or .csv", ext)
+	}
+
+	files := make([]batchFile, len(entries))
+	for i, e := range entries {
+		if e.File == "" {
+			return nil, fmt.Errorf("invalid manifest: entry (%d) has no file", i+1)
+		}
+		file := e.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		name := e.Name
+		if name == "" {
+			name = filepath.Base(file)
+		}
+		files[i] = batchFile{path: file, name: name, tags: e.Tags, metadata: e.Metadata}
+	}
+
+	return files, nil
+}
+
+// parseManifestCSV parses the entries of a CSV manifest. Its header names
+// the file, name and tags columns, tags are separated by semicolons, and
+// each metadata.<key> column holds the value of the metadata key.
+func parseManifestCSV(r io.Reader) ([]manifestEntry, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("missing header")
+	}
+
+	header := rows[0]
+	var hasFile bool
+	for _, col := range header {
+		switch {
+		case col == "file":
+			hasFile = true
+		case col == "name", col == "tags", strings.HasPrefix(col, manifestMetadataPrefix):
+		default:
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+	}
+	if !hasFile {
+		return nil, errors.New("missing file column")
+	}
+
+	entries := make([]manifestEntry, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		var e manifestEntry
+		for i, col := range header {
+			v := strings.TrimSpace(row[i])
+			switch {
+			case col == "file":
+				e.File = v
+			case col == "name":
+				e.Name = v
+			case col == "tags":
+				for _, tag := range strings.Split(v, ";") {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						e.Tags = append(e.Tags, tag)
+					}
+				}
+			case v != "":
+				if e.Metadata == nil {
+					e.Metadata = make(map[string]string)
+				}
+				e.Metadata[strings.TrimPrefix(col, manifestMetadataPrefix)] = v
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
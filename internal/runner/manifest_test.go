@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readManifest(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		file     string
+		manifest string
+		want     func(dir string) []batchFile
+		wantErr  bool
+	}{
+		{
+			desc:     "readManifest() should read the entries of a JSON manifest",
+			file:     "manifest.json",
+			manifest: `[{"file": "a.jpg", "name": "first", "tags": ["trip"], "metadata": {"source": "cms"}}, {"file": "/abs/b.png"}]`,
+			want: func(dir string) []batchFile {
+				return []batchFile{
+					{path: filepath.Join(dir, "a.jpg"), name: "first", tags: []string{"trip"}, metadata: map[string]string{"source": "cms"}},
+					{path: "/abs/b.png", name: "b.png"},
+				}
+			},
+		},
+		{
+			desc:     "readManifest() should read the entries of a CSV manifest",
+			file:     "manifest.csv",
+			manifest: "file,name,tags,metadata.source\na.jpg,first,trip; beach,cms\nb.png,,,\n",
+			want: func(dir string) []batchFile {
+				return []batchFile{
+					{path: filepath.Join(dir, "a.jpg"), name: "first", tags: []string{"trip", "beach"}, metadata: map[string]string{"source": "cms"}},
+					{path: filepath.Join(dir, "b.png"), name: "b.png"},
+				}
+			},
+		},
+		{
+			desc:     "readManifest() should reject a CSV manifest with an unknown column",
+			file:     "manifest.csv",
+			manifest: "file,source\na.jpg,cms\n",
+			wantErr:  true,
+		},
+		{
+			desc:     "readManifest() should reject an entry without a file",
+			file:     "manifest.json",
+			manifest: `[{"name": "first"}]`,
+			wantErr:  true,
+		},
+		{
+			desc:     "readManifest() should reject unsupported formats",
+			file:     "manifest.txt",
+			manifest: "a.jpg",
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "manifest")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, tc.file)
+			require.NoError(t, ioutil.WriteFile(path, []byte(tc.manifest), 0o644))
+
+			got, err := readManifest(path)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want(dir), got)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_Runner_Rename(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		name     string
+		wantName string
+		errIs    error
+		wantErr  bool
+	}{
+		{
+			desc:     "rename should rename the image",
+			name:     "c.png",
+			wantName: "c.png",
+		},
+		{
+			desc:     "rename should return ErrNameTaken when another image has the name",
+			name:     "b.png",
+			wantName: "a.png",
+			errIs:    images.ErrNameTaken,
+			wantErr:  true,
+		},
+		{
+			desc:     "rename should reject an empty name",
+			wantName: "a.png",
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			r, records := newTestRunner(t)
+			for _, rec := range []images.Record{{ID: "a", Name: "a.png"}, {ID: "b", Name: "b.png"}} {
+				rec := rec
+				require.NoError(t, records.Create(context.Background(), &rec))
+			}
+
+			err := run(r, "rename", "--imageId", "a", "--name", tc.name)
+			if tc.wantErr {
+				assert.Error(t, err)
+				if tc.errIs != nil {
+					assert.True(t, errors.Is(err, tc.errIs), err)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+
+			rec, err := records.Get(context.Background(), "a")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantName, rec.Name)
+		})
+	}
+}
@@ -1,54 +1,306 @@
 package runner
 
 import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
+	"github.com/itsHabib/sim/internal/audit"
+	"github.com/itsHabib/sim/internal/couchbase"
 	"github.com/itsHabib/sim/internal/images"
 	"github.com/itsHabib/sim/internal/images/service"
+	"github.com/itsHabib/sim/internal/imaging"
+	"github.com/itsHabib/sim/internal/migrate"
 )
 
+// activityFollowInterval is how often the activity log is polled for new
+// activities when following it.
+const activityFollowInterval = time.Second * 2
+
+// restorePollInterval is how often the status of a restore is checked when
+// waiting for it.
+const restorePollInterval = time.Second * 30
+
+// activityTimeout bounds recording the activity of a command, which is done
+// on a context of its own so that interrupted commands are recorded too.
+const activityTimeout = time.Second * 10
+
+// urlClient fetches the images of --url. Only connecting and waiting for the
+// response are bounded, the body is streamed into storage for as long as it
+// takes.
+var urlClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   time.Second * 30,
+			KeepAlive: time.Second * 30,
+		}).DialContext,
+		TLSHandshakeTimeout:   time.Second * 10,
+		ResponseHeaderTimeout: time.Second * 30,
+	},
+}
+
 // Runner is responsible for running the cobra commands that interact
 // with the images service.
 type Runner struct {
-	logger  *zap.Logger
-	command *command
-	svc     *service.Service
+	activity   audit.ActivityLog
+	actor      string
+	backends   BackendOpener
+	history    audit.Store
+	indexes    IndexesOpener
+	logger     *zap.Logger
+	command    *command
+	migrations MigrationsOpener
+	svc        *service.Service
+}
+
+// BackendOpener opens the image record reader and writer of the named
+// metadata backend.
+type BackendOpener func(name string) (images.Reader, images.Writer, error)
+
+// MigrationsOpener opens the migration runner of the metadata backend.
+type MigrationsOpener func() (*migrate.Runner, error)
+
+// IndexesOpener opens the index manager of the couchbase collection.
+type IndexesOpener func() (*couchbase.Indexes, error)
+
+// Option provides the means to configure optional behavior of the runner.
+type Option func(r *Runner)
+
+// WithBackends enables the commands which operate across metadata backends
+// i.e. migrate-db.
+func WithBackends(open BackendOpener) Option {
+	return func(r *Runner) {
+		r.backends = open
+	}
+}
+
+// WithActivity records every command run in the activity log as made by the
+// actor and enables the activity command.
+func WithActivity(log audit.ActivityLog, actor string) Option {
+	return func(r *Runner) {
+		r.activity = log
+		r.actor = actor
+	}
+}
+
+// WithHistory enables the history command, reading the audit events from
+// the store.
+func WithHistory(store audit.Store) Option {
+	return func(r *Runner) {
+		r.history = store
+	}
+}
+
+// WithIndexes enables the ensure-indexes command for the couchbase backend.
+func WithIndexes(open IndexesOpener) Option {
+	return func(r *Runner) {
+		r.indexes = open
+	}
+}
+
+// WithMigrations enables the migrate command for backends which need their
+// collections and indexes created.
+func WithMigrations(open MigrationsOpener) Option {
+	return func(r *Runner) {
+		r.migrations = open
+	}
 }
 
-func NewRunner(logger *zap.Logger, svc *service.Service) *Runner {
+func NewRunner(logger *zap.Logger, svc *service.Service, opts ...Option) *Runner {
 	r := Runner{
 		logger:  logger,
 		svc:     svc,
 		command: new(command),
 	}
+	for i := range opts {
+		opts[i](&r)
+	}
 	r.registerCommands()
 
 	return &r
 }
 
-// Run executes the underlying root cobra command.
-func (r *Runner) Run() error {
-	return r.command.root.Execute()
+// Run executes the underlying root cobra command. Cancelling the context
+// cancels the command's calls to the service.
+func (r *Runner) Run(ctx context.Context) error {
+	return r.command.root.ExecuteContext(ctx)
 }
 
 func (r *Runner) registerCommands() {
 	r.command.root = rootCmd()
 
 	r.command.root.AddCommand(
+		r.activityCommand(),
+		r.confirmUploadCommand(),
+		r.convertCommand(),
+		r.countCommand(),
 		r.deleteCommand(),
 		r.downloadCommand(),
+		r.ensureIndexesCommand(),
+		r.findCommand(),
+		r.fsckCommand(),
+		r.gcCommand(),
+		r.getCommand(),
+		r.historyCommand(),
 		r.listCommand(),
+		r.migrateCommand(),
+		r.migrateDBCommand(),
+		r.migrateStorageCommand(),
+		r.presignUploadCommand(),
+		r.reconcileDeletesCommand(),
+		r.renameCommand(),
+		r.repairCommand(),
+		r.restoreCommand(),
+		r.searchCommand(),
+		r.srcsetCommand(),
+		r.tagCommand(),
 		r.uploadCommand(),
+		r.urlCommand(),
+		r.verifyCommand(),
 	)
+	if r.activity != nil {
+		r.recordActivity(r.command.root)
+	}
+}
+
+// recordActivity wraps the commands under c so that every run is recorded in
+// the activity log, except for reading the log itself.
+func (r *Runner) recordActivity(c *cobra.Command) {
+	for _, sub := range c.Commands() {
+		r.recordActivity(sub)
+	}
+	if c.RunE == nil || c.Name() == "activity" {
+		return
+	}
+
+	run := c.RunE
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		err := run(cmd, args)
+
+		subject := r.command.subject
+		if subject == "" {
+			subject = r.command.imageID
+		}
+		a := audit.NewActivity(r.actor, commandName(cmd), subject, time.Now(), err)
+		ctx, cancel := context.WithTimeout(context.Background(), activityTimeout)
+		defer cancel()
+		if recErr := r.activity.Record(ctx, a); recErr != nil {
+			r.logger.Error("unable to record activity", zap.String("command", a.Command), zap.Error(recErr))
+		}
+
+		return err
+	}
+}
+
+// commandName returns the path of the command below the root, i.e. "tag add".
+func commandName(c *cobra.Command) string {
+	var names []string
+	for ; c.HasParent(); c = c.Parent() {
+		names = append([]string{c.Name()}, names...)
+	}
+
+	return strings.Join(names, " ")
+}
+
+func (r *Runner) activityCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "activity",
+		Short: "Show the latest commands run, who ran them and how they ended.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runActivityCommand,
+	}
+	c.Flags().IntVarP(&r.command.activityLimit, "limit", "", 20, "Maximum number of activities to show, all are shown when 0")
+	c.Flags().StringVarP(&r.command.actor, "actor", "", "", "Only show the commands run by the actor")
+	c.Flags().StringVarP(&r.command.commandName, "command", "", "", "Only show the runs of the command, i.e. 'tag add'")
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Only show the commands run on the image")
+	c.Flags().BoolVarP(&r.command.failed, "failed", "", false, "Only show the commands which failed")
+	c.Flags().BoolVarP(&r.command.follow, "follow", "", false, "Keep showing new activities as they are recorded")
+
+	return &c
+}
+
+func (r *Runner) confirmUploadCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "confirm-upload",
+		Short: "Complete the pending image of a presigned upload once its object was uploaded.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runConfirmUploadCommand,
+	}
+
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the pending image (required)")
+	c.MarkFlagRequired("imageId")
+
+	return &c
+}
+
+func (r *Runner) convertCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "convert",
+		Short: "Store a copy of the image converted to another format, or resized, next to it.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runConvertCommand,
+	}
+
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to convert")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image to convert, alternative to --imageId")
+	c.Flags().StringVarP(&r.command.to, "to", "", "", "Format to convert the image to, jpeg, png, gif or webp (defaults to the image's format)")
+	c.Flags().StringVarP(&r.command.resize, "resize", "", "", "Scale the image down to fit WIDTHxHEIGHT pixels, i.e. 800x600, 800x or x600, keeping its aspect ratio")
+	c.Flags().StringVarP(&r.command.pipeline, "pipeline", "", "", "Name of the configured pipeline to apply, alternative to --to, --resize and --watermark")
+	c.Flags().BoolVarP(&r.command.watermark, "watermark", "", false, "Overlay the configured watermark on the image")
+
+	return &c
+}
+
+func (r *Runner) countCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "count",
+		Short: "Count the images",
+		Args:  cobra.NoArgs,
+		RunE:  r.runCountCommand,
+	}
+	r.addFilterFlags(&c, "count")
+
+	return &c
+}
+
+// addFilterFlags adds the flags of the list filter to the command, verb
+// describes what the command does with the matching images.
+func (r *Runner) addFilterFlags(c *cobra.Command, verb string) {
+	c.Flags().StringVarP(&r.command.namePrefix, "name-prefix", "", "", "Only "+verb+" images whose name starts with the prefix")
+	c.Flags().StringVarP(&r.command.createdAfter, "created-after", "", "", "Only "+verb+" images created at or after the RFC 3339 time")
+	c.Flags().StringVarP(&r.command.createdBefore, "created-before", "", "", "Only "+verb+" images created before the RFC 3339 time")
+	c.Flags().StringVarP(&r.command.capturedAfter, "captured-after", "", "", "Only "+verb+" photos taken at or after the RFC 3339 time, according to their EXIF")
+	c.Flags().StringVarP(&r.command.capturedBefore, "captured-before", "", "", "Only "+verb+" photos taken before the RFC 3339 time, according to their EXIF")
+	c.Flags().StringVarP(&r.command.since, "since", "", "", "Only "+verb+" images created or updated at or after the RFC 3339 time")
+	c.Flags().StringVarP(&r.command.minSize, "min-size", "", "", "Only "+verb+" images of at least this size, in bytes or with a unit i.e. 1.5MB")
+	c.Flags().StringVarP(&r.command.maxSize, "max-size", "", "", "Only "+verb+" images of at most this size, in bytes or with a unit i.e. 1.5MB")
+	c.Flags().StringVarP(&r.command.storage, "storage", "", "", "Only "+verb+" images held in the storage profile")
+	c.Flags().StringSliceVarP(&r.command.tags, "tag", "", nil, "Only "+verb+" images with the tag, repeat to require several tags")
+	c.Flags().StringVarP(&r.command.text, "text", "", "", "Only "+verb+" images whose text extracted at upload contains the text")
 }
 
 func (r *Runner) deleteCommand() *cobra.Command {
@@ -59,8 +311,8 @@ func (r *Runner) deleteCommand() *cobra.Command {
 		RunE:  r.runDeleteCommand,
 	}
 
-	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to download (required)")
-	c.MarkFlagRequired("imageId")
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to delete")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image to delete, alternative to --imageId")
 
 	return &c
 }
@@ -73,165 +325,1933 @@ func (r *Runner) downloadCommand() *cobra.Command {
 		RunE:  r.runDownloadCommand,
 	}
 
-	c.Flags().StringVarP(&r.command.filePath, "file", "f", "", "Path to download the file into (required)")
-	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to download (required)")
+	c.Flags().StringVarP(&r.command.filePath, "file", "f", "", "Path to download the file into, - for stdout (defaults to the image's name in the current directory or --dir)")
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to download")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image to download, alternative to --imageId")
+	c.Flags().StringVarP(&r.command.dir, "dir", "", "", "Directory to download the image, or several images, into under their paths or names, alternative to --file")
+	c.Flags().StringSliceVarP(&r.command.imageIDs, "ids", "", nil, "Ids of the images to download into --dir")
+	c.Flags().StringSliceVarP(&r.command.imageNames, "names", "", nil, "Names of the images to download into --dir")
+	c.Flags().BoolVarP(&r.command.all, "all", "", false, "Download every image, or every image matching the filter flags, into --dir")
+	c.Flags().IntVarP(&r.command.concurrency, "concurrency", "", 4, "Number of images downloaded at once by a batch download")
+	c.Flags().BoolVarP(&r.command.force, "force", "", false, "Replace the files which already exist instead of failing")
+	c.Flags().BoolVarP(&r.command.resume, "resume", "", false, "Keep the download in a .part file next to the path, rerunning an interrupted download continues it")
+	c.Flags().Int64VarP(&r.command.offset, "offset", "", 0, "Byte offset of the image to start the download at, i.e. to fetch part of a large file")
+	c.Flags().Int64VarP(&r.command.length, "length", "", 0, "Number of bytes to download from --offset (defaults to the rest of the image)")
+	c.Flags().StringVarP(&r.command.resize, "resize", "", "", "Scale the image down to fit WIDTHxHEIGHT pixels, i.e. 800x600, 800x or x600, keeping its aspect ratio")
+	c.Flags().StringVarP(&r.command.to, "to", "", "", "Format to convert the image to, jpeg, png, gif or webp")
+	c.Flags().StringVarP(&r.command.pipeline, "pipeline", "", "", "Name of the configured pipeline to apply, alternative to --to, --resize and --watermark")
+	c.Flags().BoolVarP(&r.command.watermark, "watermark", "", false, "Overlay the configured watermark on the image")
+	c.Flags().BoolVarP(&r.command.cache, "cache", "", false, "Store the resized or converted image next to the image so that the next download of it is served from storage")
+	r.addFilterFlags(&c, "download")
+
+	return &c
+}
+
+func (r *Runner) fsckCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "fsck",
+		Short: "Check every image record against its object and report the inconsistencies as JSON.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runFsckCommand,
+	}
+	r.addFilterFlags(&c, "check")
+	c.Flags().StringVarP(&r.command.reportPath, "output", "o", "", "Write the report to the file instead of stdout, for the repair command")
+
+	return &c
+}
+
+func (r *Runner) gcCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "gc",
+		Short: "Delete the objects no image points to and the images whose object is gone.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runGCCommand,
+	}
+	c.Flags().StringVarP(&r.command.storage, "storage", "", "", "Only collect the storage profile, every profile is collected when empty")
+	c.Flags().DurationVarP(&r.command.gcGrace, "grace", "", time.Hour, "Skip the objects written less than this long ago as their upload may still be running")
+	c.Flags().BoolVarP(&r.command.dryRun, "dry-run", "", false, "Report the orphaned objects and dangling images without deleting them")
+
+	return &c
+}
+
+func (r *Runner) getCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "get",
+		Short: "Get the image record.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runGetCommand,
+	}
+
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to get")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image to get, alternative to --imageId")
+
+	return &c
+}
+
+func (r *Runner) historyCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "history",
+		Short: "Show the changes made to an image, including deleted images.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runHistoryCommand,
+	}
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image (required)")
 	c.MarkFlagRequired("imageId")
-	c.MarkFlagRequired("file")
 
 	return &c
 }
 
 func (r *Runner) listCommand() *cobra.Command {
-	return &cobra.Command{
+	c := cobra.Command{
 		Use:   "list",
 		Short: "List all images",
 		Args:  cobra.NoArgs,
 		RunE:  r.runListCommand,
 	}
+	c.Flags().IntVarP(&r.command.limit, "limit", "", 0, "Maximum number of images to list, all images are listed when 0")
+	c.Flags().StringVarP(&r.command.cursor, "cursor", "", "", "Cursor of the page to list, as printed by a previous list")
+	r.addFilterFlags(&c, "list")
+	c.Flags().StringVarP(&r.command.sort, "sort", "", "", "Field to sort the images by: name, size or createdAt (defaults to id)")
+	c.Flags().BoolVarP(&r.command.desc, "desc", "", false, "Sort the images in descending order")
+
+	return &c
 }
 
-func (r *Runner) uploadCommand() *cobra.Command {
+func (r *Runner) repairCommand() *cobra.Command {
 	c := cobra.Command{
-		Use:   "upload",
-		Short: "Upload an image",
+		Use:   "repair",
+		Short: "Fix the inconsistencies reported by fsck.",
+		Long: "Fix the inconsistencies of an fsck report, or of an fsck run inline using the list filter flags. " +
+			"Records are refreshed from their objects, mirrors without a copy are dropped and, when asked, " +
+			"records whose object is missing are deleted.",
+		Args: cobra.NoArgs,
+		RunE: r.runRepairCommand,
+	}
+	r.addFilterFlags(&c, "repair")
+	c.Flags().StringVarP(&r.command.reportPath, "report", "", "", "Path to the report written by fsck -o, fsck is run inline when empty")
+	c.Flags().BoolVarP(&r.command.removeMissing, "remove-missing", "", false, "Delete the images whose object is missing")
+	c.Flags().BoolVarP(&r.command.checksums, "checksums", "", false, "Compute the missing SHA-256 checksums, downloading each object")
+	c.Flags().BoolVarP(&r.command.yes, "yes", "y", false, "Do not ask for confirmation before deleting images")
+
+	return &c
+}
+
+func (r *Runner) restoreCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "restore",
+		Short: "Restore an archived image, i.e. one in GLACIER, so that it can be downloaded.",
 		Args:  cobra.NoArgs,
-		RunE:  r.runUploadCommand,
+		RunE:  r.runRestoreCommand,
 	}
-	c.Flags().StringVarP(&r.command.filePath, "file", "f", "", "Path to the image file (required)")
-	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name for the image (required)")
-	c.MarkFlagRequired("file")
-	c.MarkFlagRequired("name")
+
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to restore")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image to restore, alternative to --imageId")
+	c.Flags().StringVarP(&r.command.tier, "tier", "", string(images.RestoreStandard), "How fast the image is restored: Expedited, Standard or Bulk")
+	c.Flags().IntVarP(&r.command.days, "days", "", 1, "Number of days the restored copy can be downloaded for")
+	c.Flags().BoolVarP(&r.command.status, "status", "", false, "Only print the status of the image's restore")
+	c.Flags().BoolVarP(&r.command.wait, "wait", "", false, "Wait until the image is restored")
 
 	return &c
 }
 
-func (r *Runner) runDeleteCommand(cmd *cobra.Command, args []string) error {
-	logger := r.logger.With(zap.String("imageId", r.command.imageID))
+func (r *Runner) searchCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "search <query>",
+		Short: "Search the images, i.e. 'name:*.png AND size>2MB'",
+		Long: "Search the images matching every term of the query. Terms are name:<pattern>, " +
+			"size<op><size> i.e. size>=1.5MB, created<op><time>, updated>=<time>, storage:<name>, tag:<tag> " +
+			"and bare words matching names which contain them. Operators are one of : = > >= < <=, " +
+			"times are RFC 3339 or 2006-01-02 dates.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: r.runSearchCommand,
+	}
+	c.Flags().IntVarP(&r.command.limit, "limit", "", 0, "Maximum number of images to return, all matches are returned when 0")
+	c.Flags().StringVarP(&r.command.sort, "sort", "", "", "Field to sort the images by: name, size or createdAt (defaults to id)")
+	c.Flags().BoolVarP(&r.command.desc, "desc", "", false, "Sort the images in descending order")
 
-	if err := r.svc.Delete(r.command.imageID); err != nil {
-		const msg = "unable to delete image"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	return &c
+}
+
+func (r *Runner) srcsetCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "srcset",
+		Short: "Store the responsive sizes of the image and print the HTML img of their srcset.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runSrcsetCommand,
 	}
 
-	logger.Debug("image deleted", zap.String("imageId", r.command.imageID))
-	fmt.Printf("Image (%s) successfully deleted\n", r.command.imageID)
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image, alternative to --imageId")
+	c.Flags().IntSliceVarP(&r.command.widths, "widths", "", images.DefaultSrcsetWidths, "Widths in pixels the image is scaled down to")
+	c.Flags().StringVarP(&r.command.to, "to", "", "", "Format to convert the sizes to, jpeg, png, gif or webp (defaults to the image's format)")
+	c.Flags().StringVarP(&r.command.sizes, "sizes", "", "100vw", "Value of the img's sizes attribute")
+	c.Flags().BoolVarP(&r.command.public, "public", "", false, "Use the stable URLs of a public image, they do not expire")
+	c.Flags().DurationVarP(&r.command.expires, "expires", "", 24*time.Hour, "How long the presigned URLs are valid for, at most 168h")
 
-	return nil
+	return &c
 }
 
-func (r *Runner) runDownloadCommand(cmd *cobra.Command, args []string) error {
-	logger := r.logger.With(zap.String("filePath", r.command.filePath), zap.String("imageId", r.command.imageID))
+func (r *Runner) findCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "find",
+		Short: "Find the images by the checksum of their content.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runFindCommand,
+	}
+	c.Flags().StringVarP(&r.command.sha256, "sha256", "", "", "Hex encoded SHA-256 digest of the image")
+	c.Flags().StringVarP(&r.command.etag, "etag", "", "", "ETag of the image's object")
+	c.Flags().StringVarP(&r.command.filePath, "file", "f", "", "Path to a file whose content is looked up, i.e. to check it was already uploaded")
 
-	if _, err := r.svc.Get(r.command.imageID); err != nil {
-		const msg = "unable to get image record"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	return &c
+}
+
+func (r *Runner) ensureIndexesCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "ensure-indexes",
+		Short: "Create the missing indexes the image queries rely on.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runEnsureIndexesCommand,
 	}
+	c.Flags().BoolVarP(&r.command.check, "check", "", false, "Report the state of the indexes without creating the missing ones")
 
-	f, err := os.Create(r.command.filePath)
-	if err != nil {
-		const msg = "unable to create file"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	return &c
+}
+
+func (r *Runner) migrateCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "migrate",
+		Short: "Apply the pending migrations to the metadata backend.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runMigrateCommand,
 	}
+	c.Flags().BoolVarP(&r.command.dryRun, "dry-run", "", false, "List the pending migrations without applying them")
+
+	return &c
+}
 
-	req := images.DownloadRequest{
-		ID:     r.command.imageID,
-		Stream: f,
+func (r *Runner) migrateDBCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "migrate-db",
+		Short: "Copy the image records from one metadata backend to another.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runMigrateDBCommand,
 	}
+	c.Flags().StringVarP(&r.command.from, "from", "", "", "Metadata backend to copy the records from (required)")
+	c.Flags().StringVarP(&r.command.to, "to", "", "", "Metadata backend to copy the records to (required)")
+	c.Flags().IntVarP(&r.command.batchSize, "batch-size", "", service.DefaultBatchSize, "Number of records copied between progress reports")
+	c.MarkFlagRequired("from")
+	c.MarkFlagRequired("to")
 
-	if err := r.svc.Download(req); err != nil {
-		const msg = "unable to download image"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	return &c
+}
+
+func (r *Runner) migrateStorageCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "migrate-storage",
+		Short: "Move the images held in one storage profile to another.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runMigrateStorageCommand,
 	}
+	c.Flags().StringVarP(&r.command.from, "from", "", "", "Name of the storage profile to migrate from (required)")
+	c.Flags().StringVarP(&r.command.to, "to", "", "", "Name of the storage profile to migrate to (required)")
+	c.Flags().BoolVarP(&r.command.deleteOriginals, "delete-originals", "", false, "Delete the objects from the original storage once migrated")
+	c.MarkFlagRequired("from")
+	c.MarkFlagRequired("to")
 
-	logger.Debug("successfully downloaded image")
-	fmt.Printf("successfully downloaded file to: (%s)\n", r.command.filePath)
+	return &c
+}
 
-	return nil
+func (r *Runner) presignUploadCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "presign-upload",
+		Short: "Create a pending image and a presigned request which uploads it directly to storage.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runPresignUploadCommand,
+	}
+
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image (required)")
+	c.Flags().StringVarP(&r.command.contentType, "content-type", "", "", "MIME type the image is uploaded with (defaults to the type of the name's extension)")
+	c.Flags().StringSliceVarP(&r.command.tags, "tag", "", nil, "Tag the image, repeat to add several tags")
+	c.Flags().DurationVarP(&r.command.expires, "expires", "", 15*time.Minute, "How long the upload can be made for, at most 168h")
+	c.MarkFlagRequired("name")
+
+	return &c
 }
 
-func (r *Runner) runListCommand(cmd *cobra.Command, args []string) error {
-	list, err := r.svc.List()
-	switch err {
-	case nil:
-	case images.ErrRecordNotFound:
-		fmt.Println("[]")
-		return nil
-	default:
-		const msg = "failed to list images"
-		r.logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+func (r *Runner) reconcileDeletesCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "reconcile-deletes",
+		Short: "Finish the deletes of images which were interrupted.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runReconcileDeletesCommand,
 	}
+	c.Flags().DurationVarP(&r.command.grace, "grace", "", time.Minute, "Skip the deletes started less than this long ago as they may still be running")
 
-	b, err := json.MarshalIndent(list, "", " ")
-	if err != nil {
-		const msg = "failed to marshal image list"
-		r.logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	return &c
+}
+
+func (r *Runner) renameCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "rename",
+		Short: "Rename the image.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runRenameCommand,
 	}
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to rename (required)")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "New name for the image (required)")
+	c.MarkFlagRequired("imageId")
+	c.MarkFlagRequired("name")
 
-	fmt.Println(string(b))
+	return &c
+}
 
-	return nil
+func (r *Runner) tagCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "tag",
+		Short: "Add or remove the tags of an image.",
+	}
+	c.AddCommand(
+		r.tagChangeCommand("add", "Add the tags to the image.", r.svc.AddTags),
+		r.tagChangeCommand("remove", "Remove the tags from the image.", r.svc.RemoveTags),
+	)
+
+	return &c
 }
 
-func (r *Runner) runUploadCommand(cmd *cobra.Command, args []string) error {
-	logger := r.logger.With(zap.String("filePath", r.command.filePath), zap.String("imageName", r.command.imageName))
+// tagChangeCommand returns the tag subcommand which applies the change to
+// the image's tags.
+func (r *Runner) tagChangeCommand(use, short string, change func(ctx context.Context, id string, tags []string) (*images.Record, error)) *cobra.Command {
+	c := cobra.Command{
+		Use:   use + " <tag>...",
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rec, err := r.getRecord(cmd.Context())
+			if err != nil {
+				return err
+			}
+			logger := r.logger.With(zap.String("imageId", rec.ID), zap.Strings("tags", args))
 
-	f, err := os.Open(r.command.filePath)
-	if err != nil {
-		const msg = "failed to open file"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+			rec, err = change(cmd.Context(), rec.ID, args)
+			if err != nil {
+				const msg = "unable to change image tags"
+				logger.Error(msg, zap.Error(err))
+				return fmt.Errorf(msg+": %w", err)
+			}
+
+			logger.Debug("successfully changed image tags")
+			fmt.Printf("Image (%s) tags: %s\n", rec.ID, strings.Join(rec.Tags, ", "))
+
+			return nil
+		},
 	}
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image")
+	c.Flags().StringVarP(&r.command.imageName, "name", "", "", "Name of the image")
 
-	_, _, err = image.Decode(f)
-	switch err {
-	case nil:
-	case image.ErrFormat:
-		const msg = "unsupported image format"
-		logger.Error(msg, zap.Error(err))
+	return &c
+}
 
-		return image.ErrFormat
-	default:
-		const msg = "unsupported image format"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+func (r *Runner) urlCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "url",
+		Short: "Print a presigned URL which grants temporary read access to the image.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runURLCommand,
 	}
 
-	// we need to seek since image.Decode processes the file
-	if _, err := f.Seek(0, 0); err != nil {
-		const msg = "unable to seek file"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to share")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image to share, alternative to --imageId")
+	c.Flags().DurationVarP(&r.command.expires, "expires", "", 15*time.Minute, "How long the URL is valid for, at most 168h")
+	c.Flags().BoolVarP(&r.command.public, "public", "", false, "Print the stable URL of a public image instead, it does not expire")
+
+	return &c
+}
+
+func (r *Runner) verifyCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "verify",
+		Short: "Download the image and check it against its recorded size and checksums.",
+		Args:  cobra.NoArgs,
+		RunE:  r.runVerifyCommand,
 	}
-	request := images.UploadRequest{
-		Name: r.command.imageName,
-		Body: f,
+
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id of the image to verify")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name of the image to verify, alternative to --imageId")
+
+	return &c
+}
+
+func (r *Runner) uploadCommand() *cobra.Command {
+	c := cobra.Command{
+		Use:   "upload",
+		Short: "Upload an image",
+		Args:  cobra.NoArgs,
+		RunE:  r.runUploadCommand,
 	}
+	c.Flags().StringVarP(&r.command.filePath, "file", "f", "", "Path to the image file, or a pattern such as 'photos/*.jpg' to upload every match")
+	c.Flags().StringVarP(&r.command.imageName, "name", "n", "", "Name for the image (defaults to the file's basename)")
+	c.Flags().StringVarP(&r.command.storage, "storage", "", "", "Name of the storage profile to upload to (defaults to STORAGE)")
+	c.Flags().BoolVarP(&r.command.force, "force", "", false, "Upload even if an image with the same name exists")
+	c.Flags().BoolVarP(&r.command.resume, "resume", "", false, "Upload the file in parts whose progress is kept under ~/.sim, rerunning an interrupted upload resumes it")
+	c.Flags().BoolVarP(&r.command.replace, "replace", "", false, "Replace the content of the image with the name, keeping its id, the image must exist")
+	c.Flags().StringVarP(&r.command.onConflict, "on-conflict", "", "", "What to do when an image has the name: error, suffix to upload it as name-2, or overwrite to replace the image (defaults to error)")
+	c.Flags().StringSliceVarP(&r.command.tags, "tag", "", nil, "Tag the image, repeat to add several tags")
+	c.Flags().StringVarP(&r.command.imageID, "imageId", "", "", "Id to give the image, an existing image with the id is returned instead of uploading")
+	c.Flags().StringVarP(&r.command.idempotencyKey, "idempotency-key", "", "", "Key which makes retries of the upload return the image of the first attempt")
+	c.Flags().BoolVarP(&r.command.skipUnchanged, "skip-unchanged", "", false, "Return the image with the same name instead of uploading when its content is the same")
+	c.Flags().StringVarP(&r.command.dir, "dir", "", "", "Directory whose image files are uploaded, alternative to --file")
+	c.Flags().BoolVarP(&r.command.recursive, "recursive", "", false, "Also upload the image files of the subdirectories of --dir")
+	c.Flags().BoolVarP(&r.command.relativeNames, "relative-names", "", false, "Name the images of --dir after their paths relative to it instead of their basenames")
+	c.Flags().StringSliceVarP(&r.command.allowedTypes, "allowed-type", "", nil, "Only upload images of the MIME type, i.e. image/png or image/*, repeat to allow several (defaults to ALLOWED_TYPES)")
+	c.Flags().StringVarP(&r.command.url, "url", "", "", "Http(s) url of an image which is streamed into storage, alternative to --file")
+	c.Flags().StringVarP(&r.command.manifest, "manifest", "", "", "JSON or CSV file listing the files to upload with their names, tags and metadata, alternative to --file")
+	c.Flags().IntVarP(&r.command.concurrency, "concurrency", "", 4, "Number of files uploaded at once by a batch upload")
+	c.Flags().StringVarP(&r.command.sse, "sse", "", "", "Server-side encryption of the object, aws:kms or AES256 (defaults to SSE)")
+	c.Flags().StringVarP(&r.command.cacheControl, "cache-control", "", "", "Cache-Control header of the object, i.e. max-age=86400 (defaults to CACHE_CONTROL)")
+	c.Flags().StringVarP(&r.command.contentDisposition, "content-disposition", "", "", "Content-Disposition header of the object, inline or attachment are named after the image (defaults to CONTENT_DISPOSITION)")
+	c.Flags().StringToStringVarP(&r.command.s3Meta, "s3-meta", "", nil, "User metadata key=value to set on the object itself, i.e. for S3 event consumers, repeat to set several")
+	c.Flags().StringVarP(&r.command.storageClass, "storage-class", "", "", "Storage class of the object, i.e. STANDARD_IA or GLACIER_IR (defaults to STORAGE_CLASS)")
+	c.Flags().BoolVarP(&r.command.public, "public", "", false, "Make the object readable by anyone through its URL, objects are private otherwise")
+	c.Flags().BoolVarP(&r.command.stripMetadata, "strip-metadata", "", false, "Remove the EXIF, i.e. the GPS position, and other metadata of a jpeg or png before it is stored (defaults to STRIP_METADATA)")
+	c.Flags().StringVarP(&r.command.sseKMSKeyID, "sse-kms-key-id", "", "", "KMS key which encrypts the object, implies --sse aws:kms (defaults to SSE_KMS_KEY_ID)")
 
-	imageID, err := r.svc.Upload(request)
-	if err != nil {
-		const msg = "failed to upload file"
-		logger.Error(msg, zap.Error(err))
-		return fmt.Errorf(msg+": %w", err)
+	return &c
+}
+
+func (r *Runner) runActivityCommand(cmd *cobra.Command, args []string) error {
+	if r.activity == nil {
+		return errors.New("the activity log is disabled")
 	}
-	f.Close()
 
-	logger.Debug("successfully uploaded image")
-	fmt.Printf("Image uploaded successfully with id(%s)\n", imageID)
+	filter := audit.ActivityFilter{
+		Actor:   r.command.actor,
+		Command: r.command.commandName,
+		ImageID: r.command.imageID,
+		Failed:  r.command.failed,
+	}
+	limit := r.command.activityLimit
+	for {
+		activities, err := r.activity.Recent(cmd.Context(), filter, limit)
+		if err != nil {
+			const msg = "failed to read activity log"
+			r.logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		for i := range activities {
+			printActivity(&activities[i])
+		}
+		if !r.command.follow {
+			return nil
+		}
+
+		if len(activities) > 0 {
+			filter.After = activities[len(activities)-1].ID
+		} else if filter.After == "" {
+			// nothing was recorded yet, only follow what is recorded from now
+			filter.After = audit.TimeID(time.Now())
+		}
+		limit = 0
+		select {
+		case <-cmd.Context().Done():
+			return nil
+		case <-time.After(activityFollowInterval):
+		}
+	}
+}
+
+func printActivity(a *audit.Activity) {
+	imageID := a.ImageID
+	if imageID == "" {
+		imageID = "-"
+	}
+	line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s", a.At.Format(time.RFC3339), a.Actor, a.Command, imageID, a.Outcome)
+	if a.Error != "" {
+		line += "\t" + a.Error
+	}
+	fmt.Println(line)
+}
+
+func (r *Runner) runConfirmUploadCommand(cmd *cobra.Command, args []string) error {
+	logger := r.logger.With(zap.String("imageId", r.command.imageID))
+
+	pending, err := r.svc.Resolve(cmd.Context(), r.command.imageID)
+	if err != nil {
+		const msg = "unable to get image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	r.command.subject = pending.ID
+
+	rec, err := r.svc.ConfirmUpload(cmd.Context(), pending.ID)
+	if err != nil {
+		const msg = "unable to confirm upload"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(rec, "", " ")
+	if err != nil {
+		const msg = "failed to marshal image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+func (r *Runner) runConvertCommand(cmd *cobra.Command, args []string) error {
+	req, err := r.variantRequest()
+	if err != nil {
+		return err
+	}
+	rec, err := r.getRecord(cmd.Context())
+	if err != nil {
+		return err
+	}
+	logger := r.logger.With(zap.String("imageId", rec.ID))
+
+	req.ID = rec.ID
+	variant, err := r.svc.CreateVariant(cmd.Context(), *req)
+	if err != nil {
+		const msg = "unable to convert image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(variant, "", " ")
+	if err != nil {
+		const msg = "failed to marshal variant"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+func (r *Runner) runCountCommand(cmd *cobra.Command, args []string) error {
+	filter, err := r.listFilter()
+	if err != nil {
+		return err
+	}
+
+	n, err := r.svc.Count(cmd.Context(), *filter)
+	if err != nil {
+		const msg = "failed to count images"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(n)
+
+	return nil
+}
+
+func (r *Runner) runDeleteCommand(cmd *cobra.Command, args []string) error {
+	rec, err := r.getRecord(cmd.Context())
+	if err != nil {
+		return err
+	}
+	logger := r.logger.With(zap.String("imageId", rec.ID))
+
+	if err := r.svc.Delete(cmd.Context(), rec.ID); err != nil {
+		const msg = "unable to delete image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	logger.Debug("image deleted", zap.String("imageId", rec.ID))
+	fmt.Printf("Image (%s) successfully deleted\n", rec.ID)
+
+	return nil
+}
+
+func (r *Runner) runDownloadCommand(cmd *cobra.Command, args []string) error {
+	batch := r.command.all || len(r.command.imageIDs) > 0 || len(r.command.imageNames) > 0 || r.filterSet()
+	processed := r.command.resize != "" || r.command.to != "" || r.command.watermark || r.command.pipeline != ""
+	switch {
+	case processed && (batch || r.command.resume || r.command.offset != 0 || r.command.length != 0):
+		return errors.New("--resize, --to, --watermark and --pipeline download a single whole image, they can not be combined with --resume, a byte range or a batch")
+	case r.command.cache && !processed:
+		return errors.New("--cache requires --resize, --to, --watermark or --pipeline")
+	case batch && (r.command.imageID != "" || r.command.imageName != ""):
+		return errors.New("--imageId and --name download a single image, use --ids or --names to download several")
+	case batch && r.command.dir == "":
+		return errors.New("--ids, --names, --all and the filter flags require --dir")
+	case batch:
+		return r.downloadBatch(cmd.Context())
+	case r.command.filePath != "" && r.command.dir != "":
+		return errors.New("only one of --file or --dir can be set")
+	case r.command.resume && (r.command.filePath == "-" || r.command.offset != 0 || r.command.length != 0):
+		return errors.New("--resume can not be combined with --file - or a byte range")
+	}
+
+	var (
+		variant *images.VariantRequest
+		err     error
+	)
+	if processed {
+		if variant, err = r.variantRequest(); err != nil {
+			return err
+		}
+		variant.Cache = r.command.cache
+	}
+
+	rec, err := r.getRecord(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	// --file - streams the image to stdout i.e. into a pipe
+	if r.command.filePath == "-" && variant != nil {
+		variant.ID = rec.ID
+		variant.Writer = os.Stdout
+		if err := r.svc.DownloadVariant(cmd.Context(), *variant); err != nil {
+			const msg = "unable to download processed image"
+			r.logger.Error(msg, zap.String("imageId", rec.ID), zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+
+		return nil
+	}
+	if r.command.filePath == "-" {
+		req := images.DownloadRequest{
+			ID:     rec.ID,
+			Writer: os.Stdout,
+			Offset: r.command.offset,
+			Length: r.command.length,
+		}
+		if err := r.svc.Download(cmd.Context(), req); err != nil {
+			const msg = "unable to download image"
+			r.logger.Error(msg, zap.String("imageId", rec.ID), zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+
+		return nil
+	}
+
+	// without --file the image is downloaded into the current directory, or
+	// --dir, under its name
+	path := r.command.filePath
+	if path == "" {
+		name := rec.Name
+		if variant != nil {
+			name = variantName(name, variant.Format)
+		}
+		if path, err = localPath(r.command.dir, name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			const msg = "unable to create directory"
+			r.logger.Error(msg, zap.String("filePath", path), zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	}
+	logger := r.logger.With(zap.String("filePath", path), zap.String("imageId", rec.ID))
+	if variant != nil {
+		variant.ID = rec.ID
+		return r.downloadVariant(cmd.Context(), *variant, path, logger)
+	}
+
+	req := images.DownloadFileRequest{
+		ID:        rec.ID,
+		Path:      path,
+		Overwrite: r.command.force,
+		Resume:    r.command.resume,
+		Offset:    r.command.offset,
+		Length:    r.command.length,
+	}
+	err = r.svc.DownloadFile(cmd.Context(), req)
+	switch {
+	case err == nil:
+	case errors.Is(err, images.ErrFileExists):
+		return fmt.Errorf("file (%s) already exists, use --force to replace it", path)
+	case r.command.resume && partialExists(path):
+		const msg = "unable to download image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+", rerun to resume it from (%s): %w", path+images.PartialSuffix, err)
+	default:
+		const msg = "unable to download image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	logger.Debug("successfully downloaded image")
+	fmt.Printf("successfully downloaded file to: (%s)\n", path)
+
+	return nil
+}
+
+func (r *Runner) runFsckCommand(cmd *cobra.Command, args []string) error {
+	filter, err := r.listFilter()
+	if err != nil {
+		return err
+	}
+
+	report, err := r.svc.Fsck(cmd.Context(), *filter)
+	if err != nil {
+		const msg = "unable to check images"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(report, "", " ")
+	if err != nil {
+		const msg = "failed to marshal fsck report"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if r.command.reportPath == "" {
+		fmt.Println(string(b))
+	} else if err := ioutil.WriteFile(r.command.reportPath, append(b, '\n'), 0o644); err != nil {
+		const msg = "unable to write fsck report"
+		r.logger.Error(msg, zap.String("filePath", r.command.reportPath), zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if len(report.Inconsistencies) > 0 {
+		return fmt.Errorf("found (%d) inconsistencies in (%d) images", len(report.Inconsistencies), report.Checked)
+	}
+
+	return nil
+}
+
+func (r *Runner) runGCCommand(cmd *cobra.Command, args []string) error {
+	logger := r.logger.With(zap.String("storage", r.command.storage))
+
+	req := images.GCRequest{
+		Storage: r.command.storage,
+		Grace:   r.command.gcGrace,
+		DryRun:  r.command.dryRun,
+	}
+	res, err := r.svc.GC(cmd.Context(), req)
+	if err != nil {
+		const msg = "unable to collect garbage"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		const msg = "failed to marshal gc result"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	fmt.Println(string(b))
+
+	if len(res.Failed) > 0 {
+		return fmt.Errorf("unable to delete (%d) orphans and dangling images", len(res.Failed))
+	}
+
+	return nil
+}
+
+func (r *Runner) runGetCommand(cmd *cobra.Command, args []string) error {
+	rec, err := r.getRecord(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(rec, "", " ")
+	if err != nil {
+		const msg = "failed to marshal image record"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+func (r *Runner) runHistoryCommand(cmd *cobra.Command, args []string) error {
+	logger := r.logger.With(zap.String("imageId", r.command.imageID))
+
+	if r.history == nil {
+		return errors.New("audit history is disabled")
+	}
+
+	events, err := r.history.History(cmd.Context(), r.command.imageID)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		fmt.Println("[]")
+		return nil
+	default:
+		const msg = "failed to get image history"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(events, "", " ")
+	if err != nil {
+		const msg = "failed to marshal image history"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+func (r *Runner) runListCommand(cmd *cobra.Command, args []string) error {
+	filter, err := r.listFilter()
+	if err != nil {
+		return err
+	}
+	opts := images.ListOptions{
+		Limit:  r.command.limit,
+		Cursor: r.command.cursor,
+		Filter: *filter,
+		Sort:   images.SortField(r.command.sort),
+		Desc:   r.command.desc,
+	}
+	if !opts.Sort.Valid() {
+		return fmt.Errorf("invalid --sort field: %s", r.command.sort)
+	}
+	list, next, err := r.svc.List(cmd.Context(), opts)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		fmt.Println("[]")
+		return nil
+	default:
+		const msg = "failed to list images"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		const msg = "failed to marshal image list"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(string(b))
+	if next != "" {
+		fmt.Fprintf(os.Stderr, "next cursor: %s\n", next)
+	}
+
+	return nil
+}
+
+func (r *Runner) runFindCommand(cmd *cobra.Command, args []string) error {
+	sha, etag := r.command.sha256, r.command.etag
+	switch {
+	case r.command.filePath != "" && (sha != "" || etag != ""):
+		return errors.New("--file can not be combined with --sha256 or --etag")
+	case r.command.filePath != "":
+		var err error
+		if sha, etag, err = fileChecksums(r.command.filePath); err != nil {
+			const msg = "unable to checksum file"
+			r.logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	case sha == "" && etag == "":
+		return errors.New("one of --sha256, --etag or --file is required")
+	}
+
+	list, err := r.svc.FindByChecksum(cmd.Context(), sha, etag)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		fmt.Println("[]")
+		return nil
+	default:
+		const msg = "failed to find images"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		const msg = "failed to marshal image list"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+// fileChecksums returns the hex encoded SHA-256 and MD5 digests of the file,
+// the MD5 digest is the ETag of objects which were not uploaded in parts.
+func fileChecksums(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	sha, md := sha256.New(), md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha, md), f); err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(sha.Sum(nil)), hex.EncodeToString(md.Sum(nil)), nil
+}
+
+func (r *Runner) runRepairCommand(cmd *cobra.Command, args []string) error {
+	filter, err := r.listFilter()
+	if err != nil {
+		return err
+	}
+
+	report, err := r.fsckReport(cmd.Context(), *filter)
+	if err != nil {
+		return err
+	}
+
+	req := images.RepairRequest{
+		Inconsistencies: report.Inconsistencies,
+		RemoveMissing:   r.command.removeMissing,
+		Checksums:       r.command.checksums,
+		ChecksumFilter:  *filter,
+	}
+	if req.RemoveMissing && !r.command.yes {
+		var missing int
+		for _, i := range report.Inconsistencies {
+			if i.Problem == images.ProblemMissingObject {
+				missing++
+			}
+		}
+		if missing > 0 && !confirm(fmt.Sprintf("Delete (%d) images whose object is missing?", missing)) {
+			req.RemoveMissing = false
+		}
+	}
+
+	res, err := r.svc.Repair(cmd.Context(), req)
+	if err != nil {
+		const msg = "unable to repair images"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		const msg = "failed to marshal repair result"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	fmt.Println(string(b))
+
+	if len(res.Failed) > 0 {
+		return fmt.Errorf("unable to repair (%d) images", len(res.Failed))
+	}
+
+	return nil
+}
+
+// fsckReport reads the report given by --report, or runs fsck when none is
+// given.
+func (r *Runner) fsckReport(ctx context.Context, filter images.ListFilter) (*images.FsckReport, error) {
+	if r.command.reportPath == "" {
+		report, err := r.svc.Fsck(ctx, filter)
+		if err != nil {
+			const msg = "unable to check images"
+			r.logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+		return report, nil
+	}
+
+	b, err := ioutil.ReadFile(r.command.reportPath)
+	if err != nil {
+		const msg = "unable to read fsck report"
+		r.logger.Error(msg, zap.String("filePath", r.command.reportPath), zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	var report images.FsckReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		const msg = "unable to unmarshal fsck report"
+		r.logger.Error(msg, zap.String("filePath", r.command.reportPath), zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return &report, nil
+}
+
+// confirm asks the question on stdout and reports whether it was answered
+// with yes.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+
+	return false
+}
+
+func (r *Runner) runRestoreCommand(cmd *cobra.Command, args []string) error {
+	if r.command.status && (cmd.Flags().Changed("tier") || cmd.Flags().Changed("days")) {
+		return errors.New("--tier and --days can not be combined with --status")
+	}
+
+	rec, err := r.getRecord(cmd.Context())
+	if err != nil {
+		return err
+	}
+	logger := r.logger.With(zap.String("imageId", rec.ID))
+
+	var status *images.RestoreStatus
+	if r.command.status {
+		status, err = r.svc.RestoreStatus(cmd.Context(), rec.ID)
+	} else {
+		status, err = r.svc.Restore(cmd.Context(), rec.ID, images.RestoreTier(r.command.tier), r.command.days)
+	}
+	if err != nil {
+		const msg = "unable to restore image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	for r.command.wait && status != nil && status.InProgress {
+		fmt.Fprintln(os.Stderr, "restore in progress, waiting...")
+		select {
+		case <-cmd.Context().Done():
+			return cmd.Context().Err()
+		case <-time.After(restorePollInterval):
+		}
+
+		if status, err = r.svc.RestoreStatus(cmd.Context(), rec.ID); err != nil {
+			const msg = "unable to get restore status"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	}
+	fmt.Println(restoreStatus(status))
+
+	return nil
+}
+
+// restoreStatus describes the status of a restore.
+func restoreStatus(status *images.RestoreStatus) string {
+	switch {
+	case status == nil:
+		return "archived, not restored"
+	case status.InProgress:
+		return "restore in progress"
+	case status.Restored():
+		return "restored until " + status.ExpiresAt.Format(time.RFC3339)
+	default:
+		return "restored"
+	}
+}
+
+func (r *Runner) runSrcsetCommand(cmd *cobra.Command, args []string) error {
+	if r.command.public && cmd.Flags().Changed("expires") {
+		return errors.New("--expires can not be combined with --public, public urls do not expire")
+	}
+
+	rec, err := r.getRecord(cmd.Context())
+	if err != nil {
+		return err
+	}
+	logger := r.logger.With(zap.String("imageId", rec.ID))
+
+	candidates, err := r.svc.Srcset(cmd.Context(), images.SrcsetRequest{
+		ID:      rec.ID,
+		Widths:  r.command.widths,
+		Format:  r.command.to,
+		Public:  r.command.public,
+		Expires: r.command.expires,
+	})
+	if err != nil {
+		const msg = "unable to create srcset"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	fmt.Println(srcsetHTML(candidates, rec.Name, r.command.sizes))
+
+	return nil
+}
+
+func (r *Runner) runSearchCommand(cmd *cobra.Command, args []string) error {
+	q, err := images.ParseQuery(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	opts := images.ListOptions{
+		Limit: r.command.limit,
+		Sort:  images.SortField(r.command.sort),
+		Desc:  r.command.desc,
+	}
+	if !opts.Sort.Valid() {
+		return fmt.Errorf("invalid --sort field: %s", r.command.sort)
+	}
+
+	list, err := r.svc.Search(cmd.Context(), q, opts)
+	switch err {
+	case nil:
+	case images.ErrRecordNotFound:
+		fmt.Println("[]")
+		return nil
+	default:
+		const msg = "failed to search images"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		const msg = "failed to marshal image list"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+func (r *Runner) runEnsureIndexesCommand(cmd *cobra.Command, args []string) error {
+	if r.indexes == nil {
+		return errors.New("the metadata backend has no indexes to manage")
+	}
+
+	idx, err := r.indexes()
+	if err != nil {
+		const msg = "unable to get index manager"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	var statuses []couchbase.IndexStatus
+	if r.command.check {
+		statuses, err = idx.Status()
+	} else {
+		statuses, err = idx.Ensure()
+	}
+	if err != nil {
+		return err
+	}
+
+	var missing int
+	for i := range statuses {
+		fmt.Printf("%s: %s\n", statuses[i].Name, statuses[i].State)
+		if statuses[i].State == couchbase.IndexMissing {
+			missing++
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("(%d) indexes are missing", missing)
+	}
+
+	return nil
+}
+
+func (r *Runner) runMigrateCommand(cmd *cobra.Command, args []string) error {
+	if r.migrations == nil {
+		return errors.New("the metadata backend has no migrations")
+	}
+
+	m, err := r.migrations()
+	if err != nil {
+		const msg = "unable to get migration runner"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if r.command.dryRun {
+		pending, err := m.Pending()
+		if err != nil {
+			return err
+		}
+		for i := range pending {
+			fmt.Printf("%d: %s\n", pending[i].Version, pending[i].Description)
+		}
+		return nil
+	}
+
+	applied, err := m.Run()
+	for i := range applied {
+		fmt.Printf("applied %d: %s\n", applied[i].Version, applied[i].Description)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("no pending migrations")
+	}
+
+	return nil
+}
+
+func (r *Runner) runMigrateDBCommand(cmd *cobra.Command, args []string) error {
+	logger := r.logger.With(zap.String("from", r.command.from), zap.String("to", r.command.to))
+
+	if r.backends == nil {
+		return errors.New("migrating metadata backends is not supported")
+	}
+	if r.command.from == r.command.to {
+		return fmt.Errorf("unable to migrate backend to itself: %s", r.command.from)
+	}
+
+	from, _, err := r.backends(r.command.from)
+	if err != nil {
+		const msg = "unable to open source backend"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	to, writer, err := r.backends(r.command.to)
+	if err != nil {
+		const msg = "unable to open target backend"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	migrator, err := service.NewRecordMigrator(logger, from, to, writer, r.command.batchSize)
+	if err != nil {
+		const msg = "unable to get record migrator"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	res, err := migrator.Migrate(cmd.Context(), func(done, total int) {
+		fmt.Fprintf(os.Stderr, "migrated %d/%d records\n", done, total)
+	})
+	if err != nil {
+		const msg = "unable to migrate records"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		const msg = "failed to marshal migration result"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	fmt.Println(string(b))
+
+	if n := len(res.Failed) + len(res.Mismatched); n > 0 {
+		return fmt.Errorf("unable to migrate (%d) records", n)
+	}
+
+	return nil
+}
+
+func (r *Runner) runMigrateStorageCommand(cmd *cobra.Command, args []string) error {
+	logger := r.logger.With(zap.String("from", r.command.from), zap.String("to", r.command.to))
+
+	req := images.MigrateStorageRequest{
+		From:            r.command.from,
+		To:              r.command.to,
+		DeleteOriginals: r.command.deleteOriginals,
+	}
+	res, err := r.svc.MigrateStorage(cmd.Context(), req)
+	if err != nil {
+		const msg = "unable to migrate storage"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	b, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		const msg = "failed to marshal migration result"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	fmt.Println(string(b))
+
+	if len(res.Failed) > 0 {
+		return fmt.Errorf("unable to migrate (%d) images", len(res.Failed))
+	}
+
+	return nil
+}
+
+func (r *Runner) runPresignUploadCommand(cmd *cobra.Command, args []string) error {
+	logger := r.logger.With(zap.String("imageName", r.command.imageName))
+
+	upload, err := r.svc.PresignUpload(cmd.Context(), images.PresignUploadRequest{
+		Name:        r.command.imageName,
+		ContentType: r.command.contentType,
+		Tags:        r.command.tags,
+		Expires:     r.command.expires,
+	})
+	if err != nil {
+		const msg = "unable to presign upload"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	r.command.subject = upload.ImageID
+
+	b, err := json.MarshalIndent(upload, "", " ")
+	if err != nil {
+		const msg = "failed to marshal presigned upload"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
+
+func (r *Runner) runReconcileDeletesCommand(cmd *cobra.Command, args []string) error {
+	finished, err := r.svc.ReconcileDeletes(cmd.Context(), r.command.grace)
+	fmt.Printf("Finished (%d) interrupted deletes\n", finished)
+	if err != nil {
+		const msg = "unable to reconcile deletes"
+		r.logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+func (r *Runner) runRenameCommand(cmd *cobra.Command, args []string) error {
+	logger := r.logger.With(zap.String("imageId", r.command.imageID), zap.String("imageName", r.command.imageName))
+
+	rec, err := r.svc.Resolve(cmd.Context(), r.command.imageID)
+	if err != nil {
+		const msg = "unable to get image record"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if rec, err = r.svc.Rename(cmd.Context(), rec.ID, r.command.imageName); err != nil {
+		const msg = "unable to rename image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	logger.Debug("successfully renamed image")
+	fmt.Printf("Image (%s) successfully renamed to (%s)\n", rec.ID, rec.Name)
+
+	return nil
+}
+
+func (r *Runner) runUploadCommand(cmd *cobra.Command, args []string) error {
+	var sources int
+	for _, set := range []bool{r.command.filePath != "", r.command.dir != "", r.command.manifest != "", r.command.url != ""} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources > 1:
+		return errors.New("only one of --file, --dir, --manifest or --url can be set")
+	case !images.ConflictStrategy(r.command.onConflict).Valid():
+		return fmt.Errorf("invalid --on-conflict strategy: %s", r.command.onConflict)
+	case r.command.force && (r.command.onConflict != "" || r.command.replace):
+		return errors.New("--force can not be combined with --on-conflict or --replace")
+	case r.command.replace && r.command.onConflict != "" && r.command.onConflict != string(images.ConflictOverwrite):
+		return errors.New("--replace can only be combined with --on-conflict overwrite")
+	case r.command.resume && (r.command.filePath == "" || isGlob(r.command.filePath)):
+		return errors.New("--resume can only be used when uploading a single --file")
+	case r.command.dir != "":
+		return r.uploadDir(cmd.Context())
+	case r.command.manifest != "":
+		return r.uploadManifest(cmd.Context())
+	case r.command.url == "" && r.command.filePath == "":
+		return errors.New("one of --file, --dir, --manifest or --url is required")
+	case isGlob(r.command.filePath):
+		return r.uploadGlob(cmd.Context())
+	}
+
+	var (
+		imageID string
+		err     error
+	)
+	if r.command.url != "" {
+		imageID, err = r.uploadURL(cmd.Context())
+	} else {
+		file := batchFile{path: r.command.filePath, name: r.command.imageName}
+		if file.name == "" {
+			file.name = filepath.Base(file.path)
+		}
+		imageID, err = r.uploadFile(cmd.Context(), file)
+	}
+	if err != nil {
+		return err
+	}
+	r.command.subject = imageID
+	if r.command.replace {
+		fmt.Printf("Image replaced successfully with id(%s)\n", imageID)
+		return nil
+	}
+	fmt.Printf("Image uploaded successfully with id(%s)\n", imageID)
+
+	return nil
+}
+
+// uploadGlob uploads every file matching the --file pattern, each named
+// after its basename.
+func (r *Runner) uploadGlob(ctx context.Context) error {
+	matches, err := filepath.Glob(r.command.filePath)
+	if err != nil {
+		return fmt.Errorf("invalid --file pattern: %w", err)
+	}
+	var files []batchFile
+	for _, path := range matches {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			continue
+		}
+		files = append(files, batchFile{path: path, name: filepath.Base(path)})
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files match (%s)", r.command.filePath)
+	}
+
+	return r.uploadBatch(ctx, files)
+}
+
+// uploadDir uploads the image files of the --dir directory, and of its
+// subdirectories when recursive, each named after its basename or its path
+// relative to the directory. Files without an image extension are skipped.
+func (r *Runner) uploadDir(ctx context.Context) error {
+	var files []batchFile
+	err := filepath.WalkDir(r.command.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != r.command.dir && !r.command.recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !imageExtensions[strings.ToLower(filepath.Ext(path))] {
+			r.logger.Debug("skipping file which is not an image", zap.String("filePath", path))
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.command.dir, path)
+		if err != nil {
+			return err
+		}
+		file := batchFile{path: path, name: d.Name(), relPath: filepath.ToSlash(rel)}
+		if r.command.relativeNames {
+			file.name = file.relPath
+		}
+		files = append(files, file)
+
+		return nil
+	})
+	if err != nil {
+		const msg = "unable to walk directory"
+		r.logger.Error(msg, zap.String("dir", r.command.dir), zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no image files found in (%s)", r.command.dir)
+	}
+
+	return r.uploadBatch(ctx, files)
+}
+
+// uploadManifest uploads the files listed by the --manifest file.
+func (r *Runner) uploadManifest(ctx context.Context) error {
+	files, err := readManifest(r.command.manifest)
+	if err != nil {
+		const msg = "unable to read manifest"
+		r.logger.Error(msg, zap.String("manifest", r.command.manifest), zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files listed in (%s)", r.command.manifest)
+	}
+
+	return r.uploadBatch(ctx, files)
+}
+
+// batchFile is a file of a batch upload and the image it is uploaded as,
+// its tags are added to those of the --tag flags. relPath is the file's
+// slash separated path relative to the --dir it was found in.
+type batchFile struct {
+	path     string
+	name     string
+	relPath  string
+	tags     []string
+	metadata map[string]string
+}
+
+// uploadBatch uploads the files with up to --concurrency uploads running at
+// once, reporting the progress to stderr, and prints a table of the results.
+func (r *Runner) uploadBatch(ctx context.Context, files []batchFile) error {
+	switch {
+	case r.command.imageName != "":
+		return errors.New("--name can only be used when uploading a single file")
+	case r.command.imageID != "", r.command.idempotencyKey != "":
+		return errors.New("--imageId and --idempotency-key can only be used when uploading a single file")
+	case r.command.concurrency < 1:
+		return errors.New("--concurrency must be at least 1")
+	}
+
+	var (
+		done    int
+		jobs    = make(chan int)
+		mu      sync.Mutex
+		results = make([]uploadResult, len(files))
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < r.command.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := uploadResult{path: files[j].path}
+				res.imageID, res.err = r.uploadFile(ctx, files[j])
+				results[j] = res
+
+				mu.Lock()
+				done++
+				fmt.Fprintf(os.Stderr, "uploaded %d/%d files\n", done, len(files))
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return printUploadResults(results)
+}
+
+// uploadFile uploads the image file with the options of the command's
+// flags and returns the id of the image.
+func (r *Runner) uploadFile(ctx context.Context, file batchFile) (string, error) {
+	logger := r.logger.With(zap.String("filePath", file.path), zap.String("imageName", file.name))
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		const msg = "failed to open file"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+	defer f.Close()
+
+	if err := checkImageFile(file.path, f); err != nil {
+		logger.Error("unsupported image format", zap.Error(err))
+		return "", err
+	}
+
+	// we need to seek since checkImageFile reads the header of the file
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		const msg = "unable to seek file"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+
+	return r.upload(ctx, file, f, logger)
+}
+
+// uploadURL streams the image at the --url into storage without storing it
+// locally first and returns the id of the image.
+func (r *Runner) uploadURL(ctx context.Context) (string, error) {
+	logger := r.logger.With(zap.String("url", r.command.url))
+
+	u, err := url.Parse(r.command.url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid --url, expected an http or https url: %s", r.command.url)
+	}
+	file := batchFile{name: r.command.imageName}
+	if file.name == "" {
+		if file.name = path.Base(u.Path); file.name == "/" || file.name == "." {
+			return "", errors.New("--name is required when the url has no file name")
+		}
+	}
+	logger = logger.With(zap.String("imageName", file.name))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		const msg = "unable to create request"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+	resp, err := urlClient.Do(req)
+	if err != nil {
+		const msg = "unable to fetch url"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("unexpected response status", zap.Int("status", resp.StatusCode))
+		return "", fmt.Errorf("unable to fetch url: %s", resp.Status)
+	}
+
+	// whether the body is an image the service may store is up to the
+	// service, see WithAllowedTypes and WithImageValidation
+	return r.upload(ctx, file, resp.Body, logger)
+}
+
+// upload uploads the body as the image of the file with the options of the
+// command's flags and returns the id of the image.
+func (r *Runner) upload(ctx context.Context, file batchFile, body io.Reader, logger *zap.Logger) (string, error) {
+	request := images.UploadRequest{
+		Name:     file.name,
+		Path:     file.relPath,
+		Storage:  r.command.storage,
+		Body:     body,
+		Force:    r.command.force,
+		Tags:     append(append([]string(nil), r.command.tags...), file.tags...),
+		Metadata: file.metadata,
+
+		AllowedTypes:   r.command.allowedTypes,
+		ID:             r.command.imageID,
+		IdempotencyKey: r.command.idempotencyKey,
+		SkipUnchanged:  r.command.skipUnchanged,
+		OnConflict:     images.ConflictStrategy(r.command.onConflict),
+		Replace:        r.command.replace,
+
+		ServerSideEncryption: r.command.sse,
+		KMSKeyID:             r.command.sseKMSKeyID,
+		StorageClass:         r.command.storageClass,
+		CacheControl:         r.command.cacheControl,
+		ContentDisposition:   r.command.contentDisposition,
+		ObjectMetadata:       r.command.s3Meta,
+		Public:               r.command.public,
+		StripMetadata:        r.command.stripMetadata,
+	}
+
+	var session string
+	if r.command.resume {
+		var err error
+		if session, err = sessionPath(file, r.command.storage); err != nil {
+			const msg = "unable to resolve upload session"
+			logger.Error(msg, zap.Error(err))
+			return "", fmt.Errorf(msg+": %w", err)
+		}
+		if request.Session, err = loadSession(session); err != nil {
+			const msg = "unable to read upload session"
+			logger.Error(msg, zap.String("session", session), zap.Error(err))
+			return "", fmt.Errorf(msg+": %w", err)
+		}
+		if request.Session.UploadID != "" {
+			fmt.Fprintf(os.Stderr, "resuming upload, %d of %d parts uploaded\n", len(request.Session.Parts), request.Session.PartCount())
+		}
+		request.Checkpoint = func(s *images.UploadSession) error { return saveSession(session, s) }
+	}
+
+	imageID, err := r.svc.Upload(ctx, request)
+	if err != nil {
+		const msg = "failed to upload file"
+		logger.Error(msg, zap.Error(err))
+		if errors.Is(err, images.ErrSessionMismatch) {
+			return "", fmt.Errorf(msg+": %w, remove %s to start the upload over", err, session)
+		}
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+	if session != "" {
+		if err := os.Remove(session); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Warn("unable to remove upload session", zap.String("session", session), zap.Error(err))
+		}
+	}
+
+	logger.Debug("successfully uploaded image", zap.String("imageId", imageID))
+
+	return imageID, nil
+}
+
+// uploadResult is the outcome of uploading one file of a batch.
+type uploadResult struct {
+	path    string
+	imageID string
+	err     error
+}
+
+// printUploadResults prints a table of the results and returns an error
+// when any of the files failed to upload.
+func printUploadResults(results []uploadResult) error {
+	var failed int
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSTATUS\tIMAGE ID / ERROR")
+	for _, res := range results {
+		if res.err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\tFAILED\t%s\n", res.path, res.err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\tOK\t%s\n", res.path, res.imageID)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("unable to upload (%d) of (%d) files", failed, len(results))
+	}
+
+	return nil
+}
+
+// imageExtensions are the extensions of the image files uploaded from a
+// directory, including those of the images which are stored but can not be
+// decoded, i.e. an svg which is compressed when COMPRESS is set.
+var imageExtensions = map[string]bool{
+	".bmp":  true,
+	".gif":  true,
+	".jpeg": true,
+	".jpg":  true,
+	".png":  true,
+	".svg":  true,
+	".tif":  true,
+	".tiff": true,
+	".webp": true,
+}
+
+// checkImageFile returns image.ErrFormat unless the header of the file can
+// be decoded or, for the formats which can not be decoded, the file has an
+// image extension.
+func checkImageFile(path string, f io.Reader) error {
+	_, _, err := image.DecodeConfig(f)
+	if err == nil {
+		return nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, decodable := imaging.FormatOf(mime.TypeByExtension(ext)); imageExtensions[ext] && !decodable {
+		return nil
+	}
+	if err == image.ErrFormat {
+		return err
+	}
+
+	return fmt.Errorf("%w: %s", image.ErrFormat, err)
+}
+
+// isGlob reports whether the path is a pattern matching several files.
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func (r *Runner) runURLCommand(cmd *cobra.Command, args []string) error {
+	if r.command.public && cmd.Flags().Changed("expires") {
+		return errors.New("--expires can not be combined with --public, public urls do not expire")
+	}
+
+	rec, err := r.getRecord(cmd.Context())
+	if err != nil {
+		return err
+	}
+	logger := r.logger.With(zap.String("imageId", rec.ID))
+
+	if r.command.public {
+		link, err := r.svc.PublicURL(cmd.Context(), rec.ID)
+		if err != nil {
+			const msg = "unable to get public url"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		fmt.Println(link)
+		return nil
+	}
+
+	signed, err := r.svc.PresignURL(cmd.Context(), rec.ID, r.command.expires)
+	if err != nil {
+		const msg = "unable to presign url"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	fmt.Println(signed)
 
 	return nil
 }
 
+func (r *Runner) runVerifyCommand(cmd *cobra.Command, args []string) error {
+	rec, err := r.getRecord(cmd.Context())
+	if err != nil {
+		return err
+	}
+	logger := r.logger.With(zap.String("imageId", rec.ID))
+
+	res, err := r.svc.Verify(cmd.Context(), rec.ID)
+	if err != nil {
+		const msg = "unable to verify image"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	for _, c := range res.Checks {
+		line := fmt.Sprintf("%s\t%s", c.Name, c.Status)
+		switch {
+		case c.Status == images.CheckSkipped, c.Reason != "":
+			line += "\t" + c.Reason
+		case c.Status == images.CheckFail:
+			line += fmt.Sprintf("\texpected %s, got %s", c.Expected, c.Actual)
+		default:
+			line += "\t" + c.Actual
+		}
+		fmt.Println(line)
+	}
+	if !res.OK {
+		fmt.Printf("FAIL image (%s) does not match its record\n", rec.ID)
+		return fmt.Errorf("image (%s) failed verification", rec.ID)
+	}
+	fmt.Printf("PASS image (%s) matches its record\n", rec.ID)
+
+	return nil
+}
+
+// getRecord returns the image record addressed by either the --imageId or
+// --name flag, --imageId may be a unique prefix of the image's ID.
+func (r *Runner) getRecord(ctx context.Context) (*images.Record, error) {
+	var (
+		rec    *images.Record
+		err    error
+		logger = r.logger.With(zap.String("imageId", r.command.imageID), zap.String("imageName", r.command.imageName))
+	)
+	switch {
+	case r.command.imageID != "" && r.command.imageName != "":
+		return nil, errors.New("only one of --imageId or --name can be set")
+	case r.command.imageID != "":
+		rec, err = r.svc.Resolve(ctx, r.command.imageID)
+	case r.command.imageName != "":
+		rec, err = r.svc.GetByName(ctx, r.command.imageName)
+	default:
+		return nil, errors.New("one of --imageId or --name is required")
+	}
+	if err != nil {
+		const msg = "unable to get image record"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	r.command.subject = rec.ID
+
+	return rec, nil
+}
+
+// listFilter builds the list filter from the command's flags.
+func (r *Runner) listFilter() (*images.ListFilter, error) {
+	f := images.ListFilter{
+		NamePrefix: r.command.namePrefix,
+		Storage:    r.command.storage,
+		Tags:       r.command.tags,
+		Text:       r.command.text,
+	}
+
+	for _, t := range []struct {
+		flag  string
+		value string
+		dst   *time.Time
+	}{
+		{flag: "created-after", value: r.command.createdAfter, dst: &f.CreatedAfter},
+		{flag: "created-before", value: r.command.createdBefore, dst: &f.CreatedBefore},
+		{flag: "captured-after", value: r.command.capturedAfter, dst: &f.CapturedAfter},
+		{flag: "captured-before", value: r.command.capturedBefore, dst: &f.CapturedBefore},
+		{flag: "since", value: r.command.since, dst: &f.UpdatedSince},
+	} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s time: %w", t.flag, err)
+		}
+		*t.dst = parsed
+	}
+
+	for _, sz := range []struct {
+		flag  string
+		value string
+		dst   *int64
+	}{
+		{flag: "min-size", value: r.command.minSize, dst: &f.MinSize},
+		{flag: "max-size", value: r.command.maxSize, dst: &f.MaxSize},
+	} {
+		if sz.value == "" {
+			continue
+		}
+		parsed, err := images.ParseSize(sz.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", sz.flag, err)
+		}
+		*sz.dst = parsed
+	}
+
+	return &f, nil
+}
+
 type command struct {
-	root      *cobra.Command
-	filePath  string
-	imageName string
-	imageID   string
+	root               *cobra.Command
+	activityLimit      int
+	actor              string
+	all                bool
+	allowedTypes       []string
+	batchSize          int
+	cache              bool
+	cacheControl       string
+	capturedAfter      string
+	capturedBefore     string
+	check              bool
+	checksums          bool
+	commandName        string
+	concurrency        int
+	contentDisposition string
+	contentType        string
+	createdAfter       string
+	createdBefore      string
+	cursor             string
+	days               int
+	deleteOriginals    bool
+	desc               bool
+	dir                string
+	dryRun             bool
+	etag               string
+	expires            time.Duration
+	failed             bool
+	filePath           string
+	follow             bool
+	force              bool
+	from               string
+	gcGrace            time.Duration
+	grace              time.Duration
+	idempotencyKey     string
+	imageName          string
+	imageNames         []string
+	imageID            string
+	imageIDs           []string
+	length             int64
+	limit              int
+	manifest           string
+	maxSize            string
+	minSize            string
+	namePrefix         string
+	offset             int64
+	onConflict         string
+	pipeline           string
+	public             bool
+	recursive          bool
+	relativeNames      bool
+	replace            bool
+	resize             string
+	resume             bool
+	removeMissing      bool
+	reportPath         string
+	s3Meta             map[string]string
+	sha256             string
+	since              string
+	sizes              string
+	skipUnchanged      bool
+	sort               string
+	sse                string
+	sseKMSKeyID        string
+	status             bool
+	storage            string
+	storageClass       string
+	stripMetadata      bool
+	subject            string
+	tags               []string
+	text               string
+	tier               string
+	to                 string
+	url                string
+	wait               bool
+	watermark          bool
+	widths             []int
+	yes                bool
 }
 
 func rootCmd() *cobra.Command {
@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/filesystem"
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/images/service"
+	"github.com/itsHabib/sim/internal/memory"
+)
+
+// newTestRunner returns a runner whose service keeps its records in memory
+// and its objects in a temp dir.
+func newTestRunner(t *testing.T, opts ...service.Option) (*Runner, *memory.Records) {
+	t.Helper()
+
+	records, err := memory.NewRecords(zap.NewNop())
+	require.NoError(t, err)
+	store, err := filesystem.NewStore(zap.NewNop(), t.TempDir())
+	require.NoError(t, err)
+	svc, err := service.New(zap.NewNop(), "sim", records, records, images.Stores{"sim": store}, opts...)
+	require.NoError(t, err)
+
+	return NewRunner(zap.NewNop(), svc), records
+}
+
+// run runs the runner's command with the args.
+func run(r *Runner, args ...string) error {
+	r.command.root.SetArgs(args)
+	return r.Run(context.Background())
+}
+
+// allRecords returns the records of the images which were uploaded.
+func allRecords(t *testing.T, records *memory.Records) []images.Record {
+	t.Helper()
+
+	page, err := records.List(context.Background(), images.ListOptions{})
+	if err == images.ErrRecordNotFound {
+		return nil
+	}
+	require.NoError(t, err)
+
+	return page.Records
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// sessionDir is the directory, relative to the user's home directory, which
+// holds the state of resumable uploads.
+const sessionDir = ".sim/uploads"
+
+// sessionPath returns the path of the state of the resumable upload of the
+// file. Uploads of the same file under the same name to the same storage
+// share their state.
+func sessionPath(file batchFile, storage string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(file.path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs + "\x00" + file.name + "\x00" + storage))
+
+	return filepath.Join(home, sessionDir, hex.EncodeToString(sum[:16])+".json"), nil
+}
+
+// loadSession reads the upload session at the path, an empty session is
+// returned when there is none.
+func loadSession(path string) (*images.UploadSession, error) {
+	var session images.UploadSession
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return &session, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// saveSession writes the upload session to the path, replacing the previous
+// state at once so that an interrupted write never corrupts it.
+func saveSession(path string, session *images.UploadSession) error {
+	b, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// srcsetHTML returns the HTML img of the candidates, narrowest first, whose
+// src is the widest candidate for browsers without srcset support.
+func srcsetHTML(candidates []images.SrcsetCandidate, alt, sizes string) string {
+	set := make([]string, len(candidates))
+	for i, c := range candidates {
+		set[i] = fmt.Sprintf("%s %dw", c.URL, c.Width)
+	}
+	var src string
+	if len(candidates) > 0 {
+		src = candidates[len(candidates)-1].URL
+	}
+
+	return fmt.Sprintf(`<img src="%s" srcset="%s" sizes="%s" alt="%s">`,
+		html.EscapeString(src),
+		html.EscapeString(strings.Join(set, ", ")),
+		html.EscapeString(sizes),
+		html.EscapeString(alt),
+	)
+}
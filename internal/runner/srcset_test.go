@@ -0,0 +1,21 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+func Test_srcsetHTML(t *testing.T) {
+	candidates := []images.SrcsetCandidate{
+		{Width: 320, URL: "https://cdn.example.com/images/1/variant/320x0.jpg"},
+		{Width: 640, URL: "https://cdn.example.com/images/1/variant/640x0.jpg?a=1&b=2"},
+	}
+
+	got := srcsetHTML(candidates, `"cat".jpg`, "(max-width: 640px) 100vw, 640px")
+	assert.Equal(t, `<img src="https://cdn.example.com/images/1/variant/640x0.jpg?a=1&amp;b=2" `+
+		`srcset="https://cdn.example.com/images/1/variant/320x0.jpg 320w, https://cdn.example.com/images/1/variant/640x0.jpg?a=1&amp;b=2 640w" `+
+		`sizes="(max-width: 640px) 100vw, 640px" alt="&#34;cat&#34;.jpg">`, got)
+}
@@ -0,0 +1,290 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/images/service"
+)
+
+const svg = `<svg xmlns="http://www.w3.org/2000/svg" width="8" height="8"><rect width="8" height="8" fill="red"/></svg>`
+
+// bmp is the start of a bmp, whose format can not be decoded.
+const bmp = "BM\x46\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00"
+
+func Test_Runner_Upload_File(t *testing.T) {
+	t.Run("upload should compress an svg file with COMPRESS", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "logo.svg")
+		require.NoError(t, ioutil.WriteFile(path, []byte(svg), 0o600))
+		r, records := newTestRunner(t, service.WithCompression())
+
+		require.NoError(t, run(r, "upload", "--file", path))
+
+		recs := allRecords(t, records)
+		require.Len(t, recs, 1)
+		assert.Equal(t, "logo.svg", recs[0].Name)
+		assert.Equal(t, "image/svg+xml", recs[0].ContentType)
+		assert.Equal(t, "gzip", recs[0].ContentEncoding)
+	})
+
+	t.Run("upload should reject a file which is not an image", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notes.txt")
+		require.NoError(t, ioutil.WriteFile(path, []byte("notes"), 0o600))
+		r, records := newTestRunner(t)
+
+		err := run(r, "upload", "--file", path)
+
+		assert.True(t, errors.Is(err, image.ErrFormat), err)
+		assert.Empty(t, allRecords(t, records))
+	})
+
+	t.Run("upload should reject a file with the extension of an image which can not be decoded", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a.png")
+		require.NoError(t, ioutil.WriteFile(path, []byte("not a png"), 0o600))
+		r, records := newTestRunner(t)
+
+		err := run(r, "upload", "--file", path)
+
+		assert.True(t, errors.Is(err, image.ErrFormat), err)
+		assert.Empty(t, allRecords(t, records))
+	})
+
+	t.Run("upload should store an allowed type which can not be decoded without VALIDATE_IMAGES", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a.bmp")
+		require.NoError(t, ioutil.WriteFile(path, []byte(bmp), 0o600))
+		r, records := newTestRunner(t, service.WithAllowedTypes("image/bmp"))
+
+		require.NoError(t, run(r, "upload", "--file", path))
+
+		recs := allRecords(t, records)
+		require.Len(t, recs, 1)
+		assert.Equal(t, "image/bmp", recs[0].ContentType)
+	})
+
+	t.Run("upload should reject a file which can not be decoded with VALIDATE_IMAGES", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a.bmp")
+		require.NoError(t, ioutil.WriteFile(path, []byte(bmp), 0o600))
+		r, records := newTestRunner(t, service.WithImageValidation())
+
+		err := run(r, "upload", "--file", path)
+
+		assert.True(t, errors.Is(err, images.ErrInvalidImage), err)
+		assert.Empty(t, allRecords(t, records))
+	})
+}
+
+func Test_Runner_Upload_Dir(t *testing.T) {
+	t.Run("upload should upload the files of a directory which are not jpegs, pngs or gifs", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "logo.svg"), []byte(svg), 0o600))
+		r, records := newTestRunner(t, service.WithCompression())
+
+		require.NoError(t, run(r, "upload", "--dir", dir))
+
+		recs := allRecords(t, records)
+		require.Len(t, recs, 1)
+		assert.Equal(t, "logo.svg", recs[0].Name)
+		assert.Equal(t, "gzip", recs[0].ContentEncoding)
+	})
+
+	t.Run("upload should skip the files of a directory which are not images", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "logo.svg"), []byte(svg), 0o600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".DS_Store"), []byte("Bud1"), 0o600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o600))
+		r, records := newTestRunner(t)
+
+		require.NoError(t, run(r, "upload", "--dir", dir))
+
+		recs := allRecords(t, records)
+		require.Len(t, recs, 1)
+		assert.Equal(t, "logo.svg", recs[0].Name)
+	})
+
+	t.Run("upload should return an error for a directory without images", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0o600))
+		r, records := newTestRunner(t)
+
+		assert.Error(t, run(r, "upload", "--dir", dir))
+		assert.Empty(t, allRecords(t, records))
+	})
+}
+
+func Test_Runner_Upload_Dir_Recursive(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2023", "trip"), 0o700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.svg"), []byte(svg), 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "2023", "trip", "b.svg"), []byte(svg), 0o600))
+	for _, tc := range []struct {
+		desc      string
+		args      []string
+		wantNames []string
+		wantPaths []string
+	}{
+		{
+			desc:      "upload should only upload the files of the directory itself",
+			wantNames: []string{"a.svg"},
+			wantPaths: []string{"a.svg"},
+		},
+		{
+			desc:      "upload should upload the files of the subdirectories with --recursive",
+			args:      []string{"--recursive"},
+			wantNames: []string{"a.svg", "b.svg"},
+			wantPaths: []string{"2023/trip/b.svg", "a.svg"},
+		},
+		{
+			desc:      "upload should name the images after their relative paths with --relative-names",
+			args:      []string{"--recursive", "--relative-names"},
+			wantNames: []string{"2023/trip/b.svg", "a.svg"},
+			wantPaths: []string{"2023/trip/b.svg", "a.svg"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			r, records := newTestRunner(t)
+
+			require.NoError(t, run(r, append([]string{"upload", "--dir", dir}, tc.args...)...))
+
+			var names, paths []string
+			for _, rec := range allRecords(t, records) {
+				names, paths = append(names, rec.Name), append(paths, rec.Path)
+			}
+			sort.Strings(names)
+			sort.Strings(paths)
+			assert.Equal(t, tc.wantNames, names)
+			assert.Equal(t, tc.wantPaths, paths)
+		})
+	}
+
+	t.Run("upload should return an error for an empty directory", func(t *testing.T) {
+		r, _ := newTestRunner(t)
+
+		assert.Error(t, run(r, "upload", "--dir", t.TempDir()))
+	})
+}
+
+func Test_Runner_Upload_Glob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.svg", "b.svg", "c.txt"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(svg), 0o600))
+	}
+	for _, tc := range []struct {
+		desc      string
+		args      []string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			desc:      "upload should upload every file matching the pattern named after its basename",
+			args:      []string{"--file", filepath.Join(dir, "*.svg")},
+			wantNames: []string{"a.svg", "b.svg"},
+		},
+		{
+			desc:    "upload should return an error when no file matches the pattern",
+			args:    []string{"--file", filepath.Join(dir, "*.png")},
+			wantErr: true,
+		},
+		{
+			desc:    "upload should return an error when --name is set with a pattern",
+			args:    []string{"--file", filepath.Join(dir, "*.svg"), "--name", "x"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			r, records := newTestRunner(t)
+
+			err := run(r, append([]string{"upload"}, tc.args...)...)
+			if tc.wantErr {
+				assert.Error(t, err)
+				assert.Empty(t, allRecords(t, records))
+				return
+			}
+			require.NoError(t, err)
+
+			var names []string
+			for _, rec := range allRecords(t, records) {
+				names = append(names, rec.Name)
+			}
+			sort.Strings(names)
+			assert.Equal(t, tc.wantNames, names)
+		})
+	}
+}
+
+func Test_Runner_Upload_URL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.svg" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, svg)
+	}))
+	defer srv.Close()
+	for _, tc := range []struct {
+		desc     string
+		args     []string
+		wantName string
+		wantErr  string
+	}{
+		{
+			desc:     "upload should name the image after the last element of the url's path",
+			args:     []string{"--url", srv.URL + "/images/logo.svg?v=2"},
+			wantName: "logo.svg",
+		},
+		{
+			desc:     "upload should name the image after --name",
+			args:     []string{"--url", srv.URL + "/images/logo.svg", "--name", "brand.svg"},
+			wantName: "brand.svg",
+		},
+		{
+			desc:     "upload should accept a url without a file name with --name",
+			args:     []string{"--url", srv.URL + "/", "--name", "brand.svg"},
+			wantName: "brand.svg",
+		},
+		{
+			desc:    "upload should return an error for a url without a file name",
+			args:    []string{"--url", srv.URL + "/"},
+			wantErr: "--name is required",
+		},
+		{
+			desc:    "upload should return an error when the url does not respond with 200",
+			args:    []string{"--url", srv.URL + "/missing.svg"},
+			wantErr: "404 Not Found",
+		},
+		{
+			desc:    "upload should return an error for a url which is not http",
+			args:    []string{"--url", "ftp://example.com/a.svg"},
+			wantErr: "invalid --url",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			r, records := newTestRunner(t)
+
+			err := run(r, append([]string{"upload"}, tc.args...)...)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				assert.Empty(t, allRecords(t, records))
+				return
+			}
+			require.NoError(t, err)
+
+			recs := allRecords(t, records)
+			require.Len(t, recs, 1)
+			assert.Equal(t, tc.wantName, recs[0].Name)
+			assert.Equal(t, "image/svg+xml", recs[0].ContentType)
+		})
+	}
+}
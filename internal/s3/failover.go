@@ -0,0 +1,205 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const failoverLoggerName = "s3.failover"
+
+// FailoverStore provides an images.ObjectStore which reads from a replica
+// bucket, typically in a second region, when the primary bucket is
+// unavailable. Writes only go to the primary, the replica is expected to be
+// kept in sync by S3 replication.
+type FailoverStore struct {
+	logger  *zap.Logger
+	primary images.ObjectStore
+	replica images.ObjectStore
+}
+
+// NewFailoverStore returns an instantiated instance of a failover store which
+// has the following dependencies:
+//
+// logger: for structured logging
+//
+// primary: the store that is written to and read from first
+//
+// replica: the store that is read from when the primary is unavailable
+func NewFailoverStore(logger *zap.Logger, primary, replica images.ObjectStore) (*FailoverStore, error) {
+	s := FailoverStore{
+		logger:  logger.Named(failoverLoggerName),
+		primary: primary,
+		replica: replica,
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug("successfully initialized s3 failover store")
+
+	return &s, nil
+}
+
+func (s *FailoverStore) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "logger",
+			chk: func() bool { return s.logger != nil },
+		},
+		{
+			dep: "primary",
+			chk: func() bool { return s.primary != nil },
+		},
+		{
+			dep: "replica",
+			chk: func() bool { return s.replica != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize store due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Delete removes the object from the primary bucket.
+func (s *FailoverStore) Delete(ctx context.Context, key string) error {
+	return s.primary.Delete(ctx, key)
+}
+
+// Get downloads the object from the primary bucket, falling back to the
+// replica when the primary is unavailable.
+func (s *FailoverStore) Get(ctx context.Context, key string, stream io.WriterAt) (int64, error) {
+	n, err := s.primary.Get(ctx, key, stream)
+	if !isUnavailable(err) {
+		return n, err
+	}
+
+	s.logger.Warn("primary unavailable, downloading from replica", zap.String("key", key), zap.Error(err))
+
+	return s.replica.Get(ctx, key, stream)
+}
+
+// GetRange downloads the byte range of the object from the primary bucket,
+// falling back to the replica when the primary is unavailable.
+func (s *FailoverStore) GetRange(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error) {
+	// the bytes already written to w can not be taken back so a partial
+	// range is not retried
+	n, err := s.primary.GetRange(ctx, key, offset, length, w)
+	if !isUnavailable(err) || n > 0 {
+		return n, err
+	}
+
+	s.logger.Warn("primary unavailable, downloading range from replica", zap.String("key", key), zap.Error(err))
+
+	return s.replica.GetRange(ctx, key, offset, length, w)
+}
+
+// Head retrieves the object's metadata from the primary bucket, falling back
+// to the replica when the primary is unavailable.
+func (s *FailoverStore) Head(ctx context.Context, key string) (*images.ObjectInfo, error) {
+	info, err := s.primary.Head(ctx, key)
+	if !isUnavailable(err) {
+		return info, err
+	}
+
+	s.logger.Warn("primary unavailable, heading object from replica", zap.String("key", key), zap.Error(err))
+
+	return s.replica.Head(ctx, key)
+}
+
+// List lists the objects of the primary bucket, falling back to the replica
+// when the primary is unavailable.
+func (s *FailoverStore) List(ctx context.Context, prefix string) ([]images.ObjectInfo, error) {
+	objects, err := s.primary.List(ctx, prefix)
+	if !isUnavailable(err) {
+		return objects, err
+	}
+
+	s.logger.Warn("primary unavailable, listing objects from replica", zap.String("prefix", prefix), zap.Error(err))
+
+	return s.replica.List(ctx, prefix)
+}
+
+// Presign creates a URL against the primary bucket.
+func (s *FailoverStore) Presign(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return s.primary.Presign(ctx, key, expires)
+}
+
+// PresignPut creates an upload request against the primary bucket.
+func (s *FailoverStore) PresignPut(ctx context.Context, key string, expires time.Duration, opts images.PutOptions) (*images.PresignedRequest, error) {
+	return s.primary.PresignPut(ctx, key, expires, opts)
+}
+
+// URL returns the URL of the object in the primary bucket.
+func (s *FailoverStore) URL(ctx context.Context, key string) (string, error) {
+	return s.primary.URL(ctx, key)
+}
+
+// Put uploads the object to the primary bucket.
+func (s *FailoverStore) Put(ctx context.Context, key string, body io.Reader, opts images.PutOptions) error {
+	return s.primary.Put(ctx, key, body, opts)
+}
+
+// PutParts uploads the object to the primary bucket in parts.
+func (s *FailoverStore) PutParts(ctx context.Context, key string, body io.ReaderAt, opts images.PutOptions, session *images.UploadSession, checkpoint func() error) error {
+	return s.primary.PutParts(ctx, key, body, opts, session, checkpoint)
+}
+
+// SetTags tags the object in the primary bucket, S3 replication copies the
+// tags to the replica.
+func (s *FailoverStore) SetTags(ctx context.Context, key string, tags []string) error {
+	return s.primary.SetTags(ctx, key, tags)
+}
+
+// Restore restores the object in the primary bucket, the replica's copy is
+// archived separately by its own lifecycle rules.
+func (s *FailoverStore) Restore(ctx context.Context, key string, tier images.RestoreTier, days int) error {
+	return s.primary.Restore(ctx, key, tier, days)
+}
+
+// isUnavailable reports whether the error, returned once the SDK has
+// exhausted its retries, is a server error or timeout.
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() >= http.StatusInternalServerError {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_images "github.com/itsHabib/sim/internal/images/mocks"
+)
+
+func Test_FailoverStore_Get(t *testing.T) {
+	unavailable := fmt.Errorf("unable to download object: %w", &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}},
+		Err:      errors.New("slow down"),
+	})
+	for _, tc := range []struct {
+		desc    string
+		primary func(ctrl *gomock.Controller) images.ObjectStore
+		replica func(ctrl *gomock.Controller) images.ObjectStore
+		wantErr error
+	}{
+		{
+			desc: "Get() should not fall back to the replica when the object is not found",
+			primary: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(0), images.ErrObjectNotFound)
+
+				return s
+			},
+			replica: func(ctrl *gomock.Controller) images.ObjectStore { return mock_images.NewMockObjectStore(ctrl) },
+			wantErr: images.ErrObjectNotFound,
+		},
+		{
+			desc: "Get() should fall back to the replica when the primary returns a server error",
+			primary: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(0), unavailable)
+
+				return s
+			},
+			replica: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(10), nil)
+
+				return s
+			},
+		},
+		{
+			desc: "Get() should fall back to the replica when the primary times out",
+			primary: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(0), fmt.Errorf("unable to download object: %w", context.DeadlineExceeded))
+
+				return s
+			},
+			replica: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(10), nil)
+
+				return s
+			},
+		},
+		{
+			desc: "Get() - happy path",
+			primary: func(ctrl *gomock.Controller) images.ObjectStore {
+				s := mock_images.NewMockObjectStore(ctrl)
+				s.
+					EXPECT().
+					Get(gomock.Any(), "key", gomock.Any()).
+					Return(int64(10), nil)
+
+				return s
+			},
+			replica: func(ctrl *gomock.Controller) images.ObjectStore { return mock_images.NewMockObjectStore(ctrl) },
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			store, err := NewFailoverStore(zap.NewNop(), tc.primary(ctrl), tc.replica(ctrl))
+			require.NoError(t, err)
+
+			n, err := store.Get(context.Background(), "key", manager.NewWriteAtBuffer(nil))
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(10), n)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// Restore requests a copy of the archived object which can be read for the
+// number of days. Restoring an object which was already restored extends
+// how long its copy is kept.
+func (s *Store) Restore(ctx context.Context, key string, tier images.RestoreTier, days int) error {
+	logger := s.logger.With(zap.String("key", key), zap.String("tier", string(tier)), zap.Int("days", days))
+
+	input := s3.RestoreObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		RestoreRequest: &types.RestoreRequest{
+			Days: int32(days),
+			GlacierJobParameters: &types.GlacierJobParameters{
+				Tier: types.Tier(tier),
+			},
+		},
+	}
+	if _, err := s.sdk.client.RestoreObject(ctx, &input); err != nil {
+		switch {
+		case isNotFound(err):
+			logger.Error("object not found", zap.Error(err))
+			return images.ErrObjectNotFound
+		case hasCode(err, "RestoreAlreadyInProgress"):
+			logger.Error("restore already in progress", zap.Error(err))
+			return images.ErrRestoring
+		case hasCode(err, "InvalidObjectState"):
+			// the object is not in an archive class
+			logger.Error("object is not archived", zap.Error(err))
+			return images.ErrNotArchived
+		}
+		const msg = "unable to restore object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	logger.Info("requested object restore")
+
+	return nil
+}
+
+// isArchived reports whether objects in the storage class have to be
+// restored before they can be read.
+func isArchived(class types.StorageClass) bool {
+	return class == types.StorageClassGlacier || class == types.StorageClassDeepArchive
+}
+
+// parseRestore parses the x-amz-restore header of an archived object, i.e.
+// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT".
+func parseRestore(header string) *images.RestoreStatus {
+	status := images.RestoreStatus{
+		InProgress: strings.Contains(header, `ongoing-request="true"`),
+	}
+
+	const expiry = `expiry-date="`
+	i := strings.Index(header, expiry)
+	if i < 0 {
+		return &status
+	}
+	date := header[i+len(expiry):]
+	if j := strings.Index(date, `"`); j >= 0 {
+		if t, err := time.Parse(http.TimeFormat, date[:j]); err == nil {
+			status.ExpiresAt = &t
+		}
+	}
+
+	return &status
+}
+
+// archivedError returns ErrRestoring when the archived object is being
+// restored and ErrArchived otherwise, for a read which S3 refused as the
+// object is archived.
+func (s *Store) archivedError(ctx context.Context, key string, logger *zap.Logger) error {
+	info, err := s.Head(ctx, key)
+	if err == nil && info.Restore != nil && info.Restore.InProgress {
+		logger.Error("object is archived, restore in progress")
+		return images.ErrRestoring
+	}
+	logger.Error("object is archived")
+
+	return images.ErrArchived
+}
+
+// hasCode reports whether the error is an S3 error with the code.
+func hasCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
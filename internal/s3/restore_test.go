@@ -0,0 +1,145 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_s3 "github.com/itsHabib/sim/internal/s3/mocks"
+)
+
+func Test_Store_Restore(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		err     error
+		wantErr error
+	}{
+		{
+			desc: "Restore() should request a restore of the object in the tier",
+		},
+		{
+			desc:    "Restore() should return ErrRestoring when a restore is in progress",
+			err:     &smithy.GenericAPIError{Code: "RestoreAlreadyInProgress"},
+			wantErr: images.ErrRestoring,
+		},
+		{
+			desc:    "Restore() should return ErrNotArchived when the object is not archived",
+			err:     &smithy.GenericAPIError{Code: "InvalidObjectState"},
+			wantErr: images.ErrNotArchived,
+		},
+		{
+			desc:    "Restore() should return ErrObjectNotFound when the object does not exist",
+			err:     &smithy.GenericAPIError{Code: "NoSuchKey"},
+			wantErr: images.ErrObjectNotFound,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			c := mock_s3.NewMockClient(ctrl)
+			c.
+				EXPECT().
+				RestoreObject(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, i *s3.RestoreObjectInput, _ ...func(*s3.Options)) (*s3.RestoreObjectOutput, error) {
+					assert.Equal(t, "key", aws.ToString(i.Key))
+					assert.Equal(t, int32(3), i.RestoreRequest.Days)
+					assert.Equal(t, types.TierExpedited, i.RestoreRequest.GlacierJobParameters.Tier)
+					if tc.err != nil {
+						return nil, tc.err
+					}
+
+					return &s3.RestoreObjectOutput{}, nil
+				})
+			store, err := NewStore(zap.NewNop(), "bucket", mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.client = c
+
+			err = store.Restore(context.Background(), "key", images.RestoreExpedited, 3)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_Store_GetRange_Archived(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		restore *string
+		wantErr error
+	}{
+		{
+			desc:    "GetRange() should return ErrArchived when the archived object was not restored",
+			wantErr: images.ErrArchived,
+		},
+		{
+			desc:    "GetRange() should return ErrRestoring when the archived object is being restored",
+			restore: aws.String(`ongoing-request="true"`),
+			wantErr: images.ErrRestoring,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			c := mock_s3.NewMockClient(ctrl)
+			c.
+				EXPECT().
+				GetObject(gomock.Any(), gomock.Any()).
+				Return(nil, &smithy.GenericAPIError{Code: "InvalidObjectState"})
+			c.
+				EXPECT().
+				HeadObject(gomock.Any(), gomock.Any()).
+				Return(&s3.HeadObjectOutput{ETag: aws.String("etag"), StorageClass: types.StorageClassGlacier, Restore: tc.restore}, nil)
+			store, err := NewStore(zap.NewNop(), "bucket", mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.client = c
+
+			_, err = store.GetRange(context.Background(), "key", 0, 0, &bytes.Buffer{})
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_parseRestore(t *testing.T) {
+	expires := time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		desc   string
+		header string
+		want   *images.RestoreStatus
+	}{
+		{
+			desc:   "parseRestore() should report a restore in progress",
+			header: `ongoing-request="true"`,
+			want:   &images.RestoreStatus{InProgress: true},
+		},
+		{
+			desc:   "parseRestore() should report when the restored copy expires",
+			header: `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`,
+			want:   &images.RestoreStatus{ExpiresAt: &expires},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := parseRestore(tc.header)
+			assert.Equal(t, tc.want.InProgress, got.InProgress)
+			if tc.want.ExpiresAt == nil {
+				assert.Nil(t, got.ExpiresAt)
+				return
+			}
+			require.NotNil(t, got.ExpiresAt)
+			assert.True(t, tc.want.ExpiresAt.Equal(*got.ExpiresAt))
+		})
+	}
+}
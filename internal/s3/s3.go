@@ -1,14 +1,17 @@
 package s3
 
 import (
+	"context"
 	"io"
 
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 //go:generate go run github.com/golang/mock/mockgen -destination mocks/client.go github.com/itsHabib/sim/internal/s3 Client
 //go:generate go run github.com/golang/mock/mockgen -destination mocks/downloader.go github.com/itsHabib/sim/internal/s3 Downloader
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/presigner.go github.com/itsHabib/sim/internal/s3 Presigner
 //go:generate go run github.com/golang/mock/mockgen -destination mocks/uploader.go github.com/itsHabib/sim/internal/s3 Uploader
 
 // Client provides an abstraction to aid in mocking for unit tests
@@ -16,13 +19,52 @@ type Client interface {
 	// HeadObject retrieves metadata from an object without returning the object
 	// itself. This action is useful if you're only interested in an object's
 	// metadata.
-	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 
 	// DeleteObject removes the null version (if there is one) of an object and
 	// inserts a delete marker, which becomes the latest version of the object.
 	// If there isn't a null version, Amazon S3 does not remove any objects but
 	// will still respond that the command was successful.
-	DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+
+	// GetObject retrieves an object, or the byte range of it given by the
+	// Range header, as a stream.
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+
+	// ListObjectsV2 returns some or all (up to 1,000) of the objects in a
+	// bucket with each request.
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+
+	// PutObjectTagging sets the supplied tag-set to an object that already
+	// exists in a bucket, replacing its existing tags.
+	PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+
+	// RestoreObject restores a temporary copy of an archived object, i.e.
+	// one in the GLACIER storage class, which can be read for a number of
+	// days.
+	RestoreObject(ctx context.Context, params *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error)
+
+	// CreateMultipartUpload initiates a multipart upload and returns an
+	// upload ID which is used to upload and complete its parts.
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+
+	// UploadPart uploads a part in a multipart upload.
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+
+	// CompleteMultipartUpload completes a multipart upload by assembling
+	// previously uploaded parts.
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+}
+
+// Presigner provides an abstraction to aid in mocking for unit tests
+type Presigner interface {
+	// PresignGetObject is used to generate a presigned HTTP Request which
+	// contains presigned URL, signed headers and HTTP method used.
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+
+	// PresignPutObject is used to generate a presigned HTTP Request which
+	// uploads an object with the headers of the input.
+	PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
 }
 
 // Uploader provides an abstraction to aid in mocking for unit tests
@@ -31,7 +73,7 @@ type Uploader interface {
 	// smaller chunks and sending them in parallel across multiple goroutines.
 	// You can configure the buffer size and concurrency through the Uploader's
 	// parameters.
-	Upload(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error)
+	Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error)
 }
 
 // Downloader provides an abstraction to aid in mocking for unit tests
@@ -39,5 +81,5 @@ type Downloader interface {
 	// Download downloads an object in S3 and writes the payload into w using
 	// concurrent GET requests. The n int64 returned is the size of the object downloaded
 	// in bytes.
-	Download(w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (n int64, err error)
+	Download(ctx context.Context, w io.WriterAt, input *s3.GetObjectInput, options ...func(*manager.Downloader)) (n int64, err error)
 }
@@ -0,0 +1,621 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const storeLoggerName = "s3.store"
+
+// Store provides the S3 implementation of the images.ObjectStore.
+type Store struct {
+	addressing   addressing
+	bucket       string
+	configGetter images.ConfigGetter
+	logger       *zap.Logger
+	sdk          *sdk
+}
+
+// NewStore returns an instantiated instance of a store which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// bucket: the AWS bucket that holds the objects
+//
+// configGetter: for loading the AWS config
+//
+// optFns: optional overrides of the S3 client options i.e. a custom endpoint
+//
+// The AWS config is loaded and the SDK clients are created once here so that
+// the store is safe for concurrent use.
+func NewStore(logger *zap.Logger, bucket string, configGetter images.ConfigGetter, optFns ...func(*s3.Options)) (*Store, error) {
+	s := Store{
+		bucket:       bucket,
+		configGetter: configGetter,
+		logger:       logger.Named(storeLoggerName),
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.configGetter()
+	if err != nil {
+		const msg = "unable to get AWS config"
+		s.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	s.sdk = newSDK(s3.NewFromConfig(cfg, optFns...))
+	s.addressing = newAddressing(cfg.Region, optFns...)
+
+	s.logger.Debug("successfully initialized s3 store")
+
+	return &s, nil
+}
+
+func (s *Store) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "bucket",
+			chk: func() bool { return s.bucket != "" },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return s.logger != nil },
+		},
+		{
+			dep: "configGetter",
+			chk: func() bool { return s.configGetter != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize store due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Delete removes the object from the bucket. A missing object is not treated
+// as an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	input := s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+	if _, err := s.sdk.client.DeleteObject(ctx, &input); err != nil {
+		if isNotFound(err) {
+			logger.Info("object not found")
+			return nil
+		}
+		const msg = "unable to delete object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// Get downloads the object into the stream, returning the number of bytes
+// downloaded.
+func (s *Store) Get(ctx context.Context, key string, stream io.WriterAt) (int64, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	input := s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+	n, err := s.sdk.downloader.Download(ctx, stream, &input)
+	if err != nil {
+		switch {
+		case isNotFound(err):
+			logger.Error("object not found", zap.Error(err))
+			return 0, images.ErrObjectNotFound
+		case hasCode(err, "InvalidObjectState"):
+			return 0, s.archivedError(ctx, key, logger)
+		}
+		const msg = "unable to download object"
+		logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// GetRange downloads the byte range of the object into w with a single
+// ranged GetObject, returning the number of bytes downloaded.
+func (s *Store) GetRange(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error) {
+	logger := s.logger.With(zap.String("key", key), zap.Int64("offset", offset), zap.Int64("length", length))
+
+	if offset < 0 || length < 0 {
+		logger.Error("range is not satisfiable")
+		return 0, images.ErrInvalidRange
+	}
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		rng += strconv.FormatInt(offset+length-1, 10)
+	}
+
+	input := s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Range:  &rng,
+	}
+	resp, err := s.sdk.client.GetObject(ctx, &input)
+	if err != nil {
+		switch {
+		case isNotFound(err):
+			logger.Error("object not found", zap.Error(err))
+			return 0, images.ErrObjectNotFound
+		case isInvalidRange(err):
+			logger.Error("range is not satisfiable", zap.Error(err))
+			return 0, images.ErrInvalidRange
+		case hasCode(err, "InvalidObjectState"):
+			return 0, s.archivedError(ctx, key, logger)
+		}
+		const msg = "unable to get object range"
+		logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		const msg = "unable to download object range"
+		logger.Error(msg, zap.Error(err))
+		return n, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// Head retrieves the metadata of the object.
+func (s *Store) Head(ctx context.Context, key string) (*images.ObjectInfo, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	input := s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+	resp, err := s.sdk.client.HeadObject(ctx, &input)
+	if err != nil {
+		if isNotFound(err) {
+			logger.Error("object not found", zap.Error(err))
+			return nil, images.ErrObjectNotFound
+		}
+		const msg = "unable to head object"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	if resp.ETag == nil {
+		const msg = "etag is nil"
+		logger.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	info := images.ObjectInfo{
+		ETag:                 *resp.ETag,
+		SizeInBytes:          resp.ContentLength,
+		ServerSideEncryption: string(resp.ServerSideEncryption),
+		StorageClass:         string(resp.StorageClass),
+	}
+	if resp.SSEKMSKeyId != nil {
+		info.KMSKeyID = *resp.SSEKMSKeyId
+	}
+	// S3 only reports the storage class of objects which are not STANDARD
+	if info.StorageClass == "" {
+		info.StorageClass = string(types.StorageClassStandard)
+	}
+	if isArchived(types.StorageClass(info.StorageClass)) {
+		info.Archived = true
+		if resp.Restore != nil {
+			info.Restore = parseRestore(*resp.Restore)
+		}
+	}
+
+	return &info, nil
+}
+
+// List returns the objects in the bucket whose keys start with the prefix.
+func (s *Store) List(ctx context.Context, prefix string) ([]images.ObjectInfo, error) {
+	logger := s.logger.With(zap.String("prefix", prefix))
+
+	input := s3.ListObjectsV2Input{
+		Bucket: &s.bucket,
+		Prefix: &prefix,
+	}
+	var objects []images.ObjectInfo
+	p := s3.NewListObjectsV2Paginator(s.sdk.client, &input)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			const msg = "unable to list objects"
+			logger.Error(msg, zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+		for _, obj := range page.Contents {
+			info := images.ObjectInfo{SizeInBytes: obj.Size}
+			if obj.Key != nil {
+				info.Key = *obj.Key
+			}
+			if obj.ETag != nil {
+				info.ETag = *obj.ETag
+			}
+			if obj.LastModified != nil {
+				info.LastModified = *obj.LastModified
+			}
+			objects = append(objects, info)
+		}
+	}
+
+	return objects, nil
+}
+
+// Presign creates a presigned GET URL for the object which is valid for the
+// given duration.
+func (s *Store) Presign(ctx context.Context, key string, expires time.Duration) (string, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	input := s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+	req, err := s.sdk.presigner.PresignGetObject(ctx, &input, s3.WithPresignExpires(expires))
+	if err != nil {
+		const msg = "unable to presign request"
+		logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+
+	return req.URL, nil
+}
+
+// PresignPut creates a presigned PUT request uploading the object with the
+// attributes of the options, which are sent as signed headers.
+func (s *Store) PresignPut(ctx context.Context, key string, expires time.Duration, opts images.PutOptions) (*images.PresignedRequest, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	input := s.putObjectInput(key, nil, opts)
+	req, err := s.sdk.presigner.PresignPutObject(ctx, &input, s3.WithPresignExpires(expires))
+	if err != nil {
+		const msg = "unable to presign request"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	headers := make(map[string]string, len(req.SignedHeader))
+	for name, values := range req.SignedHeader {
+		// the client sends the host of the url
+		if strings.EqualFold(name, "Host") {
+			continue
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+
+	return &images.PresignedRequest{Method: req.Method, URL: req.URL, Headers: headers}, nil
+}
+
+// Put uploads the body to the bucket under the key with the content type of
+// the options. The object is private unless the options make it public.
+func (s *Store) Put(ctx context.Context, key string, body io.Reader, opts images.PutOptions) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	input := s.putObjectInput(key, body, opts)
+	if _, err := s.sdk.uploader.Upload(ctx, &input); err != nil {
+		const msg = "unable to upload object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// putObjectInput returns the input uploading the body under the key with
+// the attributes of the options.
+func (s *Store) putObjectInput(key string, body io.Reader, opts images.PutOptions) s3.PutObjectInput {
+	input := s3.PutObjectInput{
+		ACL:    types.ObjectCannedACLPrivate,
+		Body:   body,
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+	if opts.Public {
+		input.ACL = types.ObjectCannedACLPublicRead
+	}
+	if opts.ContentType != "" {
+		input.ContentType = &opts.ContentType
+	}
+	if opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(opts.ServerSideEncryption)
+	}
+	if opts.KMSKeyID != "" {
+		input.SSEKMSKeyId = &opts.KMSKeyID
+	}
+	if opts.StorageClass != "" {
+		input.StorageClass = types.StorageClass(opts.StorageClass)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = &opts.CacheControl
+	}
+	if opts.ContentDisposition != "" {
+		input.ContentDisposition = &opts.ContentDisposition
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = opts.Metadata
+	}
+	if len(opts.Tags) > 0 {
+		tagging := objectTagging(opts.Tags)
+		input.Tagging = &tagging
+	}
+
+	return input
+}
+
+// PutParts uploads the body to the bucket under the key as a multipart
+// upload, resuming the session's upload when it has one. Parts are uploaded
+// one at a time so the session never records a part S3 does not have.
+func (s *Store) PutParts(ctx context.Context, key string, body io.ReaderAt, opts images.PutOptions, session *images.UploadSession, checkpoint func() error) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	if session.UploadID == "" {
+		uploadID, err := s.createMultipartUpload(ctx, key, opts)
+		if err != nil {
+			const msg = "unable to create multipart upload"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		session.Start(uploadID)
+		if err := checkpoint(); err != nil {
+			const msg = "unable to checkpoint upload"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	}
+	logger = logger.With(zap.String("uploadId", session.UploadID))
+
+	for n := int32(1); n <= session.PartCount(); n++ {
+		if session.Uploaded(n) {
+			continue
+		}
+		section := session.Section(body, n)
+		input := s3.UploadPartInput{
+			Body:          section,
+			Bucket:        &s.bucket,
+			ContentLength: section.Size(),
+			Key:           &key,
+			PartNumber:    n,
+			UploadId:      &session.UploadID,
+		}
+		resp, err := s.sdk.client.UploadPart(ctx, &input)
+		if err != nil {
+			if isNoSuchUpload(err) {
+				logger.Error("multipart upload not found", zap.Error(err))
+				return images.ErrUploadExpired
+			}
+			const msg = "unable to upload part"
+			logger.Error(msg, zap.Int32("part", n), zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+		part := images.Part{Number: n}
+		if resp.ETag != nil {
+			part.ETag = *resp.ETag
+		}
+		session.Parts = append(session.Parts, part)
+		if err := checkpoint(); err != nil {
+			const msg = "unable to checkpoint upload"
+			logger.Error(msg, zap.Error(err))
+			return fmt.Errorf(msg+": %w", err)
+		}
+	}
+
+	parts := make([]types.CompletedPart, len(session.Parts))
+	for i, p := range session.Parts {
+		p := p
+		parts[i] = types.CompletedPart{ETag: &p.ETag, PartNumber: p.Number}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	input := s3.CompleteMultipartUploadInput{
+		Bucket:          &s.bucket,
+		Key:             &key,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		UploadId:        &session.UploadID,
+	}
+	if _, err := s.sdk.client.CompleteMultipartUpload(ctx, &input); err != nil {
+		if isNoSuchUpload(err) {
+			logger.Error("multipart upload not found", zap.Error(err))
+			return images.ErrUploadExpired
+		}
+		const msg = "unable to complete multipart upload"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// createMultipartUpload starts a multipart upload of the object with the
+// attributes of the options and returns its ID.
+func (s *Store) createMultipartUpload(ctx context.Context, key string, opts images.PutOptions) (string, error) {
+	input := s3.CreateMultipartUploadInput{
+		ACL:    types.ObjectCannedACLPrivate,
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+	if opts.Public {
+		input.ACL = types.ObjectCannedACLPublicRead
+	}
+	if opts.ContentType != "" {
+		input.ContentType = &opts.ContentType
+	}
+	if opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(opts.ServerSideEncryption)
+	}
+	if opts.KMSKeyID != "" {
+		input.SSEKMSKeyId = &opts.KMSKeyID
+	}
+	if opts.StorageClass != "" {
+		input.StorageClass = types.StorageClass(opts.StorageClass)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = &opts.CacheControl
+	}
+	if opts.ContentDisposition != "" {
+		input.ContentDisposition = &opts.ContentDisposition
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = opts.Metadata
+	}
+	if len(opts.Tags) > 0 {
+		tagging := objectTagging(opts.Tags)
+		input.Tagging = &tagging
+	}
+
+	resp, err := s.sdk.client.CreateMultipartUpload(ctx, &input)
+	if err != nil {
+		return "", err
+	}
+	if resp.UploadId == nil {
+		return "", errors.New("no upload id returned")
+	}
+
+	return *resp.UploadId, nil
+}
+
+// SetTags replaces the tags of the object with the image's tags.
+func (s *Store) SetTags(ctx context.Context, key string, tags []string) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	tagSet := make([]types.Tag, 0, len(tags))
+	for k, v := range images.ObjectTags(tags) {
+		k, v := k, v
+		tagSet = append(tagSet, types.Tag{Key: &k, Value: &v})
+	}
+	sort.Slice(tagSet, func(i, j int) bool { return *tagSet[i].Key < *tagSet[j].Key })
+
+	input := s3.PutObjectTaggingInput{
+		Bucket:  &s.bucket,
+		Key:     &key,
+		Tagging: &types.Tagging{TagSet: tagSet},
+	}
+	if _, err := s.sdk.client.PutObjectTagging(ctx, &input); err != nil {
+		if isNotFound(err) {
+			logger.Error("object not found", zap.Error(err))
+			return images.ErrObjectNotFound
+		}
+		const msg = "unable to tag object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+type sdk struct {
+	client     Client
+	downloader Downloader
+	presigner  Presigner
+	uploader   Uploader
+}
+
+func newSDK(client *s3.Client) *sdk {
+	return &sdk{
+		client:     client,
+		downloader: manager.NewDownloader(client),
+		presigner:  s3.NewPresignClient(client),
+		uploader:   manager.NewUploader(client),
+	}
+}
+
+// WithEndpoint returns the S3 client options which target a custom endpoint
+// i.e. localstack, using path style addressing.
+func WithEndpoint(endpoint string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.EndpointResolver = s3.EndpointResolverFromURL(endpoint)
+		o.UsePathStyle = true
+	}
+}
+
+// WithAccelerate returns the S3 client options which route uploads and
+// downloads through the bucket's Transfer Acceleration endpoint. Acceleration
+// must be enabled on the bucket.
+func WithAccelerate() func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.UseAccelerate = true
+	}
+}
+
+// objectTagging returns the tags as the URL encoded tag set S3 takes on
+// upload.
+func objectTagging(tags []string) string {
+	values := make(url.Values, len(tags))
+	for k, v := range images.ObjectTags(tags) {
+		values.Set(k, v)
+	}
+
+	return values.Encode()
+}
+
+// isNoSuchUpload reports whether the multipart upload of the request was
+// aborted or completed.
+func isNoSuchUpload(err error) bool {
+	var noSuchUpload *types.NoSuchUpload
+	if errors.As(err, &noSuchUpload) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchUpload"
+}
+
+func isInvalidRange(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange"
+}
+
+func isNotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	// requests other than GetObject report a missing key by its code only
+	return strings.Contains(apiErr.ErrorCode(), "NotFound") || apiErr.ErrorCode() == "NoSuchKey"
+}
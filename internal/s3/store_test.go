@@ -0,0 +1,681 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_s3 "github.com/itsHabib/sim/internal/s3/mocks"
+)
+
+func Test_NewStore(t *testing.T) {
+	for _, tc := range []struct {
+		desc         string
+		configGetter images.ConfigGetter
+		wantErr      bool
+	}{
+		{
+			desc:         "NewStore() should return an error when failing to get the config",
+			configGetter: func() (aws.Config, error) { return aws.Config{}, errors.New("random") },
+			wantErr:      true,
+		},
+		{
+			desc:         "NewStore() should create the SDK clients",
+			configGetter: mockConfigGetter,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			store, err := NewStore(zap.NewNop(), "bucket", tc.configGetter)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, store.sdk.client)
+			assert.NotNil(t, store.sdk.downloader)
+			assert.NotNil(t, store.sdk.presigner)
+			assert.NotNil(t, store.sdk.uploader)
+		})
+	}
+}
+
+func Test_Store_Delete(t *testing.T) {
+	bucket := "bucket"
+	for _, tc := range []struct {
+		desc    string
+		client  func(ctrl *gomock.Controller) Client
+		wantErr bool
+	}{
+		{
+			desc: "Delete() should return an error when failing to delete the object.",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					DeleteObject(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("random"))
+
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Delete() should not return an error when the object is not found.",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					DeleteObject(gomock.Any(), gomock.Any()).
+					Return(nil, &smithy.GenericAPIError{Code: "NotFound"})
+
+				return c
+			},
+		},
+		{
+			desc: "Delete() - happy path",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					DeleteObject(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+						assert.Equal(t, "key", aws.ToString(i.Key))
+						assert.Equal(t, bucket, aws.ToString(i.Bucket))
+
+						return nil, nil
+					})
+
+				return c
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			store, err := NewStore(zap.NewNop(), bucket, mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.client = tc.client(ctrl)
+
+			err = store.Delete(context.Background(), "key")
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Store_Get(t *testing.T) {
+	bucket := "bucket"
+	for _, tc := range []struct {
+		desc       string
+		downloader func(t *testing.T, ctrl *gomock.Controller) Downloader
+		wantErr    error
+	}{
+		{
+			desc: "Get() should return an error when failing to download the object.",
+			downloader: func(t *testing.T, ctrl *gomock.Controller) Downloader {
+				d := mock_s3.NewMockDownloader(ctrl)
+				d.
+					EXPECT().
+					Download(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(int64(0), errors.New("random"))
+
+				return d
+			},
+			wantErr: errors.New("random"),
+		},
+		{
+			desc: "Get() should return ErrObjectNotFound when the key does not exist.",
+			downloader: func(t *testing.T, ctrl *gomock.Controller) Downloader {
+				d := mock_s3.NewMockDownloader(ctrl)
+				d.
+					EXPECT().
+					Download(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(int64(0), &types.NoSuchKey{})
+
+				return d
+			},
+			wantErr: images.ErrObjectNotFound,
+		},
+		{
+			desc: "Get() - happy path",
+			downloader: func(t *testing.T, ctrl *gomock.Controller) Downloader {
+				d := mock_s3.NewMockDownloader(ctrl)
+				d.
+					EXPECT().
+					Download(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ io.WriterAt, i *s3.GetObjectInput, _ ...func(*manager.Downloader)) (int64, error) {
+						require.NotNil(t, i)
+						assert.Equal(t, "key", aws.ToString(i.Key))
+						assert.Equal(t, bucket, aws.ToString(i.Bucket))
+
+						return 10, nil
+					})
+
+				return d
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			store, err := NewStore(zap.NewNop(), bucket, mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.downloader = tc.downloader(t, ctrl)
+
+			n, err := store.Get(context.Background(), "key", manager.NewWriteAtBuffer(nil))
+			switch {
+			case tc.wantErr == images.ErrObjectNotFound:
+				assert.Equal(t, images.ErrObjectNotFound, err)
+			case tc.wantErr != nil:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, int64(10), n)
+			}
+		})
+	}
+}
+
+func Test_Store_GetRange(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		offset    int64
+		length    int64
+		err       error
+		wantRange string
+		wantErr   error
+	}{
+		{
+			desc:      "GetRange() should download the range with a single GetObject",
+			offset:    7,
+			length:    5,
+			wantRange: "bytes=7-11",
+		},
+		{
+			desc:      "GetRange() should download the rest of the object when the length is 0",
+			offset:    7,
+			wantRange: "bytes=7-",
+		},
+		{
+			desc:      "GetRange() should return ErrObjectNotFound when the key does not exist",
+			err:       &types.NoSuchKey{},
+			wantRange: "bytes=0-",
+			wantErr:   images.ErrObjectNotFound,
+		},
+		{
+			desc:      "GetRange() should return ErrInvalidRange when the range is not satisfiable",
+			offset:    100,
+			err:       &smithy.GenericAPIError{Code: "InvalidRange"},
+			wantRange: "bytes=100-",
+			wantErr:   images.ErrInvalidRange,
+		},
+		{
+			desc:    "GetRange() should return ErrInvalidRange for a negative offset",
+			offset:  -1,
+			wantErr: images.ErrInvalidRange,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			c := mock_s3.NewMockClient(ctrl)
+			if tc.wantRange != "" {
+				c.
+					EXPECT().
+					GetObject(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+						assert.Equal(t, "key", aws.ToString(i.Key))
+						assert.Equal(t, tc.wantRange, aws.ToString(i.Range))
+						if tc.err != nil {
+							return nil, tc.err
+						}
+
+						return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("World"))}, nil
+					})
+			}
+			store, err := NewStore(zap.NewNop(), "bucket", mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.client = c
+
+			var buf bytes.Buffer
+			n, err := store.GetRange(context.Background(), "key", tc.offset, tc.length, &buf)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), n)
+			assert.Equal(t, "World", buf.String())
+		})
+	}
+}
+
+func Test_Store_Head(t *testing.T) {
+	bucket := "bucket"
+	for _, tc := range []struct {
+		desc    string
+		client  func(ctrl *gomock.Controller) Client
+		want    *images.ObjectInfo
+		wantErr bool
+	}{
+		{
+			desc: "Head() should return an error when failing to head the object.",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					HeadObject(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("random"))
+
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Head() should return an error when the etag is missing.",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					HeadObject(gomock.Any(), gomock.Any()).
+					Return(&s3.HeadObjectOutput{ContentLength: 1024}, nil)
+
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Head() - happy path",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					HeadObject(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+						assert.Equal(t, "key", aws.ToString(input.Key))
+						assert.Equal(t, bucket, aws.ToString(input.Bucket))
+
+						return &s3.HeadObjectOutput{
+							ContentLength: 1024,
+							ETag:          aws.String("etag"),
+						}, nil
+					})
+
+				return c
+			},
+			want: &images.ObjectInfo{
+				ETag:         "etag",
+				SizeInBytes:  1024,
+				StorageClass: "STANDARD",
+			},
+		},
+		{
+			desc: "Head() should return the server-side encryption and storage class of the object",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					HeadObject(gomock.Any(), gomock.Any()).
+					Return(&s3.HeadObjectOutput{
+						ContentLength:        1024,
+						ETag:                 aws.String("etag"),
+						ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+						SSEKMSKeyId:          aws.String("key-id"),
+						StorageClass:         types.StorageClass("GLACIER_IR"),
+					}, nil)
+
+				return c
+			},
+			want: &images.ObjectInfo{
+				ETag:                 "etag",
+				SizeInBytes:          1024,
+				ServerSideEncryption: "aws:kms",
+				KMSKeyID:             "key-id",
+				StorageClass:         "GLACIER_IR",
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			store, err := NewStore(zap.NewNop(), bucket, mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.client = tc.client(ctrl)
+
+			info, err := store.Head(context.Background(), "key")
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, info)
+			}
+		})
+	}
+}
+
+func Test_Store_Put(t *testing.T) {
+	bucket := "bucket"
+	body := strings.NewReader("hw")
+	for _, tc := range []struct {
+		desc     string
+		public   bool
+		uploader func(t *testing.T, ctrl *gomock.Controller) Uploader
+		wantErr  bool
+	}{
+		{
+			desc: "Put() should return an error when failing to upload",
+			uploader: func(t *testing.T, ctrl *gomock.Controller) Uploader {
+				u := mock_s3.NewMockUploader(ctrl)
+				u.
+					EXPECT().
+					Upload(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("random"))
+
+				return u
+			},
+			wantErr: true,
+		},
+		{
+			desc:   "Put() should upload a public object with the public-read ACL",
+			public: true,
+			uploader: func(t *testing.T, ctrl *gomock.Controller) Uploader {
+				u := mock_s3.NewMockUploader(ctrl)
+				u.
+					EXPECT().
+					Upload(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.PutObjectInput, _ ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+						assert.Equal(t, types.ObjectCannedACLPublicRead, input.ACL)
+						return new(manager.UploadOutput), nil
+					})
+
+				return u
+			},
+		},
+		{
+			desc: "Put() - happy path",
+			uploader: func(t *testing.T, ctrl *gomock.Controller) Uploader {
+				u := mock_s3.NewMockUploader(ctrl)
+				u.
+					EXPECT().
+					Upload(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.PutObjectInput, _ ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+						assert.Equal(t, bucket, aws.ToString(input.Bucket))
+						assert.Equal(t, "key", aws.ToString(input.Key))
+						assert.Equal(t, types.ObjectCannedACLPrivate, input.ACL)
+						assert.Equal(t, body, input.Body)
+						assert.Equal(t, "image/png", aws.ToString(input.ContentType))
+						assert.Equal(t, types.ServerSideEncryptionAwsKms, input.ServerSideEncryption)
+						assert.Equal(t, "key-id", aws.ToString(input.SSEKMSKeyId))
+						assert.Equal(t, types.StorageClassStandardIa, input.StorageClass)
+						assert.Equal(t, "max-age=86400", aws.ToString(input.CacheControl))
+						assert.Equal(t, "attachment", aws.ToString(input.ContentDisposition))
+						assert.Equal(t, map[string]string{"source": "cms"}, input.Metadata)
+
+						return new(manager.UploadOutput), nil
+					})
+
+				return u
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			store, err := NewStore(zap.NewNop(), bucket, mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.uploader = tc.uploader(t, ctrl)
+
+			err = store.Put(context.Background(), "key", body, images.PutOptions{
+				ContentType:          "image/png",
+				ServerSideEncryption: "aws:kms",
+				KMSKeyID:             "key-id",
+				StorageClass:         "STANDARD_IA",
+				CacheControl:         "max-age=86400",
+				ContentDisposition:   "attachment",
+				Metadata:             map[string]string{"source": "cms"},
+				Public:               tc.public,
+			})
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Store_PresignPut(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		presigner func(t *testing.T, ctrl *gomock.Controller) Presigner
+		want      *images.PresignedRequest
+		wantErr   bool
+	}{
+		{
+			desc: "PresignPut() should return an error when failing to presign",
+			presigner: func(t *testing.T, ctrl *gomock.Controller) Presigner {
+				p := mock_s3.NewMockPresigner(ctrl)
+				p.EXPECT().PresignPutObject(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("random"))
+
+				return p
+			},
+			wantErr: true,
+		},
+		{
+			desc: "PresignPut() should return the signed headers except the host",
+			presigner: func(t *testing.T, ctrl *gomock.Controller) Presigner {
+				p := mock_s3.NewMockPresigner(ctrl)
+				p.
+					EXPECT().
+					PresignPutObject(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+						assert.Equal(t, "key", aws.ToString(input.Key))
+						assert.Equal(t, "image/png", aws.ToString(input.ContentType))
+						assert.Nil(t, input.Body)
+
+						return &v4.PresignedHTTPRequest{
+							URL:    "https://bucket.s3.amazonaws.com/key?X-Amz-Signature=sig",
+							Method: "PUT",
+							SignedHeader: http.Header{
+								"Host":         []string{"bucket.s3.amazonaws.com"},
+								"Content-Type": []string{"image/png"},
+							},
+						}, nil
+					})
+
+				return p
+			},
+			want: &images.PresignedRequest{
+				Method:  "PUT",
+				URL:     "https://bucket.s3.amazonaws.com/key?X-Amz-Signature=sig",
+				Headers: map[string]string{"Content-Type": "image/png"},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			store, err := NewStore(zap.NewNop(), "bucket", mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.presigner = tc.presigner(t, ctrl)
+
+			got, err := store.PresignPut(context.Background(), "key", time.Minute, images.PutOptions{ContentType: "image/png"})
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_Store_PutParts(t *testing.T) {
+	bucket := "bucket"
+	body := make([]byte, images.MinPartSize+1)
+	for _, tc := range []struct {
+		desc    string
+		session images.UploadSession
+		client  func(t *testing.T, ctrl *gomock.Controller) Client
+		wantErr error
+	}{
+		{
+			desc:    "PutParts() should return ErrUploadExpired when the upload no longer exists",
+			session: images.UploadSession{Size: int64(len(body))},
+			client: func(t *testing.T, ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					CreateMultipartUpload(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+						assert.Equal(t, types.ObjectCannedACLPrivate, i.ACL)
+						assert.Equal(t, "image/png", aws.ToString(i.ContentType))
+						return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil
+					})
+				c.
+					EXPECT().
+					UploadPart(gomock.Any(), gomock.Any()).
+					Return(nil, &smithy.GenericAPIError{Code: "NoSuchUpload"})
+
+				return c
+			},
+			wantErr: images.ErrUploadExpired,
+		},
+		{
+			desc: "PutParts() should only upload the parts the session is missing",
+			session: images.UploadSession{
+				Size:     int64(len(body)),
+				UploadID: "upload",
+				PartSize: images.MinPartSize,
+				Parts:    []images.Part{{Number: 1, ETag: "etag1"}},
+			},
+			client: func(t *testing.T, ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					UploadPart(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+						assert.Equal(t, "upload", aws.ToString(i.UploadId))
+						assert.Equal(t, int32(2), i.PartNumber)
+						assert.Equal(t, int64(1), i.ContentLength)
+						return &s3.UploadPartOutput{ETag: aws.String("etag2")}, nil
+					})
+				c.
+					EXPECT().
+					CompleteMultipartUpload(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+						require.Len(t, i.MultipartUpload.Parts, 2)
+						assert.Equal(t, "etag1", aws.ToString(i.MultipartUpload.Parts[0].ETag))
+						assert.Equal(t, "etag2", aws.ToString(i.MultipartUpload.Parts[1].ETag))
+						return &s3.CompleteMultipartUploadOutput{}, nil
+					})
+
+				return c
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			store, err := NewStore(zap.NewNop(), bucket, mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.client = tc.client(t, ctrl)
+
+			var checkpoints int
+			checkpoint := func() error {
+				checkpoints++
+				return nil
+			}
+			err = store.PutParts(context.Background(), "key", bytes.NewReader(body), images.PutOptions{ContentType: "image/png"}, &tc.session, checkpoint)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, checkpoints)
+		})
+	}
+}
+
+func mockConfigGetter() (aws.Config, error) {
+	return aws.Config{}, nil
+}
+
+func Test_Store_SetTags(t *testing.T) {
+	bucket := "bucket"
+	for _, tc := range []struct {
+		desc    string
+		client  func(ctrl *gomock.Controller) Client
+		wantErr error
+	}{
+		{
+			desc: "SetTags() should return ErrObjectNotFound when the object does not exist.",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					PutObjectTagging(gomock.Any(), gomock.Any()).
+					Return(nil, &smithy.GenericAPIError{Code: "NoSuchKey"})
+
+				return c
+			},
+			wantErr: images.ErrObjectNotFound,
+		},
+		{
+			desc: "SetTags() - happy path",
+			client: func(ctrl *gomock.Controller) Client {
+				c := mock_s3.NewMockClient(ctrl)
+				c.
+					EXPECT().
+					PutObjectTagging(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, i *s3.PutObjectTaggingInput, _ ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+						assert.Equal(t, "key", aws.ToString(i.Key))
+						assert.Equal(t, bucket, aws.ToString(i.Bucket))
+						assert.Equal(t, []types.Tag{
+							{Key: aws.String("cost-center"), Value: aws.String("eng")},
+							{Key: aws.String("vacation"), Value: aws.String("")},
+						}, i.Tagging.TagSet)
+
+						return nil, nil
+					})
+
+				return c
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			store, err := NewStore(zap.NewNop(), bucket, mockConfigGetter)
+			require.NoError(t, err)
+			store.sdk.client = tc.client(ctrl)
+
+			err = store.SetTags(context.Background(), "key", []string{"vacation", "cost-center=eng"})
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// WithPathStyle returns the S3 client options which address the bucket in
+// the path of the URL, i.e. https://s3.region.amazonaws.com/bucket/key,
+// rather than in its host.
+func WithPathStyle() func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.UsePathStyle = true
+	}
+}
+
+// addressing is how the objects of the bucket are addressed, taken from the
+// S3 client options the store was created with.
+type addressing struct {
+	endpoint  string
+	pathStyle bool
+	region    string
+}
+
+// newAddressing returns the addressing of the client options, a custom
+// endpoint is resolved for the region.
+func newAddressing(region string, optFns ...func(*s3.Options)) addressing {
+	opts := s3.Options{Region: region}
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	a := addressing{pathStyle: opts.UsePathStyle, region: opts.Region}
+	if opts.EndpointResolver != nil {
+		if e, err := opts.EndpointResolver.ResolveEndpoint(opts.Region, s3.EndpointResolverOptions{}); err == nil {
+			a.endpoint = strings.TrimSuffix(e.URL, "/")
+		}
+	}
+
+	return a
+}
+
+// URL returns the stable URL of the object, which anyone can read through
+// when the object is public. The bucket is in the host of the URL, i.e.
+// https://bucket.s3.region.amazonaws.com/key, unless the store uses path
+// style addressing. Transfer Acceleration is not used for the URL.
+func (s *Store) URL(ctx context.Context, key string) (string, error) {
+	return s.addressing.objectURL(s.bucket, key), nil
+}
+
+// objectURL returns the URL of the object in the bucket.
+func (a addressing) objectURL(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	path := "/" + strings.Join(segments, "/")
+
+	endpoint := a.endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + a.region + ".amazonaws.com"
+	}
+	if a.pathStyle {
+		return endpoint + "/" + bucket + path
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint + "/" + bucket + path
+	}
+	u.Host = bucket + "." + u.Host
+
+	return u.String() + path
+}
@@ -0,0 +1,51 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_addressing_objectURL(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		addressing addressing
+		key        string
+		want       string
+	}{
+		{
+			desc:       "objectURL() should address the bucket in the host",
+			addressing: newAddressing("us-east-1"),
+			key:        "images/1/cat.png",
+			want:       "https://bucket.s3.us-east-1.amazonaws.com/images/1/cat.png",
+		},
+		{
+			desc:       "objectURL() should address the bucket in the path with path style",
+			addressing: newAddressing("eu-west-1", WithPathStyle()),
+			key:        "images/1/cat.png",
+			want:       "https://s3.eu-west-1.amazonaws.com/bucket/images/1/cat.png",
+		},
+		{
+			desc:       "objectURL() should use the custom endpoint",
+			addressing: newAddressing("us-east-1", WithEndpoint("http://localhost:4566/")),
+			key:        "images/1/cat.png",
+			want:       "http://localhost:4566/bucket/images/1/cat.png",
+		},
+		{
+			desc:       "objectURL() should escape the segments of the key",
+			addressing: newAddressing("us-east-1"),
+			key:        "images/1/my cat?.png",
+			want:       "https://bucket.s3.us-east-1.amazonaws.com/images/1/my%20cat%3F.png",
+		},
+		{
+			desc:       "objectURL() should not use the accelerate endpoint",
+			addressing: newAddressing("us-east-1", WithAccelerate()),
+			key:        "images/1/cat.png",
+			want:       "https://bucket.s3.us-east-1.amazonaws.com/images/1/cat.png",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.addressing.objectURL("bucket", tc.key))
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const readerLoggerName = "schema.reader"
+
+// Reader decorates an images.Reader, upgrading the records it reads to the
+// current Version.
+type Reader struct {
+	logger  *zap.Logger
+	reader  images.Reader
+	rewrite images.Writer
+}
+
+// ReaderOption provides a way to configure the reader.
+type ReaderOption func(*Reader)
+
+// WithRewrite writes upgraded records back using the writer so that they are
+// only upgraded once. Failing to rewrite a record does not fail the read.
+func WithRewrite(writer images.Writer) ReaderOption {
+	return func(r *Reader) {
+		r.rewrite = writer
+	}
+}
+
+// NewReader returns an instantiated instance of an upgrading reader which has
+// the following dependencies:
+//
+// logger: for structured logging
+//
+// reader: the reader whose records are upgraded
+func NewReader(logger *zap.Logger, reader images.Reader, opts ...ReaderOption) (*Reader, error) {
+	r := Reader{
+		logger: logger.Named(readerLoggerName),
+		reader: reader,
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Debug("successfully initialized schema reader")
+
+	return &r, nil
+}
+
+func (r *Reader) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "logger",
+			chk: func() bool { return r.logger != nil },
+		},
+		{
+			dep: "reader",
+			chk: func() bool { return r.reader != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize reader due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Count counts the records using the underlying reader.
+func (r *Reader) Count(ctx context.Context, filter images.ListFilter) (int, error) {
+	return r.reader.Count(ctx, filter)
+}
+
+// Get returns the upgraded image record.
+func (r *Reader) Get(ctx context.Context, id string) (*images.Record, error) {
+	rec, err := r.reader.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.upgrade(ctx, rec)
+
+	return rec, nil
+}
+
+// GetByName returns the upgraded image record.
+func (r *Reader) GetByName(ctx context.Context, name string) (*images.Record, error) {
+	rec, err := r.reader.GetByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r.upgrade(ctx, rec)
+
+	return rec, nil
+}
+
+// GetByIDPrefix returns the image records with the prefix upgraded.
+func (r *Reader) GetByIDPrefix(ctx context.Context, prefix string, limit int) ([]images.Record, error) {
+	list, err := r.reader.GetByIDPrefix(ctx, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		r.upgrade(ctx, &list[i])
+	}
+
+	return list, nil
+}
+
+// List returns the page with its records upgraded.
+func (r *Reader) List(ctx context.Context, opts images.ListOptions) (*images.Page, error) {
+	page, err := r.reader.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Records {
+		r.upgrade(ctx, &page.Records[i])
+	}
+
+	return page, nil
+}
+
+func (r *Reader) upgrade(ctx context.Context, rec *images.Record) {
+	from := rec.SchemaVersion
+	if !Upgrade(rec) {
+		return
+	}
+
+	logger := r.logger.With(
+		zap.String("imageId", rec.ID),
+		zap.Int("from", from),
+		zap.Int("to", rec.SchemaVersion),
+	)
+	logger.Debug("upgraded image record")
+
+	if r.rewrite == nil {
+		return
+	}
+	// the revision guards against overwriting a record written since it was
+	// read, the newer write already has the current schema
+	if err := r.rewrite.Update(ctx, rec); err != nil {
+		logger.Error("unable to rewrite upgraded image record", zap.Error(err))
+	}
+}
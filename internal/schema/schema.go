@@ -0,0 +1,37 @@
+// Package schema versions the image records, upgrading records written by
+// older versions as they are read by decorating an images.Reader and
+// images.Writer.
+//
+// Adding a field which needs a value for existing records is done by bumping
+// Version and appending an upgrader which fills it in.
+package schema
+
+import "github.com/itsHabib/sim/internal/images"
+
+// Version is the current version of the record schema.
+const Version = 1
+
+// upgraders[v] upgrades a record from version v to v+1.
+var upgraders = []func(rec *images.Record){
+	// records before version 1 did not track when they were last written
+	func(rec *images.Record) {
+		if rec.UpdatedAt == nil {
+			rec.UpdatedAt = rec.CreatedAt
+		}
+	},
+}
+
+// Upgrade upgrades the record to the current Version, returning true if the
+// record was upgraded. Records from a newer version are left untouched.
+func Upgrade(rec *images.Record) bool {
+	if rec.SchemaVersion >= Version {
+		return false
+	}
+
+	for v := rec.SchemaVersion; v < Version; v++ {
+		upgraders[v](rec)
+	}
+	rec.SchemaVersion = Version
+
+	return true
+}
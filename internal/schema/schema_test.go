@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/memory"
+)
+
+func Test_Upgrade(t *testing.T) {
+	created := time.Now().UTC()
+	for _, tc := range []struct {
+		desc    string
+		rec     images.Record
+		want    images.Record
+		changed bool
+	}{
+		{
+			desc:    "Upgrade() should fill in UpdatedAt from CreatedAt for version 0 records",
+			rec:     images.Record{ID: "id", CreatedAt: &created},
+			want:    images.Record{ID: "id", CreatedAt: &created, UpdatedAt: &created, SchemaVersion: Version},
+			changed: true,
+		},
+		{
+			desc: "Upgrade() should leave current records untouched",
+			rec:  images.Record{ID: "id", CreatedAt: &created, SchemaVersion: Version},
+			want: images.Record{ID: "id", CreatedAt: &created, SchemaVersion: Version},
+		},
+		{
+			desc: "Upgrade() should leave records from newer versions untouched",
+			rec:  images.Record{ID: "id", SchemaVersion: Version + 1},
+			want: images.Record{ID: "id", SchemaVersion: Version + 1},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			rec := tc.rec
+			assert.Equal(t, tc.changed, Upgrade(&rec))
+			assert.Equal(t, tc.want, rec)
+		})
+	}
+}
+
+func Test_Reader(t *testing.T) {
+	created := time.Now().UTC()
+	legacy := images.Record{ID: "id", Name: "name", CreatedAt: &created}
+
+	t.Run("Get() should upgrade the record without rewriting it", func(t *testing.T) {
+		records, err := memory.NewRecords(zap.NewNop(), legacy)
+		require.NoError(t, err)
+		r, err := NewReader(zap.NewNop(), records)
+		require.NoError(t, err)
+
+		rec, err := r.Get(context.Background(), legacy.ID)
+		require.NoError(t, err)
+		assert.Equal(t, Version, rec.SchemaVersion)
+
+		stored, err := records.Get(context.Background(), legacy.ID)
+		require.NoError(t, err)
+		assert.Zero(t, stored.SchemaVersion)
+	})
+
+	t.Run("List() should rewrite the upgraded records", func(t *testing.T) {
+		records, err := memory.NewRecords(zap.NewNop(), legacy)
+		require.NoError(t, err)
+		r, err := NewReader(zap.NewNop(), records, WithRewrite(records))
+		require.NoError(t, err)
+
+		page, err := r.List(context.Background(), images.ListOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, Version, page.Records[0].SchemaVersion)
+
+		stored, err := records.GetByName(context.Background(), legacy.Name)
+		require.NoError(t, err)
+		assert.Equal(t, Version, stored.SchemaVersion)
+	})
+}
+
+func Test_Writer(t *testing.T) {
+	records, err := memory.NewRecords(zap.NewNop())
+	require.NoError(t, err)
+	w, err := NewWriter(zap.NewNop(), records)
+	require.NoError(t, err)
+
+	require.NoError(t, w.Create(context.Background(), &images.Record{ID: "a"}))
+	require.NoError(t, w.CreateBatch(context.Background(), []images.Record{{ID: "b"}}))
+
+	for _, id := range []string{"a", "b"} {
+		rec, err := records.Get(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, Version, rec.SchemaVersion)
+	}
+}
@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const writerLoggerName = "schema.writer"
+
+// Writer decorates an images.Writer, stamping the records it writes with the
+// current Version.
+type Writer struct {
+	logger *zap.Logger
+	writer images.Writer
+}
+
+// NewWriter returns an instantiated instance of a stamping writer which has
+// the following dependencies:
+//
+// logger: for structured logging
+//
+// writer: the writer whose records are stamped
+func NewWriter(logger *zap.Logger, writer images.Writer) (*Writer, error) {
+	w := Writer{
+		logger: logger.Named(writerLoggerName),
+		writer: writer,
+	}
+
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+
+	w.logger.Debug("successfully initialized schema writer")
+
+	return &w, nil
+}
+
+func (w *Writer) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "logger",
+			chk: func() bool { return w.logger != nil },
+		},
+		{
+			dep: "writer",
+			chk: func() bool { return w.writer != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize writer due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Create stamps and creates the record.
+func (w *Writer) Create(ctx context.Context, record *images.Record) error {
+	record.SchemaVersion = Version
+
+	return w.writer.Create(ctx, record)
+}
+
+// CreateBatch stamps and creates the records.
+func (w *Writer) CreateBatch(ctx context.Context, records []images.Record) error {
+	for i := range records {
+		records[i].SchemaVersion = Version
+	}
+
+	return w.writer.CreateBatch(ctx, records)
+}
+
+// Delete deletes the record.
+func (w *Writer) Delete(ctx context.Context, id string) error {
+	return w.writer.Delete(ctx, id)
+}
+
+// DeleteBatch deletes the records.
+func (w *Writer) DeleteBatch(ctx context.Context, ids []string) error {
+	return w.writer.DeleteBatch(ctx, ids)
+}
+
+// Update upgrades and replaces the record.
+func (w *Writer) Update(ctx context.Context, record *images.Record) error {
+	Upgrade(record)
+
+	return w.writer.Update(ctx, record)
+}
@@ -0,0 +1,418 @@
+// Package sftp provides an SFTP implementation of the images.ObjectStore so
+// images can be managed on remote file servers.
+package sftp
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+const (
+	loggerName  = "sftp.store"
+	dialTimeout = time.Second * 10
+)
+
+// Config represents the configuration needed to connect to the remote host
+// using key based authentication.
+type Config struct {
+	// Addr is the host:port of the remote host
+	Addr string
+
+	// User to authenticate as
+	User string
+
+	// KeyFile is the path to the PEM encoded private key
+	KeyFile string
+
+	// KeyPassphrase is used to decrypt the private key, if encrypted
+	KeyPassphrase string
+
+	// KnownHostsFile is the path to the known_hosts file used to verify the
+	// remote host's key. Defaults to ~/.ssh/known_hosts
+	KnownHostsFile string
+}
+
+// Dial connects to the remote host described by the config and returns an
+// SFTP client.
+func Dial(cfg Config) (*sftp.Client, error) {
+	key, err := ioutil.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read private key: %w", err)
+	}
+
+	var signer ssh.Signer
+	if cfg.KeyPassphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.KeyPassphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+
+	knownHosts := cfg.KnownHostsFile
+	if knownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve home directory: %w", err)
+		}
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	hostKeyCallback, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known hosts: %w", err)
+	}
+
+	conn, err := ssh.Dial("tcp", cfg.Addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         dialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to remote host: %w", err)
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to start sftp session: %w", err)
+	}
+
+	return client, nil
+}
+
+// Store provides the SFTP implementation of the images.ObjectStore. Objects
+// are stored as files under the root directory on the remote host using their
+// key as the relative path.
+type Store struct {
+	client *sftp.Client
+	logger *zap.Logger
+	root   string
+}
+
+// NewStore returns an instantiated instance of a store which has the
+// following dependencies:
+//
+// logger: for structured logging
+//
+// client: the SFTP client, see Dial
+//
+// root: the remote directory which holds the objects
+func NewStore(logger *zap.Logger, client *sftp.Client, root string) (*Store, error) {
+	s := Store{
+		client: client,
+		logger: logger.Named(loggerName),
+		root:   path.Clean(root),
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug("successfully initialized sftp store")
+
+	return &s, nil
+}
+
+func (s *Store) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "client",
+			chk: func() bool { return s.client != nil },
+		},
+		{
+			dep: "logger",
+			chk: func() bool { return s.logger != nil },
+		},
+		{
+			dep: "root",
+			chk: func() bool { return s.root != "" && s.root != "." },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize store due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// Delete removes the object's remote file. A missing object is not treated as
+// an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.Remove(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Info("object not found")
+			return nil
+		}
+		const msg = "unable to delete object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+// Get streams the object's remote file into the stream, returning the number
+// of bytes copied.
+func (s *Store) Get(ctx context.Context, key string, stream io.WriterAt) (int64, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	f, err := s.open(key)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(images.NewOffsetWriter(stream), f)
+	if err != nil {
+		const msg = "unable to download object"
+		logger.Error(msg, zap.Error(err))
+		return n, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// GetRange copies the byte range of the object's remote file into w, returning
+// the number of bytes copied.
+func (s *Store) GetRange(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error) {
+	logger := s.logger.With(zap.String("key", key), zap.Int64("offset", offset), zap.Int64("length", length))
+
+	f, err := s.open(key)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		const msg = "unable to stat object"
+		logger.Error(msg, zap.Error(err))
+		return 0, fmt.Errorf(msg+": %w", err)
+	}
+	n, err := images.CopyRange(w, f, fi.Size(), offset, length)
+	switch err {
+	case nil:
+	case images.ErrInvalidRange:
+		logger.Error("range is not satisfiable", zap.Int64("size", fi.Size()))
+		return 0, err
+	default:
+		const msg = "unable to download object range"
+		logger.Error(msg, zap.Error(err))
+		return n, fmt.Errorf(msg+": %w", err)
+	}
+
+	return n, nil
+}
+
+// Head retrieves the metadata of the object. The ETag is the hex encoded MD5
+// digest of the remote file which requires reading it in full.
+func (s *Store) Head(ctx context.Context, key string) (*images.ObjectInfo, error) {
+	logger := s.logger.With(zap.String("key", key))
+
+	f, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		const msg = "unable to hash object"
+		logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return &images.ObjectInfo{
+		ETag:        hex.EncodeToString(h.Sum(nil)),
+		SizeInBytes: n,
+	}, nil
+}
+
+// List returns the objects whose keys start with the prefix. Remote files are
+// not read so the ETags are left empty, temporary files of uploads in
+// progress are skipped.
+func (s *Store) List(ctx context.Context, prefix string) ([]images.ObjectInfo, error) {
+	root := strings.TrimSuffix(s.root, "/")
+
+	var objects []images.ObjectInfo
+	w := s.client.Walk(root)
+	for w.Step() {
+		if err := w.Err(); err != nil {
+			if errors.Is(err, os.ErrNotExist) && w.Path() == root {
+				return nil, nil
+			}
+			const msg = "unable to list objects"
+			s.logger.Error(msg, zap.String("prefix", prefix), zap.Error(err))
+			return nil, fmt.Errorf(msg+": %w", err)
+		}
+
+		info := w.Stat()
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".upload-") {
+			continue
+		}
+		if key := strings.TrimPrefix(w.Path(), root+"/"); strings.HasPrefix(key, prefix) {
+			objects = append(objects, images.ObjectInfo{
+				Key:          key,
+				SizeInBytes:  info.Size(),
+				LastModified: info.ModTime(),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+// Presign is not supported by SFTP and always returns ErrUnsupported.
+func (s *Store) Presign(context.Context, string, time.Duration) (string, error) {
+	return "", images.ErrUnsupported
+}
+
+// PresignPut is not supported by SFTP and always returns ErrUnsupported.
+func (s *Store) PresignPut(context.Context, string, time.Duration, images.PutOptions) (*images.PresignedRequest, error) {
+	return nil, images.ErrUnsupported
+}
+
+// URL is not supported by SFTP, its objects have no URL, and always returns
+// ErrUnsupported.
+func (s *Store) URL(context.Context, string) (string, error) {
+	return "", images.ErrUnsupported
+}
+
+// PutParts is not supported by SFTP and always returns ErrUnsupported.
+func (s *Store) PutParts(context.Context, string, io.ReaderAt, images.PutOptions, *images.UploadSession, func() error) error {
+	return images.ErrUnsupported
+}
+
+// SetTags is not supported by SFTP and always returns ErrUnsupported.
+func (s *Store) SetTags(context.Context, string, []string) error {
+	return images.ErrUnsupported
+}
+
+// Restore is not supported by SFTP and always returns ErrUnsupported.
+func (s *Store) Restore(context.Context, string, images.RestoreTier, int) error {
+	return images.ErrUnsupported
+}
+
+// Put streams the body to the object's remote file. The body is written to a
+// temporary file first and renamed into place so that readers never observe a
+// partially written object. Files have no attributes, the options are
+// ignored.
+func (s *Store) Put(ctx context.Context, key string, body io.Reader, _ images.PutOptions) error {
+	logger := s.logger.With(zap.String("key", key))
+
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	dir := path.Dir(p)
+	if err := s.client.MkdirAll(dir); err != nil {
+		const msg = "unable to create object directory"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	tmp := path.Join(dir, ".upload-"+uuid.New().String())
+	f, err := s.client.Create(tmp)
+	if err != nil {
+		const msg = "unable to create temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+	defer s.client.Remove(tmp)
+
+	if _, err := f.ReadFrom(body); err != nil {
+		f.Close()
+		const msg = "unable to write object"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		const msg = "unable to close temp file"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	if err := s.client.PosixRename(tmp, p); err != nil {
+		const msg = "unable to move object into place"
+		logger.Error(msg, zap.Error(err))
+		return fmt.Errorf(msg+": %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) open(key string) (*sftp.File, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := s.client.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.logger.Error("object not found", zap.String("key", key))
+			return nil, images.ErrObjectNotFound
+		}
+		const msg = "unable to open object"
+		s.logger.Error(msg, zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+
+	return f, nil
+}
+
+// path resolves the key to a remote file path, rejecting keys which would
+// escape the root directory.
+func (s *Store) path(key string) (string, error) {
+	p := path.Join(s.root, key)
+	if !strings.HasPrefix(p, strings.TrimSuffix(s.root, "/")+"/") {
+		return "", fmt.Errorf("invalid object key: %q", key)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,188 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// newTestStore returns a store rooted at /root of an in memory SFTP server
+// served in process.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, sftp.InMemHandler())
+	go server.Serve()
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	s, err := NewStore(zap.NewNop(), client, "/root")
+	require.NoError(t, err)
+
+	return s
+}
+
+func Test_NewStore(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		client  *sftp.Client
+		root    string
+		wantErr bool
+	}{
+		{
+			desc:    "NewStore() should return an error without a client",
+			root:    "/root",
+			wantErr: true,
+		},
+		{
+			desc:    "NewStore() should return an error without a root",
+			client:  &sftp.Client{},
+			wantErr: true,
+		},
+		{
+			desc:   "NewStore() - happy path",
+			client: &sftp.Client{},
+			root:   "/root",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewStore(zap.NewNop(), tc.client, tc.root)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_Store(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Put() and Get() should store and return the object", func(t *testing.T) {
+		s := newTestStore(t)
+
+		require.NoError(t, s.Put(ctx, "images/1/a.png", strings.NewReader("hw"), images.PutOptions{}))
+
+		buf := manager.NewWriteAtBuffer(nil)
+		n, err := s.Get(ctx, "images/1/a.png", buf)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+		assert.Equal(t, []byte("hw"), buf.Bytes())
+	})
+
+	t.Run("Put() should replace an existing object", func(t *testing.T) {
+		s := newTestStore(t)
+
+		require.NoError(t, s.Put(ctx, "a.png", strings.NewReader("old"), images.PutOptions{}))
+		require.NoError(t, s.Put(ctx, "a.png", strings.NewReader("new"), images.PutOptions{}))
+
+		buf := manager.NewWriteAtBuffer(nil)
+		_, err := s.Get(ctx, "a.png", buf)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), buf.Bytes())
+	})
+
+	t.Run("GetRange() should copy the byte range of the object", func(t *testing.T) {
+		s := newTestStore(t)
+		require.NoError(t, s.Put(ctx, "a.png", strings.NewReader("hello world"), images.PutOptions{}))
+
+		var buf bytes.Buffer
+		n, err := s.GetRange(ctx, "a.png", 6, 5, &buf)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), n)
+		assert.Equal(t, "world", buf.String())
+	})
+
+	t.Run("Head() should return the size and MD5 ETag of the object", func(t *testing.T) {
+		s := newTestStore(t)
+		require.NoError(t, s.Put(ctx, "a.png", strings.NewReader("hw"), images.PutOptions{}))
+
+		info, err := s.Head(ctx, "a.png")
+		require.NoError(t, err)
+		assert.Equal(t, &images.ObjectInfo{ETag: "65c2a3d77127c15d068dec7e00e50649", SizeInBytes: 2}, info)
+	})
+
+	t.Run("Head() and Get() should return ErrObjectNotFound for a missing object", func(t *testing.T) {
+		s := newTestStore(t)
+
+		_, err := s.Head(ctx, "missing.png")
+		assert.Equal(t, images.ErrObjectNotFound, err)
+		_, err = s.Get(ctx, "missing.png", manager.NewWriteAtBuffer(nil))
+		assert.Equal(t, images.ErrObjectNotFound, err)
+	})
+
+	t.Run("Delete() should remove the object and ignore a missing one", func(t *testing.T) {
+		s := newTestStore(t)
+		require.NoError(t, s.Put(ctx, "a.png", strings.NewReader("hw"), images.PutOptions{}))
+
+		require.NoError(t, s.Delete(ctx, "a.png"))
+		_, err := s.Head(ctx, "a.png")
+		assert.Equal(t, images.ErrObjectNotFound, err)
+		assert.NoError(t, s.Delete(ctx, "a.png"))
+	})
+
+	t.Run("List() should return the objects under the prefix", func(t *testing.T) {
+		s := newTestStore(t)
+		for _, key := range []string{"images/1/a.png", "images/2/b.png", "thumbs/1/a.jpg"} {
+			require.NoError(t, s.Put(ctx, key, strings.NewReader("hw"), images.PutOptions{}))
+		}
+
+		objects, err := s.List(ctx, "images/")
+		require.NoError(t, err)
+		var keys []string
+		for _, o := range objects {
+			keys = append(keys, o.Key)
+			assert.Equal(t, int64(2), o.SizeInBytes)
+		}
+		assert.ElementsMatch(t, []string{"images/1/a.png", "images/2/b.png"}, keys)
+	})
+
+	t.Run("List() should return no objects before the root exists", func(t *testing.T) {
+		s := newTestStore(t)
+
+		objects, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, objects)
+	})
+
+	t.Run("keys which escape the root should be rejected", func(t *testing.T) {
+		s := newTestStore(t)
+
+		for _, key := range []string{"../a.png", "images/../../a.png", ".."} {
+			assert.Error(t, s.Put(ctx, key, strings.NewReader("hw"), images.PutOptions{}), key)
+			_, err := s.Get(ctx, key, manager.NewWriteAtBuffer(nil))
+			assert.Error(t, err, key)
+			assert.Error(t, s.Delete(ctx, key), key)
+		}
+		objects, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, objects)
+	})
+
+	t.Run("Presign() should return ErrUnsupported", func(t *testing.T) {
+		s := newTestStore(t)
+
+		_, err := s.Presign(ctx, "a.png", time.Minute)
+		assert.True(t, errors.Is(err, images.ErrUnsupported))
+		_, err = s.PresignPut(ctx, "a.png", time.Minute, images.PutOptions{})
+		assert.True(t, errors.Is(err, images.ErrUnsupported))
+	})
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+// lazyStore opens the object store of a profile when it is first used. A
+// failed open is not kept, the next call tries again.
+type lazyStore struct {
+	logger  *zap.Logger
+	name    string
+	profile Profile
+
+	mu    sync.Mutex
+	store images.ObjectStore
+}
+
+func (l *lazyStore) open() (images.ObjectStore, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.store != nil {
+		return l.store, nil
+	}
+	store, err := Open(l.logger, l.profile)
+	if err != nil {
+		const msg = "unable to open storage profile"
+		l.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+" (%s): %w", l.name, err)
+	}
+	l.store = store
+
+	return store, nil
+}
+
+func (l *lazyStore) Delete(ctx context.Context, key string) error {
+	store, err := l.open()
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(ctx, key)
+}
+
+func (l *lazyStore) Get(ctx context.Context, key string, stream io.WriterAt) (int64, error) {
+	store, err := l.open()
+	if err != nil {
+		return 0, err
+	}
+
+	return store.Get(ctx, key, stream)
+}
+
+func (l *lazyStore) GetRange(ctx context.Context, key string, offset, length int64, w io.Writer) (int64, error) {
+	store, err := l.open()
+	if err != nil {
+		return 0, err
+	}
+
+	return store.GetRange(ctx, key, offset, length, w)
+}
+
+func (l *lazyStore) Head(ctx context.Context, key string) (*images.ObjectInfo, error) {
+	store, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Head(ctx, key)
+}
+
+func (l *lazyStore) List(ctx context.Context, prefix string) ([]images.ObjectInfo, error) {
+	store, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.List(ctx, prefix)
+}
+
+func (l *lazyStore) Presign(ctx context.Context, key string, expires time.Duration) (string, error) {
+	store, err := l.open()
+	if err != nil {
+		return "", err
+	}
+
+	return store.Presign(ctx, key, expires)
+}
+
+func (l *lazyStore) PresignPut(ctx context.Context, key string, expires time.Duration, opts images.PutOptions) (*images.PresignedRequest, error) {
+	store, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.PresignPut(ctx, key, expires, opts)
+}
+
+func (l *lazyStore) Put(ctx context.Context, key string, body io.Reader, opts images.PutOptions) error {
+	store, err := l.open()
+	if err != nil {
+		return err
+	}
+
+	return store.Put(ctx, key, body, opts)
+}
+
+func (l *lazyStore) PutParts(ctx context.Context, key string, body io.ReaderAt, opts images.PutOptions, session *images.UploadSession, checkpoint func() error) error {
+	store, err := l.open()
+	if err != nil {
+		return err
+	}
+
+	return store.PutParts(ctx, key, body, opts, session, checkpoint)
+}
+
+func (l *lazyStore) URL(ctx context.Context, key string) (string, error) {
+	store, err := l.open()
+	if err != nil {
+		return "", err
+	}
+
+	return store.URL(ctx, key)
+}
+
+func (l *lazyStore) SetTags(ctx context.Context, key string, tags []string) error {
+	store, err := l.open()
+	if err != nil {
+		return err
+	}
+
+	return store.SetTags(ctx, key, tags)
+}
+
+func (l *lazyStore) Restore(ctx context.Context, key string, tier images.RestoreTier, days int) error {
+	store, err := l.open()
+	if err != nil {
+		return err
+	}
+
+	return store.Restore(ctx, key, tier, days)
+}
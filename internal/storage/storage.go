@@ -0,0 +1,233 @@
+// Package storage provides the means to configure the object stores that hold
+// images as named profiles.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	gcsStorage "cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+
+	"github.com/itsHabib/sim/internal/filesystem"
+	"github.com/itsHabib/sim/internal/gcs"
+	"github.com/itsHabib/sim/internal/images"
+	"github.com/itsHabib/sim/internal/s3"
+	"github.com/itsHabib/sim/internal/sftp"
+)
+
+const (
+	ProviderFS   = "fs"
+	ProviderGCS  = "gcs"
+	ProviderS3   = "s3"
+	ProviderSFTP = "sftp"
+)
+
+// Profile represents the configuration of a single storage target. Which
+// fields are used depends on the provider.
+type Profile struct {
+	// Provider is the storage backend, one of: s3, gcs, fs, sftp
+	Provider string `json:"provider"`
+
+	// Bucket holds the objects (s3, gcs)
+	Bucket string `json:"bucket"`
+
+	// Region of the bucket (s3)
+	Region string `json:"region"`
+
+	// Endpoint overrides the provider's endpoint i.e. localstack or an
+	// emulator (s3, gcs)
+	Endpoint string `json:"endpoint"`
+
+	// ReplicaBucket and ReplicaRegion configure a replica of the bucket which
+	// downloads fall back to when the primary region is unavailable (s3)
+	ReplicaBucket string `json:"replicaBucket"`
+	ReplicaRegion string `json:"replicaRegion"`
+
+	// Accelerate uses the bucket's Transfer Acceleration endpoint, it can not
+	// be combined with a custom endpoint (s3)
+	Accelerate bool `json:"accelerate"`
+
+	// PathStyle addresses the bucket in the path of its URLs rather than in
+	// their host, it is implied by a custom endpoint (s3)
+	PathStyle bool `json:"pathStyle"`
+
+	// AccessKeyID and SecretAccessKey are static credentials, when not set the
+	// default credential chain is used (s3)
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+
+	// CredentialsFile is the service account credentials file (gcs)
+	CredentialsFile string `json:"credentialsFile"`
+
+	// Root is the directory which holds the objects (fs, sftp)
+	Root string `json:"root"`
+
+	// Addr, User, KeyFile, KeyPassphrase and KnownHostsFile configure the
+	// connection to the remote host (sftp)
+	Addr           string `json:"addr"`
+	User           string `json:"user"`
+	KeyFile        string `json:"keyFile"`
+	KeyPassphrase  string `json:"keyPassphrase"`
+	KnownHostsFile string `json:"knownHostsFile"`
+}
+
+// LoadProfiles reads the named profiles from a JSON file which maps profile
+// names to their configuration. The profiles are validated but not opened.
+func LoadProfiles(path string) (map[string]Profile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read profiles: %w", err)
+	}
+
+	var profiles map[string]Profile
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal profiles: %w", err)
+	}
+
+	if len(profiles) == 0 {
+		return nil, errors.New("no storage profiles found")
+	}
+	for name, p := range profiles {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("invalid storage profile (%s): %w", name, err)
+		}
+	}
+
+	return profiles, nil
+}
+
+// validate checks the profile's configuration without connecting to its
+// provider.
+func (p Profile) validate() error {
+	switch p.Provider {
+	case ProviderS3, "":
+		if p.Bucket == "" || p.Region == "" {
+			return errors.New("bucket and region are required for the s3 provider")
+		}
+		if p.Accelerate && (p.Endpoint != "" || p.PathStyle) {
+			return errors.New("transfer acceleration can not be used with a custom endpoint or path style addressing")
+		}
+	case ProviderGCS:
+		if p.Bucket == "" {
+			return errors.New("bucket is required for the gcs provider")
+		}
+	case ProviderFS:
+		if p.Root == "" {
+			return errors.New("root is required for the fs provider")
+		}
+	case ProviderSFTP:
+		if p.Addr == "" || p.User == "" || p.KeyFile == "" || p.Root == "" {
+			return errors.New("addr, user, keyFile and root are required for the sftp provider")
+		}
+	default:
+		return fmt.Errorf("unsupported storage provider: %q", p.Provider)
+	}
+
+	return nil
+}
+
+// OpenAll returns an object store for every valid profile, keyed by profile
+// name. A store is only opened when it is first used, so that a profile
+// whose host is unreachable does not fail the commands which never touch it.
+func OpenAll(logger *zap.Logger, profiles map[string]Profile) (images.Stores, error) {
+	stores := make(images.Stores, len(profiles))
+	for name, p := range profiles {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("invalid storage profile (%s): %w", name, err)
+		}
+		stores[name] = &lazyStore{
+			logger:  logger.With(zap.String("storage", name)),
+			name:    name,
+			profile: p,
+		}
+	}
+
+	return stores, nil
+}
+
+// Open returns the object store described by the profile, connecting to its
+// provider.
+func Open(logger *zap.Logger, p Profile) (images.ObjectStore, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	switch p.Provider {
+	case ProviderGCS:
+		var opts []option.ClientOption
+		if p.Endpoint != "" {
+			opts = append(opts, option.WithEndpoint(p.Endpoint), option.WithoutAuthentication())
+		}
+		if p.CredentialsFile != "" {
+			opts = append(opts, option.WithCredentialsFile(p.CredentialsFile))
+		}
+		client, err := gcsStorage.NewClient(context.Background(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get gcs client: %w", err)
+		}
+		return gcs.NewStore(logger, client, p.Bucket)
+	case ProviderFS:
+		return filesystem.NewStore(logger, p.Root)
+	case ProviderSFTP:
+		client, err := sftp.Dial(sftp.Config{
+			Addr:           p.Addr,
+			User:           p.User,
+			KeyFile:        p.KeyFile,
+			KeyPassphrase:  p.KeyPassphrase,
+			KnownHostsFile: p.KnownHostsFile,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get sftp client: %w", err)
+		}
+		return sftp.NewStore(logger, client, p.Root)
+	}
+
+	var optFns []func(*awsS3.Options)
+	if p.Endpoint != "" {
+		optFns = append(optFns, s3.WithEndpoint(p.Endpoint))
+	}
+	if p.Accelerate {
+		optFns = append(optFns, s3.WithAccelerate())
+	}
+	if p.PathStyle {
+		optFns = append(optFns, s3.WithPathStyle())
+	}
+	store, err := s3.NewStore(logger, p.Bucket, images.WithConfigOptions(awsConfigOptions(p)...), optFns...)
+	if err != nil || p.ReplicaBucket == "" {
+		return store, err
+	}
+
+	replica := p
+	replica.Bucket = p.ReplicaBucket
+	if p.ReplicaRegion != "" {
+		replica.Region = p.ReplicaRegion
+	}
+	replicaStore, err := s3.NewStore(logger.With(zap.Bool("replica", true)), replica.Bucket, images.WithConfigOptions(awsConfigOptions(replica)...), optFns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFailoverStore(logger, store, replicaStore)
+}
+
+func awsConfigOptions(p Profile) []func(*config.LoadOptions) error {
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(p.Region),
+	}
+
+	if p.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(p.AccessKeyID, p.SecretAccessKey, ""),
+		))
+	}
+
+	return opts
+}
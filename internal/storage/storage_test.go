@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func Test_LoadProfiles(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		json    string
+		want    map[string]Profile
+		wantErr bool
+	}{
+		{
+			desc: "LoadProfiles() should return the profiles of the file",
+			json: `{
+				"primary": {"provider": "s3", "bucket": "sim", "region": "us-east-1"},
+				"offline": {"provider": "fs", "root": "/var/lib/sim"}
+			}`,
+			want: map[string]Profile{
+				"primary": {Provider: ProviderS3, Bucket: "sim", Region: "us-east-1"},
+				"offline": {Provider: ProviderFS, Root: "/var/lib/sim"},
+			},
+		},
+		{
+			desc: "LoadProfiles() should default to the s3 provider",
+			json: `{"primary": {"bucket": "sim", "region": "us-east-1"}}`,
+			want: map[string]Profile{
+				"primary": {Bucket: "sim", Region: "us-east-1"},
+			},
+		},
+		{
+			desc:    "LoadProfiles() should return an error for invalid JSON",
+			json:    `{"primary": {`,
+			wantErr: true,
+		},
+		{
+			desc:    "LoadProfiles() should return an error when there are no profiles",
+			json:    `{}`,
+			wantErr: true,
+		},
+		{
+			desc:    "LoadProfiles() should return an error for an unknown provider",
+			json:    `{"primary": {"provider": "azure", "bucket": "sim"}}`,
+			wantErr: true,
+		},
+		{
+			desc:    "LoadProfiles() should return an error when an s3 profile has no bucket",
+			json:    `{"primary": {"provider": "s3", "region": "us-east-1"}}`,
+			wantErr: true,
+		},
+		{
+			desc:    "LoadProfiles() should return an error when an s3 profile has no region",
+			json:    `{"primary": {"provider": "s3", "bucket": "sim"}}`,
+			wantErr: true,
+		},
+		{
+			desc:    "LoadProfiles() should return an error when acceleration is combined with an endpoint",
+			json:    `{"primary": {"provider": "s3", "bucket": "sim", "region": "us-east-1", "accelerate": true, "endpoint": "http://localhost:4566"}}`,
+			wantErr: true,
+		},
+		{
+			desc:    "LoadProfiles() should return an error when a gcs profile has no bucket",
+			json:    `{"archive": {"provider": "gcs"}}`,
+			wantErr: true,
+		},
+		{
+			desc:    "LoadProfiles() should return an error when an sftp profile has no key file",
+			json:    `{"remote": {"provider": "sftp", "addr": "localhost:22", "user": "sim", "root": "/srv"}}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profiles.json")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tc.json), 0o600))
+
+			got, err := LoadProfiles(path)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_LoadProfiles_Missing(t *testing.T) {
+	_, err := LoadProfiles(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func Test_Open(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		profile Profile
+		wantErr bool
+	}{
+		{
+			desc:    "Open() should open an fs profile",
+			profile: Profile{Provider: ProviderFS, Root: t.TempDir()},
+		},
+		{
+			desc:    "Open() should open an s3 profile",
+			profile: Profile{Provider: ProviderS3, Bucket: "sim", Region: "us-east-1", AccessKeyID: "images", SecretAccessKey: "secret"},
+		},
+		{
+			desc:    "Open() should open an s3 profile with a replica",
+			profile: Profile{Provider: ProviderS3, Bucket: "sim", Region: "us-east-1", ReplicaBucket: "sim-replica", ReplicaRegion: "us-west-2", AccessKeyID: "images", SecretAccessKey: "secret"},
+		},
+		{
+			desc:    "Open() should return an error for an unknown provider",
+			profile: Profile{Provider: "azure"},
+			wantErr: true,
+		},
+		{
+			desc:    "Open() should return an error for an invalid profile",
+			profile: Profile{Provider: ProviderS3, Bucket: "sim"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			store, err := Open(zap.NewNop(), tc.profile)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, store)
+		})
+	}
+}
+
+func Test_OpenAll(t *testing.T) {
+	root := t.TempDir()
+	profiles := map[string]Profile{
+		"offline": {Provider: ProviderFS, Root: root},
+		// nothing listens on the address, the profile fails only when used
+		"remote": {Provider: ProviderSFTP, Addr: "127.0.0.1:1", User: "sim", KeyFile: filepath.Join(root, "missing"), Root: "/srv"},
+	}
+
+	stores, err := OpenAll(zap.NewNop(), profiles)
+	require.NoError(t, err)
+	require.Len(t, stores, 2)
+
+	_, err = stores["offline"].List(context.Background(), "")
+	assert.NoError(t, err)
+
+	_, err = stores["remote"].Head(context.Background(), "a.png")
+	assert.Error(t, err)
+}
+
+func Test_OpenAll_Invalid(t *testing.T) {
+	_, err := OpenAll(zap.NewNop(), map[string]Profile{"primary": {Provider: ProviderS3}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package textract
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	"github.com/aws/aws-sdk-go-v2/service/textract/types"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+)
+
+//go:generate go run github.com/golang/mock/mockgen -destination mocks/client.go github.com/itsHabib/sim/internal/textract Client
+
+const loggerName = "textract.extractor"
+
+// Client provides an abstraction to aid in mocking for unit tests
+type Client interface {
+	// DetectDocumentText detects the text in the input document, returned as
+	// the blocks of its pages, lines and words in reading order.
+	DetectDocumentText(ctx context.Context, params *textract.DetectDocumentTextInput, optFns ...func(*textract.Options)) (*textract.DetectDocumentTextOutput, error)
+}
+
+// Extractor provides the Textract implementation of the
+// images.TextExtractor.
+type Extractor struct {
+	client       Client
+	clientOpts   []func(*textract.Options)
+	configGetter images.ConfigGetter
+	logger       *zap.Logger
+}
+
+// Option provides the means to configure optional behavior of the
+// extractor.
+type Option func(e *Extractor)
+
+// WithEndpoint calls the Textract API at the endpoint instead of the
+// endpoint of the region, i.e. localstack.
+func WithEndpoint(endpoint string) Option {
+	return func(e *Extractor) {
+		e.clientOpts = append(e.clientOpts, func(o *textract.Options) {
+			o.EndpointResolver = textract.EndpointResolverFromURL(endpoint)
+		})
+	}
+}
+
+// NewExtractor returns an instantiated instance of an extractor which has
+// the following dependencies:
+//
+// logger: for structured logging
+//
+// configGetter: for loading the AWS config holding the credentials and region
+func NewExtractor(logger *zap.Logger, configGetter images.ConfigGetter, opts ...Option) (*Extractor, error) {
+	e := Extractor{
+		configGetter: configGetter,
+		logger:       logger.Named(loggerName),
+	}
+	for i := range opts {
+		opts[i](&e)
+	}
+
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := e.configGetter()
+	if err != nil {
+		const msg = "unable to get AWS config"
+		e.logger.Error(msg, zap.Error(err))
+		return nil, fmt.Errorf(msg+": %w", err)
+	}
+	e.client = textract.NewFromConfig(cfg, e.clientOpts...)
+
+	e.logger.Debug("successfully initialized textract extractor")
+
+	return &e, nil
+}
+
+func (e *Extractor) validate() error {
+	var missingDeps []string
+
+	for _, tc := range []struct {
+		dep string
+		chk func() bool
+	}{
+		{
+			dep: "logger",
+			chk: func() bool { return e.logger != nil },
+		},
+		{
+			dep: "configGetter",
+			chk: func() bool { return e.configGetter != nil },
+		},
+	} {
+		if !tc.chk() {
+			missingDeps = append(missingDeps, tc.dep)
+		}
+	}
+
+	if len(missingDeps) > 0 {
+		return fmt.Errorf(
+			"unable to initialize extractor due to (%d) missing dependencies: %s",
+			len(missingDeps),
+			strings.Join(missingDeps, ","),
+		)
+	}
+
+	return nil
+}
+
+// ExtractText detects the lines of text of the JPEG image, which must be at
+// most 5MB, and returns them joined by newlines.
+func (e *Extractor) ExtractText(ctx context.Context, jpeg []byte) (string, error) {
+	input := textract.DetectDocumentTextInput{
+		Document: &types.Document{Bytes: jpeg},
+	}
+	out, err := e.client.DetectDocumentText(ctx, &input)
+	if err != nil {
+		const msg = "unable to detect document text"
+		e.logger.Error(msg, zap.Error(err))
+		return "", fmt.Errorf(msg+": %w", err)
+	}
+
+	var lines []string
+	for _, b := range out.Blocks {
+		if b.BlockType == types.BlockTypeLine && aws.ToString(b.Text) != "" {
+			lines = append(lines, aws.ToString(b.Text))
+		}
+	}
+	e.logger.Debug("detected document text", zap.Int("lines", len(lines)))
+
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,99 @@
+package textract
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	"github.com/aws/aws-sdk-go-v2/service/textract/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/itsHabib/sim/internal/images"
+	mock_textract "github.com/itsHabib/sim/internal/textract/mocks"
+)
+
+func Test_NewExtractor(t *testing.T) {
+	for _, tc := range []struct {
+		desc         string
+		configGetter images.ConfigGetter
+		wantErr      bool
+	}{
+		{
+			desc:         "NewExtractor() should return an error when failing to get the config",
+			configGetter: func() (aws.Config, error) { return aws.Config{}, errors.New("random") },
+			wantErr:      true,
+		},
+		{
+			desc:         "NewExtractor() should create the SDK client",
+			configGetter: func() (aws.Config, error) { return aws.Config{Region: "us-east-1"}, nil },
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			e, err := NewExtractor(zap.NewNop(), tc.configGetter, WithEndpoint("http://localhost:4566"))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, e.client)
+		})
+	}
+}
+
+func Test_Extractor_ExtractText(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		out     *textract.DetectDocumentTextOutput
+		err     error
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "ExtractText() should join the lines Textract detects",
+			out: &textract.DetectDocumentTextOutput{Blocks: []types.Block{
+				{BlockType: types.BlockTypePage},
+				{BlockType: types.BlockTypeLine, Text: aws.String("INVOICE 42")},
+				{BlockType: types.BlockTypeWord, Text: aws.String("INVOICE")},
+				{BlockType: types.BlockTypeWord, Text: aws.String("42")},
+				{BlockType: types.BlockTypeLine, Text: aws.String("Total Due: $10")},
+			}},
+			want: "INVOICE 42\nTotal Due: $10",
+		},
+		{
+			desc: "ExtractText() should return no text for an image without any",
+			out:  &textract.DetectDocumentTextOutput{Blocks: []types.Block{{BlockType: types.BlockTypePage}}},
+		},
+		{
+			desc:    "ExtractText() should return an error when the text can not be detected",
+			err:     errors.New("random"),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			client := mock_textract.NewMockClient(ctrl)
+			client.
+				EXPECT().
+				DetectDocumentText(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, in *textract.DetectDocumentTextInput, _ ...func(*textract.Options)) (*textract.DetectDocumentTextOutput, error) {
+					assert.Equal(t, []byte("jpeg"), in.Document.Bytes)
+					return tc.out, tc.err
+				})
+			e := Extractor{client: client, logger: zap.NewNop()}
+
+			got, err := e.ExtractText(context.Background(), []byte("jpeg"))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/itsHabib/sim/internal/textract (interfaces: Client)
+
+// Package mock_textract is a generated GoMock package.
+package mock_textract
+
+import (
+	context "context"
+	reflect "reflect"
+
+	textract "github.com/aws/aws-sdk-go-v2/service/textract"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockClient is a mock of Client interface.
+type MockClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientMockRecorder
+}
+
+// MockClientMockRecorder is the mock recorder for MockClient.
+type MockClientMockRecorder struct {
+	mock *MockClient
+}
+
+// NewMockClient creates a new mock instance.
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
+	return m.recorder
+}
+
+// DetectDocumentText mocks base method.
+func (m *MockClient) DetectDocumentText(arg0 context.Context, arg1 *textract.DetectDocumentTextInput, arg2 ...func(*textract.Options)) (*textract.DetectDocumentTextOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DetectDocumentText", varargs...)
+	ret0, _ := ret[0].(*textract.DetectDocumentTextOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DetectDocumentText indicates an expected call of DetectDocumentText.
+func (mr *MockClientMockRecorder) DetectDocumentText(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetectDocumentText", reflect.TypeOf((*MockClient)(nil).DetectDocumentText), varargs...)
+}